@@ -0,0 +1,252 @@
+// abbrevfp.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Parsing of STARS abbreviated flight plan entries. The first field is
+// always the aircraft ID; the remaining fields may be given in any order,
+// each at most once, and are identified by their format:
+//
+// Beacon code: four octal digits, e.g. 4312.
+// Aircraft type: TYPE, TYPE/E, N/TYPE, or N/TYPE/E, where TYPE is a
+//   two to four character type designator starting with a letter, E is a
+//   single-letter equipment suffix, and N is either the number of aircraft
+//   (1-99) or a weight class: H (heavy), J (super), S (small), L (large).
+// Requested altitude: three digits, in hundreds of feet, e.g. 120.
+// VFR: "VFR" or "VFR/nnn", where nnn is an optional requested altitude.
+// Primary scratchpad: "*" followed by two to four characters.
+// Secondary scratchpad: "+" followed by one to four characters.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrFPMissingACID       = errors.New("missing aircraft ID")
+	ErrFPInvalidACID       = errors.New("invalid aircraft ID")
+	ErrFPInvalidBeacon     = errors.New("invalid beacon code")
+	ErrFPInvalidType       = errors.New("invalid aircraft type")
+	ErrFPInvalidAltitude   = errors.New("invalid altitude")
+	ErrFPInvalidScratchpad = errors.New("invalid scratchpad")
+	ErrFPUnknownField      = errors.New("unrecognized field")
+	ErrFPDuplicateField    = errors.New("field given more than once")
+)
+
+// AbbreviatedFPError describes an error in one of the fields of an
+// abbreviated flight plan entry.
+type AbbreviatedFPError struct {
+	Field string // The text of the offending field
+	Index int    // Its index in the entry, starting from zero
+	Err   error  // One of the ErrFP* errors
+}
+
+func (e *AbbreviatedFPError) Error() string {
+	return fmt.Sprintf("field %d %q: %v", e.Index+1, e.Field, e.Err)
+}
+
+func (e *AbbreviatedFPError) Unwrap() error { return e.Err }
+
+type AbbreviatedFPFields struct {
+	ACID                string
+	Beacon              Squawk
+	HaveBeacon          bool
+	NumAircraft         int    // Zero if unspecified
+	WeightClass         string // "H", "J", "S", "L", or empty
+	AircraftType        string
+	Equipment           string
+	Rules               FlightRules // UNKNOWN if unspecified
+	RequestedAltitude   int         // Feet; zero if unspecified
+	Scratchpad          string
+	SecondaryScratchpad string
+}
+
+// abbreviatedFPFieldKind identifies the fields that may be given at most
+// once.
+type abbreviatedFPFieldKind int
+
+const (
+	fpFieldBeacon abbreviatedFPFieldKind = iota
+	fpFieldType
+	fpFieldAltitude
+	fpFieldRules
+	fpFieldScratchpad
+	fpFieldSecondaryScratchpad
+)
+
+// ParseAbbreviatedFPFields parses the space-separated fields of an
+// abbreviated flight plan entry. Errors are returned as an
+// *AbbreviatedFPError that identifies the offending field.
+func ParseAbbreviatedFPFields(entry string) (AbbreviatedFPFields, error) {
+	var fp AbbreviatedFPFields
+
+	fields := strings.Fields(entry)
+	if len(fields) == 0 {
+		return fp, &AbbreviatedFPError{Err: ErrFPMissingACID}
+	}
+
+	fieldError := func(i int, err error) error {
+		return &AbbreviatedFPError{Field: fields[i], Index: i, Err: err}
+	}
+
+	if !isValidACID(fields[0]) {
+		return fp, fieldError(0, ErrFPInvalidACID)
+	}
+	fp.ACID = fields[0]
+
+	seen := make(map[abbreviatedFPFieldKind]interface{})
+	for i := 1; i < len(fields); i++ {
+		kinds, err := fp.parseField(fields[i])
+		if err != nil {
+			return fp, fieldError(i, err)
+		}
+		for _, kind := range kinds {
+			if _, ok := seen[kind]; ok {
+				return fp, fieldError(i, ErrFPDuplicateField)
+			}
+			seen[kind] = nil
+		}
+	}
+
+	return fp, nil
+}
+
+// parseField determines which kind of field f is from its format and
+// records its value. It returns the kinds of fields that f provides; for
+// example, "VFR/055" gives both the flight rules and the altitude.
+func (fp *AbbreviatedFPFields) parseField(f string) ([]abbreviatedFPFieldKind, error) {
+	switch {
+	case f[0] == '*':
+		sp := f[1:]
+		if len(sp) < 2 || len(sp) > 4 || !isScratchpadText(sp) {
+			return []abbreviatedFPFieldKind{fpFieldScratchpad}, ErrFPInvalidScratchpad
+		}
+		fp.Scratchpad = sp
+		return []abbreviatedFPFieldKind{fpFieldScratchpad}, nil
+
+	case f[0] == '+':
+		sp := f[1:]
+		if len(sp) < 1 || len(sp) > 4 || !isScratchpadText(sp) {
+			return []abbreviatedFPFieldKind{fpFieldSecondaryScratchpad}, ErrFPInvalidScratchpad
+		}
+		fp.SecondaryScratchpad = sp
+		return []abbreviatedFPFieldKind{fpFieldSecondaryScratchpad}, nil
+
+	case f == "VFR" || strings.HasPrefix(f, "VFR/"):
+		fp.Rules = VFR
+		if alt, ok := strings.CutPrefix(f, "VFR/"); ok {
+			a, err := parseAbbreviatedFPAltitude(alt)
+			if err != nil {
+				return []abbreviatedFPFieldKind{fpFieldRules}, err
+			}
+			fp.RequestedAltitude = a
+			return []abbreviatedFPFieldKind{fpFieldRules, fpFieldAltitude}, nil
+		}
+		return []abbreviatedFPFieldKind{fpFieldRules}, nil
+
+	case isAllNumbers(f):
+		switch len(f) {
+		case 3:
+			alt, err := parseAbbreviatedFPAltitude(f)
+			fp.RequestedAltitude = alt
+			return []abbreviatedFPFieldKind{fpFieldAltitude}, err
+		case 4:
+			sq, err := ParseSquawk(f)
+			if err != nil {
+				return []abbreviatedFPFieldKind{fpFieldBeacon}, ErrFPInvalidBeacon
+			}
+			fp.Beacon, fp.HaveBeacon = sq, true
+			return []abbreviatedFPFieldKind{fpFieldBeacon}, nil
+		default:
+			return []abbreviatedFPFieldKind{fpFieldAltitude}, ErrFPUnknownField
+		}
+
+	case unicode.IsLetter(rune(f[0])) || strings.Contains(f, "/"):
+		return []abbreviatedFPFieldKind{fpFieldType}, fp.parseAircraftType(f)
+
+	default:
+		return []abbreviatedFPFieldKind{fpFieldType}, ErrFPUnknownField
+	}
+}
+
+// parseAircraftType parses an aircraft type field: [N/]TYPE[/E].
+func (fp *AbbreviatedFPFields) parseAircraftType(f string) error {
+	parts := strings.Split(f, "/")
+	if len(parts) > 3 {
+		return ErrFPInvalidType
+	}
+
+	// Is there a prefix giving the number of aircraft or a weight class?
+	if len(parts) == 3 || (len(parts) == 2 && isAircraftCountOrWeight(parts[0])) {
+		if !isAircraftCountOrWeight(parts[0]) {
+			return ErrFPInvalidType
+		}
+		if n, err := strconv.Atoi(parts[0]); err == nil {
+			fp.NumAircraft = n
+		} else {
+			fp.WeightClass = parts[0]
+		}
+		parts = parts[1:]
+	}
+
+	typ := parts[0]
+	if len(typ) < 2 || len(typ) > 4 || !unicode.IsLetter(rune(typ[0])) || !isAlphanumeric(typ) {
+		return ErrFPInvalidType
+	}
+	fp.AircraftType = typ
+
+	if len(parts) == 2 {
+		if eq := parts[1]; len(eq) != 1 || !unicode.IsLetter(rune(eq[0])) {
+			return ErrFPInvalidType
+		} else {
+			fp.Equipment = eq
+		}
+	}
+	return nil
+}
+
+func parseAbbreviatedFPAltitude(s string) (int, error) {
+	if len(s) != 3 || !isAllNumbers(s) {
+		return 0, ErrFPInvalidAltitude
+	}
+	alt, _ := strconv.Atoi(s)
+	if alt == 0 {
+		return 0, ErrFPInvalidAltitude
+	}
+	return alt * 100, nil
+}
+
+func isAircraftCountOrWeight(s string) bool {
+	switch s {
+	case "H", "J", "S", "L":
+		return true
+	}
+	n, err := strconv.Atoi(s)
+	return err == nil && len(s) <= 2 && n >= 1
+}
+
+func isValidACID(s string) bool {
+	return len(s) >= 2 && len(s) <= 7 && unicode.IsLetter(rune(s[0])) && isAlphanumeric(s)
+}
+
+func isScratchpadText(s string) bool {
+	for _, ch := range s {
+		if !strings.ContainsRune("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789./", ch) {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(s string) bool {
+	for _, ch := range s {
+		if !(ch >= 'A' && ch <= 'Z') && !(ch >= '0' && ch <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+// abbrevfp_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseAbbreviatedFPFields(t *testing.T) {
+	for _, test := range []struct {
+		entry    string
+		expected AbbreviatedFPFields
+	}{
+		{"AAL123", AbbreviatedFPFields{ACID: "AAL123"}},
+		{"  N123AB  ", AbbreviatedFPFields{ACID: "N123AB"}},
+		{"AAL123 4312", AbbreviatedFPFields{ACID: "AAL123", Beacon: 0o4312, HaveBeacon: true}},
+		{"AAL123 0000", AbbreviatedFPFields{ACID: "AAL123", Beacon: 0, HaveBeacon: true}},
+		{"AAL123 B738", AbbreviatedFPFields{ACID: "AAL123", AircraftType: "B738"}},
+		{"AAL123 B738/L", AbbreviatedFPFields{ACID: "AAL123", AircraftType: "B738", Equipment: "L"}},
+		{"AAL123 H/B744", AbbreviatedFPFields{ACID: "AAL123", WeightClass: "H", AircraftType: "B744"}},
+		{"AAL123 H/B744/L", AbbreviatedFPFields{ACID: "AAL123", WeightClass: "H", AircraftType: "B744",
+			Equipment: "L"}},
+		{"VV01 2/F16/G", AbbreviatedFPFields{ACID: "VV01", NumAircraft: 2, AircraftType: "F16",
+			Equipment: "G"}},
+		{"VV01 12/F16", AbbreviatedFPFields{ACID: "VV01", NumAircraft: 12, AircraftType: "F16"}},
+		{"AAL123 120", AbbreviatedFPFields{ACID: "AAL123", RequestedAltitude: 12000}},
+		{"N123AB VFR", AbbreviatedFPFields{ACID: "N123AB", Rules: VFR}},
+		{"N123AB VFR/055", AbbreviatedFPFields{ACID: "N123AB", Rules: VFR, RequestedAltitude: 5500}},
+		{"AAL123 *JFK", AbbreviatedFPFields{ACID: "AAL123", Scratchpad: "JFK"}},
+		{"AAL123 +A", AbbreviatedFPFields{ACID: "AAL123", SecondaryScratchpad: "A"}},
+		{"AAL123 *CAMR +1.2", AbbreviatedFPFields{ACID: "AAL123", Scratchpad: "CAMR", SecondaryScratchpad: "1.2"}},
+		// All fields, in two different orders
+		{"AAL123 1234 H/B744/L 350 *JFK +R", AbbreviatedFPFields{ACID: "AAL123", Beacon: 0o1234,
+			HaveBeacon: true, WeightClass: "H", AircraftType: "B744", Equipment: "L", RequestedAltitude: 35000,
+			Scratchpad: "JFK", SecondaryScratchpad: "R"}},
+		{"AAL123 +R 350 *JFK H/B744/L 1234", AbbreviatedFPFields{ACID: "AAL123", Beacon: 0o1234,
+			HaveBeacon: true, WeightClass: "H", AircraftType: "B744", Equipment: "L", RequestedAltitude: 35000,
+			Scratchpad: "JFK", SecondaryScratchpad: "R"}},
+		{"N123AB C172/G VFR/045 1200", AbbreviatedFPFields{ACID: "N123AB", AircraftType: "C172",
+			Equipment: "G", Rules: VFR, RequestedAltitude: 4500, Beacon: 0o1200, HaveBeacon: true}},
+	} {
+		fp, err := ParseAbbreviatedFPFields(test.entry)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.entry, err)
+		} else if fp != test.expected {
+			t.Errorf("%q: got %+v, expected %+v", test.entry, fp, test.expected)
+		}
+	}
+
+	for _, test := range []struct {
+		entry string
+		index int
+		field string
+		err   error
+	}{
+		{"", 0, "", ErrFPMissingACID},
+		{"1AAL", 0, "1AAL", ErrFPInvalidACID},
+		{"A", 0, "A", ErrFPInvalidACID},
+		{"AAL12345", 0, "AAL12345", ErrFPInvalidACID},
+		{"AAL-12", 0, "AAL-12", ErrFPInvalidACID},
+		{"AAL123 4318", 1, "4318", ErrFPInvalidBeacon},
+		{"AAL123 12", 1, "12", ErrFPUnknownField},
+		{"AAL123 12345", 1, "12345", ErrFPUnknownField},
+		{"AAL123 000", 1, "000", ErrFPInvalidAltitude},
+		{"AAL123 VFR/55", 1, "VFR/55", ErrFPInvalidAltitude},
+		{"AAL123 VFR/", 1, "VFR/", ErrFPInvalidAltitude},
+		{"AAL123 B", 1, "B", ErrFPInvalidType},
+		{"AAL123 B7478", 1, "B7478", ErrFPInvalidType},
+		{"AAL123 X/B738", 1, "X/B738", ErrFPInvalidType},
+		{"AAL123 0/B738", 1, "0/B738", ErrFPInvalidType},
+		{"AAL123 H/B738/LL", 1, "H/B738/LL", ErrFPInvalidType},
+		{"AAL123 H/B738/L/X", 1, "H/B738/L/X", ErrFPInvalidType},
+		{"AAL123 B738/1", 1, "B738/1", ErrFPInvalidType},
+		{"AAL123 H/", 1, "H/", ErrFPInvalidType},
+		{"AAL123 *A", 1, "*A", ErrFPInvalidScratchpad},
+		{"AAL123 *ABCDE", 1, "*ABCDE", ErrFPInvalidScratchpad},
+		{"AAL123 *AB-", 1, "*AB-", ErrFPInvalidScratchpad},
+		{"AAL123 +", 1, "+", ErrFPInvalidScratchpad},
+		{"AAL123 #X", 1, "#X", ErrFPUnknownField},
+		{"AAL123 B738 A320", 2, "A320", ErrFPDuplicateField},
+		{"AAL123 1234 4321", 2, "4321", ErrFPDuplicateField},
+		{"AAL123 120 130", 2, "130", ErrFPDuplicateField},
+		{"AAL123 *AB *CD", 2, "*CD", ErrFPDuplicateField},
+		{"AAL123 +A +B", 2, "+B", ErrFPDuplicateField},
+		{"AAL123 VFR VFR", 2, "VFR", ErrFPDuplicateField},
+		{"N123AB 045 VFR/055", 2, "VFR/055", ErrFPDuplicateField},
+		{"N123AB VFR/055 045", 2, "045", ErrFPDuplicateField},
+	} {
+		_, err := ParseAbbreviatedFPFields(test.entry)
+		var fperr *AbbreviatedFPError
+		if !errors.As(err, &fperr) {
+			t.Errorf("%q: expected *AbbreviatedFPError, got %v", test.entry, err)
+		} else if fperr.Index != test.index || fperr.Field != test.field || !errors.Is(err, test.err) {
+			t.Errorf("%q: got error %+v, expected field %d %q: %v", test.entry, fperr, test.index,
+				test.field, test.err)
+		}
+	}
+
+	// A plain altitude along with VFR without one is fine.
+	if fp, err := ParseAbbreviatedFPFields("N123AB 045 VFR"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if fp.Rules != VFR || fp.RequestedAltitude != 4500 {
+		t.Errorf("unexpected result %+v", fp)
+	}
+}
+
+// checkAbbreviatedFPProperties checks invariants that should hold for any
+// entry, valid or not.
+func checkAbbreviatedFPProperties(t *testing.T, entry string) {
+	fields := strings.Fields(entry)
+	fp, err := ParseAbbreviatedFPFields(entry)
+
+	if err != nil {
+		var fperr *AbbreviatedFPError
+		if !errors.As(err, &fperr) {
+			t.Fatalf("%q: expected *AbbreviatedFPError, got %v", entry, err)
+		}
+		if len(fields) > 0 && (fperr.Index >= len(fields) || fperr.Field != fields[fperr.Index]) {
+			t.Fatalf("%q: error %+v doesn't identify a field of the entry", entry, fperr)
+		}
+		return
+	}
+
+	if fp.ACID != fields[0] {
+		t.Fatalf("%q: got ACID %q", entry, fp.ACID)
+	}
+	if fp.HaveBeacon && fp.Beacon > 0o7777 {
+		t.Fatalf("%q: invalid beacon code %s", entry, fp.Beacon)
+	}
+	if fp.RequestedAltitude%100 != 0 || fp.RequestedAltitude < 0 || fp.RequestedAltitude > 99900 {
+		t.Fatalf("%q: invalid altitude %d", entry, fp.RequestedAltitude)
+	}
+
+	// The fields after the ACID may be given in any order.
+	rest := slices.Clone(fields[1:])
+	slices.Reverse(rest)
+	reversed := strings.Join(append([]string{fields[0]}, rest...), " ")
+	if fp2, err := ParseAbbreviatedFPFields(reversed); err != nil || fp2 != fp {
+		t.Fatalf("%q: reordered as %q gives %+v (%v), expected %+v", entry, reversed, fp2, err, fp)
+	}
+}
+
+func TestAbbreviatedFPProperties(t *testing.T) {
+	for _, entry := range []string{"", " ", "AAL123", "AAL123 1234 H/B744/L 350 *JFK +R",
+		"N123AB C172/G VFR/045 1200", "AAL123 VFR/", "AAL123 H/", "AAL123 /", "AAL123 //", "AAL123 * +",
+		"AAL123 \u00e9", "\u00c9AL123", "AAL123 \u0663\u0663"} {
+		checkAbbreviatedFPProperties(t, entry)
+	}
+}
+
+func FuzzParseAbbreviatedFPFields(f *testing.F) {
+	for _, entry := range []string{"AAL123", "AAL123 1234 H/B744/L 350 *JFK +R", "VV01 2/F16/G",
+		"N123AB VFR/055", "AAL123 *CAMR +1.2", "AAL123 B738 A320", "AAL123 0000"} {
+		f.Add(entry)
+	}
+	f.Fuzz(checkAbbreviatedFPProperties)
+}
+
+func FuzzParseSquawk(f *testing.F) {
+	for _, s := range []string{"", "0", "0000", "1200", "7777", "10000", "8", "-1", "+12"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		sq, err := ParseSquawk(s)
+		if err != nil {
+			return
+		}
+		if sq > 0o7777 {
+			t.Fatalf("%q: out of range code %s", s, sq)
+		}
+		if sq2, err := ParseSquawk(sq.String()); err != nil || sq2 != sq {
+			t.Fatalf("%q: %s doesn't round trip: %s (%v)", s, sq, sq2, err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+// accessibility.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Accessibility settings for users with visual impairments. They apply
+// to the parts of the user interface outside of the radar scope:
+//
+//   - A minimum font size: the user interface font is never smaller than
+//     it, regardless of the font size setting or profile.
+//   - The "High Contrast" theme preset (see theme.go), which uses solid
+//     black backgrounds with white text and borders.
+//   - Spoken announcements: neither imgui nor GLFW exposes widgets to the
+//     platform's accessibility APIs, so screen readers can't see them.
+//     Instead, when announcements are enabled, the text of dialog boxes,
+//     the labels of their buttons when they are hovered, and status
+//     messages are spoken using the platform's text-to-speech command
+//     ("say" on macOS, System.Speech via PowerShell on Windows, and
+//     "spd-say" from speech-dispatcher elsewhere).
+
+import (
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type AccessibilityConfig struct {
+	// Zero means no minimum.
+	MinimumFontSize int
+	Announce        bool
+	// Name of the color vision palette to use, if any; see
+	// colorvision.go.
+	ColorVision string
+}
+
+// uiFontSize returns the size of the font to use for the user interface.
+func (gc *GlobalConfig) uiFontSize() int {
+	return max(gc.UIFontSize, gc.Accessibility.MinimumFontSize)
+}
+
+// accessibleDialog can be implemented by ModalDialogClients that show
+// text beyond their title, so that it can be announced.
+type accessibleDialog interface {
+	AccessibleText() string
+}
+
+var announcer struct {
+	ch        chan string
+	lastLabel string
+}
+
+// announce speaks the given text if announcements are enabled. It
+// doesn't wait for the text to be spoken; if text is already being
+// spoken, it's dropped unless it's the most recent.
+func announce(text string) {
+	if !globalConfig.Accessibility.Announce || strings.TrimSpace(text) == "" {
+		return
+	}
+
+	if announcer.ch == nil {
+		announcer.ch = make(chan string, 1)
+		go func() {
+			for text := range announcer.ch {
+				if cmd := speechCommand(text); cmd == nil {
+					lg.Warnf("announce: no text-to-speech command available")
+				} else if err := cmd.Run(); err != nil {
+					lg.Warnf("announce: %v", err)
+				}
+			}
+		}()
+	}
+
+	// Replace any announcement that hasn't started yet.
+	select {
+	case <-announcer.ch:
+	default:
+	}
+	announcer.ch <- text
+}
+
+// announceLabel announces the label of a widget that the mouse is over;
+// it's only spoken when the mouse moves to a different one.
+func announceLabel(label string) {
+	if label != announcer.lastLabel {
+		announcer.lastLabel = label
+		announce(label)
+	}
+}
+
+// speechCommand returns a command that speaks the given text, or nil if
+// there isn't one.
+func speechCommand(text string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		// As on Windows, pass the text via stdin so that text starting
+		// with "-" isn't taken as an option.
+		cmd := exec.Command("say", "-f", "-")
+		cmd.Stdin = strings.NewReader(text)
+		return cmd
+	case "windows":
+		// Pass the text via stdin so that it doesn't need to be quoted.
+		cmd := exec.Command("powershell", "-NoProfile", "-Command",
+			"Add-Type -AssemblyName System.Speech; "+
+				"(New-Object System.Speech.Synthesis.SpeechSynthesizer).Speak([Console]::In.ReadToEnd())")
+		cmd.Stdin = strings.NewReader(text)
+		return cmd
+	default:
+		if _, err := exec.LookPath("spd-say"); err != nil {
+			return nil
+		}
+		return exec.Command("spd-say", "--wait", "--", text)
+	}
+}
+
+func (a *AccessibilityConfig) DrawUI() {
+	if imgui.BeginComboV("Minimum font size", Select(a.MinimumFontSize == 0, "None", strconv.Itoa(a.MinimumFontSize)), 0) {
+		for _, size := range append([]int{0}, uiFontSizes()...) {
+			label := Select(size == 0, "None", strconv.Itoa(size))
+			if imgui.SelectableV(label, size == a.MinimumFontSize, 0, imgui.Vec2{}) {
+				a.MinimumFontSize = size
+				ui.font = GetFont(FontIdentifier{Name: "Roboto Regular", Size: globalConfig.uiFontSize()})
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	if imgui.Checkbox("Speak dialog boxes and status messages", &a.Announce) && a.Announce {
+		announce("Announcements enabled")
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Uses the system's text-to-speech voice")
+	}
+
+	uiDrawColorVisionSettings()
+
+	if globalConfig.Theme.Name != "High Contrast" && imgui.Button("Use high contrast theme") {
+		if i := slices.IndexFunc(themePresets, func(t Theme) bool { return t.Name == "High Contrast" }); i != -1 {
+			globalConfig.Theme = copyTheme(themePresets[i])
+			globalConfig.Theme.Apply()
+		}
+	}
+}
+
+// uiFontSizes returns the sizes that the user interface font is
+// available in.
+func uiFontSizes() []int {
+	sizes := make(map[int]interface{})
+	for fontid := range fonts {
+		if fontid.Name == "Roboto Regular" {
+			sizes[fontid.Size] = nil
+		}
+	}
+	return SortedMapKeys(sizes)
+}
@@ -0,0 +1,437 @@
+// adaptationeditor.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// STARS facility adaptation editor: the parts of a scenario group's
+// "stars_config" that facility engineers maintain--scratchpad rules (see
+// scratchpads.go), airspace awareness (coordination) rules, the map buttons, list
+// positions, and a few general settings--can be edited in-app. Edits are
+// validated against the current world and applied to the local scopes
+// immediately; the result can then be exported in normalized JSON to be
+// pasted into the scenario file. The sim's own copy of the adaptation
+// (e.g., for the scratchpads that it assigns to new departures) is only
+// updated once the scenario is reloaded.
+//
+// Maps can't be added here since their geometry comes from the video map
+// file; existing ones can be relabeled, regrouped, reordered, or removed.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+// STARSScratchpadRule is an editable entry of the adaptation's
+// "scratchpads" map.
+type STARSScratchpadRule struct {
+	Fix, Scratchpad string
+}
+
+// STARSAdaptationEdit holds a working copy of a facility adaptation.
+// The scratchpads and the airspace awareness fixes and aircraft types
+// are kept in forms that are easier to edit.
+type STARSAdaptationEdit struct {
+	Adaptation     STARSFacilityAdaptation
+	Scratchpads    []STARSScratchpadRule
+	AwarenessFixes []string // space-separated, parallel to Adaptation.AirspaceAwareness
+	AwarenessTypes []string
+	// Space-separated; see ScratchpadRules.
+	Forbidden, Patterns, SecondaryPatterns string
+}
+
+// MakeSTARSAdaptationEdit returns an editable copy of the given
+// adaptation; the original isn't affected by subsequent edits.
+func MakeSTARSAdaptationEdit(fa STARSFacilityAdaptation) *STARSAdaptationEdit {
+	ed := &STARSAdaptationEdit{Adaptation: fa}
+	a := &ed.Adaptation
+
+	a.Maps = DuplicateSlice(fa.Maps)
+	a.ListPositions = DuplicateMap(fa.ListPositions)
+	a.AirspaceAwareness = DuplicateSlice(fa.AirspaceAwareness)
+	for _, aa := range fa.AirspaceAwareness {
+		ed.AwarenessFixes = append(ed.AwarenessFixes, strings.Join(aa.Fix, " "))
+		ed.AwarenessTypes = append(ed.AwarenessTypes, strings.Join(aa.AircraftType, " "))
+	}
+	for _, fix := range SortedMapKeys(fa.Scratchpads) {
+		ed.Scratchpads = append(ed.Scratchpads, STARSScratchpadRule{Fix: fix, Scratchpad: fa.Scratchpads[fix]})
+	}
+	ed.Forbidden = strings.Join(fa.ScratchpadRules.Forbidden, " ")
+	ed.Patterns = strings.Join(fa.ScratchpadRules.Patterns, " ")
+	ed.SecondaryPatterns = strings.Join(fa.ScratchpadRules.SecondaryPatterns, " ")
+	return ed
+}
+
+// Result returns the adaptation with the edits applied.
+func (ed *STARSAdaptationEdit) Result() STARSFacilityAdaptation {
+	fa := ed.Adaptation
+	fa.Maps = DuplicateSlice(fa.Maps)
+	fa.ListPositions = DuplicateMap(fa.ListPositions)
+
+	fa.AirspaceAwareness = nil
+	for i, aa := range ed.Adaptation.AirspaceAwareness {
+		aa.Fix = strings.Fields(strings.ToUpper(ed.AwarenessFixes[i]))
+		aa.AircraftType = strings.Fields(strings.ToUpper(ed.AwarenessTypes[i]))
+		fa.AirspaceAwareness = append(fa.AirspaceAwareness, aa)
+	}
+
+	fa.Scratchpads = make(map[string]string)
+	for _, r := range ed.Scratchpads {
+		fa.Scratchpads[strings.ToUpper(r.Fix)] = strings.ToUpper(r.Scratchpad)
+	}
+	fa.ScratchpadRules.Forbidden = strings.Fields(strings.ToUpper(ed.Forbidden))
+	fa.ScratchpadRules.Patterns = strings.Fields(ed.Patterns)
+	fa.ScratchpadRules.SecondaryPatterns = strings.Fields(ed.SecondaryPatterns)
+	return fa
+}
+
+// Check reports problems with the edited parts of the adaptation, given
+// the world that it will be used in.
+func (fa *STARSFacilityAdaptation) Check(w *World, e *ErrorLogger) {
+	if fa.Range <= 0 {
+		e.ErrorString("\"range\" must be positive")
+	}
+
+	e.Push("scratchpads")
+	for _, fix := range SortedMapKeys(fa.Scratchpads) {
+		if _, ok := w.Locate(fix); !ok {
+			e.ErrorString("%s: fix unknown", fix)
+		}
+		if sp := fa.Scratchpads[fix]; sp == "" || fa.CheckScratchpad(sp, false) != nil {
+			e.ErrorString("%s: \"%s\" is not a valid scratchpad", fix, sp)
+		}
+	}
+	for _, p := range append(DuplicateSlice(fa.ScratchpadRules.Patterns), fa.ScratchpadRules.SecondaryPatterns...) {
+		if _, err := regexp.Compile(p); err != nil {
+			e.ErrorString("\"%s\": invalid pattern: %v", p, err)
+		}
+	}
+	e.Pop()
+
+	for i, aa := range fa.AirspaceAwareness {
+		e.Push(fmt.Sprintf("airspace_awareness %d", i+1))
+		if len(aa.Fix) == 0 {
+			e.ErrorString("no fixes specified")
+		}
+		for _, fix := range aa.Fix {
+			if _, ok := w.Locate(fix); !ok && fix != "ALL" {
+				e.ErrorString("%s: fix unknown", fix)
+			}
+		}
+		if aa.AltitudeRange[0] > aa.AltitudeRange[1] {
+			e.ErrorString("lower end of \"altitude_range\" %d above upper end %d",
+				aa.AltitudeRange[0], aa.AltitudeRange[1])
+		}
+		if _, ok := w.Controllers[aa.ReceivingController]; !ok {
+			e.ErrorString("%s: controller unknown", aa.ReceivingController)
+		}
+		e.Pop()
+	}
+
+	e.Push("stars_maps")
+	if len(fa.Maps) == 0 {
+		e.ErrorString("no maps specified")
+	} else if len(fa.Maps) > NumSTARSMaps {
+		e.ErrorString("%d maps given; at most %d may be used", len(fa.Maps), NumSTARSMaps)
+	}
+	for _, m := range fa.Maps {
+		if m.Label == "" {
+			e.ErrorString("%s: no label specified", m.Name)
+		}
+		if m.Group != 0 && m.Group != 1 {
+			e.ErrorString("%s: group must be A or B", m.Name)
+		}
+	}
+	e.Pop()
+
+	for name, p := range fa.ListPositions {
+		if p[0] < 0 || p[0] > 1 || p[1] < 0 || p[1] > 1 {
+			e.ErrorString("position %v for list \"%s\" must be between 0 and 1", p, name)
+		}
+	}
+}
+
+// MarshalSTARSFacilityAdaptation returns the adaptation's "stars_config"
+// JSON, normalized in the same way as scenario files are by
+// MarshalScenarioGroup.
+func MarshalSTARSFacilityAdaptation(fa *STARSFacilityAdaptation) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marshalScenarioJSON(&buf, reflect.ValueOf(fa), ""); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// SetSTARSFacilityAdaptation updates the adaptation used by the local
+// scopes. The maps' geometry is taken from the current maps with the
+// same names.
+func (w *World) SetSTARSFacilityAdaptation(fa STARSFacilityAdaptation) {
+	cbs := make(map[string]CommandBuffer)
+	for _, m := range w.STARSMaps {
+		cbs[m.Name] = m.CommandBuffer
+	}
+	w.STARSMaps = nil
+	for _, m := range fa.Maps {
+		m.CommandBuffer = cbs[m.Name]
+		w.STARSMaps = append(w.STARSMaps, m)
+	}
+	w.Scratchpads = fa.Scratchpads
+	w.STARSFacilityAdaptation = fa
+}
+
+///////////////////////////////////////////////////////////////////////////
+// UI
+
+var adaptationEditor struct {
+	visible        bool
+	edit           *STARSAdaptationEdit
+	errors         []ErrorLogEntry
+	exportFilename string
+	status         string
+}
+
+func uiToggleShowAdaptationEditor(w *World) {
+	ed := &adaptationEditor
+	ed.visible = !ed.visible
+	if ed.visible && w != nil {
+		ed.edit = MakeSTARSAdaptationEdit(w.STARSFacilityAdaptation)
+		ed.errors = nil
+		ed.status = ""
+		if ed.exportFilename == "" {
+			ed.exportFilename = filepath.Join(configDirectory(), w.TRACON+"-stars_config.json")
+		}
+	}
+}
+
+func uiDrawAdaptationEditor(w *World) {
+	ed := &adaptationEditor
+	if !ed.visible || w == nil || ed.edit == nil {
+		return
+	}
+	fa := &ed.edit.Adaptation
+
+	imgui.BeginV("STARS Facility Adaptation", &ed.visible, imgui.WindowFlagsAlwaysAutoResize)
+
+	if imgui.CollapsingHeader("General") {
+		imgui.SliderFloatV("Default range", &fa.Range, 6, 256, "%.0f", 0)
+		imgui.Checkbox("Quick look to self when owned", &fa.ForceQLToSelf)
+		imgui.Checkbox("Allow 4-character primary scratchpads", &fa.AllowLongScratchpad[0])
+		imgui.Checkbox("Allow 4-character secondary scratchpads", &fa.AllowLongScratchpad[1])
+	}
+
+	if imgui.CollapsingHeader("Scratchpad rules") {
+		sps := &ed.edit.Scratchpads
+		for i := 0; i < len(*sps); i++ {
+			r := &(*sps)[i]
+			imgui.PushID(fmt.Sprintf("sp%d", i))
+			imgui.SetNextItemWidth(100)
+			imgui.InputTextV("##fix", &r.Fix, imgui.InputTextFlagsCharsUppercase, nil)
+			imgui.SameLine()
+			imgui.Text(FontAwesomeIconArrowRight)
+			imgui.SameLine()
+			imgui.SetNextItemWidth(60)
+			imgui.InputTextV("##sp", &r.Scratchpad, imgui.InputTextFlagsCharsUppercase, nil)
+			imgui.SameLine()
+			if imgui.Button(FontAwesomeIconTrash) {
+				*sps = DeleteSliceElement(*sps, i)
+			}
+			imgui.PopID()
+		}
+		if imgui.Button("Add scratchpad rule") {
+			*sps = append(*sps, STARSScratchpadRule{})
+		}
+
+		imgui.SetNextItemWidth(250)
+		imgui.InputTextV("Forbidden", &ed.edit.Forbidden, imgui.InputTextFlagsCharsUppercase, nil)
+		imgui.SetNextItemWidth(250)
+		imgui.InputTextV("Primary patterns", &ed.edit.Patterns, 0, nil)
+		imgui.SetNextItemWidth(250)
+		imgui.InputTextV("Secondary patterns", &ed.edit.SecondaryPatterns, 0, nil)
+	}
+
+	if imgui.CollapsingHeader("Airspace awareness") {
+		for i := 0; i < len(fa.AirspaceAwareness); i++ {
+			aa := &fa.AirspaceAwareness[i]
+			imgui.PushID(fmt.Sprintf("aa%d", i))
+			imgui.SetNextItemWidth(250)
+			imgui.InputTextV("Fixes", &ed.edit.AwarenessFixes[i], imgui.InputTextFlagsCharsUppercase, nil)
+			lo, hi := int32(aa.AltitudeRange[0]), int32(aa.AltitudeRange[1])
+			imgui.SetNextItemWidth(120)
+			if imgui.InputIntV("Floor", &lo, 100, 1000, 0) {
+				aa.AltitudeRange[0] = int(lo)
+			}
+			imgui.SameLine()
+			imgui.SetNextItemWidth(120)
+			if imgui.InputIntV("Ceiling", &hi, 100, 1000, 0) {
+				aa.AltitudeRange[1] = int(hi)
+			}
+			imgui.SetNextItemWidth(250)
+			imgui.InputTextV("Aircraft types", &ed.edit.AwarenessTypes[i], imgui.InputTextFlagsCharsUppercase, nil)
+			imgui.SetNextItemWidth(250)
+			if imgui.BeginComboV("Receiving controller", aa.ReceivingController, imgui.ComboFlagsHeightLarge) {
+				for _, callsign := range SortedMapKeys(w.Controllers) {
+					if imgui.SelectableV(callsign, callsign == aa.ReceivingController, 0, imgui.Vec2{}) {
+						aa.ReceivingController = callsign
+					}
+				}
+				imgui.EndCombo()
+			}
+			if imgui.Button(FontAwesomeIconTrash + " Remove rule") {
+				fa.AirspaceAwareness = DeleteSliceElement(fa.AirspaceAwareness, i)
+				ed.edit.AwarenessFixes = DeleteSliceElement(ed.edit.AwarenessFixes, i)
+				ed.edit.AwarenessTypes = DeleteSliceElement(ed.edit.AwarenessTypes, i)
+			}
+			imgui.Separator()
+			imgui.PopID()
+		}
+		if imgui.Button("Add airspace awareness rule") {
+			fa.AirspaceAwareness = append(fa.AirspaceAwareness, AirspaceAwareness{AltitudeRange: [2]int{0, 99000}})
+			ed.edit.AwarenessFixes = append(ed.edit.AwarenessFixes, "")
+			ed.edit.AwarenessTypes = append(ed.edit.AwarenessTypes, "")
+		}
+	}
+
+	if imgui.CollapsingHeader("Maps") {
+		for i := 0; i < len(fa.Maps); i++ {
+			m := &fa.Maps[i]
+			imgui.PushID(fmt.Sprintf("map%d", i))
+			imgui.Text(fmt.Sprintf("%2d", i+1))
+			imgui.SameLine()
+			imgui.SetNextItemWidth(100)
+			imgui.InputTextV("##label", &m.Label, imgui.InputTextFlagsCharsUppercase, nil)
+			imgui.SameLine()
+			imgui.RadioButtonInt("A", &m.Group, 0)
+			imgui.SameLine()
+			imgui.RadioButtonInt("B", &m.Group, 1)
+			imgui.SameLine()
+			if imgui.Button(FontAwesomeIconArrowUp) && i > 0 {
+				fa.Maps[i-1], fa.Maps[i] = fa.Maps[i], fa.Maps[i-1]
+			}
+			imgui.SameLine()
+			if imgui.Button(FontAwesomeIconArrowDown) && i+1 < len(fa.Maps) {
+				fa.Maps[i], fa.Maps[i+1] = fa.Maps[i+1], fa.Maps[i]
+			}
+			imgui.SameLine()
+			if imgui.Button(FontAwesomeIconTrash) {
+				fa.Maps = DeleteSliceElement(fa.Maps, i)
+			}
+			imgui.SameLine()
+			imgui.Text(m.Name)
+			imgui.PopID()
+		}
+	}
+
+	if imgui.CollapsingHeader("List positions") {
+		imgui.Text("Positions take effect when the STARS preferences are reset.")
+		for _, name := range starsListNames {
+			imgui.PushID(name)
+			p, ok := fa.ListPositions[name]
+			if imgui.Checkbox(name, &ok) && ok {
+				p = [2]float32{0.5, 0.5}
+			}
+			if ok {
+				imgui.SameLine()
+				imgui.SetNextItemWidth(120)
+				imgui.SliderFloatV("x", &p[0], 0, 1, "%.2f", 0)
+				imgui.SameLine()
+				imgui.SetNextItemWidth(120)
+				imgui.SliderFloatV("y", &p[1], 0, 1, "%.2f", 0)
+				if fa.ListPositions == nil {
+					fa.ListPositions = make(map[string][2]float32)
+				}
+				fa.ListPositions[name] = p
+			} else {
+				delete(fa.ListPositions, name)
+			}
+			imgui.PopID()
+		}
+	}
+
+	imgui.Separator()
+
+	check := func() (STARSFacilityAdaptation, bool) {
+		result := ed.edit.Result()
+		var e ErrorLogger
+		result.Check(w, &e)
+		ed.errors = e.Errors()
+		return result, !e.HaveErrors()
+	}
+
+	if imgui.Button("Validate") {
+		if _, ok := check(); ok {
+			ed.status = "No errors found."
+		} else {
+			ed.status = ""
+		}
+	}
+	imgui.SameLine()
+	if imgui.Button("Apply") {
+		if result, ok := check(); ok {
+			w.SetSTARSFacilityAdaptation(result)
+			ed.status = "Applied to the local scopes."
+		} else {
+			ed.status = ""
+		}
+	}
+	imgui.SameLine()
+	if imgui.Button("Revert") {
+		ed.edit = MakeSTARSAdaptationEdit(w.STARSFacilityAdaptation)
+		ed.errors = nil
+		ed.status = ""
+	}
+
+	imgui.SetNextItemWidth(400)
+	imgui.InputTextV("##export", &ed.exportFilename, 0, nil)
+	imgui.SameLine()
+	export := func(save func([]byte) error) {
+		result, ok := check()
+		if !ok {
+			ed.status = ""
+			return
+		}
+		if b, err := MarshalSTARSFacilityAdaptation(&result); err != nil {
+			ed.status = "Error: " + err.Error()
+		} else if err := save(b); err != nil {
+			ed.status = "Error: " + err.Error()
+		}
+	}
+	if imgui.Button("Export") {
+		export(func(b []byte) error {
+			err := writeFileAtomically(ed.exportFilename, func(w io.Writer) error {
+				_, err := w.Write(b)
+				return err
+			})
+			if err == nil {
+				ed.status = "Wrote " + ed.exportFilename
+			}
+			return err
+		})
+	}
+	imgui.SameLine()
+	if imgui.Button("Copy JSON") {
+		export(func(b []byte) error {
+			platform.GetClipboard().SetText(string(b))
+			ed.status = "Copied \"stars_config\" JSON to the clipboard."
+			return nil
+		})
+	}
+
+	for _, err := range ed.errors {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{1, .3, .3, 1})
+		imgui.Text(err.String())
+		imgui.PopStyleColor()
+	}
+	if ed.status != "" {
+		imgui.Text(ed.status)
+	}
+
+	imgui.End()
+}
@@ -0,0 +1,75 @@
+// adaptationeditor_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSTARSAdaptationEdit(t *testing.T) {
+	saved := database
+	database = &StaticDatabase{}
+	defer func() { database = saved }()
+
+	fa := STARSFacilityAdaptation{
+		Range:       50,
+		Scratchpads: map[string]string{"CAMRN": "CAM"},
+		AirspaceAwareness: []AirspaceAwareness{
+			{Fix: []string{"MERIT"}, AltitudeRange: [2]int{0, 10000}, ReceivingController: "2K"},
+		},
+		Maps: []STARSMap{{Label: "JFK", Name: "JFK 4"}},
+	}
+	w := &World{
+		Fixes:       map[string]Point2LL{"CAMRN": {}, "MERIT": {}, "BETTE": {}},
+		Controllers: map[string]*Controller{"2K": {}},
+	}
+
+	ed := MakeSTARSAdaptationEdit(fa)
+	ed.Scratchpads = append(ed.Scratchpads, STARSScratchpadRule{Fix: "bette", Scratchpad: "bet"})
+	ed.AwarenessFixes[0] = "merit  camrn"
+	ed.Adaptation.Maps[0].Label = "JFK2"
+
+	result := ed.Result()
+	if fa.Maps[0].Label != "JFK" || len(fa.Scratchpads) != 1 {
+		t.Errorf("original adaptation was modified")
+	}
+	if result.Scratchpads["BETTE"] != "BET" {
+		t.Errorf("expected BETTE -> BET scratchpad, got %v", result.Scratchpads)
+	}
+	if f := result.AirspaceAwareness[0].Fix; len(f) != 2 || f[0] != "MERIT" || f[1] != "CAMRN" {
+		t.Errorf("unexpected airspace awareness fixes %v", f)
+	}
+
+	var e ErrorLogger
+	result.Check(w, &e)
+	if e.HaveErrors() {
+		t.Errorf("unexpected errors: %s", e.String())
+	}
+
+	ed.Scratchpads = append(ed.Scratchpads, STARSScratchpadRule{Fix: "NOPE", Scratchpad: "X"})
+	ed.Adaptation.AirspaceAwareness[0].ReceivingController = "9Z"
+	bad := ed.Result()
+	e = ErrorLogger{}
+	bad.Check(w, &e)
+	if n := len(e.Errors()); n != 3 {
+		t.Errorf("expected 3 errors, got %d: %s", n, e.String())
+	}
+
+	b, err := MarshalSTARSFacilityAdaptation(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt STARSFacilityAdaptation
+	if err := json.Unmarshal(b, &rt); err != nil {
+		t.Errorf("exported JSON doesn't parse: %v", err)
+	} else if rt.Scratchpads["BETTE"] != "BET" || rt.Maps[0].Label != "JFK2" {
+		t.Errorf("unexpected exported adaptation %s", string(b))
+	}
+	if strings.Contains(string(b), "command_buffer") {
+		t.Errorf("map geometry shouldn't be exported: %s", string(b))
+	}
+}
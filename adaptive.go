@@ -0,0 +1,211 @@
+// adaptive.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Adaptive launch rates: when enabled, the sim monitors signals of the
+// controller's workload--how long pilots wait for a first instruction
+// after checking in, how many aircraft are in a loss of separation, and
+// how much longer arrivals are taking than the quickest ones--and scales
+// the departure and arrival rates up or down within the configured bounds
+// so that a solo session stays challenging without being overwhelming.
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type AdaptiveLaunchConfig struct {
+	Enabled bool
+	// Bounds on the factor that launch rates are scaled by.
+	MinScale, MaxScale float32
+	// The current scale factor; it's maintained by the sim.
+	Scale float32
+}
+
+const adaptiveAdjustInterval = 2 * time.Minute
+
+func (a *AdaptiveLaunchConfig) bounds() (float32, float32) {
+	lo, hi := a.MinScale, a.MaxScale
+	if lo == 0 {
+		lo = 0.5
+	}
+	if hi == 0 {
+		hi = 2
+	}
+	return lo, hi
+}
+
+func (a *AdaptiveLaunchConfig) scale() float32 {
+	if !a.Enabled || a.Scale == 0 {
+		return 1
+	}
+	return a.Scale
+}
+
+// scaleRate returns the given launch rate (aircraft per hour) scaled
+// according to the current workload.
+func (a *AdaptiveLaunchConfig) scaleRate(rate int) int {
+	if rate == 0 {
+		return 0
+	}
+	return max(1, int(float32(rate)*a.scale()+0.5))
+}
+
+func (a *AdaptiveLaunchConfig) DrawUI() (changed bool) {
+	changed = imgui.Checkbox("Adapt launch rates to workload", &a.Enabled)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Automatically increase or decrease the departure and arrival rates based on how busy you are")
+	}
+
+	uiStartDisable(!a.Enabled)
+	lo, hi := a.bounds()
+	changed = imgui.SliderFloatV("Minimum rate scale", &lo, 0.1, 1, "%.2f", 0) || changed
+	changed = imgui.SliderFloatV("Maximum rate scale", &hi, 1, 4, "%.2f", 0) || changed
+	a.MinScale, a.MaxScale = lo, hi
+	if a.Enabled {
+		imgui.Text(fmt.Sprintf("Current rate scale: %.2f", a.scale()))
+	}
+	uiEndDisable(!a.Enabled)
+
+	return
+}
+
+// workloadMonitor collects the signals that adaptive launch rates are
+// based on. It isn't serialized; after a sim is restored, it starts again
+// from scratch.
+type workloadMonitor struct {
+	// callsign -> when the pilot checked in
+	awaitingCommand map[string]time.Time
+	latencies       []time.Duration
+
+	// callsign -> launch time for arrivals
+	arrivalLaunch map[string]time.Time
+	// Shortest time from launch to landing for each airport
+	minArrivalTime map[string]time.Duration
+	delays         []time.Duration
+
+	conflictSeconds int
+	lastAdjust      time.Time
+}
+
+func (wm *workloadMonitor) init() {
+	if wm.awaitingCommand == nil {
+		wm.awaitingCommand = make(map[string]time.Time)
+		wm.arrivalLaunch = make(map[string]time.Time)
+		wm.minArrivalTime = make(map[string]time.Duration)
+	}
+}
+
+// contact records that a pilot has checked in on a human controller's
+// frequency.
+func (wm *workloadMonitor) contact(callsign string, now time.Time) {
+	wm.init()
+	if _, ok := wm.awaitingCommand[callsign]; !ok {
+		wm.awaitingCommand[callsign] = now
+	}
+}
+
+// command records that the controller has issued an instruction.
+func (wm *workloadMonitor) command(callsign string, now time.Time) {
+	wm.init()
+	if t, ok := wm.awaitingCommand[callsign]; ok {
+		wm.latencies = append(wm.latencies, now.Sub(t))
+		delete(wm.awaitingCommand, callsign)
+	}
+}
+
+func (wm *workloadMonitor) launchedArrival(ac *Aircraft, now time.Time) {
+	wm.init()
+	wm.arrivalLaunch[ac.Callsign] = now
+}
+
+func (wm *workloadMonitor) landed(ac *Aircraft, now time.Time) {
+	wm.init()
+	t, ok := wm.arrivalLaunch[ac.Callsign]
+	if !ok || ac.FlightPlan == nil {
+		return
+	}
+	delete(wm.arrivalLaunch, ac.Callsign)
+
+	ap := ac.FlightPlan.ArrivalAirport
+	d := now.Sub(t)
+	if m, ok := wm.minArrivalTime[ap]; !ok || d < m {
+		wm.minArrivalTime[ap] = d
+	}
+	wm.delays = append(wm.delays, d-wm.minArrivalTime[ap])
+}
+
+func averageDuration(d []time.Duration) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	return sum / time.Duration(len(d))
+}
+
+// updateAdaptiveLaunch is called once a second with s.mu held; it
+// periodically adjusts the launch rate scale based on the controller's
+// workload.
+func (s *Sim) updateAdaptiveLaunch() {
+	a := &s.LaunchConfig.Adaptive
+	wm := &s.workload
+	wm.init()
+
+	// Forget about aircraft that have left.
+	for callsign := range wm.awaitingCommand {
+		if _, ok := s.World.Aircraft[callsign]; !ok {
+			delete(wm.awaitingCommand, callsign)
+		}
+	}
+	for callsign := range wm.arrivalLaunch {
+		if _, ok := s.World.Aircraft[callsign]; !ok {
+			delete(wm.arrivalLaunch, callsign)
+		}
+	}
+
+	if !a.Enabled {
+		a.Scale = 1
+		wm.lastAdjust = s.SimTime
+		return
+	}
+
+	wm.conflictSeconds += len(s.TrainingState.dealPairs)
+	if s.SimTime.Sub(wm.lastAdjust) < adaptiveAdjustInterval {
+		return
+	}
+
+	// Pilots who are still waiting count too.
+	latencies := wm.latencies
+	for _, t := range wm.awaitingCommand {
+		latencies = append(latencies, s.SimTime.Sub(t))
+	}
+	latency, delay := averageDuration(latencies), averageDuration(wm.delays)
+
+	scale := a.scale()
+	if wm.conflictSeconds > 0 || latency > 20*time.Second || delay > 4*time.Minute {
+		scale *= 0.85
+	} else if latency < 8*time.Second && delay < 90*time.Second {
+		scale *= 1.1
+	}
+	lo, hi := a.bounds()
+	scale = clamp(scale, lo, hi)
+
+	if scale != a.scale() {
+		s.lg.Info("adjusting launch rates", slog.Float64("scale", float64(scale)),
+			slog.Duration("command_latency", latency), slog.Duration("arrival_delay", delay),
+			slog.Int("conflict_seconds", wm.conflictSeconds))
+	}
+	a.Scale = scale
+
+	wm.latencies, wm.delays = nil, nil
+	wm.conflictSeconds = 0
+	wm.lastAdjust = s.SimTime
+}
@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"slices"
 	"strings"
+	"time"
 )
 
 type Aircraft struct {
@@ -41,9 +42,15 @@ type Aircraft struct {
 	Nav Nav
 
 	// Departure related state
+	SID                        string
 	Exit                       string
+	DepartureRunway            string
 	DepartureContactAltitude   float32
 	DepartureContactController string
+	Release                    *DepartureRelease // nil if no release is needed
+	// The next sector after the first human controller; see
+	// departuregates.go.
+	ExitHandoffController string
 
 	// Arrival-related state
 	STAR              string
@@ -54,6 +61,25 @@ type Aircraft struct {
 
 	// Who to try to hand off to at a waypoint with /ho
 	WaypointHandoffController string
+
+	// Frozen aircraft aren't updated; see instructor.go.
+	Frozen bool
+
+	// nil if fuel isn't modeled for the aircraft; see fuel.go.
+	Fuel *FuelState
+
+	// The request the pilot has made, if any; see pilotrequests.go.
+	PilotRequest *PilotRequest
+
+	// NORDO aircraft; see lostcomms.go.
+	LostComms      bool
+	LostCommsStart time.Time
+
+	// Predicted by the sim; see trajectory.go.
+	Trajectory Trajectory
+
+	// The sector the aircraft is in, if any; see sectors.go.
+	Sector string
 }
 
 type RedirectedHandoff struct {
@@ -87,6 +113,11 @@ func (ac *Aircraft) HandleControllerDisconnect(callsign string, w *World) {
 		return
 	}
 
+	if r := ac.Release; r != nil && !r.Released && r.Controller == callsign {
+		// Don't leave it stuck on the ground.
+		r.Released = true
+	}
+
 	if ac.HandoffTrackController == callsign {
 		// Otherwise redirect handoffs to the primary controller. This is
 		// not a perfect solution; for an arrival, for example, we should
@@ -153,10 +184,25 @@ func (ac *Aircraft) transmitResponse(r PilotResponse) []RadioTransmission {
 	}}
 }
 
-func (ac *Aircraft) Update(w *World, ep EventPoster, simlg *Logger) *Waypoint {
+// UpdateNav advances the aircraft's flight by one second, returning the
+// waypoint it passed, if any. It only reads the World and only modifies
+// the aircraft itself, so it may be called concurrently for different
+// aircraft; FinishUpdate must then be called to handle the consequences.
+func (ac *Aircraft) UpdateNav(w *World, simlg *Logger) *Waypoint {
+	lg := simlg.With(slog.String("callsign", ac.Callsign))
+
+	// Magnetic variation changes enough over a large scenario that
+	// aircraft should use the local value when flying headings.
+	ac.Nav.FlightState.MagneticVariation = w.MagneticVariationAt(ac.Position())
+
+	return ac.Nav.Update(w, lg.Subsystem("nav"))
+}
+
+// FinishUpdate handles the parts of the aircraft's update that may affect
+// the rest of the world, given the result of UpdateNav.
+func (ac *Aircraft) FinishUpdate(w *World, ep EventPoster, passedWaypoint *Waypoint, simlg *Logger) *Waypoint {
 	lg := simlg.With(slog.String("callsign", ac.Callsign))
 
-	passedWaypoint := ac.Nav.Update(w, lg)
 	if passedWaypoint != nil {
 		lg.Info("passed", slog.Any("waypoint", passedWaypoint))
 
@@ -170,29 +216,35 @@ func (ac *Aircraft) Update(w *World, ep EventPoster, simlg *Logger) *Waypoint {
 		if d, err := ac.Nav.distanceToEndOfApproach(); err == nil && d < *ac.GoAroundDistance {
 			lg.Info("randomly going around")
 			ac.GoAroundDistance = nil // only go around once
-			rt := ac.GoAround()
-			ac.ControllingController = w.DepartureController(ac)
-			PostRadioEvents(ac.Callsign, rt, ep)
-
-			// If it was handed off to tower, hand it back to us
-			if ac.TrackingController != "" && ac.TrackingController != ac.ApproachController {
-				ac.HandoffTrackController = w.DepartureController(ac)
-				if ac.HandoffTrackController == "" {
-					ac.HandoffTrackController = ac.ApproachController
-				}
-				ep.PostEvent(Event{
-					Type:           OfferedHandoffEvent,
-					Callsign:       ac.Callsign,
-					FromController: ac.TrackingController,
-					ToController:   ac.ApproachController,
-				})
-			}
+			ac.executeGoAround(w, ep)
 		}
 	}
 
 	return passedWaypoint
 }
 
+// executeGoAround has the aircraft go around and contact the departure
+// controller.
+func (ac *Aircraft) executeGoAround(w *World, ep EventPoster) {
+	rt := ac.GoAround()
+	ac.ControllingController = w.DepartureController(ac)
+	PostRadioEvents(ac.Callsign, rt, ep)
+
+	// If it was handed off to tower, hand it back to us
+	if ac.TrackingController != "" && ac.TrackingController != ac.ApproachController {
+		ac.HandoffTrackController = w.DepartureController(ac)
+		if ac.HandoffTrackController == "" {
+			ac.HandoffTrackController = ac.ApproachController
+		}
+		ep.PostEvent(Event{
+			Type:           OfferedHandoffEvent,
+			Callsign:       ac.Callsign,
+			FromController: ac.TrackingController,
+			ToController:   ac.ApproachController,
+		})
+	}
+}
+
 func (ac *Aircraft) GoAround() []RadioTransmission {
 	resp := ac.Nav.GoAround()
 	return []RadioTransmission{RadioTransmission{
@@ -290,6 +342,13 @@ func (ac *Aircraft) ExpectApproach(id string, w *World, lg *Logger) []RadioTrans
 		return ac.readbackUnexpected("unable.")
 	}
 
+	if rt := ac.checkApproachEquipment(id, w); rt != nil {
+		return rt
+	}
+	if rt := ac.checkApproachWeather(id, w); rt != nil {
+		return rt
+	}
+
 	lg = lg.With(slog.String("callsign", ac.Callsign), slog.Any("aircraft", ac))
 	resp := ac.Nav.ExpectApproach(ac.FlightPlan.ArrivalAirport, id, arr, w, lg)
 	return ac.transmitResponse(resp)
@@ -309,9 +368,17 @@ func (ac *Aircraft) ClearedApproach(id string, w *World) []RadioTransmission {
 		return ac.readbackUnexpected("unable.")
 	}
 
+	if rt := ac.checkApproachEquipment(id, w); rt != nil {
+		return rt
+	}
+	if rt := ac.checkApproachWeather(id, w); rt != nil {
+		return rt
+	}
+
 	resp, err := ac.Nav.clearedApproach(ac.FlightPlan.ArrivalAirport, id, false, arr, w)
 	if err == nil {
 		ac.ApproachController = ac.ControllingController
+		ac.maybeMissApproach(id, w)
 	}
 	return ac.transmitResponse(resp)
 }
@@ -326,9 +393,17 @@ func (ac *Aircraft) ClearedStraightInApproach(id string, w *World) []RadioTransm
 		return ac.readbackUnexpected("unable.")
 	}
 
+	if rt := ac.checkApproachEquipment(id, w); rt != nil {
+		return rt
+	}
+	if rt := ac.checkApproachWeather(id, w); rt != nil {
+		return rt
+	}
+
 	resp, err := ac.Nav.clearedApproach(ac.FlightPlan.ArrivalAirport, id, true, arr, w)
 	if err == nil {
 		ac.ApproachController = ac.ControllingController
+		ac.maybeMissApproach(id, w)
 	}
 	return ac.transmitResponse(resp)
 }
@@ -338,10 +413,16 @@ func (ac *Aircraft) CancelApproachClearance() []RadioTransmission {
 }
 
 func (ac *Aircraft) ClimbViaSID() []RadioTransmission {
+	if rt := ac.checkProcedureEquipment(true); rt != nil {
+		return rt
+	}
 	return ac.transmitResponse(ac.Nav.ClimbViaSID())
 }
 
 func (ac *Aircraft) DescendViaSTAR() []RadioTransmission {
+	if rt := ac.checkProcedureEquipment(false); rt != nil {
+		return rt
+	}
 	return ac.transmitResponse(ac.Nav.DescendViaSTAR())
 }
 
@@ -434,6 +515,14 @@ func (ac *Aircraft) InitializeArrival(w *World, arrivalGroup string,
 		lg = lg.With(slog.String("callsign", ac.Callsign), slog.Any("aircraft", ac))
 		ac.ExpectApproach(arr.ExpectApproach, w, lg)
 	}
+	if ac.Scratchpad == "" && ac.Nav.Approach.Assigned != nil {
+		if sp, ok := w.STARSFacilityAdaptation.ArrivalScratchpad(ac.FlightPlan.ArrivalAirport,
+			ac.Nav.Approach.AssignedId); ok {
+			ac.Scratchpad = sp
+		}
+	}
+
+	ac.initializeFuel()
 
 	return nil
 }
@@ -456,12 +545,13 @@ func (ac *Aircraft) InitializeDeparture(w *World, ap *Airport, departureAirport
 		return ErrUnknownAircraftType
 	}
 
-	ac.Scratchpad = dep.Scratchpad
-	if ac.Scratchpad == "" {
-		ac.Scratchpad = w.Scratchpads[dep.Exit]
+	ac.Scratchpad, ac.SecondaryScratchpad = w.STARSFacilityAdaptation.departureScratchpads(dep)
+	if gate, ok := w.STARSFacilityAdaptation.DepartureGate(dep.Exit); ok {
+		ac.ExitHandoffController = gate.Handoff
 	}
-	ac.SecondaryScratchpad = dep.SecondaryScratchpad
+	ac.SID = exitRoute.SID
 	ac.Exit = dep.Exit
+	ac.DepartureRunway = runway
 
 	if dep.Altitude == 0 {
 		ac.FlightPlan.Altitude = PlausibleFinalAltitude(w, ac.FlightPlan, perf)
@@ -606,3 +696,41 @@ func (ac *Aircraft) AircraftPerformance() AircraftPerformance {
 func (ac *Aircraft) RouteIncludesFix(fix string) bool {
 	return slices.ContainsFunc(ac.Nav.Waypoints, func(w Waypoint) bool { return w.Fix == fix })
 }
+
+// AircraftSummary is a read-only snapshot of an aircraft's externally
+// visible state; it is used to expose the sim state outside of vice's
+// core (e.g., to plugins) without sharing the Aircraft itself.
+type AircraftSummary struct {
+	Callsign              string
+	Position              Point2LL
+	Altitude              float32
+	Heading               float32
+	GS                    float32
+	Squawk                string
+	Scratchpad            string
+	TrackingController    string
+	ControllingController string
+	AircraftType          string
+	DepartureAirport      string
+	ArrivalAirport        string
+}
+
+func (ac *Aircraft) Summary() AircraftSummary {
+	s := AircraftSummary{
+		Callsign:              ac.Callsign,
+		Position:              ac.Position(),
+		Altitude:              ac.Altitude(),
+		Heading:               ac.Heading(),
+		GS:                    ac.GS(),
+		Squawk:                ac.Squawk.String(),
+		Scratchpad:            ac.Scratchpad,
+		TrackingController:    ac.TrackingController,
+		ControllingController: ac.ControllingController,
+	}
+	if fp := ac.FlightPlan; fp != nil {
+		s.AircraftType = fp.AircraftType
+		s.DepartureAirport = fp.DepartureAirport
+		s.ArrivalAirport = fp.ArrivalAirport
+	}
+	return s
+}
@@ -23,6 +23,9 @@ type Airport struct {
 	// Optional: initial tracking controller, for cases where a virtual
 	// controller has the initial track.
 	DepartureController string `json:"departure_controller"`
+	// Departures must be released by their first controller before they
+	// may take off; see release.go.
+	DepartureRelease bool `json:"departure_release"`
 
 	ExitCategories map[string]string `json:"exit_categories"`
 
@@ -34,6 +37,9 @@ type Airport struct {
 
 	ATPAVolumes           map[string]*ATPAVolume `json:"atpa_volumes"`
 	OmitArrivalScratchpad bool                   `json:"omit_arrival_scratchpad"`
+
+	// runway -> exits; see runwayoccupancy.go.
+	RunwayExits map[string][]RunwayExit `json:"runway_exits"`
 }
 
 type ConvergingRunways struct {
@@ -441,8 +447,8 @@ func (ap *Airport) PostDeserialize(icao string, sg *ScenarioGroup, e *ErrorLogge
 		e.Push("Departure exit " + dep.Exit)
 		e.Push("Destination " + dep.Destination)
 
-		if _, ok := sg.STARSFacilityAdaptation.Scratchpads[dep.Exit]; dep.Scratchpad == "" && !ok {
-			e.ErrorString("exit not in scenario group \"scratchpads\"")
+		if sp, _ := sg.STARSFacilityAdaptation.departureScratchpads(&dep); sp == "" {
+			e.ErrorString("exit not in scenario group \"scratchpads\" or \"departure_gates\"")
 		}
 
 		if dep.Altitude < 500 && dep.Altitude != 0 {
@@ -626,6 +632,8 @@ func (ap *Airport) PostDeserialize(icao string, sg *ScenarioGroup, e *ErrorLogge
 
 		e.Pop()
 	}
+
+	ap.checkRunwayExits(icao, e)
 }
 
 type ExitRoute struct {
@@ -0,0 +1,109 @@
+// altimeter.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Mode C altitude reporting. Transponders report pressure altitude, which
+// is relative to the standard pressure of 29.92 inHg. Below the
+// transition altitude, pilots fly altitudes using the altimeter setting
+// from the nearest reporting station, so on a low-pressure day an
+// aircraft at 10,000' reports a higher pressure altitude. STARS corrects
+// reported altitudes below the transition level using its system
+// altimeter setting (here, the primary airport's) before displaying them;
+// altitudes above it are displayed uncorrected. As in real life, the
+// readouts of aircraft near the transition altitude may thus be
+// surprising when the pressure is far from standard.
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	standardAltimeter  = 29.92 // inHg
+	transitionAltitude = 18000 // feet
+)
+
+// ParseAltimeter parses an altimeter setting as given in a METAR, e.g.
+// "A2992", returning the setting in inches of mercury.
+func ParseAltimeter(s string) (float32, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "A")
+	if len(s) != 4 {
+		return 0, false
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false
+	}
+	return float32(v) / 100, true
+}
+
+// pressureAltitude returns the pressure altitude of an aircraft flying at
+// the given altitude; below the transition altitude, the altitude is
+// taken to be flown using the given altimeter setting.
+func pressureAltitude(alt float32, altimeter float32) float32 {
+	if alt < transitionAltitude {
+		return alt + (standardAltimeter-altimeter)*1000
+	}
+	return alt
+}
+
+// correctedAltitude returns the altitude that STARS displays for the
+// given Mode C pressure altitude, using the given system altimeter
+// setting.
+func correctedAltitude(pressureAlt float32, altimeter float32) float32 {
+	if pressureAlt < transitionAltitude {
+		return pressureAlt - (standardAltimeter-altimeter)*1000
+	}
+	return pressureAlt
+}
+
+// AltimeterSetting returns the altimeter setting reported by the given
+// station.
+func (w *World) AltimeterSetting(icao string) (float32, bool) {
+	if metar := w.GetMETAR(icao); metar != nil {
+		return ParseAltimeter(metar.Altimeter)
+	}
+	return 0, false
+}
+
+// NearestAltimeterSetting returns the altimeter setting from the
+// reporting station closest to the given point; the standard setting is
+// returned if there are no reporting stations.
+func (w *World) NearestAltimeterSetting(p Point2LL) float32 {
+	setting, closest := float32(standardAltimeter), float32(0)
+	for icao, metar := range w.METAR {
+		loc, ok := w.Locate(icao)
+		if !ok {
+			continue
+		}
+		alt, ok := ParseAltimeter(metar.Altimeter)
+		if !ok {
+			continue
+		}
+		if d := nmdistance2ll(p, loc); closest == 0 || d < closest {
+			setting, closest = alt, d
+		}
+	}
+	return setting
+}
+
+// SystemAltimeterSetting returns the altimeter setting that STARS uses to
+// correct Mode C altitudes.
+func (w *World) SystemAltimeterSetting() float32 {
+	if alt, ok := w.AltimeterSetting(w.PrimaryAirport); ok {
+		return alt
+	}
+	if ap := w.GetAirport(w.PrimaryAirport); ap != nil {
+		return w.NearestAltimeterSetting(ap.Location)
+	}
+	return standardAltimeter
+}
+
+// ModeCAltitude returns the altitude that STARS displays for the
+// aircraft, given the pressure altitude reported by its transponder.
+func (w *World) ModeCAltitude(ac *Aircraft) int {
+	p := pressureAltitude(ac.Altitude(), w.NearestAltimeterSetting(ac.Position()))
+	return int(correctedAltitude(p, w.SystemAltimeterSetting()))
+}
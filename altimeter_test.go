@@ -0,0 +1,56 @@
+// altimeter_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestParseAltimeter(t *testing.T) {
+	for _, test := range []struct {
+		s   string
+		alt float32
+		ok  bool
+	}{
+		{"A2992", 29.92, true},
+		{"A3012", 30.12, true},
+		{"2941", 29.41, true},
+		{"A299", 0, false},
+		{"AXXXX", 0, false},
+		{"", 0, false},
+	} {
+		alt, ok := ParseAltimeter(test.s)
+		if ok != test.ok || abs(alt-test.alt) > 0.001 {
+			t.Errorf("%q: got %f/%v, expected %f/%v", test.s, alt, ok, test.alt, test.ok)
+		}
+	}
+}
+
+func TestModeCCorrection(t *testing.T) {
+	for _, test := range []struct {
+		alt, local, system float32
+		displayed          int
+	}{
+		// Standard day: no change.
+		{10000, 29.92, 29.92, 10000},
+		{25000, 29.92, 29.92, 25000},
+		// Low pressure, same setting locally and at the system station:
+		// corrected below the transition level.
+		{10000, 29.42, 29.42, 10000},
+		// Flying 17,800' locally gives a pressure altitude above the
+		// transition level, which isn't corrected.
+		{17800, 29.42, 29.42, 18300},
+		// Flight levels are flown using the standard setting.
+		{19000, 29.42, 29.42, 19000},
+		// Different local and system settings.
+		{10000, 29.82, 29.92, 10100},
+		{10000, 30.12, 29.92, 9800},
+	} {
+		p := pressureAltitude(test.alt, test.local)
+		if d := int(correctedAltitude(p, test.system) + 0.5); abs(d-test.displayed) > 1 {
+			t.Errorf("%+v: got displayed altitude %d", test, d)
+		}
+	}
+}
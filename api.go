@@ -0,0 +1,372 @@
+// api.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// This file implements an optional local HTTP API that allows external
+// programs (stream overlays, home-built hardware panels, test harnesses,
+// ...) to query the sim state and issue commands. It is only enabled if
+// the -apiport command-line option is given and it only listens on the
+// loopback interface. Requests are rejected unless their Host is a
+// loopback address and any Origin they carry is the API server itself, so
+// that web pages open in a browser can't issue commands (directly or via
+// DNS rebinding).
+//
+// Endpoints:
+//
+//	GET  /api/v1/state              sim time, callsign, and all aircraft
+//	GET  /api/v1/aircraft/CALLSIGN  a single aircraft
+//	POST /api/v1/command            {"callsign": "AAL1", "commands": "C80 S210"}
+//	POST /api/v1/pause              toggle pause
+//	GET  /api/v1/events             server-sent event stream of sim events
+//	GET  /api/v1/screenshot         PNG of the window (?scope=1 for just the radar scope)
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIServer handles HTTP requests on its own goroutines, but the World
+// may only be accessed from the main thread. Therefore, handlers send
+// closures to the main thread via the requests chan; they are run when
+// Process is called each frame.
+type APIServer struct {
+	requests chan func(w *World, es *EventStream)
+	events   *EventsSubscription
+	mux      *http.ServeMux
+
+	mu        sync.Mutex
+	listeners map[chan Event]interface{}
+}
+
+type APIState struct {
+	Callsign string
+	SimName  string
+	SimTime  time.Time
+	Paused   bool
+	Aircraft []AircraftSummary
+}
+
+type APICommand struct {
+	Callsign string `json:"callsign"`
+	Commands string `json:"commands"`
+}
+
+type APICommandResult struct {
+	Error          string `json:"error,omitempty"`
+	RemainingInput string `json:"remaining_input,omitempty"`
+}
+
+// LaunchAPIServer starts the HTTP API server on the given local port.
+func LaunchAPIServer(port int, es *EventStream) (*APIServer, error) {
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	api := &APIServer{
+		requests:  make(chan func(*World, *EventStream), 64),
+		events:    es.Subscribe(),
+		mux:       mux,
+		listeners: make(map[chan Event]interface{}),
+	}
+
+	mux.HandleFunc("/api/v1/state", api.handleState)
+	mux.HandleFunc("/api/v1/aircraft/", api.handleAircraft)
+	mux.HandleFunc("/api/v1/command", api.handleCommand)
+	mux.HandleFunc("/api/v1/pause", api.handlePause)
+	mux.HandleFunc("/api/v1/events", api.handleEvents)
+	mux.HandleFunc("/api/v1/screenshot", api.handleScreenshot)
+
+	go func() {
+		if err := http.Serve(l, http.HandlerFunc(api.serveLocal)); err != nil {
+			lg.Errorf("API server: %v", err)
+		}
+	}()
+	lg.Info("launched API server", slog.Int("port", port))
+
+	return api, nil
+}
+
+// serveLocal passes requests to the mux if they come from a local client
+// that isn't a web page from elsewhere.
+func (api *APIServer) serveLocal(w http.ResponseWriter, r *http.Request) {
+	if !isLoopbackHost(r.Host) {
+		http.Error(w, "invalid host", http.StatusForbidden)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		// Pages served by the API server (e.g., the overlay) may make
+		// requests to it; everything else is cross-origin.
+		if u, err := url.Parse(origin); err != nil || u.Scheme != "http" || u.Host != r.Host {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+	}
+	api.mux.ServeHTTP(w, r)
+}
+
+// isLoopbackHost returns true if the given host, with an optional port,
+// is localhost or a loopback IP address.
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// HandleFunc registers an additional handler with the API server; it
+// allows other parts of vice to serve data over the same port.
+func (api *APIServer) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	api.mux.HandleFunc(pattern, handler)
+}
+
+// Process runs pending API requests and forwards events to the event
+// stream listeners; it must be called from the main thread.
+func (api *APIServer) Process(w *World, es *EventStream) {
+	if api == nil {
+		return
+	}
+
+	events := api.events.Get()
+	if len(events) > 0 {
+		api.mu.Lock()
+		for ch := range api.listeners {
+			for _, e := range events {
+				select {
+				case ch <- e:
+				default:
+					// Drop events for slow clients rather than stalling
+					// the main thread.
+				}
+			}
+		}
+		api.mu.Unlock()
+	}
+
+	for {
+		select {
+		case req := <-api.requests:
+			req(w, es)
+		default:
+			return
+		}
+	}
+}
+
+// run sends f to the main thread and waits for it to finish.
+func (api *APIServer) run(f func(w *World, es *EventStream)) bool {
+	done := make(chan struct{})
+	select {
+	case api.requests <- func(w *World, es *EventStream) { f(w, es); close(done) }:
+	case <-time.After(5 * time.Second):
+		return false
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(5 * time.Second):
+		return false
+	}
+}
+
+func apiWriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		lg.Warnf("API: %v", err)
+	}
+}
+
+func apiError(w http.ResponseWriter, status int, msg string) {
+	apiWriteJSON(w, status, APICommandResult{Error: msg})
+}
+
+func (api *APIServer) handleState(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(rw, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	var state *APIState
+	if !api.run(func(w *World, es *EventStream) {
+		if w == nil {
+			return
+		}
+		state = &APIState{
+			Callsign: w.Callsign,
+			SimName:  w.SimName,
+			SimTime:  w.CurrentTime(),
+			Paused:   w.SimIsPaused,
+		}
+		for _, callsign := range SortedMapKeys(w.Aircraft) {
+			state.Aircraft = append(state.Aircraft, w.Aircraft[callsign].Summary())
+		}
+	}) {
+		apiError(rw, http.StatusServiceUnavailable, "timed out")
+	} else if state == nil {
+		apiError(rw, http.StatusServiceUnavailable, "not connected to a sim")
+	} else {
+		apiWriteJSON(rw, http.StatusOK, state)
+	}
+}
+
+func (api *APIServer) handleAircraft(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(rw, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	callsign := strings.ToUpper(strings.TrimPrefix(r.URL.Path, "/api/v1/aircraft/"))
+	var summary *AircraftSummary
+	if !api.run(func(w *World, es *EventStream) {
+		if w == nil {
+			return
+		}
+		if ac, ok := w.Aircraft[callsign]; ok {
+			s := ac.Summary()
+			summary = &s
+		}
+	}) {
+		apiError(rw, http.StatusServiceUnavailable, "timed out")
+	} else if summary == nil {
+		apiError(rw, http.StatusNotFound, callsign+": no such aircraft")
+	} else {
+		apiWriteJSON(rw, http.StatusOK, summary)
+	}
+}
+
+func (api *APIServer) handleCommand(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(rw, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	var cmd APICommand
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		apiError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The result arrives asynchronously, once the RPC to the sim
+	// completes.
+	resultChan := make(chan APICommandResult, 1)
+	connected := false
+	if !api.run(func(w *World, es *EventStream) {
+		if w == nil {
+			return
+		}
+		connected = true
+		w.RunAircraftCommands(strings.ToUpper(cmd.Callsign), strings.ToUpper(cmd.Commands),
+			func(errorString string, remaining string) {
+				resultChan <- APICommandResult{Error: errorString, RemainingInput: remaining}
+			})
+	}) {
+		apiError(rw, http.StatusServiceUnavailable, "timed out")
+		return
+	} else if !connected {
+		apiError(rw, http.StatusServiceUnavailable, "not connected to a sim")
+		return
+	}
+
+	select {
+	case result := <-resultChan:
+		apiWriteJSON(rw, Select(result.Error == "", http.StatusOK, http.StatusBadRequest), result)
+	case <-time.After(5 * time.Second):
+		apiError(rw, http.StatusGatewayTimeout, "timed out waiting for the sim")
+	}
+}
+
+func (api *APIServer) handlePause(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(rw, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	if !api.run(func(w *World, es *EventStream) {
+		if w != nil {
+			w.ToggleSimPause()
+		}
+	}) {
+		apiError(rw, http.StatusServiceUnavailable, "timed out")
+	} else {
+		apiWriteJSON(rw, http.StatusOK, APICommandResult{})
+	}
+}
+
+func (api *APIServer) handleEvents(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		apiError(rw, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	ch := make(chan Event, 256)
+	api.mu.Lock()
+	api.listeners[ch] = nil
+	api.mu.Unlock()
+	defer func() {
+		api.mu.Lock()
+		delete(api.listeners, ch)
+		api.mu.Unlock()
+	}()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	for {
+		select {
+		case e := <-ch:
+			b, err := json.Marshal(e)
+			if err != nil {
+				lg.Warnf("API: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", e.Type, b); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (api *APIServer) handleScreenshot(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(rw, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	scopeOnly := r.URL.Query().Get("scope") == "1"
+	imgChan := make(chan *image.RGBA, 1)
+	RequestScreenshot(scopeOnly, func(img *image.RGBA) { imgChan <- img })
+
+	select {
+	case img := <-imgChan:
+		rw.Header().Set("Content-Type", "image/png")
+		if err := png.Encode(rw, img); err != nil {
+			lg.Warnf("API: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		apiError(rw, http.StatusGatewayTimeout, "timed out")
+	}
+}
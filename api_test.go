@@ -0,0 +1,43 @@
+// api_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIServerRejectsNonLocalRequests(t *testing.T) {
+	api := &APIServer{mux: http.NewServeMux()}
+	api.mux.HandleFunc("/api/v1/state", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, test := range []struct {
+		host, origin string
+		ok           bool
+	}{
+		{host: "127.0.0.1:6502", ok: true},
+		{host: "localhost:6502", ok: true},
+		{host: "[::1]:6502", ok: true},
+		{host: "127.0.0.1:6502", origin: "http://127.0.0.1:6502", ok: true},
+		{host: "evil.example.com:6502"},
+		{host: "10.0.0.1:6502"},
+		{host: "127.0.0.1:6502", origin: "https://evil.example.com"},
+		{host: "127.0.0.1:6502", origin: "http://localhost:8080"},
+		{host: "127.0.0.1:6502", origin: "null"},
+	} {
+		req := httptest.NewRequest("GET", "/api/v1/state", nil)
+		req.Host = test.host
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		rec := httptest.NewRecorder()
+		api.serveLocal(rec, req)
+
+		if ok := rec.Code == http.StatusOK; ok != test.ok {
+			t.Errorf("host %q origin %q: got status %d, expected ok=%v", test.host, test.origin, rec.Code, test.ok)
+		}
+	}
+}
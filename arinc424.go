@@ -227,6 +227,14 @@ func ParseARINC424(file []byte) (map[string]FAAAirport, map[string]Navaid, map[s
 				fixes[id] = Fix{Id: id, Location: location}
 
 			case 'D': // SID 4.1.9
+				recs := matchingSSARecs(line)
+				id := recs[0].id
+				if airports[icao].SIDs == nil {
+					ap := airports[icao]
+					ap.SIDs = make(map[string]SID)
+					airports[icao] = ap
+				}
+				airports[icao].SIDs[id] = SID{RNAV: slices.ContainsFunc(recs, ssaRecord.rnav)}
 
 			case 'E': // STAR 4.1.9
 				recs := matchingSSARecs(line)
@@ -330,6 +338,7 @@ func tidyFAAApproachId(id string) string {
 type ssaRecord struct {
 	icao                   string
 	id                     string
+	routeType              byte
 	transition             string
 	fix                    string
 	turnDirectionValid     byte
@@ -362,6 +371,7 @@ func parseSSA(line []byte) ssaRecord {
 	return ssaRecord{
 		icao:                   string(line[6:10]),
 		id:                     strings.TrimSpace(string(line[13:19])),
+		routeType:              line[19], // 5.7
 		continuation:           line[38],
 		transition:             strings.TrimSpace(string(line[20:25])),
 		fix:                    strings.TrimSpace(string(line[29:34])),
@@ -383,6 +393,11 @@ func parseSSA(line []byte) ssaRecord {
 	}
 }
 
+// rnav returns true if the record is from an RNAV or FMS SID or STAR.
+func (r ssaRecord) rnav() bool {
+	return strings.IndexByte("456FMS", r.routeType) != -1
+}
+
 func (r *ssaRecord) GetWaypoint() (wp Waypoint, arc *DMEArc, ok bool) {
 	switch string(r.pathAndTermination) {
 	case "FM", "VM":
@@ -550,6 +565,7 @@ func parseSTAR(recs []ssaRecord) *STAR {
 		func(r ssaRecord, transitions map[string]WaypointArray) bool { return false })    // terminate
 
 	star := MakeSTAR()
+	star.RNAV = slices.ContainsFunc(recs, ssaRecord.rnav)
 	for t, wps := range transitions {
 		if len(t) > 3 && t[:2] == "RW" && t[2] >= '0' && t[2] <= '9' {
 			// it's a runway
@@ -10,6 +10,13 @@ import "C"
 
 import (
 	"C"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync"
 	"unsafe"
 
@@ -32,6 +39,10 @@ const (
 	AudioInboundHandoff
 	AudioCommandError
 	AudioHandoffAccepted
+	AudioNewMessage
+	AudioRadioStatic
+	AudioSquelchTail
+	AudioBlockedTransmission
 	AudioNumTypes
 )
 
@@ -44,12 +55,26 @@ func (ae AudioType) String() string {
 		"Inbound Handoff",
 		"Command Error",
 		"Handoff Accepted",
+		"New Message",
+		"Radio Static",
+		"Squelch Tail",
+		"Blocked Transmission",
 	}[ae]
 }
 
+// soundPackName returns the base name of the file that provides the
+// sound for the audio type in a sound pack, e.g., "conflict-alert".
+func (ae AudioType) soundPackName() string {
+	return strings.ReplaceAll(strings.ToLower(ae.String()), " ", "-")
+}
+
 type AudioEngine struct {
 	AudioEnabled  bool
 	EffectEnabled [AudioNumTypes]bool
+	Volume        [AudioNumTypes]float32
+	// Name of a directory in the sounds directory with user-supplied
+	// sounds; sounds that it doesn't provide use the defaults.
+	SoundPack string
 
 	effects [AudioNumTypes]AudioEffect
 
@@ -67,6 +92,7 @@ func (a *AudioEngine) SetDefaults() {
 	a.AudioEnabled = true
 	for i := 0; i < AudioNumTypes; i++ {
 		a.EffectEnabled[i] = true
+		a.Volume[i] = 1
 	}
 }
 
@@ -113,9 +139,10 @@ func audioCallback(user unsafe.Pointer, ptr *C.uint8, size C.int) {
 
 	for i := range a.effects {
 		e := &a.effects[i]
+		vol := a.Volume[i]
 		buf := make([]byte, n)
 		bread := buf
-		for len(bread) > 0 && (e.playContinuous || e.playOnceCount > 0) {
+		for len(bread) > 0 && len(e.pcm) > 0 && (e.playContinuous || e.playOnceCount > 0) {
 			nc := copy(bread, e.pcm[e.playOffset:])
 			e.playOffset += nc
 			bread = bread[nc:]
@@ -129,7 +156,7 @@ func audioCallback(user unsafe.Pointer, ptr *C.uint8, size C.int) {
 		}
 
 		for i := 0; i < len(buf)/2; i++ {
-			accum[i] += int(int16(buf[2*i])|int16(buf[2*i+1])<<8) / 2
+			accum[i] += int(float32(int16(buf[2*i])|int16(buf[2*i+1])<<8)*vol) / 2
 		}
 	}
 
@@ -155,6 +182,173 @@ func (a *AudioEngine) loadMP3(filename string) AudioEffect {
 	return AudioEffect{pcm: pcm}
 }
 
+// synthesizeRadio returns procedurally-generated samples for the radio
+// sounds: band-limited static, optionally with a heterodyne squeal (as
+// when two transmitters are keyed at once), that fades out over the
+// given fraction of its duration at the end.
+func synthesizeRadio(duration float32, amplitude float32, heterodyne bool, fade float32) []byte {
+	n := int(duration * AudioSampleRate)
+	pcm := make([]byte, 2*n)
+	r := NewRand(int64(n))
+	var lowpass float32
+	for i := 0; i < n; i++ {
+		lowpass = lerp(0.35, lowpass, 2*r.Float32()-1)
+		v := lowpass
+		if heterodyne {
+			// Two slightly-detuned carriers beat against each other.
+			t := float64(i) / AudioSampleRate
+			v = 0.5*v + 0.25*float32(math.Sin(2*math.Pi*1100*t)+math.Sin(2*math.Pi*1163*t))
+		}
+		if f := float32(i) / float32(n); fade > 0 && f > 1-fade {
+			v *= (1 - f) / fade
+		}
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(int16(clamp(v*amplitude*32767, -32768, 32767))))
+	}
+	return pcm
+}
+
+func soundPacksDirectory() string {
+	return path.Join(configDirectory(), "sounds")
+}
+
+// loadSoundFile loads a user-supplied sound in MP3 or WAV format,
+// converting it to the format used by the engine.
+func loadSoundFile(filename string) ([]byte, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var pcm []byte
+	var rate, channels int
+	if strings.ToLower(filepath.Ext(filename)) == ".wav" {
+		if pcm, rate, channels, err = decodeWAV(b); err != nil {
+			return nil, err
+		}
+	} else {
+		dec, mp3pcm, err := minimp3.DecodeFull(b)
+		if err != nil {
+			return nil, err
+		}
+		pcm, rate, channels = mp3pcm, dec.SampleRate, dec.Channels
+	}
+	if rate == 0 || channels == 0 || len(pcm) == 0 {
+		return nil, fmt.Errorf("no audio found")
+	}
+	return convertPCM(pcm, rate, channels), nil
+}
+
+// decodeWAV returns the samples, sample rate, and number of channels of
+// a WAV file with 16-bit PCM samples.
+func decodeWAV(b []byte) ([]byte, int, int, error) {
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return nil, 0, 0, fmt.Errorf("not a WAV file")
+	}
+
+	var rate, channels int
+	for b = b[12:]; len(b) >= 8; {
+		id, size := string(b[:4]), int(binary.LittleEndian.Uint32(b[4:8]))
+		b = b[8:]
+		if size > len(b) {
+			size = len(b)
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, 0, fmt.Errorf("invalid fmt chunk")
+			}
+			if format, bits := binary.LittleEndian.Uint16(b[0:2]), binary.LittleEndian.Uint16(b[14:16]); format != 1 || bits != 16 {
+				return nil, 0, 0, fmt.Errorf("only 16-bit PCM WAV files are supported")
+			}
+			channels = int(binary.LittleEndian.Uint16(b[2:4]))
+			rate = int(binary.LittleEndian.Uint32(b[4:8]))
+		case "data":
+			if channels == 0 {
+				return nil, 0, 0, fmt.Errorf("data chunk before fmt chunk")
+			}
+			return b[:size], rate, channels, nil
+		}
+		// Chunks are padded to an even number of bytes.
+		b = b[min(len(b), size+size%2):]
+	}
+	return nil, 0, 0, fmt.Errorf("no data chunk found")
+}
+
+// convertPCM converts 16-bit little-endian samples with the given sample
+// rate and number of channels to mono samples at AudioSampleRate.
+func convertPCM(pcm []byte, rate, channels int) []byte {
+	n := len(pcm) / (2 * channels)
+	sample := func(i int) float32 {
+		// Average the channels.
+		var sum int
+		for c := 0; c < channels; c++ {
+			o := 2 * (i*channels + c)
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[o:])))
+		}
+		return float32(sum) / float32(channels)
+	}
+
+	nout := int(int64(n) * AudioSampleRate / int64(rate))
+	out := make([]byte, 2*nout)
+	for i := 0; i < nout; i++ {
+		// Linearly interpolate between the nearest input samples.
+		t := float32(i) * float32(rate) / AudioSampleRate
+		i0 := min(int(t), n-1)
+		i1 := min(i0+1, n-1)
+		v := lerp(t-float32(i0), sample(i0), sample(i1))
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(clamp(v, -32768, 32767))))
+	}
+	return out
+}
+
+// loadSoundPack replaces the default sounds with the ones provided by
+// the current sound pack, if any.
+func (a *AudioEngine) loadSoundPack() {
+	if a.SoundPack == "" {
+		return
+	}
+	dir := path.Join(soundPacksDirectory(), a.SoundPack)
+	for t := AudioType(0); t < AudioNumTypes; t++ {
+		for _, ext := range []string{".wav", ".mp3"} {
+			fn := path.Join(dir, t.soundPackName()+ext)
+			if _, err := os.Stat(fn); err != nil {
+				continue
+			}
+			if pcm, err := loadSoundFile(fn); err != nil {
+				lg.Errorf("%s: %v", fn, err)
+			} else {
+				a.effects[t].pcm = pcm
+				break
+			}
+		}
+	}
+}
+
+// loadEffects loads the sounds for all of the audio types.
+func (a *AudioEngine) loadEffects() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range a.effects {
+		// Stop anything that is playing since the sounds may change
+		// length.
+		a.effects[i] = AudioEffect{}
+	}
+	a.effects[AudioConflictAlert] = a.loadMP3("ca.mp3")
+	a.effects[AudioEmergencySquawk] = a.loadMP3("emergency.mp3")
+	a.effects[AudioMinimumSafeAltitudeWarning] = a.loadMP3("msaw.mp3")
+	a.effects[AudioModeCIntruder] = a.loadMP3("intruder.mp3")
+	a.effects[AudioInboundHandoff] = a.loadMP3("263124__pan14__sine-octaves-up-beep.mp3")
+	a.effects[AudioCommandError] = a.loadMP3("426888__thisusernameis__beep4.mp3")
+	a.effects[AudioHandoffAccepted] = a.loadMP3("321104__nsstudios__blip2.mp3")
+	a.effects[AudioNewMessage] = a.loadMP3("321104__nsstudios__blip2.mp3")
+	a.effects[AudioRadioStatic] = AudioEffect{pcm: synthesizeRadio(2, 0.08, false, 0)}
+	a.effects[AudioSquelchTail] = AudioEffect{pcm: synthesizeRadio(0.2, 0.5, false, 0.7)}
+	a.effects[AudioBlockedTransmission] = AudioEffect{pcm: synthesizeRadio(1.5, 0.4, true, 0.2)}
+
+	a.loadSoundPack()
+}
+
 func (a *AudioEngine) Activate() error {
 	lg.Info("Starting to initialize audio")
 
@@ -168,13 +362,7 @@ func (a *AudioEngine) Activate() error {
 	sdl.OpenAudio(&spec, nil)
 	sdl.PauseAudio(false)
 
-	a.effects[AudioConflictAlert] = a.loadMP3("ca.mp3")
-	a.effects[AudioEmergencySquawk] = a.loadMP3("emergency.mp3")
-	a.effects[AudioMinimumSafeAltitudeWarning] = a.loadMP3("msaw.mp3")
-	a.effects[AudioModeCIntruder] = a.loadMP3("intruder.mp3")
-	a.effects[AudioInboundHandoff] = a.loadMP3("263124__pan14__sine-octaves-up-beep.mp3")
-	a.effects[AudioCommandError] = a.loadMP3("426888__thisusernameis__beep4.mp3")
-	a.effects[AudioHandoffAccepted] = a.loadMP3("321104__nsstudios__blip2.mp3")
+	a.loadEffects()
 
 	lg.Info("Finished initializing audio")
 	return nil
@@ -185,14 +373,71 @@ func (a *AudioEngine) DrawUI() {
 	imgui.Separator()
 
 	uiStartDisable(!a.AudioEnabled)
+
+	packName := Select(a.SoundPack == "", "Default", a.SoundPack)
+	if imgui.BeginComboV("Sound pack", packName, 0) {
+		packs := []string{""}
+		if entries, err := os.ReadDir(soundPacksDirectory()); err == nil {
+			for _, e := range entries {
+				if e.IsDir() {
+					packs = append(packs, e.Name())
+				}
+			}
+		}
+		for _, p := range packs {
+			if imgui.SelectableV(Select(p == "", "Default", p), p == a.SoundPack, 0, imgui.Vec2{}) && p != a.SoundPack {
+				a.SoundPack = p
+				a.loadEffects()
+			}
+		}
+		imgui.EndCombo()
+	}
+	if a.SoundPack != "" {
+		imgui.SameLine()
+		if imgui.Button("Reload") {
+			a.loadEffects()
+		}
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Sound packs are directories in " + soundPacksDirectory() +
+			" with .wav or .mp3 files named for the sounds they replace, e.g., " +
+			AudioType(AudioConflictAlert).soundPackName() + ".wav")
+	}
+
 	// Not all of the ones available in the engine are used, so only offer these up:
-	for _, i := range []AudioType{AudioConflictAlert, AudioInboundHandoff, AudioHandoffAccepted, AudioCommandError} {
-		if imgui.Checkbox(AudioType(i).String(), &a.EffectEnabled[i]) && a.EffectEnabled[i] {
-			n := Select(i == AudioConflictAlert, 5, 1)
+	for _, i := range []AudioType{AudioConflictAlert, AudioMinimumSafeAltitudeWarning, AudioInboundHandoff,
+		AudioHandoffAccepted, AudioCommandError, AudioNewMessage, AudioRadioStatic, AudioSquelchTail,
+		AudioBlockedTransmission} {
+		imgui.PushID(i.String())
+
+		play := func() {
+			n := Select(i == AudioConflictAlert || i == AudioMinimumSafeAltitudeWarning, 5, 1)
 			for j := 0; j < n; j++ {
 				a.PlayOnce(i)
 			}
 		}
+
+		if imgui.Checkbox("##enabled", &a.EffectEnabled[i]) && a.EffectEnabled[i] {
+			play()
+		}
+		imgui.SameLine()
+		uiStartDisable(!a.EffectEnabled[i])
+		vol := a.Volume[i]
+		imgui.SetNextItemWidth(150)
+		if imgui.SliderFloatV("##volume", &vol, 0, 1, "%.2f", 0) {
+			a.mu.Lock()
+			a.Volume[i] = vol
+			a.mu.Unlock()
+		}
+		imgui.SameLine()
+		if imgui.Button(FontAwesomeIconPlayCircle) {
+			play()
+		}
+		uiEndDisable(!a.EffectEnabled[i])
+		imgui.SameLine()
+		imgui.Text(i.String())
+
+		imgui.PopID()
 	}
 	uiEndDisable(!a.AudioEnabled)
 }
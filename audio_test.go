@@ -0,0 +1,61 @@
+// audio_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeWAV(t *testing.T) {
+	// Stereo, 24 kHz, two frames.
+	samples := []int16{100, 300, -1000, -2000}
+	var data []byte
+	for _, s := range samples {
+		data = binary.LittleEndian.AppendUint16(data, uint16(s))
+	}
+
+	var wav []byte
+	wav = append(wav, "RIFF"...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(4+8+16+8+3+1+8+len(data)))
+	wav = append(wav, "WAVE"...)
+	wav = append(wav, "fmt "...)
+	wav = binary.LittleEndian.AppendUint32(wav, 16)
+	wav = binary.LittleEndian.AppendUint16(wav, 1) // PCM
+	wav = binary.LittleEndian.AppendUint16(wav, 2) // channels
+	wav = binary.LittleEndian.AppendUint32(wav, 24000)
+	wav = binary.LittleEndian.AppendUint32(wav, 24000*4)
+	wav = binary.LittleEndian.AppendUint16(wav, 4)
+	wav = binary.LittleEndian.AppendUint16(wav, 16)
+	// An odd-sized chunk that should be skipped, along with its padding.
+	wav = append(wav, "LIST"...)
+	wav = binary.LittleEndian.AppendUint32(wav, 3)
+	wav = append(wav, 1, 2, 3, 0)
+	wav = append(wav, "data"...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(data)))
+	wav = append(wav, data...)
+
+	pcm, rate, channels, err := decodeWAV(wav)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rate != 24000 || channels != 2 || string(pcm) != string(data) {
+		t.Errorf("got rate %d channels %d pcm %v; expected 24000, 2, %v", rate, channels, pcm, data)
+	}
+
+	// Downmixed to mono and downsampled from 24 kHz to 12 kHz, only the
+	// first frame should remain.
+	mono := convertPCM(pcm, rate, channels)
+	if len(mono) != 2 {
+		t.Fatalf("got %d bytes of converted audio, expected 2", len(mono))
+	}
+	if v := int16(binary.LittleEndian.Uint16(mono)); v != 200 {
+		t.Errorf("got converted sample %d, expected 200", v)
+	}
+
+	if _, _, _, err := decodeWAV([]byte("RIFF1234WAVX")); err == nil {
+		t.Errorf("expected error for non-WAV data")
+	}
+}
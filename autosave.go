@@ -0,0 +1,131 @@
+// autosave.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Autosave: while running a local sim, a snapshot of it is periodically
+// saved so that a crash doesn't lose a long session. The snapshot is
+// taken by the sim server, which copies the Sim while holding its lock,
+// and it's then encoded and written to disk in a separate goroutine so
+// that the UI doesn't hitch.
+//
+// Autosaves are stored alongside the other saved sims (see savedsims.go),
+// rotating through the most recent few of them so that there's something
+// to fall back on if the latest one is bad. A marker file is present
+// while a session with autosaves is running and is removed at a clean
+// exit, when the sim is saved in the config file as usual; if it's found
+// at startup, vice presumably crashed and the latest autosave is
+// restored instead.
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	autosaveInterval = 3 * time.Minute
+	numAutosaves     = 3
+)
+
+var autosave struct {
+	last     time.Time
+	inFlight atomic.Bool
+}
+
+func autosaveFilename(i int) string {
+	return path.Join(savedSimsDirectory(), fmt.Sprintf("autosave-%d.json", i))
+}
+
+func autosaveMarkerFilename() string {
+	return path.Join(savedSimsDirectory(), "session-running")
+}
+
+// maybeAutosaveSim is called once per frame; if it's time, it starts an
+// asynchronous save of the local sim, if there is one.
+func maybeAutosaveSim(w *World) {
+	if !isLocalSim(w) {
+		return
+	}
+	if autosave.last.IsZero() {
+		// Don't save right away after connecting.
+		autosave.last = time.Now()
+		return
+	}
+	if time.Since(autosave.last) < autosaveInterval || autosave.inFlight.Load() {
+		return
+	}
+
+	autosave.last = time.Now()
+	autosave.inFlight.Store(true)
+
+	// Grab what we need from the World now, since it isn't safe to access
+	// it from another goroutine.
+	proxy, callsign := w.simProxy, w.Callsign
+	go func() {
+		defer autosave.inFlight.Store(false)
+
+		start := time.Now()
+		sim, err := proxy.GetSerializeSim()
+		if err != nil {
+			lg.Errorf("autosave: %v", err)
+			return
+		}
+
+		if err := writeAutosave(callsign, sim); err != nil {
+			lg.Errorf("autosave: %v", err)
+		} else {
+			lg.Infof("autosaved sim in %s", time.Since(start))
+		}
+	}()
+}
+
+func writeAutosave(callsign string, sim *Sim) error {
+	if f, err := os.Create(autosaveMarkerFilename()); err != nil {
+		return err
+	} else {
+		f.Close()
+	}
+
+	// Rotate the existing autosaves, dropping the oldest.
+	for i := numAutosaves - 1; i >= 1; i-- {
+		if err := os.Rename(autosaveFilename(i), autosaveFilename(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	info := makeSavedSimInfo("Autosave", callsign, sim)
+	info.Autosave = true
+	return writeSavedSim(autosaveFilename(1), info, sim)
+}
+
+// loadAutosave returns the most recent autosave if vice didn't exit
+// cleanly last time.
+func loadAutosave() (*SavedSimInfo, *Sim) {
+	if _, err := os.Stat(autosaveMarkerFilename()); err != nil {
+		return nil, nil
+	}
+
+	info, sim, err := readSavedSim(autosaveFilename(1))
+	if err != nil {
+		lg.Errorf("%s: %v", autosaveFilename(1), err)
+		return nil, nil
+	}
+	return &info, sim
+}
+
+// autosaveCleanExit is called at a clean exit, after the sim has been
+// saved in the config file.
+func autosaveCleanExit() {
+	for autosave.inFlight.Load() {
+		// Let a save in progress finish so that it doesn't recreate the
+		// marker afterward.
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := os.Remove(autosaveMarkerFilename()); err != nil && !os.IsNotExist(err) {
+		lg.Errorf("%v", err)
+	}
+}
@@ -32,6 +32,7 @@ type FAAAirport struct {
 	Runways    []Runway
 	Approaches map[string][]WaypointArray
 	STARs      map[string]STAR
+	SIDs       map[string]SID
 }
 
 type TRACON struct {
@@ -79,6 +80,13 @@ type ArrivalAirline struct {
 type STAR struct {
 	Transitions     map[string]WaypointArray
 	RunwayWaypoints map[string]WaypointArray
+	RNAV            bool
+}
+
+// SID records the departure procedures in the FAA database; only whether
+// they are RNAV procedures is currently needed.
+type SID struct {
+	RNAV bool
 }
 
 func (s STAR) Check(e *ErrorLogger) {
@@ -136,13 +144,54 @@ func MakeSTAR() *STAR {
 	}
 }
 
-func (s STAR) Print(name string) {
-	for tr, wps := range s.Transitions {
-		fmt.Printf("%-12s: %s\n", name+"."+tr, wps.Encode())
+// PrintRoutes writes the airport's STARs and approaches, either as text or
+// as JSON.
+func (ap FAAAirport) PrintRoutes(w io.Writer, asJSON bool) error {
+	if asJSON {
+		routes := struct {
+			Airport    string              `json:"airport"`
+			STARs      map[string]string   `json:"stars"`
+			Approaches map[string][]string `json:"approaches"`
+		}{
+			Airport:    ap.Id,
+			STARs:      make(map[string]string),
+			Approaches: make(map[string][]string),
+		}
+		for name, star := range ap.STARs {
+			for tr, wps := range star.Transitions {
+				routes.STARs[name+"."+tr] = wps.Encode()
+			}
+			for rwy, wps := range star.RunwayWaypoints {
+				routes.STARs[name+".RWY"+rwy] = wps.Encode()
+			}
+		}
+		for name, appr := range ap.Approaches {
+			routes.Approaches[name] = MapSlice(appr, func(wps WaypointArray) string { return wps.Encode() })
+		}
+		return writeJSON(w, routes)
+	}
+
+	fmt.Fprintf(w, "STARs:\n")
+	for _, name := range SortedMapKeys(ap.STARs) {
+		star := ap.STARs[name]
+		for _, tr := range SortedMapKeys(star.Transitions) {
+			fmt.Fprintf(w, "%-12s: %s\n", name+"."+tr, star.Transitions[tr].Encode())
+		}
+		for _, rwy := range SortedMapKeys(star.RunwayWaypoints) {
+			fmt.Fprintf(w, "%-12s: %s\n", name+".RWY"+rwy, star.RunwayWaypoints[rwy].Encode())
+		}
 	}
-	for rwy, wps := range s.RunwayWaypoints {
-		fmt.Printf("%-12s: %s\n", name+".RWY"+rwy, wps.Encode())
+	fmt.Fprintf(w, "\nApproaches:\n")
+	for _, name := range SortedMapKeys(ap.Approaches) {
+		fmt.Fprintf(w, "%-5s: ", name)
+		for i, wps := range ap.Approaches[name] {
+			if i > 0 {
+				fmt.Fprintf(w, "       ")
+			}
+			fmt.Fprintln(w, wps.Encode())
+		}
 	}
+	return nil
 }
 
 type Runway struct {
@@ -248,6 +297,7 @@ type Controller struct {
 	SectorId           string    `json:"sector_id"`  // e.g. N56, 2J, ...
 	Scope              string    `json:"scope_char"` // For tracked a/c on the scope--e.g., T
 	IsHuman            bool      // Not provided in scenario JSON
+	Initials           string    // Not provided in scenario JSON; set at sign on
 	FacilityIdentifier string    `json:"facility_id"`     // For example the "N" in "N4P" showing the N90 TRACON
 	ERAMFacility       bool      `json:"eram_facility"`   // To weed out N56 and N4P being the same fac
 	DefaultAirport     string    `json:"default_airport"` // only required if CRDA is a thing
@@ -422,6 +472,23 @@ func (fp FlightPlan) TypeWithoutSuffix() string {
 	}
 }
 
+// EquipmentSuffix returns the equipment suffix from the aircraft type, if
+// there is one.
+func (fp FlightPlan) EquipmentSuffix() string {
+	actypeFields := strings.Split(fp.AircraftType, "/")
+	switch len(actypeFields) {
+	case 3:
+		return actypeFields[2]
+	case 2:
+		if actypeFields[0] == "H" || actypeFields[0] == "S" || actypeFields[0] == "J" {
+			return ""
+		}
+		return actypeFields[1]
+	default:
+		return ""
+	}
+}
+
 func PlausibleFinalAltitude(w *World, fp *FlightPlan, perf AircraftPerformance) (altitude int) {
 	// try to figure out direction of flight
 	dep, dok := database.Airports[fp.DepartureAirport]
@@ -431,11 +498,11 @@ func PlausibleFinalAltitude(w *World, fp *FlightPlan, perf AircraftPerformance)
 	}
 
 	pDep, pArr := dep.Location, arr.Location
-	if nmdistance2ll(pDep, pArr) < 100 {
+	if d := nmgeodesic2ll(pDep, pArr); d < 100 {
 		altitude = 7000
-	} else if nmdistance2ll(pDep, pArr) < 200 {
+	} else if d < 200 {
 		altitude = 11000
-	} else if nmdistance2ll(pDep, pArr) < 300 {
+	} else if d < 300 {
 		altitude = 21000
 	} else {
 		altitude = 37000
@@ -841,12 +908,23 @@ func (wp *Waypoint) ETA(p Point2LL, gs float32) time.Duration {
 
 type WaypointArray []Waypoint
 
+// Encode returns the waypoints in the compact string format used in
+// scenario files; parsing the result gives the same waypoints.
 func (wslice WaypointArray) Encode() string {
+	// Write floats with as many digits as are needed to represent them
+	// exactly.
+	ftoa := func(f float32) string { return strconv.FormatFloat(float64(f), 'f', -1, 32) }
+
 	var entries []string
 	for _, w := range wslice {
 		s := w.Fix
-		if w.AltitudeRestriction != nil {
-			s += "/a" + w.AltitudeRestriction.Encoded()
+		if ar := w.AltitudeRestriction; ar != nil {
+			if enc := ar.Encoded(); enc != "" {
+				s += "/a" + enc
+			} else {
+				// An "at 0" restriction (e.g. "/a000") has an empty range.
+				s += "/a0"
+			}
 		}
 		if w.Speed != 0 {
 			s += fmt.Sprintf("/s%d", w.Speed)
@@ -866,9 +944,9 @@ func (wslice WaypointArray) Encode() string {
 				}
 			}
 			if pt.MinuteLimit != 0 {
-				s += fmt.Sprintf("%.1fmin", pt.MinuteLimit)
+				s += ftoa(pt.MinuteLimit) + "min"
 			} else {
-				s += fmt.Sprintf("%.1fnm", pt.NmLimit)
+				s += ftoa(pt.NmLimit) + "nm"
 			}
 			if pt.Entry180NoPT {
 				s += "/nopt180"
@@ -903,9 +981,9 @@ func (wslice WaypointArray) Encode() string {
 		}
 		if w.Arc != nil {
 			if w.Arc.Fix != "" {
-				s += fmt.Sprintf("/arc%.1f%s", w.Arc.Radius, w.Arc.Fix)
+				s += "/arc" + ftoa(w.Arc.Radius) + w.Arc.Fix
 			} else {
-				s += fmt.Sprintf("/arc%.1f", w.Arc.Length)
+				s += "/arc" + ftoa(w.Arc.Length)
 			}
 		}
 
@@ -1259,6 +1337,12 @@ func (rs *RadarSite) CheckVisibility(w *World, p Point2LL, altitude int) (primar
 
 	primary = distance <= float32(rs.PrimaryRange)
 	secondary = !primary && distance <= float32(rs.SecondaryRange)
+
+	if (primary || secondary) &&
+		LookupTerrain(w.TRACON).BlocksLineOfSight(rs.Position, float32(rs.Elevation), p, float32(altitude)) {
+		// Masked by terrain
+		primary, secondary = false, false
+	}
 	return
 }
 
@@ -1355,6 +1439,7 @@ type StaticDatabase struct {
 	AircraftPerformance map[string]AircraftPerformance
 	Airlines            map[string]Airline
 	MagneticGrid        MagneticGrid
+	MagneticModel       *MagneticModel // may be nil
 	ARTCCs              map[string]ARTCC
 	TRACONs             map[string]TRACON
 	MVAs                map[string][]MVA // TRACON -> MVAs
@@ -1438,6 +1523,8 @@ func InitializeStaticDatabase() *StaticDatabase {
 	wg.Add(1)
 	go func() { db.MagneticGrid = parseMagneticGrid(); wg.Done() }()
 	wg.Add(1)
+	go func() { db.MagneticModel = parseMagneticModel(); wg.Done() }()
+	wg.Add(1)
 	go func() { db.ARTCCs, db.TRACONs = parseARTCCsAndTRACONs(); wg.Done() }()
 	wg.Add(1)
 	go func() { db.MVAs = parseMVAs(); wg.Done() }()
@@ -0,0 +1,123 @@
+// callsigns.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Similar callsigns: as at real facilities, controllers are warned when
+// aircraft with confusingly similar callsigns (e.g., AAL123 and AAL132,
+// or AAL123 and UAL123) are active at the same time. The sim posts a
+// SimilarCallsignEvent when such an aircraft is launched, the STARS
+// pane can optionally highlight the callsigns in their datablocks, and
+// the launch configuration can ask the spawner to avoid generating them
+// in the first place.
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+// Number of times to resample a new aircraft to try to avoid a callsign
+// that is similar to an existing one.
+const similarCallsignRetries = 10
+
+// splitCallsign returns the airline or registration prefix of the given
+// callsign and the flight number or the rest of the registration.
+func splitCallsign(callsign string) (string, string) {
+	if idx := strings.IndexAny(callsign, "0123456789"); idx != -1 {
+		return callsign[:idx], callsign[idx:]
+	}
+	return callsign, ""
+}
+
+// callsignsSimilar returns true if the two callsigns are different but may
+// be confused with each other: they either have the same flight number
+// with different airlines, or are for the same airline and have flight
+// numbers of the same length that differ in a single character or have
+// the same characters in a different order.
+func callsignsSimilar(a, b string) bool {
+	if a == b {
+		return false
+	}
+
+	pa, na := splitCallsign(a)
+	pb, nb := splitCallsign(b)
+	if na == "" || nb == "" {
+		return false
+	}
+	if pa != pb {
+		return na == nb
+	}
+	if len(na) != len(nb) {
+		return false
+	}
+
+	ndiff := 0
+	for i := range na {
+		if na[i] != nb[i] {
+			ndiff++
+		}
+	}
+	if ndiff == 1 {
+		return true
+	}
+
+	sa, sb := []byte(na), []byte(nb)
+	slices.Sort(sa)
+	slices.Sort(sb)
+	return slices.Equal(sa, sb)
+}
+
+// SimilarCallsigns returns the callsigns of the aircraft that may be
+// confused with the given one, sorted alphabetically.
+func (w *World) SimilarCallsigns(callsign string) []string {
+	var similar []string
+	for cs := range w.Aircraft {
+		if callsignsSimilar(callsign, cs) {
+			similar = append(similar, cs)
+		}
+	}
+	slices.Sort(similar)
+	return similar
+}
+
+// createAircraft calls the provided function to create a new aircraft;
+// if the launch configuration asks for similar callsigns to be avoided,
+// it tries again a few times if the aircraft's callsign is similar to an
+// existing one. Assumes s.mu is held.
+func (s *Sim) createAircraft(create func() (*Aircraft, error)) (*Aircraft, error) {
+	for i := 0; ; i++ {
+		ac, err := create()
+		if err != nil || ac == nil || !s.LaunchConfig.AvoidSimilarCallsigns ||
+			i == similarCallsignRetries || len(s.World.SimilarCallsigns(ac.Callsign)) == 0 {
+			return ac, err
+		}
+		s.lg.Debug("resampling aircraft with similar callsign", slog.String("callsign", ac.Callsign))
+	}
+}
+
+// checkSimilarCallsigns is called with s.mu held when an aircraft is
+// launched; it posts an event if its callsign is similar to those of any
+// of the active aircraft.
+func (s *Sim) checkSimilarCallsigns(ac *Aircraft) {
+	if similar := s.World.SimilarCallsigns(ac.Callsign); len(similar) > 0 {
+		s.eventStream.Post(Event{
+			Type:     SimilarCallsignEvent,
+			Callsign: ac.Callsign,
+			Message:  "Similar callsigns: " + ac.Callsign + ", " + strings.Join(similar, ", "),
+		})
+		s.lg.Info("similar callsigns", slog.String("callsign", ac.Callsign), slog.Any("similar", similar))
+	}
+}
+
+func (lc *LaunchConfig) DrawCallsignUI() (changed bool) {
+	imgui.Separator()
+	changed = imgui.Checkbox("Avoid similar callsigns", &lc.AvoidSimilarCallsigns)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Don't launch aircraft with callsigns that may be confused with those of active aircraft")
+	}
+	return
+}
@@ -0,0 +1,38 @@
+// callsigns_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestCallsignsSimilar(t *testing.T) {
+	for _, test := range []struct {
+		a, b    string
+		similar bool
+	}{
+		{"AAL123", "AAL132", true},  // transposition
+		{"AAL123", "AAL321", true},  // same digits
+		{"AAL123", "AAL124", true},  // one digit differs
+		{"AAL123", "UAL123", true},  // same flight number
+		{"N123AB", "N132AB", true},  // registrations
+		{"AAL123", "AAL123", false}, // identical
+		{"AAL123", "AAL456", false},
+		{"AAL123", "AAL1234", false},
+		{"AAL123", "UAL124", false},
+		{"AAL123", "AAL143", true},
+		{"AAL1234", "AAL4321", true},
+		{"AAL1234", "AAL1243", true},
+		{"AAL1234", "AAL1256", false},
+		{"AAL", "AAL", false},
+	} {
+		if s := callsignsSimilar(test.a, test.b); s != test.similar {
+			t.Errorf("%s/%s: got %v, expected %v", test.a, test.b, s, test.similar)
+		}
+		if s := callsignsSimilar(test.b, test.a); s != test.similar {
+			t.Errorf("%s/%s: not symmetric", test.b, test.a)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+// cloudsync.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Configuration profiles and a few other settings can optionally be
+// synced through a user-supplied WebDAV server (Nextcloud, ownCloud, many
+// NAS boxes, or an S3 bucket behind a WebDAV gateway) so that they follow
+// users across machines. The synced settings are stored in a single JSON
+// file that is downloaded when vice starts and uploaded when it exits;
+// if both have changed, the most recently modified version wins. Local
+// changes are detected by periodically hashing the synced settings, which
+// gives the time they were last modified. (The upload at exit is given
+// only a few seconds so that a slow server doesn't hold up quitting; if
+// it doesn't finish, the changes are uploaded the next time vice exits.)
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type ConfigSync struct {
+	Enabled bool
+	// URL of the file to sync to, e.g.
+	// https://cloud.example.com/remote.php/dav/files/user/vice.json
+	URL      string
+	Username string
+	// Note that the password is stored in the config file in plain text;
+	// an app-specific password should be used if the server supports them.
+	Password string
+	LastSync time.Time
+	// Hash of the synced settings when they were last checked for local
+	// changes and the time at which they were last seen to change.
+	Hash          string
+	LocalModified time.Time
+}
+
+// SyncedConfig is the subset of GlobalConfig that is synced.
+type SyncedConfig struct {
+	Version    int
+	Modified   time.Time
+	Profiles   map[string]*ConfigProfile
+	UIFontSize int
+	LogLevels  map[string]string
+}
+
+var ErrNoSyncedConfig = errors.New("no synced config found on the server")
+
+const (
+	configSyncTimeout     = 15 * time.Second
+	configSyncExitTimeout = 3 * time.Second
+)
+
+// Network requests run asynchronously; when they finish, they send a
+// function to run on the main thread to process the result.
+var configSync struct {
+	results   chan func(*World, Renderer, *EventStream)
+	pending   bool
+	status    string
+	lastCheck time.Time
+}
+
+func (cs *ConfigSync) request(ctx context.Context, method string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, cs.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if cs.Username != "" {
+		req.SetBasicAuth(cs.Username, cs.Password)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return http.DefaultClient.Do(req)
+}
+
+func (cs *ConfigSync) Download(ctx context.Context) (*SyncedConfig, error) {
+	resp, err := cs.request(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var sc SyncedConfig
+		if err := json.NewDecoder(resp.Body).Decode(&sc); err != nil {
+			return nil, err
+		}
+		return &sc, nil
+	case http.StatusNotFound:
+		return nil, ErrNoSyncedConfig
+	default:
+		return nil, fmt.Errorf("download: server returned %s", resp.Status)
+	}
+}
+
+func (cs *ConfigSync) Upload(ctx context.Context, b []byte) error {
+	resp, err := cs.request(ctx, http.MethodPut, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("upload: server returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (gc *GlobalConfig) MakeSyncedConfig() *SyncedConfig {
+	// Make sure that the synced profile reflects the current settings.
+	if gc.ActiveProfile != "" {
+		if err := gc.SaveProfile(gc.ActiveProfile); err != nil {
+			lg.Warnf("%s: unable to save profile: %v", gc.ActiveProfile, err)
+		}
+	}
+
+	return &SyncedConfig{
+		Version:    CurrentConfigVersion,
+		Modified:   time.Now(),
+		Profiles:   gc.Profiles,
+		UIFontSize: gc.UIFontSize,
+		LogLevels:  gc.LogLevels,
+	}
+}
+
+// syncedSettingsHash returns a hash of the settings that would be synced.
+// The current display configuration, pane windows, and key bindings are
+// used for the active profile, since they are saved to it before
+// uploading.
+func (gc *GlobalConfig) syncedSettingsHash() string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, name := range SortedMapKeys(gc.Profiles) {
+		if name != gc.ActiveProfile {
+			_ = enc.Encode(name)
+			_ = enc.Encode(gc.Profiles[name])
+		}
+	}
+	if gc.ActiveProfile != "" {
+		_ = enc.Encode(gc.ActiveProfile)
+		_ = enc.Encode(gc.DisplayRoot)
+		_ = enc.Encode(gc.PaneWindows)
+		_ = enc.Encode(gc.KeyBindings)
+	}
+	_ = enc.Encode(gc.UIFontSize)
+	_ = enc.Encode(gc.LogLevels)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkLocalSyncChanges updates LocalModified if the synced settings have
+// changed since they were last checked.
+func (gc *GlobalConfig) checkLocalSyncChanges() {
+	h := gc.syncedSettingsHash()
+	if h != gc.Sync.Hash {
+		if gc.Sync.Hash != "" {
+			gc.Sync.LocalModified = time.Now()
+		}
+		gc.Sync.Hash = h
+	}
+}
+
+// ApplySyncedConfig merges the synced settings into the config: profiles
+// from the server replace local profiles with the same name, while
+// profiles that only exist locally are kept. If the active profile was
+// replaced, the display is rebuilt from it.
+func (gc *GlobalConfig) ApplySyncedConfig(sc *SyncedConfig, w *World, r Renderer, eventStream *EventStream) error {
+	if sc.Version > CurrentConfigVersion {
+		return fmt.Errorf("synced config is from a newer version of vice (%d)", sc.Version)
+	}
+
+	for name, p := range sc.Profiles {
+		if p.DisplayRoot == nil {
+			continue
+		}
+		if sc.Version < CurrentConfigVersion {
+			p.VisitPanes(func(pane Pane) {
+				if up, ok := pane.(PaneUpgrader); ok {
+					up.Upgrade(sc.Version, CurrentConfigVersion)
+				}
+			})
+		}
+		if gc.Profiles == nil {
+			gc.Profiles = make(map[string]*ConfigProfile)
+		}
+		gc.Profiles[name] = p
+	}
+
+	// Load the synced copy of the active profile so that the local
+	// layout doesn't overwrite it when the profile is next saved.
+	if _, ok := sc.Profiles[gc.ActiveProfile]; ok && gc.ActiveProfile != "" {
+		if err := gc.LoadProfile(gc.ActiveProfile, w, r, eventStream); err != nil {
+			lg.Warnf("%s: unable to load synced profile: %v", gc.ActiveProfile, err)
+		}
+	}
+
+	if sc.UIFontSize != 0 && sc.UIFontSize != gc.UIFontSize {
+		gc.UIFontSize = sc.UIFontSize
+		ui.font = GetFont(FontIdentifier{Name: "Roboto Regular", Size: gc.uiFontSize()})
+	}
+	for subsystem, level := range sc.LogLevels {
+		if err := SetLogLevel(subsystem, level); err == nil {
+			if gc.LogLevels == nil {
+				gc.LogLevels = make(map[string]string)
+			}
+			gc.LogLevels[subsystem] = level
+		}
+	}
+
+	gc.Sync.LastSync = sc.Modified
+	// The downloaded settings aren't a local change.
+	gc.Sync.Hash = gc.syncedSettingsHash()
+	return nil
+}
+
+func configSyncStart(f func() func(*World, Renderer, *EventStream)) {
+	if configSync.results == nil {
+		configSync.results = make(chan func(*World, Renderer, *EventStream), 1)
+	}
+	configSync.pending = true
+	go func() { configSync.results <- f() }()
+}
+
+// StartConfigSyncDownload starts downloading the synced config in the
+// background. If force is false, it is only applied if it has changed
+// since the last sync.
+func StartConfigSyncDownload(force bool) {
+	if !globalConfig.Sync.Enabled || globalConfig.Sync.URL == "" || configSync.pending {
+		return
+	}
+
+	configSync.status = "Downloading..."
+	cs := globalConfig.Sync
+	configSyncStart(func() func(*World, Renderer, *EventStream) {
+		ctx, cancel := context.WithTimeout(context.Background(), configSyncTimeout)
+		defer cancel()
+		sc, err := cs.Download(ctx)
+		return func(w *World, r Renderer, eventStream *EventStream) {
+			if err == ErrNoSyncedConfig {
+				configSync.status = "No synced settings on the server yet"
+			} else if err != nil {
+				lg.Warnf("config sync: %v", err)
+				configSync.status = "Error: " + err.Error()
+			} else if !force && !sc.Modified.After(globalConfig.Sync.LastSync) {
+				configSync.status = "Settings are up to date"
+			} else if !force && !sc.Modified.After(globalConfig.Sync.LocalModified) {
+				configSync.status = "Local settings are newer than the synced settings"
+			} else if err := globalConfig.ApplySyncedConfig(sc, w, r, eventStream); err != nil {
+				configSync.status = "Error: " + err.Error()
+			} else {
+				configSync.status = "Downloaded settings from " + sc.Modified.Local().Format(time.DateTime)
+				eventStream.Post(Event{Type: StatusMessageEvent, Message: "Synced settings downloaded"})
+			}
+		}
+	})
+}
+
+// StartConfigSyncUpload starts uploading the current settings in the
+// background.
+func StartConfigSyncUpload() {
+	if !globalConfig.Sync.Enabled || globalConfig.Sync.URL == "" || configSync.pending {
+		return
+	}
+
+	sc := globalConfig.MakeSyncedConfig()
+	b, err := json.Marshal(sc)
+	if err != nil {
+		configSync.status = "Error: " + err.Error()
+		return
+	}
+
+	configSync.status = "Uploading..."
+	cs := globalConfig.Sync
+	hash := globalConfig.syncedSettingsHash()
+	configSyncStart(func() func(*World, Renderer, *EventStream) {
+		ctx, cancel := context.WithTimeout(context.Background(), configSyncTimeout)
+		defer cancel()
+		err := cs.Upload(ctx, b)
+		return func(*World, Renderer, *EventStream) {
+			if err != nil {
+				lg.Warnf("config sync: %v", err)
+				configSync.status = "Error: " + err.Error()
+			} else {
+				globalConfig.Sync.LastSync = sc.Modified
+				globalConfig.Sync.Hash = hash
+				configSync.status = "Uploaded settings"
+			}
+		}
+	})
+}
+
+// uiUpdateConfigSync should be called once per frame from the main
+// thread; it processes the results of completed sync requests.
+func uiUpdateConfigSync(w *World, r Renderer, eventStream *EventStream) {
+	if globalConfig.Sync.Enabled && time.Since(configSync.lastCheck) > 15*time.Second {
+		configSync.lastCheck = time.Now()
+		globalConfig.checkLocalSyncChanges()
+	}
+
+	if !configSync.pending {
+		return
+	}
+
+	select {
+	case f := <-configSync.results:
+		configSync.pending = false
+		f(w, r, eventStream)
+	default:
+	}
+}
+
+// UploadSyncedConfig synchronously uploads the current settings; it is
+// called when vice exits and so gives up after configSyncExitTimeout. The
+// settings are only uploaded if they have changed locally since the last
+// sync and the copy on the server hasn't been modified more recently.
+func UploadSyncedConfig() {
+	gc := globalConfig
+	if !gc.Sync.Enabled || gc.Sync.URL == "" {
+		return
+	}
+
+	gc.checkLocalSyncChanges()
+	if !gc.Sync.LocalModified.After(gc.Sync.LastSync) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), configSyncExitTimeout)
+	defer cancel()
+
+	if remote, err := gc.Sync.Download(ctx); err == nil {
+		if remote.Modified.After(gc.Sync.LocalModified) {
+			lg.Infof("config sync: not uploading settings; the server's copy is newer (%s)", remote.Modified)
+			return
+		}
+	} else if err != ErrNoSyncedConfig {
+		lg.Warnf("config sync: %v", err)
+		return
+	}
+
+	sc := gc.MakeSyncedConfig()
+	sc.Modified = gc.Sync.LocalModified
+	b, err := json.Marshal(sc)
+	if err == nil {
+		err = gc.Sync.Upload(ctx, b)
+	}
+	if err != nil {
+		lg.Warnf("config sync: %v", err)
+	} else {
+		gc.Sync.LastSync = sc.Modified
+		gc.Sync.Hash = gc.syncedSettingsHash()
+	}
+}
+
+func uiDrawConfigSyncSettings() {
+	cs := &globalConfig.Sync
+	imgui.Checkbox("Sync profiles via WebDAV", &cs.Enabled)
+
+	uiStartDisable(!cs.Enabled)
+	imgui.InputTextV("URL", &cs.URL, 0, nil)
+	imgui.InputTextV("Username", &cs.Username, 0, nil)
+	imgui.InputTextV("Password", &cs.Password, imgui.InputTextFlagsPassword, nil)
+
+	uiStartDisable(configSync.pending)
+	if imgui.Button("Upload now") {
+		StartConfigSyncUpload()
+	}
+	imgui.SameLine()
+	if imgui.Button("Download now") {
+		StartConfigSyncDownload(true)
+	}
+	uiEndDisable(configSync.pending)
+	uiEndDisable(!cs.Enabled)
+
+	if configSync.status != "" {
+		imgui.Text(configSync.status)
+	}
+}
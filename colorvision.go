@@ -0,0 +1,109 @@
+// colorvision.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Color vision palettes replace the colors that carry safety-critical
+// distinctions on the scope--owned versus unowned tracks, point outs,
+// alerts, and weather levels--with ones that remain distinguishable
+// with common color vision deficiencies. The defaults rely on
+// red/green/yellow, which are hard to tell apart with deuteranopia and
+// protanopia. A palette is applied on top of the current theme (see
+// theme.go), so that the rest of the theme's colors are kept; it's
+// selected in the accessibility settings.
+//
+// The colors are based on the Okabe-Ito palette, adjusted to be bright
+// enough to read against the scope's dark background.
+
+import (
+	"github.com/mmp/imgui-go/v4"
+)
+
+type ColorVisionPalette struct {
+	Name        string
+	Description string
+	// Colors that the palette overrides, indexed by ThemeColor Name.
+	Colors map[string]RGB
+}
+
+var colorVisionPalettes = []ColorVisionPalette{
+	{
+		Name:        "Deuteranopia / Protanopia",
+		Description: "For red-green color blindness: uses blue/yellow/magenta in place of green/yellow/red",
+		Colors: map[string]RGB{
+			"Tracked aircraft":   {1, 1, 1},
+			"Untracked aircraft": {.34, .71, .91}, // sky blue
+			"Inbound point outs": {.94, .89, .26}, // yellow
+			"Ghosts":             {.94, .89, .26},
+			"Selected aircraft":  {.6, .8, 1},
+			"ATPA warning":       {.94, .89, .26},
+			"ATPA alert":         {1, .3, 1}, // magenta
+			"Text alerts":        {1, .3, 1},
+			"Similar callsigns":  {.8, .47, .65}, // reddish purple
+			"Lists":              {.34, .71, .91},
+			"Weather levels 1-3": RGBFromHex(0x1B3F66), // dark blue
+			"Weather levels 4-6": RGBFromHex(0x80620F), // dark orange
+			"Caution":            {.94, .89, .26},
+			"Error":              {1, .3, 1},
+		},
+	},
+	{
+		Name:        "Tritanopia",
+		Description: "For blue-yellow color blindness: uses red/cyan/white in place of yellow/blue",
+		Colors: map[string]RGB{
+			"Tracked aircraft":   {1, 1, 1},
+			"Untracked aircraft": {0, .8, .8},  // cyan
+			"Inbound point outs": {1, .45, .2}, // orange-red
+			"Ghosts":             {1, .45, .2},
+			"Selected aircraft":  {1, .6, .8}, // pink
+			"ATPA warning":       {1, .45, .2},
+			"ATPA alert":         {1, 0, 0},
+			"Text alerts":        {1, 0, 0},
+			"Similar callsigns":  {1, .6, .8},
+			"Track blocks":       {.9, .9, .9},
+			"Weather levels 1-3": RGBFromHex(0x2A4D4D), // dark cyan
+			"Weather levels 4-6": RGBFromHex(0x6B2A2A), // dark red
+			"Caution":            {1, .45, .2},
+			"Error":              {1, 0, 0},
+		},
+	},
+}
+
+// currentColorVisionPalette returns the colors that are overridden by the
+// selected color vision palette, if any.
+func currentColorVisionPalette() map[string]RGB {
+	if globalConfig == nil {
+		return nil
+	}
+	for _, p := range colorVisionPalettes {
+		if p.Name == globalConfig.Accessibility.ColorVision {
+			return p.Colors
+		}
+	}
+	return nil
+}
+
+func uiDrawColorVisionSettings() {
+	cur := globalConfig.Accessibility.ColorVision
+	if imgui.BeginComboV("Color vision palette", Select(cur == "", "Default", cur), 0) {
+		if imgui.SelectableV("Default", cur == "", 0, imgui.Vec2{}) {
+			globalConfig.Accessibility.ColorVision = ""
+			globalConfig.Theme.Apply()
+		}
+		for _, p := range colorVisionPalettes {
+			if imgui.SelectableV(p.Name, p.Name == cur, 0, imgui.Vec2{}) {
+				globalConfig.Accessibility.ColorVision = p.Name
+				globalConfig.Theme.Apply()
+			}
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip(p.Description)
+			}
+		}
+		imgui.EndCombo()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Replaces the scope colors for tracks, alerts, and weather with ones\n" +
+			"that are easier to distinguish with color vision deficiencies")
+	}
+}
@@ -0,0 +1,43 @@
+// colorvision_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestColorVisionPalettes(t *testing.T) {
+	safetyCritical := []string{"Tracked aircraft", "Untracked aircraft", "Inbound point outs",
+		"ATPA warning", "ATPA alert", "Text alerts", "Weather levels 1-3", "Weather levels 4-6"}
+
+	for _, p := range colorVisionPalettes {
+		for name := range p.Colors {
+			if !slices.ContainsFunc(themeColors, func(tc ThemeColor) bool { return tc.Name == name }) {
+				t.Errorf("%s: unknown theme color %q", p.Name, name)
+			}
+		}
+		for _, name := range safetyCritical {
+			if _, ok := p.Colors[name]; !ok {
+				t.Errorf("%s: doesn't set %q", p.Name, name)
+			}
+		}
+		if p.Colors["Tracked aircraft"] == p.Colors["Untracked aircraft"] ||
+			p.Colors["Untracked aircraft"] == p.Colors["Text alerts"] ||
+			p.Colors["Weather levels 1-3"] == p.Colors["Weather levels 4-6"] {
+			t.Errorf("%s: safety-critical colors aren't distinct", p.Name)
+		}
+	}
+
+	defer func(gc *GlobalConfig) { globalConfig = gc }(globalConfig)
+	globalConfig = &GlobalConfig{}
+	if p := currentColorVisionPalette(); p != nil {
+		t.Errorf("expected no palette by default, got %v", p)
+	}
+	globalConfig.Accessibility.ColorVision = colorVisionPalettes[0].Name
+	if p := currentColorVisionPalette(); p == nil || p["Untracked aircraft"] != colorVisionPalettes[0].Colors["Untracked aircraft"] {
+		t.Errorf("expected the %s palette, got %v", colorVisionPalettes[0].Name, p)
+	}
+}
@@ -0,0 +1,107 @@
+// commandjournal.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Command journal: the sim records each aircraft command issued by a
+// controller, along with the sim time and the controller's position. The
+// commands are logged and the journal is saved with the sim, so it's
+// included in saved sims and autosaves. The -replayscript option turns a
+// saved sim's journal into a smoke test (see smoketest.go), e.g.:
+//
+//	vice -replayscript ~/.config/vice/saves/Autosave.json > replay.json
+//	vice -smoketest replay.json
+//
+// so that a bug report can include a script that reproduces the session
+// by replaying the commands at the same times against the same scenario
+// and random seed. Replay is best-effort: the smoke test issues all of
+// the commands as the primary controller, aircraft launched by hand
+// aren't recorded, and the user interface of a local sim draws from the
+// same random number generator as the sim does.
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type CommandJournalEntry struct {
+	Time       int // seconds after the sim started
+	Controller string
+	Callsign   string
+	Commands   string
+	// If there was an error, the commands that weren't run and the error
+	// message that was returned to the controller.
+	Remaining string `json:",omitempty"`
+	Error     string `json:",omitempty"`
+}
+
+// journalCommands records the aircraft commands issued by the controller
+// with the given token and their result.
+func (s *Sim) journalCommands(token, callsign, commands string, result *AircraftCommandsResult) {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return
+	}
+
+	e := CommandJournalEntry{
+		Time:       int(s.SimTime.Sub(s.ScriptState.Start).Seconds()),
+		Controller: ctrl.Callsign,
+		Callsign:   callsign,
+		Commands:   strings.Join(strings.Fields(commands), " "),
+	}
+	if result.ErrorMessage != "" {
+		e.Remaining, e.Error = result.RemainingInput, result.ErrorMessage
+	}
+	s.CommandJournal = append(s.CommandJournal, e)
+
+	s.lg.Info("controller command", slog.Int("time", e.Time), slog.String("controller", e.Controller),
+		slog.String("callsign", e.Callsign), slog.String("commands", e.Commands),
+		slog.String("error", e.Error))
+}
+
+// ReplaySmokeTest returns a smoke test that replays the sim's command
+// journal. Commands that weren't run due to an error are left out.
+func (s *Sim) ReplaySmokeTest() SmokeTest {
+	t := SmokeTest{
+		Scenario: s.Scenario,
+		Seed:     s.Seed,
+		Duration: int(s.SimTime.Sub(s.ScriptState.Start).Seconds()),
+	}
+	if s.World != nil {
+		t.TRACON = s.World.TRACON
+	}
+
+	for _, e := range s.CommandJournal {
+		cmds := strings.TrimSpace(strings.TrimSuffix(e.Commands, e.Remaining))
+		if cmds == "" {
+			continue
+		}
+		t.Commands = append(t.Commands, SmokeTestCommand{
+			Time:     e.Time,
+			Callsign: e.Callsign,
+			Commands: cmds,
+		})
+		t.Duration = max(t.Duration, e.Time)
+	}
+	return t
+}
+
+// writeReplayScript writes a smoke test that replays the command journal
+// of the saved sim in the given file.
+func writeReplayScript(w io.Writer, fn string) error {
+	_, sim, err := readSavedSim(fn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	if sim.World == nil {
+		return fmt.Errorf("%s: saved sim has no world", fn)
+	}
+
+	return writeJSON(w, sim.ReplaySmokeTest())
+}
@@ -0,0 +1,62 @@
+// commandjournal_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReplaySmokeTest(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := &Sim{
+		Scenario:    "JFK 31L/31R",
+		World:       &World{TRACON: "N90"},
+		Seed:        42,
+		SimTime:     start.Add(5 * time.Minute),
+		ScriptState: ScriptState{Start: start},
+		CommandJournal: []CommandJournalEntry{
+			{Time: 10, Controller: "2K", Callsign: "AAL1", Commands: "D40 S210"},
+			// Only the commands before the error were run.
+			{Time: 20, Controller: "2K", Callsign: "JBU2", Commands: "H180 XYZ D50",
+				Remaining: "XYZ D50", Error: "Invalid or unknown command"},
+			{Time: 30, Controller: "2K", Callsign: "DAL3", Commands: "C80",
+				Remaining: "C80", Error: "Aircraft is not on your frequency"},
+		},
+	}
+
+	st := s.ReplaySmokeTest()
+	if st.TRACON != "N90" || st.Scenario != "JFK 31L/31R" || st.Seed != 42 || st.Duration != 300 {
+		t.Errorf("unexpected smoke test %+v", st)
+	}
+	expected := []SmokeTestCommand{
+		{Time: 10, Callsign: "AAL1", Commands: "D40 S210"},
+		{Time: 20, Callsign: "JBU2", Commands: "H180"},
+	}
+	if !slices.EqualFunc(st.Commands, expected, func(a, b SmokeTestCommand) bool {
+		return a.Time == b.Time && a.Callsign == b.Callsign && a.Commands == b.Commands
+	}) {
+		t.Errorf("got commands %+v, expected %+v", st.Commands, expected)
+	}
+
+	// Make sure that the smoke test can be loaded.
+	fn := filepath.Join(t.TempDir(), "replay.json")
+	f, err := os.Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSON(f, st); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if lt, err := LoadSmokeTest(fn); err != nil {
+		t.Errorf("unable to load replay script: %v", err)
+	} else if len(lt.Commands) != 2 || lt.Seed != 42 {
+		t.Errorf("unexpected loaded smoke test %+v", lt)
+	}
+}
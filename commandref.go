@@ -0,0 +1,937 @@
+// commandref.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// The aircraft control commands (e.g., H270 for "fly heading 270") are
+// defined by the aircraftCommands table below. Each entry gives the
+// command's syntax, what it does, and an example, along with its
+// grammar and the code that runs it. The same table drives the server's
+// command parser (SimDispatcher.RunAircraftCommands), the keyboard
+// command reference window, tab completion in the messages pane, and
+// the help overlay that the STARS scope shows next to the mouse cursor
+// as a command is typed, so that the documentation can't drift from
+// what is actually accepted.
+//
+// Syntax, descriptions, and examples use the markup understood by
+// uiDrawMarkedupText: *text* is a literal and _text_ is a placeholder,
+// so "*C_alt" is C followed by an altitude.
+//
+// Grammars are what the parser uses. Characters stand for themselves,
+// {kind} is an argument of one of the kinds in commandArgKinds, [...]
+// is optional, and | separates alternatives, so "H[{hdg}]" is H,
+// optionally followed by a heading. Entries are matched in order, so
+// more specific commands must come before more general ones with the
+// same prefix. Because the parser knows which argument it's in, it can
+// report where a command went wrong ("expected heading at character
+// 2") and which fix a partially-typed command is naming.
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type AircraftCommand struct {
+	Syntax      string
+	Description string
+	Example     string
+	// Primary commands are the ones that are used most frequently; the
+	// rest are listed separately in the command reference.
+	Primary bool
+	Grammar string
+
+	// run issues the command with the arguments given by its grammar;
+	// it is nil for commands that are handled by the scope rather than
+	// the server.
+	run func(s *Sim, token, callsign string, args commandArgs) error
+}
+
+type commandArgKind struct {
+	name string
+	// scan returns the length of the longest prefix of s that may be an
+	// argument of this kind.
+	scan func(s string) int
+	// valid, if non-nil, checks the complete argument.
+	valid func(s string) bool
+}
+
+var commandArgKinds = map[string]commandArgKind{
+	"alt":  {name: "altitude", scan: scanDigits},
+	"altr": {name: "altitude restriction", scan: scanAltitudeRestriction, valid: validAltitudeRestriction},
+	"appr": {name: "approach", scan: scanField, valid: func(s string) bool { return !isAllNumbers(s) }},
+	"code": {name: "squawk code", scan: scanDigits, valid: func(s string) bool { _, err := ParseSquawk(s); return err == nil }},
+	"deg":  {name: "degrees", scan: scanDigits},
+	"fix":  {name: "fix", scan: scanField},
+	"hdg":  {name: "heading", scan: scanDigits},
+	"kts":  {name: "speed", scan: scanDigits},
+	"min":  {name: "minutes", scan: scanDigits},
+	"pos":  {name: "position", scan: scanField},
+}
+
+func scanDigits(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// scanField scans up to the next '/', which separates the parts of
+// commands like C_fix/A_alt.
+func scanField(s string) int {
+	if n := strings.IndexByte(s, '/'); n != -1 {
+		return n
+	}
+	return len(s)
+}
+
+func scanAltitudeRestriction(s string) int {
+	n := 0
+	for n < len(s) && strings.IndexByte("0123456789+-", s[n]) != -1 {
+		n++
+	}
+	return n
+}
+
+func validAltitudeRestriction(s string) bool {
+	_, err := ParseAltitudeRestriction(s)
+	return err == nil
+}
+
+type commandArg struct {
+	kind string
+	text string
+	// pos is the offset of the argument in the command.
+	pos int
+}
+
+// int returns the value of a numeric argument; the parser has already
+// checked that it is all digits.
+func (a commandArg) int() int {
+	v, _ := strconv.Atoi(a.text)
+	return v
+}
+
+type commandArgs []commandArg
+
+// get returns the first argument of the given kind.
+func (a commandArgs) get(kind string) (commandArg, bool) {
+	for _, arg := range a {
+		if arg.kind == kind {
+			return arg, true
+		}
+	}
+	return commandArg{}, false
+}
+
+var aircraftCommands = []AircraftCommand{
+	{
+		Syntax:      "*A_fix*/C_appr",
+		Description: `"At _fix_, cleared _appr_ approach."`,
+		Example:     "*AROSLY/CI2L*",
+		Grammar:     "A{fix}/C{appr}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.AtFixCleared(token, callsign, strings.ToUpper(args[0].text), strings.ToUpper(args[1].text))
+		},
+	},
+	{
+		Syntax:      "*CAC*",
+		Description: `"Cancel approach clearance".`,
+		Example:     "*CAC*",
+		Grammar:     "CAC",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.CancelApproachClearance(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*CVS*",
+		Description: `"Climb via the SID"`,
+		Example:     "*CVS*",
+		Grammar:     "CVS",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.ClimbViaSID(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*CSI_appr",
+		Description: `"Cleared straight-in _appr_ approach.`,
+		Example:     "*CSII6*",
+		Grammar:     "CSI{appr}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.ClearedApproach(token, callsign, args[0].text, true)
+		},
+	},
+	{
+		Syntax: "*C_fix*/A_alt*/S_kts",
+		Description: `"Cross _fix_ at _alt_ / _kts_ knots."
+Either one or both of *A* and *S* may be specified.`,
+		Example: "*CCAMRN/A110+*",
+		Grammar: "C{fix}/A{altr}[/S{kts}]|C{fix}/S{kts}[/A{altr}]",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			var ar *AltitudeRestriction
+			if a, ok := args.get("altr"); ok {
+				ar, _ = ParseAltitudeRestriction(a.text)
+				// User input here is 100s of feet, while AltitudeRestriction is feet...
+				ar.Range[0] *= 100
+				ar.Range[1] *= 100
+			}
+			speed := 0
+			if kts, ok := args.get("kts"); ok {
+				speed = kts.int()
+			}
+			return s.CrossFixAt(token, callsign, args[0].text, ar, speed)
+		},
+	},
+	{
+		Syntax:      "*C_appr",
+		Description: `"Cleared _appr_ approach."`,
+		Example:     "*CI2L*",
+		Primary:     true,
+		Grammar:     "C{appr}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.ClearedApproach(token, callsign, args[0].text, false)
+		},
+	},
+	{
+		Syntax:      "*C_alt",
+		Description: `"Climb and maintain _alt_".`,
+		Example:     "*C170*",
+		Primary:     true,
+		Grammar:     "C{alt}",
+		run:         runAssignAltitude(false),
+	},
+	{
+		Syntax:      "*DVS*",
+		Description: `"Descend via the STAR"`,
+		Example:     "*DVS*",
+		Grammar:     "DVS",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.DescendViaSTAR(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*D_fix*/H_hdg",
+		Description: `"Depart _fix_ heading _hdg_".`,
+		Example:     "*DLENDY/H180*",
+		Grammar:     "D{fix}/H{hdg}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.DepartFixHeading(token, callsign, args[0].text, args[1].int())
+		},
+	},
+	{
+		Syntax:      "*D_fix1*/D_fix2",
+		Description: `"Depart _fix1_ direct _fix2_".`,
+		Example:     "*DLENDY/DWAVEY*",
+		Grammar:     "D{fix}/D{fix}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.DepartFixDirect(token, callsign, args[0].text, args[1].text)
+		},
+	},
+	{
+		Syntax:      "*D_alt",
+		Description: `"Descend and maintain _alt_".`,
+		Example:     "*D20*",
+		Primary:     true,
+		Grammar:     "D{alt}",
+		run:         runAssignAltitude(false),
+	},
+	{
+		Syntax:      "*D_fix",
+		Description: `"Proceed direct _fix_".`,
+		Example:     "*DWAVEY*",
+		Primary:     true,
+		Grammar:     "D{fix}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.DirectFix(token, callsign, args[0].text)
+		},
+	},
+	{
+		Syntax:      "*ED*",
+		Description: `"Expedite descent"`,
+		Example:     "*ED*",
+		Grammar:     "ED",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.ExpediteDescent(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*EC*",
+		Description: `"Expedite climb"`,
+		Example:     "*EC*",
+		Grammar:     "EC",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.ExpediteClimb(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*E_appr",
+		Description: `"Expect the _appr_ approach."`,
+		Example:     "*EI2L*",
+		Primary:     true,
+		Grammar:     "E{appr}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.ExpectApproach(token, callsign, args[0].text)
+		},
+	},
+	{
+		Syntax:      "*FC*",
+		Description: `"Contact _ctrl_ on _freq_, where _ctrl_ is the controller who has the track and _freq_ is their frequency."`,
+		Example:     "*FC*",
+		Primary:     true,
+		Grammar:     "FC",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.HandoffControl(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*FC_pos",
+		Description: `"Contact _ctrl_ on _freq_, where _ctrl_ is the controller working position _pos_ and _freq_ is its frequency."`,
+		Example:     "*FC2K*",
+		Primary:     true,
+		Grammar:     "FC{pos}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.ContactController(token, callsign, args[0].text)
+		},
+	},
+	{
+		Syntax: "*GUARD*",
+		Description: `"(Callsign), (facility) on guard." NORDO aircraft occasionally respond
+and return to your frequency.`,
+		Example: "*GUARD*",
+		Primary: true,
+		Grammar: "GUARD",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.CallOnGuard(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*H_hdg",
+		Description: `"Fly heading _hdg_." If no heading is given, "fly present heading".`,
+		Example:     "*H050*, *H*",
+		Primary:     true,
+		Grammar:     "H[{hdg}]",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			if len(args) == 0 {
+				return s.AssignHeading(&HeadingArgs{
+					ControllerToken: token,
+					Callsign:        callsign,
+					Present:         true,
+				})
+			} else {
+				return s.AssignHeading(&HeadingArgs{
+					ControllerToken: token,
+					Callsign:        callsign,
+					Heading:         args[0].int(),
+					Turn:            TurnClosest,
+				})
+			}
+		},
+	},
+	{
+		Syntax:      "*I*",
+		Description: `"Intercept the localizer."`,
+		Example:     "*I*",
+		Grammar:     "I",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.InterceptLocalizer(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*ID*",
+		Description: `"Ident."`,
+		Example:     "*ID*",
+		Grammar:     "ID",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.Ident(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*L_deg*D",
+		Description: `"Turn _deg_ degrees left."`,
+		Example:     "*L10D*",
+		Grammar:     "L{deg}D",
+		run:         runTurnDegrees('L'),
+	},
+	{
+		Syntax:      "*L_hdg",
+		Description: `"Turn left heading _hdg_."`,
+		Example:     "*L130*",
+		Grammar:     "L{hdg}",
+		run:         runTurnHeading(TurnLeft),
+	},
+	{
+		Syntax:      "*REL_min",
+		Description: `Release a departure that is being held for release, optionally after _min_ minutes.`,
+		Example:     "*REL*, *REL3*",
+		Grammar:     "REL[{min}]",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			var delay time.Duration
+			if len(args) > 0 {
+				delay = time.Duration(args[0].int()) * time.Minute
+			}
+			return s.ReleaseDeparture(token, callsign, delay)
+		},
+	},
+	{
+		Syntax:      "*R_deg*D",
+		Description: `"Turn _deg_ degrees right".`,
+		Example:     "*R20D*",
+		Grammar:     "R{deg}D",
+		run:         runTurnDegrees('R'),
+	},
+	{
+		Syntax:      "*R_hdg",
+		Description: `"Turn right heading _hdg_".`,
+		Example:     "*R210*",
+		Grammar:     "R{hdg}",
+		run:         runTurnHeading(TurnRight),
+	},
+	{
+		Syntax:      "*S*",
+		Description: `"Cancel speed restrictions".`,
+		Example:     "*S*",
+		Primary:     true,
+		Grammar:     "S",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.AssignSpeed(token, callsign, 0, false)
+		},
+	},
+	{
+		Syntax:      "*SQ_code",
+		Description: `"Squawk _code_". If no code is given, the aircraft squawks the code assigned in its flight plan.`,
+		Example:     "*SQ*, *SQ4621*",
+		Grammar:     "SQ[{code}]",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			if len(args) > 0 {
+				sq, _ := ParseSquawk(args[0].text)
+				if err := s.SetSquawk(token, callsign, sq); err != nil {
+					return err
+				}
+			}
+			return s.SquawkAssignedCode(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*SMIN*",
+		Description: `"Maintain slowest practical speed".`,
+		Example:     "*SMIN*",
+		Grammar:     "SMIN",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.MaintainSlowestPractical(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*SMAX*",
+		Description: `"Maintain maximum forward speed".`,
+		Example:     "*SMAX*",
+		Grammar:     "SMAX",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.MaintainMaximumForward(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*S_kts",
+		Description: `"Reduce/increase speed to _kts_."`,
+		Example:     "*S210*",
+		Primary:     true,
+		Grammar:     "S{kts}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.AssignSpeed(token, callsign, args[0].int(), false)
+		},
+	},
+	{
+		Syntax:      "*TO*",
+		Description: `"Contact tower"`,
+		Example:     "*TO*",
+		Primary:     true,
+		Grammar:     "TO",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.ContactTower(token, callsign)
+		},
+	},
+	{
+		Syntax:      "*T_deg*L",
+		Description: `"Turn _deg_ degrees left."`,
+		Example:     "*T10L*",
+		Grammar:     "T{deg}L",
+		run:         runTurnDegrees('L'),
+	},
+	{
+		Syntax:      "*T_deg*R",
+		Description: `"Turn _deg_ degrees right".`,
+		Example:     "*T20R*",
+		Grammar:     "T{deg}R",
+		run:         runTurnDegrees('R'),
+	},
+	{
+		Syntax: "*TS_kts",
+		Description: `"After reaching _alt_, reduce/increase speed to _kts_", where _alt_ is a previously-assigned
+altitude. (*TS* = 'then speed')`,
+		Example: "*TS210*",
+		Primary: true,
+		Grammar: "TS{kts}",
+		run: func(s *Sim, token, callsign string, args commandArgs) error {
+			return s.AssignSpeed(token, callsign, args[0].int(), true)
+		},
+	},
+	{
+		Syntax:      "*TC_alt",
+		Description: `"After reaching speed _kts_, climb and maintain _alt_", where _kts_ is a previously-assigned speed.`,
+		Example:     "*TC170*",
+		Primary:     true,
+		Grammar:     "TC{alt}",
+		run:         runAssignAltitude(true),
+	},
+	{
+		Syntax: "*TD_alt",
+		Description: `"Descend and maintain _alt_ after reaching _kts_ knots", where _kts_ is a previously-assigned
+speed. (*TD* = 'then descend')`,
+		Example: "*TD20*",
+		Primary: true,
+		Grammar: "TD{alt}",
+		run:     runAssignAltitude(true),
+	},
+	{
+		Syntax:      "*TA_alt",
+		Description: `"After reaching speed _kts_, climb or descend and maintain _alt_", where _kts_ is a previously-assigned speed.`,
+		Example:     "*TA50*",
+		Grammar:     "TA{alt}",
+		run:         runAssignAltitude(true),
+	},
+	{
+		Syntax:      "*X*",
+		Description: "(Deletes the aircraft.)",
+		Example:     "*X*",
+		Primary:     true,
+		Grammar:     "X",
+	},
+}
+
+func runTurnDegrees(dir byte) func(*Sim, string, string, commandArgs) error {
+	return func(s *Sim, token, callsign string, args commandArgs) error {
+		a := &HeadingArgs{ControllerToken: token, Callsign: callsign}
+		if dir == 'L' {
+			a.LeftDegrees = args[0].int()
+		} else {
+			a.RightDegrees = args[0].int()
+		}
+		return s.AssignHeading(a)
+	}
+}
+
+func runTurnHeading(turn TurnMethod) func(*Sim, string, string, commandArgs) error {
+	return func(s *Sim, token, callsign string, args commandArgs) error {
+		return s.AssignHeading(&HeadingArgs{
+			ControllerToken: token,
+			Callsign:        callsign,
+			Heading:         args[0].int(),
+			Turn:            turn,
+		})
+	}
+}
+
+// runAssignAltitude returns a function that assigns the altitude
+// argument, which is given in hundreds of feet.
+func runAssignAltitude(afterSpeed bool) func(*Sim, string, string, commandArgs) error {
+	return func(s *Sim, token, callsign string, args commandArgs) error {
+		return s.AssignAltitude(token, callsign, 100*args[0].int(), afterSpeed)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Parsing
+
+// CommandParseError reports where a command couldn't be parsed.
+type CommandParseError struct {
+	// Pos is the offset in the command where the problem is.
+	Pos int
+	Msg string
+}
+
+func (e *CommandParseError) Error() string {
+	return fmt.Sprintf("%s at character %d", e.Msg, e.Pos+1)
+}
+
+// grammarMatch holds the state of matching a command against the
+// commands' grammars.
+type grammarMatch struct {
+	cmd string
+	// If partial is set, the command only has to be a prefix of one that
+	// matches the grammar, as when it is still being typed.
+	partial bool
+
+	args commandArgs
+	// last is the argument that a partial command ends in, if any.
+	last *commandArg
+
+	// The furthest position that matching has failed at across all of
+	// the grammars tried, and what was expected there; "" for the end of
+	// the command.
+	failPos  int
+	expected []string
+	invalid  string
+}
+
+func newGrammarMatch(cmd string, partial bool) *grammarMatch {
+	return &grammarMatch{cmd: cmd, partial: partial, failPos: -1}
+}
+
+func (m *grammarMatch) fail(pos int, expected string) {
+	if pos > m.failPos {
+		m.failPos, m.expected, m.invalid = pos, nil, ""
+	}
+	if pos == m.failPos && !slices.Contains(m.expected, expected) {
+		m.expected = append(m.expected, expected)
+	}
+}
+
+func (m *grammarMatch) failInvalid(pos int, msg string) {
+	if pos >= m.failPos {
+		m.fail(pos, "")
+		m.invalid = msg
+	}
+}
+
+// matches reports whether the command matches c's grammar, leaving its
+// arguments in m.args if so.
+func (m *grammarMatch) matches(c *AircraftCommand) bool {
+	for _, g := range strings.Split(c.Grammar, "|") {
+		m.args, m.last = nil, nil
+		if pos, ok := m.match(g, 0); ok {
+			if pos == len(m.cmd) {
+				return true
+			}
+			m.fail(pos, "")
+		}
+	}
+	return false
+}
+
+// match matches the grammar g against the command starting at pos,
+// returning the position after the match.
+func (m *grammarMatch) match(g string, pos int) (int, bool) {
+	for len(g) > 0 {
+		if m.partial && pos == len(m.cmd) {
+			if g[0] == '{' {
+				kind := g[1:strings.IndexByte(g, '}')]
+				m.last = &commandArg{kind: kind, pos: pos}
+			}
+			return pos, true
+		}
+
+		switch g[0] {
+		case '[':
+			end := matchingGrammarBracket(g)
+			n := len(m.args)
+			if p, ok := m.match(g[1:end], pos); ok {
+				pos = p
+			} else {
+				m.args = m.args[:n]
+			}
+			g = g[end+1:]
+
+		case '{':
+			end := strings.IndexByte(g, '}')
+			kind := commandArgKinds[g[1:end]]
+			arg := commandArg{kind: g[1:end], pos: pos}
+			g = g[end+1:]
+
+			n := kind.scan(m.cmd[pos:])
+			if n == 0 {
+				m.fail(pos, kind.name)
+				return pos, false
+			}
+			arg.text = m.cmd[pos : pos+n]
+			m.args = append(m.args, arg)
+			pos += n
+
+			if m.partial && pos == len(m.cmd) {
+				// It may not be complete yet.
+				m.last = &arg
+				return pos, true
+			}
+			if kind.valid != nil && !kind.valid(arg.text) {
+				m.failInvalid(arg.pos, fmt.Sprintf("invalid %s %q", kind.name, arg.text))
+				return pos, false
+			}
+
+		default:
+			if pos == len(m.cmd) || m.cmd[pos] != g[0] {
+				m.fail(pos, strconv.Quote(g[:1]))
+				return pos, false
+			}
+			pos++
+			g = g[1:]
+		}
+	}
+	return pos, true
+}
+
+// matchingGrammarBracket returns the index of the ']' that closes the
+// '[' at the start of g.
+func matchingGrammarBracket(g string) int {
+	depth := 0
+	for i, ch := range g {
+		if ch == '[' {
+			depth++
+		} else if ch == ']' {
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	panic("unbalanced brackets in command grammar " + g)
+}
+
+// err returns an error describing the furthest that matching got.
+func (m *grammarMatch) err() *CommandParseError {
+	pos := m.failPos
+	expected := slices.DeleteFunc(slices.Clone(m.expected), func(e string) bool { return e == "" })
+
+	switch {
+	case pos <= 0:
+		return &CommandParseError{Pos: 0, Msg: fmt.Sprintf("unknown command %q", m.cmd)}
+	case m.invalid != "":
+		return &CommandParseError{Pos: pos, Msg: m.invalid}
+	case len(expected) == 0:
+		return &CommandParseError{Pos: pos, Msg: fmt.Sprintf("unexpected %q", m.cmd[pos:])}
+	case len(expected) > 3 && pos == len(m.cmd):
+		return &CommandParseError{Pos: pos, Msg: "incomplete command"}
+	case len(expected) > 3:
+		return &CommandParseError{Pos: pos, Msg: fmt.Sprintf("unexpected %q", m.cmd[pos:])}
+	default:
+		return &CommandParseError{Pos: pos, Msg: "expected " + strings.Join(expected, " or ")}
+	}
+}
+
+// parseAircraftCommand returns the command that cmd is an instance of
+// and its arguments. If locate is non-nil, it is used to check that the
+// fixes given as arguments exist. Errors are returned as a
+// *CommandParseError.
+func parseAircraftCommand(cmd string, locate func(fix string) bool) (*AircraftCommand, commandArgs, error) {
+	m := newGrammarMatch(cmd, false)
+	for i := range aircraftCommands {
+		c := &aircraftCommands[i]
+		if !m.matches(c) {
+			continue
+		}
+
+		if locate != nil {
+			for _, arg := range m.args {
+				if arg.kind == "fix" && !locate(arg.text) {
+					return nil, nil, &CommandParseError{Pos: arg.pos, Msg: fmt.Sprintf("unknown fix %q", arg.text)}
+				}
+			}
+		}
+		return c, m.args, nil
+	}
+	return nil, nil, m.err()
+}
+
+// lookupAircraftCommand returns the command that cmd is an instance of,
+// or nil if there isn't one.
+func lookupAircraftCommand(cmd string) *AircraftCommand {
+	c, _, _ := parseAircraftCommand(cmd, nil)
+	return c
+}
+
+// aircraftCommandHelp returns the commands that the partially-typed
+// command cmd may be; the one it would currently be interpreted as, if
+// any, is first.
+func aircraftCommandHelp(cmd string) []*AircraftCommand {
+	if cmd == "" {
+		return nil
+	}
+
+	var r []*AircraftCommand
+	if c := lookupAircraftCommand(cmd); c != nil {
+		r = append(r, c)
+	}
+	for i := range aircraftCommands {
+		c := &aircraftCommands[i]
+		if newGrammarMatch(cmd, true).matches(c) && !slices.Contains(r, c) {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Completion
+
+// completeCommandInput returns the completions of the word that ends at
+// the cursor in the given messages pane input, along with the offset
+// where the text they replace starts. The first word is completed with
+// callsigns and fix arguments of aircraft commands are completed with
+// fixes, preferring those on the aircraft's route.
+func completeCommandInput(w *World, input string, cursor int) (int, []string) {
+	if input == "" || input[0] == '/' {
+		return cursor, nil
+	}
+
+	text := input[:cursor]
+	start := strings.LastIndexByte(text, ' ') + 1
+	if start == 0 {
+		if input[0] == '!' || input[0] == '?' {
+			// Instructor edits and trial plans
+			start = 1
+		}
+		var callsigns []string
+		for _, callsign := range SortedMapKeys(w.Aircraft) {
+			if strings.HasPrefix(callsign, text[start:]) {
+				callsigns = append(callsigns, callsign)
+			}
+		}
+		return start, callsigns
+	} else if input[0] == '!' || input[0] == '?' {
+		// Their commands have their own syntax.
+		return cursor, nil
+	}
+
+	callsign, _, _ := strings.Cut(input, " ")
+	ac := w.GetAircraft(callsign, true /*abbreviated*/)
+	for i := range aircraftCommands {
+		m := newGrammarMatch(text[start:], true)
+		if m.matches(&aircraftCommands[i]) && m.last != nil && m.last.kind == "fix" {
+			if fixes := fixCompletions(w, ac, m.last.text); len(fixes) > 0 {
+				return start + m.last.pos, fixes
+			}
+		}
+	}
+	return cursor, nil
+}
+
+// fixCompletions returns the fixes that start with prefix; those on the
+// aircraft's route if there are any, otherwise the scenario's fixes.
+func fixCompletions(w *World, ac *Aircraft, prefix string) []string {
+	var fixes []string
+	if ac != nil {
+		for _, wp := range ac.Nav.Waypoints {
+			if strings.HasPrefix(wp.Fix, prefix) && !strings.HasPrefix(wp.Fix, "_") &&
+				!slices.Contains(fixes, wp.Fix) {
+				fixes = append(fixes, wp.Fix)
+			}
+		}
+	}
+	if len(fixes) == 0 {
+		for _, fix := range SortedMapKeys(w.Fixes) {
+			if strings.HasPrefix(fix, prefix) && !strings.HasPrefix(fix, "_") {
+				fixes = append(fixes, fix)
+			}
+		}
+	}
+	return fixes
+}
+
+// commonPrefix returns the longest string that all of the given ones
+// start with.
+func commonPrefix(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	p := s[0]
+	for _, str := range s[1:] {
+		for !strings.HasPrefix(str, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	return p
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Command reference
+
+// plainCommandText returns the given command reference text with the
+// markup removed and placeholders in angle brackets, e.g. "C<alt>" for
+// "*C_alt".
+func plainCommandText(s string) string {
+	var b strings.Builder
+	placeholder, literal := false, false
+	for _, ch := range s {
+		if literal {
+			b.WriteRune(ch)
+			literal = false
+			continue
+		}
+
+		switch {
+		case ch == '\\':
+			literal = true
+		case ch == '@':
+			b.WriteString(FontAwesomeIconMouse)
+		case ch == '_' && !placeholder:
+			b.WriteRune('<')
+			placeholder = true
+		case ch == '_' || ch == '*' || ch == ' ' || ch == '\n':
+			if placeholder {
+				b.WriteRune('>')
+				placeholder = false
+			}
+			if ch == ' ' || ch == '\n' {
+				b.WriteRune(ch)
+			}
+		default:
+			b.WriteRune(ch)
+		}
+	}
+	if placeholder {
+		b.WriteRune('>')
+	}
+	return b.String()
+}
+
+// matchesCommandSearch reports whether the command reference entry
+// given by the strings matches the search text.
+func matchesCommandSearch(search string, text ...string) bool {
+	search = strings.ToLower(strings.TrimSpace(search))
+	if search == "" {
+		return true
+	}
+	for _, t := range text {
+		if strings.Contains(strings.ToLower(plainCommandText(t)), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// uiDrawCommandHelp shows the syntax and examples of the aircraft
+// commands that the last command in the given scope input may be in a
+// tooltip next to the mouse cursor.
+func uiDrawCommandHelp(input string) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 || strings.HasSuffix(input, " ") {
+		return
+	}
+	cmds := aircraftCommandHelp(fields[len(fields)-1])
+	if len(cmds) == 0 {
+		return
+	}
+
+	imgui.BeginTooltip()
+	flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg |
+		imgui.TableFlagsSizingFixedFit
+	if imgui.BeginTableV("commandhelp", 3, flags, imgui.Vec2{}, 0.) {
+		for i, c := range cmds {
+			if i == 8 {
+				break
+			}
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.Text(plainCommandText(c.Syntax))
+			imgui.TableNextColumn()
+			desc, _ := wrapText(plainCommandText(c.Description), 60, 0, true)
+			imgui.Text(desc)
+			imgui.TableNextColumn()
+			imgui.Text(plainCommandText(c.Example))
+		}
+		imgui.EndTable()
+	}
+	imgui.EndTooltip()
+}
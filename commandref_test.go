@@ -0,0 +1,144 @@
+// commandref_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAircraftCommandExamples(t *testing.T) {
+	// The examples in the command reference must be parsed as the
+	// command they document.
+	for i := range aircraftCommands {
+		c := &aircraftCommands[i]
+		for _, ex := range strings.Split(plainCommandText(c.Example), ",") {
+			ex = strings.TrimSpace(ex)
+			if lc := lookupAircraftCommand(ex); lc != c {
+				t.Errorf("%s: example %q matched %v", c.Syntax, ex, lc)
+			}
+		}
+		if c.run == nil && c.Syntax != "*X*" {
+			t.Errorf("%s: no run function", c.Syntax)
+		}
+	}
+
+	for _, cmd := range []string{"", "Q", "F", "GUARDS", "T"} {
+		if c := lookupAircraftCommand(cmd); c != nil {
+			t.Errorf("%q: unexpectedly matched %s", cmd, c.Syntax)
+		}
+	}
+}
+
+func TestPlainCommandText(t *testing.T) {
+	for _, c := range [][2]string{
+		{"*C_alt", "C<alt>"},
+		{"*C_fix*/A_alt*/S_kts", "C<fix>/A<alt>/S<kts>"},
+		{"*T_deg*L", "T<deg>L"},
+		{`"Fly heading _hdg_." If no heading`, `"Fly heading <hdg>." If no heading`},
+		{`*CAC*`, "CAC"},
+		{`_id_\* @`, "<id>* " + FontAwesomeIconMouse},
+	} {
+		if p := plainCommandText(c[0]); p != c[1] {
+			t.Errorf("%q: got %q, expected %q", c[0], p, c[1])
+		}
+	}
+}
+
+func TestAircraftCommandHelp(t *testing.T) {
+	syntax := func(cmds []*AircraftCommand) []string {
+		var s []string
+		for _, c := range cmds {
+			s = append(s, c.Syntax)
+		}
+		return s
+	}
+
+	if h := syntax(aircraftCommandHelp("C1")); len(h) == 0 || h[0] != "*C_alt" {
+		t.Errorf("C1: expected climb first, got %v", h)
+	}
+	if h := syntax(aircraftCommandHelp("CS")); !strings.Contains(strings.Join(h, " "), "*CSI_appr") {
+		t.Errorf("CS: expected straight-in approach, got %v", h)
+	}
+	if h := syntax(aircraftCommandHelp("T10")); len(h) != 2 || h[0] != "*T_deg*L" || h[1] != "*T_deg*R" {
+		t.Errorf("T10: expected turns by degrees, got %v", h)
+	}
+	if h := aircraftCommandHelp("Q"); len(h) != 0 {
+		t.Errorf("Q: expected no commands, got %v", syntax(h))
+	}
+}
+
+func TestAircraftCommandErrors(t *testing.T) {
+	locate := func(fix string) bool { return fix == "LENDY" || fix == "WAVEY" }
+
+	for _, c := range []struct {
+		cmd string
+		pos int
+		msg string
+	}{
+		{"Q", 0, `unknown command "Q"`},
+		{"H12X", 3, `unexpected "X"`},
+		{"T10X", 3, `expected "L" or "R"`},
+		{"L", 1, "expected degrees or heading"},
+		{"SQ9999", 2, `invalid squawk code "9999"`},
+		{"CCAMRN/X", 7, `expected "A" or "S"`},
+		{"DFOO", 1, `unknown fix "FOO"`},
+		{"DLENDY/DFOO", 8, `unknown fix "FOO"`},
+	} {
+		_, _, err := parseAircraftCommand(c.cmd, locate)
+		if perr, ok := err.(*CommandParseError); !ok {
+			t.Errorf("%s: expected a parse error, got %v", c.cmd, err)
+		} else if perr.Pos != c.pos || perr.Msg != c.msg {
+			t.Errorf("%s: got %q at %d, expected %q at %d", c.cmd, perr.Msg, perr.Pos, c.msg, c.pos)
+		}
+	}
+
+	if _, _, err := parseAircraftCommand("CCAMRN/S210/A110+", locate); err == nil {
+		t.Errorf("expected an unknown fix error")
+	}
+	c, args, err := parseAircraftCommand("CCAMRN/S210/A110+", nil)
+	if err != nil || c.Syntax != "*C_fix*/A_alt*/S_kts" {
+		t.Errorf("unexpected result %v %v", c, err)
+	} else if a, ok := args.get("altr"); !ok || a.text != "110+" || a.pos != 13 {
+		t.Errorf("unexpected altitude restriction %+v", a)
+	}
+}
+
+func TestCompleteCommandInput(t *testing.T) {
+	w := &World{
+		Aircraft: map[string]*Aircraft{
+			"AAL1":   {Callsign: "AAL1", Nav: Nav{Waypoints: []Waypoint{{Fix: "_RWY"}, {Fix: "LENDY"}, {Fix: "LEDDE"}}}},
+			"AAL123": {Callsign: "AAL123"},
+			"JBU2":   {Callsign: "JBU2"},
+		},
+		Fixes: map[string]Point2LL{"WAVEY": {}, "LENDY": {}, "_X": {}},
+	}
+
+	for _, c := range []struct {
+		input       string
+		start       int
+		completions []string
+	}{
+		{"AA", 0, []string{"AAL1", "AAL123"}},
+		{"!J", 1, []string{"JBU2"}},
+		{"AAL1 DLE", 6, []string{"LENDY", "LEDDE"}},
+		{"AAL1 H180 D", 11, []string{"LENDY", "LEDDE"}},
+		{"AAL1 DLENDY/DLEN", 13, []string{"LENDY"}},
+		{"AAL123 CWA", 8, []string{"WAVEY"}},
+		{"AAL1 D2", 7, nil},
+		{"AAL1 H1", 7, nil},
+		{"/AA", 3, nil},
+	} {
+		start, completions := completeCommandInput(w, c.input, len(c.input))
+		if start != c.start || !slices.Equal(completions, c.completions) {
+			t.Errorf("%q: got %d %v, expected %d %v", c.input, start, completions, c.start, c.completions)
+		}
+	}
+
+	if p := commonPrefix([]string{"LENDY", "LEDDE"}); p != "LE" {
+		t.Errorf("got common prefix %q", p)
+	}
+}
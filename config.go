@@ -7,9 +7,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -52,10 +54,53 @@ type GlobalConfigNoSim struct {
 	LastServer            string
 	LastTRACON            string
 	UIFontSize            int
+	// Operating initials used when signing on to a position.
+	ControllerInitials string
+	// Scenarios where all of the training objectives have been
+	// completed, indexed by "TRACON/scenario".
+	CompletedScenarios map[string]bool
+	// Additional fonts to take characters that the regular fonts don't
+	// have from (e.g., for CJK text); see fonts.go.
+	FallbackFonts []string
+	// Characters beyond Latin-1 that have been displayed; they are
+	// included in the font atlas when it's built at startup.
+	FontCharacters string
+	// Overall scale factor for text and the user interface; zero is
+	// treated as 1.
+	UIScale float32
+	// See accessibility.go.
+	Accessibility AccessibilityConfig
+	// Rendering backend: "gl2" to always use OpenGL 2.1; otherwise, OpenGL
+	// 3.3 is used if available.
+	Renderer string
+
+	Theme       Theme
+	SavedThemes map[string]*Theme
+	// When to switch to night brightness settings and themes; see
+	// daynight.go.
+	DayNight DayNightConfig
+
+	KeyBindings KeyBindings
+
+	HardwareKeyboard HardwareKeyboardConfig
+	Controllers      ControllerConfig
+	// If set, primary button drags on the scope pan and zoom it, as
+	// suits touchscreens and pens; see touch.go.
+	TouchInput bool
+
+	RadioCongestion RadioCongestionConfig
+
+	Screenshots ScreenshotConfig
+	Video       VideoConfig
 
 	Audio AudioEngine
 
 	DisplayRoot *DisplayNode
+	// Panes that have been moved to their own windows.
+	PaneWindows []*PaneWindow
+
+	Layouts      map[string]*DisplayLayout
+	ActiveLayout string
 
 	AskedDiscordOptIn        bool
 	InhibitDiscordActivity   AtomicBool
@@ -63,6 +108,21 @@ type GlobalConfigNoSim struct {
 
 	Callsign string
 
+	// Per-subsystem log levels; subsystems that aren't present use the
+	// level given by the -loglevel command-line option.
+	LogLevels map[string]string
+
+	Profiles      map[string]*ConfigProfile
+	ActiveProfile string
+	Sync          ConfigSync
+
+	// URL of the index of scenario packs that are available to download;
+	// see scenariopacks.go.
+	ScenarioPackIndexURL string
+	// Public keys of signed scenario packs that the user has chosen to
+	// trust, indexed by pack name.
+	TrustedScenarioPackKeys map[string]string
+
 	highlightedLocation        Point2LL
 	highlightedLocationEndTime time.Time
 }
@@ -71,20 +131,62 @@ type GlobalConfigSim struct {
 	Sim *Sim
 }
 
-func configFilePath() string {
-	dir, err := os.UserConfigDir()
+// portableMarkerFile is the name of a file that, if present next to the
+// vice executable, enables portable mode.
+const portableMarkerFile = "vice-portable.txt"
+
+// portableMode reports whether vice should keep its config, logs, and
+// other files next to the executable rather than in the user's config
+// directory (e.g., so that it can be run from a USB stick).
+func portableMode() bool {
+	if *portable {
+		return true
+	}
+	exe, err := os.Executable()
 	if err != nil {
-		lg.Errorf("Unable to find user config dir: %v", err)
-		dir = "."
+		return false
 	}
+	_, err = os.Stat(filepath.Join(filepath.Dir(exe), portableMarkerFile))
+	return err == nil
+}
 
-	dir = path.Join(dir, "Vice")
-	err = os.MkdirAll(dir, 0o700)
-	if err != nil {
+// configDirectory returns the directory that holds vice's config file,
+// logs, plugins, and other per-user files, creating it if necessary.
+func configDirectory() string {
+	var dir string
+	if *configDir != "" {
+		dir = *configDir
+	} else if portableMode() {
+		exe, err := os.Executable()
+		if err != nil {
+			lg.Errorf("Unable to find executable path: %v", err)
+			exe = "."
+		}
+		dir = filepath.Join(filepath.Dir(exe), "config")
+	} else {
+		var err error
+		if dir, err = os.UserConfigDir(); err != nil {
+			lg.Errorf("Unable to find user config dir: %v", err)
+			dir = "."
+		}
+		dir = path.Join(dir, "Vice")
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
 		lg.Errorf("%s: unable to make directory for config file: %v", dir, err)
 	}
+	return dir
+}
+
+func configFilePath() string {
+	return path.Join(configDirectory(), "config.json")
+}
 
-	return path.Join(dir, "config.json")
+// configPreviousPath returns the filename of the previous generation of
+// the config file, which is kept so that it can be restored if the config
+// file is corrupt.
+func configPreviousPath() string {
+	return configFilePath() + ".prev"
 }
 
 func (gc *GlobalConfig) Encode(w io.Writer) error {
@@ -94,14 +196,23 @@ func (gc *GlobalConfig) Encode(w io.Writer) error {
 }
 
 func (c *GlobalConfig) Save() error {
-	lg.Infof("Saving config to: %s", configFilePath())
-	f, err := os.Create(configFilePath())
-	if err != nil {
-		return err
+	fn := configFilePath()
+	lg.Infof("Saving config to: %s", fn)
+
+	// Keep the current config file as the previous generation, unless it
+	// is itself corrupt, in which case the existing previous generation
+	// is the one worth keeping.
+	if prev, err := os.ReadFile(fn); err == nil && json.Valid(prev) {
+		err := writeFileAtomically(configPreviousPath(), func(w io.Writer) error {
+			_, err := w.Write(prev)
+			return err
+		})
+		if err != nil {
+			lg.Warnf("%s: unable to back up config: %v", configPreviousPath(), err)
+		}
 	}
-	defer f.Close()
 
-	return c.Encode(f)
+	return writeFileAtomically(fn, c.Encode)
 }
 
 func (gc *GlobalConfig) SaveIfChanged(renderer Renderer, platform Platform, w *World, saveSim bool) bool {
@@ -148,76 +259,260 @@ func SetDefaultConfig() {
 	globalConfig = &GlobalConfig{}
 
 	globalConfig.Audio.SetDefaults()
+	globalConfig.RadioCongestion.BlockProbability = 0.5
 	globalConfig.Version = CurrentConfigVersion
 	globalConfig.WhatsNewIndex = len(whatsNew)
 	globalConfig.InitialWindowPosition = [2]int{100, 100}
 	globalConfig.NotifiedNewCommandSyntax = true // don't warn for new installs
 }
 
+// ConfigMigration describes the changes needed to bring a config file up
+// to the given version. Raw, if non-nil, is applied to the top-level JSON
+// object before it is decoded, which allows carrying over fields that
+// have since been renamed or restructured in GlobalConfig. Upgrade, if
+// non-nil, is applied to the decoded GlobalConfig. (Changes to Panes'
+// settings are handled separately via the PaneUpgrader interface.)
+type ConfigMigration struct {
+	Version int
+	Raw     func(m map[string]json.RawMessage) error
+	Upgrade func(gc *GlobalConfig)
+}
+
+// configMigrations must be sorted by Version.
+var configMigrations = []ConfigMigration{
+	{
+		Version: 1,
+		Upgrade: func(gc *GlobalConfig) {
+			// Force upgrade via upcoming Activate() call...
+			gc.DisplayRoot = nil
+		},
+	},
+	{
+		Version: 5,
+		Upgrade: func(gc *GlobalConfig) { gc.Callsign = "" },
+	},
+	{
+		Version: 15,
+		Upgrade: func(gc *GlobalConfig) {
+			if gc.Audio.AudioEnabled {
+				for i := 0; i < AudioNumTypes; i++ {
+					gc.Audio.EffectEnabled[i] = true
+				}
+			}
+		},
+	},
+}
+
+// configBackupPath returns the filename used for a backup of the config
+// file written by the given config version.
+func configBackupPath(version int) string {
+	return fmt.Sprintf("%s.v%d.bak", configFilePath(), version)
+}
+
+// backupConfig saves a copy of the given config file contents so that
+// they aren't lost when the config file is next written.
+func backupConfig(config []byte, fn string) {
+	if err := os.WriteFile(fn, config, 0o600); err != nil {
+		lg.Errorf("%s: unable to back up config: %v", fn, err)
+	} else {
+		lg.Infof("Backed up config to %s", fn)
+	}
+}
+
+// configLoadError records why the config file couldn't be loaded at
+// startup when there's a previous generation of it that may be restored;
+// see recoverCorruptConfig.
+var configLoadError error
+
+func configCorrupt(config []byte, fn string, err error) {
+	backupConfig(config, fn+".corrupt")
+	if _, serr := os.Stat(configPreviousPath()); serr == nil {
+		configLoadError = err
+		lg.Errorf("%s: configuration file is corrupt: %v", fn, err)
+	} else {
+		ShowErrorDialog("Configuration file is corrupt: %v", err)
+	}
+}
+
+// migrateConfigJSON applies the raw migrations for versions after the
+// given one to the config file contents.
+func migrateConfigJSON(config []byte, version int) ([]byte, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(config, &m); err != nil {
+		return nil, err
+	}
+
+	migrated := false
+	for _, mig := range configMigrations {
+		if version < mig.Version && mig.Raw != nil {
+			if err := mig.Raw(m); err != nil {
+				return nil, fmt.Errorf("migration to version %d: %w", mig.Version, err)
+			}
+			migrated = true
+		}
+	}
+	if !migrated {
+		return config, nil
+	}
+	return json.Marshal(m)
+}
+
 func LoadOrMakeDefaultConfig() {
 	fn := configFilePath()
 	lg.Infof("Loading config from: %s", fn)
 
 	SetDefaultConfig()
 	if config, err := os.ReadFile(fn); err == nil {
-		r := bytes.NewReader(config)
-		d := json.NewDecoder(r)
-
-		globalConfig = &GlobalConfig{}
-		if err := d.Decode(&globalConfig.GlobalConfigNoSim); err != nil {
-			SetDefaultConfig()
-			ShowErrorDialog("Configuration file is corrupt: %v", err)
-		}
+		loadConfigFile(config, fn)
+	}
+	finishLoadingConfig()
+}
 
-		if globalConfig.Version < 1 {
-			// Force upgrade via upcoming Activate() call...
-			globalConfig.DisplayRoot = nil
-		}
-		if globalConfig.Version < 5 {
-			globalConfig.Callsign = ""
-		}
-		if globalConfig.Version < 15 && globalConfig.Audio.AudioEnabled {
-			for i := 0; i < AudioNumTypes; i++ {
-				globalConfig.Audio.EffectEnabled[i] = true
-			}
+func loadConfigFile(config []byte, fn string) {
+	var header struct{ Version int }
+	if err := json.Unmarshal(config, &header); err != nil {
+		configCorrupt(config, fn, err)
+		config = nil
+	} else if header.Version > CurrentConfigVersion {
+		// The config was written by a newer version of vice. Save a
+		// copy of it so that its settings can be recovered by hand
+		// (nothing restores it automatically) and roll back to the
+		// config that was backed up before it was upgraded, if there
+		// is one.
+		backupConfig(config, configBackupPath(header.Version))
+		if b, err := os.ReadFile(configBackupPath(CurrentConfigVersion)); err == nil {
+			lg.Infof("Restoring config from %s", configBackupPath(CurrentConfigVersion))
+			config = b
+		} else {
+			lg.Warnf("Config file version %d is newer than %d; some settings may be lost",
+				header.Version, CurrentConfigVersion)
 		}
-
-		if globalConfig.Version < CurrentConfigVersion {
-			if globalConfig.DisplayRoot != nil {
-				globalConfig.DisplayRoot.VisitPanes(func(p Pane) {
-					if up, ok := p.(PaneUpgrader); ok {
-						up.Upgrade(globalConfig.Version, CurrentConfigVersion)
-					}
-				})
-			}
+	} else if header.Version < CurrentConfigVersion {
+		backupConfig(config, configBackupPath(header.Version))
+		var err error
+		if config, err = migrateConfigJSON(config, header.Version); err != nil {
+			ShowErrorDialog("Unable to upgrade configuration file: %v", err)
+			config = nil
 		}
+	}
 
-		if globalConfig.Version == CurrentConfigVersion {
-			// Go ahead and deserialize the Sim
-			r.Seek(0, io.SeekStart)
-			if err := d.Decode(&globalConfig.GlobalConfigSim); err != nil {
-				ShowErrorDialog("Configuration file is corrupt: %v", err)
-			}
-		}
+	if config != nil {
+		loadConfig(config, fn)
 	}
+}
 
+// finishLoadingConfig fills in defaults for settings that weren't in the
+// config file and applies the ones that take effect immediately.
+func finishLoadingConfig() {
 	if globalConfig.UIFontSize == 0 {
 		globalConfig.UIFontSize = 16
 	}
+	if globalConfig.Theme.Name == "" {
+		globalConfig.Theme.Name = "Default"
+	}
+	for subsystem, level := range globalConfig.LogLevels {
+		if err := SetLogLevel(subsystem, level); err != nil {
+			lg.Warnf("%s: %v", subsystem, err)
+		}
+	}
 	globalConfig.Version = CurrentConfigVersion
 
-	if err := globalConfig.Audio.Activate(); err != nil {
-		lg.Errorf("Audio: %v", err)
+	imgui.LoadIniSettingsFromMemory(globalConfig.ImGuiSettings)
+}
+
+// recoverCorruptConfig is called at startup after the config file has
+// been loaded and before the window is created, since the window and
+// renderer settings come from the config. If the config file was
+// corrupt, the previous generation of it is restored rather than
+// starting out with the default configuration; since there's no window
+// yet to ask the user first, a dialog is shown once there is one that
+// explains what happened.
+func recoverCorruptConfig() {
+	if configLoadError == nil {
+		return
 	}
+	loadErr := configLoadError
+	configLoadError = nil
 
-	imgui.LoadIniSettingsFromMemory(globalConfig.ImGuiSettings)
+	prev := configPreviousPath()
+	fi, err := os.Stat(prev)
+	if err != nil {
+		ShowErrorDialog("Configuration file is corrupt: %v", loadErr)
+		return
+	}
+	config, err := os.ReadFile(prev)
+	if err != nil {
+		ShowErrorDialog("Configuration file is corrupt: %v\n%s: %v", loadErr, prev, err)
+		return
+	}
+
+	lg.Infof("Restoring config from %s", prev)
+	SetDefaultConfig()
+	loadConfigFile(config, prev)
+	finishLoadingConfig()
+
+	if configLoadError != nil {
+		ShowErrorDialog("The configuration file and its backup are both corrupt; the default "+
+			"configuration will be used.\n\n%v\n%v", loadErr, configLoadError)
+		configLoadError = nil
+		return
+	}
+
+	msg, _ := wrapText(fmt.Sprintf("The configuration file was corrupt (%v), so the backup of it that "+
+		"was saved at %s has been restored. The corrupt file was saved as %s.", loadErr,
+		fi.ModTime().Local().Format(time.DateTime), configFilePath()+".corrupt"), 80, 0, true)
+	uiShowModalDialog(NewModalDialogBox(&ErrorModalClient{message: msg}), true)
+}
+
+func loadConfig(config []byte, fn string) {
+	r := bytes.NewReader(config)
+	d := json.NewDecoder(r)
+
+	globalConfig = &GlobalConfig{}
+	if err := d.Decode(&globalConfig.GlobalConfigNoSim); err != nil {
+		SetDefaultConfig()
+		configCorrupt(config, fn, err)
+		return
+	}
+
+	for _, mig := range configMigrations {
+		if globalConfig.Version < mig.Version && mig.Upgrade != nil {
+			mig.Upgrade(globalConfig)
+		}
+	}
+
+	if globalConfig.Version < CurrentConfigVersion {
+		upgrade := func(p Pane) {
+			if up, ok := p.(PaneUpgrader); ok {
+				up.Upgrade(globalConfig.Version, CurrentConfigVersion)
+			}
+		}
+		globalConfig.VisitAllPanes(upgrade)
+		for _, p := range globalConfig.Profiles {
+			p.VisitPanes(upgrade)
+		}
+		for _, l := range globalConfig.Layouts {
+			l.DisplayRoot.VisitPanes(upgrade)
+			for _, pw := range l.PaneWindows {
+				pw.DisplayRoot.VisitPanes(upgrade)
+			}
+		}
+	}
+
+	if globalConfig.Version == CurrentConfigVersion {
+		// Go ahead and deserialize the Sim
+		r.Seek(0, io.SeekStart)
+		if err := d.Decode(&globalConfig.GlobalConfigSim); err != nil {
+			ShowErrorDialog("Configuration file is corrupt: %v", err)
+		}
+	}
 }
 
 func (gc *GlobalConfig) Activate(w *World, r Renderer, eventStream *EventStream) {
 	// Upgrade old ones without a MessagesPane
 	if gc.DisplayRoot != nil {
 		haveMessages := false
-		gc.DisplayRoot.VisitPanes(func(p Pane) {
+		gc.VisitAllPanes(func(p Pane) {
 			if _, ok := p.(*MessagesPane); ok {
 				haveMessages = true
 			}
@@ -243,36 +538,42 @@ func (gc *GlobalConfig) Activate(w *World, r Renderer, eventStream *EventStream)
 	}
 
 	if gc.DisplayRoot == nil {
-		stars := NewSTARSPane(w)
-		messages := NewMessagesPane()
-
-		fsp := NewFlightStripPane()
-		fsp.AutoAddDepartures = true
-		fsp.AutoAddTracked = true
-		fsp.AutoAddAcceptedHandoffs = true
-		fsp.AutoRemoveDropped = true
-		fsp.AutoRemoveHandoffs = true
-
-		gc.DisplayRoot = &DisplayNode{
-			SplitLine: SplitLine{
-				Pos:  0.8,
-				Axis: SplitAxisX,
-			},
-			Children: [2]*DisplayNode{
-				&DisplayNode{
-					SplitLine: SplitLine{
-						Pos:  0.075,
-						Axis: SplitAxisY,
-					},
-					Children: [2]*DisplayNode{
-						&DisplayNode{Pane: messages},
-						&DisplayNode{Pane: stars},
-					},
+		gc.DisplayRoot = makeDefaultDisplayRoot(w)
+	}
+
+	gc.VisitAllPanes(func(p Pane) { p.Activate(w, r, eventStream) })
+}
+
+// makeDefaultDisplayRoot returns the standard display layout: the STARS
+// scope with the messages pane above it and the flight strips to the right.
+func makeDefaultDisplayRoot(w *World) *DisplayNode {
+	stars := NewSTARSPane(w)
+	messages := NewMessagesPane()
+
+	fsp := NewFlightStripPane()
+	fsp.AutoAddDepartures = true
+	fsp.AutoAddTracked = true
+	fsp.AutoAddAcceptedHandoffs = true
+	fsp.AutoRemoveDropped = true
+	fsp.AutoRemoveHandoffs = true
+
+	return &DisplayNode{
+		SplitLine: SplitLine{
+			Pos:  0.8,
+			Axis: SplitAxisX,
+		},
+		Children: [2]*DisplayNode{
+			&DisplayNode{
+				SplitLine: SplitLine{
+					Pos:  0.075,
+					Axis: SplitAxisY,
+				},
+				Children: [2]*DisplayNode{
+					&DisplayNode{Pane: messages},
+					&DisplayNode{Pane: stars},
 				},
-				&DisplayNode{Pane: fsp},
 			},
-		}
+			&DisplayNode{Pane: fsp},
+		},
 	}
-
-	gc.DisplayRoot.VisitPanes(func(p Pane) { p.Activate(w, r, eventStream) })
 }
@@ -0,0 +1,204 @@
+// conflictprobe.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Conflict probe: as with ERAM's trial planning, a controller may ask
+// what would happen if an aircraft were given a new altitude, heading, or
+// route before issuing the clearance. The sim predicts the trajectories
+// of the aircraft, with the trial plan applied, and of the other airborne
+// traffic a few minutes ahead (see trajectory.go) and reports any
+// predicted losses of en route separation.
+//
+// In the messages pane, trial plans are entered as "?CALLSIGN PLAN...",
+// where each PLAN is one of the following:
+//
+// ALT <altitude>: climb or descend to the altitude, in hundreds of feet
+// HDG <heading>: fly the heading
+// RTE <fix>...: fly direct to the first fix and then the rest; must be
+// the last item, as it consumes the remaining fixes
+//
+// For example, "?AAL123 ALT 240" probes a climb to FL240.
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EnRouteLateralMinimum = 5 // nm
+	conflictProbeDuration = 5 * time.Minute
+)
+
+type TrialPlan struct {
+	Altitude *float32
+	Heading  *float32
+	Route    []string
+}
+
+type ProbeConflict struct {
+	Callsign string
+	// How long from now until separation is lost.
+	Time time.Duration
+	// The lateral and vertical separation at the closest point of
+	// the conflict.
+	Distance           float32
+	AltitudeDifference float32
+}
+
+func (c ProbeConflict) String() string {
+	return fmt.Sprintf("%s in %s: %.1fnm, %d'", c.Callsign, c.Time.Round(time.Second),
+		c.Distance, int(c.AltitudeDifference))
+}
+
+// ParseTrialPlan parses the space-separated items that follow the
+// callsign in a trial plan.
+func ParseTrialPlan(s string) (TrialPlan, error) {
+	var plan TrialPlan
+	f := strings.Fields(strings.ToUpper(s))
+
+	for i := 0; i < len(f); i++ {
+		switch f[i] {
+		case "ALT", "HDG":
+			if i+1 >= len(f) {
+				return plan, fmt.Errorf("%s: missing value", f[i])
+			}
+			v, err := strconv.Atoi(f[i+1])
+			if f[i] == "ALT" {
+				if err != nil || v <= 0 || v > 600 {
+					return plan, fmt.Errorf("%s %s: invalid altitude", f[i], f[i+1])
+				}
+				alt := float32(100 * v)
+				plan.Altitude = &alt
+			} else {
+				if err != nil || v < 1 || v > 360 {
+					return plan, fmt.Errorf("%s %s: invalid heading", f[i], f[i+1])
+				}
+				hdg := float32(v)
+				plan.Heading = &hdg
+			}
+			i++
+
+		case "RTE":
+			if i+1 >= len(f) {
+				return plan, fmt.Errorf("%s: missing route", f[i])
+			}
+			plan.Route = f[i+1:]
+			i = len(f)
+
+		default:
+			return plan, fmt.Errorf("%s: unknown trial plan item", f[i])
+		}
+	}
+
+	if plan.Altitude == nil && plan.Heading == nil && len(plan.Route) == 0 {
+		return plan, ErrInvalidTrialPlan
+	}
+	if plan.Heading != nil && len(plan.Route) > 0 {
+		return plan, ErrInvalidTrialPlan
+	}
+	return plan, nil
+}
+
+// predictConflicts returns the conflicts between the subject's predicted
+// trajectory and those of the other aircraft, ordered by when they
+// start. Only the most likely positions are considered, since the
+// controller will presumably keep an eye on things.
+func predictConflicts(subject Trajectory, others map[string]Trajectory) []ProbeConflict {
+	var conflicts []ProbeConflict
+	for callsign, traj := range others {
+		if c, ok := subject.Conflict(traj, EnRouteLateralMinimum, VerticalMinimum, 0, subject.Start,
+			subject.End()); ok {
+			conflicts = append(conflicts, ProbeConflict{
+				Callsign:           callsign,
+				Time:               c.Time.Sub(subject.Start),
+				Distance:           c.Distance,
+				AltitudeDifference: c.AltitudeDifference,
+			})
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Time != conflicts[j].Time {
+			return conflicts[i].Time < conflicts[j].Time
+		}
+		return conflicts[i].Callsign < conflicts[j].Callsign
+	})
+	return conflicts
+}
+
+// ProbeTrialPlan predicts the conflicts that would result if the aircraft
+// were given the trial plan; the aircraft itself is unchanged.
+func (s *Sim) ProbeTrialPlan(token, callsign string, plan TrialPlan, result *[]ProbeConflict) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if _, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	}
+	ac, ok := s.World.Aircraft[callsign]
+	if !ok {
+		return ErrNoAircraftForCallsign
+	}
+
+	var route []Waypoint
+	for _, fix := range plan.Route {
+		if p, ok := s.World.Locate(fix); !ok {
+			return ErrInvalidTrialPlan
+		} else {
+			route = append(route, Waypoint{Fix: fix, Location: p})
+		}
+	}
+
+	// Work with a copy of the aircraft so that the sim isn't affected.
+	trial, err := duplicateAircraft(ac, ac.Callsign)
+	if err != nil {
+		return err
+	}
+	nav := &trial.Nav
+	if plan.Altitude != nil {
+		nav.AssignAltitude(*plan.Altitude, false)
+	}
+	if plan.Heading != nil {
+		nav.AssignHeading(*plan.Heading, TurnClosest)
+	}
+	if len(route) > 0 {
+		nav.Waypoints = route
+		nav.Heading = NavHeading{}
+		nav.DeferredHeading = nil
+	}
+	// Heading assignments are deferred by a few seconds of wall clock
+	// time, which won't pass while the trajectory is predicted, so start
+	// following the trial heading right away.
+	if dh := nav.DeferredHeading; dh != nil {
+		nav.Heading = dh.Heading
+		nav.DeferredHeading = nil
+	}
+
+	lg := s.lg.Subsystem("trajectory").With(slog.String("trial_plan", callsign))
+	predict := func(ac *Aircraft) (Trajectory, error) {
+		return ac.PredictTrajectory(s.World, s.SimTime, conflictProbeDuration, time.Second, lg)
+	}
+
+	subject, err := predict(trial)
+	if err != nil {
+		return err
+	}
+	others := make(map[string]Trajectory)
+	for cs, other := range s.World.Aircraft {
+		if cs == callsign || !other.IsAirborne() {
+			continue
+		}
+		if others[cs], err = predict(other); err != nil {
+			return err
+		}
+	}
+
+	*result = predictConflicts(subject, others)
+	return nil
+}
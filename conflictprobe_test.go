@@ -0,0 +1,127 @@
+// conflictprobe_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTrialPlan(t *testing.T) {
+	plan, err := ParseTrialPlan("alt 240 rte camrn deer park")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan.Altitude == nil || *plan.Altitude != 24000 {
+		t.Errorf("expected altitude 24000, got %v", plan.Altitude)
+	}
+	if !slices.Equal(plan.Route, []string{"CAMRN", "DEER", "PARK"}) {
+		t.Errorf("got route %v", plan.Route)
+	}
+
+	for _, bad := range []string{"", "ALT", "ALT 0", "HDG 400", "RTE", "HDG 270 RTE CAMRN", "CLIMB 240"} {
+		if _, err := ParseTrialPlan(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestPredictConflicts(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+
+	// The subject flies east at 6nm/minute at 10,000', climbing at
+	// 1,000'/minute.
+	subject := Trajectory{Start: start, Step: time.Minute}
+	for i := 0; i < 5; i++ {
+		subject.Points = append(subject.Points, TrajectoryPoint{
+			Position: Point2LL{-74 + 0.13*float32(i), 40},
+			Altitude: 10000 + 1000*float32(i),
+		})
+	}
+
+	same := func(dalt float32) Trajectory {
+		traj := Trajectory{Start: start, Step: time.Minute}
+		for _, p := range subject.Points {
+			traj.Points = append(traj.Points, TrajectoryPoint{Position: p.Position, Altitude: p.Altitude + dalt})
+		}
+		return traj
+	}
+	level := func(alt float32) Trajectory {
+		traj := Trajectory{Start: start, Step: time.Minute}
+		for _, p := range subject.Points {
+			traj.Points = append(traj.Points, TrajectoryPoint{Position: p.Position, Altitude: alt})
+		}
+		return traj
+	}
+	short := level(13000)
+	short.Points = short.Points[:2]
+
+	conflicts := predictConflicts(subject, map[string]Trajectory{
+		"AAL1": same(2000),   // always separated vertically
+		"UAL2": level(13000), // the subject climbs through its altitude
+		"DAL3": same(500),    // in conflict from the start
+		"JBU4": short,
+	})
+
+	if len(conflicts) != 2 {
+		t.Fatalf("expected 2 conflicts, got %v", conflicts)
+	}
+	if conflicts[0].Callsign != "DAL3" || conflicts[0].Time != 0 {
+		t.Errorf("expected an immediate conflict with DAL3, got %v", conflicts[0])
+	}
+	if c := conflicts[1]; c.Callsign != "UAL2" || c.Time != 3*time.Minute || c.AltitudeDifference != 0 {
+		t.Errorf("expected a conflict with UAL2 in 3 minutes, got %v", c)
+	}
+}
+
+func TestProbeTrialPlanHeading(t *testing.T) {
+	loadTestDatabase()
+
+	// Both aircraft fly east at 10,000'; DAL2 is 12nm south and 10nm
+	// west of AAL1, so they stay separated unless AAL1 turns south.
+	flying := func(callsign string, p Point2LL) *Aircraft {
+		hdg, alt := float32(90), float32(10000)
+		return &Aircraft{Callsign: callsign, Nav: Nav{
+			FlightState: FlightState{Position: p, Heading: hdg, Altitude: alt, IAS: 250, GS: 250,
+				NmPerLongitude: 46},
+			Perf:     database.AircraftPerformance["B738"],
+			Altitude: NavAltitude{Assigned: &alt},
+			Heading:  NavHeading{Assigned: &hdg},
+		}}
+	}
+	s := &Sim{
+		World: &World{Aircraft: map[string]*Aircraft{
+			"AAL1": flying("AAL1", Point2LL{-74, 40}),
+			"DAL2": flying("DAL2", Point2LL{-74 - 10./46, 40 - 12./60}),
+		}},
+		controllers: map[string]*ServerController{"token": {}},
+		lg:          &Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+	}
+
+	probe := func(p string) []ProbeConflict {
+		plan, err := ParseTrialPlan(p)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", p, err)
+		}
+		var conflicts []ProbeConflict
+		if err := s.ProbeTrialPlan("token", "AAL1", plan, &conflicts); err != nil {
+			t.Fatalf("%s: unexpected error: %v", p, err)
+		}
+		return conflicts
+	}
+
+	if c := probe("HDG 090"); len(c) != 0 {
+		t.Errorf("expected no conflicts when continuing east, got %v", c)
+	}
+	if c := probe("HDG 180"); len(c) != 1 || c[0].Callsign != "DAL2" {
+		t.Errorf("expected a conflict with DAL2 after turning south, got %v", c)
+	}
+	if ac := s.World.Aircraft["AAL1"]; ac.Nav.DeferredHeading != nil || *ac.Nav.Heading.Assigned != 90 {
+		t.Errorf("trial plan modified the aircraft")
+	}
+}
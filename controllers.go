@@ -0,0 +1,294 @@
+// controllers.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Bindings for game controllers, joysticks, and MIDI control surfaces,
+// for users who build physical consoles. Buttons (and MIDI notes) invoke
+// the same actions as keypad keys (see hwkeyboard.go), while axes and
+// MIDI knobs and sliders continuously control settings like the scope
+// range and brightnesses.
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/mmp/imgui-go/v4"
+)
+
+const (
+	ControlInputButton      = "Button"
+	ControlInputAxis        = "Axis"
+	ControlInputMIDINote    = "MIDI note"
+	ControlInputMIDIControl = "MIDI control"
+)
+
+// Actions for continuous inputs; each is given a value between 0 and 1.
+// They're handled by the panes via PaneContext.controls.
+var controlContinuousActions = []string{
+	"Range",
+	"Map brightness",
+	"Full datablock brightness",
+	"Limited datablock brightness",
+	"Position brightness",
+	"Weather brightness",
+	"History brightness",
+}
+
+type ControlBinding struct {
+	// Name of the joystick or MIDI device.
+	Device string
+	Input  string
+	// Button or axis index for joysticks; note or controller number for
+	// MIDI.
+	Index int
+	// For discrete inputs, one of the HardwareKeyAction* actions and
+	// their parameters; for continuous ones, one of
+	// controlContinuousActions.
+	Action   string
+	Function string
+	Text     string
+}
+
+func (b *ControlBinding) Continuous() bool {
+	return b.Input == ControlInputAxis || b.Input == ControlInputMIDIControl
+}
+
+func (b *ControlBinding) Matches(e ControlEvent) bool {
+	return b.Device == e.Device && b.Input == e.Input && b.Index == e.Index
+}
+
+func (b *ControlBinding) InputName() string {
+	return fmt.Sprintf("%s: %s %d", b.Device, b.Input, b.Index)
+}
+
+type ControllerConfig struct {
+	Enabled  bool
+	Bindings []ControlBinding
+}
+
+// ControlEvent represents a button press or a change in the value of a
+// continuous input, normalized to [0,1].
+type ControlEvent struct {
+	Device string
+	Input  string
+	Index  int
+	Value  float32
+}
+
+// controllers holds the state of the attached devices; it isn't saved
+// in the config.
+var controllers struct {
+	opened  bool
+	midi    []*MIDIDevice
+	buttons map[glfw.Joystick][]glfw.Action
+	axes    map[glfw.Joystick][]float32
+}
+
+func openControllers() {
+	for _, d := range controllers.midi {
+		d.Close()
+	}
+	controllers.midi = OpenMIDIDevices()
+	controllers.buttons = make(map[glfw.Joystick][]glfw.Action)
+	controllers.axes = make(map[glfw.Joystick][]float32)
+	controllers.opened = true
+}
+
+// Poll returns the events from all of the attached devices since the
+// last time it was called. It must be called from the main thread.
+func (cc *ControllerConfig) Poll() []ControlEvent {
+	if !cc.Enabled && !controllerEditor.visible {
+		return nil
+	}
+	if !controllers.opened {
+		openControllers()
+	}
+
+	var events []ControlEvent
+	for j := glfw.Joystick1; j <= glfw.JoystickLast; j++ {
+		if !j.Present() {
+			delete(controllers.buttons, j)
+			delete(controllers.axes, j)
+			continue
+		}
+		name := j.GetName()
+
+		buttons := j.GetButtons()
+		prev := controllers.buttons[j]
+		for i, b := range buttons {
+			if b == glfw.Press && (i >= len(prev) || prev[i] != glfw.Press) {
+				events = append(events, ControlEvent{Device: name, Input: ControlInputButton, Index: i, Value: 1})
+			}
+		}
+		controllers.buttons[j] = append(prev[:0], buttons...)
+
+		axes := j.GetAxes()
+		prevAxes := controllers.axes[j]
+		for i, a := range axes {
+			if i >= len(prevAxes) {
+				// Wait for the axis to move before reporting it.
+				continue
+			}
+			// Ignore jitter.
+			if math.Abs(float64(a-prevAxes[i])) > 0.01 {
+				events = append(events, ControlEvent{Device: name, Input: ControlInputAxis, Index: i,
+					Value: (a + 1) / 2})
+			} else {
+				axes[i] = prevAxes[i]
+			}
+		}
+		controllers.axes[j] = append(prevAxes[:0], axes...)
+	}
+
+	for _, d := range controllers.midi {
+	drain:
+		for {
+			select {
+			case m, ok := <-d.Messages():
+				if !ok {
+					break drain
+				}
+				e := ControlEvent{Device: d.Name, Index: m.Number, Value: float32(m.Value) / 127}
+				e.Input = Select(m.Note, ControlInputMIDINote, ControlInputMIDIControl)
+				events = append(events, e)
+			default:
+				break drain
+			}
+		}
+	}
+
+	return events
+}
+
+// Apply updates the keyboard state to reflect the discrete inputs in
+// events and returns the values of the continuous actions, indexed by
+// action name, as well as whether the slew action was invoked.
+func (cc *ControllerConfig) Apply(events []ControlEvent, k *KeyboardState) (map[string]float32, bool) {
+	if !cc.Enabled {
+		return nil, false
+	}
+
+	var values map[string]float32
+	slew := false
+	for _, e := range events {
+		for _, b := range cc.Bindings {
+			if !b.Matches(e) {
+				continue
+			}
+			if b.Continuous() {
+				if values == nil {
+					values = make(map[string]float32)
+				}
+				values[b.Action] = e.Value
+			} else if k != nil {
+				slew = applyKeyAction(b.Action, b.Function, b.Text, k) || slew
+			}
+		}
+	}
+	return values, slew
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Controller bindings UI
+
+var controllerEditor struct {
+	visible  bool
+	learning bool
+	// Initial values of the continuous inputs when learning started, so
+	// that a knob or axis is only learned once it's moved substantially.
+	initial map[string]float32
+}
+
+func uiToggleShowControllerEditor() {
+	controllerEditor.visible = !controllerEditor.visible
+	controllerEditor.learning = false
+}
+
+func uiDrawControllerEditor() {
+	ed := &controllerEditor
+	if !ed.visible {
+		return
+	}
+	cc := &globalConfig.Controllers
+
+	if ed.learning {
+		for _, e := range wm.controlEvents {
+			b := ControlBinding{Device: e.Device, Input: e.Input, Index: e.Index}
+			if b.Continuous() {
+				name := b.InputName()
+				if v, ok := ed.initial[name]; !ok {
+					ed.initial[name] = e.Value
+					continue
+				} else if math.Abs(float64(e.Value-v)) < 0.25 {
+					continue
+				}
+				b.Action = controlContinuousActions[0]
+			} else {
+				b.Action = HardwareKeyActionFunction
+			}
+			cc.Bindings = append(cc.Bindings, b)
+			ed.learning = false
+			break
+		}
+	}
+
+	imgui.BeginV("Controller and MIDI Bindings", &ed.visible, imgui.WindowFlagsAlwaysAutoResize)
+
+	imgui.Checkbox("Enable controller and MIDI bindings", &cc.Enabled)
+
+	for j := glfw.Joystick1; j <= glfw.JoystickLast; j++ {
+		if j.Present() {
+			imgui.Text("Controller: " + j.GetName())
+		}
+	}
+	for _, d := range controllers.midi {
+		imgui.Text("MIDI device: " + d.Name)
+	}
+	if imgui.Button("Rescan MIDI devices") {
+		openControllers()
+	}
+
+	imgui.Separator()
+	for i := 0; i < len(cc.Bindings); i++ {
+		b := &cc.Bindings[i]
+		imgui.PushID(fmt.Sprintf("binding%d", i))
+
+		imgui.Text(b.InputName())
+		imgui.SameLine()
+		if b.Continuous() {
+			imgui.SetNextItemWidth(200)
+			if imgui.BeginComboV("##action", b.Action, imgui.ComboFlagsHeightLarge) {
+				for _, a := range controlContinuousActions {
+					if imgui.SelectableV(a, a == b.Action, 0, imgui.Vec2{}) {
+						b.Action = a
+					}
+				}
+				imgui.EndCombo()
+			}
+		} else {
+			uiDrawKeyActionEditor(&b.Action, &b.Function, &b.Text)
+		}
+
+		imgui.SameLine()
+		if imgui.Button(FontAwesomeIconTrash) {
+			cc.Bindings = DeleteSliceElement(cc.Bindings, i)
+		}
+		imgui.PopID()
+	}
+
+	if ed.learning {
+		imgui.Text("Press a button or move a knob, slider, or axis...")
+		imgui.SameLine()
+		if imgui.Button("Cancel") {
+			ed.learning = false
+		}
+	} else if imgui.Button("Add binding") {
+		ed.learning = true
+		ed.initial = make(map[string]float32)
+	}
+
+	imgui.End()
+}
@@ -0,0 +1,309 @@
+// crashreport.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// When vice crashes, the fatal error dialog offers to upload a
+// CrashReport. Nothing is sent unless the user clicks "Send report"; the
+// report ID that the server returns can then be included in a bug report
+// so that the two can be matched up. The config file is included, minus
+// the settings sync credentials.
+//
+// On the vice server, handleCrashReport receives the reports on the
+// HTTP stats port and saves each one, gzip-compressed, in the
+// crash-reports/ directory, named by its ID.
+
+import (
+	"bytes"
+	"compress/gzip"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+var crashReportURL = fmt.Sprintf("http://%s:%d/crashreport", ViceServerAddress, ViceHTTPServerPort)
+
+// crashReportDir is where the vice server saves the reports it receives.
+var crashReportDir = "crash-reports"
+
+// Only the end of the log is included, which is where the interesting
+// stuff is and keeps the upload a reasonable size.
+const crashReportMaxLogBytes = 2 * 1024 * 1024
+
+// The most that the server will accept, compressed and uncompressed.
+const (
+	crashReportMaxUploadBytes = 4 * 1024 * 1024
+	crashReportMaxBytes       = 16 * 1024 * 1024
+)
+
+type CrashReport struct {
+	ID       string `json:",omitempty"` // assigned by the server
+	Time     time.Time
+	Version  string
+	GOOS     string
+	GOARCH   string
+	Error    string
+	Stack    string
+	TRACON   string          `json:",omitempty"`
+	Scenario string          `json:",omitempty"`
+	Config   json.RawMessage `json:",omitempty"`
+	Log      string
+}
+
+// MakeCrashReport gathers the information for a crash report; w may be
+// nil if the crash happened when no sim was active.
+func MakeCrashReport(err any, stack []byte, w *World) *CrashReport {
+	r := &CrashReport{
+		Time:    time.Now().UTC(),
+		Version: strings.TrimSpace(buildVersion),
+		GOOS:    runtime.GOOS,
+		GOARCH:  runtime.GOARCH,
+		Error:   fmt.Sprintf("%v", err),
+		Stack:   string(stack),
+	}
+	if w != nil {
+		r.TRACON = w.TRACON
+		r.Scenario = w.SimDescription
+	}
+
+	if config, err := os.ReadFile(configFilePath()); err == nil {
+		if config, err := redactConfig(config); err == nil {
+			r.Config = config
+		}
+	}
+
+	if f, err := os.Open(lg.logFile); err == nil {
+		if fi, err := f.Stat(); err == nil && fi.Size() > crashReportMaxLogBytes {
+			_, _ = f.Seek(-crashReportMaxLogBytes, io.SeekEnd)
+		}
+		if b, err := io.ReadAll(f); err == nil {
+			r.Log = string(b)
+		}
+		f.Close()
+	}
+
+	return r
+}
+
+// redactConfig returns the given config file with the settings sync
+// account details removed.
+func redactConfig(config []byte) ([]byte, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(config, &m); err != nil {
+		return nil, err
+	}
+	if sync, ok := m["Sync"]; ok {
+		var cs ConfigSync
+		if err := json.Unmarshal(sync, &cs); err != nil {
+			delete(m, "Sync")
+		} else {
+			b, err := json.Marshal(ConfigSync{Enabled: cs.Enabled, LastSync: cs.LastSync})
+			if err != nil {
+				return nil, err
+			}
+			m["Sync"] = b
+		}
+	}
+	return json.Marshal(m)
+}
+
+// crashReportResponse is returned by the server after it has saved a
+// report.
+type crashReportResponse struct {
+	ID string
+}
+
+// Submit uploads the report as gzip-compressed JSON and returns the ID
+// that the server assigned to it.
+func (r *CrashReport) Submit() (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(r); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, crashReportURL, &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("crash report server returned %s", resp.Status)
+	}
+	var cr crashReportResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
+		return "", err
+	} else if cr.ID == "" {
+		return "", errors.New("crash report server didn't return a report ID")
+	}
+	return cr.ID, nil
+}
+
+// handleCrashReport receives a report uploaded by Submit, saves it, and
+// returns its ID.
+func handleCrashReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "crash reports must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, crashReportMaxUploadBytes)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	var report CrashReport
+	if err := json.NewDecoder(io.LimitReader(body, crashReportMaxBytes)).Decode(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var id [8]byte
+	if _, err := crand.Read(id[:]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report.ID = strings.ToUpper(hex.EncodeToString(id[:]))
+
+	if err := saveCrashReport(&report); err != nil {
+		lg.Errorf("%s: unable to save crash report: %v", report.ID, err)
+		http.Error(w, "unable to save crash report", http.StatusInternalServerError)
+		return
+	}
+	lg.Infof("%s: saved crash report from %s (%s/%s): %s", report.ID, r.RemoteAddr,
+		report.Version, report.GOOS, report.Error)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(crashReportResponse{ID: report.ID}); err != nil {
+		lg.Errorf("%s: %v", report.ID, err)
+	}
+}
+
+func saveCrashReport(r *CrashReport) error {
+	if err := os.MkdirAll(crashReportDir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(crashReportDir, r.ID+".json.gz"))
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+///////////////////////////////////////////////////////////////////////////
+// CrashReportModalClient
+
+type CrashReportModalClient struct {
+	message string
+	report  *CrashReport
+	sending bool
+	result  chan crashReportResult
+	status  string
+}
+
+type crashReportResult struct {
+	id  string
+	err error
+}
+
+func (c *CrashReportModalClient) Title() string { return "Vice Error" }
+func (c *CrashReportModalClient) Opening()      {}
+
+func (c *CrashReportModalClient) Buttons() []ModalDialogButton {
+	send := ModalDialogButton{
+		text:     "Send report",
+		disabled: c.sending || c.report == nil,
+		action: func() bool {
+			c.sending = true
+			c.status = "Sending report..."
+			c.result = make(chan crashReportResult, 1)
+			go func(r *CrashReport) {
+				id, err := r.Submit()
+				c.result <- crashReportResult{id: id, err: err}
+			}(c.report)
+			return false
+		},
+	}
+	// Don't allow closing (and thus exiting) while the upload is in flight.
+	return []ModalDialogButton{send, ModalDialogButton{text: "Close", disabled: c.result != nil}}
+}
+
+func (c *CrashReportModalClient) Draw() int {
+	if c.result != nil {
+		select {
+		case res := <-c.result:
+			if res.err != nil {
+				lg.Errorf("unable to send crash report: %v", res.err)
+				c.status = "Unable to send report: " + res.err.Error()
+				c.sending = false
+			} else {
+				c.status = "Thanks! Please include report ID " + res.id + " if you file a bug."
+				c.report = nil // only send it once
+			}
+			c.result = nil
+		default:
+		}
+	}
+
+	if imgui.BeginTableV("Error", 2, 0, imgui.Vec2{}, 0) {
+		imgui.TableSetupColumn("icon")
+		imgui.TableSetupColumn("text")
+
+		imgui.TableNextRow()
+		imgui.TableNextColumn()
+		imgui.Image(imgui.TextureID(ui.sadTowerTextureID), imgui.Vec2{128, 128})
+
+		imgui.TableNextColumn()
+		text, _ := wrapText(c.message, 80, 0, true)
+		imgui.Text("\n\n" + text)
+		imgui.Text("\n\"Send report\" uploads the end of vice's log file, the error\n" +
+			"details, your vice configuration, and the current scenario\n" +
+			"name to the vice developers.")
+		if c.status != "" {
+			imgui.Text("\n" + c.status)
+		}
+
+		imgui.EndTable()
+	}
+	return -1
+}
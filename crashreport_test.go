@@ -0,0 +1,104 @@
+// crashreport_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactConfig(t *testing.T) {
+	config := `{"Version":30,"Sync":{"Enabled":true,"URL":"https://user:pw@cloud.example.com/vice.json",` +
+		`"Username":"user","Password":"hunter2"},"UIFontSize":16}`
+
+	b, err := redactConfig([]byte(config))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, s := range []string{"hunter2", "user", "cloud.example.com"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("redacted config still contains %q: %s", s, b)
+		}
+	}
+
+	var gc struct {
+		Version    int
+		Sync       ConfigSync
+		UIFontSize int
+	}
+	if err := json.Unmarshal(b, &gc); err != nil {
+		t.Fatalf("unable to decode redacted config: %v", err)
+	}
+	if gc.Version != 30 || gc.UIFontSize != 16 || !gc.Sync.Enabled {
+		t.Errorf("redacted config lost settings: %s", b)
+	}
+
+	if _, err := redactConfig([]byte("{not json")); err == nil {
+		t.Errorf("expected error for invalid config")
+	}
+}
+
+func TestCrashReportSubmit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(handleCrashReport))
+	defer srv.Close()
+
+	defer func(url, dir string) { crashReportURL, crashReportDir = url, dir }(crashReportURL, crashReportDir)
+	crashReportURL = srv.URL + "/crashreport"
+	crashReportDir = filepath.Join(t.TempDir(), "crash-reports")
+
+	report := &CrashReport{Version: "v0.0.0", Error: "runtime error: index out of range", Log: "log"}
+	id, err := report.Submit()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id == "" {
+		t.Fatalf("no report ID returned")
+	}
+
+	f, err := os.Open(filepath.Join(crashReportDir, id+".json.gz"))
+	if err != nil {
+		t.Fatalf("report not saved: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var saved CrashReport
+	if err := json.NewDecoder(zr).Decode(&saved); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved.ID != id || saved.Error != report.Error || saved.Log != report.Log {
+		t.Errorf("saved report %+v doesn't match %+v (ID %s)", saved, report, id)
+	}
+
+	// Each report gets its own ID.
+	if id2, err := report.Submit(); err != nil || id2 == id {
+		t.Errorf("expected a new ID for the second report, got %q (%v)", id2, err)
+	}
+
+	if resp, err := http.Get(crashReportURL); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("GET: got status %s", resp.Status)
+		}
+	}
+	if resp, err := http.Post(crashReportURL, "application/json", strings.NewReader("{")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("malformed report: got status %s", resp.Status)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// daynight.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Day and night display settings: each STARS preference set has separate
+// brightness settings for day and night, and a different theme may be
+// used for each. Switching between them can be done by hand, at given
+// times on the sim clock (so that an evening scenario is dimmed even if
+// it's being run in the afternoon), or by following the operating
+// system's dark mode setting.
+//
+// Only the currently active brightness settings are stored in
+// STARSPreferenceSet.Brightness, which is what all of the drawing code
+// uses; the other ones are kept in AlternateBrightness and the two are
+// swapped when day turns to night or vice versa. Changes made with the
+// BRITE menu thus apply to whichever is active.
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type DayNightSchedule int
+
+const (
+	DayNightManual DayNightSchedule = iota
+	DayNightSimClock
+	DayNightSystem
+)
+
+func (s DayNightSchedule) String() string {
+	return []string{"Manual", "Sim clock", "System dark mode"}[s]
+}
+
+type DayNightConfig struct {
+	Schedule DayNightSchedule
+	// Used with DayNightManual.
+	Night bool
+	// Zulu HHMM times when night starts and ends on the sim clock;
+	// defaults are used if they're empty.
+	NightStart, NightEnd string
+	// Names of the themes to switch to; empty if the theme shouldn't
+	// change.
+	DayTheme, NightTheme string
+}
+
+const (
+	defaultNightStart = "2300"
+	defaultNightEnd   = "1100"
+)
+
+// IsNight returns true if the night display settings should be used.
+func (dn *DayNightConfig) IsNight(w *World) bool {
+	switch dn.Schedule {
+	case DayNightSimClock:
+		now := time.Now()
+		if w != nil {
+			now = w.CurrentTime()
+		}
+		return isNightTime(now, Select(dn.NightStart != "", dn.NightStart, defaultNightStart),
+			Select(dn.NightEnd != "", dn.NightEnd, defaultNightEnd))
+	case DayNightSystem:
+		return systemDarkMode()
+	default:
+		return dn.Night
+	}
+}
+
+// isNightTime returns true if t's zulu time of day is between the given
+// zulu start and end times, which may span midnight.
+func isNightTime(t time.Time, start, end string) bool {
+	sh, sm, err := parseZuluTime(start)
+	if err != nil {
+		return false
+	}
+	eh, em, err := parseZuluTime(end)
+	if err != nil {
+		return false
+	}
+
+	t = t.UTC()
+	m, sm, em := 60*t.Hour()+t.Minute(), 60*sh+sm, 60*eh+em
+	if sm <= em {
+		return m >= sm && m < em
+	}
+	return m >= sm || m < em
+}
+
+// Dimmed returns brightness settings suitable for night, derived from the
+// given daytime settings.
+func (b STARSBrightnessSettings) Dimmed() STARSBrightnessSettings {
+	dim := func(v STARSBrightness) STARSBrightness {
+		return v / 2 / 5 * 5
+	}
+	d := b
+	d.DCB = max(5, dim(b.DCB))
+	d.VideoGroupA = dim(b.VideoGroupA)
+	d.VideoGroupB = dim(b.VideoGroupB)
+	d.FullDatablocks = dim(b.FullDatablocks)
+	d.Lists = dim(b.Lists)
+	d.Positions = dim(b.Positions)
+	d.LimitedDatablocks = dim(b.LimitedDatablocks)
+	d.OtherTracks = dim(b.OtherTracks)
+	d.Lines = dim(b.Lines)
+	d.RangeRings = dim(b.RangeRings)
+	d.Compass = dim(b.Compass)
+	d.BeaconSymbols = dim(b.BeaconSymbols)
+	d.PrimarySymbols = dim(b.PrimarySymbols)
+	d.History = dim(b.History)
+	d.Weather = dim(b.Weather)
+	return d
+}
+
+// UpdateDayNight makes the day or night brightness settings active.
+func (ps *STARSPreferenceSet) UpdateDayNight(night bool) {
+	if ps.NightBrightnessActive != night {
+		ps.Brightness, ps.AlternateBrightness = ps.AlternateBrightness, ps.Brightness
+		ps.NightBrightnessActive = night
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// System dark mode
+
+var darkMode struct {
+	dark      atomic.Bool
+	querying  atomic.Bool
+	lastQuery time.Time
+}
+
+// systemDarkMode returns whether the operating system is in dark mode.
+// The setting is queried in the background every few seconds so that the
+// caller never waits for it.
+func systemDarkMode() bool {
+	if time.Since(darkMode.lastQuery) > 10*time.Second && !darkMode.querying.Load() {
+		darkMode.lastQuery = time.Now()
+		darkMode.querying.Store(true)
+		go func() {
+			defer darkMode.querying.Store(false)
+			darkMode.dark.Store(queryDarkMode())
+		}()
+	}
+	return darkMode.dark.Load()
+}
+
+func queryDarkMode() bool {
+	switch runtime.GOOS {
+	case "darwin":
+		// The key is only present in dark mode.
+		out, err := exec.Command("defaults", "read", "-g", "AppleInterfaceStyle").Output()
+		return err == nil && strings.TrimSpace(string(out)) == "Dark"
+	case "windows":
+		out, err := exec.Command("reg", "query",
+			`HKCU\Software\Microsoft\Windows\CurrentVersion\Themes\Personalize`,
+			"/v", "AppsUseLightTheme").Output()
+		return err == nil && strings.Contains(string(out), "0x0")
+	default:
+		out, err := exec.Command("gsettings", "get", "org.gnome.desktop.interface", "color-scheme").Output()
+		return err == nil && strings.Contains(string(out), "dark")
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Themes and UI
+
+var dayNightState struct {
+	initialized bool
+	night       bool
+}
+
+// uiUpdateDayNight switches to the day or night theme when it becomes
+// day or night. The theme is only changed at the transition so that the
+// user can still pick another one in the meantime.
+func uiUpdateDayNight(w *World) {
+	night := globalConfig.DayNight.IsNight(w)
+	if dayNightState.initialized && night == dayNightState.night {
+		return
+	}
+	dayNightState.initialized, dayNightState.night = true, night
+
+	name := Select(night, globalConfig.DayNight.NightTheme, globalConfig.DayNight.DayTheme)
+	if name == "" || name == globalConfig.Theme.Name {
+		return
+	}
+	if t, ok := lookupTheme(name); ok {
+		globalConfig.Theme = copyTheme(t)
+		globalConfig.Theme.Apply()
+	} else {
+		lg.Warnf("%s: theme not found for day/night switch", name)
+	}
+}
+
+func lookupTheme(name string) (Theme, bool) {
+	if t, ok := globalConfig.SavedThemes[name]; ok {
+		return *t, true
+	}
+	for _, t := range themePresets {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Theme{}, false
+}
+
+func (dn *DayNightConfig) DrawUI() {
+	if imgui.BeginComboV("Day/night switching", dn.Schedule.String(), 0) {
+		for _, s := range []DayNightSchedule{DayNightManual, DayNightSimClock, DayNightSystem} {
+			if imgui.SelectableV(s.String(), s == dn.Schedule, 0, imgui.Vec2{}) {
+				dn.Schedule = s
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	switch dn.Schedule {
+	case DayNightManual:
+		imgui.Checkbox("Night", &dn.Night)
+	case DayNightSimClock:
+		zuluInput := func(label string, t *string, def string) {
+			imgui.InputTextV(label, t, imgui.InputTextFlagsCharsDecimal|imgui.InputTextFlagsCharsNoBlank, nil)
+			if *t == "" {
+				imgui.SameLine()
+				imgui.Text("(default " + def + ")")
+			} else if _, _, err := parseZuluTime(*t); err != nil {
+				imgui.SameLine()
+				imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{1, .5, .5, 1})
+				imgui.Text("Expected zulu HHMM")
+				imgui.PopStyleColor()
+			}
+		}
+		zuluInput("Night starts (zulu)", &dn.NightStart, defaultNightStart)
+		zuluInput("Night ends (zulu)", &dn.NightEnd, defaultNightEnd)
+	}
+
+	themeCombo := func(label string, name *string) {
+		orig := *name
+		if imgui.BeginComboV(label, Select(*name == "", "(unchanged)", *name), 0) {
+			if imgui.SelectableV("(unchanged)", *name == "", 0, imgui.Vec2{}) {
+				*name = ""
+			}
+			for _, t := range themePresets {
+				if imgui.SelectableV(t.Name, t.Name == *name, 0, imgui.Vec2{}) {
+					*name = t.Name
+				}
+			}
+			for _, n := range SortedMapKeys(globalConfig.SavedThemes) {
+				if imgui.SelectableV(n, n == *name, 0, imgui.Vec2{}) {
+					*name = n
+				}
+			}
+			imgui.EndCombo()
+		}
+		if *name != orig {
+			// Apply the newly-selected theme right away.
+			dayNightState.initialized = false
+		}
+	}
+	themeCombo("Day theme", &dn.DayTheme)
+	themeCombo("Night theme", &dn.NightTheme)
+}
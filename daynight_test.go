@@ -0,0 +1,60 @@
+// daynight_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsNightTime(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 1, 1, h, m, 0, 0, time.UTC) }
+
+	for _, test := range []struct {
+		t          time.Time
+		start, end string
+		night      bool
+	}{
+		{at(23, 30), "2300", "1100", true},
+		{at(3, 0), "2300", "1100", true},
+		{at(11, 0), "2300", "1100", false},
+		{at(15, 0), "2300", "1100", false},
+		{at(1, 0), "0000", "0600", true},
+		{at(6, 0), "0000", "0600", false},
+		{at(1, 0), "bogus", "0600", false},
+	} {
+		if n := isNightTime(test.t, test.start, test.end); n != test.night {
+			t.Errorf("%s %s-%s: expected night %v", test.t.Format("1504"), test.start, test.end, test.night)
+		}
+	}
+}
+
+func TestDayNightBrightness(t *testing.T) {
+	var ps STARSPreferenceSet
+	ps.Brightness.DCB = 60
+	ps.Brightness.FullDatablocks = 80
+	ps.Brightness.BackgroundContrast = 10
+	ps.AlternateBrightness = ps.Brightness.Dimmed()
+
+	if ps.AlternateBrightness.FullDatablocks != 40 || ps.AlternateBrightness.BackgroundContrast != 10 {
+		t.Errorf("unexpected dimmed brightness %+v", ps.AlternateBrightness)
+	}
+
+	ps.UpdateDayNight(true)
+	if !ps.NightBrightnessActive || ps.Brightness.FullDatablocks != 40 {
+		t.Errorf("expected night brightness to be active")
+	}
+
+	// Changes made at night should be kept for the next night.
+	ps.Brightness.FullDatablocks = 25
+	ps.UpdateDayNight(false)
+	if ps.NightBrightnessActive || ps.Brightness.FullDatablocks != 80 {
+		t.Errorf("expected day brightness to be active")
+	}
+	ps.UpdateDayNight(true)
+	if ps.Brightness.FullDatablocks != 25 {
+		t.Errorf("night brightness changes were lost")
+	}
+}
@@ -0,0 +1,163 @@
+// deeplink.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// vice registers the vice:// URL scheme so that links of the form
+// vice://join/<server>/<sim> (as generated by viceJoinURL) launch vice
+// with the connect dialog set up to join the given multi-controller sim.
+// Discord only allows http(s) links in buttons, so the "Join" button
+// points to http://vice.pharr.org:6502/join/<server>/<sim>, served by
+// handleJoinLanding on the vice server's HTTP port, which redirects the
+// browser to the vice:// link. Since links can come from anywhere, the
+// user is asked before vice connects to a server other than the one that
+// it's using.
+//
+// How the scheme is registered and how links reach vice depends on the
+// OS:
+//   - Windows: the installer registers the scheme; the link is passed as
+//     the last command-line argument.
+//   - macOS: Info.plist declares the scheme in CFBundleURLTypes; links
+//     are delivered as Apple Events (see deeplink_darwin.m), both at
+//     launch and while vice is running, and are sent to viceURLs.
+//   - Linux: linux/vice.desktop declares "MimeType=x-scheme-handler/vice;"
+//     and runs "vice %u", so the link is on the command line; it must be
+//     installed with xdg-desktop-menu or copied to
+//     ~/.local/share/applications.
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type ViceLink struct {
+	Server string // host:port
+	Sim    string
+}
+
+var ErrNotViceLink = errors.New("not a vice:// link")
+
+// ParseViceURL parses a vice://join/<server>/<sim> link. If the server
+// doesn't specify a port, the default vice server port is used.
+func ParseViceURL(s string) (ViceLink, error) {
+	if !strings.HasPrefix(strings.ToLower(s), "vice://") {
+		return ViceLink{}, ErrNotViceLink
+	}
+
+	// Browsers may add a trailing slash when launching the handler.
+	s = strings.TrimSuffix(s[len("vice://"):], "/")
+	action, rest, _ := strings.Cut(s, "/")
+	if !strings.EqualFold(action, "join") {
+		return ViceLink{}, fmt.Errorf("%s: unknown vice:// action", action)
+	}
+
+	server, sim, ok := strings.Cut(rest, "/")
+	if !ok || server == "" || sim == "" {
+		return ViceLink{}, errors.New("vice://join link must be of the form vice://join/<server>/<sim>")
+	}
+
+	var link ViceLink
+	var err error
+	if link.Server, err = url.PathUnescape(server); err != nil {
+		return ViceLink{}, err
+	}
+	if link.Sim, err = url.PathUnescape(sim); err != nil {
+		return ViceLink{}, err
+	}
+
+	if _, _, err := net.SplitHostPort(link.Server); err != nil {
+		link.Server = net.JoinHostPort(link.Server, fmt.Sprintf("%d", ViceServerPort))
+	}
+
+	return link, nil
+}
+
+// viceJoinURL returns a vice:// URL that other users can open to join the
+// given remote sim.
+func viceJoinURL(server, simName string) string {
+	return "vice://join/" + url.PathEscape(server) + "/" + url.PathEscape(simName)
+}
+
+// viceJoinLandingURL returns an http URL for a page on the vice server
+// that redirects to the corresponding vice:// URL.
+func viceJoinLandingURL(server, simName string) string {
+	return fmt.Sprintf("http://%s:%d/join/", ViceServerAddress, ViceHTTPServerPort) +
+		url.PathEscape(server) + "/" + url.PathEscape(simName)
+}
+
+// handleJoinLanding serves the page for the URLs returned by
+// viceJoinLandingURL.
+func handleJoinLanding(w http.ResponseWriter, r *http.Request) {
+	link, err := ParseViceURL("vice://join/" + strings.TrimPrefix(r.URL.EscapedPath(), "/join/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u := html.EscapeString(viceJoinURL(link.Server, link.Sim))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><meta http-equiv="refresh" content="0; url=%s"><title>Join %s</title></head>
+<body><p>Opening vice... If nothing happens, make sure vice is installed and <a href="%s">click here</a>.</p></body></html>
+`, u, html.EscapeString(link.Sim), u)
+}
+
+// viceURLs receives vice:// links that the OS delivers to vice other than
+// via the command line.
+var viceURLs = make(chan string, 4)
+
+// pendingViceLink returns the next valid link received via viceURLs, if
+// any.
+func pendingViceLink() (ViceLink, bool) {
+	for {
+		select {
+		case u := <-viceURLs:
+			link, err := ParseViceURL(u)
+			if err == nil {
+				return link, true
+			}
+			lg.Warnf("%s: %v", u, err)
+		default:
+			return ViceLink{}, false
+		}
+	}
+}
+
+// findViceLink returns the first vice:// link in the given command-line
+// arguments, if any.
+func findViceLink(args []string) (ViceLink, bool) {
+	for _, arg := range args {
+		if link, err := ParseViceURL(arg); err == nil {
+			return link, true
+		} else if err != ErrNotViceLink {
+			lg.Warnf("%s: %v", arg, err)
+		}
+	}
+	return ViceLink{}, false
+}
+
+// SelectRemoteSim sets up the configuration to join the named sim on the
+// remote server; it returns false if the sim isn't available.
+func (c *NewSimConfiguration) SelectRemoteSim(name string) bool {
+	if remoteServer == nil {
+		return false
+	}
+	rs, ok := remoteServer.runningSims[name]
+	if !ok || len(rs.AvailablePositions) == 0 {
+		return false
+	}
+
+	c.NewSimType = NewSimJoinRemote
+	c.selectedServer = remoteServer
+	c.SelectedRemoteSim = name
+	if _, ok := rs.CoveredPositions[rs.PrimaryController]; !ok {
+		c.SelectedRemoteSimPosition = rs.PrimaryController
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+// deeplink_darwin.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// #cgo LDFLAGS: -framework Foundation
+// void viceInstallURLHandler(void);
+import "C"
+
+// installViceURLHandler registers for the Apple Events that macOS uses to
+// deliver vice:// links. It must be called before GLFW is initialized so
+// that the link that launched vice isn't missed.
+func installViceURLHandler() {
+	C.viceInstallURLHandler()
+}
+
+//export viceOpenURL
+func viceOpenURL(u *C.char) {
+	select {
+	case viceURLs <- C.GoString(u):
+	default:
+		lg.Warnf("%s: dropping vice:// link", C.GoString(u))
+	}
+}
@@ -0,0 +1,29 @@
+// deeplink_darwin.m
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+#import <Foundation/Foundation.h>
+
+#include "_cgo_export.h"
+
+@interface ViceURLHandler : NSObject
+- (void)handleGetURLEvent:(NSAppleEventDescriptor *)event withReplyEvent:(NSAppleEventDescriptor *)reply;
+@end
+
+@implementation ViceURLHandler
+- (void)handleGetURLEvent:(NSAppleEventDescriptor *)event withReplyEvent:(NSAppleEventDescriptor *)reply {
+    NSString *url = [[event paramDescriptorForKeyword:keyDirectObject] stringValue];
+    if (url != nil)
+        viceOpenURL((char *)[url UTF8String]);
+}
+@end
+
+void viceInstallURLHandler(void) {
+    static ViceURLHandler *handler;
+    handler = [[ViceURLHandler alloc] init];
+    [[NSAppleEventManager sharedAppleEventManager]
+        setEventHandler:handler
+            andSelector:@selector(handleGetURLEvent:withReplyEvent:)
+          forEventClass:kInternetEventClass
+             andEventID:kAEGetURL];
+}
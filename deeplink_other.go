@@ -0,0 +1,11 @@
+// deeplink_other.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+//go:build !darwin
+
+package main
+
+// installViceURLHandler is a no-op; on other platforms, vice:// links are
+// passed on the command line.
+func installViceURLHandler() {}
@@ -0,0 +1,73 @@
+// deeplink_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseViceURL(t *testing.T) {
+	for _, test := range []struct {
+		url  string
+		link ViceLink
+		err  bool
+	}{
+		{url: "vice://join/example.com:8001/crazy-owl", link: ViceLink{Server: "example.com:8001", Sim: "crazy-owl"}},
+		{url: "vice://join/example.com/crazy-owl/", link: ViceLink{Server: "example.com:8001", Sim: "crazy-owl"}},
+		{url: "VICE://JOIN/1.2.3.4:9000/my%20sim", link: ViceLink{Server: "1.2.3.4:9000", Sim: "my sim"}},
+		{url: "vice://join/example.com", err: true},
+		{url: "vice://watch/example.com/sim", err: true},
+		{url: "https://example.com", err: true},
+	} {
+		link, err := ParseViceURL(test.url)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected error, got %+v", test.url, link)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", test.url, err)
+		} else if link != test.link {
+			t.Errorf("%s: got %+v, expected %+v", test.url, link, test.link)
+		}
+	}
+
+	// Make sure that we can parse the links we generate.
+	if link, err := ParseViceURL(viceJoinURL("example.com:8001", "a/b sim")); err != nil {
+		t.Errorf("round trip: %v", err)
+	} else if link.Server != "example.com:8001" || link.Sim != "a/b sim" {
+		t.Errorf("round trip: got %+v", link)
+	}
+}
+
+func TestJoinLanding(t *testing.T) {
+	landing := viceJoinLandingURL("example.com:8001", "a/b sim")
+	if u, err := url.Parse(landing); err != nil {
+		t.Fatalf("%s: %v", landing, err)
+	} else if u.Scheme != "http" || u.Port() != fmt.Sprint(ViceHTTPServerPort) {
+		t.Errorf("%s: landing URL must be served by the vice server's HTTP port", landing)
+	}
+
+	req := httptest.NewRequest("GET", landing, nil)
+	rec := httptest.NewRecorder()
+	handleJoinLanding(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: got status %d", landing, rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, viceJoinURL("example.com:8001", "a/b sim")) {
+		t.Errorf("%s: page doesn't link to vice:// URL: %s", landing, body)
+	}
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("http://%s:%d/join/example.com", ViceServerAddress, ViceHTTPServerPort), nil)
+	rec = httptest.NewRecorder()
+	handleJoinLanding(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed join link: got status %d", rec.Code)
+	}
+}
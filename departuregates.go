@@ -0,0 +1,97 @@
+// departuregates.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Departure gates: a facility's adaptation may group departure exit fixes
+// into gates, each of which gives the scratchpads that departures using
+// it start out with and the sector that they are handed off to next,
+// e.g.:
+//
+//	"departure_gates": {
+//	    "NORTH": { "exits": [ "GAYEL", "COATE" ], "scratchpad": "N",
+//	               "secondary_scratchpad": "J", "handoff": "N56" }
+//	}
+//
+// As with STARS automation, this information is filled in when a
+// departure is launched: scratchpads that a departure doesn't specify and
+// that aren't given by the adaptation's "scratchpads" come from its gate,
+// and the controller who has the track can then hand it off to the next
+// sector by entering a handoff without a position.
+
+import (
+	"slices"
+)
+
+type DepartureGate struct {
+	Exits               []string `json:"exits"`
+	Scratchpad          string   `json:"scratchpad"`
+	SecondaryScratchpad string   `json:"secondary_scratchpad"`
+	// Control position that departures are handed off to after the first
+	// human controller.
+	Handoff string `json:"handoff"`
+}
+
+func (fa *STARSFacilityAdaptation) checkDepartureGates(e *ErrorLogger, sg *ScenarioGroup) {
+	gateForExit := make(map[string]string)
+	for _, name := range SortedMapKeys(fa.DepartureGates) {
+		gate := fa.DepartureGates[name]
+		e.Push("Departure gate " + name)
+
+		if len(gate.Exits) == 0 {
+			e.ErrorString("no \"exits\" specified")
+		}
+		for _, exit := range gate.Exits {
+			if other, ok := gateForExit[exit]; ok {
+				e.ErrorString("exit \"%s\" is also in gate \"%s\"", exit, other)
+			} else if _, ok := sg.locate(exit); !ok {
+				e.ErrorString("exit \"%s\" unknown", exit)
+			}
+			gateForExit[exit] = name
+		}
+
+		if err := fa.CheckScratchpad(gate.Scratchpad, false); err != nil {
+			e.ErrorString("\"scratchpad\" \"%s\" isn't allowed", gate.Scratchpad)
+		}
+		if err := fa.CheckScratchpad(gate.SecondaryScratchpad, true); err != nil {
+			e.ErrorString("\"secondary_scratchpad\" \"%s\" isn't allowed", gate.SecondaryScratchpad)
+		}
+		if _, ok := sg.ControlPositions[gate.Handoff]; gate.Handoff != "" && !ok {
+			e.ErrorString("\"handoff\" control position \"%s\" unknown", gate.Handoff)
+		}
+
+		e.Pop()
+	}
+}
+
+// DepartureGate returns the departure gate that includes the given exit,
+// if any.
+func (fa *STARSFacilityAdaptation) DepartureGate(exit string) (DepartureGate, bool) {
+	for _, name := range SortedMapKeys(fa.DepartureGates) {
+		if gate := fa.DepartureGates[name]; slices.Contains(gate.Exits, exit) {
+			return gate, true
+		}
+	}
+	return DepartureGate{}, false
+}
+
+// departureScratchpads returns the primary and secondary scratchpads that
+// the given departure starts out with.
+func (fa *STARSFacilityAdaptation) departureScratchpads(dep *Departure) (string, string) {
+	gate, _ := fa.DepartureGate(dep.Exit)
+
+	sp := dep.Scratchpad
+	if sp == "" {
+		sp = fa.Scratchpads[dep.Exit]
+	}
+	if sp == "" {
+		sp = gate.Scratchpad
+	}
+
+	sp2 := dep.SecondaryScratchpad
+	if sp2 == "" {
+		sp2 = gate.SecondaryScratchpad
+	}
+	return sp, sp2
+}
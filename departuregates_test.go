@@ -0,0 +1,40 @@
+// departuregates_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestDepartureScratchpads(t *testing.T) {
+	fa := STARSFacilityAdaptation{
+		Scratchpads: map[string]string{"WAVEY": "WAV"},
+		DepartureGates: map[string]DepartureGate{
+			"NORTH": {Exits: []string{"GAYEL", "COATE"}, Scratchpad: "N", SecondaryScratchpad: "J", Handoff: "N56"},
+			"SOUTH": {Exits: []string{"WAVEY"}, SecondaryScratchpad: "S"},
+		},
+	}
+
+	for _, test := range []struct {
+		dep      Departure
+		sp, sp2  string
+		handoff  string
+		haveGate bool
+	}{
+		{dep: Departure{Exit: "GAYEL"}, sp: "N", sp2: "J", handoff: "N56", haveGate: true},
+		{dep: Departure{Exit: "COATE", Scratchpad: "CO", SecondaryScratchpad: "X"}, sp: "CO", sp2: "X", handoff: "N56", haveGate: true},
+		{dep: Departure{Exit: "WAVEY"}, sp: "WAV", sp2: "S", haveGate: true},
+		{dep: Departure{Exit: "DIXIE"}},
+	} {
+		sp, sp2 := fa.departureScratchpads(&test.dep)
+		if sp != test.sp || sp2 != test.sp2 {
+			t.Errorf("%s: expected scratchpads %q/%q, got %q/%q", test.dep.Exit, test.sp, test.sp2, sp, sp2)
+		}
+		gate, ok := fa.DepartureGate(test.dep.Exit)
+		if ok != test.haveGate || gate.Handoff != test.handoff {
+			t.Errorf("%s: expected gate %v with handoff %q, got %v/%q", test.dep.Exit, test.haveGate, test.handoff, ok, gate.Handoff)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// dependentops.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Dependent operations between nearby airports: in busy metroplex
+// airspace, departures from one airport often can't be launched
+// independently of the traffic at another--departure paths cross, or a
+// departure climbs out underneath another airport's final. Scenarios may
+// specify such dependencies and the towers then coordinate releases: a
+// departure subject to one is held on the runway until it's clear. For
+// example:
+//
+//	"dependent_operations": [
+//	    { "departure": "KLGA/13", "after_departure": "KJFK/31L", "seconds": 90,
+//	      "description": "LGA 13 departures cross JFK 31L departures" },
+//	    { "departure": "KTEB/24", "arrival": "KEWR/22L", "miles": 5 }
+//	]
+//
+// With "after_departure", departures wait until the given time has passed
+// since the last departure from the other runway; with "arrival", they
+// wait while an arrival for the other runway is within the given distance
+// of its airport. Dependencies only apply in the direction given; crossing
+// departure paths are usually specified in both directions.
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type DependentOperation struct {
+	Departure      string  `json:"departure"`
+	AfterDeparture string  `json:"after_departure,omitempty"`
+	Seconds        int     `json:"seconds,omitempty"`
+	Arrival        string  `json:"arrival,omitempty"`
+	Miles          float32 `json:"miles,omitempty"`
+	Description    string  `json:"description,omitempty"`
+}
+
+// splitAirportRunway splits an "airport/runway" specifier into its
+// components. Any extra qualifiers on the runway (e.g., "31L.LGA") are
+// removed.
+func splitAirportRunway(s string) (string, string, bool) {
+	ap, rwy, ok := strings.Cut(s, "/")
+	rwy = baseRunway(rwy)
+	return ap, rwy, ok && ap != "" && rwy != ""
+}
+
+func baseRunway(rwy string) string {
+	rwy, _, _ = strings.Cut(rwy, ".")
+	return rwy
+}
+
+func (d *DependentOperation) PostDeserialize(sg *ScenarioGroup, e *ErrorLogger) {
+	checkAirport := func(field, s string) {
+		if ap, _, ok := splitAirportRunway(s); !ok {
+			e.ErrorString("\"%s\": \"%s\" must be of the form \"airport/runway\"", field, s)
+		} else if _, ok := sg.Airports[ap]; !ok {
+			e.ErrorString("\"%s\": airport \"%s\" not found", field, ap)
+		}
+	}
+
+	checkAirport("departure", d.Departure)
+	if d.AfterDeparture == "" && d.Arrival == "" {
+		e.ErrorString("must specify \"after_departure\" and/or \"arrival\"")
+	}
+	if d.AfterDeparture != "" {
+		checkAirport("after_departure", d.AfterDeparture)
+		if d.Seconds <= 0 {
+			e.ErrorString("\"seconds\" must be given with \"after_departure\"")
+		}
+	}
+	if d.Arrival != "" {
+		checkAirport("arrival", d.Arrival)
+		if d.Miles <= 0 {
+			e.ErrorString("\"miles\" must be given with \"arrival\"")
+		}
+	}
+}
+
+// holdReason returns a description of why the given departure, which
+// hasn't started its takeoff roll, must wait, or an empty string if it
+// may go.
+func (s *Sim) holdReason(ac *Aircraft, now time.Time) string {
+	if occupant, ok := s.runwayOccupant(ac.FlightPlan.DepartureAirport, ac.DepartureRunway, now); ok {
+		return "landing traffic " + occupant + " on the runway"
+	}
+
+	for _, d := range s.DependentOperations {
+		ap, rwy, _ := splitAirportRunway(d.Departure)
+		if ap != ac.FlightPlan.DepartureAirport || rwy != baseRunway(ac.DepartureRunway) {
+			continue
+		}
+
+		if d.AfterDeparture != "" {
+			depAirport, depRunway, _ := splitAirportRunway(d.AfterDeparture)
+			if t, ok := s.RunwayDepartureTimes[depAirport+"/"+depRunway]; ok &&
+				now.Sub(t) < time.Duration(d.Seconds)*time.Second {
+				return depAirport + " " + depRunway + " departure"
+			}
+		}
+
+		if d.Arrival != "" {
+			arrAirport, arrRunway, _ := splitAirportRunway(d.Arrival)
+			airport, ok := s.World.Airports[arrAirport]
+			if !ok {
+				continue
+			}
+			for _, arr := range s.World.Aircraft {
+				if arr.FlightPlan == nil || arr.FlightPlan.ArrivalAirport != arrAirport ||
+					arr.Nav.Approach.Assigned == nil || baseRunway(arr.Nav.Approach.Assigned.Runway) != arrRunway {
+					continue
+				}
+				if nmdistance2ll(arr.Position(), airport.Location) < d.Miles {
+					return arrAirport + " " + arrRunway + " arrival " + arr.Callsign
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// departureHeld is called once a second with s.mu held for each departure
+// that has been released; it returns true if the departure must wait for
+// traffic at a nearby airport. Otherwise, if the departure is just
+// starting its takeoff roll, the time is recorded for the dependencies on
+// its runway.
+func (s *Sim) departureHeld(ac *Aircraft, now time.Time) bool {
+	if !ac.IsDeparture() || ac.FlightPlan == nil || ac.DepartureRunway == "" || ac.IAS() > 0 {
+		return false
+	}
+
+	if reason := s.holdReason(ac, now); reason != "" {
+		if s.dependentHolds == nil {
+			s.dependentHolds = make(map[string]string)
+		}
+		if s.dependentHolds[ac.Callsign] != reason {
+			s.dependentHolds[ac.Callsign] = reason
+			s.lg.Info("departure held for dependent operation", slog.String("callsign", ac.Callsign),
+				slog.String("reason", reason))
+			s.PostEvent(Event{
+				Type:    StatusMessageEvent,
+				Message: fmt.Sprintf("%s tower holding %s for %s", ac.FlightPlan.DepartureAirport, ac.Callsign, reason),
+			})
+		}
+		return true
+	}
+
+	delete(s.dependentHolds, ac.Callsign)
+	if s.RunwayDepartureTimes == nil {
+		s.RunwayDepartureTimes = make(map[string]time.Time)
+	}
+	s.RunwayDepartureTimes[ac.FlightPlan.DepartureAirport+"/"+baseRunway(ac.DepartureRunway)] = now
+	return false
+}
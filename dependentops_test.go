@@ -0,0 +1,77 @@
+// dependentops_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitAirportRunway(t *testing.T) {
+	for _, test := range []struct {
+		s, ap, rwy string
+		ok         bool
+	}{
+		{"KJFK/31L", "KJFK", "31L", true},
+		{"KLGA/13.LGA", "KLGA", "13", true},
+		{"KJFK", "KJFK", "", false},
+		{"/31L", "", "31L", false},
+	} {
+		ap, rwy, ok := splitAirportRunway(test.s)
+		if ap != test.ap || rwy != test.rwy || ok != test.ok {
+			t.Errorf("%s: got %q %q %v, expected %q %q %v", test.s, ap, rwy, ok, test.ap, test.rwy, test.ok)
+		}
+	}
+}
+
+func TestDependentOperationHolds(t *testing.T) {
+	now := time.Now()
+	ewr := Point2LL{-74.1687, 40.6925}
+
+	dep := &Aircraft{
+		Callsign:        "AAL1",
+		FlightPlan:      &FlightPlan{DepartureAirport: "KLGA"},
+		DepartureRunway: "13",
+	}
+	dep.Nav.FlightState.IsDeparture = true
+
+	arr := &Aircraft{
+		Callsign:   "UAL2",
+		FlightPlan: &FlightPlan{ArrivalAirport: "KEWR"},
+	}
+	arr.Nav.Approach.Assigned = &Approach{Runway: "22L"}
+	arr.Nav.FlightState.Position = Point2LL{ewr[0], ewr[1] + 0.1} // 6nm north
+
+	s := &Sim{
+		World: &World{
+			Aircraft: map[string]*Aircraft{"AAL1": dep, "UAL2": arr},
+			Airports: map[string]*Airport{"KEWR": {Location: ewr}},
+		},
+		DependentOperations: []DependentOperation{
+			{Departure: "KLGA/13", AfterDeparture: "KJFK/31L", Seconds: 90},
+			{Departure: "KLGA/13", Arrival: "KEWR/22L", Miles: 5},
+		},
+		RunwayDepartureTimes: map[string]time.Time{"KJFK/31L": now.Add(-time.Minute)},
+	}
+
+	if r := s.holdReason(dep, now); !strings.Contains(r, "KJFK 31L") {
+		t.Errorf("expected hold for the JFK departure, got %q", r)
+	}
+	if r := s.holdReason(dep, now.Add(time.Minute)); r != "" {
+		t.Errorf("expected no hold after the JFK departure, got %q", r)
+	}
+
+	arr.Nav.FlightState.Position = Point2LL{ewr[0], ewr[1] + 0.05} // 3nm north
+	if r := s.holdReason(dep, now.Add(time.Minute)); !strings.Contains(r, "UAL2") {
+		t.Errorf("expected hold for the EWR arrival, got %q", r)
+	}
+
+	// Departures from other runways aren't affected.
+	dep.DepartureRunway = "4"
+	if r := s.holdReason(dep, now); r != "" {
+		t.Errorf("expected no hold for runway 4, got %q", r)
+	}
+}
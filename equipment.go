@@ -0,0 +1,117 @@
+// equipment.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Aircraft equipment: the FAA equipment suffix in the flight plan's
+// aircraft type (e.g., the "L" in "B738/L") describes the aircraft's
+// navigation capabilities, and pilots refuse procedures that they aren't
+// equipped to fly: RNAV SIDs and STARs require RNAV, RNAV (GPS)
+// approaches require GNSS, and ILS approaches require an ILS receiver.
+// The suffixes don't say anything about ILS receivers; we assume that
+// aircraft without DME are minimally equipped and don't have one
+// either. Aircraft types without a suffix are assumed to be fully
+// equipped.
+
+// Probability that an airliner has RNAV but not GNSS.
+const nonGNSSProbability = 0.1
+
+type Equipment struct {
+	RNAV bool
+	GNSS bool
+	ILS  bool
+}
+
+var equipmentSuffixes = map[string]Equipment{
+	// No DME
+	"X": {}, "T": {}, "U": {},
+	// DME or TACAN
+	"D": {ILS: true}, "B": {ILS: true}, "A": {ILS: true},
+	"M": {ILS: true}, "N": {ILS: true}, "P": {ILS: true},
+	// RVSM without RNAV
+	"W": {ILS: true},
+	// RNAV without GNSS
+	"Y": {RNAV: true, ILS: true}, "C": {RNAV: true, ILS: true}, "I": {RNAV: true, ILS: true},
+	"Z": {RNAV: true, ILS: true},
+	// GNSS
+	"V": {RNAV: true, GNSS: true, ILS: true}, "S": {RNAV: true, GNSS: true, ILS: true},
+	"G": {RNAV: true, GNSS: true, ILS: true}, "L": {RNAV: true, GNSS: true, ILS: true},
+}
+
+func (fp FlightPlan) Equipment() Equipment {
+	if eq, ok := equipmentSuffixes[fp.EquipmentSuffix()]; ok {
+		return eq
+	}
+	return Equipment{RNAV: true, GNSS: true, ILS: true}
+}
+
+// SetEquipmentSuffix replaces the equipment suffix in the aircraft type;
+// an empty suffix removes it.
+func (fp *FlightPlan) SetEquipmentSuffix(suffix string) {
+	fp.AircraftType = fp.TypeWithoutSuffix()
+	if suffix != "" {
+		fp.AircraftType += "/" + suffix
+	}
+}
+
+// sampleEquipmentSuffix returns an equipment suffix for an airliner.
+// Nearly all have GNSS, though some older ones only have RNAV.
+func sampleEquipmentSuffix(requireGNSS bool) string {
+	if !requireGNSS && rand.Float32() < nonGNSSProbability {
+		return "Z"
+	}
+	return "L"
+}
+
+// rnavProcedure returns true if the database says that the given SID or
+// STAR at the airport is an RNAV procedure.
+func rnavProcedure(airport, procedure string, sid bool) bool {
+	ap, ok := database.Airports[airport]
+	if !ok {
+		return false
+	}
+	if sid {
+		return ap.SIDs[procedure].RNAV
+	}
+	return ap.STARs[procedure].RNAV
+}
+
+// checkProcedureEquipment returns the pilot's response if the aircraft
+// isn't equipped to fly its SID or STAR.
+func (ac *Aircraft) checkProcedureEquipment(sid bool) []RadioTransmission {
+	if ac.FlightPlan == nil || ac.FlightPlan.Equipment().RNAV {
+		return nil
+	}
+
+	proc, airport := ac.STAR, ac.FlightPlan.ArrivalAirport
+	if sid {
+		proc, airport = ac.SID, ac.FlightPlan.DepartureAirport
+	}
+	if proc != "" && rnavProcedure(airport, proc, sid) {
+		return ac.readbackUnexpected("unable the %s, we're not RNAV equipped.", proc)
+	}
+	return nil
+}
+
+// checkApproachEquipment returns the pilot's response if the aircraft
+// isn't equipped to fly the given approach.
+func (ac *Aircraft) checkApproachEquipment(id string, w *World) []RadioTransmission {
+	ap := w.GetAirport(ac.FlightPlan.ArrivalAirport)
+	if ap == nil {
+		return nil
+	}
+	appr, ok := ap.Approaches[id]
+	if !ok {
+		// Let Nav report the unknown approach.
+		return nil
+	}
+
+	eq := ac.FlightPlan.Equipment()
+	if appr.Type == ILSApproach && !eq.ILS {
+		return ac.readbackUnexpected("unable the %s, we're not ILS equipped.", appr.FullName)
+	} else if appr.Type == RNAVApproach && !eq.GNSS {
+		return ac.readbackUnexpected("unable the %s, we're not GPS equipped.", appr.FullName)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// equipment_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestEquipmentSuffix(t *testing.T) {
+	for _, test := range []struct {
+		actype string
+		suffix string
+		eq     Equipment
+	}{
+		{"B738/L", "L", Equipment{RNAV: true, GNSS: true, ILS: true}},
+		{"H/B77W/Z", "Z", Equipment{RNAV: true, ILS: true}},
+		{"H/B744", "", Equipment{RNAV: true, GNSS: true, ILS: true}},
+		{"C172/U", "U", Equipment{}},
+		{"BE58/A", "A", Equipment{ILS: true}},
+		{"A320", "", Equipment{RNAV: true, GNSS: true, ILS: true}},
+	} {
+		fp := FlightPlan{AircraftType: test.actype}
+		if s := fp.EquipmentSuffix(); s != test.suffix {
+			t.Errorf("%s: got suffix %q, expected %q", test.actype, s, test.suffix)
+		}
+		if eq := fp.Equipment(); eq != test.eq {
+			t.Errorf("%s: got equipment %+v, expected %+v", test.actype, eq, test.eq)
+		}
+	}
+
+	fp := FlightPlan{AircraftType: "H/B77W/L"}
+	fp.SetEquipmentSuffix("Z")
+	if fp.AircraftType != "H/B77W/Z" {
+		t.Errorf("got %q after setting suffix Z", fp.AircraftType)
+	}
+	fp.SetEquipmentSuffix("")
+	if fp.AircraftType != "H/B77W" {
+		t.Errorf("got %q after removing suffix", fp.AircraftType)
+	}
+}
@@ -14,29 +14,41 @@ import (
 
 // Aviation-related
 var (
+	ErrBeaconCodeInUse              = errors.New("Beacon code is already in use")
+	ErrCallsignInUse                = errors.New("Callsign is already in use")
 	ErrClearedForUnexpectedApproach = errors.New("Cleared for unexpected approach")
 	ErrFixNotInRoute                = errors.New("Fix not in aircraft's route")
+	ErrInvalidAircraftEdit          = errors.New("Invalid aircraft edit")
 	ErrInvalidAltitude              = errors.New("Altitude above aircraft's ceiling")
 	ErrInvalidApproach              = errors.New("Invalid approach")
 	ErrInvalidCommandSyntax         = errors.New("Invalid command syntax")
 	ErrInvalidController            = errors.New("Invalid controller")
 	ErrInvalidHeading               = errors.New("Invalid heading")
+	ErrInvalidScratchpad            = errors.New("Invalid scratchpad")
+	ErrInvalidTrialPlan             = errors.New("Invalid trial plan")
 	ErrNoAircraftForCallsign        = errors.New("No aircraft exists with specified callsign")
+	ErrNoAvailableBeaconCode        = errors.New("No beacon codes are available")
 	ErrNoController                 = errors.New("No controller with that callsign")
 	ErrNotLaunchController          = errors.New("Not signed in as the launch controller")
 	ErrNoFlightPlan                 = errors.New("No flight plan has been filed for aircraft")
+	ErrNoReleaseRequested           = errors.New("No departure release has been requested for aircraft")
+	ErrNoResponse                   = errors.New("No response")
 	ErrNoValidArrivalFound          = errors.New("Unable to find a valid arrival")
 	ErrNoValidDepartureFound        = errors.New("Unable to find a valid departure")
 	ErrNotBeingHandedOffToMe        = errors.New("Aircraft not being handed off to current controller")
 	ErrNotPointedOutToMe            = errors.New("Aircraft not being pointed out to current controller")
 	ErrNotClearedForApproach        = errors.New("Aircraft has not been cleared for an approach")
 	ErrNotFlyingRoute               = errors.New("Aircraft is not currently flying its assigned route")
+	ErrNotOnFrequency               = errors.New("Aircraft is not on your frequency")
 	ErrOtherControllerHasTrack      = errors.New("Another controller is already tracking the aircraft")
 	ErrUnableCommand                = errors.New("Unable")
 	ErrUnknownAircraftType          = errors.New("Unknown aircraft type")
 	ErrUnknownAirport               = errors.New("Unknown airport")
 	ErrUnknownApproach              = errors.New("Unknown approach")
+	ErrUnknownArrivalGroup          = errors.New("Unknown arrival group")
 	ErrUnknownRunway                = errors.New("Unknown runway")
+	ErrUnknownRadarSite             = errors.New("Unknown radar site")
+	ErrUnknownSituation             = errors.New("Unknown situation")
 )
 
 // Sim/server-related
@@ -50,22 +62,34 @@ var (
 	ErrRPCVersionMismatch        = errors.New("Client and server RPC versions don't match")
 	ErrRestoringSavedState       = errors.New("Errors during state restoration")
 	ErrInvalidPassword           = errors.New("Invalid password")
+	ErrNoReliefRequest           = errors.New("No one has asked to relieve this position")
+	ErrNotSignedIn               = errors.New("No controller is signed in to that position")
+	ErrScenarioReload            = errors.New("Errors in the reloaded scenarios; see the server log")
 )
 
 var errorStringToError = map[string]error{
+	ErrBeaconCodeInUse.Error():              ErrBeaconCodeInUse,
+	ErrCallsignInUse.Error():                ErrCallsignInUse,
 	ErrClearedForUnexpectedApproach.Error(): ErrClearedForUnexpectedApproach,
 	ErrFixNotInRoute.Error():                ErrFixNotInRoute,
+	ErrInvalidAircraftEdit.Error():          ErrInvalidAircraftEdit,
 	ErrInvalidAltitude.Error():              ErrInvalidAltitude,
+	ErrInvalidScratchpad.Error():            ErrInvalidScratchpad,
 	ErrInvalidApproach.Error():              ErrInvalidApproach,
 	ErrInvalidCommandSyntax.Error():         ErrInvalidCommandSyntax,
 	ErrInvalidController.Error():            ErrInvalidController,
 	ErrInvalidHeading.Error():               ErrInvalidHeading,
+	ErrInvalidTrialPlan.Error():             ErrInvalidTrialPlan,
 	ErrNoAircraftForCallsign.Error():        ErrNoAircraftForCallsign,
+	ErrNoAvailableBeaconCode.Error():        ErrNoAvailableBeaconCode,
 	ErrNoController.Error():                 ErrNoController,
 	ErrNoFlightPlan.Error():                 ErrNoFlightPlan,
+	ErrNoReleaseRequested.Error():           ErrNoReleaseRequested,
+	ErrNoResponse.Error():                   ErrNoResponse,
 	ErrNoValidDepartureFound.Error():        ErrNoValidDepartureFound,
 	ErrNotBeingHandedOffToMe.Error():        ErrNotBeingHandedOffToMe,
 	ErrNotPointedOutToMe.Error():            ErrNotPointedOutToMe,
+	ErrNotOnFrequency.Error():               ErrNotOnFrequency,
 	ErrNotClearedForApproach.Error():        ErrNotClearedForApproach,
 	ErrNotFlyingRoute.Error():               ErrNotFlyingRoute,
 	ErrOtherControllerHasTrack.Error():      ErrOtherControllerHasTrack,
@@ -73,7 +97,10 @@ var errorStringToError = map[string]error{
 	ErrUnknownAircraftType.Error():          ErrUnknownAircraftType,
 	ErrUnknownAirport.Error():               ErrUnknownAirport,
 	ErrUnknownApproach.Error():              ErrUnknownApproach,
+	ErrUnknownArrivalGroup.Error():          ErrUnknownArrivalGroup,
 	ErrUnknownRunway.Error():                ErrUnknownRunway,
+	ErrUnknownRadarSite.Error():             ErrUnknownRadarSite,
+	ErrUnknownSituation.Error():             ErrUnknownSituation,
 	ErrControllerAlreadySignedIn.Error():    ErrControllerAlreadySignedIn,
 	ErrDuplicateSimName.Error():             ErrDuplicateSimName,
 	ErrInvalidControllerToken.Error():       ErrInvalidControllerToken,
@@ -83,6 +110,9 @@ var errorStringToError = map[string]error{
 	ErrRPCVersionMismatch.Error():           ErrRPCVersionMismatch,
 	ErrRestoringSavedState.Error():          ErrRestoringSavedState,
 	ErrInvalidPassword.Error():              ErrInvalidPassword,
+	ErrNoReliefRequest.Error():              ErrNoReliefRequest,
+	ErrNotSignedIn.Error():                  ErrNotSignedIn,
+	ErrScenarioReload.Error():               ErrScenarioReload,
 }
 
 func TryDecodeError(e error) error {
@@ -117,6 +147,7 @@ var (
 	ErrSTARSIllegalMap        = NewSTARSError("ILL MAP")
 	ErrSTARSIllegalParam      = NewSTARSError("ILL PARAM")
 	ErrSTARSIllegalPosition   = NewSTARSError("ILL POS")
+	ErrSTARSIllegalPrefSet    = NewSTARSError("ILL PREF SET")
 	ErrSTARSIllegalRPC        = NewSTARSError("ILL RPC") // CRDA runway pair config
 	ErrSTARSIllegalRunway     = NewSTARSError("ILL RWY")
 	ErrSTARSIllegalScratchpad = NewSTARSError("ILL SCR")
@@ -129,6 +160,7 @@ var (
 )
 
 var starsErrorRemap = map[error]*STARSError{
+	ErrBeaconCodeInUse:              ErrSTARSDuplicateBeacon,
 	ErrClearedForUnexpectedApproach: ErrSTARSIllegalValue,
 	ErrFixNotInRoute:                ErrSTARSIllegalFix,
 	ErrInvalidAltitude:              ErrSTARSIllegalValue,
@@ -136,13 +168,17 @@ var starsErrorRemap = map[error]*STARSError{
 	ErrInvalidCommandSyntax:         ErrSTARSCommandFormat,
 	ErrInvalidController:            ErrSTARSIllegalPosition,
 	ErrInvalidHeading:               ErrSTARSIllegalValue,
+	ErrInvalidScratchpad:            ErrSTARSIllegalScratchpad,
 	ErrNoAircraftForCallsign:        ErrSTARSNoFlight,
+	ErrNoAvailableBeaconCode:        ErrSTARSIllegalCode,
 	ErrNoController:                 ErrSTARSIllegalSector,
 	ErrNoFlightPlan:                 ErrSTARSIllegalFlight,
+	ErrNoResponse:                   ErrSTARSIllegalTrack,
 	ErrNotBeingHandedOffToMe:        ErrSTARSIllegalTrack,
 	ErrNotPointedOutToMe:            ErrSTARSIllegalTrack,
 	ErrNotClearedForApproach:        ErrSTARSIllegalValue,
 	ErrNotFlyingRoute:               ErrSTARSIllegalValue,
+	ErrNotOnFrequency:               ErrSTARSIllegalTrack,
 	ErrOtherControllerHasTrack:      ErrSTARSIllegalTrack,
 	ErrUnableCommand:                ErrSTARSIllegalValue,
 	ErrUnknownAircraftType:          ErrSTARSIllegalParam,
@@ -181,7 +217,18 @@ type ErrorLogger struct {
 	// an error is found.
 	hierarchy []string
 	// Actual error messages to report.
-	errors []string
+	errors []ErrorLogEntry
+}
+
+// ErrorLogEntry is a single error reported to an ErrorLogger along with
+// the context it was reported in.
+type ErrorLogEntry struct {
+	Context []string `json:"context"`
+	Message string   `json:"message"`
+}
+
+func (e ErrorLogEntry) String() string {
+	return strings.Join(e.Context, " / ") + ": " + e.Message
 }
 
 func (e *ErrorLogger) Push(s string) {
@@ -193,22 +240,27 @@ func (e *ErrorLogger) Pop() {
 }
 
 func (e *ErrorLogger) ErrorString(s string, args ...interface{}) {
-	e.errors = append(e.errors, strings.Join(e.hierarchy, " / ")+": "+fmt.Sprintf(s, args...))
+	e.errors = append(e.errors, ErrorLogEntry{Context: DuplicateSlice(e.hierarchy), Message: fmt.Sprintf(s, args...)})
 }
 
 func (e *ErrorLogger) Error(err error) {
-	e.errors = append(e.errors, strings.Join(e.hierarchy, " / ")+": "+err.Error())
+	e.errors = append(e.errors, ErrorLogEntry{Context: DuplicateSlice(e.hierarchy), Message: err.Error()})
 }
 
 func (e *ErrorLogger) HaveErrors() bool {
 	return len(e.errors) > 0
 }
 
+// Errors returns all of the errors that have been reported.
+func (e *ErrorLogger) Errors() []ErrorLogEntry {
+	return e.errors
+}
+
 func (e *ErrorLogger) PrintErrors(lg *Logger) {
 	// Two loops so they aren't interleaved with logging to stdout
 	if lg != nil {
 		for _, err := range e.errors {
-			lg.Errorf("%+v", err)
+			lg.Errorf("%+v", err.String())
 		}
 	}
 	for _, err := range e.errors {
@@ -217,5 +269,5 @@ func (e *ErrorLogger) PrintErrors(lg *Logger) {
 }
 
 func (e *ErrorLogger) String() string {
-	return strings.Join(e.errors, "\n")
+	return strings.Join(MapSlice(e.errors, func(e ErrorLogEntry) string { return e.String() }), "\n")
 }
@@ -24,6 +24,7 @@ type EventStream struct {
 	events        []Event
 	lastCompact   time.Time
 	subscriptions map[*EventsSubscription]interface{}
+	journal       *EventJournal
 }
 
 type EventPoster interface {
@@ -86,6 +87,24 @@ func (e *EventsSubscription) Unsubscribe() {
 	e.stream = nil
 }
 
+// SetJournal specifies an EventJournal that records all events
+// subsequently posted to the stream, regardless of whether there are any
+// subscribers.
+func (e *EventStream) SetJournal(j *EventJournal) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.journal = j
+}
+
+// Journal returns the stream's EventJournal, which may be nil.
+func (e *EventStream) Journal() *EventJournal {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.journal
+}
+
 // Post adds an event to the event stream. The type used to encode the
 // event is arbitrary; it's up to the EventStream users to establish
 // conventions.
@@ -93,7 +112,14 @@ func (e *EventStream) Post(event Event) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	lg.Debug("posted event", slog.Any("event", event))
+	if lg.DebugEnabled() {
+		// Check first to avoid allocating for the slog.Any argument.
+		lg.Debug("posted event", slog.Any("event", event))
+	}
+
+	if e.journal != nil {
+		e.journal.Record(event)
+	}
 
 	// Ignore the event if no one's paying attention.
 	if len(e.subscriptions) > 0 {
@@ -198,6 +224,10 @@ const (
 	HandoffControllEvent
 	SetGlobalLeaderLineEvent
 	TrackClickedEvent
+	ReliefRequestEvent
+	DepartureReleaseRequestEvent
+	SimilarCallsignEvent
+	UntrackedInSectorEvent
 	NumEventTypes
 )
 
@@ -206,7 +236,8 @@ func (t EventType) String() string {
 		"OfferedHandoff", "AcceptedHandoff", "CanceledHandoff", "RejectedHandoff",
 		"RadioTransmission", "StatusMessage", "ServerBroadcastMessage", "GlobalMessage",
 		"AcknowledgedPointOut", "RejectedPointOut", "Ident", "HandoffControll",
-		"SetGlobalLeaderLine", "TrackClicked"}[t]
+		"SetGlobalLeaderLine", "TrackClicked", "ReliefRequest",
+		"DepartureReleaseRequest", "SimilarCallsign", "UntrackedInSector"}[t]
 }
 
 type Event struct {
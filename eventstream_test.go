@@ -37,7 +37,7 @@ func TestEventStream(t *testing.T) {
 }
 
 func TestEventStreamCompact(t *testing.T) {
-	lg = NewLogger(false, "debug")
+	lg = NewLogger(false, "debug", LogRetention{})
 	es := NewEventStream()
 
 	// multiple consumers, at different offsets
@@ -81,3 +81,18 @@ func TestEventStreamCompact(t *testing.T) {
 		t.Errorf("is compaction not happening? len %d cap %d", len(es.events), cap(es.events))
 	}
 }
+
+func BenchmarkEventStream(b *testing.B) {
+	es := NewEventStream()
+	sub := es.Subscribe()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 16; j++ {
+			es.Post(Event{Type: EventType(j % NumEventTypes), Callsign: "AAL123"})
+		}
+		if len(sub.Get()) != 16 {
+			b.Fatalf("expected 16 events")
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// fdio.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// The FDIOPane emulates a flight data input/output terminal, as used by
+// clearance delivery and flight data positions: it lists the departures
+// that are still on the ground and accepts flight data entries to read
+// out, amend, and print their flight plans before they depart. Together
+// with the flight strips pane, it allows a controller in a multi-controller
+// session to staff clearance delivery.
+//
+// The following entries are supported, where ACID is an aircraft's
+// callsign:
+//
+// FR ACID: flight plan readout
+// AM ACID FIELD VALUE: amend a field of the flight plan; FIELD is given
+// by either its number or its name: 3/TYP, 5/SPD, 8/ALT, 10/RTE, 11/RMK.
+// SR ACID: strip request; the flight strip is added to the flight strips
+// pane.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errFDIOFormat = errors.New("FORMAT")
+
+type FDIOPane struct {
+	FontIdentifier FontIdentifier
+	font           *Font
+	scrollbar      *ScrollBar
+	eventStream    *EventStream
+
+	output []Message
+	input  CLIInput
+}
+
+func NewFDIOPane() *FDIOPane {
+	return &FDIOPane{
+		FontIdentifier: FontIdentifier{Name: "Flight Strip Printer", Size: 12},
+	}
+}
+
+func (fd *FDIOPane) Name() string { return "Flight Data (FDIO)" }
+
+func (fd *FDIOPane) Activate(w *World, r Renderer, eventStream *EventStream) {
+	if fd.font = GetFont(fd.FontIdentifier); fd.font == nil {
+		fd.font = GetDefaultFont()
+		fd.FontIdentifier = fd.font.id
+	}
+	if fd.scrollbar == nil {
+		fd.scrollbar = NewVerticalScrollBar(4, true)
+	}
+	fd.eventStream = eventStream
+}
+
+func (fd *FDIOPane) Deactivate() {
+	fd.eventStream = nil
+}
+
+func (fd *FDIOPane) ResetWorld(w *World) {
+	fd.output = nil
+}
+
+func (fd *FDIOPane) CanTakeKeyboardFocus() bool { return true }
+
+func (fd *FDIOPane) DrawUI() {
+	if newFont, changed := DrawFontPicker(&fd.FontIdentifier, "Font"); changed {
+		fd.font = newFont
+	}
+}
+
+// proposedDepartures returns the departures with flight plans that are
+// still on the ground, sorted by callsign.
+func (fd *FDIOPane) proposedDepartures(w *World) []*Aircraft {
+	var deps []*Aircraft
+	for _, ac := range w.Aircraft {
+		if ac.IsDeparture() && !ac.IsAirborne() && ac.FlightPlan != nil {
+			deps = append(deps, ac)
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Callsign < deps[j].Callsign })
+	return deps
+}
+
+func (fd *FDIOPane) Draw(ctx *PaneContext, cb *CommandBuffer) {
+	if ctx.mouse != nil && ctx.mouse.Clicked[MouseButtonPrimary] {
+		wmTakeKeyboardFocus(fd, false)
+	}
+	fd.processKeyboard(ctx)
+
+	// Build up all of the lines to draw from the top down: the proposed
+	// departures, then the responses to flight data entries.
+	type line struct {
+		text  string
+		color RGB
+	}
+	var lines []line
+	lines = append(lines, line{"PROPOSED DEPARTURES", RGB{.2, .9, .9}})
+	deps := fd.proposedDepartures(ctx.world)
+	for _, ac := range deps {
+		lines = append(lines, line{formatFDIOReadout(ac), RGB{1, 1, 1}})
+	}
+	if len(deps) == 0 {
+		lines = append(lines, line{"NONE", RGB{.6, .6, .6}})
+	}
+	lines = append(lines, line{"", RGB{}})
+	for _, msg := range fd.output {
+		lines = append(lines, line{msg.contents, msg.Color()})
+	}
+
+	lineHeight := float32(fd.font.size + 1)
+	visibleLines := int(ctx.paneExtent.Height()/lineHeight) - 1 /* prompt */
+	fd.scrollbar.Update(len(lines), visibleLines, ctx)
+
+	td := GetTextDrawBuilder()
+	defer ReturnTextDrawBuilder(td)
+
+	indent := float32(2)
+	style := TextStyle{Font: fd.font, Color: RGB{1, 1, .2}}
+
+	// The prompt is at the bottom; the cursor is drawn if we have focus.
+	ci := fd.input
+	if ctx.haveFocus {
+		cursorStyle := TextStyle{Font: fd.font, Color: RGB{1, 1, .2}, DrawBackground: true,
+			BackgroundColor: RGB{1, 1, 1}}
+		if ci.cursor == len(ci.cmd) {
+			td.AddTextMulti([]string{"> " + ci.cmd, " "}, [2]float32{indent, lineHeight},
+				[]TextStyle{style, cursorStyle})
+		} else {
+			td.AddTextMulti([]string{"> " + ci.cmd[:ci.cursor], ci.cmd[ci.cursor : ci.cursor+1], ci.cmd[ci.cursor+1:]},
+				[2]float32{indent, lineHeight}, []TextStyle{style, cursorStyle, style})
+		}
+	} else {
+		td.AddText("> "+ci.cmd, [2]float32{indent, lineHeight}, style)
+	}
+
+	// Scroll so that the most recent output is visible by default.
+	start := max(0, len(lines)-visibleLines-fd.scrollbar.Offset())
+	y := ctx.paneExtent.Height()
+	for i := start; i < len(lines) && y > 2*lineHeight; i++ {
+		td.AddText(lines[i].text, [2]float32{indent, y}, TextStyle{Font: fd.font, Color: lines[i].color})
+		y -= lineHeight
+	}
+
+	ctx.SetWindowCoordinateMatrices(cb)
+	if ctx.haveFocus {
+		ld := GetLinesDrawBuilder()
+		defer ReturnLinesDrawBuilder(ld)
+
+		w, h := ctx.paneExtent.Width(), ctx.paneExtent.Height()
+		ld.AddClosedPolyline([][2]float32{{0, 0}, {w, 0}, {w, h}, {0, h}})
+		cb.SetRGB(RGB{1, 1, 0})
+		ld.GenerateCommands(cb)
+	}
+	fd.scrollbar.Draw(ctx, cb)
+	td.GenerateCommands(cb)
+}
+
+func (fd *FDIOPane) processKeyboard(ctx *PaneContext) {
+	if ctx.keyboard == nil || !ctx.haveFocus {
+		return
+	}
+
+	fd.input.InsertAtCursor(strings.ToUpper(ctx.keyboard.Input))
+
+	if ctx.keyboard.IsPressed(KeyLeftArrow) && fd.input.cursor > 0 {
+		fd.input.cursor--
+	}
+	if ctx.keyboard.IsPressed(KeyRightArrow) && fd.input.cursor < len(fd.input.cmd) {
+		fd.input.cursor++
+	}
+	if ctx.keyboard.IsPressed(KeyHome) {
+		fd.input.cursor = 0
+	}
+	if ctx.keyboard.IsPressed(KeyEnd) {
+		fd.input.cursor = len(fd.input.cmd)
+	}
+	if ctx.keyboard.IsPressed(KeyBackspace) {
+		fd.input.DeleteBeforeCursor()
+	}
+	if ctx.keyboard.IsPressed(KeyDelete) {
+		fd.input.DeleteAfterCursor()
+	}
+	if ctx.keyboard.IsPressed(KeyEscape) {
+		fd.input = CLIInput{}
+	}
+	if ctx.keyboard.IsPressed(KeyEnter) && fd.input.cmd != "" {
+		cmd := fd.input.cmd
+		fd.input = CLIInput{}
+		fd.output = append(fd.output, Message{contents: "> " + cmd})
+		if err := fd.runCommand(ctx.world, cmd); err != nil {
+			fd.output = append(fd.output, Message{contents: err.Error(), error: true})
+		}
+	}
+}
+
+func (fd *FDIOPane) runCommand(w *World, cmd string) error {
+	f := strings.Fields(cmd)
+	if len(f) < 2 {
+		return errFDIOFormat
+	}
+
+	ac := w.GetAircraft(f[1], false)
+	if ac == nil {
+		return fmt.Errorf("%s: FLID NOT FOUND", f[1])
+	} else if ac.FlightPlan == nil {
+		return fmt.Errorf("%s: NO FLIGHT PLAN", ac.Callsign)
+	}
+
+	switch f[0] {
+	case "FR":
+		fd.output = append(fd.output, Message{contents: formatFDIOReadout(ac), system: true})
+		return nil
+
+	case "AM":
+		if len(f) < 4 {
+			return errFDIOFormat
+		}
+		amended := *ac.FlightPlan
+		if err := amendFlightPlanField(&amended, f[2], strings.Join(f[3:], " ")); err != nil {
+			return err
+		}
+		callsign := ac.Callsign
+		w.AmendFlightPlan(callsign, amended,
+			func(any) {
+				fd.output = append(fd.output, Message{contents: "AMENDED " + callsign, system: true})
+			},
+			func(err error) {
+				fd.output = append(fd.output, Message{contents: callsign + ": " + err.Error(), error: true})
+			})
+		return nil
+
+	case "SR":
+		if fd.eventStream != nil {
+			fd.eventStream.Post(Event{
+				Type:         PushedFlightStripEvent,
+				Callsign:     ac.Callsign,
+				ToController: w.Callsign,
+			})
+		}
+		fd.output = append(fd.output, Message{contents: "STRIP " + ac.Callsign, system: true})
+		return nil
+
+	default:
+		return fmt.Errorf("%s: INVALID MESSAGE TYPE", f[0])
+	}
+}
+
+// formatFDIOReadout returns a one-line readout of the aircraft's flight
+// plan: callsign, aircraft type, beacon code, departure airport,
+// requested altitude in hundreds of feet, route, and destination.
+func formatFDIOReadout(ac *Aircraft) string {
+	fp := ac.FlightPlan
+	return fmt.Sprintf("%-8s %-10s %s %s %03d %s %s", ac.Callsign, fp.AircraftType, ac.AssignedSquawk,
+		fp.DepartureAirport, fp.Altitude/100, fp.Route, fp.ArrivalAirport)
+}
+
+// amendFlightPlanField applies a flight data amendment to the given field
+// of the flight plan, where the field is specified by either its FDIO
+// field number or its name.
+func amendFlightPlanField(fp *FlightPlan, field, value string) error {
+	switch field {
+	case "3", "TYP":
+		fp.AircraftType = value
+
+	case "5", "SPD":
+		spd, err := strconv.Atoi(value)
+		if err != nil || spd <= 0 {
+			return fmt.Errorf("%s: INVALID SPEED", value)
+		}
+		fp.CruiseSpeed = spd
+
+	case "8", "ALT":
+		alt, err := strconv.Atoi(value)
+		if err != nil || alt <= 0 {
+			return fmt.Errorf("%s: INVALID ALTITUDE", value)
+		}
+		if len(value) <= 3 {
+			// Given in hundreds of feet
+			alt *= 100
+		}
+		fp.Altitude = alt
+
+	case "10", "RTE":
+		fp.Route = value
+
+	case "11", "RMK":
+		fp.Remarks = value
+
+	default:
+		return fmt.Errorf("%s: INVALID FIELD", field)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// fdio_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestAmendFlightPlanField(t *testing.T) {
+	fp := FlightPlan{AircraftType: "B738/L", Altitude: 35000, Route: "PORTE3 LEV", CruiseSpeed: 450}
+
+	for _, test := range []struct {
+		field, value string
+		check        func(fp FlightPlan) bool
+	}{
+		{"8", "240", func(fp FlightPlan) bool { return fp.Altitude == 24000 }},
+		{"ALT", "17000", func(fp FlightPlan) bool { return fp.Altitude == 17000 }},
+		{"10", "DIXIE V1 ATR", func(fp FlightPlan) bool { return fp.Route == "DIXIE V1 ATR" }},
+		{"TYP", "A320/L", func(fp FlightPlan) bool { return fp.AircraftType == "A320/L" }},
+		{"5", "420", func(fp FlightPlan) bool { return fp.CruiseSpeed == 420 }},
+		{"RMK", "NO RNAV", func(fp FlightPlan) bool { return fp.Remarks == "NO RNAV" }},
+	} {
+		amended := fp
+		if err := amendFlightPlanField(&amended, test.field, test.value); err != nil {
+			t.Errorf("%s %s: unexpected error: %v", test.field, test.value, err)
+		} else if !test.check(amended) {
+			t.Errorf("%s %s: unexpected result %+v", test.field, test.value, amended)
+		}
+	}
+
+	for _, test := range [][2]string{{"8", "FL240"}, {"8", "-10"}, {"SPD", "FAST"}, {"99", "X"}} {
+		amended := fp
+		if err := amendFlightPlanField(&amended, test[0], test[1]); err == nil {
+			t.Errorf("%s %s: expected error", test[0], test[1])
+		} else if amended != fp {
+			t.Errorf("%s %s: flight plan modified on error", test[0], test[1])
+		}
+	}
+}
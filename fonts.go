@@ -4,12 +4,16 @@
 
 package main
 
+// #include <stdlib.h>
+import "C"
+
 import (
-	"C"
 	"fmt"
 	"image"
 	"math"
+	"os"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"unicode/utf8"
@@ -37,13 +41,16 @@ var (
 	FontAwesomeIconCaretRight          = faUsedIcons["CaretRight"]
 	FontAwesomeIconCheckSquare         = faUsedIcons["CheckSquare"]
 	FontAwesomeIconCog                 = faUsedIcons["Cog"]
+	FontAwesomeIconColumns             = faUsedIcons["Columns"]
 	FontAwesomeIconCopyright           = faUsedIcons["Copyright"]
 	FontAwesomeIconDiscord             = faBrandsUsedIcons["Discord"]
 	FontAwesomeIconExclamationTriangle = faUsedIcons["ExclamationTriangle"]
 	FontAwesomeIconFile                = faUsedIcons["File"]
 	FontAwesomeIconFolder              = faUsedIcons["Folder"]
+	FontAwesomeIconGraduationCap       = faUsedIcons["GraduationCap"]
 	FontAwesomeIconGithub              = faBrandsUsedIcons["Github"]
 	FontAwesomeIconHandPointLeft       = faUsedIcons["HandPointLeft"]
+	FontAwesomeIconHistory             = faUsedIcons["History"]
 	FontAwesomeIconHome                = faUsedIcons["Home"]
 	FontAwesomeIconInfoCircle          = faUsedIcons["InfoCircle"]
 	FontAwesomeIconKeyboard            = faUsedIcons["Keyboard"]
@@ -52,11 +59,16 @@ var (
 	FontAwesomeIconMouse               = faUsedIcons["Mouse"]
 	FontAwesomeIconPauseCircle         = faUsedIcons["PauseCircle"]
 	FontAwesomeIconPlayCircle          = faUsedIcons["PlayCircle"]
+	FontAwesomeIconPuzzlePiece         = faUsedIcons["PuzzlePiece"]
 	FontAwesomeIconQuestionCircle      = faUsedIcons["QuestionCircle"]
 	FontAwesomeIconPlaneDeparture      = faUsedIcons["PlaneDeparture"]
 	FontAwesomeIconRedo                = faUsedIcons["Redo"]
 	FontAwesomeIconSquare              = faUsedIcons["Square"]
 	FontAwesomeIconTrash               = faUsedIcons["Trash"]
+	FontAwesomeIconUserCog             = faUsedIcons["UserCog"]
+	FontAwesomeIconUserFriends         = faUsedIcons["UserFriends"]
+	FontAwesomeIconWindowRestore       = faUsedIcons["WindowRestore"]
+	FontAwesomeIconVideo               = faUsedIcons["Video"]
 )
 
 var (
@@ -77,11 +89,14 @@ var (
 		"CaretRight":          FontAwesomeString("CaretRight"),
 		"CheckSquare":         FontAwesomeString("CheckSquare"),
 		"Cog":                 FontAwesomeString("Cog"),
+		"Columns":             FontAwesomeString("Columns"),
 		"Copyright":           FontAwesomeString("Copyright"),
 		"ExclamationTriangle": FontAwesomeString("ExclamationTriangle"),
 		"File":                FontAwesomeString("File"),
 		"Folder":              FontAwesomeString("Folder"),
+		"GraduationCap":       FontAwesomeString("GraduationCap"),
 		"HandPointLeft":       FontAwesomeString("HandPointLeft"),
+		"History":             FontAwesomeString("History"),
 		"Home":                FontAwesomeString("Home"),
 		"InfoCircle":          FontAwesomeString("InfoCircle"),
 		"Keyboard":            FontAwesomeString("Keyboard"),
@@ -90,11 +105,16 @@ var (
 		"Mouse":               FontAwesomeString("Mouse"),
 		"PauseCircle":         FontAwesomeString("PauseCircle"),
 		"PlayCircle":          FontAwesomeString("PlayCircle"),
+		"PuzzlePiece":         FontAwesomeString("PuzzlePiece"),
 		"QuestionCircle":      FontAwesomeString("QuestionCircle"),
 		"PlaneDeparture":      FontAwesomeString("PlaneDeparture"),
 		"Redo":                FontAwesomeString("Redo"),
 		"Square":              FontAwesomeString("Square"),
 		"Trash":               FontAwesomeString("Trash"),
+		"UserCog":             FontAwesomeString("UserCog"),
+		"UserFriends":         FontAwesomeString("UserFriends"),
+		"WindowRestore":       FontAwesomeString("WindowRestore"),
+		"Video":               FontAwesomeString("Video"),
 	}
 	faBrandsUsedIcons map[string]string = map[string]string{
 		"Discord": FontAwesomeBrandsString("Discord"),
@@ -148,6 +168,7 @@ func (f *Font) createGlyph(ch rune) *Glyph {
 
 // LookupGlyph returns the Glyph for the specified rune.
 func (f *Font) LookupGlyph(ch rune) *Glyph {
+	fontsAddRune(ch)
 	if int(ch) < len(f.lowGlyphs) {
 		if g := f.lowGlyphs[ch]; g == nil {
 			g = f.createGlyph(ch)
@@ -197,58 +218,148 @@ func ptrToUint16Slice(p unsafe.Pointer) []uint16 {
 	return (*[unrealisticLargePointer / 2]uint16)(p)[:]
 }
 
+// fontAtlas tracks the characters beyond Latin-1 that are in the font
+// atlas. imgui can't add characters to an atlas once it has been built,
+// so the atlas is built once at startup with the Latin-1 range, the used
+// icons, and the characters in globalConfig.FontCharacters, with glyphs
+// taken from the regular font if it has them and otherwise from the
+// fallback fonts. Other characters that are encountered are added to
+// FontCharacters so that they are available the next time vice starts.
+var fontAtlas struct {
+	// Non-Latin-1 characters that are in the atlas and ones that have
+	// been encountered since it was built.
+	runes   map[rune]interface{}
+	pending map[rune]interface{}
+}
+
+// Fonts with broad Unicode coverage, including CJK, that are commonly
+// available on each platform. Ones specified in the config are tried
+// first.
+var systemFallbackFonts = map[string][]string{
+	"darwin": []string{
+		"/System/Library/Fonts/Supplemental/Arial Unicode.ttf",
+		"/Library/Fonts/Arial Unicode.ttf",
+		"/System/Library/Fonts/Hiragino Sans GB.ttc",
+		"/System/Library/Fonts/AppleSDGothicNeo.ttc",
+	},
+	"linux": []string{
+		"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf",
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	},
+	"windows": []string{
+		`C:\Windows\Fonts\msyh.ttc`,
+		`C:\Windows\Fonts\msgothic.ttc`,
+		`C:\Windows\Fonts\malgun.ttf`,
+		`C:\Windows\Fonts\seguisym.ttf`,
+	},
+}
+
+func loadFallbackFonts() [][]byte {
+	var fallbacks [][]byte
+	for _, fn := range append(slices.Clone(globalConfig.FallbackFonts), systemFallbackFonts[runtime.GOOS]...) {
+		if ttf, err := os.ReadFile(fn); err == nil {
+			lg.Infof("%s: using fallback font", fn)
+			fallbacks = append(fallbacks, ttf)
+		}
+	}
+	if len(fallbacks) == 0 {
+		lg.Warn("no fallback fonts found; non-Latin text may not display")
+	}
+	return fallbacks
+}
+
+// newGlyphRanges returns an imgui.GlyphRanges that encompasses the given
+// characters, which must be sorted.
+func newGlyphRanges(runes []rune) imgui.GlyphRanges {
+	// imgui represents such glyph ranges as an array of uint16s, where
+	// each range is given by two successive values and where a value of
+	// 0 denotes the end of the array.  We need to resort to malloc for
+	// this array since imgui's AddFontFromMemoryTTF() function holds on
+	// to its pointer.  (Thus, using a slice or go's new fails
+	// unpredictably, since go's GC will happily reclaim the memory.)
+	r := C.malloc(C.size_t(4*len(runes) + 2))
+	ranges := ptrToUint16Slice(r)
+	i := 0
+	for _, ch := range runes {
+		if i > 0 && rune(ranges[i-1])+1 == ch {
+			// Extend the current range
+			ranges[i-1] = uint16(ch)
+		} else {
+			// The specified range is inclusive.
+			ranges[i] = uint16(ch)
+			ranges[i+1] = uint16(ch)
+			i += 2
+		}
+	}
+	ranges[i] = 0
+	return imgui.GlyphRanges(r)
+}
+
 func fontsInit(r Renderer, platform Platform) {
 	lg.Info("Starting to initialize fonts")
 	fonts = make(map[FontIdentifier]*Font)
+
+	scale := fontScale(platform)
+	uiScaleState.fontsBuiltScale = scale
+	lg.Infof("Font scale %.3f", scale)
+
+	fontAtlas.runes = make(map[rune]interface{})
+	fontAtlas.pending = make(map[rune]interface{})
+	for _, ch := range globalConfig.FontCharacters {
+		if ch > 0xff && ch <= 0xffff {
+			fontAtlas.runes[ch] = nil
+		}
+	}
+
 	io := imgui.CurrentIO()
 
 	// Given a map that specifies the icons used in an icon font, returns
 	// an imgui.GlyphRanges that encompasses those icons.  This GlyphRanges
 	// is then used shortly when the fonts are loaded.
 	glyphRangeForIcons := func(icons map[string]string) imgui.GlyphRanges {
-		// imgui represents such glyph ranges as an array of uint16s, where
-		// each range is given by two successive values and where a value
-		// of 0 denotes the end of the array.  We need to resort to malloc
-		// for this array since imgui's AddFontFromMemoryTTF() function
-		// holds on to its pointer.  (Thus, using a slice or go's new fails
-		// unpredictably, since go's GC will happily reclaim the memory.)
-		r := C.malloc(C.size_t(4*len(icons) + 2))
-		ranges := ptrToUint16Slice(r)
-		i := 0
+		var runes []rune
 		for _, str := range icons {
 			unicode, _ := utf8.DecodeRuneInString(str)
-			// The specified range is inclusive so we just double-up the
-			// unicode value.
-			ranges[i] = uint16(unicode)
-			ranges[i+1] = uint16(unicode)
-			i += 2
+			runes = append(runes, unicode)
 		}
-		ranges[i] = 0
-		return imgui.GlyphRanges(r)
+		slices.Sort(runes)
+		return newGlyphRanges(runes)
+	}
+	faGlyphRange := glyphRangeForIcons(faUsedIcons)
+	faBrandsGlyphRange := glyphRangeForIcons(faBrandsUsedIcons)
+
+	// Latin-1, which imgui includes by default, and then the additional
+	// characters that have been used.
+	var runes []rune
+	for ch := rune(0x20); ch <= 0xff; ch++ {
+		runes = append(runes, ch)
+	}
+	extra := SortedMapKeys(fontAtlas.runes)
+	textGlyphRange := newGlyphRanges(append(runes, extra...))
+	var extraGlyphRange imgui.GlyphRanges
+	if len(extra) > 0 {
+		extraGlyphRange = newGlyphRanges(extra)
 	}
 
-	// Decompress and get the glyph ranges for the Font Awesome fonts just once.
 	faTTF := LoadResource("fonts/Font Awesome 5 Free-Solid-900.otf.zst")
 	fabrTTF := LoadResource("fonts/Font Awesome 5 Brands-Regular-400.otf.zst")
-	faGlyphRange := glyphRangeForIcons(faUsedIcons)
-	faBrandsGlyphRange := glyphRangeForIcons(faBrandsUsedIcons)
+	var fallbacks [][]byte
+	if extraGlyphRange != 0 {
+		fallbacks = loadFallbackFonts()
+	}
 
 	add := func(filename string, mono bool, name string) {
 		ttf := LoadResource("fonts/" + filename)
 		for _, size := range []int{6, 7, 8, 9, 10, 11, 12, 13, 14, 16, 18, 20, 22, 24, 28} {
 			sp := float32(size)
-			if runtime.GOOS == "windows" {
-				if dpis := platform.DPIScale(); dpis > 1 {
-					sp *= platform.DPIScale()
-				} else {
-					// Fix font sizes to account for Windows using 96dpi but
-					// everyone else using 72...
-					sp *= 96. / 72.
-				}
-				sp = float32(int(sp + 0.5))
+			if scale != 1 {
+				sp = float32(int(sp*scale + 0.5))
 			}
 
-			ifont := io.Fonts().AddFontFromMemoryTTFV(ttf, sp, imgui.DefaultFontConfig, imgui.EmptyGlyphRanges)
+			ifont := io.Fonts().AddFontFromMemoryTTFV(ttf, sp, imgui.DefaultFontConfig, textGlyphRange)
 
 			config := imgui.NewFontConfig()
 			config.SetMergeMode(true)
@@ -256,6 +367,13 @@ func fontsInit(r Renderer, platform Platform) {
 			// make the icon sizes match the font's character sizes.
 			io.Fonts().AddFontFromMemoryTTFV(faTTF, .8*sp, config, faGlyphRange)
 			io.Fonts().AddFontFromMemoryTTFV(fabrTTF, .8*sp, config, faBrandsGlyphRange)
+			if extraGlyphRange != 0 {
+				// Characters the font doesn't have come from the first
+				// fallback font that does.
+				for _, fb := range fallbacks {
+					io.Fonts().AddFontFromMemoryTTFV(fb, sp, config, extraGlyphRange)
+				}
+			}
 
 			id := FontIdentifier{Name: name, Size: size}
 			fonts[id] = &Font{
@@ -282,12 +400,55 @@ func fontsInit(r Renderer, platform Platform) {
 		Pix:    unsafe.Slice((*uint8)(img.Pixels), 4*img.Width*img.Height),
 		Stride: 4 * img.Width,
 		Rect:   image.Rectangle{Max: image.Point{X: img.Width, Y: img.Height}}}
-	fontId := r.CreateTextureFromImage(rgb8Image, false)
-	io.Fonts().SetTextureID(imgui.TextureID(fontId))
+	id := r.CreateTextureFromImage(rgb8Image, false)
+	io.Fonts().SetTextureID(imgui.TextureID(id))
 
 	lg.Info("Finished initializing fonts")
 }
 
+// fontsAddText records any characters in the string that aren't in the
+// font atlas so that they will be added to it the next time vice starts.
+// Text drawn using Fonts is handled automatically; this should be called
+// for text that imgui draws directly.
+func fontsAddText(s string) {
+	for _, ch := range s {
+		fontsAddRune(ch)
+	}
+}
+
+func fontsAddRune(ch rune) {
+	// imgui only supports the basic multilingual plane.
+	if ch <= 0xff || ch > 0xffff || fontAtlas.pending == nil {
+		return
+	}
+	if _, ok := fontAtlas.runes[ch]; ok {
+		return
+	}
+	if ch >= 0xe000 && ch <= 0xf8ff {
+		// Private use area; this is where the icons are.
+		return
+	}
+	fontAtlas.pending[ch] = nil
+}
+
+// fontsUpdate adds characters that have been encountered but aren't in
+// the font atlas to globalConfig.FontCharacters so that they will be
+// included when the atlas is built the next time vice starts.
+func fontsUpdate() {
+	if len(fontAtlas.pending) == 0 {
+		return
+	}
+
+	for ch := range fontAtlas.pending {
+		fontAtlas.runes[ch] = nil
+	}
+	lg.Infof("Adding %d characters to the font atlas at the next startup", len(fontAtlas.pending))
+	clear(fontAtlas.pending)
+
+	chars := SortedMapKeys(fontAtlas.runes)
+	globalConfig.FontCharacters = string(chars)
+}
+
 // GetAllFonts returns a FontIdentifier slice that gives identifiers for
 // all of the available fonts, sorted by font name and then within each
 // name, by font size.
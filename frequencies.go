@@ -0,0 +1,142 @@
+// frequencies.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Frequencies: each control position has its own frequency and an
+// aircraft only responds to the controller whose frequency it is on
+// (its ControllingController). When an aircraft is switched to another
+// position, the pilot reads back the frequency and leaves; a few seconds
+// later, after retuning and waiting for a break in the transmissions,
+// the pilot checks in with whoever is working that position. In the
+// meantime the aircraft isn't on anyone's frequency and transmissions to
+// it go unanswered.
+
+import (
+	"log/slog"
+	"time"
+)
+
+type PendingContact struct {
+	Position string    // position whose frequency the aircraft was switched to
+	Time     time.Time // when the pilot checks in
+}
+
+// checkInDelay returns how long it takes a pilot to check in after being
+// switched to a new frequency.
+func checkInDelay() time.Duration {
+	return time.Duration(4+rand.Intn(9)) * time.Second
+}
+
+// onFrequency returns an error if the aircraft won't hear the
+// controller's transmissions.
+func onFrequency(ctrl *Controller, ac *Aircraft) error {
+	if ac.ControllingController != ctrl.Callsign {
+		return ErrNotOnFrequency
+	} else if ac.LostComms {
+		return ErrNoResponse
+	}
+	return nil
+}
+
+// workingController returns the callsign of the controller currently
+// working the given position: human positions may be consolidated, while
+// virtual controllers always work their own.
+func (s *Sim) workingController(position string) string {
+	if _, ok := s.SignOnPositions[position]; !ok {
+		return position
+	}
+	return s.ResolveController(position)
+}
+
+// switchFrequency has the aircraft leave its current frequency and check
+// in on the given position's frequency after a delay; s.mu must be held.
+func (s *Sim) switchFrequency(ac *Aircraft, position string) {
+	if s.PendingContacts == nil {
+		s.PendingContacts = make(map[string]PendingContact)
+	}
+	ac.ControllingController = ""
+	s.PendingContacts[ac.Callsign] = PendingContact{
+		Position: position,
+		Time:     s.SimTime.Add(checkInDelay()),
+	}
+}
+
+// frequencyChange returns the pilot's readback when the controller
+// switches the aircraft to the given controller.
+func frequencyChange(ac *Aircraft, octrl *Controller) RadioTransmission {
+	name := Select(octrl.FullName != "", octrl.FullName, octrl.Callsign)
+	bye := Sample("good day", "seeya")
+	contact := Sample("contact ", "over to ", "")
+	return RadioTransmission{
+		Controller: ac.ControllingController,
+		Message:    contact + name + " on " + octrl.Frequency.String() + ", " + bye,
+		Type:       RadioTransmissionReadback,
+	}
+}
+
+// updatePendingContacts checks in the aircraft whose frequency changes
+// have completed; s.mu must be held.
+func (s *Sim) updatePendingContacts(now time.Time) {
+	for _, callsign := range SortedMapKeys(s.PendingContacts) {
+		pc := s.PendingContacts[callsign]
+		if now.Before(pc.Time) {
+			continue
+		}
+		delete(s.PendingContacts, callsign)
+
+		ac, ok := s.World.Aircraft[callsign]
+		if !ok || ac.ControllingController != "" {
+			// It's gone or some other controller has already picked it up.
+			continue
+		} else if ac.LostComms {
+			s.lg.Info("NORDO aircraft didn't check in", slog.String("callsign", callsign),
+				slog.String("position", pc.Position))
+			continue
+		}
+
+		ctrl := s.workingController(pc.Position)
+		s.lg.Info("checked in", slog.String("callsign", callsign), slog.String("position", pc.Position),
+			slog.String("controller", ctrl))
+		ac.ControllingController = ctrl
+		PostRadioEvents(callsign, []RadioTransmission{RadioTransmission{
+			Controller: ctrl,
+			Message:    ac.ContactMessage(s.ReportingPoints),
+			Type:       RadioTransmissionContact,
+		}}, s)
+	}
+}
+
+// ContactController switches the aircraft to the frequency of the given
+// position, which need not be the one that is tracking it.
+func (s *Sim) ContactController(token, callsign, position string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	return s.dispatchCommand(token, callsign,
+		func(ctrl *Controller, ac *Aircraft) error {
+			if err := onFrequency(ctrl, ac); err != nil {
+				return err
+			} else if octrl := s.World.GetControllerByCallsign(position); octrl == nil {
+				return ErrNoController
+			} else if octrl.Callsign == ctrl.Callsign {
+				return ErrInvalidController
+			}
+			return nil
+		},
+		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
+			octrl := s.World.GetControllerByCallsign(position)
+			rt := frequencyChange(ac, octrl)
+
+			s.eventStream.Post(Event{
+				Type:           HandoffControllEvent,
+				FromController: ac.ControllingController,
+				ToController:   s.workingController(octrl.Callsign),
+				Callsign:       ac.Callsign,
+			})
+
+			s.switchFrequency(ac, octrl.Callsign)
+			return []RadioTransmission{rt}
+		})
+}
@@ -0,0 +1,47 @@
+// frequencies_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPendingContacts(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	ac := &Aircraft{Callsign: "AAL1", ControllingController: "2K"}
+	ac.Nav.FlightState.Altitude = 8000
+
+	s := &Sim{
+		World:           &World{Aircraft: map[string]*Aircraft{"AAL1": ac}},
+		SignOnPositions: map[string]*Controller{"2K": {Callsign: "2K"}},
+		eventStream:     NewEventStream(),
+		SimTime:         start,
+	}
+	sub := s.eventStream.Subscribe()
+
+	s.switchFrequency(ac, "N56")
+	if ac.ControllingController != "" {
+		t.Errorf("expected the aircraft to be off frequency, got %q", ac.ControllingController)
+	}
+
+	// Pilots take a few seconds to check in.
+	s.updatePendingContacts(start.Add(3 * time.Second))
+	if ac.ControllingController != "" || len(sub.Get()) != 0 {
+		t.Errorf("unexpected check in after 3 seconds")
+	}
+
+	s.updatePendingContacts(start.Add(15 * time.Second))
+	if ac.ControllingController != "N56" {
+		t.Errorf("expected the aircraft to be on N56's frequency, got %q", ac.ControllingController)
+	}
+	if events := sub.Get(); len(events) != 1 || events[0].Type != RadioTransmissionEvent ||
+		events[0].ToController != "N56" || events[0].RadioTransmissionType != RadioTransmissionContact {
+		t.Errorf("expected a check in with N56, got %v", events)
+	}
+	if len(s.PendingContacts) != 0 {
+		t.Errorf("expected no pending contacts, got %v", s.PendingContacts)
+	}
+}
@@ -0,0 +1,146 @@
+// fuel.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Fuel: arrivals carry enough fuel to fly their route and approach with
+// some extra for delays, plus the required IFR reserve. Fuel is tracked
+// in minutes of flying time; as it runs low, pilots first advise minimum
+// fuel, then tell the controller they'll have to divert if they can't
+// get the approach soon, and finally declare an emergency once they're
+// into their reserve. Thus, excessive vectoring and holding has
+// consequences. For simplicity, the thresholds only consider the fuel
+// remaining and not how long it will take to land.
+//
+// Departures and VFR aircraft leave the sim before fuel matters and so
+// it isn't modeled for them.
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+const (
+	fuelReserveMinutes = 45 // IFR final reserve
+	// Minutes of fuel above the reserve at which pilots advise minimum
+	// fuel and request a diversion.
+	minimumFuelMargin   = 15
+	diversionFuelMargin = 5
+	// Nominal speed used to estimate the time to fly the route.
+	fuelPlanningSpeed = 250
+)
+
+type FuelStatus int
+
+const (
+	FuelNormal FuelStatus = iota
+	FuelMinimum
+	FuelDiversion
+	FuelEmergency
+)
+
+func (fs FuelStatus) String() string {
+	return []string{"normal", "minimum", "diversion", "emergency"}[fs]
+}
+
+type FuelState struct {
+	Minutes float32 // remaining flying time
+	// The most urgent status that the pilot has reported to ATC.
+	Advised FuelStatus
+}
+
+func (f FuelState) Status() FuelStatus {
+	switch margin := f.Minutes - fuelReserveMinutes; {
+	case margin <= 0:
+		return FuelEmergency
+	case margin <= diversionFuelMargin:
+		return FuelDiversion
+	case margin <= minimumFuelMargin:
+		return FuelMinimum
+	default:
+		return FuelNormal
+	}
+}
+
+// initializeFuel gives a new arrival enough fuel for its route along with
+// a random amount extra for delays.
+func (ac *Aircraft) initializeFuel() {
+	var d float32
+	p := ac.Position()
+	for _, wp := range ac.Nav.Waypoints {
+		d += nmdistance2ll(p, wp.Location)
+		p = wp.Location
+	}
+	route := d / fuelPlanningSpeed * 60
+	const approach = 10
+	extra := float32(20 + rand.Intn(26))
+
+	ac.Fuel = &FuelState{Minutes: route + approach + extra + fuelReserveMinutes}
+}
+
+// alternateAirport returns the closest airport in the scenario, other than
+// the aircraft's destination, or an empty string if there is none.
+func (s *Sim) alternateAirport(ac *Aircraft) string {
+	alternate, dist := "", float32(0)
+	for _, icao := range SortedMapKeys(s.World.Airports) {
+		if ac.FlightPlan != nil && icao == ac.FlightPlan.ArrivalAirport {
+			continue
+		}
+		if d := nmdistance2ll(ac.Position(), s.World.Airports[icao].Location); alternate == "" || d < dist {
+			alternate, dist = icao, d
+		}
+	}
+	return alternate
+}
+
+// updateFuel is called once a second with s.mu held; it burns the
+// aircraft's fuel and has pilots report when it runs low.
+func (s *Sim) updateFuel() {
+	var aircraft []*Aircraft
+	for _, ac := range s.World.Aircraft {
+		if ac.Fuel != nil && ac.IsAirborne() && !ac.Frozen {
+			aircraft = append(aircraft, ac)
+		}
+	}
+	sort.Slice(aircraft, func(i, j int) bool { return aircraft[i].Callsign < aircraft[j].Callsign })
+
+	for _, ac := range aircraft {
+		ac.Fuel.Minutes = max(0, ac.Fuel.Minutes-float32(1)/60)
+
+		status := ac.Fuel.Status()
+		if status == FuelEmergency && ac.Squawk != Squawk(0o7700) {
+			s.lg.Info("fuel emergency", slog.String("callsign", ac.Callsign))
+			ac.Squawk = Squawk(0o7700)
+		}
+		if status <= ac.Fuel.Advised || ac.ControllingController == "" || ac.LostComms {
+			// Nothing new to report, or no one to tell.
+			continue
+		}
+
+		var msg string
+		switch status {
+		case FuelMinimum:
+			msg = "we're minimum fuel"
+		case FuelDiversion:
+			alternate := s.alternateAirport(ac)
+			if alternate == "" {
+				alternate = "our alternate"
+			}
+			msg = fmt.Sprintf("we're getting low on fuel. If we can't get the approach in the next %d minutes, "+
+				"we'll need to divert to %s", diversionFuelMargin, alternate)
+		case FuelEmergency:
+			msg = "we're declaring an emergency, we're low on fuel and need to land as soon as possible"
+		}
+
+		s.lg.Info("fuel advisory", slog.String("callsign", ac.Callsign), slog.String("status", status.String()),
+			slog.Float64("minutes", float64(ac.Fuel.Minutes)))
+		ac.Fuel.Advised = status
+		PostRadioEvents(ac.Callsign, []RadioTransmission{RadioTransmission{
+			Controller: ac.ControllingController,
+			Message:    msg,
+			Type:       RadioTransmissionUnexpected,
+		}}, s)
+	}
+}
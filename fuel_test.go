@@ -0,0 +1,63 @@
+// fuel_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestFuelAdvisories(t *testing.T) {
+	ac := &Aircraft{
+		Callsign:              "AAL1",
+		AssignedSquawk:        Squawk(0o1234),
+		Squawk:                Squawk(0o1234),
+		ControllingController: "2K",
+		FlightPlan:            &FlightPlan{ArrivalAirport: "KJFK"},
+		Fuel:                  &FuelState{Minutes: fuelReserveMinutes + minimumFuelMargin + 0.5},
+	}
+	ac.Nav.FlightState.IAS = 250
+
+	s := &Sim{
+		World: &World{
+			Aircraft: map[string]*Aircraft{"AAL1": ac},
+			Airports: map[string]*Airport{"KJFK": {}, "KLGA": {}},
+		},
+		eventStream: NewEventStream(),
+	}
+	sub := s.eventStream.Subscribe()
+
+	run := func(minutes int) []Event {
+		for i := 0; i < 60*minutes; i++ {
+			s.updateFuel()
+		}
+		return sub.Get()
+	}
+
+	if ev := run(1); len(ev) != 1 || ac.Fuel.Advised != FuelMinimum || ev[0].ToController != "2K" {
+		t.Errorf("expected a minimum fuel advisory, got %v", ev)
+	}
+	if ev := run(5); len(ev) != 0 {
+		t.Errorf("unexpected advisories %v", ev)
+	}
+
+	// No one to tell while changing frequencies, so the advisory comes
+	// after checking in.
+	ac.ControllingController = ""
+	if ev := run(6); len(ev) != 0 || ac.Fuel.Advised != FuelMinimum {
+		t.Errorf("unexpected advisories %v", ev)
+	}
+	ac.ControllingController = "2K"
+	if ev := run(1); len(ev) != 1 || ac.Fuel.Advised != FuelDiversion {
+		t.Errorf("expected a diversion request, got %v", ev)
+	}
+
+	if ev := run(5); len(ev) != 1 || ac.Fuel.Advised != FuelEmergency || ac.Squawk != Squawk(0o7700) {
+		t.Errorf("expected an emergency squawking 7700, got %v %s", ev, ac.Squawk)
+	}
+
+	if alt := s.alternateAirport(ac); alt != "KLGA" {
+		t.Errorf("expected KLGA as the alternate, got %q", alt)
+	}
+}
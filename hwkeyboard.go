@@ -0,0 +1,254 @@
+// hwkeyboard.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Support for dedicated keypads and STARS/ERAM-style hardware keyboards.
+// Individual physical keys, identified either by their GLFW key code or
+// by their scancode, can be mapped to scope functions, to text, or to the
+// STARS slew key, which acts like clicking the primary mouse button at
+// the current cursor position.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/mmp/imgui-go/v4"
+)
+
+const (
+	HardwareKeyActionFunction = "Function"
+	HardwareKeyActionText     = "Text"
+	HardwareKeyActionSlew     = "Slew"
+)
+
+type HardwareKeyMapping struct {
+	// If Key is non-zero, the mapping matches the GLFW key code;
+	// otherwise it matches Scancode.
+	Key      int
+	Scancode int
+	Action   string
+	// Name of the KeyFunction to invoke for HardwareKeyActionFunction.
+	Function string
+	// Text to enter for HardwareKeyActionText.
+	Text string
+}
+
+type HardwareKeyboardConfig struct {
+	Enabled  bool
+	Mappings []HardwareKeyMapping
+}
+
+var keypadKeyNames = map[glfw.Key]string{
+	glfw.KeyKP0: "Keypad 0", glfw.KeyKP1: "Keypad 1", glfw.KeyKP2: "Keypad 2",
+	glfw.KeyKP3: "Keypad 3", glfw.KeyKP4: "Keypad 4", glfw.KeyKP5: "Keypad 5",
+	glfw.KeyKP6: "Keypad 6", glfw.KeyKP7: "Keypad 7", glfw.KeyKP8: "Keypad 8",
+	glfw.KeyKP9: "Keypad 9", glfw.KeyKPDecimal: "Keypad .", glfw.KeyKPDivide: "Keypad /",
+	glfw.KeyKPMultiply: "Keypad *", glfw.KeyKPSubtract: "Keypad -", glfw.KeyKPAdd: "Keypad +",
+	glfw.KeyKPEnter: "Keypad Enter", glfw.KeyKPEqual: "Keypad =",
+}
+
+// hardwareKeyboardPresets gives mappings for common setups; keypad
+// digits aren't mapped since they already enter numbers.
+var hardwareKeyboardPresets = map[string][]HardwareKeyMapping{
+	"Numeric keypad": []HardwareKeyMapping{
+		{Key: int(glfw.KeyKPEnter), Action: HardwareKeyActionFunction, Function: "Enter"},
+		{Key: int(glfw.KeyKPAdd), Action: HardwareKeyActionSlew},
+		{Key: int(glfw.KeyKPMultiply), Action: HardwareKeyActionFunction, Function: "Multifunction"},
+		{Key: int(glfw.KeyKPDivide), Action: HardwareKeyActionFunction, Function: "Initiate control"},
+		{Key: int(glfw.KeyKPSubtract), Action: HardwareKeyActionFunction, Function: "Clear"},
+		{Key: int(glfw.KeyKPEqual), Action: HardwareKeyActionFunction, Function: "Handoff"},
+	},
+}
+
+func (m *HardwareKeyMapping) Matches(e KeyEvent) bool {
+	if m.Key != 0 {
+		return m.Key == e.Key
+	}
+	return m.Scancode == e.Scancode
+}
+
+func (m *HardwareKeyMapping) KeyName() string {
+	if m.Key != 0 {
+		if n, ok := keypadKeyNames[glfw.Key(m.Key)]; ok {
+			return n
+		}
+		if n := glfw.GetKeyName(glfw.Key(m.Key), m.Scancode); n != "" {
+			return strings.ToUpper(n)
+		}
+		return fmt.Sprintf("Key %d", m.Key)
+	}
+	return fmt.Sprintf("Scancode %d", m.Scancode)
+}
+
+// Apply updates the keyboard state to reflect the mapped keys that were
+// pressed. It returns true if the slew key was pressed.
+func (hk *HardwareKeyboardConfig) Apply(events []KeyEvent, k *KeyboardState) (slew bool) {
+	if !hk.Enabled || k == nil {
+		return
+	}
+
+	for _, e := range events {
+		for _, m := range hk.Mappings {
+			if !m.Matches(e) {
+				continue
+			}
+
+			// Don't also enter any characters the key generated.
+			if e.Chars != "" {
+				k.Input = strings.Replace(k.Input, e.Chars, "", 1)
+			}
+
+			slew = applyKeyAction(m.Action, m.Function, m.Text, k) || slew
+			break
+		}
+	}
+	return
+}
+
+// applyKeyAction updates the keyboard state for one of the
+// HardwareKeyAction* actions; it returns true if the action is a slew.
+func applyKeyAction(action, function, text string, k *KeyboardState) bool {
+	switch action {
+	case HardwareKeyActionFunction:
+		for _, f := range keyFunctions {
+			if f.Name == function {
+				k.Pressed[f.Default.Key] = nil
+				k.setModifier(KeyControl, f.Default.Control)
+				k.setModifier(KeyAlt, f.Default.Alt)
+				k.setModifier(KeyShift, f.Default.Shift)
+			}
+		}
+	case HardwareKeyActionText:
+		k.Input += strings.ToUpper(text)
+	case HardwareKeyActionSlew:
+		return true
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Hardware keyboard settings
+
+var hardwareKeyboardEditor struct {
+	visible bool
+	// Index of the mapping for which the next key press is recorded;
+	// -1 if none.
+	learn int
+}
+
+func uiToggleShowHardwareKeyboardEditor() {
+	hardwareKeyboardEditor.visible = !hardwareKeyboardEditor.visible
+	hardwareKeyboardEditor.learn = -1
+}
+
+// hardwareKeyboardLearning reports whether the editor is waiting for a
+// key press, in which case keyboard input shouldn't go to the panes.
+func hardwareKeyboardLearning() bool {
+	return hardwareKeyboardEditor.visible && hardwareKeyboardEditor.learn >= 0
+}
+
+func uiDrawHardwareKeyboardEditor(p Platform) {
+	ed := &hardwareKeyboardEditor
+	if !ed.visible {
+		return
+	}
+	hk := &globalConfig.HardwareKeyboard
+
+	if ed.learn >= 0 && ed.learn < len(hk.Mappings) {
+		if events := p.KeyEvents(); len(events) > 0 {
+			e := events[0]
+			m := &hk.Mappings[ed.learn]
+			// Use the key code if GLFW knows the key since it's
+			// portable; otherwise fall back to the scancode.
+			if e.Key != int(glfw.KeyUnknown) {
+				m.Key, m.Scancode = e.Key, e.Scancode
+			} else {
+				m.Key, m.Scancode = 0, e.Scancode
+			}
+			ed.learn = -1
+		}
+	}
+
+	imgui.BeginV("Keypad and Hardware Keyboard", &ed.visible, imgui.WindowFlagsAlwaysAutoResize)
+
+	imgui.Checkbox("Enable keypad / hardware keyboard mappings", &hk.Enabled)
+
+	uiStartDisable(!hk.Enabled)
+	if imgui.BeginComboV("Load preset", "", imgui.ComboFlagsNoPreview) {
+		for _, name := range SortedMapKeys(hardwareKeyboardPresets) {
+			if imgui.Selectable(name) {
+				hk.Mappings = DuplicateSlice(hardwareKeyboardPresets[name])
+				ed.learn = -1
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	for i := 0; i < len(hk.Mappings); i++ {
+		m := &hk.Mappings[i]
+		imgui.PushID(fmt.Sprintf("mapping%d", i))
+
+		label := m.KeyName()
+		if ed.learn == i {
+			label = "Press a key..."
+		}
+		if imgui.Button(label) {
+			ed.learn = i
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Click and then press a key to change it")
+		}
+
+		imgui.SameLine()
+		uiDrawKeyActionEditor(&m.Action, &m.Function, &m.Text)
+
+		imgui.SameLine()
+		if imgui.Button(FontAwesomeIconTrash) {
+			hk.Mappings = DeleteSliceElement(hk.Mappings, i)
+			ed.learn = -1
+		}
+		imgui.PopID()
+	}
+
+	if imgui.Button("Add mapping") {
+		hk.Mappings = append(hk.Mappings, HardwareKeyMapping{Action: HardwareKeyActionFunction})
+		ed.learn = len(hk.Mappings) - 1
+	}
+	uiEndDisable(!hk.Enabled)
+
+	imgui.End()
+}
+
+// uiDrawKeyActionEditor draws widgets for choosing one of the
+// HardwareKeyAction* actions and its parameters.
+func uiDrawKeyActionEditor(action, function, text *string) {
+	imgui.SetNextItemWidth(100)
+	if imgui.BeginComboV("##action", *action, 0) {
+		for _, a := range []string{HardwareKeyActionFunction, HardwareKeyActionText, HardwareKeyActionSlew} {
+			if imgui.SelectableV(a, a == *action, 0, imgui.Vec2{}) {
+				*action = a
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	imgui.SameLine()
+	switch *action {
+	case HardwareKeyActionFunction:
+		imgui.SetNextItemWidth(200)
+		if imgui.BeginComboV("##function", *function, imgui.ComboFlagsHeightLarge) {
+			for _, f := range keyFunctions {
+				if imgui.SelectableV(f.Name, f.Name == *function, 0, imgui.Vec2{}) {
+					*function = f.Name
+				}
+			}
+			imgui.EndCombo()
+		}
+	case HardwareKeyActionText:
+		imgui.SetNextItemWidth(200)
+		imgui.InputTextV("##text", text, imgui.InputTextFlagsCharsUppercase, nil)
+	}
+}
@@ -0,0 +1,278 @@
+// instructor.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Instructor tools for setting up training situations: aircraft can be
+// moved to a new position, altitude, heading, or speed, given a new
+// route, frozen in place, or duplicated. Edits may only be made by the
+// launch controller, if there is one, as with deleting aircraft.
+//
+// In the messages pane, edits are entered as "!CALLSIGN EDIT...", where
+// each EDIT is one of the following:
+//
+// POS <fix or lat-long>: move the aircraft to the given location
+// ALT <altitude>: set the altitude, in hundreds of feet
+// HDG <heading>: set the heading
+// SPD <speed>: set the indicated airspeed, in knots
+// RTE <fix>...: fly direct to the first fix and then the rest; must be
+// the last edit, as it consumes the remaining fixes
+// FREEZE, UNFREEZE: stop or resume updating the aircraft's position
+// NORDO, COMMS: lose or restore communications (see lostcomms.go)
+// EQ <suffix>: set the flight plan's equipment suffix (see equipment.go)
+// FUEL <minutes>: set the fuel remaining above the reserve (see fuel.go)
+// DUP <callsign>: create a copy of the aircraft with the given callsign;
+// the other edits are applied to the copy.
+//
+// For example, "!AAL123 DUP AAL456 POS CAMRN ALT 80 HDG 270" makes a copy
+// of AAL123 at CAMRN at 8,000' heading 270.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// AircraftEdit describes changes to make to an aircraft; zero-valued
+// fields are left unchanged.
+type AircraftEdit struct {
+	Position  string // fix or lat-long
+	Altitude  *float32
+	Heading   *float32
+	Speed     *float32
+	Route     []string
+	Freeze    *bool
+	LostComms *bool
+	Equipment string   // equipment suffix
+	Fuel      *float32 // minutes above the reserve
+	Duplicate string   // callsign for the copy
+}
+
+func (e AircraftEdit) IsEmpty() bool {
+	return e.Position == "" && e.Altitude == nil && e.Heading == nil && e.Speed == nil &&
+		len(e.Route) == 0 && e.Freeze == nil && e.LostComms == nil && e.Equipment == "" && e.Fuel == nil &&
+		e.Duplicate == ""
+}
+
+// ParseAircraftEdit parses the space-separated edits that follow the
+// callsign in an instructor command.
+func ParseAircraftEdit(s string) (AircraftEdit, error) {
+	var edit AircraftEdit
+	f := strings.Fields(strings.ToUpper(s))
+
+	number := func(i int, lo, hi float32) (*float32, error) {
+		if i+1 >= len(f) {
+			return nil, fmt.Errorf("%s: missing value", f[i])
+		}
+		v, err := strconv.ParseFloat(f[i+1], 32)
+		if err != nil || float32(v) < lo || float32(v) > hi {
+			return nil, fmt.Errorf("%s %s: invalid value", f[i], f[i+1])
+		}
+		v32 := float32(v)
+		return &v32, nil
+	}
+
+	for i := 0; i < len(f); i++ {
+		var err error
+		switch f[i] {
+		case "POS":
+			if i+1 >= len(f) {
+				return edit, fmt.Errorf("%s: missing location", f[i])
+			}
+			edit.Position = f[i+1]
+			i++
+
+		case "ALT":
+			if edit.Altitude, err = number(i, 0, 600); err != nil {
+				return edit, err
+			}
+			*edit.Altitude *= 100
+			i++
+
+		case "HDG":
+			if edit.Heading, err = number(i, 1, 360); err != nil {
+				return edit, err
+			}
+			i++
+
+		case "SPD":
+			if edit.Speed, err = number(i, 0, 600); err != nil {
+				return edit, err
+			}
+			i++
+
+		case "RTE":
+			if i+1 >= len(f) {
+				return edit, fmt.Errorf("%s: missing route", f[i])
+			}
+			edit.Route = f[i+1:]
+			i = len(f)
+
+		case "FREEZE", "UNFREEZE":
+			freeze := f[i] == "FREEZE"
+			edit.Freeze = &freeze
+
+		case "NORDO", "COMMS":
+			nordo := f[i] == "NORDO"
+			edit.LostComms = &nordo
+
+		case "EQ":
+			if i+1 >= len(f) {
+				return edit, fmt.Errorf("%s: missing suffix", f[i])
+			} else if _, ok := equipmentSuffixes[f[i+1]]; !ok {
+				return edit, fmt.Errorf("%s %s: unknown equipment suffix", f[i], f[i+1])
+			}
+			edit.Equipment = f[i+1]
+			i++
+
+		case "FUEL":
+			if edit.Fuel, err = number(i, 0, 600); err != nil {
+				return edit, err
+			}
+			i++
+
+		case "DUP":
+			if i+1 >= len(f) {
+				return edit, fmt.Errorf("%s: missing callsign", f[i])
+			}
+			edit.Duplicate = f[i+1]
+			i++
+
+		default:
+			return edit, fmt.Errorf("%s: unknown edit", f[i])
+		}
+	}
+
+	if edit.IsEmpty() {
+		return edit, ErrInvalidAircraftEdit
+	}
+	return edit, nil
+}
+
+// EditAircraft applies the given edits to the aircraft or, if a duplicate
+// is requested, to a copy of it.
+func (s *Sim) EditAircraft(token, callsign string, edit AircraftEdit) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	var pos Point2LL
+	var route []Waypoint
+	return s.dispatchCommand(token, callsign,
+		func(ctrl *Controller, ac *Aircraft) error {
+			if lctrl := s.LaunchConfig.Controller; lctrl != "" && lctrl != ctrl.Callsign {
+				return ErrNotLaunchController
+			}
+			if edit.Duplicate != "" {
+				if _, ok := s.World.Aircraft[edit.Duplicate]; ok {
+					return ErrCallsignInUse
+				}
+			}
+			if edit.Position != "" {
+				var ok bool
+				if pos, ok = s.World.Locate(edit.Position); !ok {
+					return ErrInvalidAircraftEdit
+				}
+			}
+			for _, fix := range edit.Route {
+				if p, ok := s.World.Locate(fix); !ok {
+					return ErrInvalidAircraftEdit
+				} else {
+					route = append(route, Waypoint{Fix: fix, Location: p})
+				}
+			}
+			return nil
+		},
+		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
+			if edit.Duplicate != "" {
+				dup, err := duplicateAircraft(ac, edit.Duplicate)
+				if err != nil {
+					s.lg.Errorf("%s: unable to duplicate: %v", ac.Callsign, err)
+					return nil
+				}
+				s.World.Aircraft[dup.Callsign] = dup
+				if dup.IsDeparture() {
+					s.TotalDepartures++
+				} else {
+					s.TotalArrivals++
+				}
+				s.lg.Info("duplicated aircraft", slog.String("callsign", ac.Callsign),
+					slog.String("duplicate", dup.Callsign))
+				ac = dup
+			}
+
+			nav := &ac.Nav
+			if edit.Position != "" {
+				nav.FlightState.Position = pos
+			}
+			if edit.Altitude != nil {
+				alt := *edit.Altitude
+				nav.FlightState.Altitude = alt
+				nav.Altitude = NavAltitude{Assigned: &alt}
+			}
+			if edit.Heading != nil {
+				hdg := *edit.Heading
+				nav.FlightState.Heading = hdg
+				nav.Heading = NavHeading{Assigned: &hdg}
+			}
+			if edit.Speed != nil {
+				spd := *edit.Speed
+				nav.FlightState.IAS = spd
+				nav.Speed = NavSpeed{Assigned: &spd}
+			}
+			if len(route) > 0 {
+				nav.Waypoints = route
+				nav.Heading = NavHeading{}
+				if ac.FlightPlan != nil {
+					ac.FlightPlan.Route = strings.Join(edit.Route, " ")
+				}
+			}
+			if edit.Freeze != nil {
+				ac.Frozen = *edit.Freeze
+			}
+			if edit.Equipment != "" && ac.FlightPlan != nil {
+				ac.FlightPlan.SetEquipmentSuffix(edit.Equipment)
+			}
+			if edit.Fuel != nil {
+				if ac.Fuel == nil {
+					ac.Fuel = &FuelState{}
+				}
+				ac.Fuel.Minutes = fuelReserveMinutes + *edit.Fuel
+				// Let the pilot report again if it's still low.
+				ac.Fuel.Advised = min(ac.Fuel.Advised, ac.Fuel.Status())
+			}
+			if edit.LostComms != nil {
+				if *edit.LostComms {
+					s.loseComms(ac)
+				} else {
+					s.restoreComms(ac)
+				}
+			}
+
+			s.lg.Info("edited aircraft", slog.String("callsign", ac.Callsign),
+				slog.String("controller", ctrl.Callsign), slog.Any("aircraft", ac))
+			return nil
+		})
+}
+
+// duplicateAircraft returns a deep copy of the aircraft with the given
+// callsign and a new beacon code.
+func duplicateAircraft(ac *Aircraft, callsign string) (*Aircraft, error) {
+	b, err := json.Marshal(ac)
+	if err != nil {
+		return nil, err
+	}
+	var dup Aircraft
+	if err := json.Unmarshal(b, &dup); err != nil {
+		return nil, err
+	}
+
+	dup.Callsign = callsign
+	dup.AssignedSquawk = Squawk(rand.Intn(0o7000))
+	dup.Squawk = Select(dup.LostComms, Squawk(0o7600), dup.AssignedSquawk)
+	dup.HandoffTrackController = ""
+	dup.PointOutHistory = nil
+	return &dup, nil
+}
@@ -0,0 +1,53 @@
+// instructor_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseAircraftEdit(t *testing.T) {
+	edit, err := ParseAircraftEdit("dup aal456 pos camrn alt 80 hdg 270 spd 250 freeze rte camrn deer park")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edit.Duplicate != "AAL456" || edit.Position != "CAMRN" {
+		t.Errorf("got duplicate %q position %q", edit.Duplicate, edit.Position)
+	}
+	if edit.Altitude == nil || *edit.Altitude != 8000 {
+		t.Errorf("expected altitude 8000, got %v", edit.Altitude)
+	}
+	if edit.Heading == nil || *edit.Heading != 270 || edit.Speed == nil || *edit.Speed != 250 {
+		t.Errorf("expected heading 270 and speed 250, got %v %v", edit.Heading, edit.Speed)
+	}
+	if edit.Freeze == nil || !*edit.Freeze {
+		t.Errorf("expected freeze")
+	}
+	if !slices.Equal(edit.Route, []string{"CAMRN", "DEER", "PARK"}) {
+		t.Errorf("got route %v", edit.Route)
+	}
+
+	if edit, err := ParseAircraftEdit("UNFREEZE"); err != nil || edit.Freeze == nil || *edit.Freeze {
+		t.Errorf("expected unfreeze, got %+v %v", edit, err)
+	}
+	if edit, err := ParseAircraftEdit("nordo"); err != nil || edit.LostComms == nil || !*edit.LostComms {
+		t.Errorf("expected lost comms, got %+v %v", edit, err)
+	}
+
+	if edit, err := ParseAircraftEdit("fuel 12"); err != nil || edit.Fuel == nil || *edit.Fuel != 12 {
+		t.Errorf("expected 12 minutes of fuel, got %+v %v", edit, err)
+	}
+	if edit, err := ParseAircraftEdit("eq u"); err != nil || edit.Equipment != "U" {
+		t.Errorf("expected equipment suffix U, got %+v %v", edit, err)
+	}
+
+	for _, bad := range []string{"", "ALT", "ALT xyz", "HDG 400", "SPD -10", "POS", "RTE", "DUP", "CLIMB 50",
+		"EQ", "EQ Q"} {
+		if _, err := ParseAircraftEdit(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// journal.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+// JournalEntry is a single Event recorded in an EventJournal along with
+// the time at which it was posted.
+type JournalEntry struct {
+	Time  time.Time
+	Event Event
+}
+
+// EventJournal records all events posted to an EventStream so that they
+// can be queried after the fact (e.g., for a searchable message history
+// or for post-session analysis) without each consumer having to maintain
+// its own copy. The most recent events are kept in memory; optionally, all
+// events are also appended to a file on disk in JSON-lines format.
+type EventJournal struct {
+	mu      sync.Mutex
+	entries *RingBuffer[JournalEntry]
+	f       *os.File
+	w       *bufio.Writer
+	enc     *json.Encoder
+	lastErr time.Time
+}
+
+// EventJournalQuery specifies which entries should be returned by
+// EventJournal Query. Zero-valued fields are ignored.
+type EventJournalQuery struct {
+	Start, End time.Time
+	Callsign   string
+	Types      []EventType
+	// Limit gives the maximum number of (most recent) entries to return.
+	Limit int
+}
+
+// NewEventJournal returns a new EventJournal that holds up to capacity
+// entries in memory. If filename is non-empty, entries are also appended
+// to the given file.
+func NewEventJournal(capacity int, filename string) (*EventJournal, error) {
+	j := &EventJournal{entries: NewRingBuffer[JournalEntry](capacity)}
+
+	if filename != "" {
+		f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return j, err
+		}
+		j.f = f
+		j.w = bufio.NewWriter(f)
+		j.enc = json.NewEncoder(j.w)
+	}
+
+	return j, nil
+}
+
+// Record adds the given event to the journal.
+func (j *EventJournal) Record(e Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := JournalEntry{Time: time.Now(), Event: e}
+	j.entries.Add(entry)
+
+	if j.enc != nil {
+		if err := j.enc.Encode(entry); err != nil && time.Since(j.lastErr) > time.Minute {
+			// Don't flood the log if the disk fills up, etc.
+			j.lastErr = time.Now()
+			lg.Warnf("unable to write event journal: %v", err)
+		}
+	}
+}
+
+// Flush writes any buffered entries to disk.
+func (j *EventJournal) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.w == nil {
+		return nil
+	}
+	return j.w.Flush()
+}
+
+// Close flushes and closes the on-disk journal, if there is one. The
+// in-memory entries remain available for queries.
+func (j *EventJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return nil
+	}
+
+	err := j.w.Flush()
+	if cerr := j.f.Close(); err == nil {
+		err = cerr
+	}
+	j.f, j.w, j.enc = nil, nil, nil
+	return err
+}
+
+func (q *EventJournalQuery) matches(e *JournalEntry) bool {
+	if !q.Start.IsZero() && e.Time.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && e.Time.After(q.End) {
+		return false
+	}
+	if q.Callsign != "" && e.Event.Callsign != q.Callsign {
+		return false
+	}
+	if len(q.Types) > 0 && !slices.Contains(q.Types, e.Event.Type) {
+		return false
+	}
+	return true
+}
+
+// Query returns the in-memory journal entries that match the given query,
+// ordered from oldest to newest.
+func (j *EventJournal) Query(q EventJournalQuery) []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var result []JournalEntry
+	// Walk backward from the newest so that Limit keeps the most recent
+	// matches.
+	for i := j.entries.Size() - 1; i >= 0; i-- {
+		e := j.entries.Get(i)
+		if q.matches(&e) {
+			result = append(result, e)
+			if q.Limit > 0 && len(result) == q.Limit {
+				break
+			}
+		}
+	}
+	slices.Reverse(result)
+	return result
+}
+
+// ReadEventJournal reads all of the entries from an on-disk journal that
+// match the given query; it is intended for post-session analysis.
+func ReadEventJournal(r io.Reader, q EventJournalQuery) ([]JournalEntry, error) {
+	var result []JournalEntry
+	dec := json.NewDecoder(r)
+	for {
+		var e JournalEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return result, err
+		}
+		if q.matches(&e) {
+			result = append(result, e)
+		}
+	}
+
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[len(result)-q.Limit:]
+	}
+	return result, nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Event history window
+
+var eventHistory struct {
+	visible  bool
+	callsign string
+	types    [NumEventTypes]bool
+}
+
+func uiToggleShowEventHistoryWindow() {
+	eventHistory.visible = !eventHistory.visible
+}
+
+// uiDrawEventHistoryWindow draws a window that allows searching the
+// events recorded in the given journal.
+func uiDrawEventHistoryWindow(j *EventJournal) {
+	if !eventHistory.visible || j == nil {
+		return
+	}
+
+	imgui.BeginV("Event History", &eventHistory.visible, 0)
+
+	imgui.InputTextV("Callsign", &eventHistory.callsign, imgui.InputTextFlagsCharsUppercase, nil)
+	if imgui.CollapsingHeader("Event types") {
+		for i := range eventHistory.types {
+			imgui.Checkbox(EventType(i).String(), &eventHistory.types[i])
+		}
+	}
+
+	q := EventJournalQuery{Callsign: eventHistory.callsign, Limit: 500}
+	for i, show := range eventHistory.types {
+		if show {
+			q.Types = append(q.Types, EventType(i))
+		}
+	}
+
+	flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg |
+		imgui.TableFlagsSizingStretchProp | imgui.TableFlagsScrollY
+	if imgui.BeginTableV("history", 4, flags, imgui.Vec2{0, 400}, 0.) {
+		imgui.TableSetupColumn("Time")
+		imgui.TableSetupColumn("Type")
+		imgui.TableSetupColumn("Callsign")
+		imgui.TableSetupColumn("Details")
+		imgui.TableHeadersRow()
+
+		entries := j.Query(q)
+		for i := range entries {
+			// Most recent first
+			e := &entries[len(entries)-1-i]
+
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.Text(e.Time.Format("15:04:05"))
+			imgui.TableNextColumn()
+			imgui.Text(e.Event.Type.String())
+			imgui.TableNextColumn()
+			imgui.Text(e.Event.Callsign)
+			imgui.TableNextColumn()
+			details := e.Event.Message
+			if e.Event.FromController != "" || e.Event.ToController != "" {
+				details = e.Event.FromController + "->" + e.Event.ToController + " " + details
+			}
+			imgui.Text(details)
+		}
+		imgui.EndTable()
+	}
+
+	imgui.End()
+}
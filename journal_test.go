@@ -0,0 +1,54 @@
+// journal_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestEventJournalQuery(t *testing.T) {
+	j, err := NewEventJournal(4, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j.Record(Event{Type: InitiatedTrackEvent, Callsign: "AAL1"})
+	j.Record(Event{Type: DroppedTrackEvent, Callsign: "AAL1"})
+	j.Record(Event{Type: InitiatedTrackEvent, Callsign: "UAL2"})
+	j.Record(Event{Type: PointOutEvent, Callsign: "AAL1"})
+	j.Record(Event{Type: InitiatedTrackEvent, Callsign: "DAL3"}) // evicts the first one
+
+	if n := len(j.Query(EventJournalQuery{})); n != 4 {
+		t.Errorf("expected 4 entries, got %d", n)
+	}
+
+	aal := j.Query(EventJournalQuery{Callsign: "AAL1"})
+	if len(aal) != 2 || aal[0].Event.Type != DroppedTrackEvent || aal[1].Event.Type != PointOutEvent {
+		t.Errorf("unexpected callsign query result %+v", aal)
+	}
+
+	it := j.Query(EventJournalQuery{Types: []EventType{InitiatedTrackEvent}, Limit: 1})
+	if len(it) != 1 || it[0].Event.Callsign != "DAL3" {
+		t.Errorf("expected most recent initiated track, got %+v", it)
+	}
+}
+
+func TestReadEventJournal(t *testing.T) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, cs := range []string{"AAL1", "UAL2", "AAL1"} {
+		enc.Encode(JournalEntry{Event: Event{Type: IdentEvent, Callsign: cs}})
+	}
+
+	entries, err := ReadEventJournal(&buf, EventJournalQuery{Callsign: "AAL1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(entries))
+	}
+}
@@ -0,0 +1,370 @@
+// keybindings.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// KeyBindings allow the keys that invoke the scope's functions to be
+// changed and allow users to define command aliases and keyboard macros.
+// Remapping is done by translating the keyboard state before it is given
+// to the panes: when a function's new key is pressed, the panes see the
+// function's default key instead (and pressing the default key does
+// nothing). Aliases are expanded when a command is entered; macros enter
+// text as if it had been typed.
+
+import (
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+// KeyChord is a non-modifier key along with the modifier keys that must be
+// held when it is pressed.
+type KeyChord struct {
+	Key     Key
+	Control bool
+	Alt     bool
+	Shift   bool
+}
+
+var keyNames = map[Key]string{
+	KeyEnter:      "Enter",
+	KeyUpArrow:    "Up",
+	KeyDownArrow:  "Down",
+	KeyLeftArrow:  "Left",
+	KeyRightArrow: "Right",
+	KeyHome:       "Home",
+	KeyEnd:        "End",
+	KeyBackspace:  "Backspace",
+	KeyDelete:     "Delete",
+	KeyEscape:     "Escape",
+	KeyTab:        "Tab",
+	KeyPageUp:     "PageUp",
+	KeyPageDown:   "PageDown",
+	KeyF1:         "F1",
+	KeyF2:         "F2",
+	KeyF3:         "F3",
+	KeyF4:         "F4",
+	KeyF5:         "F5",
+	KeyF6:         "F6",
+	KeyF7:         "F7",
+	KeyF8:         "F8",
+	KeyF9:         "F9",
+	KeyF10:        "F10",
+	KeyF11:        "F11",
+	KeyF12:        "F12",
+}
+
+func (k KeyChord) String() string {
+	s := ""
+	if k.Control {
+		s += "Ctrl-"
+	}
+	if k.Alt {
+		s += "Alt-"
+	}
+	if k.Shift {
+		s += "Shift-"
+	}
+	return s + keyNames[k.Key]
+}
+
+func isModifierKey(k Key) bool {
+	return k == KeyShift || k == KeyControl || k == KeyAlt
+}
+
+// pressedChords returns the chords corresponding to the non-modifier keys
+// that are currently pressed.
+func (k *KeyboardState) pressedChords() []KeyChord {
+	var chords []KeyChord
+	for key := range k.Pressed {
+		if !isModifierKey(key) {
+			chords = append(chords, KeyChord{
+				Key:     key,
+				Control: k.IsPressed(KeyControl),
+				Alt:     k.IsPressed(KeyAlt),
+				Shift:   k.IsPressed(KeyShift),
+			})
+		}
+	}
+	return chords
+}
+
+func (k *KeyboardState) setModifier(key Key, pressed bool) {
+	if pressed {
+		k.Pressed[key] = nil
+	} else {
+		delete(k.Pressed, key)
+	}
+}
+
+// KeyFunction is a scope function that is invoked from the keyboard.
+type KeyFunction struct {
+	Name    string
+	Default KeyChord
+}
+
+var keyFunctions = []KeyFunction{
+	{Name: "Enter", Default: KeyChord{Key: KeyEnter}},
+	{Name: "Clear", Default: KeyChord{Key: KeyEscape}},
+	{Name: "Min", Default: KeyChord{Key: KeyEnd}},
+	{Name: "Switch focus to messages", Default: KeyChord{Key: KeyTab}},
+	{Name: "Recenter scope", Default: KeyChord{Key: KeyF1, Control: true}},
+	{Name: "Maps menu", Default: KeyChord{Key: KeyF2, Control: true}},
+	{Name: "Brightness menu", Default: KeyChord{Key: KeyF3, Control: true}},
+	{Name: "Initiate control", Default: KeyChord{Key: KeyF3}},
+	{Name: "Leader line length", Default: KeyChord{Key: KeyF4, Control: true}},
+	{Name: "Terminate control", Default: KeyChord{Key: KeyF4}},
+	{Name: "Character size menu", Default: KeyChord{Key: KeyF5, Control: true}},
+	{Name: "Handoff", Default: KeyChord{Key: KeyF5}},
+	{Name: "Flight data", Default: KeyChord{Key: KeyF6}},
+	{Name: "Toggle auxiliary DCB menu", Default: KeyChord{Key: KeyF7, Control: true}},
+	{Name: "Multifunction", Default: KeyChord{Key: KeyF7}},
+	{Name: "Toggle DCB", Default: KeyChord{Key: KeyF8, Control: true}},
+	{Name: "Range rings", Default: KeyChord{Key: KeyF9, Control: true}},
+	{Name: "VFR plan", Default: KeyChord{Key: KeyF9}},
+	{Name: "Range", Default: KeyChord{Key: KeyF10, Control: true}},
+	{Name: "Site menu", Default: KeyChord{Key: KeyF11, Control: true}},
+	{Name: "Collision alert", Default: KeyChord{Key: KeyF11}},
+	{Name: "Screenshot", Default: KeyChord{Key: KeyF12, Control: true}},
+}
+
+// KeyMacro enters the given text when its chord is pressed; if Execute
+// is set, Enter is pressed afterward.
+type KeyMacro struct {
+	Chord   KeyChord
+	Text    string
+	Execute bool
+}
+
+type KeyBindings struct {
+	// Chords for functions that have been remapped, indexed by
+	// KeyFunction Name.
+	Remapped map[string]KeyChord
+	// Aliases map a word typed in a command to its expansion.
+	Aliases map[string]string
+	Macros  []KeyMacro
+}
+
+// Binding returns the chord that currently invokes the named function.
+func (kb *KeyBindings) Binding(f KeyFunction) KeyChord {
+	if c, ok := kb.Remapped[f.Name]; ok {
+		return c
+	}
+	return f.Default
+}
+
+// Apply translates the keyboard state according to the bindings.
+func (kb *KeyBindings) Apply(k *KeyboardState) {
+	if k == nil || (len(kb.Remapped) == 0 && len(kb.Macros) == 0) {
+		return
+	}
+
+	for _, chord := range k.pressedChords() {
+		for _, m := range kb.Macros {
+			if m.Chord == chord {
+				delete(k.Pressed, chord.Key)
+				k.Input += strings.ToUpper(m.Text)
+				if m.Execute {
+					k.Pressed[KeyEnter] = nil
+				}
+			}
+		}
+
+		var translated *KeyChord
+		for _, f := range keyFunctions {
+			if kb.Binding(f) == chord {
+				translated = &f.Default
+				break
+			} else if f.Default == chord {
+				if _, ok := kb.Remapped[f.Name]; ok {
+					// The default key for a function that has been
+					// moved elsewhere does nothing.
+					delete(k.Pressed, chord.Key)
+				}
+			}
+		}
+		if translated != nil {
+			delete(k.Pressed, chord.Key)
+			k.Pressed[translated.Key] = nil
+			k.setModifier(KeyControl, translated.Control)
+			k.setModifier(KeyAlt, translated.Alt)
+			k.setModifier(KeyShift, translated.Shift)
+		}
+	}
+}
+
+// ExpandAliases returns the given command with any aliases replaced with
+// their expansions.
+func (kb *KeyBindings) ExpandAliases(cmd string) string {
+	if len(kb.Aliases) == 0 {
+		return cmd
+	}
+
+	fields := strings.Split(cmd, " ")
+	for i, f := range fields {
+		for alias, expansion := range kb.Aliases {
+			if strings.EqualFold(f, alias) {
+				fields[i] = strings.ToUpper(expansion)
+				break
+			}
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func (kb KeyBindings) Duplicate() KeyBindings {
+	return KeyBindings{
+		Remapped: DuplicateMap(kb.Remapped),
+		Aliases:  DuplicateMap(kb.Aliases),
+		Macros:   DuplicateSlice(kb.Macros),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Key bindings editor
+
+var keyBindingsEditor struct {
+	visible bool
+	// When capturing, the next key pressed is assigned to the function
+	// or macro given by capture, which is an index into keyFunctions or,
+	// if captureMacro is set, into KeyBindings.Macros.
+	capturing    bool
+	capture      int
+	captureMacro bool
+
+	newAlias, newExpansion string
+}
+
+func uiToggleShowKeyBindingsEditor() {
+	keyBindingsEditor.visible = !keyBindingsEditor.visible
+	keyBindingsEditor.capturing = false
+}
+
+// captureChord returns the chord pressed in the current frame, if any.
+func captureChord(p Platform) (KeyChord, bool) {
+	for _, c := range NewKeyboardState(p).pressedChords() {
+		return c, true
+	}
+	return KeyChord{}, false
+}
+
+func uiDrawKeyBindingsEditor(p Platform) {
+	if !keyBindingsEditor.visible {
+		return
+	}
+	kb := &globalConfig.KeyBindings
+	ed := &keyBindingsEditor
+
+	if ed.capturing {
+		if c, ok := captureChord(p); ok {
+			if c.Key == KeyEscape && !c.Control && !c.Alt && !c.Shift {
+				// cancel
+			} else if ed.captureMacro {
+				kb.Macros[ed.capture].Chord = c
+			} else {
+				if kb.Remapped == nil {
+					kb.Remapped = make(map[string]KeyChord)
+				}
+				f := keyFunctions[ed.capture]
+				if c == f.Default {
+					delete(kb.Remapped, f.Name)
+				} else {
+					kb.Remapped[f.Name] = c
+				}
+			}
+			ed.capturing = false
+		}
+	}
+
+	imgui.BeginV("Key Bindings", &ed.visible, imgui.WindowFlagsAlwaysAutoResize)
+
+	bindingButton := func(chord KeyChord, idx int, macro bool) {
+		label := chord.String()
+		if ed.capturing && ed.capture == idx && ed.captureMacro == macro {
+			label = "Press a key..."
+		}
+		if imgui.Button(label) {
+			ed.capturing = true
+			ed.capture = idx
+			ed.captureMacro = macro
+		}
+	}
+
+	flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg |
+		imgui.TableFlagsSizingStretchProp
+	if imgui.CollapsingHeader("Scope functions") && imgui.BeginTableV("functions", 3, flags, imgui.Vec2{}, 0.) {
+		for i, f := range keyFunctions {
+			imgui.PushID(f.Name)
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.Text(f.Name)
+			imgui.TableNextColumn()
+			bindingButton(kb.Binding(f), i, false)
+			imgui.TableNextColumn()
+			if _, ok := kb.Remapped[f.Name]; ok {
+				if imgui.Button(FontAwesomeIconRedo) {
+					delete(kb.Remapped, f.Name)
+				}
+				if imgui.IsItemHovered() {
+					imgui.SetTooltip("Reset to " + f.Default.String())
+				}
+			}
+			imgui.PopID()
+		}
+		imgui.EndTable()
+	}
+
+	if imgui.CollapsingHeader("Macros") {
+		for i := 0; i < len(kb.Macros); i++ {
+			m := &kb.Macros[i]
+			imgui.PushID(strings.Repeat("m", i+1))
+			bindingButton(m.Chord, i, true)
+			imgui.SameLine()
+			imgui.SetNextItemWidth(250)
+			imgui.InputTextV("##text", &m.Text, 0, nil)
+			imgui.SameLine()
+			imgui.Checkbox("Execute", &m.Execute)
+			imgui.SameLine()
+			if imgui.Button(FontAwesomeIconTrash) {
+				kb.Macros = DeleteSliceElement(kb.Macros, i)
+				ed.capturing = false
+			}
+			imgui.PopID()
+		}
+		if imgui.Button("Add macro") {
+			kb.Macros = append(kb.Macros, KeyMacro{Chord: KeyChord{Key: KeyF12, Shift: true}})
+		}
+	}
+
+	if imgui.CollapsingHeader("Command aliases") {
+		for _, alias := range SortedMapKeys(kb.Aliases) {
+			imgui.PushID(alias)
+			imgui.Text(alias + " " + FontAwesomeIconArrowRight + " " + kb.Aliases[alias])
+			imgui.SameLine()
+			if imgui.Button(FontAwesomeIconTrash) {
+				delete(kb.Aliases, alias)
+			}
+			imgui.PopID()
+		}
+
+		imgui.SetNextItemWidth(80)
+		imgui.InputTextV("##alias", &ed.newAlias, imgui.InputTextFlagsCharsUppercase|imgui.InputTextFlagsCharsNoBlank, nil)
+		imgui.SameLine()
+		imgui.SetNextItemWidth(250)
+		imgui.InputTextV("##expansion", &ed.newExpansion, imgui.InputTextFlagsCharsUppercase, nil)
+		imgui.SameLine()
+		disable := ed.newAlias == "" || strings.TrimSpace(ed.newExpansion) == ""
+		uiStartDisable(disable)
+		if imgui.Button("Add alias") {
+			if kb.Aliases == nil {
+				kb.Aliases = make(map[string]string)
+			}
+			kb.Aliases[ed.newAlias] = strings.TrimSpace(ed.newExpansion)
+			ed.newAlias, ed.newExpansion = "", ""
+		}
+		uiEndDisable(disable)
+	}
+
+	imgui.End()
+}
@@ -0,0 +1,68 @@
+// keybindings_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func makeKeyboard(input string, keys ...Key) *KeyboardState {
+	k := &KeyboardState{Input: input, Pressed: make(map[Key]interface{})}
+	for _, key := range keys {
+		k.Pressed[key] = nil
+	}
+	return k
+}
+
+func TestKeyBindingsApply(t *testing.T) {
+	kb := KeyBindings{
+		Remapped: map[string]KeyChord{
+			// Handoff moves from F5 to Shift-F2
+			"Handoff": KeyChord{Key: KeyF2, Shift: true},
+		},
+		Macros: []KeyMacro{
+			KeyMacro{Chord: KeyChord{Key: KeyF12, Alt: true}, Text: "dvs s250", Execute: true},
+		},
+	}
+
+	k := makeKeyboard("", KeyF2, KeyShift)
+	kb.Apply(k)
+	if !k.IsPressed(KeyF5) || k.IsPressed(KeyF2) || k.IsPressed(KeyShift) {
+		t.Errorf("Shift-F2 not translated to F5: %+v", k.Pressed)
+	}
+
+	k = makeKeyboard("", KeyF5)
+	kb.Apply(k)
+	if k.IsPressed(KeyF5) {
+		t.Errorf("F5 should do nothing after handoff was remapped: %+v", k.Pressed)
+	}
+
+	// Ctrl-F5 is a different function and shouldn't be affected.
+	k = makeKeyboard("", KeyF5, KeyControl)
+	kb.Apply(k)
+	if !k.IsPressed(KeyF5) || !k.IsPressed(KeyControl) {
+		t.Errorf("Ctrl-F5 unexpectedly changed: %+v", k.Pressed)
+	}
+
+	k = makeKeyboard("", KeyF12, KeyAlt)
+	kb.Apply(k)
+	if k.Input != "DVS S250" || !k.IsPressed(KeyEnter) || k.IsPressed(KeyF12) {
+		t.Errorf("macro not applied: input %q, pressed %+v", k.Input, k.Pressed)
+	}
+}
+
+func TestExpandAliases(t *testing.T) {
+	kb := KeyBindings{Aliases: map[string]string{"DV": "dvs s250", "X": "C80"}}
+	for _, test := range []struct{ in, out string }{
+		{"DV", "DVS S250"},
+		{"dv X", "DVS S250 C80"},
+		{"DVS", "DVS"},
+		{"", ""},
+	} {
+		if out := kb.ExpandAliases(test.in); out != test.out {
+			t.Errorf("%q: got %q, expected %q", test.in, out, test.out)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// layouts.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Display layouts are named arrangements of panes--the split tree for the
+// main window as well as any separate pane windows--that can be switched
+// between from the menu bar or using Alt-F1 through Alt-F12. Unlike
+// profiles, layouts don't include other settings like the UI font size.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type DisplayLayout struct {
+	DisplayRoot *DisplayNode
+	PaneWindows []*PaneWindow
+	// Function key (1-12) that selects the layout when pressed with Alt;
+	// zero if there is no hotkey.
+	Hotkey int
+}
+
+// DisplayLayoutTemplate describes one of the built-in layouts.
+type DisplayLayoutTemplate struct {
+	Name string
+	Make func(w *World) *DisplayNode
+}
+
+var displayLayoutTemplates = []DisplayLayoutTemplate{
+	{
+		Name: "Single scope",
+		Make: func(w *World) *DisplayNode {
+			return &DisplayNode{
+				SplitLine: SplitLine{
+					Pos:  0.075,
+					Axis: SplitAxisY,
+				},
+				Children: [2]*DisplayNode{
+					&DisplayNode{Pane: NewMessagesPane()},
+					&DisplayNode{Pane: NewSTARSPane(w)},
+				},
+			}
+		},
+	},
+	{
+		Name: "Scope, messages, and flight strips",
+		Make: makeDefaultDisplayRoot,
+	},
+	{
+		// For clearance delivery and other flight data positions.
+		Name: "Flight data (FDIO) and flight strips",
+		Make: func(w *World) *DisplayNode {
+			fsp := NewFlightStripPane()
+			fsp.AutoAddDepartures = true
+
+			return &DisplayNode{
+				SplitLine: SplitLine{
+					Pos:  0.6,
+					Axis: SplitAxisX,
+				},
+				Children: [2]*DisplayNode{
+					&DisplayNode{
+						SplitLine: SplitLine{
+							Pos:  0.15,
+							Axis: SplitAxisY,
+						},
+						Children: [2]*DisplayNode{
+							&DisplayNode{Pane: NewMessagesPane()},
+							&DisplayNode{Pane: NewFDIOPane()},
+						},
+					},
+					&DisplayNode{Pane: fsp},
+				},
+			}
+		},
+	},
+}
+
+func copyPaneWindows(windows []*PaneWindow) ([]*PaneWindow, error) {
+	if len(windows) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(windows)
+	if err != nil {
+		return nil, err
+	}
+	var c []*PaneWindow
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// SaveLayout stores the current arrangement of panes under the given
+// name, replacing any existing layout with that name but preserving its
+// hotkey.
+func (gc *GlobalConfig) SaveLayout(name string) error {
+	if gc.DisplayRoot == nil {
+		return fmt.Errorf("no display configuration")
+	}
+	root, err := copyDisplayNode(gc.DisplayRoot)
+	if err != nil {
+		return err
+	}
+	windows, err := copyPaneWindows(gc.PaneWindows)
+	if err != nil {
+		return err
+	}
+
+	if gc.Layouts == nil {
+		gc.Layouts = make(map[string]*DisplayLayout)
+	}
+	hotkey := 0
+	if l, ok := gc.Layouts[name]; ok {
+		hotkey = l.Hotkey
+	}
+	gc.Layouts[name] = &DisplayLayout{
+		DisplayRoot: root,
+		PaneWindows: windows,
+		Hotkey:      hotkey,
+	}
+	gc.ActiveLayout = name
+	return nil
+}
+
+// LoadLayout switches to the named layout. The current arrangement is
+// first saved to the active layout so that changes to it aren't lost.
+func (gc *GlobalConfig) LoadLayout(name string, w *World, r Renderer, eventStream *EventStream) error {
+	l, ok := gc.Layouts[name]
+	if !ok {
+		return fmt.Errorf("%s: no such layout", name)
+	}
+	root, err := copyDisplayNode(l.DisplayRoot)
+	if err != nil {
+		return err
+	}
+	windows, err := copyPaneWindows(l.PaneWindows)
+	if err != nil {
+		return err
+	}
+
+	if gc.ActiveLayout != "" && gc.ActiveLayout != name {
+		if err := gc.SaveLayout(gc.ActiveLayout); err != nil {
+			lg.Warnf("%s: unable to save layout: %v", gc.ActiveLayout, err)
+		}
+	}
+
+	gc.setDisplay(root, windows, w, r, eventStream)
+	gc.ActiveLayout = name
+	lg.Infof("%s: loaded display layout", name)
+	return nil
+}
+
+// setDisplay replaces all of the current panes with the given ones.
+func (gc *GlobalConfig) setDisplay(root *DisplayNode, windows []*PaneWindow, w *World, r Renderer,
+	eventStream *EventStream) {
+	if gc.DisplayRoot != nil {
+		gc.DisplayRoot.VisitPanes(func(p Pane) { p.Deactivate() })
+	}
+	gc.ClosePaneWindows()
+
+	gc.DisplayRoot = root
+	gc.PaneWindows = windows
+	gc.Activate(w, r, eventStream)
+}
+
+// LayoutForHotkey returns the name of the layout that is selected with
+// Alt and the given function key, if any.
+func (gc *GlobalConfig) LayoutForHotkey(fkey int) (string, bool) {
+	for _, name := range SortedMapKeys(gc.Layouts) {
+		if gc.Layouts[name].Hotkey == fkey {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// wmCheckLayoutHotkeys checks whether a layout's hotkey has been pressed;
+// if so, the layout is switched to after the panes have been drawn and
+// the function key is removed from the keyboard state so that panes
+// don't also act on it.
+func wmCheckLayoutHotkeys(keyboard *KeyboardState) {
+	if keyboard == nil || !keyboard.IsPressed(KeyAlt) {
+		return
+	}
+	for i := 1; i <= 12; i++ {
+		key := Key(int(KeyF1) + i - 1)
+		if !keyboard.IsPressed(key) {
+			continue
+		}
+		if name, ok := globalConfig.LayoutForHotkey(i); ok {
+			wm.pendingLayout = name
+			delete(keyboard.Pressed, key)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Layouts UI
+
+var layoutsUI struct {
+	newName string
+}
+
+func uiApplyPendingLayout(w *World, r Renderer, eventStream *EventStream) {
+	if wm.pendingLayout == "" {
+		return
+	}
+	name := wm.pendingLayout
+	wm.pendingLayout = ""
+	if name == globalConfig.ActiveLayout {
+		return
+	}
+	if err := globalConfig.LoadLayout(name, w, r, eventStream); err != nil {
+		ShowErrorDialog("%s: unable to load layout: %v", name, err)
+	} else {
+		eventStream.Post(Event{Type: StatusMessageEvent, Message: "Switched to layout " + name})
+	}
+}
+
+func hotkeyName(fkey int) string {
+	if fkey == 0 {
+		return "(none)"
+	}
+	return fmt.Sprintf("Alt-F%d", fkey)
+}
+
+// uiDrawLayoutsMenu draws the menu bar menu for switching, saving, and
+// managing display layouts.
+func uiDrawLayoutsMenu(w *World, r Renderer, eventStream *EventStream) {
+	if imgui.BeginMenu(FontAwesomeIconColumns) {
+		for _, name := range SortedMapKeys(globalConfig.Layouts) {
+			l := globalConfig.Layouts[name]
+			shortcut := Select(l.Hotkey != 0, hotkeyName(l.Hotkey), "")
+			if imgui.MenuItemV(name, shortcut, name == globalConfig.ActiveLayout, true) {
+				wm.pendingLayout = name
+			}
+		}
+
+		if w != nil && imgui.BeginMenu("New from template") {
+			for _, t := range displayLayoutTemplates {
+				if imgui.MenuItem(t.Name) {
+					globalConfig.setDisplay(t.Make(w), nil, w, r, eventStream)
+					globalConfig.ActiveLayout = ""
+				}
+			}
+			imgui.EndMenu()
+		}
+
+		imgui.Separator()
+		imgui.InputTextV("##layoutname", &layoutsUI.newName, 0, nil)
+		imgui.SameLine()
+		name := strings.TrimSpace(layoutsUI.newName)
+		uiStartDisable(name == "")
+		if imgui.Button("Save current layout") {
+			if err := globalConfig.SaveLayout(name); err != nil {
+				ShowErrorDialog("%s: unable to save layout: %v", name, err)
+			}
+			layoutsUI.newName = ""
+		}
+		uiEndDisable(name == "")
+
+		if len(globalConfig.Layouts) > 0 && imgui.BeginMenu("Manage layouts") {
+			for _, name := range SortedMapKeys(globalConfig.Layouts) {
+				l := globalConfig.Layouts[name]
+				imgui.PushID(name)
+				if imgui.BeginMenu(name) {
+					if imgui.BeginMenu("Hotkey") {
+						for fkey := 0; fkey <= 12; fkey++ {
+							if imgui.MenuItemV(hotkeyName(fkey), "", l.Hotkey == fkey, true) {
+								// Hotkeys are unique
+								if other, ok := globalConfig.LayoutForHotkey(fkey); ok && fkey != 0 {
+									globalConfig.Layouts[other].Hotkey = 0
+								}
+								l.Hotkey = fkey
+							}
+						}
+						imgui.EndMenu()
+					}
+					if imgui.MenuItem("Update from current") {
+						if err := globalConfig.SaveLayout(name); err != nil {
+							ShowErrorDialog("%s: unable to save layout: %v", name, err)
+						}
+					}
+					if imgui.MenuItem("Delete") {
+						delete(globalConfig.Layouts, name)
+						if globalConfig.ActiveLayout == name {
+							globalConfig.ActiveLayout = ""
+						}
+					}
+					imgui.EndMenu()
+				}
+				imgui.PopID()
+			}
+			imgui.EndMenu()
+		}
+
+		imgui.EndMenu()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Display layouts")
+	}
+}
@@ -0,0 +1,131 @@
+// listscenarios.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Support for the -listscenarios command-line option, which prints a
+// summary of the scenarios that are available so that server operators
+// and scenario authors can see what a build of vice includes without
+// launching the GUI. The summary is printed as a table or, if -json is
+// given, as JSON.
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+type ScenarioGroupSummary struct {
+	TRACON           string                   `json:"tracon"`
+	Name             string                   `json:"name"`
+	PrimaryAirport   string                   `json:"primary_airport"`
+	Airports         []string                 `json:"airports"`
+	ControlPositions []ControlPositionSummary `json:"control_positions"`
+	DefaultScenario  string                   `json:"default_scenario"`
+	Scenarios        []ScenarioSummary        `json:"scenarios"`
+}
+
+type ControlPositionSummary struct {
+	Callsign  string `json:"callsign"`
+	Name      string `json:"name"`
+	Frequency string `json:"frequency"`
+}
+
+type ScenarioSummary struct {
+	Name             string   `json:"name"`
+	SoloController   string   `json:"solo_controller"`
+	DefaultSplit     string   `json:"default_split,omitempty"`
+	Splits           []string `json:"splits,omitempty"`
+	DepartureRunways []string `json:"departure_runways,omitempty"`
+	ArrivalRunways   []string `json:"arrival_runways,omitempty"`
+	Wind             Wind     `json:"wind"`
+	DefaultMaps      []string `json:"default_maps,omitempty"`
+}
+
+// summarizeScenarioGroups returns summaries of the provided scenario
+// groups, sorted by TRACON and then by name.
+func summarizeScenarioGroups(scenarioGroups map[string]map[string]*ScenarioGroup) []ScenarioGroupSummary {
+	var summaries []ScenarioGroupSummary
+	for _, tracon := range SortedMapKeys(scenarioGroups) {
+		for _, name := range SortedMapKeys(scenarioGroups[tracon]) {
+			sg := scenarioGroups[tracon][name]
+			sgs := ScenarioGroupSummary{
+				TRACON:          tracon,
+				Name:            name,
+				PrimaryAirport:  sg.PrimaryAirport,
+				Airports:        SortedMapKeys(sg.Airports),
+				DefaultScenario: sg.DefaultScenario,
+			}
+
+			for _, callsign := range SortedMapKeys(sg.ControlPositions) {
+				ctrl := sg.ControlPositions[callsign]
+				sgs.ControlPositions = append(sgs.ControlPositions, ControlPositionSummary{
+					Callsign:  callsign,
+					Name:      ctrl.FullName,
+					Frequency: ctrl.Frequency.String(),
+				})
+			}
+
+			for _, sname := range SortedMapKeys(sg.Scenarios) {
+				s := sg.Scenarios[sname]
+				sgs.Scenarios = append(sgs.Scenarios, ScenarioSummary{
+					Name:           sname,
+					SoloController: s.SoloController,
+					DefaultSplit:   s.DefaultSplit,
+					Splits:         SortedMapKeys(s.SplitConfigurations),
+					DepartureRunways: MapSlice(s.DepartureRunways, func(r ScenarioGroupDepartureRunway) string {
+						if r.Category != "" {
+							return r.Airport + "/" + r.Runway + "/" + r.Category
+						}
+						return r.Airport + "/" + r.Runway
+					}),
+					ArrivalRunways: MapSlice(s.ArrivalRunways, func(r ScenarioGroupArrivalRunway) string {
+						return r.Airport + "/" + r.Runway
+					}),
+					Wind:        s.Wind,
+					DefaultMaps: s.DefaultMaps,
+				})
+			}
+
+			summaries = append(summaries, sgs)
+		}
+	}
+	return summaries
+}
+
+// printScenarioSummaries writes the summaries as a table.
+func printScenarioSummaries(w io.Writer, summaries []ScenarioGroupSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for i, sgs := range summaries {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s: %s\n", sgs.TRACON, sgs.Name)
+		fmt.Fprintf(tw, "  Primary airport:\t%s\n", sgs.PrimaryAirport)
+		fmt.Fprintf(tw, "  Airports:\t%s\n", strings.Join(sgs.Airports, ", "))
+		fmt.Fprintf(tw, "  Default scenario:\t%s\n", sgs.DefaultScenario)
+
+		fmt.Fprintf(tw, "  Control positions:\n")
+		for _, cp := range sgs.ControlPositions {
+			fmt.Fprintf(tw, "    %s\t%s\t%s\n", cp.Callsign, cp.Frequency, cp.Name)
+		}
+
+		fmt.Fprintf(tw, "  Scenarios:\n")
+		fmt.Fprintf(tw, "    NAME\tCONTROLLER\tSPLITS\tDEPARTURES\tARRIVALS\tWIND\n")
+		for _, s := range sgs.Scenarios {
+			splits := strings.Join(s.Splits, ",")
+			if s.DefaultSplit != "" {
+				splits = s.DefaultSplit + " (" + splits + ")"
+			}
+			wind := fmt.Sprintf("%03d@%d", s.Wind.Direction, s.Wind.Speed)
+			if s.Wind.Gust > 0 {
+				wind += fmt.Sprintf("G%d", s.Wind.Gust)
+			}
+			fmt.Fprintf(tw, "    %s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.SoloController, splits,
+				strings.Join(s.DepartureRunways, ","), strings.Join(s.ArrivalRunways, ","), wind)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,138 @@
+// loading.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Loading the scenarios and video maps can take a while with large
+// facility packs. It's done in the background by the local sim server,
+// which reports its progress via startupProgress; until it finishes, the
+// main thread shows a splash screen with the current progress rather than
+// an empty window.
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+// LoadingProgress tracks the progress of a multi-stage loading process;
+// all of its methods are safe to call from multiple goroutines.
+type LoadingProgress struct {
+	mu          sync.Mutex
+	stage       string
+	done, total int
+	current     string
+	err         string
+}
+
+var startupProgress LoadingProgress
+
+// StartStage starts a new stage of loading with the given number of
+// items; total may be zero if it's not known.
+func (lp *LoadingProgress) StartStage(stage string, total int) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	lp.stage = stage
+	lp.done, lp.total = 0, total
+	lp.current = ""
+}
+
+// Loading records that the given item is now being loaded.
+func (lp *LoadingProgress) Loading(item string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.current = item
+}
+
+// Finished records that an item has been loaded.
+func (lp *LoadingProgress) Finished() {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.done++
+}
+
+// Fail records that loading failed with the given errors.
+func (lp *LoadingProgress) Fail(err string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.err = err
+}
+
+func (lp *LoadingProgress) get() (stage string, done, total int, current, err string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.stage, lp.done, lp.total, lp.current, lp.err
+}
+
+// waitForLocalServer shows the loading progress until the local sim
+// server is ready, which it then returns. If loading the scenarios fails,
+// the errors are shown and vice exits.
+func waitForLocalServer(r Renderer, p Platform, ch chan *SimServer) *SimServer {
+	for {
+		select {
+		case s := <-ch:
+			return s
+		default:
+		}
+
+		stage, done, total, current, err := startupProgress.get()
+		if err != "" {
+			ShowFatalErrorDialog(r, p, nil, "Errors were found when loading the scenarios:\n\n%s", err)
+			os.Exit(1)
+		}
+
+		p.ProcessEvents()
+		if p.ShouldStop() {
+			os.Exit(0)
+		}
+		p.NewFrame()
+		imgui.NewFrame()
+		imgui.PushFont(GetFont(FontIdentifier{Name: "Roboto Regular", Size: globalConfig.uiFontSize()}).ifont)
+		drawLoadingProgress(p, stage, done, total, current)
+		imgui.PopFont()
+
+		imgui.Render()
+		var cb CommandBuffer
+		GenerateImguiCommandBuffer(&cb)
+		r.RenderCommandBuffer(&cb)
+		p.PostRender()
+
+		// There's no need to redraw at full frame rate.
+		time.Sleep(33 * time.Millisecond)
+	}
+}
+
+func drawLoadingProgress(p Platform, stage string, done, total int, current string) {
+	ds := p.DisplaySize()
+	imgui.SetNextWindowPosV(imgui.Vec2{ds[0] / 2, ds[1] / 2}, imgui.ConditionAlways, imgui.Vec2{0.5, 0.5})
+	flags := imgui.WindowFlagsNoDecoration | imgui.WindowFlagsAlwaysAutoResize | imgui.WindowFlagsNoSavedSettings |
+		imgui.WindowFlagsNoMove
+	imgui.BeginV("Loading", nil, flags)
+
+	imgui.Text("Starting vice...")
+	imgui.Text(Select(stage != "", stage, "Loading"))
+
+	fraction := float32(0)
+	overlay := ""
+	if total > 0 {
+		fraction = float32(done) / float32(total)
+		overlay = fmt.Sprintf("%d / %d", done, total)
+	} else if done > 0 {
+		overlay = fmt.Sprintf("%d", done)
+	}
+	imgui.ProgressBarV(fraction, imgui.Vec2{400, 0}, overlay)
+
+	if current != "" {
+		imgui.Text(path.Base(current))
+	} else {
+		imgui.Text("")
+	}
+
+	imgui.End()
+}
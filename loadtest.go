@@ -0,0 +1,378 @@
+// loadtest.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Server load testing: the -loadtest option runs the given number of
+// synthetic controllers against the server given by -server, e.g.:
+//
+//	vice -loadtest 50 -loadtesttime 10m -server localhost:8000
+//
+// so that the capacity of the public server can be measured before busy
+// events. Each one connects separately, creates a password-protected
+// sim with a random scenario, and then acts like a controller would:
+// it requests world updates once a second as the client does, accepts
+// handoffs, and every so often issues heading, altitude, and speed
+// instructions to one of its aircraft. The clients are started
+// gradually over the first part of the test.
+//
+// At the end, the latency percentiles for each type of RPC are printed
+// along with the server's peak resource usage, which is sampled every
+// few seconds. The sims are created so that the server ends them once
+// their controller signs off. (The connections aren't closed, since
+// closing an RPCClient can hang; see SimProxy.SignOff.)
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Seconds between aircraft commands, on average, for each synthetic
+// controller.
+const loadTestCommandInterval = 10
+
+type LoadTestReport struct {
+	Server      string                `json:"server"`
+	Clients     int                   `json:"clients"`
+	Connected   int                   `json:"connected"`
+	Duration    string                `json:"duration"`
+	RPCs        []LoadTestRPCStats    `json:"rpcs"`
+	ServerUsage *LoadTestResourcePeak `json:"server_usage,omitempty"`
+}
+
+// LoadTestRPCStats summarizes the latency of one type of RPC; durations
+// are in milliseconds.
+type LoadTestRPCStats struct {
+	Method string  `json:"method"`
+	Calls  int     `json:"calls"`
+	Errors int     `json:"errors"`
+	P50    float64 `json:"p50_ms"`
+	P90    float64 `json:"p90_ms"`
+	P99    float64 `json:"p99_ms"`
+	Max    float64 `json:"max_ms"`
+}
+
+// LoadTestResourcePeak holds the largest values of each of the server's
+// resource usage statistics over the test.
+type LoadTestResourcePeak struct {
+	Samples       int    `json:"samples"`
+	CPUUsage      int    `json:"cpu_percent"`
+	AllocMemory   uint64 `json:"alloc_mb"`
+	SysMemory     uint64 `json:"sys_mb"`
+	NumGoRoutines int    `json:"goroutines"`
+	NumSims       int    `json:"sims"`
+}
+
+type loadTestStats struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration // RPC method -> latencies of successful calls
+	errors    map[string]int
+	usage     *LoadTestResourcePeak
+}
+
+func (s *loadTestStats) record(method string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.errors[method]++
+	} else {
+		s.latencies[method] = append(s.latencies[method], d)
+	}
+}
+
+func (s *loadTestStats) recordUsage(u ServerResourceUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.usage == nil {
+		s.usage = &LoadTestResourcePeak{}
+	}
+	p := s.usage
+	p.Samples++
+	p.CPUUsage = max(p.CPUUsage, u.CPUUsage)
+	p.AllocMemory = max(p.AllocMemory, u.AllocMemory)
+	p.SysMemory = max(p.SysMemory, u.SysMemory)
+	p.NumGoRoutines = max(p.NumGoRoutines, u.NumGoRoutines)
+	p.NumSims = max(p.NumSims, u.NumSims)
+}
+
+// rpcStats returns the latency statistics for each RPC method, sorted by
+// method.
+func (s *loadTestStats) rpcStats() []LoadTestRPCStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	methods := make(map[string]interface{})
+	for m := range s.latencies {
+		methods[m] = nil
+	}
+	for m := range s.errors {
+		methods[m] = nil
+	}
+
+	var stats []LoadTestRPCStats
+	for _, m := range SortedMapKeys(methods) {
+		lat := slices.Clone(s.latencies[m])
+		slices.Sort(lat)
+		ms := func(p float32) float64 {
+			if len(lat) == 0 {
+				return 0
+			}
+			d := lat[int(p*float32(len(lat)-1))]
+			return float64(d.Microseconds()) / 1000
+		}
+		stats = append(stats, LoadTestRPCStats{
+			Method: m,
+			Calls:  len(lat) + s.errors[m],
+			Errors: s.errors[m],
+			P50:    ms(.5),
+			P90:    ms(.9),
+			P99:    ms(.99),
+			Max:    ms(1),
+		})
+	}
+	return stats
+}
+
+// loadTestBot is a synthetic controller.
+type loadTestBot struct {
+	client   *RPCClient
+	token    string
+	callsign string
+	aircraft map[string]*Aircraft
+	r        *Rand
+	stats    *loadTestStats
+}
+
+func (b *loadTestBot) call(method string, args any, reply any) error {
+	start := time.Now()
+	err := b.client.CallWithTimeout(method, args, reply)
+	b.stats.record(method, time.Since(start), err)
+	return err
+}
+
+// connect signs on to the server and creates a sim for the bot.
+func (b *loadTestBot) connect(server string, index int) error {
+	var err error
+	if b.client, err = getClient(server); err != nil {
+		return err
+	}
+
+	var so SignOnResult
+	if err := b.call("SimManager.SignOn", ViceRPCVersion, &so); err != nil {
+		return err
+	}
+	if len(so.Configurations) == 0 {
+		return fmt.Errorf("%s: server has no scenarios", server)
+	}
+
+	tracons := SortedMapKeys(so.Configurations)
+	tracon := tracons[b.r.Intn(len(tracons))]
+	groups := SortedMapKeys(so.Configurations[tracon])
+	config := NewSimConfiguration{
+		NewSimType:      NewSimCreateRemote,
+		TRACONName:      tracon,
+		TRACON:          so.Configurations[tracon],
+		NewSimName:      fmt.Sprintf("Load test %d-%04x", index+1, b.r.Intn(0x10000)),
+		RequirePassword: true,
+		Password:        fmt.Sprintf("%08x", b.r.Int31n(0x7fffffff)),
+		ExitWhenEmpty:   true,
+	}
+	config.SetScenario(groups[b.r.Intn(len(groups))], "")
+
+	// Creating a sim includes running it for a while to spawn the
+	// initial aircraft, which can take longer than the usual RPC
+	// timeout, so we wait for as long as it takes.
+	var result NewSimResult
+	start := time.Now()
+	err = b.client.Call("SimManager.New", &config, &result)
+	b.stats.record("SimManager.New", time.Since(start), err)
+	if err != nil {
+		return err
+	}
+
+	b.token = result.ControllerToken
+	b.callsign = result.World.PrimaryController
+	b.aircraft = result.World.Aircraft
+	return nil
+}
+
+// run acts as a controller until the given time and then signs off.
+func (b *loadTestBot) run(end time.Time) {
+	nextCommand := time.Now().Add(b.commandWait())
+	for time.Now().Before(end) {
+		var wu SimWorldUpdate
+		if err := b.call("Sim.GetWorldUpdate", b.token, &wu); err == nil {
+			b.aircraft = wu.Aircraft
+			if wu.Callsign != "" {
+				b.callsign = wu.Callsign
+			}
+		}
+
+		for _, callsign := range SortedMapKeys(b.aircraft) {
+			if b.aircraft[callsign].HandoffTrackController == b.callsign {
+				b.call("Sim.AcceptHandoff", &AcceptHandoffArgs{
+					ControllerToken: b.token,
+					Callsign:        callsign,
+				}, nil)
+			}
+		}
+
+		if time.Now().After(nextCommand) {
+			b.issueCommand()
+			nextCommand = time.Now().Add(b.commandWait())
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	b.call("Sim.SignOff", b.token, nil)
+}
+
+func (b *loadTestBot) commandWait() time.Duration {
+	return time.Duration(1+b.r.Intn(2*loadTestCommandInterval)) * time.Second
+}
+
+// issueCommand gives a random instruction to one of the aircraft that the
+// bot is controlling.
+func (b *loadTestBot) issueCommand() {
+	var controlled []*Aircraft
+	for _, callsign := range SortedMapKeys(b.aircraft) {
+		if ac := b.aircraft[callsign]; ac.ControllingController == b.callsign {
+			controlled = append(controlled, ac)
+		}
+	}
+	if len(controlled) == 0 {
+		return
+	}
+	ac := controlled[b.r.Intn(len(controlled))]
+
+	var cmd string
+	switch b.r.Intn(3) {
+	case 0:
+		cmd = fmt.Sprintf("H%03d", 10*(1+b.r.Intn(36)))
+	case 1:
+		// Within 2,000' of the current altitude, in hundreds of feet.
+		alt := max(20, 10*(int(ac.Altitude()+500)/1000+b.r.Intn(5)-2))
+		cmd = Select(float32(alt*100) < ac.Altitude(), "D", "C") + fmt.Sprintf("%d", alt)
+	default:
+		cmd = fmt.Sprintf("S%d", 180+10*b.r.Intn(8))
+	}
+
+	var result AircraftCommandsResult
+	b.call("Sim.RunAircraftCommands", &AircraftCommandsArgs{
+		ControllerToken: b.token,
+		Callsign:        ac.Callsign,
+		Commands:        cmd,
+	}, &result)
+}
+
+// runLoadTest runs the load test, prints the results, and returns the
+// process exit code.
+func runLoadTest(server string, clients int, duration time.Duration, jsonOutput bool) int {
+	stats := &loadTestStats{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+	end := time.Now().Add(duration)
+
+	// Start the clients over the first 10% of the test (but no more than
+	// a minute) so that the server isn't hit with all of the sim
+	// creation requests at once.
+	ramp := min(duration/10, time.Minute) / time.Duration(clients)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	connected := 0
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * ramp)
+
+			b := &loadTestBot{r: NewRand(time.Now().UnixNano() + int64(i)), stats: stats}
+			if err := b.connect(server, i); err != nil {
+				lg.Errorf("load test client %d: %v", i+1, err)
+				return
+			}
+
+			mu.Lock()
+			connected++
+			mu.Unlock()
+
+			b.run(end)
+		}(i)
+	}
+
+	// Monitor the server's resource usage with a separate connection so
+	// that it isn't held up by the clients' requests.
+	done := make(chan struct{})
+	go func() {
+		client, err := getClient(server)
+		if err != nil {
+			lg.Errorf("load test monitor: %v", err)
+			return
+		}
+
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var u ServerResourceUsage
+				if err := client.CallWithTimeout("SimManager.GetResourceUsage", 0, &u); err != nil {
+					lg.Warnf("load test monitor: %v", err)
+				} else {
+					stats.recordUsage(u)
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+
+	stats.mu.Lock()
+	usage := stats.usage
+	stats.mu.Unlock()
+	report := LoadTestReport{
+		Server:      server,
+		Clients:     clients,
+		Connected:   connected,
+		Duration:    duration.String(),
+		RPCs:        stats.rpcStats(),
+		ServerUsage: usage,
+	}
+	if jsonOutput {
+		writeJSON(os.Stdout, report)
+	} else {
+		printLoadTestReport(os.Stdout, report)
+	}
+
+	return Select(connected == clients, 0, 1)
+}
+
+func printLoadTestReport(w io.Writer, r LoadTestReport) {
+	fmt.Fprintf(w, "%s: %d of %d clients connected, %s\n\n", r.Server, r.Connected, r.Clients, r.Duration)
+
+	fmt.Fprintf(w, "%-28s %8s %7s %9s %9s %9s %9s\n", "RPC", "Calls", "Errors", "p50 ms", "p90 ms", "p99 ms", "max ms")
+	for _, s := range r.RPCs {
+		fmt.Fprintf(w, "%-28s %8d %7d %9.1f %9.1f %9.1f %9.1f\n", s.Method, s.Calls, s.Errors,
+			s.P50, s.P90, s.P99, s.Max)
+	}
+
+	if u := r.ServerUsage; u != nil {
+		fmt.Fprintf(w, "\nPeak server usage (%d samples): CPU %d%%, %d MB allocated, %d MB system, %d goroutines, %d sims\n",
+			u.Samples, u.CPUUsage, u.AllocMemory, u.SysMemory, u.NumGoRoutines, u.NumSims)
+	} else {
+		fmt.Fprintln(w, "\nServer resource usage unavailable")
+	}
+}
@@ -0,0 +1,41 @@
+// loadtest_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadTestStats(t *testing.T) {
+	s := &loadTestStats{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+	for i := 100; i >= 1; i-- {
+		s.record("Sim.GetWorldUpdate", time.Duration(i)*time.Millisecond, nil)
+	}
+	s.record("Sim.GetWorldUpdate", 0, ErrRPCTimeout)
+	s.record("Sim.SignOff", 0, errors.New("connection reset"))
+
+	stats := s.rpcStats()
+	if len(stats) != 2 || stats[0].Method != "Sim.GetWorldUpdate" || stats[1].Method != "Sim.SignOff" {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	wu := stats[0]
+	if wu.Calls != 101 || wu.Errors != 1 || wu.P50 != 50 || wu.P90 != 90 || wu.P99 != 99 || wu.Max != 100 {
+		t.Errorf("unexpected GetWorldUpdate stats %+v", wu)
+	}
+	if so := stats[1]; so.Calls != 1 || so.Errors != 1 || so.Max != 0 {
+		t.Errorf("unexpected SignOff stats %+v", so)
+	}
+
+	s.recordUsage(ServerResourceUsage{CPUUsage: 40, AllocMemory: 100, NumSims: 3})
+	s.recordUsage(ServerResourceUsage{CPUUsage: 20, AllocMemory: 300, NumSims: 2})
+	if u := s.usage; u.Samples != 2 || u.CPUUsage != 40 || u.AllocMemory != 300 || u.NumSims != 3 {
+		t.Errorf("unexpected peak usage %+v", u)
+	}
+}
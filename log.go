@@ -5,12 +5,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"path"
 	"runtime"
 	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -22,7 +25,15 @@ type Logger struct {
 	start   time.Time
 }
 
-func NewLogger(server bool, level string) *Logger {
+// LogRetention specifies when log files are rotated and how many old ones
+// are kept; zero-valued fields are replaced with defaults.
+type LogRetention struct {
+	MaxSizeMB  int // size at which the log file is rotated
+	MaxBackups int // maximum number of rotated log files to keep
+	MaxAgeDays int // rotated log files older than this are deleted
+}
+
+func NewLogger(server bool, level string, retention LogRetention) *Logger {
 	var w *lumberjack.Logger
 
 	if server {
@@ -33,37 +44,40 @@ func NewLogger(server bool, level string) *Logger {
 			Compress: true,
 		}
 	} else {
-		dir, err := os.UserConfigDir()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to find user config dir: %v", err)
-			dir = "."
-		}
-		fn := path.Join(dir, "Vice", "vice.slog")
+		fn := path.Join(configDirectory(), "vice.slog")
 
 		w = &lumberjack.Logger{
 			Filename:   fn,
 			MaxSize:    Select(level == "debug", 512, 32), // MB
 			MaxBackups: 1,
+			MaxAge:     30,
 		}
 	}
 
-	lvl := slog.LevelInfo
-	switch level {
-	case "debug":
-		lvl = slog.LevelDebug
-	case "info":
-		lvl = slog.LevelInfo
-	case "warn":
-		lvl = slog.LevelWarn
-	case "error":
-		lvl = slog.LevelError
-	default:
-		fmt.Fprintf(os.Stderr, "%s: invalid log level", level)
+	if retention.MaxSizeMB > 0 {
+		w.MaxSize = retention.MaxSizeMB
+	}
+	if retention.MaxBackups > 0 {
+		w.MaxBackups = retention.MaxBackups
+	}
+	if retention.MaxAgeDays > 0 {
+		w.MaxAge = retention.MaxAgeDays
 	}
 
-	h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})
+	lvl, err := parseLogLevel(level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	// The given level is the default for all subsystems.
+	for _, name := range LogSubsystems {
+		logSubsystemLevel(name).Set(lvl)
+	}
+
+	// The JSONHandler accepts everything; filtering is done by the
+	// subsystemHandler based on the subsystem's current level.
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
 	l := &Logger{
-		Logger:  slog.New(h),
+		Logger:  slog.New(&subsystemHandler{Handler: h, level: logSubsystemLevel(LogSubsystemDefault)}),
 		logFile: w.Filename,
 		start:   time.Now(),
 	}
@@ -113,6 +127,13 @@ func (l *Logger) Debug(msg string, args ...any) {
 	}
 }
 
+// DebugEnabled returns true if debug messages will be logged; it can be
+// used to avoid the cost of gathering arguments for Debug when they would
+// be discarded anyway.
+func (l *Logger) DebugEnabled() bool {
+	return l != nil && l.Logger.Enabled(nil, slog.LevelDebug)
+}
+
 // Debugf is a convenience wrapper that logs just a message and allows
 // printf-style formatting of the provided args.
 func (l *Logger) Debugf(msg string, args ...any) {
@@ -166,6 +187,23 @@ func (l *Logger) Errorf(msg string, args ...any) {
 	}
 }
 
+// Subsystem returns a Logger for the named subsystem; its messages are
+// filtered using the subsystem's log level, which may be changed at
+// runtime using SetLogLevel.
+func (l *Logger) Subsystem(name string) *Logger {
+	h := l.Logger.Handler()
+	if sh, ok := h.(*subsystemHandler); ok {
+		h = sh.Handler
+	}
+	h = h.WithAttrs([]slog.Attr{slog.String("subsystem", name)})
+
+	return &Logger{
+		Logger:  slog.New(&subsystemHandler{Handler: h, level: logSubsystemLevel(name)}),
+		logFile: l.logFile,
+		start:   l.start,
+	}
+}
+
 func (l *Logger) With(args ...any) *Logger {
 	return &Logger{
 		Logger:  l.Logger.With(args...),
@@ -174,6 +212,90 @@ func (l *Logger) With(args ...any) *Logger {
 	}
 }
 
+///////////////////////////////////////////////////////////////////////////
+// Subsystem log levels
+
+const LogSubsystemDefault = "vice"
+
+// LogSubsystems lists the subsystems that have their own log levels.
+var LogSubsystems = []string{LogSubsystemDefault, "sim", "nav", "net", "renderer", "trajectory"}
+
+var logLevelVars struct {
+	mu     sync.Mutex
+	levels map[string]*slog.LevelVar
+}
+
+func logSubsystemLevel(name string) *slog.LevelVar {
+	logLevelVars.mu.Lock()
+	defer logLevelVars.mu.Unlock()
+
+	if logLevelVars.levels == nil {
+		logLevelVars.levels = make(map[string]*slog.LevelVar)
+	}
+	v, ok := logLevelVars.levels[name]
+	if !ok {
+		v = &slog.LevelVar{}
+		if name != LogSubsystemDefault {
+			if def, ok := logLevelVars.levels[LogSubsystemDefault]; ok {
+				v.Set(def.Level())
+			}
+		}
+		logLevelVars.levels[name] = v
+	}
+	return v
+}
+
+func parseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("%s: invalid log level", level)
+	}
+}
+
+// SetLogLevel sets the log level for the named subsystem; it may be called
+// at any time.
+func SetLogLevel(subsystem string, level string) error {
+	lvl, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	logSubsystemLevel(subsystem).Set(lvl)
+	return nil
+}
+
+// GetLogLevel returns the current log level of the named subsystem as a
+// lower-case string.
+func GetLogLevel(subsystem string) string {
+	return strings.ToLower(logSubsystemLevel(subsystem).Level().String())
+}
+
+// subsystemHandler wraps a slog.Handler and only passes along records that
+// are at or above its subsystem's current level.
+type subsystemHandler struct {
+	slog.Handler
+	level *slog.LevelVar
+}
+
+func (h *subsystemHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &subsystemHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *subsystemHandler) WithGroup(name string) slog.Handler {
+	return &subsystemHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
 // Stats collects a few statistics related to rendering and time spent in
 // various phases of the system.
 type Stats struct {
@@ -0,0 +1,279 @@
+// logviewer.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+	"github.com/pkg/browser"
+)
+
+// LogRecord is a single parsed entry from the JSON log file.
+type LogRecord struct {
+	Time      time.Time
+	Level     string
+	Message   string
+	Subsystem string
+	Raw       string
+}
+
+var logLevelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+// parseLogLine parses a line written by slog's JSONHandler; it returns
+// false if the line isn't a valid log record.
+func parseLogLine(line string) (LogRecord, bool) {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return LogRecord{}, false
+	}
+
+	r := LogRecord{Raw: line}
+	r.Level, _ = m[slog.LevelKey].(string)
+	r.Message, _ = m[slog.MessageKey].(string)
+	r.Subsystem, _ = m["subsystem"].(string)
+	if t, ok := m[slog.TimeKey].(string); ok {
+		r.Time, _ = time.Parse(time.RFC3339Nano, t)
+	}
+	return r, true
+}
+
+// ReadLogTail returns the records from the last maxBytes of the given log
+// file.
+func ReadLogTail(fn string, maxBytes int64) ([]LogRecord, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	skipFirst := false
+	if fi, err := f.Stat(); err == nil && fi.Size() > maxBytes {
+		if _, err := f.Seek(-maxBytes, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		// We're probably starting in the middle of a line.
+		skipFirst = true
+	}
+
+	var records []LogRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16*1024*1024) // some records (e.g. build info) are long
+	for sc.Scan() {
+		if skipFirst {
+			skipFirst = false
+			continue
+		}
+		if r, ok := parseLogLine(sc.Text()); ok {
+			records = append(records, r)
+		}
+	}
+	return records, sc.Err()
+}
+
+// uiOpenLogsFolder opens the directory that holds the log files in the
+// system's file browser.
+func uiOpenLogsFolder() {
+	if err := browser.OpenFile(path.Dir(lg.logFile)); err != nil {
+		ShowErrorDialog("Unable to open logs folder: %v", err)
+	}
+}
+
+// uiDrawLogLevelSettings draws combo boxes that allow changing each
+// subsystem's log level; changes take effect immediately and are saved in
+// the config.
+func uiDrawLogLevelSettings() {
+	for _, subsystem := range LogSubsystems {
+		cur := GetLogLevel(subsystem)
+		if imgui.BeginComboV("Log level: "+subsystem, cur, 0) {
+			for _, level := range logLevelNames {
+				level = strings.ToLower(level)
+				if imgui.SelectableV(level, level == cur, 0, imgui.Vec2{}) && level != cur {
+					if err := SetLogLevel(subsystem, level); err != nil {
+						lg.Warnf("%s: %v", subsystem, err)
+					} else {
+						if globalConfig.LogLevels == nil {
+							globalConfig.LogLevels = make(map[string]string)
+						}
+						globalConfig.LogLevels[subsystem] = level
+						lg.Infof("%s: log level set to %s", subsystem, level)
+					}
+				}
+			}
+			imgui.EndCombo()
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Log viewer window
+
+var logViewer struct {
+	visible    bool
+	search     string
+	minLevel   int
+	subsystem  string
+	records    []LogRecord
+	subsystems []string
+	lastLoad   time.Time
+	lastSize   int64
+
+	// Indices into records of the ones that pass the current filter, most
+	// recent first, and the filter settings they were computed with.
+	shown       []int
+	shownFilter string
+	shownValid  bool
+}
+
+func uiToggleShowLogViewer() {
+	logViewer.visible = !logViewer.visible
+	logViewer.lastLoad = time.Time{} // force a reload
+}
+
+func (r *LogRecord) levelIndex() int {
+	for i, l := range logLevelNames {
+		if strings.HasPrefix(r.Level, l) {
+			return i
+		}
+	}
+	return 0
+}
+
+func logViewerReload() {
+	logViewer.lastLoad = time.Now()
+
+	fi, err := os.Stat(lg.logFile)
+	if err != nil || fi.Size() == logViewer.lastSize {
+		return
+	}
+	logViewer.lastSize = fi.Size()
+
+	records, err := ReadLogTail(lg.logFile, 4*1024*1024)
+	if err != nil {
+		lg.Warnf("%s: %v", lg.logFile, err)
+		return
+	}
+	logViewer.records = records
+	logViewer.shownValid = false
+
+	subsystems := make(map[string]interface{})
+	for _, r := range records {
+		if r.Subsystem != "" {
+			subsystems[r.Subsystem] = nil
+		}
+	}
+	logViewer.subsystems = SortedMapKeys(subsystems)
+}
+
+// logViewerUpdateShown recomputes the records that pass the filter if
+// the records or the filter settings have changed since the last time.
+func logViewerUpdateShown() {
+	filter := fmt.Sprintf("%d|%s|%s", logViewer.minLevel, logViewer.subsystem, logViewer.search)
+	if logViewer.shownValid && filter == logViewer.shownFilter {
+		return
+	}
+	logViewer.shownValid = true
+	logViewer.shownFilter = filter
+
+	search := strings.ToLower(logViewer.search)
+	logViewer.shown = logViewer.shown[:0]
+	for i := len(logViewer.records) - 1; i >= 0; i-- { // most recent first
+		r := &logViewer.records[i]
+		if r.levelIndex() < logViewer.minLevel ||
+			(logViewer.subsystem != "" && r.Subsystem != logViewer.subsystem) ||
+			(search != "" && !strings.Contains(strings.ToLower(r.Raw), search)) {
+			continue
+		}
+		logViewer.shown = append(logViewer.shown, i)
+	}
+}
+
+func uiDrawLogViewer() {
+	if !logViewer.visible {
+		return
+	}
+	if time.Since(logViewer.lastLoad) > 2*time.Second {
+		logViewerReload()
+	}
+
+	imgui.BeginV("Log Viewer", &logViewer.visible, 0)
+
+	imgui.InputTextV("Search", &logViewer.search, 0, nil)
+	imgui.SameLine()
+	imgui.SetNextItemWidth(100)
+	if imgui.BeginComboV("Min. level", logLevelNames[logViewer.minLevel], 0) {
+		for i, l := range logLevelNames {
+			if imgui.SelectableV(l, i == logViewer.minLevel, 0, imgui.Vec2{}) {
+				logViewer.minLevel = i
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.SameLine()
+	imgui.SetNextItemWidth(120)
+	if imgui.BeginComboV("Subsystem", Select(logViewer.subsystem == "", "(all)", logViewer.subsystem), 0) {
+		if imgui.SelectableV("(all)", logViewer.subsystem == "", 0, imgui.Vec2{}) {
+			logViewer.subsystem = ""
+		}
+		for _, s := range logViewer.subsystems {
+			if imgui.SelectableV(s, s == logViewer.subsystem, 0, imgui.Vec2{}) {
+				logViewer.subsystem = s
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.SameLine()
+	if imgui.Button(FontAwesomeIconFolder + " Open folder") {
+		uiOpenLogsFolder()
+	}
+
+	if imgui.CollapsingHeader("Log levels") {
+		uiDrawLogLevelSettings()
+	}
+
+	logViewerUpdateShown()
+	flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg |
+		imgui.TableFlagsSizingStretchProp | imgui.TableFlagsScrollY
+	if imgui.BeginTableV("logs", 4, flags, imgui.Vec2{900, 500}, 0.) {
+		imgui.TableSetupColumn("Time")
+		imgui.TableSetupColumn("Level")
+		imgui.TableSetupColumn("Subsystem")
+		imgui.TableSetupColumn("Message")
+		imgui.TableHeadersRow()
+
+		// The log may have tens of thousands of records, so only submit
+		// the rows that are actually visible.
+		var clipper imgui.ListClipper
+		clipper.Begin(len(logViewer.shown))
+		for clipper.Step() {
+			for _, idx := range logViewer.shown[clipper.DisplayStart:clipper.DisplayEnd] {
+				r := &logViewer.records[idx]
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				imgui.Text(r.Time.Local().Format("15:04:05"))
+				imgui.TableNextColumn()
+				imgui.Text(r.Level)
+				imgui.TableNextColumn()
+				imgui.Text(r.Subsystem)
+				imgui.TableNextColumn()
+				imgui.Text(r.Message)
+				if imgui.IsItemHovered() {
+					imgui.SetTooltip(r.Raw)
+				}
+			}
+		}
+		imgui.EndTable()
+	}
+
+	imgui.End()
+}
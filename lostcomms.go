@@ -0,0 +1,128 @@
+// lostcomms.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Lost communications: an aircraft may become NORDO, either because its
+// radios have failed or because the pilot has ended up on the wrong
+// frequency. NORDO aircraft squawk 7600, don't respond to instructions,
+// never check in after frequency changes, and proceed following the
+// lost-comm rules of 14 CFR 91.185:
+//
+//   - An aircraft that was being vectored continues on the vector for a
+//     minute and then proceeds direct to the next fix in its route.
+//   - Departures climb to their filed altitude ten minutes after losing
+//     communications, as if they had been told to expect it then.
+//   - Otherwise, aircraft continue with their last clearance.
+//
+// Controllers may try to reach NORDO aircraft on guard (121.5); pilots
+// occasionally hear it, in which case they switch back to the
+// controller's frequency and communications are restored. Instructors
+// make aircraft NORDO with the NORDO edit (see instructor.go).
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+const (
+	lostCommsVectorTime         = time.Minute
+	lostCommsExpectAltitudeTime = 10 * time.Minute
+	// Probability that a NORDO pilot hears a call on guard.
+	guardResponseProbability = 0.3
+)
+
+// loseComms makes the aircraft NORDO; s.mu must be held.
+func (s *Sim) loseComms(ac *Aircraft) {
+	if ac.LostComms {
+		return
+	}
+	s.lg.Info("lost communications", slog.String("callsign", ac.Callsign))
+	ac.LostComms = true
+	ac.LostCommsStart = s.SimTime
+	ac.Squawk = Squawk(0o7600)
+}
+
+// restoreComms returns a NORDO aircraft to normal operation; s.mu must be
+// held.
+func (s *Sim) restoreComms(ac *Aircraft) {
+	if !ac.LostComms {
+		return
+	}
+	s.lg.Info("communications restored", slog.String("callsign", ac.Callsign))
+	ac.LostComms = false
+	ac.LostCommsStart = time.Time{}
+	ac.Squawk = ac.AssignedSquawk
+}
+
+// updateLostComms is called once a second with s.mu held; it has NORDO
+// aircraft follow the lost-comm rules.
+func (s *Sim) updateLostComms(now time.Time) {
+	var aircraft []*Aircraft
+	for _, ac := range s.World.Aircraft {
+		if ac.LostComms && ac.IsAirborne() {
+			aircraft = append(aircraft, ac)
+		}
+	}
+	sort.Slice(aircraft, func(i, j int) bool { return aircraft[i].Callsign < aircraft[j].Callsign })
+
+	for _, ac := range aircraft {
+		elapsed := now.Sub(ac.LostCommsStart)
+
+		if _, ok := ac.Nav.AssignedHeading(); ok && elapsed >= lostCommsVectorTime && len(ac.Nav.Waypoints) > 0 {
+			s.lg.Info("lost comms: resuming route", slog.String("callsign", ac.Callsign),
+				slog.String("fix", ac.Nav.Waypoints[0].Fix))
+			// This is the pilot's decision rather than an instruction, so
+			// it takes effect immediately.
+			ac.Nav.Heading = NavHeading{}
+			ac.Nav.DeferredHeading = nil
+		}
+
+		if ac.IsDeparture() && ac.FlightPlan != nil && elapsed >= lostCommsExpectAltitudeTime {
+			alt := float32(ac.FlightPlan.Altitude)
+			if assigned := ac.Nav.Altitude.Assigned; assigned != nil && *assigned < alt {
+				s.lg.Info("lost comms: climbing to filed altitude", slog.String("callsign", ac.Callsign),
+					slog.Float64("altitude", float64(alt)))
+				ac.Nav.Altitude = NavAltitude{Assigned: &alt}
+			}
+		}
+	}
+}
+
+// CallOnGuard transmits to the aircraft on guard. Any controller may do
+// so, whether or not the aircraft is on their frequency.
+func (s *Sim) CallOnGuard(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	return s.dispatchCommand(token, callsign,
+		func(ctrl *Controller, ac *Aircraft) error {
+			if ac.LostComms && rand.Float32() >= guardResponseProbability {
+				return ErrNoResponse
+			}
+			return nil
+		},
+		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
+			if !ac.LostComms {
+				// Everyone monitors guard, but there's nothing for the
+				// pilot to do.
+				return []RadioTransmission{RadioTransmission{
+					Controller: ctrl.Callsign,
+					Message:    "loud and clear on guard",
+					Type:       RadioTransmissionReadback,
+				}}
+			}
+
+			s.restoreComms(ac)
+			rt := RadioTransmission{
+				Controller: ctrl.Callsign,
+				Message:    "on guard, sorry about that, we'll contact you on " + ctrl.Frequency.String(),
+				Type:       RadioTransmissionReadback,
+			}
+			delete(s.PendingContacts, ac.Callsign)
+			s.switchFrequency(ac, ctrl.Callsign)
+			return []RadioTransmission{rt}
+		})
+}
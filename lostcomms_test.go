@@ -0,0 +1,73 @@
+// lostcomms_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLostComms(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+
+	hdg, alt := float32(270), float32(7000)
+	ac := &Aircraft{
+		Callsign:              "AAL1",
+		AssignedSquawk:        Squawk(0o1234),
+		Squawk:                Squawk(0o1234),
+		ControllingController: "2K",
+		FlightPlan:            &FlightPlan{Altitude: 23000},
+	}
+	ac.Nav.FlightState.IAS = 250
+	ac.Nav.FlightState.IsDeparture = true
+	ac.Nav.Heading = NavHeading{Assigned: &hdg}
+	ac.Nav.Altitude = NavAltitude{Assigned: &alt}
+	ac.Nav.Waypoints = []Waypoint{{Fix: "GAYEL"}, {Fix: "J95"}}
+
+	s := &Sim{
+		World:       &World{Aircraft: map[string]*Aircraft{"AAL1": ac}},
+		eventStream: NewEventStream(),
+		SimTime:     start,
+	}
+
+	s.loseComms(ac)
+	if !ac.LostComms || ac.Squawk != Squawk(0o7600) {
+		t.Fatalf("expected NORDO squawking 7600, got %v %s", ac.LostComms, ac.Squawk)
+	}
+	if err := onFrequency(&Controller{Callsign: "2K"}, ac); err != ErrNoResponse {
+		t.Errorf("expected no response, got %v", err)
+	}
+
+	// Continue on the vector for a minute, then resume the route.
+	s.updateLostComms(start.Add(30 * time.Second))
+	if _, ok := ac.Nav.AssignedHeading(); !ok {
+		t.Errorf("expected the vector to be followed for a minute")
+	}
+	s.updateLostComms(start.Add(90 * time.Second))
+	if _, ok := ac.Nav.AssignedHeading(); ok {
+		t.Errorf("expected the aircraft to resume its route")
+	}
+	if *ac.Nav.Altitude.Assigned != 7000 {
+		t.Errorf("expected the assigned altitude to be maintained, got %.0f", *ac.Nav.Altitude.Assigned)
+	}
+
+	// Climb to the filed altitude after 10 minutes.
+	s.updateLostComms(start.Add(10 * time.Minute))
+	if *ac.Nav.Altitude.Assigned != 23000 {
+		t.Errorf("expected a climb to the filed altitude, got %.0f", *ac.Nav.Altitude.Assigned)
+	}
+
+	// NORDO aircraft never check in after a frequency change.
+	s.switchFrequency(ac, "N56")
+	s.updatePendingContacts(start.Add(time.Hour))
+	if ac.ControllingController != "" {
+		t.Errorf("unexpected check in with %q", ac.ControllingController)
+	}
+
+	s.restoreComms(ac)
+	if ac.LostComms || ac.Squawk != ac.AssignedSquawk {
+		t.Errorf("expected communications to be restored, got %v %s", ac.LostComms, ac.Squawk)
+	}
+}
@@ -30,6 +30,7 @@ import (
 
 const ViceServerAddress = "vice.pharr.org"
 const ViceServerPort = 8001
+const ViceHTTPServerPort = 6502
 
 var (
 	// There are a handful of widely-used global variables in vice, all
@@ -51,6 +52,9 @@ var (
 	remoteServer *SimServer
 	airportWind  map[string]Wind
 	windRequest  map[string]chan getweather.MetarData
+	plugins      *PluginManager
+	apiServer    *APIServer
+	overlay      *StreamOverlay
 
 	//go:embed resources/version.txt
 	buildVersion string
@@ -59,16 +63,38 @@ var (
 	cpuprofile        = flag.String("cpuprofile", "", "write CPU profile to file")
 	memprofile        = flag.String("memprofile", "", "write memory profile to this file")
 	logLevel          = flag.String("loglevel", "info", "logging level: debug, info, warn, error")
+	logMaxSize        = flag.Int("logsize", 0, "size in MB at which the log file is rotated (0 for the default)")
+	logBackups        = flag.Int("logbackups", 0, "number of rotated log files to keep (0 for the default)")
+	logMaxAge         = flag.Int("logdays", 0, "number of days to keep rotated log files (0 for the default)")
 	lintScenarios     = flag.Bool("lint", false, "check the validity of the built-in scenarios")
+	lintFix           = flag.Bool("fix", false, "with -lint, rewrite the file given with -scenario in normalized form")
+	listScenarios     = flag.Bool("listscenarios", false, "list the available scenarios")
+	jsonOutput        = flag.Bool("json", false, "print the output of -lint, -listscenarios, -loadtest, -routes, -smoketest, and \"vice maps\" as JSON")
 	server            = flag.Bool("runserver", false, "run vice scenario server")
 	serverPort        = flag.Int("port", ViceServerPort, "port to listen on when running server")
 	serverAddress     = flag.String("server", ViceServerAddress+fmt.Sprintf(":%d", ViceServerPort), "IP address of vice multi-controller server")
 	scenarioFilename  = flag.String("scenario", "", "filename of JSON file with a scenario definition")
 	videoMapFilename  = flag.String("videomap", "", "filename of JSON file with video map definitions")
 	broadcastMessage  = flag.String("broadcast", "", "message to broadcast to all active clients on the server")
-	broadcastPassword = flag.String("password", "", "password to authenticate with server for broadcast message")
+	broadcastPassword = flag.String("password", "", "password to authenticate with server for -broadcast and -reload")
+	reloadServer      = flag.Bool("reload", false, "ask the server given by -server to reload its scenarios and video maps")
 	resetSim          = flag.Bool("resetsim", false, "discard the saved simulation and do not try to resume it")
 	showRoutes        = flag.String("routes", "", "display the STARS, SIDs, and approaches known for the given airport")
+	apiPort           = flag.Int("apiport", 0, "if non-zero, serve the local sim-control HTTP API on the given port")
+	overlayDir        = flag.String("overlaydir", "", "directory to write streaming overlay text files to")
+	configDir         = flag.String("configdir", "", "directory for the config file, logs, and other per-user files")
+	portable          = flag.Bool("portable", false, "keep the config file, logs, and other files next to the vice executable")
+	resourcesDir      = flag.String("resourcesdir", "", "directory with vice's resources (scenarios, video maps, etc.)")
+	journalEvents     = flag.Bool("journal", false, "record all sim events to events.jsonl in the configuration directory")
+	videoMapCacheSize = flag.Int("videomapcache", 512, "memory budget in MB for cached video maps")
+	smokeTest         = flag.String("smoketest", "", "run the scenario smoke tests in the given JSON file or directory and report the results")
+	loadTest          = flag.Int("loadtest", 0, "run a load test with the given number of synthetic controllers against the server given by -server")
+	loadTestDuration  = flag.Duration("loadtesttime", 5*time.Minute, "how long to run -loadtest for")
+	replayScript      = flag.String("replayscript", "", "print a smoke test that replays the commands issued in the given saved sim")
+	netLatency        = flag.Duration("netlatency", 0, "add the given one-way latency to the connection to the local sim server, for testing")
+	netJitter         = flag.Duration("netjitter", 0, "add up to the given random delay to the connection to the local sim server, for testing")
+	netLoss           = flag.Float64("netloss", 0, "fraction of packets to \"lose\" on the connection to the local sim server, for testing")
+	rendererFlag      = flag.String("renderer", "", "rendering backend: \"gl3\" (OpenGL 3.3, falling back to 2.1 if unavailable) or \"gl2\" (OpenGL 2.1)")
 )
 
 func init() {
@@ -82,6 +108,10 @@ func init() {
 func main() {
 	flag.Parse()
 
+	// A vice:// link given on the command line (e.g., when launched by the
+	// OS's URL handler); it's handled once the UI is up.
+	viceLink, haveViceLink := findViceLink(flag.Args())
+
 	rand.Seed(time.Now().UnixNano())
 
 	// Common initialization for both client and server
@@ -92,7 +122,11 @@ func main() {
 	}
 
 	// Initialize the logging system first and foremost.
-	lg = NewLogger(*server, *logLevel)
+	lg = NewLogger(*server, *logLevel, LogRetention{
+		MaxSizeMB:  *logMaxSize,
+		MaxBackups: *logBackups,
+		MaxAgeDays: *logMaxAge,
+	})
 
 	// If the path is non-absolute, convert it to an absolute path
 	// w.r.t. the current directory.  (This is to work around that vice
@@ -159,36 +193,74 @@ func main() {
 
 	database = InitializeStaticDatabase()
 
-	if *lintScenarios {
+	if flag.NArg() > 0 && flag.Arg(0) == "maps" {
+		os.Exit(runVideoMapTool(flag.Args()[1:]))
+	} else if *lintScenarios {
+		var e ErrorLogger
+		scenarioGroups, simConfigurations := LoadScenarioGroups(&e)
+		if !e.HaveErrors() {
+			lintSims(scenarioGroups, simConfigurations, &e)
+		}
+		if *jsonOutput {
+			writeJSON(os.Stdout, struct {
+				Errors []ErrorLogEntry `json:"errors"`
+			}{Errors: Select(e.HaveErrors(), e.Errors(), []ErrorLogEntry{})})
+		} else if e.HaveErrors() {
+			e.PrintErrors(nil)
+		}
+		if e.HaveErrors() {
+			os.Exit(1)
+		}
+		if *lintFix {
+			if *scenarioFilename == "" {
+				fmt.Fprintln(os.Stderr, "-fix requires a scenario file to be given with -scenario")
+				os.Exit(1)
+			}
+			if err := normalizeScenarioFile(*scenarioFilename); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	} else if *listScenarios {
 		var e ErrorLogger
-		_, _ = LoadScenarioGroups(&e)
+		scenarioGroups, _ := LoadScenarioGroups(&e)
 		if e.HaveErrors() {
 			e.PrintErrors(nil)
 			os.Exit(1)
 		}
+		summaries := summarizeScenarioGroups(scenarioGroups)
+		if *jsonOutput {
+			writeJSON(os.Stdout, summaries)
+		} else {
+			printScenarioSummaries(os.Stdout, summaries)
+		}
+	} else if *smokeTest != "" {
+		os.Exit(runSmokeTests(*smokeTest, *jsonOutput))
+	} else if *loadTest > 0 {
+		os.Exit(runLoadTest(*serverAddress, *loadTest, *loadTestDuration, *jsonOutput))
+	} else if *replayScript != "" {
+		if err := writeReplayScript(os.Stdout, *replayScript); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	} else if *broadcastMessage != "" {
 		BroadcastMessage(*serverAddress, *broadcastMessage, *broadcastPassword)
+	} else if *reloadServer {
+		if err := ReloadServerScenarios(*serverAddress, *broadcastPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *serverAddress, err)
+			os.Exit(1)
+		}
 	} else if *server {
 		RunSimServer()
 	} else if *showRoutes != "" {
 		ap, ok := database.Airports[*showRoutes]
 		if !ok {
-			fmt.Printf("%s: airport not present in database\n", *showRoutes)
+			fmt.Fprintf(os.Stderr, "%s: airport not present in database\n", *showRoutes)
 			os.Exit(1)
 		}
-		fmt.Printf("STARs:\n")
-		for _, s := range SortedMapKeys(ap.STARs) {
-			ap.STARs[s].Print(s)
-		}
-		fmt.Printf("\nApproaches:\n")
-		for _, appr := range SortedMapKeys(ap.Approaches) {
-			fmt.Printf("%-5s: ", appr)
-			for i, wp := range ap.Approaches[appr] {
-				if i > 0 {
-					fmt.Printf("       ")
-				}
-				fmt.Println(wp.Encode())
-			}
+		if err := ap.PrintRoutes(os.Stdout, *jsonOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *showRoutes, err)
+			os.Exit(1)
 		}
 	} else {
 		localSimServerChan, err := LaunchLocalSimServer()
@@ -197,11 +269,23 @@ func main() {
 			os.Exit(1)
 		}
 
+		journalFile := ""
+		if *journalEvents {
+			journalFile = path.Join(configDirectory(), "events.jsonl")
+		}
+		journal, err := NewEventJournal(10000, journalFile)
+		if err != nil {
+			lg.Errorf("%s: unable to open event journal: %v", journalFile, err)
+		}
+		eventStream.SetJournal(journal)
+		defer journal.Close()
+
 		lastRemoteServerAttempt := time.Now()
 		remoteSimServerChan := TryConnectRemoteServer(*serverAddress)
 
 		var stats Stats
 		var renderer Renderer
+		var world *World
 
 		// Catch any panics so that we can put up a dialog box and hopefully
 		// get a bug report.
@@ -209,11 +293,12 @@ func main() {
 		if os.Getenv("DELVE_GOVERSION") == "" { // hack: don't catch panics when debugging..
 			defer func() {
 				if err := recover(); err != nil {
-					lg.Error("Caught panic!", slog.String("stack", string(debug.Stack())))
-					ShowFatalErrorDialog(renderer, platform,
+					stack := debug.Stack()
+					lg.Error("Caught panic!", slog.String("stack", string(stack)))
+					ShowFatalErrorDialog(renderer, platform, MakeCrashReport(err, stack, world),
 						"Unfortunately an unexpected error has occurred and vice is unable to recover.\n"+
-							"Apologies! Please do file a bug and include the vice.log file for this session\nso that "+
-							"this bug can be fixed.\n\nError: %v", err)
+							"Apologies! Please send a crash report or file a bug and include the vice.log\n"+
+							"file for this session so that this bug can be fixed.\n\nError: %v", err)
 				}
 
 				// Clean up in backwards order from how things were created.
@@ -230,27 +315,63 @@ func main() {
 		context = imguiInit()
 
 		LoadOrMakeDefaultConfig()
+		recoverCorruptConfig()
+		StartConfigSyncDownload(false)
+
+		installViceURLHandler()
 
 		multisample := runtime.GOOS != "darwin"
+		backend := Select(*rendererFlag != "", *rendererFlag, globalConfig.Renderer)
 		platform, err = NewGLFWPlatform(imgui.CurrentIO(), globalConfig.InitialWindowSize,
-			globalConfig.InitialWindowPosition, multisample)
+			globalConfig.InitialWindowPosition, multisample, backend != "gl2")
 		if err != nil {
 			panic(fmt.Sprintf("Unable to create application window: %v", err))
 		}
 		imgui.CurrentIO().SetClipboard(platform.GetClipboard())
 
-		renderer, err = NewOpenGL2Renderer()
+		// On macOS, the vice:// link that launched vice is delivered while
+		// GLFW is initialized rather than on the command line.
+		if link, ok := pendingViceLink(); ok {
+			viceLink, haveViceLink = link, true
+		}
+
+		if platform.OpenGLCoreProfile() {
+			if renderer, err = NewOpenGL3Renderer(); err != nil {
+				lg.Warnf("Unable to initialize OpenGL 3.3 renderer; falling back to OpenGL 2.1: %v", err)
+				// The OpenGL 2.1 renderer requires a compatibility
+				// context, so the window must be recreated.
+				platform.Dispose()
+				platform, err = NewGLFWPlatform(imgui.CurrentIO(), globalConfig.InitialWindowSize,
+					globalConfig.InitialWindowPosition, multisample, false)
+				if err != nil {
+					panic(fmt.Sprintf("Unable to create application window: %v", err))
+				}
+				imgui.CurrentIO().SetClipboard(platform.GetClipboard())
+			}
+		}
+		if renderer == nil {
+			renderer, err = NewOpenGL2Renderer()
+		}
 		if err != nil {
 			panic(fmt.Sprintf("Unable to initialize OpenGL: %v", err))
 		}
 
 		fontsInit(renderer, platform)
 
+		if err := globalConfig.Audio.Activate(); err != nil {
+			lg.Errorf("Audio: %v", err)
+		}
+
 		newWorldChan = make(chan *World, 2)
-		var world *World
 
-		localServer = <-localSimServerChan
+		localServer = waitForLocalServer(renderer, platform, localSimServerChan)
 
+		if info, sim := loadAutosave(); sim != nil && !*resetSim {
+			// vice didn't exit cleanly last time, so the autosave is more
+			// recent than the sim saved in the config file.
+			lg.Infof("restoring sim autosaved at %s", info.Time)
+			globalConfig.Sim, globalConfig.Callsign = sim, info.Callsign
+		}
 		if globalConfig.Sim != nil && !*resetSim {
 			var result NewSimResult
 			if err := localServer.Call("SimManager.Add", globalConfig.Sim, &result); err != nil {
@@ -269,9 +390,71 @@ func main() {
 
 		uiInit(renderer, platform, eventStream)
 
+		plugins = NewPluginManager(pluginDirectory(), eventStream)
+		defer plugins.Shutdown()
+
+		if *apiPort != 0 {
+			if apiServer, err = LaunchAPIServer(*apiPort, eventStream); err != nil {
+				lg.Errorf("unable to launch API server: %v", err)
+			}
+		}
+		if *overlayDir != "" || apiServer != nil {
+			absPath(overlayDir)
+			overlay = NewStreamOverlay(*overlayDir, eventStream)
+			if apiServer != nil {
+				overlay.RegisterHandlers(apiServer)
+			}
+		}
+
 		globalConfig.Activate(world, renderer, eventStream)
 
-		if world == nil {
+		stopConnectingRemoteServer := false
+
+		// joinViceLink switches to the server given in a vice:// link if
+		// it isn't the current one; the dialog to join the link's sim is
+		// shown once we've heard back from the server.
+		joinViceLink := func(link ViceLink) {
+			if link.Server != *serverAddress {
+				*serverAddress = link.Server
+				viceLink, haveViceLink = link, true
+				remoteServer = nil
+				uiCloseConnectDialogs()
+				stopConnectingRemoteServer = false
+				lastRemoteServerAttempt = time.Now()
+				remoteSimServerChan = TryConnectRemoteServer(*serverAddress)
+			} else if remoteServer != nil {
+				uiShowJoinDialog(link.Sim, false)
+			} else {
+				viceLink, haveViceLink = link, true
+			}
+		}
+		// handleViceLink asks before connecting to a server other than the
+		// one vice is using, since links can come from anywhere.
+		handleViceLink := func(link ViceLink) {
+			if world != nil {
+				ShowErrorDialog("Please disconnect from the current sim before joining %q.", link.Sim)
+			} else if link.Server == *serverAddress {
+				joinViceLink(link)
+			} else {
+				uiShowModalDialog(NewModalDialogBox(&YesOrNoModalClient{
+					title: "Join Sim",
+					query: fmt.Sprintf("The link you opened is for the sim %q on the server %s.\n\n"+
+						"Only connect to servers that you trust. Connect to %s?", link.Sim, link.Server, link.Server),
+					ok: func() { joinViceLink(link) },
+					notok: func() {
+						if world == nil {
+							uiCloseConnectDialogs()
+							uiShowConnectDialog(false)
+						}
+					},
+				}), true)
+			}
+		}
+
+		if haveViceLink {
+			haveViceLink = false
+			handleViceLink(viceLink)
+		} else if world == nil {
 			uiShowConnectDialog(false)
 		}
 
@@ -291,7 +474,6 @@ func main() {
 		airportWind = make(map[string]Wind)
 		windRequest = make(map[string]chan getweather.MetarData)
 
-		stopConnectingRemoteServer := false
 		frameIndex := 0
 		stats.startTime = time.Now()
 		for {
@@ -307,9 +489,15 @@ func main() {
 					uiShowConnectDialog(false)
 				} else if world != nil {
 					world.ToggleShowScenarioInfoWindow()
-					globalConfig.DisplayRoot.VisitPanes(func(p Pane) {
+					globalConfig.VisitAllPanes(func(p Pane) {
 						p.ResetWorld(world)
 					})
+
+					if name, ok := globalConfig.ProfileForFacility(world.TRACON); ok && name != globalConfig.ActiveProfile {
+						if err := globalConfig.LoadProfile(name, world, renderer, eventStream); err != nil {
+							lg.Errorf("%s: unable to load profile: %v", name, err)
+						}
+					}
 				}
 
 			case remoteServerConn := <-remoteSimServerChan:
@@ -332,6 +520,17 @@ func main() {
 					remoteServer = remoteServerConn.server
 				}
 
+				if haveViceLink {
+					haveViceLink = false
+					if world == nil {
+						if remoteServer != nil {
+							uiShowJoinDialog(viceLink.Sim, false)
+						} else {
+							uiShowConnectDialog(false)
+						}
+					}
+				}
+
 			default:
 			}
 
@@ -341,12 +540,30 @@ func main() {
 			} else {
 				platform.SetWindowTitle("vice: " + world.GetWindowTitle())
 				// Update discord RPC
-				SetDiscordStatus(discordStatus{
+				status := discordStatus{
 					totalDepartures: world.TotalDepartures,
 					totalArrivals:   world.TotalArrivals,
 					callsign:        world.Callsign,
 					start:           simStartTime,
-				})
+					tracon:          world.TRACON,
+					scenario:        world.SimDescription,
+				}
+				if world.SimName != "" {
+					status.simName = world.SimName
+					status.server = *serverAddress
+					status.maxPlayers = len(world.MultiControllers)
+					for _, ctrl := range world.Controllers {
+						if ctrl.IsHuman {
+							status.players++
+						}
+					}
+				}
+				SetDiscordStatus(status)
+			}
+
+			// vice:// links opened while vice is running (macOS only).
+			if link, ok := pendingViceLink(); ok {
+				handleViceLink(link)
 			}
 
 			if remoteServer == nil && time.Since(lastRemoteServerAttempt) > 10*time.Second && !stopConnectingRemoteServer {
@@ -389,12 +606,23 @@ func main() {
 					})
 			}
 
+			plugins.Update(world, eventStream)
+			apiServer.Process(world, eventStream)
+			overlay.Update(world)
+			uiUpdateConfigSync(world, renderer, eventStream)
+			uiUpdateScenarioPacks(eventStream)
+			maybeAutosaveSim(world)
+
 			platform.NewFrame()
+			fontsAddText(platform.InputCharacters())
+			fontsUpdate()
+			uiUpdateScale(platform)
 			imgui.NewFrame()
 
 			// Generate and render vice draw lists
 			if world != nil {
 				wmDrawPanes(platform, renderer, world, &stats)
+				wmDrawPaneWindows(platform, renderer, world, &stats)
 			} else {
 				commandBuffer := GetCommandBuffer()
 				commandBuffer.ClearRGB(RGB{})
@@ -402,12 +630,15 @@ func main() {
 				ReturnCommandBuffer(commandBuffer)
 			}
 
+			captureScreenshots(renderer, platform, true)
 			timeMarker(&stats.drawPanes)
 
 			// Draw the user interface
 			drawUI(platform, renderer, world, eventStream, &stats)
 			timeMarker(&stats.drawImgui)
 
+			captureScreenshots(renderer, platform, false)
+
 			// Wait for vsync
 			platform.PostRender()
 
@@ -420,7 +651,15 @@ func main() {
 			if platform.ShouldStop() && len(ui.activeModalDialogs) == 0 {
 				// Do this while we're still running the event loop.
 				saveSim := world != nil && world.simProxy.Client == localServer.RPCClient
+				UploadSyncedConfig()
 				globalConfig.SaveIfChanged(renderer, platform, world, saveSim)
+				autosaveCleanExit()
+
+				if videoRecorder != nil {
+					if _, err := videoRecorder.Stop(); err != nil {
+						lg.Errorf("unable to finish video recording: %v", err)
+					}
+				}
 
 				if world != nil {
 					world.Disconnect()
@@ -430,17 +430,10 @@ func (p Point2LL) DDString() string {
 // N039.51.39.243,W075.16.29.511
 func (p Point2LL) DMSString() string {
 	format := func(v float32) string {
-		s := fmt.Sprintf("%03d", int(v))
-		v -= floor(v)
-		v *= 60
-		s += fmt.Sprintf(".%02d", int(v))
-		v -= floor(v)
-		v *= 60
-		s += fmt.Sprintf(".%02d", int(v))
-		v -= floor(v)
-		v *= 1000
-		s += fmt.Sprintf(".%03d", int(v))
-		return s
+		// Round to the nearest thousandth of a second so that the
+		// string parses back to the same value.
+		ms := int64(math.Round(float64(v) * 3600000))
+		return fmt.Sprintf("%03d.%02d.%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
 	}
 
 	var s string
@@ -694,6 +687,178 @@ func ll2nm(p Point2LL, nmPerLongitude float32) [2]float32 {
 	return [2]float32{p[0] * nmPerLongitude, p[1] * nmPerLatitude}
 }
 
+///////////////////////////////////////////////////////////////////////////
+// geodesics
+
+// The functions above treat the earth as a sphere and, for headings and
+// projections, as locally flat; that's fine within a TRACON but loses
+// accuracy over long distances. The following compute distances and
+// bearings on the WGS84 ellipsoid using Vincenty's formulae.
+
+const (
+	wgs84A        = 6378137.0         // semi-major axis, metres
+	wgs84F        = 1 / 298.257223563 // flattening
+	wgs84B        = wgs84A * (1 - wgs84F)
+	metresPerNm   = 1852
+	vincentyEps   = 1e-12
+	vincentyIters = 200
+)
+
+// geodesicInverse returns the distance in nautical miles along the
+// ellipsoid between two points as well as the initial and final true
+// bearings of the geodesic between them. For nearly antipodal points
+// where the iteration doesn't converge, the great circle distance and
+// bearings are returned instead.
+func geodesicInverse(a Point2LL, b Point2LL) (dist, bearing0, bearing1 float32) {
+	rad := func(d float32) float64 { return float64(d) / 180 * math.Pi }
+	phi1, phi2 := rad(a[1]), rad(b[1])
+	L := rad(b[0]) - rad(a[0])
+
+	tanU1, tanU2 := (1-wgs84F)*math.Tan(phi1), (1-wgs84F)*math.Tan(phi2)
+	cosU1, cosU2 := 1/math.Sqrt(1+tanU1*tanU1), 1/math.Sqrt(1+tanU2*tanU2)
+	sinU1, sinU2 := tanU1*cosU1, tanU2*cosU2
+
+	lambda := L
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < vincentyIters; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		sinSqSigma := sqr(cosU2*sinLambda) + sqr(cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSqSigma == 0 {
+			// Coincident points
+			return 0, 0, 0
+		}
+		sinSigma = math.Sqrt(sinSqSigma)
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0 // equatorial line
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+		C := wgs84F / 16 * cosSqAlpha * (4 + wgs84F*(4-3*cosSqAlpha))
+		prev := lambda
+		lambda = L + (1-C)*wgs84F*sinAlpha*
+			(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < vincentyEps {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return nmdistance2ll(a, b), greatCircleBearing2ll(a, b),
+			NormalizeHeading(greatCircleBearing2ll(b, a) + 180)
+	}
+
+	uSq := cosSqAlpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	s := wgs84B * A * (sigma - deltaSigma)
+
+	alpha1 := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	alpha2 := math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)
+
+	return float32(s / metresPerNm), NormalizeHeading(float32(alpha1 * 180 / math.Pi)),
+		NormalizeHeading(float32(alpha2 * 180 / math.Pi))
+}
+
+// nmgeodesic2ll returns the distance in nautical miles between two points
+// along the ellipsoid.
+func nmgeodesic2ll(a Point2LL, b Point2LL) float32 {
+	d, _, _ := geodesicInverse(a, b)
+	return d
+}
+
+// geodesicDirect returns the point reached by following the geodesic
+// with the given initial true bearing from p for the given distance in
+// nautical miles.
+func geodesicDirect(p Point2LL, bearing float32, dist float32) Point2LL {
+	rad := func(d float32) float64 { return float64(d) / 180 * math.Pi }
+	phi1 := rad(p[1])
+	sinAlpha1, cosAlpha1 := math.Sincos(rad(bearing))
+	s := float64(dist) * metresPerNm
+
+	tanU1 := (1 - wgs84F) * math.Tan(phi1)
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cosSqAlpha := 1 - sinAlpha*sinAlpha
+	uSq := cosSqAlpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+
+	sigma := s / (wgs84B * A)
+	var sinSigma, cosSigma, cos2SigmaM float64
+	for i := 0; i < vincentyIters; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		prev := sigma
+		sigma = s/(wgs84B*A) + deltaSigma
+		if math.Abs(sigma-prev) < vincentyEps {
+			break
+		}
+	}
+	sinSigma, cosSigma = math.Sincos(sigma)
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	phi2 := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1,
+		(1-wgs84F)*math.Sqrt(sinAlpha*sinAlpha+x*x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	C := wgs84F / 16 * cosSqAlpha * (4 + wgs84F*(4-3*cosSqAlpha))
+	L := lambda - (1-C)*wgs84F*sinAlpha*
+		(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+
+	long := float64(p[0]) + L*180/math.Pi
+	if long > 180 {
+		long -= 360
+	} else if long < -180 {
+		long += 360
+	}
+	return Point2LL{float32(long), float32(phi2 * 180 / math.Pi)}
+}
+
+// greatCircleBearing2ll returns the initial true bearing of the great
+// circle from a to b.
+func greatCircleBearing2ll(a Point2LL, b Point2LL) float32 {
+	rad := func(d float32) float64 { return float64(d) / 180 * math.Pi }
+	phi1, phi2 := rad(a[1]), rad(b[1])
+	dlon := rad(b[0]) - rad(a[0])
+	y := math.Sin(dlon) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dlon)
+	return NormalizeHeading(float32(math.Atan2(y, x) * 180 / math.Pi))
+}
+
+// greatCircleIntermediate2ll returns the point a fraction t of the way
+// along the great circle from a to b.
+func greatCircleIntermediate2ll(a Point2LL, b Point2LL, t float32) Point2LL {
+	rad := func(d float32) float64 { return float64(d) / 180 * math.Pi }
+	phi1, lambda1 := rad(a[1]), rad(a[0])
+	phi2, lambda2 := rad(b[1]), rad(b[0])
+
+	// Angular distance between the points
+	h := sqr(math.Sin((phi2-phi1)/2)) + math.Cos(phi1)*math.Cos(phi2)*sqr(math.Sin((lambda2-lambda1)/2))
+	delta := 2 * math.Atan2(math.Sqrt(h), math.Sqrt(1-h))
+	if delta == 0 {
+		return a
+	}
+
+	sd := math.Sin(delta)
+	wa, wb := math.Sin((1-float64(t))*delta)/sd, math.Sin(float64(t)*delta)/sd
+	x := wa*math.Cos(phi1)*math.Cos(lambda1) + wb*math.Cos(phi2)*math.Cos(lambda2)
+	y := wa*math.Cos(phi1)*math.Sin(lambda1) + wb*math.Cos(phi2)*math.Sin(lambda2)
+	z := wa*math.Sin(phi1) + wb*math.Sin(phi2)
+
+	return Point2LL{float32(math.Atan2(y, x) * 180 / math.Pi),
+		float32(math.Atan2(z, math.Sqrt(x*x+y*y)) * 180 / math.Pi)}
+}
+
 // Store Point2LLs as strings is JSON, for compactness/friendliness...
 func (p Point2LL) MarshalJSON() ([]byte, error) {
 	return []byte("\"" + p.DMSString() + "\""), nil
@@ -912,6 +1077,14 @@ func init() {
 	rand.r = pcg.NewPCG32()
 }
 
+// NewRand returns a separate generator with the given seed, for when a
+// reproducible sequence is needed.
+func NewRand(seed int64) *Rand {
+	r := &Rand{r: pcg.NewPCG32()}
+	r.Seed(seed)
+	return r
+}
+
 func (r *Rand) Seed(s int64) {
 	r.r.Seed(uint64(s), 0xda3e39cb94b95bdb)
 }
@@ -928,6 +1101,21 @@ func (r *Rand) Float32() float32 {
 	return float32(r.r.Random()) / (1<<32 - 1)
 }
 
+func (r *Rand) Perm(n int) []int {
+	p := make([]int, n)
+	for i := range p {
+		p[i] = i
+	}
+	r.Shuffle(n, func(i, j int) { p[i], p[j] = p[j], p[i] })
+	return p
+}
+
+func (r *Rand) Shuffle(n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		swap(i, r.Intn(i+1))
+	}
+}
+
 // PermutationElement returns the ith element of a random permutation of the
 // set of integers [0...,n-1].
 // i/n, p is hash, via Andrew Kensler
@@ -349,3 +349,40 @@ func TestLCM(t *testing.T) {
 		}
 	}
 }
+
+func TestGeodesic(t *testing.T) {
+	// Flinders Peak to Buninyong, the standard example from Vincenty's
+	// paper: 54972.271m, initial bearing 306.86816, final 307.17363.
+	p0 := Point2LL{144.42486789, -37.95103342}
+	p1 := Point2LL{143.92649554, -37.65282114}
+
+	d, b0, b1 := geodesicInverse(p0, p1)
+	if abs(d-54972.271/1852) > 0.001 {
+		t.Errorf("got distance %f, expected %f", d, 54972.271/1852)
+	}
+	if abs(b0-306.86816) > 0.001 || abs(b1-307.17363) > 0.001 {
+		t.Errorf("got bearings %f, %f, expected 306.86816, 307.17363", b0, b1)
+	}
+
+	p := geodesicDirect(p0, 306.86816, 54972.271/1852)
+	if abs(p[0]-p1[0]) > 1e-4 || abs(p[1]-p1[1]) > 1e-4 {
+		t.Errorf("got destination %v, expected %v", p, p1)
+	}
+
+	if d, _, _ := geodesicInverse(p0, p0); d != 0 {
+		t.Errorf("got distance %f between coincident points", d)
+	}
+
+	// Halfway between JFK and LAX along the great circle is well north
+	// of the midpoint of their latitudes.
+	jfk, lax := Point2LL{-73.7789, 40.6397}, Point2LL{-118.4081, 33.9425}
+	if mid := greatCircleIntermediate2ll(jfk, lax, 0.5); mid[1] < 39 || mid[0] > -95 || mid[0] < -99 {
+		t.Errorf("unexpected great circle midpoint %v", mid)
+	}
+	if p := greatCircleIntermediate2ll(jfk, lax, 0); abs(p[0]-jfk[0]) > 1e-4 || abs(p[1]-jfk[1]) > 1e-4 {
+		t.Errorf("got %v for start of great circle, expected %v", p, jfk)
+	}
+	if b := greatCircleBearing2ll(Point2LL{0, 0}, Point2LL{10, 0}); abs(b-90) > 1e-3 {
+		t.Errorf("got bearing %f, expected 90", b)
+	}
+}
@@ -0,0 +1,120 @@
+// midi.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"io"
+)
+
+// MIDIMessage is a channel voice message from a MIDI control surface;
+// only the messages that are useful for controls--note on and control
+// change--are reported.
+type MIDIMessage struct {
+	Channel int
+	// Note is true for note on messages, where Number is the note and
+	// Value the velocity; otherwise the message is a control change,
+	// where Number is the controller and Value its setting.
+	Note   bool
+	Number int
+	Value  int
+}
+
+// MIDIParser decodes a stream of MIDI bytes, including running status,
+// into MIDIMessages.
+type MIDIParser struct {
+	status byte
+	data   []byte
+}
+
+// Add processes the next byte from the stream, returning a message and
+// true if it completes one.
+func (mp *MIDIParser) Add(b byte) (MIDIMessage, bool) {
+	if b >= 0xf8 {
+		// System real-time messages may be interleaved anywhere.
+		return MIDIMessage{}, false
+	}
+	if b&0x80 != 0 {
+		if b >= 0xf0 {
+			// System common/exclusive; ignore it and its data, which also
+			// cancels running status.
+			mp.status = 0
+		} else {
+			mp.status = b
+		}
+		mp.data = mp.data[:0]
+		return MIDIMessage{}, false
+	}
+	if mp.status == 0 {
+		return MIDIMessage{}, false
+	}
+
+	mp.data = append(mp.data, b)
+	n := 2
+	if kind := mp.status & 0xf0; kind == 0xc0 || kind == 0xd0 {
+		n = 1
+	}
+	if len(mp.data) < n {
+		return MIDIMessage{}, false
+	}
+	data := mp.data
+	mp.data = mp.data[:0] // running status: keep mp.status
+
+	channel := int(mp.status & 0xf)
+	switch mp.status & 0xf0 {
+	case 0x90:
+		if data[1] == 0 {
+			// Note on with zero velocity is a note off.
+			return MIDIMessage{}, false
+		}
+		return MIDIMessage{Channel: channel, Note: true, Number: int(data[0]), Value: int(data[1])}, true
+	case 0xb0:
+		return MIDIMessage{Channel: channel, Number: int(data[0]), Value: int(data[1])}, true
+	}
+	return MIDIMessage{}, false
+}
+
+// MIDIDevice is an open MIDI input device; messages received from it are
+// sent to the channel returned by Messages.
+type MIDIDevice struct {
+	Name     string
+	r        io.ReadCloser
+	messages chan MIDIMessage
+}
+
+func newMIDIDevice(name string, r io.ReadCloser) *MIDIDevice {
+	d := &MIDIDevice{Name: name, r: r, messages: make(chan MIDIMessage, 64)}
+	go d.read()
+	return d
+}
+
+func (d *MIDIDevice) read() {
+	var parser MIDIParser
+	buf := make([]byte, 256)
+	for {
+		n, err := d.r.Read(buf)
+		for _, b := range buf[:n] {
+			if m, ok := parser.Add(b); ok {
+				select {
+				case d.messages <- m:
+				default:
+					// Drop messages if the UI isn't keeping up.
+				}
+			}
+		}
+		if err != nil {
+			lg.Infof("%s: MIDI device closed: %v", d.Name, err)
+			close(d.messages)
+			return
+		}
+	}
+}
+
+func (d *MIDIDevice) Messages() <-chan MIDIMessage {
+	return d.messages
+}
+
+func (d *MIDIDevice) Close() error {
+	return d.r.Close()
+}
@@ -0,0 +1,26 @@
+// midi_linux.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// OpenMIDIDevices opens all of the ALSA raw MIDI devices.
+func OpenMIDIDevices() []*MIDIDevice {
+	paths, _ := filepath.Glob("/dev/snd/midiC*D*")
+
+	var devices []*MIDIDevice
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			lg.Warnf("%s: unable to open MIDI device: %v", p, err)
+			continue
+		}
+		devices = append(devices, newMIDIDevice(filepath.Base(p), f))
+	}
+	return devices
+}
@@ -0,0 +1,13 @@
+// midi_other.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+//go:build !linux
+
+package main
+
+// OpenMIDIDevices returns the available MIDI input devices; MIDI input is
+// currently only supported on Linux.
+func OpenMIDIDevices() []*MIDIDevice {
+	return nil
+}
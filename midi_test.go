@@ -0,0 +1,45 @@
+// midi_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestMIDIParser(t *testing.T) {
+	stream := []byte{
+		0x90, 60, 100, // note on, channel 0
+		62, 0, // running status note on with zero velocity: note off
+		0xf8,              // timing clock in the middle of things
+		0xb3, 7, 0xf8, 64, // control change on channel 3, interrupted by a clock
+		10, 127, // running status control change
+		0xc0, 5, // program change: ignored
+		0xf0, 1, 2, 3, 0xf7, // sysex: ignored
+		20, 30, // no running status after sysex
+		0x80, 60, 0, // note off: ignored
+	}
+	expected := []MIDIMessage{
+		MIDIMessage{Channel: 0, Note: true, Number: 60, Value: 100},
+		MIDIMessage{Channel: 3, Number: 7, Value: 64},
+		MIDIMessage{Channel: 3, Number: 10, Value: 127},
+	}
+
+	var mp MIDIParser
+	var msgs []MIDIMessage
+	for _, b := range stream {
+		if m, ok := mp.Add(b); ok {
+			msgs = append(msgs, m)
+		}
+	}
+
+	if len(msgs) != len(expected) {
+		t.Fatalf("got %d messages %+v, expected %d", len(msgs), msgs, len(expected))
+	}
+	for i := range msgs {
+		if msgs[i] != expected[i] {
+			t.Errorf("message %d: got %+v, expected %+v", i, msgs[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// modes.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Mode S downlinked data: aircraft with Mode S enhanced surveillance or
+// ADS-B report their selected altitude, magnetic heading, and ground
+// speed, which STARS can show in their full datablocks. The values come
+// straight from the aircraft's navigation state, so a wrong selected
+// altitude or heading is visible before the aircraft starts to deviate.
+//
+// The equipment suffix doesn't say whether an aircraft has Mode S; we
+// assume that aircraft with RVSM or GNSS and Mode C do, as do aircraft
+// without a suffix.
+
+import (
+	"fmt"
+)
+
+var modeSEquipmentSuffixes = map[string]interface{}{
+	"G": nil, "L": nil, "W": nil, "Z": nil,
+}
+
+// ModeSEquipped returns true if the aircraft downlinks Mode S data.
+func (fp FlightPlan) ModeSEquipped() bool {
+	suffix := fp.EquipmentSuffix()
+	_, ok := modeSEquipmentSuffixes[suffix]
+	return suffix == "" || ok
+}
+
+type ModeSData struct {
+	SelectedAltitude int // feet
+	Heading          int // magnetic
+	Groundspeed      int // knots
+}
+
+// SelectedAltitude returns the altitude that the pilot has set in the
+// altitude selector: the controller's assignment if there is one and
+// otherwise the altitude that the aircraft is cleared to.
+func (nav *Nav) SelectedAltitude() float32 {
+	if nav.Altitude.Assigned != nil {
+		return *nav.Altitude.Assigned
+	} else if nav.Altitude.Cleared != nil {
+		return *nav.Altitude.Cleared
+	} else if ar := nav.Altitude.Restriction; ar != nil {
+		return ar.TargetAltitude(nav.FlightState.Altitude)
+	}
+	return nav.FinalAltitude
+}
+
+// ModeSData returns the aircraft's downlinked data if it is Mode S
+// equipped and its transponder is on.
+func (ac *Aircraft) ModeSData() (ModeSData, bool) {
+	if ac.FlightPlan == nil || !ac.FlightPlan.ModeSEquipped() || ac.Mode != Charlie {
+		return ModeSData{}, false
+	}
+
+	hdg := int(ac.Nav.FlightState.Heading + 0.5)
+	if hdg <= 0 {
+		hdg += 360
+	} else if hdg > 360 {
+		hdg -= 360
+	}
+	return ModeSData{
+		SelectedAltitude: int(ac.Nav.SelectedAltitude()),
+		Heading:          hdg,
+		Groundspeed:      int(ac.Nav.FlightState.GS + 0.5),
+	}, true
+}
+
+// DatablockFields returns the data formatted for a datablock, to be
+// time-shared in a single field.
+func (d ModeSData) DatablockFields() []string {
+	return []string{
+		fmt.Sprintf("S%03d", (d.SelectedAltitude+50)/100),
+		fmt.Sprintf("H%03d", d.Heading),
+		fmt.Sprintf("G%03d", d.Groundspeed),
+	}
+}
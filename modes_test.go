@@ -0,0 +1,56 @@
+// modes_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestModeSData(t *testing.T) {
+	for _, test := range []struct {
+		actype   string
+		equipped bool
+	}{
+		{"B738/L", true},
+		{"H/B77W/Z", true},
+		{"A320", true},
+		{"C172/U", false},
+		{"BE58/A", false},
+	} {
+		fp := FlightPlan{AircraftType: test.actype}
+		if fp.ModeSEquipped() != test.equipped {
+			t.Errorf("%s: expected Mode S equipped %v", test.actype, test.equipped)
+		}
+	}
+
+	alt := float32(11000)
+	ac := &Aircraft{
+		FlightPlan: &FlightPlan{AircraftType: "B738/L"},
+		Mode:       Charlie,
+	}
+	ac.Nav.FlightState.Heading = 359.7
+	ac.Nav.FlightState.GS = 249.6
+	ac.Nav.FinalAltitude = 35000
+	ac.Nav.Altitude.Assigned = &alt
+
+	d, ok := ac.ModeSData()
+	if !ok {
+		t.Fatalf("expected Mode S data")
+	}
+	if fields := d.DatablockFields(); !slices.Equal(fields, []string{"S110", "H360", "G250"}) {
+		t.Errorf("unexpected datablock fields %v", fields)
+	}
+
+	ac.Nav.Altitude.Assigned = nil
+	if sel := ac.Nav.SelectedAltitude(); sel != 35000 {
+		t.Errorf("expected selected altitude 35000, got %.0f", sel)
+	}
+
+	ac.Mode = Standby
+	if _, ok := ac.ModeSData(); ok {
+		t.Errorf("unexpected Mode S data with the transponder in standby")
+	}
+}
@@ -194,7 +194,7 @@ func makeNav(w *World, fp FlightPlan, perf AircraftPerformance, wp []Waypoint) *
 	}
 
 	nav.FlightState = FlightState{
-		MagneticVariation: w.MagneticVariation,
+		MagneticVariation: w.MagneticVariationAt(nav.Waypoints[0].Location),
 		NmPerLongitude:    w.NmPerLongitude,
 		Position:          nav.Waypoints[0].Location,
 		Heading:           float32(nav.Waypoints[0].Heading),
@@ -1393,10 +1393,11 @@ func (nav *Nav) distanceToEndOfApproach() (float32, error) {
 		return remainingDistance, nil
 	} else {
 		// Distance to the next fix plus sum of the distances between
-		// remaining fixes.
-		remainingDistance := nmdistance2ll(nav.FlightState.Position, wp[0].Location)
+		// remaining fixes. Legs may be long for en-route arrivals, so
+		// distances are computed along the ellipsoid.
+		remainingDistance := nmgeodesic2ll(nav.FlightState.Position, wp[0].Location)
 		for i := 0; i < len(wp)-1; i++ {
-			remainingDistance += nmdistance2ll(wp[i].Location, wp[i+1].Location)
+			remainingDistance += nmgeodesic2ll(wp[i].Location, wp[i+1].Location)
 		}
 
 		return remainingDistance, nil
@@ -0,0 +1,158 @@
+// netsim.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Simulated network problems: to test how the client copes with a poor
+// connection (timeouts, reconnects, stale state) without needing an
+// actual bad network, the -netlatency, -netjitter, and -netloss options
+// degrade the connection to the local sim server, e.g.:
+//
+//	vice -netlatency 150ms -netjitter 50ms -netloss 0.02
+//
+// They apply separately to the data sent in each direction, so the round
+// trip time is twice the latency. Each write is delivered after the
+// latency plus a random amount of jitter. With the given probability,
+// the write is "lost" and is only delivered after a retransmission
+// timeout, which holds up everything sent after it, as happens with TCP.
+
+import (
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Linux's minimum TCP retransmission timeout.
+const minRetransmitTimeout = 200 * time.Millisecond
+
+type DegradedConn struct {
+	net.Conn
+	latency, jitter time.Duration
+	loss            float32
+
+	// Data read from the connection by readLoop, waiting to be delivered.
+	readCh  chan delayedChunk
+	pending []byte
+	readErr error
+
+	// Data written by the caller, waiting to be sent by writeLoop.
+	mu        sync.Mutex
+	closed    bool
+	writeCh   chan delayedChunk
+	writeErr  atomic.Pointer[error]
+	writeRand *Rand
+	lastWrite time.Time
+}
+
+type delayedChunk struct {
+	b       []byte
+	deliver time.Time
+	err     error
+}
+
+func netSimEnabled() bool {
+	return *netLatency > 0 || *netJitter > 0 || *netLoss > 0
+}
+
+func MakeDegradedConn(c net.Conn, latency, jitter time.Duration, loss float32) *DegradedConn {
+	dc := &DegradedConn{
+		Conn:      c,
+		latency:   latency,
+		jitter:    jitter,
+		loss:      loss,
+		readCh:    make(chan delayedChunk, 256),
+		writeCh:   make(chan delayedChunk, 256),
+		writeRand: NewRand(time.Now().UnixNano()),
+	}
+	go dc.readLoop(NewRand(time.Now().UnixNano() + 1))
+	go dc.writeLoop()
+	return dc
+}
+
+// deliveryTime returns the time at which data sent now should be
+// delivered, given the time the previous data is delivered; data is
+// always delivered in order.
+func (c *DegradedConn) deliveryTime(last time.Time, r *Rand) time.Time {
+	d := c.latency + time.Duration(r.Float32()*float32(c.jitter))
+	if r.Float32() < c.loss {
+		d += max(minRetransmitTimeout, 2*(c.latency+c.jitter))
+	}
+	if t := time.Now().Add(d); t.After(last) {
+		return t
+	}
+	return last
+}
+
+func (c *DegradedConn) readLoop(r *Rand) {
+	var last time.Time
+	for {
+		buf := make([]byte, 32*1024)
+		n, err := c.Conn.Read(buf)
+		last = c.deliveryTime(last, r)
+		c.readCh <- delayedChunk{b: buf[:n], deliver: last, err: err}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *DegradedConn) Read(b []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		chunk := <-c.readCh
+		time.Sleep(time.Until(chunk.deliver))
+		c.pending, c.readErr = chunk.b, chunk.err
+	}
+
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *DegradedConn) writeLoop() {
+	for chunk := range c.writeCh {
+		if c.writeErr.Load() != nil {
+			// Discard everything after an error.
+			continue
+		}
+		time.Sleep(time.Until(chunk.deliver))
+		if _, err := c.Conn.Write(chunk.b); err != nil {
+			c.writeErr.Store(&err)
+		}
+	}
+}
+
+// Write queues the data to be sent after the simulated delay; as with a
+// real network connection, the caller doesn't wait for it to be
+// delivered.
+func (c *DegradedConn) Write(b []byte) (int, error) {
+	if err := c.writeErr.Load(); err != nil {
+		return 0, *err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+
+	c.lastWrite = c.deliveryTime(c.lastWrite, c.writeRand)
+	c.writeCh <- delayedChunk{b: slices.Clone(b), deliver: c.lastWrite}
+	return len(b), nil
+}
+
+func (c *DegradedConn) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.writeCh)
+	}
+	c.mu.Unlock()
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,51 @@
+// netsim_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDegradedConn(t *testing.T) {
+	a, b := net.Pipe()
+	const latency = 20 * time.Millisecond
+	dc := MakeDegradedConn(a, latency, 10*time.Millisecond, 0.3)
+	defer dc.Close()
+
+	// Both directions should be delayed but still deliver everything in
+	// order.
+	check := func(name string, w io.Writer, r io.Reader) {
+		start := time.Now()
+		go func() {
+			for i := 0; i < 20; i++ {
+				if _, err := w.Write([]byte{byte(i)}); err != nil {
+					t.Errorf("%s: write: %v", name, err)
+				}
+			}
+		}()
+
+		var buf [1]byte
+		for i := 0; i < 20; i++ {
+			if _, err := io.ReadFull(r, buf[:]); err != nil {
+				t.Fatalf("%s: read: %v", name, err)
+			} else if buf[0] != byte(i) {
+				t.Fatalf("%s: got %d, expected %d", name, buf[0], i)
+			}
+		}
+		if d := time.Since(start); d < latency {
+			t.Errorf("%s: data delivered after %s; expected at least %s", name, d, latency)
+		}
+	}
+	check("read", b, dc)
+	check("write", dc, b)
+
+	dc.Close()
+	if _, err := dc.Write([]byte{0}); err == nil {
+		t.Errorf("expected an error writing to a closed connection")
+	}
+}
@@ -17,11 +17,13 @@ import (
 
 type OpenGL2Renderer struct {
 	createdTextures map[uint32]int
+	lg              *Logger
 }
 
 // NewOpenGL2Renderer creates an OpenGL context and creates a texture for the imgui fonts.
 // Thus, all font creation must be finished before the renderer is created.
 func NewOpenGL2Renderer() (Renderer, error) {
+	lg := lg.Subsystem("renderer")
 	lg.Info("Starting OpenGL2Renderer initialization")
 	if err := gl.Init(); err != nil {
 		return nil, fmt.Errorf("failed to initialize OpenGL: %w", err)
@@ -33,6 +35,7 @@ func NewOpenGL2Renderer() (Renderer, error) {
 	lg.Info("Finished OpenGL2Renderer initialization")
 	return &OpenGL2Renderer{
 		createdTextures: make(map[uint32]int),
+		lg:              lg,
 	}, nil
 }
 
@@ -52,9 +55,9 @@ func (ogl2 *OpenGL2Renderer) createdTexture(texid uint32, bytes int) {
 	mb := float32(total) / (1024 * 1024)
 
 	if exists {
-		lg.Infof("Updated tex id %d: %d bytes -> %.2f MiB of textures total", texid, bytes, mb)
+		ogl2.lg.Infof("Updated tex id %d: %d bytes -> %.2f MiB of textures total", texid, bytes, mb)
 	} else {
-		lg.Infof("Created tex id %d: %d bytes -> %.2f MiB of textures total", texid, bytes, mb)
+		ogl2.lg.Infof("Created tex id %d: %d bytes -> %.2f MiB of textures total", texid, bytes, mb)
 	}
 }
 
@@ -110,6 +113,17 @@ func (ogl2 *OpenGL2Renderer) DestroyTexture(texid uint32) {
 	delete(ogl2.createdTextures, texid)
 }
 
+func (ogl2 *OpenGL2Renderer) ReadPixels(x, y, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width > 0 && height > 0 {
+		gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+		gl.ReadPixels(int32(x), int32(y), int32(width), int32(height), gl.RGBA, gl.UNSIGNED_BYTE,
+			unsafe.Pointer(&img.Pix[0]))
+		finishReadPixels(img)
+	}
+	return img
+}
+
 func (ogl2 *OpenGL2Renderer) RenderCommandBuffer(cb *CommandBuffer) RendererStats {
 	var stats RendererStats
 	stats.nBuffers++
@@ -264,11 +278,76 @@ func (ogl2 *OpenGL2Renderer) RenderCommandBuffer(cb *CommandBuffer) RendererStat
 			offset := ui32()
 			ptr := uintptr(unsafe.Pointer(&cb.Buf[0])) + uintptr(offset)
 			count := i32()
+
+			// Antialias lines; blending is required for that, so enable
+			// it if needed and then restore the current state afterward.
+			blend := gl.IsEnabled(gl.BLEND)
+			if !blend {
+				gl.Enable(gl.BLEND)
+				gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+			}
+			gl.Enable(gl.LINE_SMOOTH)
+			gl.Hint(gl.LINE_SMOOTH_HINT, gl.NICEST)
+
 			gl.DrawElements(gl.LINES, count, gl.UNSIGNED_INT, unsafe.Pointer(ptr))
 
+			gl.Disable(gl.LINE_SMOOTH)
+			if !blend {
+				gl.Disable(gl.BLEND)
+			}
+
 			stats.nDrawCalls++
 			stats.nLines += int(count / 2)
 
+		case RendererDrawLinesInstanced:
+			offset := ui32()
+			ptr := uintptr(unsafe.Pointer(&cb.Buf[0])) + uintptr(offset)
+			count := i32()
+			positionsOffset := int(ui32())
+			colorsOffset := int(ui32())
+			n := int(i32())
+			positions := cb.FloatSlice(positionsOffset, 2*n)
+			colors := cb.FloatSlice(colorsOffset, 3*n)
+
+			// There's no instancing in OpenGL 2.1, so draw each instance
+			// separately with the modelview matrix translated to its
+			// position.
+			blend := gl.IsEnabled(gl.BLEND)
+			if !blend {
+				gl.Enable(gl.BLEND)
+				gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+			}
+			gl.Enable(gl.LINE_SMOOTH)
+			gl.Hint(gl.LINE_SMOOTH_HINT, gl.NICEST)
+			gl.DisableClientState(gl.COLOR_ARRAY)
+			gl.MatrixMode(gl.MODELVIEW)
+
+			for j := 0; j < n; j++ {
+				gl.Color3f(colors[3*j], colors[3*j+1], colors[3*j+2])
+				gl.PushMatrix()
+				gl.Translatef(positions[2*j], positions[2*j+1], 0)
+				gl.DrawElements(gl.LINES, count, gl.UNSIGNED_INT, unsafe.Pointer(ptr))
+				gl.PopMatrix()
+			}
+
+			gl.Disable(gl.LINE_SMOOTH)
+			if !blend {
+				gl.Disable(gl.BLEND)
+			}
+
+			stats.nDrawCalls += n
+			stats.nLines += n * int(count/2)
+
+		case RendererLineStipple:
+			factor := i32()
+			pattern := ui32()
+			if factor == 0 {
+				gl.Disable(gl.LINE_STIPPLE)
+			} else {
+				gl.Enable(gl.LINE_STIPPLE)
+				gl.LineStipple(factor, uint16(pattern))
+			}
+
 		case RendererDrawTriangles:
 			offset := ui32()
 			ptr := uintptr(unsafe.Pointer(&cb.Buf[0])) + uintptr(offset)
@@ -295,6 +374,7 @@ func (ogl2 *OpenGL2Renderer) RenderCommandBuffer(cb *CommandBuffer) RendererStat
 			gl.DisableClientState(gl.COLOR_ARRAY)
 			gl.DisableClientState(gl.TEXTURE_COORD_ARRAY)
 			gl.Disable(gl.TEXTURE_2D)
+			gl.Disable(gl.LINE_STIPPLE)
 
 		case RendererCallBuffer:
 			idx := ui32()
@@ -302,7 +382,7 @@ func (ogl2 *OpenGL2Renderer) RenderCommandBuffer(cb *CommandBuffer) RendererStat
 			stats.Merge(s2)
 
 		default:
-			lg.Error("unhandled command")
+			ogl2.lg.Error("unhandled command")
 		}
 	}
 
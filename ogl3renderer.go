@@ -0,0 +1,738 @@
+// ogl3renderer.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// OpenGL3Renderer implements the Renderer interface using the OpenGL 3.3
+// core profile, which is required for modern OpenGL on macOS. Each
+// CommandBuffer is uploaded to a vertex buffer object that serves as both
+// the vertex and the index buffer for its draw calls. Fixed-function
+// features that aren't available in the core profile are emulated: lines
+// are expanded to quads by a geometry shader and then antialiased and
+// stippled in the fragment shader, round points are rasterized in the
+// fragment shader, and quads are drawn as pairs of triangles. Instanced
+// lines use per-instance position offset and color attributes.
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"strings"
+	"unsafe"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+const ogl3VertexShader = `
+#version 330 core
+layout(location = 0) in vec4 position;
+layout(location = 1) in vec4 color;
+layout(location = 2) in vec2 texcoord;
+// Zero, unless drawing instances.
+layout(location = 3) in vec2 instanceOffset;
+
+uniform mat4 projection;
+uniform mat4 modelview;
+uniform float pointSize;
+
+out VertexData {
+	vec4 color;
+	vec2 texcoord;
+} vout;
+
+void main() {
+	gl_Position = projection * modelview * (position + vec4(instanceOffset, 0.0, 0.0));
+	gl_PointSize = pointSize;
+	vout.color = color;
+	vout.texcoord = texcoord;
+}
+`
+
+// Expands each line segment into a screen-aligned quad that covers the
+// line plus a one pixel border for antialiasing. Each vertex records its
+// signed distance from the line's center and its distance along the
+// line, both in pixels, for use in the fragment shader.
+const ogl3LineGeometryShader = `
+#version 330 core
+layout(lines) in;
+layout(triangle_strip, max_vertices = 4) out;
+
+uniform vec2 viewportSize;
+uniform float lineWidth;
+
+in VertexData {
+	vec4 color;
+	vec2 texcoord;
+} gin[];
+
+out LineData {
+	vec4 color;
+	noperspective float edgeDistance;
+	noperspective float linePosition;
+} gout;
+
+void main() {
+	vec4 p0 = gl_in[0].gl_Position, p1 = gl_in[1].gl_Position;
+	// From NDC to pixels.
+	vec2 scale = 0.5 * viewportSize;
+	vec2 dir = (p1.xy / p1.w - p0.xy / p0.w) * scale;
+	float len = length(dir);
+	dir = len > 0.0 ? dir / len : vec2(1.0, 0.0);
+	vec2 normal = vec2(-dir.y, dir.x);
+	float halfWidth = 0.5 * max(lineWidth, 1.0) + 1.0;
+
+	for (int i = 0; i < 2; ++i) {
+		vec4 p = gl_in[i].gl_Position;
+		for (int side = -1; side <= 1; side += 2) {
+			vec2 offset = normal * float(side) * halfWidth / scale;
+			gl_Position = vec4(p.xy + offset * p.w, p.zw);
+			gout.color = gin[i].color;
+			gout.edgeDistance = float(side) * halfWidth;
+			gout.linePosition = float(i) * len;
+			EmitVertex();
+		}
+	}
+	EndPrimitive();
+}
+`
+
+// Computes the pixel's coverage by the line for antialiasing and applies
+// the stipple pattern, if any.
+const ogl3LineFragmentShader = `
+#version 330 core
+in LineData {
+	vec4 color;
+	noperspective float edgeDistance;
+	noperspective float linePosition;
+} fin;
+
+uniform float lineWidth;
+uniform int stippleFactor;
+uniform int stipplePattern;
+
+out vec4 fragColor;
+
+void main() {
+	if (stippleFactor > 0) {
+		int bit = int(fin.linePosition / float(stippleFactor)) & 15;
+		if ((stipplePattern & (1 << bit)) == 0)
+			discard;
+	}
+
+	vec4 c = fin.color;
+	float halfWidth = 0.5 * max(lineWidth, 1.0);
+	c.a *= clamp(halfWidth + 0.5 - abs(fin.edgeDistance), 0.0, 1.0);
+	if (c.a == 0.0)
+		discard;
+	fragColor = c;
+}
+`
+
+const ogl3FragmentShader = `
+#version 330 core
+in VertexData {
+	vec4 color;
+	vec2 texcoord;
+} fin;
+
+uniform sampler2D tex;
+uniform bool useTexture;
+uniform bool roundPoints;
+
+out vec4 fragColor;
+
+void main() {
+	vec4 c = fin.color;
+	if (useTexture)
+		c *= texture(tex, fin.texcoord);
+	if (roundPoints) {
+		// Antialiased disk, as with GL_POINT_SMOOTH.
+		float r = length(2.0 * gl_PointCoord - 1.0);
+		float w = fwidth(r);
+		c.a *= 1.0 - smoothstep(1.0 - w, 1.0, r);
+		if (c.a == 0.0)
+			discard;
+	}
+	fragColor = c;
+}
+`
+
+// Vertex attribute indices
+const (
+	ogl3Position       = 0
+	ogl3Color          = 1
+	ogl3TexCoord       = 2
+	ogl3InstanceOffset = 3
+)
+
+type ogl3Program struct {
+	id                                                     uint32
+	projection, modelview, pointSize, useTexture, roundPts int32
+	viewportSize, lineWidth, stippleFactor, stipplePattern int32
+}
+
+// ogl3State records the fixed-function state that is maintained in
+// shader uniforms.
+type ogl3State struct {
+	projection, modelview [16]float32
+	pointSize, lineWidth  float32
+	viewportSize          [2]float32
+	stippleFactor         int32
+	stipplePattern        int32
+	useTexture            bool
+	roundPoints           bool
+	blend                 bool
+}
+
+// ogl3Buffers stores the vertex array and vertex buffer objects used for
+// rendering a command buffer. CallBuffer commands are rendered using the
+// buffers for the next nesting level so that the caller's state is
+// preserved.
+type ogl3Buffers struct {
+	vao, vbo uint32
+}
+
+type OpenGL3Renderer struct {
+	createdTextures map[uint32]int
+	lg              *Logger
+
+	basic, lines ogl3Program
+	state        ogl3State
+
+	// Vertex array objects aren't shared between OpenGL contexts, so
+	// they're maintained separately for each window.
+	buffers map[*glfw.Window][]ogl3Buffers
+	// Index buffer used when drawing quads as triangles.
+	quadIndexBuffer uint32
+	quadIndices     []uint32
+}
+
+// NewOpenGL3Renderer returns a renderer that uses the OpenGL 3.3 core
+// profile; an OpenGL 3.3 context must be current.
+func NewOpenGL3Renderer() (Renderer, error) {
+	lg := lg.Subsystem("renderer")
+	lg.Info("Starting OpenGL3Renderer initialization")
+	if err := gl.Init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize OpenGL: %w", err)
+	}
+	lg.Infof("OpenGL vendor %s renderer %s version %s", gl.GoStr(gl.GetString(gl.VENDOR)),
+		gl.GoStr(gl.GetString(gl.RENDERER)), gl.GoStr(gl.GetString(gl.VERSION)))
+
+	ogl3 := &OpenGL3Renderer{
+		createdTextures: make(map[uint32]int),
+		lg:              lg,
+		buffers:         make(map[*glfw.Window][]ogl3Buffers),
+	}
+
+	var err error
+	if ogl3.basic, err = ogl3NewProgram(ogl3VertexShader, "", ogl3FragmentShader); err != nil {
+		return nil, err
+	}
+	if ogl3.lines, err = ogl3NewProgram(ogl3VertexShader, ogl3LineGeometryShader,
+		ogl3LineFragmentShader); err != nil {
+		return nil, err
+	}
+
+	gl.GenBuffers(1, &ogl3.quadIndexBuffer)
+
+	gl.Enable(gl.PROGRAM_POINT_SIZE)
+	gl.Enable(gl.MULTISAMPLE)
+
+	glfwReleaseContextCallbacks = append(glfwReleaseContextCallbacks, ogl3.releaseContext)
+
+	lg.Info("Finished OpenGL3Renderer initialization")
+	return ogl3, nil
+}
+
+func ogl3CompileShader(source string, shaderType uint32) (uint32, error) {
+	shader := gl.CreateShader(shaderType)
+	csource, free := gl.Strs(source + "\x00")
+	gl.ShaderSource(shader, 1, csource, nil)
+	free()
+	gl.CompileShader(shader)
+
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
+		return 0, fmt.Errorf("failed to compile shader: %s", strings.TrimRight(log, "\x00"))
+	}
+	return shader, nil
+}
+
+// ogl3NewProgram compiles and links a shader program; the geometry
+// shader is optional.
+func ogl3NewProgram(vs, gs, fs string) (ogl3Program, error) {
+	sources := map[uint32]string{gl.VERTEX_SHADER: vs, gl.FRAGMENT_SHADER: fs}
+	if gs != "" {
+		sources[gl.GEOMETRY_SHADER] = gs
+	}
+
+	id := gl.CreateProgram()
+	for shaderType, src := range sources {
+		shader, err := ogl3CompileShader(src, shaderType)
+		if err != nil {
+			gl.DeleteProgram(id)
+			return ogl3Program{}, err
+		}
+		gl.AttachShader(id, shader)
+		// It's freed when the program is.
+		gl.DeleteShader(shader)
+	}
+	gl.LinkProgram(id)
+
+	var status int32
+	gl.GetProgramiv(id, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(id, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(id, logLength, nil, gl.Str(log))
+		gl.DeleteProgram(id)
+		return ogl3Program{}, fmt.Errorf("failed to link program: %s", strings.TrimRight(log, "\x00"))
+	}
+
+	loc := func(name string) int32 { return gl.GetUniformLocation(id, gl.Str(name+"\x00")) }
+	p := ogl3Program{
+		id:           id,
+		projection:   loc("projection"),
+		modelview:    loc("modelview"),
+		pointSize:    loc("pointSize"),
+		useTexture:   loc("useTexture"),
+		roundPts:     loc("roundPoints"),
+		viewportSize: loc("viewportSize"),
+		lineWidth:    loc("lineWidth"),
+
+		stippleFactor:  loc("stippleFactor"),
+		stipplePattern: loc("stipplePattern"),
+	}
+	gl.UseProgram(id)
+	gl.Uniform1i(loc("tex"), 0)
+	gl.UseProgram(0)
+
+	return p, nil
+}
+
+func (ogl3 *OpenGL3Renderer) Dispose() {
+	for texid := range ogl3.createdTextures {
+		gl.DeleteTextures(1, &texid)
+	}
+	for _, bufs := range ogl3.buffers {
+		for _, b := range bufs {
+			gl.DeleteBuffers(1, &b.vbo)
+		}
+	}
+	gl.DeleteBuffers(1, &ogl3.quadIndexBuffer)
+	gl.DeleteProgram(ogl3.basic.id)
+	gl.DeleteProgram(ogl3.lines.id)
+}
+
+// releaseContext frees the vertex array and buffer objects for the given
+// window's context, which must be current.
+func (ogl3 *OpenGL3Renderer) releaseContext(w *glfw.Window) {
+	for _, b := range ogl3.buffers[w] {
+		gl.DeleteVertexArrays(1, &b.vao)
+		gl.DeleteBuffers(1, &b.vbo)
+	}
+	delete(ogl3.buffers, w)
+}
+
+func (ogl3 *OpenGL3Renderer) createdTexture(texid uint32, bytes int) {
+	_, exists := ogl3.createdTextures[texid]
+
+	ogl3.createdTextures[texid] = bytes
+
+	reduce := func(id uint32, bytes int, total int) int { return total + bytes }
+	total := ReduceMap[uint32, int, int](ogl3.createdTextures, reduce, 0)
+	mb := float32(total) / (1024 * 1024)
+
+	if exists {
+		ogl3.lg.Infof("Updated tex id %d: %d bytes -> %.2f MiB of textures total", texid, bytes, mb)
+	} else {
+		ogl3.lg.Infof("Created tex id %d: %d bytes -> %.2f MiB of textures total", texid, bytes, mb)
+	}
+}
+
+func (ogl3 *OpenGL3Renderer) CreateTextureFromImage(img image.Image, magNearest bool) uint32 {
+	return ogl3.CreateTextureFromImages([]image.Image{img}, magNearest)
+}
+
+func (ogl3 *OpenGL3Renderer) CreateTextureFromImages(pyramid []image.Image, magNearest bool) uint32 {
+	var texid uint32
+	gl.GenTextures(1, &texid)
+	ogl3.UpdateTextureFromImages(texid, pyramid, magNearest)
+	return texid
+}
+
+func (ogl3 *OpenGL3Renderer) UpdateTextureFromImage(texid uint32, img image.Image, magNearest bool) {
+	ogl3.UpdateTextureFromImages(texid, []image.Image{img}, magNearest)
+}
+
+func (ogl3 *OpenGL3Renderer) UpdateTextureFromImages(texid uint32, pyramid []image.Image, magNearest bool) {
+	var lastTexture int32
+	gl.GetIntegerv(gl.TEXTURE_BINDING_2D, &lastTexture)
+
+	gl.BindTexture(gl.TEXTURE_2D, texid)
+	if len(pyramid) == 1 {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	} else {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, int32(Select(magNearest, gl.NEAREST, gl.LINEAR)))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, int32(len(pyramid)-1))
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, 0)
+
+	bytes := 0
+	for level, img := range pyramid {
+		ny, nx := img.Bounds().Dy(), img.Bounds().Dx()
+		bytes += 4 * nx * ny
+
+		rgba, ok := img.(*image.RGBA)
+		if !ok {
+			rgba = image.NewRGBA(image.Rect(0, 0, nx, ny))
+			draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+		}
+		gl.TexImage2D(gl.TEXTURE_2D, int32(level), gl.RGBA, int32(nx), int32(ny), 0, gl.RGBA,
+			gl.UNSIGNED_BYTE, unsafe.Pointer(&rgba.Pix[0]))
+	}
+
+	gl.BindTexture(gl.TEXTURE_2D, uint32(lastTexture))
+
+	ogl3.createdTexture(texid, bytes)
+}
+
+func (ogl3 *OpenGL3Renderer) DestroyTexture(texid uint32) {
+	gl.DeleteTextures(1, &texid)
+	delete(ogl3.createdTextures, texid)
+}
+
+func (ogl3 *OpenGL3Renderer) ReadPixels(x, y, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width > 0 && height > 0 {
+		gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+		gl.ReadPixels(int32(x), int32(y), int32(width), int32(height), gl.RGBA, gl.UNSIGNED_BYTE,
+			unsafe.Pointer(&img.Pix[0]))
+		finishReadPixels(img)
+	}
+	return img
+}
+
+// bindBuffers binds the vertex array and buffer objects for the given
+// nesting level of command buffers in the current context, creating them
+// if necessary.
+func (ogl3 *OpenGL3Renderer) bindBuffers(depth int) {
+	ctx := glfw.GetCurrentContext()
+	bufs := ogl3.buffers[ctx]
+	for len(bufs) <= depth {
+		var b ogl3Buffers
+		gl.GenVertexArrays(1, &b.vao)
+		gl.GenBuffers(1, &b.vbo)
+		bufs = append(bufs, b)
+	}
+	ogl3.buffers[ctx] = bufs
+
+	gl.BindVertexArray(bufs[depth].vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, bufs[depth].vbo)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, bufs[depth].vbo)
+}
+
+// useProgram makes the given program current and updates its uniforms
+// to match the current state.
+func (ogl3 *OpenGL3Renderer) useProgram(p *ogl3Program) {
+	s := &ogl3.state
+	b2i := func(b bool) int32 { return int32(Select(b, 1, 0)) }
+
+	gl.UseProgram(p.id)
+	gl.UniformMatrix4fv(p.projection, 1, false, &s.projection[0])
+	gl.UniformMatrix4fv(p.modelview, 1, false, &s.modelview[0])
+	gl.Uniform1f(p.pointSize, s.pointSize)
+	gl.Uniform1i(p.useTexture, b2i(s.useTexture))
+	gl.Uniform1i(p.roundPts, b2i(s.roundPoints))
+	gl.Uniform2f(p.viewportSize, s.viewportSize[0], s.viewportSize[1])
+	gl.Uniform1f(p.lineWidth, s.lineWidth)
+	gl.Uniform1i(p.stippleFactor, s.stippleFactor)
+	gl.Uniform1i(p.stipplePattern, s.stipplePattern)
+}
+
+func (ogl3 *OpenGL3Renderer) RenderCommandBuffer(cb *CommandBuffer) RendererStats {
+	stats := ogl3.renderCommandBuffer(cb, 0)
+	gl.BindVertexArray(0)
+	gl.UseProgram(0)
+	return stats
+}
+
+func (ogl3 *OpenGL3Renderer) renderCommandBuffer(cb *CommandBuffer, depth int) RendererStats {
+	var stats RendererStats
+	stats.nBuffers++
+	stats.bufferBytes += 4 * len(cb.Buf)
+	if len(cb.Buf) == 0 {
+		return stats
+	}
+
+	ogl3.bindBuffers(depth)
+	gl.BufferData(gl.ARRAY_BUFFER, 4*len(cb.Buf), unsafe.Pointer(&cb.Buf[0]), gl.STREAM_DRAW)
+	// Attribute arrays from earlier command buffers refer to data that
+	// is no longer there.
+	for _, attrib := range []uint32{ogl3Position, ogl3Color, ogl3TexCoord, ogl3InstanceOffset} {
+		gl.DisableVertexAttribArray(attrib)
+	}
+
+	i := 0
+	ui32 := func() uint32 {
+		v := cb.Buf[i]
+		i++
+		return v
+	}
+	i32 := func() int32 {
+		return int32(ui32())
+	}
+	float := func() float32 {
+		return math.Float32frombits(ui32())
+	}
+	matrix := func(m *[16]float32) {
+		for j := range m {
+			m[j] = float()
+		}
+	}
+	attribArray := func(attrib uint32, xtype uint32, normalized bool) {
+		offset := ui32()
+		nc := i32()
+		stride := i32()
+		gl.EnableVertexAttribArray(attrib)
+		gl.VertexAttribPointer(attrib, nc, xtype, normalized, stride, gl.PtrOffset(int(offset)))
+	}
+
+	for i < len(cb.Buf) {
+		cmd := cb.Buf[i]
+		i++
+		switch cmd {
+		case RendererLoadProjectionMatrix:
+			matrix(&ogl3.state.projection)
+
+		case RendererLoadModelViewMatrix:
+			matrix(&ogl3.state.modelview)
+
+		case RendererClearRGBA:
+			r := float()
+			g := float()
+			b := float()
+			a := float()
+			gl.ClearColor(r, g, b, a)
+			gl.Clear(gl.COLOR_BUFFER_BIT)
+
+		case RendererScissor:
+			x := i32()
+			y := i32()
+			w := i32()
+			h := i32()
+			gl.Enable(gl.SCISSOR_TEST)
+			gl.Scissor(x, y, w, h)
+
+		case RendererViewport:
+			x := i32()
+			y := i32()
+			w := i32()
+			h := i32()
+			gl.Viewport(x, y, w, h)
+			ogl3.state.viewportSize = [2]float32{float32(w), float32(h)}
+
+		case RendererBlend:
+			gl.Enable(gl.BLEND)
+			gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+			ogl3.state.blend = true
+
+		case RendererDisableBlend:
+			gl.Disable(gl.BLEND)
+			ogl3.state.blend = false
+
+		case RendererSetRGBA:
+			r := float()
+			g := float()
+			b := float()
+			a := float()
+			// The constant attribute value is used when the array is
+			// disabled, like glColor.
+			gl.DisableVertexAttribArray(ogl3Color)
+			gl.VertexAttrib4f(ogl3Color, r, g, b, a)
+
+		case RendererFloatBuffer, RendererIntBuffer, RendererRawBuffer:
+			// Already uploaded with the rest of the buffer; skip ahead
+			i += int(ui32())
+
+		case RendererEnableTexture:
+			gl.ActiveTexture(gl.TEXTURE0)
+			gl.BindTexture(gl.TEXTURE_2D, ui32())
+			ogl3.state.useTexture = true
+
+		case RendererDisableTexture:
+			ogl3.state.useTexture = false
+
+		case RendererVertexArray:
+			attribArray(ogl3Position, gl.FLOAT, false)
+
+		case RendererDisableVertexArray:
+			gl.DisableVertexAttribArray(ogl3Position)
+
+		case RendererRGB32Array:
+			attribArray(ogl3Color, gl.FLOAT, false)
+
+		case RendererRGB8Array:
+			attribArray(ogl3Color, gl.UNSIGNED_BYTE, true)
+
+		case RendererDisableColorArray:
+			gl.DisableVertexAttribArray(ogl3Color)
+
+		case RendererTexCoordArray:
+			attribArray(ogl3TexCoord, gl.FLOAT, false)
+
+		case RendererDisableTexCoordArray:
+			gl.DisableVertexAttribArray(ogl3TexCoord)
+
+		case RendererPointSize:
+			ogl3.state.pointSize = float()
+
+		case RendererDrawPoints:
+			offset := ui32()
+			count := i32()
+
+			ogl3.state.roundPoints = true
+			ogl3.useProgram(&ogl3.basic)
+			ogl3.enableAntialiasBlend()
+
+			gl.DrawElements(gl.POINTS, count, gl.UNSIGNED_INT, gl.PtrOffset(int(offset)))
+			stats.nDrawCalls++
+			stats.nPoints += int(count)
+
+			ogl3.restoreBlend()
+			ogl3.state.roundPoints = false
+
+		case RendererLineWidth:
+			ogl3.state.lineWidth = float()
+
+		case RendererDrawLines:
+			offset := ui32()
+			count := i32()
+			ogl3.useProgram(&ogl3.lines)
+			ogl3.enableAntialiasBlend()
+
+			gl.DrawElements(gl.LINES, count, gl.UNSIGNED_INT, gl.PtrOffset(int(offset)))
+			stats.nDrawCalls++
+			stats.nLines += int(count / 2)
+
+			ogl3.restoreBlend()
+
+		case RendererDrawLinesInstanced:
+			offset := ui32()
+			count := i32()
+			positions := ui32()
+			colors := ui32()
+			n := i32()
+
+			gl.EnableVertexAttribArray(ogl3InstanceOffset)
+			gl.VertexAttribPointer(ogl3InstanceOffset, 2, gl.FLOAT, false, 2*4, gl.PtrOffset(int(positions)))
+			gl.VertexAttribDivisor(ogl3InstanceOffset, 1)
+			gl.EnableVertexAttribArray(ogl3Color)
+			gl.VertexAttribPointer(ogl3Color, 3, gl.FLOAT, false, 3*4, gl.PtrOffset(int(colors)))
+			gl.VertexAttribDivisor(ogl3Color, 1)
+			ogl3.useProgram(&ogl3.lines)
+			ogl3.enableAntialiasBlend()
+
+			gl.DrawElementsInstanced(gl.LINES, count, gl.UNSIGNED_INT, gl.PtrOffset(int(offset)), n)
+			stats.nDrawCalls++
+			stats.nLines += int(n * count / 2)
+
+			ogl3.restoreBlend()
+			gl.VertexAttribDivisor(ogl3InstanceOffset, 0)
+			gl.DisableVertexAttribArray(ogl3InstanceOffset)
+			gl.VertexAttribDivisor(ogl3Color, 0)
+			gl.DisableVertexAttribArray(ogl3Color)
+
+		case RendererLineStipple:
+			ogl3.state.stippleFactor = i32()
+			ogl3.state.stipplePattern = i32()
+
+		case RendererDrawTriangles:
+			offset := ui32()
+			count := i32()
+			ogl3.useProgram(&ogl3.basic)
+			gl.DrawElements(gl.TRIANGLES, count, gl.UNSIGNED_INT, gl.PtrOffset(int(offset)))
+
+			stats.nDrawCalls++
+			stats.nTriangles += int(count / 3)
+
+		case RendererDrawQuads:
+			offset := ui32()
+			count := i32()
+			ogl3.drawQuads(cb, offset, count, depth)
+
+			stats.nDrawCalls++
+			stats.nQuads += int(count / 4)
+
+		case RendererResetState:
+			gl.Disable(gl.SCISSOR_TEST)
+			gl.Disable(gl.BLEND)
+			gl.DisableVertexAttribArray(ogl3Position)
+			gl.DisableVertexAttribArray(ogl3Color)
+			gl.DisableVertexAttribArray(ogl3TexCoord)
+			ogl3.state.useTexture = false
+			ogl3.state.blend = false
+			ogl3.state.stippleFactor = 0
+
+		case RendererCallBuffer:
+			idx := ui32()
+			s2 := ogl3.renderCommandBuffer(&cb.called[idx], depth+1)
+			stats.Merge(s2)
+			ogl3.bindBuffers(depth)
+
+		default:
+			ogl3.lg.Error("unhandled command")
+		}
+	}
+
+	return stats
+}
+
+// enableAntialiasBlend enables the blending that antialiased points and
+// lines require; restoreBlend restores the blending state specified by
+// the command buffer afterward.
+func (ogl3 *OpenGL3Renderer) enableAntialiasBlend() {
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+}
+
+func (ogl3 *OpenGL3Renderer) restoreBlend() {
+	if !ogl3.state.blend {
+		gl.Disable(gl.BLEND)
+	}
+}
+
+// drawQuads draws the quads given by the indices at the given offset in
+// the command buffer; GL_QUADS isn't available in the core profile, so
+// each one is split into two triangles.
+func (ogl3 *OpenGL3Renderer) drawQuads(cb *CommandBuffer, offset uint32, count int32, depth int) {
+	indices := cb.Buf[offset/4 : offset/4+uint32(count)]
+	ogl3.quadIndices = ogl3.quadIndices[:0]
+	for q := 0; q+3 < len(indices); q += 4 {
+		v := indices[q : q+4]
+		ogl3.quadIndices = append(ogl3.quadIndices, v[0], v[1], v[2], v[0], v[2], v[3])
+	}
+	if len(ogl3.quadIndices) == 0 {
+		return
+	}
+
+	ogl3.useProgram(&ogl3.basic)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ogl3.quadIndexBuffer)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, 4*len(ogl3.quadIndices), unsafe.Pointer(&ogl3.quadIndices[0]),
+		gl.STREAM_DRAW)
+	gl.DrawElements(gl.TRIANGLES, int32(len(ogl3.quadIndices)), gl.UNSIGNED_INT, nil)
+
+	// Restore the command buffer's index buffer.
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ogl3.buffers[glfw.GetCurrentContext()][depth].vbo)
+}
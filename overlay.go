@@ -0,0 +1,163 @@
+// overlay.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// StreamOverlay makes a summary of the current session available to
+// streaming software like OBS, either as a set of text files that can be
+// used as text sources, or as a transparent-background web page (served
+// by the API server) that can be used as a browser source.
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type OverlayState struct {
+	Callsign     string
+	Scenario     string
+	Aircraft     int
+	Tracked      int
+	Departures   int
+	Arrivals     int
+	RecentEvents []string
+}
+
+type StreamOverlay struct {
+	dir       string
+	events    *EventsSubscription
+	recent    *RingBuffer[string]
+	lastWrite time.Time
+
+	mu    sync.Mutex
+	state OverlayState
+}
+
+//go:embed resources/overlay.html
+var overlayHTML string
+
+// NewStreamOverlay returns a StreamOverlay; if dir is non-empty, text
+// files with the overlay information are written to it.
+func NewStreamOverlay(dir string, es *EventStream) *StreamOverlay {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			lg.Errorf("%s: unable to create overlay directory: %v", dir, err)
+			dir = ""
+		}
+	}
+
+	return &StreamOverlay{
+		dir:    dir,
+		events: es.Subscribe(),
+		recent: NewRingBuffer[string](5),
+	}
+}
+
+// overlayEventText returns a short description of events that are of
+// interest to viewers; it returns the empty string for others.
+func overlayEventText(e Event) string {
+	switch e.Type {
+	case AcceptedHandoffEvent:
+		return fmt.Sprintf("%s handed off to %s", e.Callsign, e.ToController)
+	case PointOutEvent:
+		return fmt.Sprintf("%s pointed out to %s", e.Callsign, e.ToController)
+	case InitiatedTrackEvent:
+		return fmt.Sprintf("%s tracked by %s", e.Callsign, e.ToController)
+	case GlobalMessageEvent:
+		return e.Message
+	default:
+		return ""
+	}
+}
+
+// Update should be called once per frame from the main thread.
+func (o *StreamOverlay) Update(w *World) {
+	if o == nil {
+		return
+	}
+
+	for _, e := range o.events.Get() {
+		if s := overlayEventText(e); s != "" {
+			o.recent.Add(time.Now().Format("15:04") + " " + s)
+		}
+	}
+
+	if time.Since(o.lastWrite) < time.Second {
+		return
+	}
+	o.lastWrite = time.Now()
+
+	var s OverlayState
+	if w != nil {
+		s.Callsign = w.Callsign
+		s.Scenario = w.SimDescription
+		s.Aircraft = len(w.Aircraft)
+		s.Departures = w.TotalDepartures
+		s.Arrivals = w.TotalArrivals
+		for _, ac := range w.Aircraft {
+			if ac.TrackingController == w.Callsign {
+				s.Tracked++
+			}
+		}
+	}
+	for i := o.recent.Size() - 1; i >= 0; i-- {
+		s.RecentEvents = append(s.RecentEvents, o.recent.Get(i))
+	}
+
+	o.mu.Lock()
+	changed := !reflect.DeepEqual(s, o.state)
+	o.state = s
+	o.mu.Unlock()
+
+	if changed && o.dir != "" {
+		o.writeFiles(s)
+	}
+}
+
+func (o *StreamOverlay) writeFiles(s OverlayState) {
+	recent := ""
+	for _, e := range s.RecentEvents {
+		recent += e + "\n"
+	}
+
+	files := map[string]string{
+		"callsign.txt": s.Callsign,
+		"scenario.txt": s.Scenario,
+		"traffic.txt": strconv.Itoa(s.Tracked) + " tracked / " + strconv.Itoa(s.Aircraft) + " aircraft\n" +
+			strconv.Itoa(s.Departures) + " departures / " + strconv.Itoa(s.Arrivals) + " arrivals",
+		"events.txt": recent,
+	}
+	for name, contents := range files {
+		// Write to a temporary file and rename so that OBS never sees a
+		// partially-written file.
+		fn := filepath.Join(o.dir, name)
+		if err := os.WriteFile(fn+".tmp", []byte(contents), 0o644); err != nil {
+			lg.Warnf("%s: %v", fn, err)
+		} else if err := os.Rename(fn+".tmp", fn); err != nil {
+			lg.Warnf("%s: %v", fn, err)
+		}
+	}
+}
+
+// RegisterHandlers adds the overlay's web page and data to the given API
+// server.
+func (o *StreamOverlay) RegisterHandlers(api *APIServer) {
+	api.HandleFunc("/overlay", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(overlayHTML))
+	})
+	api.HandleFunc("/overlay.json", func(w http.ResponseWriter, r *http.Request) {
+		o.mu.Lock()
+		s := o.state
+		o.mu.Unlock()
+		apiWriteJSON(w, http.StatusOK, s)
+	})
+}
@@ -47,6 +47,9 @@ type PaneContext struct {
 	mouse     *MouseState
 	keyboard  *KeyboardState
 	haveFocus bool
+	// Values of continuous controls from game controllers and MIDI
+	// devices that changed this frame; see controllers.go.
+	controls map[string]float32
 }
 
 type MouseState struct {
@@ -232,6 +235,9 @@ func unmarshalPane(paneType string, data []byte) (Pane, error) {
 	case "*main.EmptyPane":
 		return unmarshalPaneHelper[*EmptyPane](data)
 
+	case "*main.FDIOPane":
+		return unmarshalPaneHelper[*FDIOPane](data)
+
 	case "*main.FlightStripPane":
 		return unmarshalPaneHelper[*FlightStripPane](data)
 
@@ -801,6 +807,7 @@ type MessagesPane struct {
 	scrollbar      *ScrollBar
 	events         *EventsSubscription
 	messages       []Message
+	frequency      RadioFrequency
 
 	// Command-input-related
 	input         CLIInput
@@ -831,10 +838,12 @@ func (mp *MessagesPane) Activate(w *World, r Renderer, eventStream *EventStream)
 func (mp *MessagesPane) Deactivate() {
 	mp.events.Unsubscribe()
 	mp.events = nil
+	mp.frequency.Reset()
 }
 
 func (mp *MessagesPane) ResetWorld(w *World) {
 	mp.messages = nil
+	mp.frequency.Reset()
 }
 
 func (mp *MessagesPane) CanTakeKeyboardFocus() bool { return true }
@@ -847,6 +856,7 @@ func (mp *MessagesPane) DrawUI() {
 
 func (mp *MessagesPane) Draw(ctx *PaneContext, cb *CommandBuffer) {
 	mp.processEvents(ctx.world)
+	mp.messages = append(mp.messages, mp.frequency.Update(globalConfig.RadioCongestion, ctx.world.CurrentTime())...)
 
 	if ctx.mouse != nil && ctx.mouse.Clicked[MouseButtonPrimary] {
 		wmTakeKeyboardFocus(mp, false)
@@ -893,6 +903,13 @@ func (mp *MessagesPane) Draw(ctx *PaneContext, cb *CommandBuffer) {
 		styles := []TextStyle{cliStyle, cursorStyle, cliStyle}
 		td.AddTextMulti([]string{sb, sc, se}, [2]float32{indent, y}, styles)
 	}
+	if busy, _ := mp.frequency.Busy(ctx.world.CurrentTime()); busy && globalConfig.RadioCongestion.Enabled {
+		// Frequency busy indicator at the right side of the prompt line
+		const busyText = "FREQ BUSY"
+		bx, _ := mp.font.BoundText(busyText, 0)
+		td.AddText(busyText, [2]float32{drawWidth - float32(bx) - indent, y},
+			TextStyle{Font: mp.font, Color: RGB{1, .6, .2}})
+	}
 	y += lineHeight
 
 	for i := scrollOffset; i < min(len(mp.messages), visibleLines+scrollOffset+1); i++ {
@@ -924,9 +941,11 @@ func (mp *MessagesPane) processKeyboard(ctx *PaneContext) {
 		return
 	}
 
-	if ctx.keyboard.IsPressed(KeyTab) {
+	if ctx.keyboard.IsPressed(KeyTab) && mp.completeInput(ctx.world) {
+		// Completed a callsign or fix.
+	} else if ctx.keyboard.IsPressed(KeyTab) {
 		// focus back to the STARS Pane (assume just one...)
-		globalConfig.DisplayRoot.VisitPanes(func(pane Pane) {
+		globalConfig.VisitAllPanes(func(pane Pane) {
 			if sp, ok := pane.(*STARSPane); ok {
 				wmTakeKeyboardFocus(sp, false)
 				delete(ctx.keyboard.Pressed, KeyTab) // prevent cycling back and forth
@@ -1020,6 +1039,62 @@ func (mp *MessagesPane) runCommands(w *World) {
 		return
 	}
 
+	if mp.input.cmd[0] == '!' {
+		// Instructor edits
+		callsign, edits, _ := strings.Cut(mp.input.cmd[1:], " ")
+		mp.messages = append(mp.messages, Message{contents: "> " + mp.input.cmd})
+		mp.history = append(mp.history, mp.input)
+		mp.input = CLIInput{}
+
+		if ac := w.GetAircraft(callsign, true /*abbreviated*/); ac == nil {
+			mp.messages = append(mp.messages, Message{contents: callsign + ": no such aircraft", error: true})
+		} else if edit, err := ParseAircraftEdit(edits); err != nil {
+			mp.messages = append(mp.messages, Message{contents: err.Error(), error: true})
+		} else {
+			w.EditAircraft(ac.Callsign, edit, func(err error) {
+				mp.messages = append(mp.messages, Message{contents: ac.Callsign + ": " + err.Error(), error: true})
+			})
+		}
+		return
+	}
+
+	if mp.input.cmd[0] == '?' {
+		// Trial plans
+		callsign, items, _ := strings.Cut(mp.input.cmd[1:], " ")
+		mp.messages = append(mp.messages, Message{contents: "> " + mp.input.cmd})
+		mp.history = append(mp.history, mp.input)
+		mp.input = CLIInput{}
+
+		if ac := w.GetAircraft(callsign, true /*abbreviated*/); ac == nil {
+			mp.messages = append(mp.messages, Message{contents: callsign + ": no such aircraft", error: true})
+		} else if plan, err := ParseTrialPlan(items); err != nil {
+			mp.messages = append(mp.messages, Message{contents: err.Error(), error: true})
+		} else {
+			w.ProbeTrialPlan(ac.Callsign, plan,
+				func(conflicts []ProbeConflict) {
+					if len(conflicts) == 0 {
+						mp.messages = append(mp.messages, Message{contents: ac.Callsign + ": no conflicts predicted"})
+					}
+					for _, c := range conflicts {
+						mp.messages = append(mp.messages, Message{contents: ac.Callsign + ": conflict with " + c.String()})
+					}
+				},
+				func(err error) {
+					mp.messages = append(mp.messages, Message{contents: ac.Callsign + ": " + err.Error(), error: true})
+				})
+		}
+		return
+	}
+
+	// Aircraft take precedence over plugin commands with the same name.
+	if first, _, _ := strings.Cut(mp.input.cmd, " "); w.GetAircraft(first, true /*abbreviated*/) == nil &&
+		plugins.HandleCommand(mp.input.cmd) {
+		mp.messages = append(mp.messages, Message{contents: "> " + mp.input.cmd})
+		mp.history = append(mp.history, mp.input)
+		mp.input = CLIInput{}
+		return
+	}
+
 	callsign, cmd, ok := strings.Cut(mp.input.cmd, " ")
 	mp.messages = append(mp.messages, Message{contents: "> " + mp.input.cmd})
 	mp.history = append(mp.history, mp.input)
@@ -1027,6 +1102,7 @@ func (mp *MessagesPane) runCommands(w *World) {
 
 	if ok {
 		if ac := w.GetAircraft(callsign, true /*abbreviated*/); ac != nil {
+			mp.frequency.ControllerTransmit(len(strings.Fields(cmd)), w.CurrentTime())
 			w.RunAircraftCommands(ac.Callsign, cmd, func(errorString string, remainingCommands string) {
 				if errorString != "" {
 					mp.messages = append(mp.messages, Message{contents: errorString, error: true})
@@ -1044,6 +1120,37 @@ func (mp *MessagesPane) runCommands(w *World) {
 	}
 }
 
+// completeInput completes the callsign or fix being typed at the cursor,
+// if possible. If there are multiple possibilities, their common prefix
+// is inserted; if that doesn't add anything, they're listed.
+func (mp *MessagesPane) completeInput(w *World) bool {
+	if w == nil || mp.input.cmd == "" {
+		return false
+	}
+	start, completions := completeCommandInput(w, mp.input.cmd, mp.input.cursor)
+	if len(completions) == 0 {
+		return false
+	}
+
+	c := commonPrefix(completions)
+	if len(completions) == 1 && start == 0 {
+		c += " " // a callsign; the commands follow
+	}
+	if start+len(c) <= mp.input.cursor {
+		const maxListed = 10
+		list := strings.Join(completions[:min(len(completions), maxListed)], " ")
+		if len(completions) > maxListed {
+			list += " (+" + strconv.Itoa(len(completions)-maxListed) + " more)"
+		}
+		mp.messages = append(mp.messages, Message{contents: list, system: true})
+		return true
+	}
+
+	mp.input.cmd = mp.input.cmd[:start] + c + mp.input.cmd[mp.input.cursor:]
+	mp.input.cursor = start + len(c)
+	return true
+}
+
 func (ci *CLIInput) InsertAtCursor(s string) {
 	if len(s) == 0 {
 		return
@@ -1114,7 +1221,7 @@ func (mp *MessagesPane) processEvents(w *World) {
 			msg = Message{contents: response + ". " + radioCallsign, error: unexpectedTransmission}
 		}
 		lg.Debug("radio_transmission", slog.String("callsign", callsign), slog.Any("message", msg))
-		mp.messages = append(mp.messages, msg)
+		mp.frequency.Transmit(callsign, msg, lastRadioType == RadioTransmissionContact, w.CurrentTime())
 	}
 
 	for _, event := range mp.events.Get() {
@@ -1136,6 +1243,19 @@ func (mp *MessagesPane) processEvents(w *World) {
 		case GlobalMessageEvent:
 			if event.FromController != w.Callsign {
 				mp.messages = append(mp.messages, Message{contents: event.Message, global: true})
+				globalConfig.Audio.PlayOnce(AudioNewMessage)
+			}
+		case DepartureReleaseRequestEvent:
+			if event.ToController == w.Callsign {
+				mp.messages = append(mp.messages, Message{contents: event.Message, system: true})
+				globalConfig.Audio.PlayOnce(AudioNewMessage)
+			}
+		case SimilarCallsignEvent:
+			mp.messages = append(mp.messages, Message{contents: event.Message, system: true})
+		case UntrackedInSectorEvent:
+			if event.ToController == w.Callsign {
+				mp.messages = append(mp.messages, Message{contents: event.Message, error: true})
+				globalConfig.Audio.PlayOnce(AudioNewMessage)
 			}
 		case StatusMessageEvent:
 			// Don't spam the same message repeatedly; look in the most recent 5.
@@ -1148,6 +1268,7 @@ func (mp *MessagesPane) processEvents(w *World) {
 						contents: event.Message,
 						system:   true,
 					})
+				announce(event.Message)
 			}
 
 		case TrackClickedEvent:
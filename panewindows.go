@@ -0,0 +1,299 @@
+// panewindows.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Panes can be torn off of the main window into their own OS-level
+// windows, which can then be moved to other monitors; for example, the
+// flight strips can be put on a second monitor so that the STARS scope
+// can use all of the main one. Each PaneWindow has its own DisplayNode
+// hierarchy. Closing a PaneWindow returns its panes to the main window.
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type PaneWindow struct {
+	Title       string
+	Position    [2]int
+	Size        [2]int
+	DisplayRoot *DisplayNode
+
+	window SecondaryWindow
+
+	// Mouse state from the previous frame, used to synthesize click,
+	// release, and drag events for the panes in the window.
+	mouseDown     [MouseButtonCount]bool
+	mousePressPos [MouseButtonCount][2]float32
+	lastClick     [MouseButtonCount]time.Time
+	lastMousePos  [2]float32
+	mouseConsumer Pane
+}
+
+var ErrLastPaneInWindow = errors.New("the last pane in the main window can't be moved to another window")
+
+// VisitAllPanes visits the panes in the main window as well as the panes
+// in all of the pane windows.
+func (gc *GlobalConfig) VisitAllPanes(visit func(Pane)) {
+	if gc.DisplayRoot != nil {
+		gc.DisplayRoot.VisitPanes(visit)
+	}
+	for _, pw := range gc.PaneWindows {
+		pw.DisplayRoot.VisitPanes(visit)
+	}
+}
+
+func (pw *PaneWindow) open(p Platform) error {
+	if pw.Size[0] == 0 || pw.Size[1] == 0 {
+		pw.Size = [2]int{1024, 768}
+	}
+	var err error
+	pw.window, err = p.NewSecondaryWindow(pw.Title, pw.Size, pw.Position)
+	return err
+}
+
+func (pw *PaneWindow) close() {
+	if pw.window != nil {
+		pw.window.Destroy()
+		pw.window = nil
+	}
+}
+
+// TearOffPane moves the given pane from the main window to a new
+// PaneWindow.
+func (gc *GlobalConfig) TearOffPane(pane Pane, p Platform) error {
+	parent, idx := gc.DisplayRoot.ParentNodeForPane(pane)
+	if parent == nil {
+		return ErrLastPaneInWindow
+	}
+
+	pos := p.WindowPosition()
+	pw := &PaneWindow{
+		Title:       pane.Name(),
+		Position:    [2]int{pos[0] + 50, pos[1] + 50},
+		DisplayRoot: &DisplayNode{Pane: pane},
+	}
+	if err := pw.open(p); err != nil {
+		return err
+	}
+
+	// Replace the parent node with the pane's sibling.
+	*parent = *parent.Children[1-idx]
+	gc.PaneWindows = append(gc.PaneWindows, pw)
+
+	lg.Infof("%s: moved to separate window", pane.Name())
+	return nil
+}
+
+// ReturnPaneWindow closes the given PaneWindow and moves its panes back
+// to the right side of the main window.
+func (gc *GlobalConfig) ReturnPaneWindow(pw *PaneWindow) {
+	pw.close()
+	gc.PaneWindows = slices.DeleteFunc(gc.PaneWindows, func(p *PaneWindow) bool { return p == pw })
+
+	gc.DisplayRoot = &DisplayNode{
+		SplitLine: SplitLine{
+			Pos:  0.75,
+			Axis: SplitAxisX,
+		},
+		Children: [2]*DisplayNode{gc.DisplayRoot, pw.DisplayRoot},
+	}
+	lg.Infof("%s: returned to main window", pw.Title)
+}
+
+// ClosePaneWindows closes all of the pane windows, deactivating their
+// panes; it is used when the display configuration is replaced wholesale,
+// as when a profile is loaded.
+func (gc *GlobalConfig) ClosePaneWindows() {
+	for _, pw := range gc.PaneWindows {
+		pw.DisplayRoot.VisitPanes(func(p Pane) { p.Deactivate() })
+		pw.close()
+	}
+	gc.PaneWindows = nil
+}
+
+// mouseState returns the state of the mouse in the window, with the
+// position in window coordinates (origin at the lower left). It returns
+// nil if the window doesn't have the focus.
+func (pw *PaneWindow) mouseState(displaySize [2]float32) *MouseState {
+	if !pw.window.Focused() {
+		pw.mouseDown = [MouseButtonCount]bool{}
+		return nil
+	}
+
+	cp := pw.window.CursorPos()
+	ms := &MouseState{Pos: [2]float32{cp[0], displaySize[1] - 1 - cp[1]}}
+	wheel := pw.window.ConsumeScroll()
+	ms.Wheel = [2]float32{wheel[0], -wheel[1]}
+
+	now := time.Now()
+	for b := 0; b < MouseButtonCount; b++ {
+		down := pw.window.MouseButtonDown(b)
+		ms.Down[b] = down
+		ms.Clicked[b] = down && !pw.mouseDown[b]
+		ms.Released[b] = !down && pw.mouseDown[b]
+
+		if ms.Clicked[b] {
+			pw.mousePressPos[b] = ms.Pos
+			ms.DoubleClicked[b] = now.Sub(pw.lastClick[b]) < 300*time.Millisecond
+			pw.lastClick[b] = now
+		}
+		// Follow imgui's lead and only report dragging once the mouse
+		// has moved a few pixels.
+		if down && !ms.Clicked[b] && distance2f(ms.Pos, pw.mousePressPos[b]) > 6 {
+			ms.Dragging[b] = true
+			ms.DragDelta = sub2f(ms.Pos, pw.lastMousePos)
+		}
+		pw.mouseDown[b] = down
+	}
+	pw.lastMousePos = ms.Pos
+
+	return ms
+}
+
+func (pw *PaneWindow) draw(p Platform, r Renderer, w *World, keyboard *KeyboardState, stats *Stats) {
+	pw.Position = pw.window.WindowPosition()
+	pw.Size = pw.window.WindowSize()
+
+	displaySize := pw.window.DisplaySize()
+	fbSize := pw.window.FramebufferSize()
+	if displaySize[1] == 0 || fbSize[1] == 0 {
+		// Minimized
+		return
+	}
+	displayExtent := Extent2D{p0: [2]float32{0, 0}, p1: displaySize}
+
+	windowMouse := pw.mouseState(displaySize)
+	var mousePane Pane
+	if windowMouse != nil {
+		mousePane = pw.DisplayRoot.FindPaneForMouse(displayExtent, windowMouse.Pos)
+
+		anyDown := windowMouse.Down[0] || windowMouse.Down[1] || windowMouse.Down[2]
+		if anyDown && pw.mouseConsumer == nil {
+			pw.mouseConsumer = mousePane
+		} else if !anyDown {
+			pw.mouseConsumer = nil
+		}
+	}
+
+	commandBuffer := GetCommandBuffer()
+	defer ReturnCommandBuffer(commandBuffer)
+	commandBuffer.framebufferScale = fbSize[1] / displaySize[1]
+
+	commandBuffer.SetDrawBounds(displayExtent)
+	commandBuffer.ClearRGB(RGB{})
+
+	pw.DisplayRoot.VisitPanesWithBounds(displayExtent, displayExtent,
+		func(paneExtent Extent2D, parentExtent Extent2D, pane Pane) {
+			ctx := PaneContext{
+				paneExtent:       paneExtent,
+				parentPaneExtent: parentExtent,
+				platform:         p,
+				renderer:         r,
+				world:            w,
+				keyboard:         keyboard,
+				controls:         wm.controls,
+				haveFocus:        pane == wm.keyboardFocusPane && keyboard != nil,
+			}
+
+			if windowMouse != nil && (pane == pw.mouseConsumer || (pw.mouseConsumer == nil && pane == mousePane)) {
+				ms := *windowMouse
+				ms.Pos = sub2f(ms.Pos, paneExtent.p0)
+				ms.Clicked[MouseButtonPrimary] = ms.Clicked[MouseButtonPrimary] || wm.slew
+				ctx.mouse = &ms
+			}
+
+			commandBuffer.SetDrawBounds(paneExtent)
+			pane.Draw(&ctx, commandBuffer)
+			commandBuffer.ResetState()
+		})
+
+	pw.window.MakeContextCurrent()
+	stats.render.Merge(r.RenderCommandBuffer(commandBuffer))
+	pw.window.SwapBuffers()
+}
+
+// wmDrawPaneWindows draws the panes in all of the pane windows; it should
+// be called after wmDrawPanes. When it returns, the main window's OpenGL
+// context is current.
+func wmDrawPaneWindows(p Platform, r Renderer, w *World, stats *Stats) {
+	if len(globalConfig.PaneWindows) == 0 {
+		return
+	}
+
+	keyboard := wmNewKeyboardState(p)
+
+	for _, pw := range slices.Clone(globalConfig.PaneWindows) {
+		if pw.window == nil {
+			// Pane windows from the saved config are opened lazily.
+			if err := pw.open(p); err != nil {
+				lg.Errorf("%s: unable to open window: %v", pw.Title, err)
+				globalConfig.ReturnPaneWindow(pw)
+				continue
+			}
+		}
+		if pw.window.ShouldClose() {
+			globalConfig.ReturnPaneWindow(pw)
+			continue
+		}
+
+		pw.draw(p, r, w, keyboard, stats)
+	}
+
+	p.MakeContextCurrent()
+}
+
+// uiDrawPaneWindowsMenu draws the menu bar menu used to move panes into
+// and out of separate windows.
+func uiDrawPaneWindowsMenu(p Platform) {
+	if imgui.BeginMenu(FontAwesomeIconWindowRestore) {
+		// Don't modify the display hierarchy while it's being traversed.
+		var tearOff Pane
+		var returnWindow *PaneWindow
+
+		if globalConfig.DisplayRoot != nil && globalConfig.DisplayRoot.SplitLine.Axis != SplitAxisNone {
+			i := 0
+			globalConfig.DisplayRoot.VisitPanes(func(pane Pane) {
+				if _, ok := pane.(*SplitLine); ok {
+					return
+				}
+				imgui.PushID(fmt.Sprintf("main%d", i))
+				i++
+				if imgui.MenuItem("Move " + pane.Name() + " to new window") {
+					tearOff = pane
+				}
+				imgui.PopID()
+			})
+		}
+
+		if len(globalConfig.PaneWindows) > 0 {
+			imgui.Separator()
+			for i, pw := range globalConfig.PaneWindows {
+				imgui.PushID(fmt.Sprintf("window%d", i))
+				if imgui.MenuItem("Return " + pw.Title + " to main window") {
+					returnWindow = pw
+				}
+				imgui.PopID()
+			}
+		}
+
+		if tearOff != nil {
+			if err := globalConfig.TearOffPane(tearOff, p); err != nil {
+				ShowErrorDialog("%s: %v", tearOff.Name(), err)
+			}
+		}
+		if returnWindow != nil {
+			globalConfig.ReturnPaneWindow(returnWindow)
+		}
+		imgui.EndMenu()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Move panes to separate windows")
+	}
+}
@@ -0,0 +1,170 @@
+// parallel.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Utilities for running work concurrently. Panics in worker goroutines
+// are caught and logged along with their stack trace and are then raised
+// again in the goroutine that waits for the work to finish, so that they
+// are handled the same way as panics in the calling code would be (e.g.,
+// by putting up the crash report dialog on the main thread.)
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// WorkerPanic is the value passed to panic when work running in another
+// goroutine panicked.
+type WorkerPanic struct {
+	Value any
+	Stack []byte // of the worker goroutine
+}
+
+func (wp *WorkerPanic) Error() string {
+	return fmt.Sprintf("%v\n\nWorker goroutine stack:\n%s", wp.Value, wp.Stack)
+}
+
+// panicCapture records the first panic from a set of worker goroutines.
+type panicCapture struct {
+	mu sync.Mutex
+	wp *WorkerPanic
+}
+
+// recover must be called via defer by each worker goroutine.
+func (pc *panicCapture) recover() {
+	if err := recover(); err != nil {
+		stack := debug.Stack()
+		lg.Error("Caught panic in worker", slog.Any("panic", err), slog.String("stack", string(stack)))
+
+		pc.mu.Lock()
+		if pc.wp == nil {
+			pc.wp = &WorkerPanic{Value: err, Stack: stack}
+		}
+		pc.mu.Unlock()
+	}
+}
+
+// repanic panics if any of the workers did.
+func (pc *panicCapture) repanic() {
+	pc.mu.Lock()
+	wp := pc.wp
+	pc.wp = nil
+	pc.mu.Unlock()
+
+	if wp != nil {
+		panic(wp)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// WorkerPool
+
+// WorkerPool runs tasks using a fixed number of goroutines.
+type WorkerPool struct {
+	tasks   chan func()
+	pending sync.WaitGroup
+	panics  panicCapture
+}
+
+// NewWorkerPool returns a WorkerPool with the given number of workers; if
+// n is zero or negative, one worker per CPU is used.
+func NewWorkerPool(n int) *WorkerPool {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+
+	p := &WorkerPool{tasks: make(chan func())}
+	for i := 0; i < n; i++ {
+		go func() {
+			for f := range p.tasks {
+				p.run(f)
+			}
+		}()
+	}
+	return p
+}
+
+func (p *WorkerPool) run(f func()) {
+	defer p.pending.Done()
+	defer p.panics.recover()
+	f()
+}
+
+// Go runs the given task on one of the pool's workers; it blocks until a
+// worker is available.
+func (p *WorkerPool) Go(f func()) {
+	p.pending.Add(1)
+	p.tasks <- f
+}
+
+// Wait waits for all of the tasks that have been started to finish. If
+// any of them panicked, Wait panics with a *WorkerPanic.
+func (p *WorkerPool) Wait() {
+	p.pending.Wait()
+	p.panics.repanic()
+}
+
+// Close shuts down the pool's workers once they finish their current
+// tasks. The pool may not be used after it has been closed.
+func (p *WorkerPool) Close() {
+	close(p.tasks)
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Parallel loops
+
+// ParallelFor calls the provided function for each index in [0,n),
+// using multiple goroutines if there is enough work to do so; each worker
+// is given at least minPerWorker items. It returns once all of the calls
+// have completed. The order in which indices are processed is
+// unspecified.
+func ParallelFor(n int, minPerWorker int, f func(i int)) {
+	nworkers := min(runtime.GOMAXPROCS(0), n/max(minPerWorker, 1))
+	if nworkers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	var next atomic.Int64
+	var panics panicCapture
+	wg.Add(nworkers)
+	for w := 0; w < nworkers; w++ {
+		go func() {
+			defer wg.Done()
+			defer panics.recover()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+	panics.repanic()
+}
+
+// ParallelForEach calls the provided function for each element of the
+// slice using multiple goroutines.
+func ParallelForEach[T any](s []T, f func(i int, v T)) {
+	ParallelFor(len(s), 1, func(i int) { f(i, s[i]) })
+}
+
+// ParallelMap returns a slice with the result of calling the provided
+// function with each element of the given slice, using multiple
+// goroutines.
+func ParallelMap[T, U any](s []T, f func(T) U) []U {
+	result := make([]U, len(s))
+	ParallelFor(len(s), 1, func(i int) { result[i] = f(s[i]) })
+	return result
+}
@@ -0,0 +1,89 @@
+// parallel_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParallelMap(t *testing.T) {
+	var s []int
+	for i := 0; i < 1000; i++ {
+		s = append(s, i)
+	}
+
+	sq := ParallelMap(s, func(v int) int { return v * v })
+	for i, v := range sq {
+		if v != i*i {
+			t.Errorf("got %d at index %d, expected %d", v, i, i*i)
+		}
+	}
+
+	var sum atomic.Int64
+	ParallelForEach(s, func(i int, v int) {
+		if i != v {
+			t.Errorf("index %d passed with value %d", i, v)
+		}
+		sum.Add(int64(v))
+	})
+	if sum.Load() != 999*1000/2 {
+		t.Errorf("got sum %d, expected %d", sum.Load(), 999*1000/2)
+	}
+}
+
+func TestParallelPanic(t *testing.T) {
+	// Make sure that ParallelFor doesn't just run serially.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	expectPanic := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if err := recover(); err == nil {
+				t.Errorf("%s: expected panic", name)
+			} else if wp, ok := err.(*WorkerPanic); !ok {
+				t.Errorf("%s: expected *WorkerPanic, got %T", name, err)
+			} else if wp.Value != "oops" {
+				t.Errorf("%s: got panic value %v, expected \"oops\"", name, wp.Value)
+			}
+		}()
+		f()
+	}
+
+	expectPanic("ParallelFor", func() {
+		ParallelFor(1000, 1, func(i int) {
+			if i == 500 {
+				panic("oops")
+			}
+		})
+	})
+
+	p := NewWorkerPool(4)
+	defer p.Close()
+	var n atomic.Int64
+	expectPanic("WorkerPool", func() {
+		for i := 0; i < 100; i++ {
+			i := i
+			p.Go(func() {
+				n.Add(1)
+				if i == 50 {
+					panic("oops")
+				}
+			})
+		}
+		p.Wait()
+	})
+	if n.Load() != 100 {
+		t.Errorf("%d tasks ran, expected 100", n.Load())
+	}
+
+	// The pool should still be usable after a panic.
+	p.Go(func() { n.Add(1) })
+	p.Wait()
+	if n.Load() != 101 {
+		t.Errorf("%d tasks ran, expected 101", n.Load())
+	}
+}
@@ -0,0 +1,172 @@
+// perfhud.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+var perfHUD struct {
+	visible bool
+
+	lastFrame  time.Time
+	frameTimes *RingBuffer[time.Duration]
+
+	// runtime.ReadMemStats stops the world, so only call it periodically.
+	lastMemStats time.Time
+	mem          runtime.MemStats
+
+	profiling     bool
+	profileStatus chan string
+	lastProfile   string
+}
+
+func uiToggleShowPerfHUD() {
+	perfHUD.visible = !perfHUD.visible
+}
+
+// uiDrawPerfHUD draws a small overlay with rendering, sim, network, and
+// GC statistics.
+func uiDrawPerfHUD(stats *Stats, w *World) {
+	now := time.Now()
+	if perfHUD.frameTimes == nil {
+		perfHUD.frameTimes = NewRingBuffer[time.Duration](120)
+	}
+	if !perfHUD.lastFrame.IsZero() {
+		perfHUD.frameTimes.Add(now.Sub(perfHUD.lastFrame))
+	}
+	perfHUD.lastFrame = now
+
+	select {
+	case s := <-perfHUD.profileStatus:
+		perfHUD.lastProfile = s
+		perfHUD.profiling = false
+	default:
+	}
+
+	if !perfHUD.visible {
+		return
+	}
+
+	if time.Since(perfHUD.lastMemStats) > time.Second {
+		perfHUD.lastMemStats = time.Now()
+		runtime.ReadMemStats(&perfHUD.mem)
+	}
+
+	var total time.Duration
+	n := perfHUD.frameTimes.Size()
+	for i := 0; i < n; i++ {
+		total += perfHUD.frameTimes.Get(i)
+	}
+	frameTime := time.Duration(0)
+	if n > 0 {
+		frameTime = total / time.Duration(n)
+	}
+
+	flags := imgui.WindowFlagsNoTitleBar | imgui.WindowFlagsAlwaysAutoResize | imgui.WindowFlagsNoSavedSettings
+	imgui.SetNextWindowBgAlpha(0.75)
+	imgui.BeginV("Performance", &perfHUD.visible, flags)
+
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.2f ms", float64(d.Microseconds())/1000) }
+	if frameTime > 0 {
+		imgui.Text(fmt.Sprintf("Frame:       %s (%.1f fps)", ms(frameTime), float64(time.Second)/float64(frameTime)))
+	}
+	imgui.Text("Draw panes:  " + ms(stats.drawPanes))
+	imgui.Text("Draw imgui:  " + ms(stats.drawImgui))
+	imgui.Text(fmt.Sprintf("Redraws:     %d", stats.redraws))
+	imgui.Text("Panes: " + stats.render.String())
+	imgui.Text("UI:    " + stats.renderUI.String())
+
+	imgui.Separator()
+	if w != nil && w.Connected() {
+		imgui.Text("Sim tick:    " + ms(w.simUpdateDuration))
+		imgui.Text("RPC latency: " + ms(w.updateLatency))
+	} else {
+		imgui.Text("Not connected")
+	}
+
+	imgui.Separator()
+	m := &perfHUD.mem
+	imgui.Text(fmt.Sprintf("Heap:        %.1f MB in use, %d objects", float64(m.HeapAlloc)/(1024*1024),
+		m.HeapObjects))
+	lastPause := time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	imgui.Text(fmt.Sprintf("GC:          %d cycles, last pause %s, total %s", m.NumGC, ms(lastPause),
+		ms(time.Duration(m.PauseTotalNs))))
+	imgui.Text(fmt.Sprintf("Goroutines:  %d", runtime.NumGoroutine()))
+
+	imgui.Separator()
+	uiStartDisable(perfHUD.profiling)
+	if imgui.Button("Capture 30s profile") {
+		if err := startProfileCapture(30 * time.Second); err != nil {
+			perfHUD.lastProfile = "Error: " + err.Error()
+		} else {
+			perfHUD.profiling = true
+			perfHUD.lastProfile = "Capturing profile..."
+		}
+	}
+	uiEndDisable(perfHUD.profiling)
+	if perfHUD.lastProfile != "" {
+		imgui.Text(perfHUD.lastProfile)
+	}
+
+	imgui.End()
+}
+
+// startProfileCapture starts a CPU profile that runs for the given
+// duration; when it finishes, a heap profile is also written. The files
+// are stored in the "profiles" directory next to the config file and a
+// description of the result is sent to perfHUD.profileStatus.
+func startProfileCapture(d time.Duration) error {
+	dir := path.Join(configDirectory(), "profiles")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	cpuFn := path.Join(dir, "cpu-"+stamp+".pprof")
+	heapFn := path.Join(dir, "heap-"+stamp+".pprof")
+
+	f, err := os.Create(cpuFn)
+	if err != nil {
+		return err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		// Most likely, -cpuprofile was given on the command line.
+		f.Close()
+		os.Remove(cpuFn)
+		return err
+	}
+	lg.Infof("started CPU profile: %s", cpuFn)
+
+	perfHUD.profileStatus = make(chan string, 1)
+	go func(status chan<- string) {
+		time.Sleep(d)
+		pprof.StopCPUProfile()
+		f.Close()
+
+		hf, err := os.Create(heapFn)
+		if err != nil {
+			status <- "Error: " + err.Error()
+			return
+		}
+		defer hf.Close()
+		if err := pprof.WriteHeapProfile(hf); err != nil {
+			status <- "Error: " + err.Error()
+			return
+		}
+
+		lg.Infof("wrote profiles %s and %s", cpuFn, heapFn)
+		status <- "Wrote profiles to " + dir
+	}(perfHUD.profileStatus)
+
+	return nil
+}
@@ -0,0 +1,203 @@
+// pilotrequests.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Pilot requests: so that the controller isn't the only one initiating
+// communication, pilots on human controllers' frequencies occasionally
+// make requests at a rate given in the launch configuration:
+//
+//   - A different altitude for a better ride: higher for departures and
+//     lower for arrivals.
+//   - Direct to a fix further along their route.
+//   - A practice approach at their destination.
+//   - A deviation for weather. (The sim doesn't model weather, so these
+//     are just plausible-sounding deviations.)
+//
+// Each aircraft makes at most one request. There's no need for the
+// controller to explicitly approve or deny it; they can just issue the
+// appropriate instructions or tell the pilot "unable".
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type PilotRequestType int
+
+const (
+	AltitudePilotRequest PilotRequestType = iota
+	DirectPilotRequest
+	ApproachPilotRequest
+	DeviationPilotRequest
+	NumPilotRequestTypes
+)
+
+func (t PilotRequestType) String() string {
+	return []string{"altitude", "direct", "approach", "deviation"}[t]
+}
+
+type PilotRequest struct {
+	Type    PilotRequestType
+	Time    time.Time
+	Message string
+}
+
+func (lc *LaunchConfig) DrawPilotRequestUI() (changed bool) {
+	imgui.Separator()
+	imgui.Text("Pilot Requests")
+	r := int32(lc.PilotRequestRate)
+	changed = imgui.InputIntV("Pilot requests per hour", &r, 0, 60, 0)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Pilots occasionally request altitude changes, shortcuts, approaches, and deviations")
+	}
+	lc.PilotRequestRate = max(0, int(r))
+	return
+}
+
+// levelAt returns the aircraft's assigned altitude if it's level there.
+func levelAt(ac *Aircraft) (float32, bool) {
+	assigned := ac.Nav.Altitude.Assigned
+	if assigned == nil || abs(*assigned-ac.Nav.FlightState.Altitude) > 100 {
+		return 0, false
+	}
+	return *assigned, true
+}
+
+func altitudePilotRequest(ac *Aircraft) (string, bool) {
+	alt, ok := levelAt(ac)
+	if !ok || ac.FlightPlan == nil {
+		return "", false
+	}
+
+	if ac.IsDeparture() {
+		if req := alt + 2000; req <= float32(ac.FlightPlan.Altitude) {
+			return "it's a little bumpy here, request " + FormatAltitude(req) + " for the ride", true
+		}
+	} else if req := alt - 2000; req >= 3000 {
+		return "we're getting some chop, request " + FormatAltitude(req), true
+	}
+	return "", false
+}
+
+func directPilotRequest(ac *Aircraft) (string, bool) {
+	if _, ok := ac.Nav.AssignedHeading(); ok {
+		return "", false
+	}
+
+	// Skip at least one fix, and don't go too far down the route.
+	wps := ac.Nav.Waypoints
+	var fixes []string
+	for i := 2; i < min(len(wps), 5); i++ {
+		if fix := wps[i].Fix; fix != "" && fix[0] != '_' {
+			fixes = append(fixes, fix)
+		}
+	}
+	if len(fixes) == 0 {
+		return "", false
+	}
+	return "request direct " + FixReadback(SampleSlice(fixes)) + " if able", true
+}
+
+func approachPilotRequest(ac *Aircraft, w *World) (string, bool) {
+	if ac.IsDeparture() || ac.FlightPlan == nil || ac.Nav.Approach.Cleared {
+		return "", false
+	}
+	ap := w.GetAirport(ac.FlightPlan.ArrivalAirport)
+	if ap == nil {
+		return "", false
+	}
+
+	var names []string
+	for _, id := range SortedMapKeys(ap.Approaches) {
+		appr := ap.Approaches[id]
+		if assigned := ac.Nav.Approach.Assigned; assigned != nil && assigned.FullName == appr.FullName {
+			continue
+		}
+		if appr.Type == ChartedVisualApproach || ac.checkApproachEquipment(id, w) != nil ||
+			ac.checkApproachWeather(id, w) != nil {
+			continue
+		}
+		names = append(names, appr.FullName)
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	return "we'd like to request the " + SampleSlice(names) + " approach for practice", true
+}
+
+func deviationPilotRequest(ac *Aircraft) (string, bool) {
+	if _, ok := levelAt(ac); !ok || ac.Nav.FlightState.Altitude < 4000 {
+		return "", false
+	}
+	return fmt.Sprintf("there's some weather ahead, request %d degrees %s", 10*(1+rand.Intn(3)),
+		Sample("left", "right")), true
+}
+
+// samplePilotRequest returns a request that the aircraft could plausibly
+// make, if there is one.
+func (s *Sim) samplePilotRequest(ac *Aircraft) (PilotRequest, bool) {
+	for _, i := range rand.Perm(int(NumPilotRequestTypes)) {
+		t := PilotRequestType(i)
+		var msg string
+		var ok bool
+		switch t {
+		case AltitudePilotRequest:
+			msg, ok = altitudePilotRequest(ac)
+		case DirectPilotRequest:
+			msg, ok = directPilotRequest(ac)
+		case ApproachPilotRequest:
+			msg, ok = approachPilotRequest(ac, s.World)
+		case DeviationPilotRequest:
+			msg, ok = deviationPilotRequest(ac)
+		}
+		if ok {
+			return PilotRequest{Type: t, Time: s.SimTime, Message: msg}, true
+		}
+	}
+	return PilotRequest{}, false
+}
+
+// updatePilotRequests is called once a second with s.mu held; when it's
+// time, it has a random aircraft make a request.
+func (s *Sim) updatePilotRequests(now time.Time) {
+	if s.LaunchConfig.PilotRequestRate == 0 {
+		return
+	}
+	if s.NextPilotRequest.IsZero() {
+		s.NextPilotRequest = now.Add(randomWait(s.LaunchConfig.PilotRequestRate, false))
+	}
+	if now.Before(s.NextPilotRequest) {
+		return
+	}
+	s.NextPilotRequest = now.Add(randomWait(s.LaunchConfig.PilotRequestRate, false))
+
+	var aircraft []*Aircraft
+	for _, ac := range s.World.Aircraft {
+		if ac.PilotRequest == nil && ac.IsAirborne() && !ac.LostComms && !ac.Frozen &&
+			ac.FlightPlan != nil && ac.FlightPlan.Rules == IFR && s.controllerIsSignedIn(ac.ControllingController) {
+			aircraft = append(aircraft, ac)
+		}
+	}
+	sort.Slice(aircraft, func(i, j int) bool { return aircraft[i].Callsign < aircraft[j].Callsign })
+	rand.Shuffle(len(aircraft), func(i, j int) { aircraft[i], aircraft[j] = aircraft[j], aircraft[i] })
+
+	for _, ac := range aircraft {
+		if req, ok := s.samplePilotRequest(ac); ok {
+			s.lg.Info("pilot request", slog.String("callsign", ac.Callsign),
+				slog.String("type", req.Type.String()), slog.String("message", req.Message))
+			ac.PilotRequest = &req
+			PostRadioEvents(ac.Callsign, []RadioTransmission{RadioTransmission{
+				Controller: ac.ControllingController,
+				Message:    req.Message,
+				Type:       RadioTransmissionContact,
+			}}, s)
+			return
+		}
+	}
+}
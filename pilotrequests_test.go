@@ -0,0 +1,63 @@
+// pilotrequests_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAltitudePilotRequest(t *testing.T) {
+	alt := func(a float32) *float32 { return &a }
+
+	ac := &Aircraft{FlightPlan: &FlightPlan{Altitude: 12000}}
+	ac.Nav.FlightState.IsDeparture = true
+	ac.Nav.FlightState.Altitude = 9000
+	ac.Nav.Altitude.Assigned = alt(10000)
+	if _, ok := altitudePilotRequest(ac); ok {
+		t.Errorf("unexpected request while climbing")
+	}
+
+	ac.Nav.FlightState.Altitude = 10000
+	if msg, ok := altitudePilotRequest(ac); !ok || !strings.Contains(msg, FormatAltitude(12000)) {
+		t.Errorf("expected a request for 12,000, got %q", msg)
+	}
+
+	// Don't ask for more than the filed altitude.
+	ac.Nav.FlightState.Altitude = 11000
+	ac.Nav.Altitude.Assigned = alt(11000)
+	if msg, ok := altitudePilotRequest(ac); ok {
+		t.Errorf("unexpected request %q above the filed altitude", msg)
+	}
+
+	ac.Nav.FlightState.IsDeparture = false
+	if msg, ok := altitudePilotRequest(ac); !ok || !strings.Contains(msg, FormatAltitude(9000)) {
+		t.Errorf("expected an arrival to request 9,000, got %q", msg)
+	}
+	ac.Nav.FlightState.Altitude = 4000
+	ac.Nav.Altitude.Assigned = alt(4000)
+	if msg, ok := altitudePilotRequest(ac); ok {
+		t.Errorf("unexpected request %q for low altitude", msg)
+	}
+}
+
+func TestDirectPilotRequest(t *testing.T) {
+	saved := database
+	database = &StaticDatabase{}
+	defer func() { database = saved }()
+
+	ac := &Aircraft{}
+	ac.Nav.Waypoints = []Waypoint{{Fix: "CAMRN"}, {Fix: "_CAMRN_1"}, {Fix: "_JFK_31L"}, {Fix: "ZACHS"}}
+	for i := 0; i < 10; i++ {
+		if msg, ok := directPilotRequest(ac); !ok || msg != "request direct ZACHS if able" {
+			t.Errorf("expected a request for ZACHS, got %q", msg)
+		}
+	}
+
+	ac.Nav.Waypoints = ac.Nav.Waypoints[:3]
+	if msg, ok := directPilotRequest(ac); ok {
+		t.Errorf("unexpected request %q", msg)
+	}
+}
@@ -39,6 +39,9 @@ type Platform interface {
 	// InputCharacters returns a string of all the characters (generally at most one!) that have
 	// been entered since the last call to ProcessEvents.
 	InputCharacters() string
+	// KeyEvents returns the raw key presses since the last call to
+	// ProcessEvents.
+	KeyEvents() []KeyEvent
 	// EnableVSync specifies whether v-sync should be used when rendering;
 	// v-sync is on by default and should only be disabled for benchmarking.
 	EnableVSync(sync bool)
@@ -60,6 +63,47 @@ type Platform interface {
 	EndCaptureMouse()
 	// Scaling factor to account for Retina-style displays
 	DPIScale() float32
+	// NewSecondaryWindow opens an additional window that shares the main
+	// window's OpenGL objects (textures, buffers, etc.).
+	NewSecondaryWindow(title string, size [2]int, pos [2]int) (SecondaryWindow, error)
+	// OpenGLCoreProfile returns true if the window has an OpenGL 3.3
+	// core profile context rather than an OpenGL 2.1 one.
+	OpenGLCoreProfile() bool
+	// MakeContextCurrent makes the main window's OpenGL context current.
+	MakeContextCurrent()
+}
+
+// SecondaryWindow is an additional OS-level window, used for displaying
+// panes that have been torn off of the main window. Keyboard input in a
+// secondary window is delivered through imgui, just like keyboard input
+// in the main window; mouse input is available via CursorPos,
+// MouseButtonDown, and ConsumeScroll.
+type SecondaryWindow interface {
+	MakeContextCurrent()
+	SwapBuffers()
+	ShouldClose() bool
+	Destroy()
+	Focused() bool
+	DisplaySize() [2]float32
+	FramebufferSize() [2]float32
+	WindowSize() [2]int
+	WindowPosition() [2]int
+	// CursorPos returns the mouse position with respect to the window's
+	// upper left corner.
+	CursorPos() [2]float32
+	MouseButtonDown(button int) bool
+	// ConsumeScroll returns the scroll wheel motion since the last call.
+	ConsumeScroll() [2]float32
+}
+
+// KeyEvent records a single key press, identified both by the GLFW key
+// code and by the keyboard's scancode, which is needed for keys that GLFW
+// doesn't know about (as on some specialized keyboards.) Chars holds any
+// characters that the key press generated.
+type KeyEvent struct {
+	Key      int
+	Scancode int
+	Chars    string
 }
 
 ///////////////////////////////////////////////////////////////////////////
@@ -75,16 +119,21 @@ type GLFWPlatform struct {
 	mouseCursors           [imgui.MouseCursorCount]*glfw.Cursor
 	currentCursor          *glfw.Cursor
 	inputCharacters        string
+	keyEvents              []KeyEvent
 	anyEvents              bool
 	lastMouseX, lastMouseY float64
 	multisample            bool
+	coreProfile            bool
 	windowTitle            string
 	mouseCapture           Extent2D
 }
 
 // NewGLFWPlatform returns a new instance of a GLFWPlatform with a window
 // of the specified size open at the specified position on the screen.
-func NewGLFWPlatform(io imgui.IO, windowSize [2]int, windowPosition [2]int, multisample bool) (Platform, error) {
+// If coreProfile is true, an OpenGL 3.3 core profile context is requested;
+// if that isn't available, an OpenGL 2.1 context is used instead.
+func NewGLFWPlatform(io imgui.IO, windowSize [2]int, windowPosition [2]int, multisample bool,
+	coreProfile bool) (Platform, error) {
 	lg.Info("Starting GLFW initialization")
 	err := glfw.Init()
 	if err != nil {
@@ -94,9 +143,6 @@ func NewGLFWPlatform(io imgui.IO, windowSize [2]int, windowPosition [2]int, mult
 
 	io.SetBackendFlags(io.GetBackendFlags() | imgui.BackendFlagsHasMouseCursors)
 
-	glfw.WindowHint(glfw.ContextVersionMajor, 2)
-	glfw.WindowHint(glfw.ContextVersionMinor, 1)
-
 	if windowSize[0] == 0 || windowSize[1] == 0 {
 		vm := glfw.GetPrimaryMonitor().GetVideoMode()
 		if runtime.GOOS == "windows" {
@@ -114,10 +160,30 @@ func NewGLFWPlatform(io imgui.IO, windowSize [2]int, windowPosition [2]int, mult
 	if multisample {
 		glfw.WindowHint(glfw.Samples, 4)
 	}
-	window, err := glfw.CreateWindow(windowSize[0], windowSize[1], "vice", nil, nil)
-	if err != nil {
-		glfw.Terminate()
-		return nil, fmt.Errorf("failed to create window: %w", err)
+	// Note that the context hints also apply to any secondary windows
+	// that are created later.
+	var window *glfw.Window
+	if coreProfile {
+		glfw.WindowHint(glfw.ContextVersionMajor, 3)
+		glfw.WindowHint(glfw.ContextVersionMinor, 3)
+		glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+		glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+		window, err = glfw.CreateWindow(windowSize[0], windowSize[1], "vice", nil, nil)
+		if err != nil {
+			lg.Warnf("Unable to create OpenGL 3.3 context; falling back to OpenGL 2.1: %v", err)
+			coreProfile = false
+		}
+	}
+	if window == nil {
+		glfw.WindowHint(glfw.ContextVersionMajor, 2)
+		glfw.WindowHint(glfw.ContextVersionMinor, 1)
+		glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLAnyProfile)
+		glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.False)
+		window, err = glfw.CreateWindow(windowSize[0], windowSize[1], "vice", nil, nil)
+		if err != nil {
+			glfw.Terminate()
+			return nil, fmt.Errorf("failed to create window: %w", err)
+		}
 	}
 	window.SetPos(windowPosition[0], windowPosition[1])
 	window.Show()
@@ -127,6 +193,7 @@ func NewGLFWPlatform(io imgui.IO, windowSize [2]int, windowPosition [2]int, mult
 		imguiIO:     io,
 		window:      window,
 		multisample: multisample,
+		coreProfile: coreProfile,
 	}
 	platform.setKeyMapping()
 	platform.installCallbacks()
@@ -139,8 +206,9 @@ func NewGLFWPlatform(io imgui.IO, windowSize [2]int, windowPosition [2]int, mult
 
 func (g *GLFWPlatform) DPIScale() float32 {
 	if runtime.GOOS == "windows" {
+		// This may be fractional, e.g. 1.5 for 150% scaling.
 		sx, sy := g.window.GetContentScale()
-		return float32(int((sx + sy) / 2))
+		return (sx + sy) / 2
 	} else {
 		return g.FramebufferSize()[0] / g.DisplaySize()[0]
 	}
@@ -163,6 +231,10 @@ func (g *GLFWPlatform) InputCharacters() string {
 	return g.inputCharacters
 }
 
+func (g *GLFWPlatform) KeyEvents() []KeyEvent {
+	return g.keyEvents
+}
+
 func (g *GLFWPlatform) ShouldStop() bool {
 	return g.window.ShouldClose()
 }
@@ -173,6 +245,7 @@ func (g *GLFWPlatform) CancelShouldStop() {
 
 func (g *GLFWPlatform) ProcessEvents() bool {
 	g.inputCharacters = ""
+	g.keyEvents = g.keyEvents[:0]
 	g.anyEvents = false
 
 	glfw.PollEvents()
@@ -217,7 +290,9 @@ func (g *GLFWPlatform) FramebufferSize() [2]float32 {
 }
 
 func (g *GLFWPlatform) NewFrame() {
-	if g.multisample {
+	// The OpenGL 3 renderer enables multisampling itself; the OpenGL 2.1
+	// bindings aren't initialized in that case.
+	if g.multisample && !g.coreProfile {
 		gl.Enable(gl.MULTISAMPLE)
 	}
 
@@ -352,6 +427,7 @@ func (g *GLFWPlatform) keyChange(window *glfw.Window, key glfw.Key, scancode int
 	g.anyEvents = true
 	if action == glfw.Press {
 		g.imguiIO.KeyPress(int(key))
+		g.keyEvents = append(g.keyEvents, KeyEvent{Key: int(key), Scancode: scancode})
 	}
 	if action == glfw.Release {
 		g.imguiIO.KeyRelease(int(key))
@@ -368,6 +444,11 @@ func (g *GLFWPlatform) charChange(window *glfw.Window, char rune) {
 	g.anyEvents = true
 	g.imguiIO.AddInputCharacters(string(char))
 	g.inputCharacters = g.inputCharacters + string(char)
+	// The character callback follows the key callback for the key that
+	// generated it.
+	if n := len(g.keyEvents); n > 0 {
+		g.keyEvents[n-1].Chars += string(char)
+	}
 }
 
 func (g *GLFWPlatform) createMouseCursors() {
@@ -398,7 +479,10 @@ type GLFWClipboard struct {
 }
 
 func (cb GLFWClipboard) Text() (string, error) {
-	return cb.window.GetClipboardString(), nil
+	s := cb.window.GetClipboardString()
+	// Make sure that pasted text can be displayed.
+	fontsAddText(s)
+	return s, nil
 }
 
 func (cb GLFWClipboard) SetText(text string) {
@@ -414,3 +498,113 @@ func (g *GLFWPlatform) StartCaptureMouse(e Extent2D) {
 func (g *GLFWPlatform) EndCaptureMouse() {
 	g.mouseCapture = Extent2D{}
 }
+
+func (g *GLFWPlatform) OpenGLCoreProfile() bool {
+	return g.coreProfile
+}
+
+func (g *GLFWPlatform) MakeContextCurrent() {
+	g.window.MakeContextCurrent()
+}
+
+///////////////////////////////////////////////////////////////////////////
+// GLFWSecondaryWindow
+
+type GLFWSecondaryWindow struct {
+	window *glfw.Window
+	scroll [2]float32
+}
+
+// glfwReleaseContextCallbacks are called with a secondary window's OpenGL
+// context current just before the window is destroyed so that renderers
+// can free objects that aren't shared between contexts.
+var glfwReleaseContextCallbacks []func(*glfw.Window)
+
+func (g *GLFWPlatform) NewSecondaryWindow(title string, size [2]int, pos [2]int) (SecondaryWindow, error) {
+	glfw.WindowHint(glfw.Visible, 0)
+	// Passing the main window for the share parameter means that textures
+	// and vertex buffers created by the renderer can be used in the new
+	// window as well.
+	window, err := glfw.CreateWindow(size[0], size[1], title, nil, g.window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create window: %w", err)
+	}
+	window.SetPos(pos[0], pos[1])
+	window.Show()
+
+	sw := &GLFWSecondaryWindow{window: window}
+	window.SetScrollCallback(func(w *glfw.Window, x, y float64) {
+		g.anyEvents = true
+		sw.scroll[0] += float32(x)
+		sw.scroll[1] += float32(y)
+	})
+	window.SetKeyCallback(g.keyChange)
+	window.SetCharCallback(g.charChange)
+	window.SetMouseButtonCallback(func(*glfw.Window, glfw.MouseButton, glfw.Action, glfw.ModifierKey) {
+		g.anyEvents = true
+	})
+
+	// Only the main window waits for vsync; otherwise the frame rate would
+	// be divided by the number of open windows.
+	window.MakeContextCurrent()
+	glfw.SwapInterval(0)
+	g.window.MakeContextCurrent()
+
+	return sw, nil
+}
+
+func (sw *GLFWSecondaryWindow) MakeContextCurrent() { sw.window.MakeContextCurrent() }
+func (sw *GLFWSecondaryWindow) SwapBuffers()        { sw.window.SwapBuffers() }
+func (sw *GLFWSecondaryWindow) ShouldClose() bool   { return sw.window.ShouldClose() }
+func (sw *GLFWSecondaryWindow) Focused() bool       { return sw.window.GetAttrib(glfw.Focused) != 0 }
+
+func (sw *GLFWSecondaryWindow) Destroy() {
+	if len(glfwReleaseContextCallbacks) > 0 {
+		prev := glfw.GetCurrentContext()
+		sw.window.MakeContextCurrent()
+		for _, cb := range glfwReleaseContextCallbacks {
+			cb(sw.window)
+		}
+		if prev != nil && prev != sw.window {
+			prev.MakeContextCurrent()
+		} else {
+			glfw.DetachCurrentContext()
+		}
+	}
+	sw.window.Destroy()
+}
+
+func (sw *GLFWSecondaryWindow) DisplaySize() [2]float32 {
+	w, h := sw.window.GetSize()
+	return [2]float32{float32(w), float32(h)}
+}
+
+func (sw *GLFWSecondaryWindow) FramebufferSize() [2]float32 {
+	w, h := sw.window.GetFramebufferSize()
+	return [2]float32{float32(w), float32(h)}
+}
+
+func (sw *GLFWSecondaryWindow) WindowSize() [2]int {
+	w, h := sw.window.GetSize()
+	return [2]int{w, h}
+}
+
+func (sw *GLFWSecondaryWindow) WindowPosition() [2]int {
+	x, y := sw.window.GetPos()
+	return [2]int{x, y}
+}
+
+func (sw *GLFWSecondaryWindow) CursorPos() [2]float32 {
+	x, y := sw.window.GetCursorPos()
+	return [2]float32{float32(x), float32(y)}
+}
+
+func (sw *GLFWSecondaryWindow) MouseButtonDown(button int) bool {
+	return sw.window.GetMouseButton(glfwButtonIDByIndex[button]) == glfw.Press
+}
+
+func (sw *GLFWSecondaryWindow) ConsumeScroll() [2]float32 {
+	s := sw.scroll
+	sw.scroll = [2]float32{}
+	return s
+}
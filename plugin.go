@@ -0,0 +1,393 @@
+// plugin.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// vice plugins are separate executables that are found in the "plugins"
+// directory next to the vice configuration file. Each one is launched when
+// vice starts and communicates with vice via newline-delimited JSON
+// messages over its stdin and stdout. (Go's plugin package isn't a good
+// fit since plugins would need to share vice's types, which live in
+// package main, and it isn't supported on Windows.) This approach allows
+// plugins to be written in any language.
+//
+// vice sends PluginHostMessages to the plugin:
+//   - "hello": sent at startup with the vice version.
+//   - "event": every event posted to the EventStream.
+//   - "state": a read-only snapshot of the aircraft in the sim, sent once
+//     a second.
+//   - "command": a user-entered command that the plugin registered.
+//
+// The plugin sends PluginMessages back:
+//   - "register_command": claims a command name; when the user enters
+//     that command in the messages pane, it is forwarded to the plugin.
+//     (Aircraft callsigns take precedence over plugin commands.)
+//   - "status": displays a status message.
+//   - "aircraft_command": runs control commands for an aircraft, as if
+//     entered by the user.
+//   - "register_pane": adds a window with the given name that the user can
+//     show from the plugins menu.
+//   - "pane_text": replaces the lines of text shown in the named pane.
+//
+// Messages to a plugin are queued and written by a separate goroutine so
+// that a plugin that stops reading its input can't stall vice; if the
+// queue fills up, the plugin is disconnected.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type PluginHostMessage struct {
+	Type     string
+	Version  string            `json:",omitempty"`
+	Event    *Event            `json:",omitempty"`
+	Aircraft []AircraftSummary `json:",omitempty"`
+	SimTime  time.Time         `json:",omitempty"`
+	Command  string            `json:",omitempty"`
+	Args     string            `json:",omitempty"`
+}
+
+type PluginMessage struct {
+	Type     string
+	Name     string   // register_command, register_pane, pane_text
+	Message  string   // status
+	Callsign string   // aircraft_command
+	Commands string   // aircraft_command
+	Lines    []string // pane_text
+}
+
+type pluginProcess struct {
+	name     string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	outgoing chan PluginHostMessage
+	messages chan PluginMessage
+	dead     bool
+}
+
+type pluginPane struct {
+	plugin  *pluginProcess
+	name    string
+	lines   []string
+	visible bool
+}
+
+// PluginManager launches plugins and shuttles messages between them and
+// the rest of vice.
+type PluginManager struct {
+	plugins   []*pluginProcess
+	commands  map[string]*pluginProcess
+	panes     []*pluginPane
+	events    *EventsSubscription
+	lastState time.Time
+}
+
+func pluginDirectory() string {
+	return path.Join(configDirectory(), "plugins")
+}
+
+// NewPluginManager launches all of the plugins in the given directory. It
+// always returns a valid *PluginManager, even if there are no plugins or
+// if some of them fail to start.
+func NewPluginManager(dir string, es *EventStream) *PluginManager {
+	pm := &PluginManager{commands: make(map[string]*pluginProcess)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			lg.Warnf("%s: unable to read plugin directory: %v", dir, err)
+		}
+		return pm
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if runtime.GOOS == "windows" && filepath.Ext(entry.Name()) != ".exe" {
+			continue
+		}
+
+		if p, err := launchPlugin(filepath.Join(dir, entry.Name())); err != nil {
+			lg.Errorf("%s: unable to launch plugin: %v", entry.Name(), err)
+		} else {
+			lg.Info("launched plugin", slog.String("name", p.name))
+			pm.plugins = append(pm.plugins, p)
+		}
+	}
+
+	if len(pm.plugins) > 0 {
+		pm.events = es.Subscribe()
+	}
+
+	return pm
+}
+
+func launchPlugin(fn string) (*pluginProcess, error) {
+	p := &pluginProcess{
+		name:     strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn)),
+		cmd:      exec.Command(fn),
+		outgoing: make(chan PluginHostMessage, 256),
+		messages: make(chan PluginMessage, 64),
+	}
+
+	var err error
+	if p.stdin, err = p.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := p.cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		enc := json.NewEncoder(p.stdin)
+		for m := range p.outgoing {
+			if err := enc.Encode(m); err != nil {
+				lg.Warnf("%s: plugin has exited: %v", p.name, err)
+				break
+			}
+		}
+		p.stdin.Close()
+	}()
+	go func() {
+		dec := json.NewDecoder(stdout)
+		for {
+			var m PluginMessage
+			if err := dec.Decode(&m); err != nil {
+				// The pipe is closed once the plugin has been reaped
+				// after being disconnected.
+				if err != io.EOF && !errors.Is(err, os.ErrClosed) {
+					lg.Warnf("%s: plugin output: %v", p.name, err)
+				}
+				close(p.messages)
+				return
+			}
+			p.messages <- m
+		}
+	}()
+	go func() {
+		sc := bufio.NewScanner(stderr)
+		for sc.Scan() {
+			lg.Info("plugin stderr", slog.String("plugin", p.name), slog.String("text", sc.Text()))
+		}
+	}()
+
+	p.send(PluginHostMessage{Type: "hello", Version: strings.TrimSpace(buildVersion)})
+
+	return p, nil
+}
+
+func (p *pluginProcess) send(m PluginHostMessage) {
+	if p.dead {
+		return
+	}
+	select {
+	case p.outgoing <- m:
+	default:
+		lg.Warnf("%s: plugin isn't reading its input; disconnecting it", p.name)
+		p.disconnect()
+	}
+}
+
+// disconnect stops sending messages to the plugin, which closes its
+// stdin once the queued ones have been written, and stops the plugin.
+func (p *pluginProcess) disconnect() {
+	if p.dead {
+		return
+	}
+	p.dead = true
+	close(p.outgoing)
+	if err := p.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		lg.Warnf("%s: unable to stop plugin: %v", p.name, err)
+	}
+	// Reap the process so that it doesn't linger as a zombie.
+	go func() {
+		if err := p.cmd.Wait(); err != nil {
+			lg.Infof("%s: plugin exited: %v", p.name, err)
+		}
+	}()
+}
+
+// Update should be called regularly (e.g., once per frame); it forwards
+// events and sim state to the plugins and handles any messages they have
+// sent.
+func (pm *PluginManager) Update(w *World, es *EventStream) {
+	if len(pm.plugins) == 0 {
+		return
+	}
+
+	for _, e := range pm.events.Get() {
+		e := e
+		for _, p := range pm.plugins {
+			p.send(PluginHostMessage{Type: "event", Event: &e})
+		}
+	}
+
+	if w != nil && time.Since(pm.lastState) > time.Second {
+		pm.lastState = time.Now()
+
+		var summaries []AircraftSummary
+		for _, callsign := range SortedMapKeys(w.Aircraft) {
+			summaries = append(summaries, w.Aircraft[callsign].Summary())
+		}
+		for _, p := range pm.plugins {
+			p.send(PluginHostMessage{Type: "state", Aircraft: summaries, SimTime: w.CurrentTime()})
+		}
+	}
+
+	for _, p := range pm.plugins {
+	loop:
+		for {
+			select {
+			case m, ok := <-p.messages:
+				if !ok {
+					p.disconnect()
+					break loop
+				}
+				pm.handleMessage(p, m, w, es)
+			default:
+				break loop
+			}
+		}
+	}
+}
+
+func (pm *PluginManager) handleMessage(p *pluginProcess, m PluginMessage, w *World, es *EventStream) {
+	switch m.Type {
+	case "register_command":
+		name := strings.ToUpper(m.Name)
+		if other, ok := pm.commands[name]; ok && other != p {
+			lg.Warnf("%s: plugin command already registered by %s", name, other.name)
+		} else {
+			pm.commands[name] = p
+		}
+
+	case "register_pane":
+		if idx := slices.IndexFunc(pm.panes, func(pane *pluginPane) bool { return pane.name == m.Name }); idx != -1 {
+			if other := pm.panes[idx].plugin; other != p {
+				lg.Warnf("%s: plugin pane already registered by %s", m.Name, other.name)
+			}
+		} else {
+			pm.panes = append(pm.panes, &pluginPane{plugin: p, name: m.Name})
+		}
+
+	case "pane_text":
+		if idx := slices.IndexFunc(pm.panes, func(pane *pluginPane) bool {
+			return pane.name == m.Name && pane.plugin == p
+		}); idx == -1 {
+			lg.Warnf("%s: %s: plugin pane not registered", p.name, m.Name)
+		} else {
+			pm.panes[idx].lines = m.Lines
+		}
+
+	case "status":
+		es.Post(Event{Type: StatusMessageEvent, Message: p.name + ": " + m.Message})
+
+	case "aircraft_command":
+		if w == nil {
+			return
+		}
+		w.RunAircraftCommands(m.Callsign, m.Commands, func(errorString string, remaining string) {
+			if errorString != "" {
+				es.Post(Event{Type: StatusMessageEvent, Message: p.name + ": " + m.Callsign + ": " + errorString})
+			}
+		})
+
+	default:
+		lg.Warnf("%s: unknown plugin message type %q", p.name, m.Type)
+	}
+}
+
+// HandleCommand forwards the given command to the plugin that registered
+// it, if any, and returns true if a plugin handled it.
+func (pm *PluginManager) HandleCommand(cmd string) bool {
+	if pm == nil {
+		return false
+	}
+
+	// As with aircraft commands, plugin command names are
+	// case-insensitive; the arguments are passed along as entered.
+	name, args, _ := strings.Cut(cmd, " ")
+	name = strings.ToUpper(name)
+	if p, ok := pm.commands[name]; ok && !p.dead {
+		p.send(PluginHostMessage{Type: "command", Command: name, Args: args})
+		return true
+	}
+	return false
+}
+
+// Shutdown closes the plugins' stdin, which signals them to exit.
+func (pm *PluginManager) Shutdown() {
+	for _, p := range pm.plugins {
+		if !p.dead {
+			p.dead = true
+			close(p.outgoing)
+		}
+	}
+	if pm.events != nil {
+		pm.events.Unsubscribe()
+	}
+}
+
+// DrawMenu draws the menu that shows and hides the plugins' panes; it is
+// only shown if a plugin has registered one.
+func (pm *PluginManager) DrawMenu() {
+	if pm == nil || len(pm.panes) == 0 {
+		return
+	}
+
+	if imgui.BeginMenu(FontAwesomeIconPuzzlePiece) {
+		for _, pane := range pm.panes {
+			if imgui.MenuItemV(pane.name, "", pane.visible, true) {
+				pane.visible = !pane.visible
+			}
+		}
+		imgui.EndMenu()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Plugin windows")
+	}
+}
+
+func (pm *PluginManager) DrawPanes() {
+	if pm == nil {
+		return
+	}
+
+	for i, pane := range pm.panes {
+		if !pane.visible {
+			continue
+		}
+		imgui.BeginV(pane.name+"##plugin"+strconv.Itoa(i), &pane.visible, imgui.WindowFlagsAlwaysAutoResize)
+		for _, line := range pane.lines {
+			fontsAddText(line)
+			imgui.Text(line)
+		}
+		imgui.End()
+	}
+}
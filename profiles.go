@@ -0,0 +1,363 @@
+// profiles.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Configuration profiles hold the display layout (including all of the
+// STARS settings like maps and brightness) and the UI font size under a
+// name so that users who control at multiple facilities can quickly
+// switch between setups. A profile may also be associated with a
+// facility, in which case it is activated automatically when a sim at
+// that facility starts. Profiles can be exported to a file and imported
+// by other users.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type ConfigProfile struct {
+	DisplayRoot *DisplayNode
+	// Panes that have been torn off into their own windows.
+	PaneWindows []*PaneWindow `json:",omitempty"`
+	UIFontSize  int
+	// May be nil for profiles saved before key bindings were added.
+	KeyBindings *KeyBindings
+	// If non-empty, the profile is activated automatically for sims at
+	// this TRACON.
+	Facility string
+}
+
+// ExportedConfigProfile is the file format used for sharing profiles.
+type ExportedConfigProfile struct {
+	Name    string
+	Version int
+	Profile *ConfigProfile
+}
+
+var ErrNoSuchProfile = errors.New("no such profile")
+
+// copyDisplayNode returns a deep copy of the given display hierarchy; the
+// panes in the copy have not been activated.
+func copyDisplayNode(d *DisplayNode) (*DisplayNode, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var c DisplayNode
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SaveProfile stores the current display configuration under the given
+// name, replacing any existing profile with that name.
+func (gc *GlobalConfig) SaveProfile(name string) error {
+	if gc.DisplayRoot == nil {
+		return errors.New("no display configuration")
+	}
+	root, err := copyDisplayNode(gc.DisplayRoot)
+	if err != nil {
+		return err
+	}
+	windows, err := copyPaneWindows(gc.PaneWindows)
+	if err != nil {
+		return err
+	}
+
+	if gc.Profiles == nil {
+		gc.Profiles = make(map[string]*ConfigProfile)
+	}
+	facility := ""
+	if p, ok := gc.Profiles[name]; ok {
+		facility = p.Facility
+	}
+	kb := gc.KeyBindings.Duplicate()
+	gc.Profiles[name] = &ConfigProfile{
+		DisplayRoot: root,
+		PaneWindows: windows,
+		UIFontSize:  gc.UIFontSize,
+		KeyBindings: &kb,
+		Facility:    facility,
+	}
+	gc.ActiveProfile = name
+	lg.Infof("%s: saved config profile", name)
+	return nil
+}
+
+// LoadProfile makes the named profile the current configuration. The
+// current configuration is first saved to the active profile, if there is
+// one, so that changes made to it aren't lost.
+func (gc *GlobalConfig) LoadProfile(name string, w *World, r Renderer, eventStream *EventStream) error {
+	p, ok := gc.Profiles[name]
+	if !ok {
+		return ErrNoSuchProfile
+	}
+	root, err := copyDisplayNode(p.DisplayRoot)
+	if err != nil {
+		return err
+	}
+	windows, err := copyPaneWindows(p.PaneWindows)
+	if err != nil {
+		return err
+	}
+
+	if gc.ActiveProfile != "" && gc.ActiveProfile != name {
+		if err := gc.SaveProfile(gc.ActiveProfile); err != nil {
+			lg.Warnf("%s: unable to save profile: %v", gc.ActiveProfile, err)
+		}
+	}
+
+	gc.setDisplay(root, windows, w, r, eventStream)
+	// The profile's panes don't correspond to any saved layout.
+	gc.ActiveLayout = ""
+
+	if p.UIFontSize != 0 && p.UIFontSize != gc.UIFontSize {
+		gc.UIFontSize = p.UIFontSize
+		ui.font = GetFont(FontIdentifier{Name: "Roboto Regular", Size: gc.uiFontSize()})
+	}
+	if p.KeyBindings != nil {
+		gc.KeyBindings = p.KeyBindings.Duplicate()
+	}
+
+	gc.ActiveProfile = name
+	lg.Infof("%s: loaded config profile", name)
+	return nil
+}
+
+// VisitPanes visits the panes in the profile's main window as well as
+// those in its pane windows.
+func (p *ConfigProfile) VisitPanes(visit func(Pane)) {
+	if p.DisplayRoot != nil {
+		p.DisplayRoot.VisitPanes(visit)
+	}
+	for _, pw := range p.PaneWindows {
+		pw.DisplayRoot.VisitPanes(visit)
+	}
+}
+
+// ProfileForFacility returns the name of the profile associated with the
+// given TRACON, if any.
+func (gc *GlobalConfig) ProfileForFacility(tracon string) (string, bool) {
+	for _, name := range SortedMapKeys(gc.Profiles) {
+		if gc.Profiles[name].Facility == tracon {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (gc *GlobalConfig) ExportProfile(name string, filename string) error {
+	p, ok := gc.Profiles[name]
+	if !ok {
+		return ErrNoSuchProfile
+	}
+
+	b, err := json.MarshalIndent(ExportedConfigProfile{
+		Name:    name,
+		Version: CurrentConfigVersion,
+		Profile: p,
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
+
+// ImportProfile reads a profile that was written by ExportProfile and adds
+// it to the config, returning its name. If a profile with the same name
+// already exists, the imported one is renamed.
+func (gc *GlobalConfig) ImportProfile(filename string) (string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	var e ExportedConfigProfile
+	if err := json.Unmarshal(b, &e); err != nil {
+		return "", err
+	}
+	if e.Profile == nil || e.Profile.DisplayRoot == nil {
+		return "", fmt.Errorf("%s: no profile found in file", filename)
+	}
+	if e.Version > CurrentConfigVersion {
+		return "", fmt.Errorf("%s: profile is from a newer version of vice", filename)
+	}
+	if e.Version < CurrentConfigVersion {
+		e.Profile.VisitPanes(func(p Pane) {
+			if up, ok := p.(PaneUpgrader); ok {
+				up.Upgrade(e.Version, CurrentConfigVersion)
+			}
+		})
+	}
+
+	base := e.Name
+	if base == "" {
+		base = strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	}
+	name := base
+	for i := 2; ; i++ {
+		if _, ok := gc.Profiles[name]; !ok {
+			break
+		}
+		name = fmt.Sprintf("%s (%d)", base, i)
+	}
+
+	if gc.Profiles == nil {
+		gc.Profiles = make(map[string]*ConfigProfile)
+	}
+	gc.Profiles[name] = e.Profile
+	return name, nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Profiles UI
+
+var profilesWindow struct {
+	visible    bool
+	newName    string
+	fileDialog *FileSelectDialogBox
+}
+
+func uiToggleShowProfilesWindow() {
+	profilesWindow.visible = !profilesWindow.visible
+}
+
+// uiDrawProfilesMenu draws the menu bar menu that allows quickly switching
+// between profiles.
+func uiDrawProfilesMenu(w *World, r Renderer, eventStream *EventStream) {
+	if imgui.BeginMenu(FontAwesomeIconUserCog) {
+		for _, name := range SortedMapKeys(globalConfig.Profiles) {
+			if imgui.MenuItemV(name, "", name == globalConfig.ActiveProfile, true) &&
+				name != globalConfig.ActiveProfile {
+				if err := globalConfig.LoadProfile(name, w, r, eventStream); err != nil {
+					ShowErrorDialog("%s: unable to load profile: %v", name, err)
+				}
+			}
+		}
+		if len(globalConfig.Profiles) > 0 {
+			imgui.Separator()
+		}
+		if imgui.MenuItem("Manage profiles...") {
+			uiToggleShowProfilesWindow()
+		}
+		imgui.EndMenu()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Switch configuration profiles")
+	}
+}
+
+func uiDrawProfilesWindow(w *World, r Renderer, eventStream *EventStream) {
+	if profilesWindow.fileDialog != nil {
+		profilesWindow.fileDialog.Draw()
+	}
+
+	if !profilesWindow.visible {
+		return
+	}
+
+	imgui.BeginV("Configuration Profiles", &profilesWindow.visible, imgui.WindowFlagsAlwaysAutoResize)
+
+	imgui.InputTextV("##name", &profilesWindow.newName, 0, nil)
+	imgui.SameLine()
+	name := strings.TrimSpace(profilesWindow.newName)
+	uiStartDisable(name == "")
+	if imgui.Button("Save current as new profile") {
+		if err := globalConfig.SaveProfile(name); err != nil {
+			ShowErrorDialog("%s: unable to save profile: %v", name, err)
+		}
+		profilesWindow.newName = ""
+	}
+	uiEndDisable(name == "")
+
+	flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg |
+		imgui.TableFlagsSizingStretchProp
+	if len(globalConfig.Profiles) > 0 && imgui.BeginTableV("profiles", 3, flags, imgui.Vec2{}, 0.) {
+		imgui.TableSetupColumn("Profile")
+		imgui.TableSetupColumn("Facility")
+		imgui.TableSetupColumn("")
+		imgui.TableHeadersRow()
+
+		for _, name := range SortedMapKeys(globalConfig.Profiles) {
+			p := globalConfig.Profiles[name]
+			imgui.PushID(name)
+
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.Text(Select(name == globalConfig.ActiveProfile, "* ", "  ") + name)
+
+			imgui.TableNextColumn()
+			if w != nil {
+				auto := p.Facility != "" && p.Facility == w.TRACON
+				if imgui.Checkbox("Use for "+w.TRACON, &auto) {
+					p.Facility = Select(auto, w.TRACON, "")
+				}
+			} else {
+				imgui.Text(p.Facility)
+			}
+
+			imgui.TableNextColumn()
+			if imgui.Button("Load") {
+				if err := globalConfig.LoadProfile(name, w, r, eventStream); err != nil {
+					ShowErrorDialog("%s: unable to load profile: %v", name, err)
+				}
+			}
+			imgui.SameLine()
+			if imgui.Button("Update") {
+				if err := globalConfig.SaveProfile(name); err != nil {
+					ShowErrorDialog("%s: unable to save profile: %v", name, err)
+				}
+			}
+			imgui.SameLine()
+			if imgui.Button("Export") {
+				profilesWindow.fileDialog = NewDirectorySelectDialogBox("Export to directory...", "",
+					func(dir string) {
+						fn := path.Join(dir, name+".json")
+						if err := globalConfig.ExportProfile(name, fn); err != nil {
+							ShowErrorDialog("%s: unable to export profile: %v", fn, err)
+						} else {
+							eventStream.Post(Event{Type: StatusMessageEvent, Message: "Exported profile to " + fn})
+						}
+					})
+				profilesWindow.fileDialog.Activate()
+			}
+			imgui.SameLine()
+			if imgui.Button(FontAwesomeIconTrash) {
+				delete(globalConfig.Profiles, name)
+				if globalConfig.ActiveProfile == name {
+					globalConfig.ActiveProfile = ""
+				}
+			}
+
+			imgui.PopID()
+		}
+		imgui.EndTable()
+	}
+
+	if imgui.CollapsingHeader("Sync") {
+		uiDrawConfigSyncSettings()
+	}
+
+	if imgui.Button("Import...") {
+		profilesWindow.fileDialog = NewFileSelectDialogBox("Import profile...", []string{".json"}, "",
+			func(fn string) {
+				if name, err := globalConfig.ImportProfile(fn); err != nil {
+					ShowErrorDialog("%s: unable to import profile: %v", fn, err)
+				} else {
+					eventStream.Post(Event{Type: StatusMessageEvent, Message: "Imported profile " + name})
+				}
+			})
+		profilesWindow.fileDialog.Activate()
+	}
+
+	imgui.End()
+}
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"bytes"
 	_ "embed"
 	"fmt"
 	"image"
@@ -13,7 +14,6 @@ import (
 	"image/png"
 	"log/slog"
 	"math"
-	"net/http"
 	"net/url"
 	"sort"
 	"time"
@@ -37,7 +37,10 @@ type WeatherRadar struct {
 
 	// Texture id for each wx level's image.
 	texId [NumWxLevels]uint32
-	wxCb  [NumWxLevels]CommandBuffer
+	// Colors used for the textures, so that they can be regenerated if
+	// the theme changes.
+	texColors [3]RGB
+	wxCb      [NumWxLevels]CommandBuffer
 }
 
 const NumWxLevels = 6
@@ -65,42 +68,54 @@ func (w *WeatherRadar) Activate(center Point2LL, r Renderer) {
 	w.cbChan = make(chan [NumWxLevels]CommandBuffer, 8)
 
 	if w.texId[0] == 0 {
-		// Create a small texture for each weather level
-		img := image.NewRGBA(image.Rectangle{Max: image.Point{X: WxBlockRes, Y: WxBlockRes}})
+		w.makeTextures(r)
+	}
 
-		for i := 0; i < NumWxLevels; i++ {
-			// RGBs from STARS Manual, B-5
-			baseColor := Select(i < 3, color.RGBA{R: 37, G: 77, B: 77, A: 255},
-				color.RGBA{R: 100, G: 100, B: 51, A: 255})
-			stipple := i % 3
-
-			for y := 0; y < WxBlockRes; y++ {
-				for x := 0; x < WxBlockRes; x++ {
-					c := baseColor
-					switch stipple {
-					case 1: // light stipple: every other line, every 4th pixel
-						if y&1 == 1 {
-							offset := y & 2 // alternating 0 and 2
-							if x%4 == offset {
-								c = color.RGBA{R: 250, G: 250, B: 250, A: 255}
-							}
-						}
+	go fetchWeather(w.reqChan, w.cbChan)
+}
+
+// makeTextures creates (or updates) the small stippled texture used for
+// each weather level using the current weather colors.
+func (w *WeatherRadar) makeTextures(r Renderer) {
+	w.texColors = [3]RGB{STARSWeatherLowColor, STARSWeatherHighColor, STARSWeatherStippleColor}
+	toRGBA := func(c RGB) color.RGBA {
+		return color.RGBA{R: uint8(255*c.R + 0.5), G: uint8(255*c.G + 0.5), B: uint8(255*c.B + 0.5), A: 255}
+	}
+	stippleColor := toRGBA(STARSWeatherStippleColor)
 
-					case 2: // dense stipple: every other line, every other pixel
-						if x&1 == 1 && y&1 == 1 {
-							c = color.RGBA{R: 250, G: 250, B: 250, A: 255}
+	for i := 0; i < NumWxLevels; i++ {
+		img := image.NewRGBA(image.Rectangle{Max: image.Point{X: WxBlockRes, Y: WxBlockRes}})
+		baseColor := toRGBA(Select(i < 3, STARSWeatherLowColor, STARSWeatherHighColor))
+		stipple := i % 3
+
+		for y := 0; y < WxBlockRes; y++ {
+			for x := 0; x < WxBlockRes; x++ {
+				c := baseColor
+				switch stipple {
+				case 1: // light stipple: every other line, every 4th pixel
+					if y&1 == 1 {
+						offset := y & 2 // alternating 0 and 2
+						if x%4 == offset {
+							c = stippleColor
 						}
 					}
-					img.Set(x, y, c)
+
+				case 2: // dense stipple: every other line, every other pixel
+					if x&1 == 1 && y&1 == 1 {
+						c = stippleColor
+					}
 				}
+				img.Set(x, y, c)
 			}
+		}
 
-			// Nearest filter for magnification
+		// Nearest filter for magnification
+		if w.texId[i] == 0 {
 			w.texId[i] = r.CreateTextureFromImage(img, true)
+		} else {
+			r.UpdateTextureFromImage(w.texId[i], img, true)
 		}
 	}
-
-	go fetchWeather(w.reqChan, w.cbChan)
 }
 
 // Deactivate causes the WeatherRadar to stop fetching weather updates.
@@ -331,14 +346,13 @@ func fetchWeather(reqChan chan Point2LL, cbChan chan [NumWxLevels]CommandBuffer)
 
 		// Request the image
 		lg.Info("Fetching weather", slog.String("url", url))
-		resp, err := http.Get(url)
+		b, err := FetchURLWithOptions(url, FetchOptions{Retries: 2, MaxSize: 64 << 20})
 		if err != nil {
 			lg.Infof("Weather error: %s", err)
 			continue
 		}
-		defer resp.Body.Close()
 
-		img, err := png.Decode(resp.Body)
+		img, err := png.Decode(bytes.NewReader(b))
 		if err != nil {
 			lg.Infof("Weather error: %s", err)
 			continue
@@ -448,6 +462,10 @@ func (w *WeatherRadar) Draw(ctx *PaneContext, intensity float32, contrast float3
 	}
 
 	if w.active {
+		if w.texColors != [3]RGB{STARSWeatherLowColor, STARSWeatherHighColor, STARSWeatherStippleColor} {
+			w.makeTextures(ctx.renderer)
+		}
+
 		transforms.LoadLatLongViewingMatrices(cb)
 		cb.SetRGBA(RGBA{1, 1, 1, intensity})
 		cb.Blend()
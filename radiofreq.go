@@ -0,0 +1,168 @@
+// radiofreq.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Simulation of frequency congestion. When enabled, radio transmissions
+// aren't shown as soon as the sim generates them; rather, each one
+// occupies the frequency for as long as it would take to say it, others
+// wait until the frequency is clear, and two pilots who key up at the
+// same time block each other and have to call again later. Squelch and
+// static sounds play as transmissions start and end.
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type RadioCongestionConfig struct {
+	Enabled bool
+	// Probability that two pilots who call at the same time block each
+	// other.
+	BlockProbability float32
+}
+
+func (rc *RadioCongestionConfig) DrawUI() {
+	imgui.Checkbox("Simulate frequency congestion", &rc.Enabled)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Transmissions take time and pilots who call at the same time may block each other")
+	}
+	uiStartDisable(!rc.Enabled)
+	imgui.SliderFloatV("Blocked transmission probability", &rc.BlockProbability, 0.05, 1, "%.2f", 0)
+	uiEndDisable(!rc.Enabled)
+}
+
+type pendingTransmission struct {
+	callsign string
+	message  Message
+	// Pilot-initiated transmissions may be blocked by each other;
+	// readbacks aren't.
+	contact bool
+	// Earliest time that it may be transmitted.
+	ready time.Time
+}
+
+// RadioFrequency models a single frequency; times are in sim time so that
+// pausing the sim or changing its rate is handled naturally.
+type RadioFrequency struct {
+	queue []pendingTransmission
+	// The transmission that is currently in progress, if any, and when
+	// it will end.
+	transmitting string
+	busyUntil    time.Time
+}
+
+// airtime returns the time it takes to say the given message.
+func airtime(message string) time.Duration {
+	const wordsPerSecond = 2.5
+	words := len(strings.Fields(message))
+	return 400*time.Millisecond + time.Duration(float32(words)/wordsPerSecond*float32(time.Second))
+}
+
+// Transmit queues a pilot's transmission to be sent once the frequency is
+// clear.
+func (rf *RadioFrequency) Transmit(callsign string, message Message, contact bool, now time.Time) {
+	rf.queue = append(rf.queue, pendingTransmission{
+		callsign: callsign,
+		message:  message,
+		contact:  contact,
+		ready:    now,
+	})
+}
+
+// ControllerTransmit marks the frequency as busy while the controller
+// issues the given number of instructions.
+func (rf *RadioFrequency) ControllerTransmit(n int, now time.Time) {
+	d := time.Second + time.Duration(n)*time.Second
+	if end := now.Add(d); end.After(rf.busyUntil) {
+		rf.busyUntil = end
+	}
+}
+
+// Busy returns the callsign of the aircraft that is transmitting if the
+// frequency is busy; the callsign is empty if the controller is
+// transmitting or if a transmission is blocked.
+func (rf *RadioFrequency) Busy(now time.Time) (bool, string) {
+	return now.Before(rf.busyUntil), rf.transmitting
+}
+
+// Update returns the messages for transmissions that have started since
+// the last call.
+func (rf *RadioFrequency) Update(config RadioCongestionConfig, now time.Time) []Message {
+	var messages []Message
+	if !config.Enabled {
+		// Flush anything that was pending when congestion was disabled.
+		for _, t := range rf.queue {
+			messages = append(messages, t.message)
+		}
+		rf.queue = nil
+		rf.stop()
+		return messages
+	}
+
+	for {
+		if now.Before(rf.busyUntil) {
+			return messages
+		}
+		if rf.transmitting != "" {
+			rf.stop()
+			globalConfig.Audio.PlayOnce(AudioSquelchTail)
+		}
+
+		// Find the transmissions that are waiting to go.
+		var ready []int
+		for i, t := range rf.queue {
+			if !t.ready.After(now) {
+				ready = append(ready, i)
+			}
+		}
+		if len(ready) == 0 {
+			return messages
+		}
+
+		t := rf.queue[ready[0]]
+		if t.contact {
+			// Is another pilot calling at the same time?
+			for _, j := range ready[1:] {
+				other := &rf.queue[j]
+				if !other.contact || other.callsign == t.callsign || rand.Float32() >= config.BlockProbability {
+					continue
+				}
+
+				// Both transmissions are blocked; each pilot will try
+				// again after waiting a random amount of time.
+				const blocked = 1500 * time.Millisecond
+				rf.queue[ready[0]].ready = now.Add(blocked + time.Duration(2+rand.Intn(7))*time.Second)
+				other.ready = now.Add(blocked + time.Duration(2+rand.Intn(7))*time.Second)
+				rf.busyUntil = now.Add(blocked)
+				messages = append(messages, Message{contents: "(blocked transmission)", error: true})
+				globalConfig.Audio.PlayOnce(AudioBlockedTransmission)
+				break
+			}
+			if now.Before(rf.busyUntil) {
+				continue
+			}
+		}
+
+		rf.queue = DeleteSliceElement(rf.queue, ready[0])
+		messages = append(messages, t.message)
+		rf.transmitting = t.callsign
+		rf.busyUntil = now.Add(airtime(t.message.contents))
+		globalConfig.Audio.StartPlayContinuous(AudioRadioStatic)
+	}
+}
+
+func (rf *RadioFrequency) stop() {
+	rf.transmitting = ""
+	globalConfig.Audio.StopPlayContinuous(AudioRadioStatic)
+}
+
+// Reset discards all pending transmissions.
+func (rf *RadioFrequency) Reset() {
+	rf.queue = nil
+	rf.busyUntil = time.Time{}
+	rf.stop()
+}
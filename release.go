@@ -0,0 +1,93 @@
+// release.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Departure releases: at airports with "departure_release" set, departures
+// wait on the ground until the controller who will first work them
+// releases them. The (virtual) tower requests the release when the
+// departure is ready; the controller may release it immediately or give a
+// time after which it may depart, e.g. to fit it in with other traffic.
+// If the departure's first controller isn't a human, it's released
+// automatically.
+//
+// Releases are issued with the REL command: "REL" releases the aircraft
+// immediately and "RELn" releases it to depart in n minutes.
+
+import (
+	"log/slog"
+	"time"
+)
+
+type DepartureRelease struct {
+	// Controller that the release was requested from.
+	Controller  string
+	RequestTime time.Time
+	Released    bool
+	// When released, the aircraft may depart at or after this time.
+	Time time.Time
+}
+
+// Waiting returns true if the aircraft may not yet depart.
+func (r *DepartureRelease) Waiting(now time.Time) bool {
+	return r != nil && (!r.Released || now.Before(r.Time))
+}
+
+// requestDepartureRelease is called with s.mu held when a departure is
+// launched; if its airport requires releases, it is held on the ground
+// and the tower asks the departure's first controller for a release.
+func (s *Sim) requestDepartureRelease(ac *Aircraft) {
+	if ac.FlightPlan == nil {
+		return
+	}
+	ap := s.World.Airports[ac.FlightPlan.DepartureAirport]
+	if ap == nil || !ap.DepartureRelease {
+		return
+	}
+
+	ctrl := ac.DepartureContactController
+	if ctrl == "" {
+		// A virtual controller has the initial track; the release comes
+		// from the controller who will be handed the aircraft.
+		ctrl = s.ResolveController(ac.WaypointHandoffController)
+	}
+	if c := s.World.GetControllerByCallsign(ctrl); c == nil || !c.IsHuman {
+		return
+	}
+
+	ac.Release = &DepartureRelease{Controller: ctrl, RequestTime: s.SimTime}
+	s.PostEvent(Event{
+		Type:           DepartureReleaseRequestEvent,
+		Callsign:       ac.Callsign,
+		FromController: ac.FlightPlan.DepartureAirport,
+		ToController:   ctrl,
+		Message:        ac.FlightPlan.DepartureAirport + " tower requests release for " + ac.Callsign,
+	})
+	s.lg.Info("departure release requested", slog.String("callsign", ac.Callsign),
+		slog.String("controller", ctrl))
+}
+
+// ReleaseDeparture releases a departure that is waiting for release,
+// allowing it to depart after the given delay.
+func (s *Sim) ReleaseDeparture(token, callsign string, delay time.Duration) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	return s.dispatchCommand(token, callsign,
+		func(ctrl *Controller, ac *Aircraft) error {
+			if ac.Release == nil || ac.Release.Released {
+				return ErrNoReleaseRequested
+			} else if ac.Release.Controller != ctrl.Callsign {
+				return ErrOtherControllerHasTrack
+			}
+			return nil
+		},
+		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
+			ac.Release.Released = true
+			ac.Release.Time = s.SimTime.Add(delay)
+			s.lg.Info("departure released", slog.String("callsign", ac.Callsign),
+				slog.Duration("delay", delay))
+			return nil
+		})
+}
@@ -0,0 +1,251 @@
+// relief.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Position relief: a controller can ask to relieve another controller who
+// is signed in to the same sim. The controller being relieved then goes
+// through a briefing checklist that is generated from the current state
+// of the sim before transferring the position; the two controllers swap
+// positions, with tracks staying with the positions.
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+var relief struct {
+	visible bool
+	events  *EventsSubscription
+
+	// Position that the user would like to relieve.
+	position string
+	// Set when another controller has asked to relieve the user.
+	requester string
+	// Briefing categories that have been covered.
+	briefed map[string]bool
+
+	status string
+	err    error
+}
+
+func uiToggleShowReliefWindow() {
+	relief.visible = !relief.visible
+}
+
+type ReliefBriefingItem struct {
+	Category string
+	Items    []string
+}
+
+// reliefBriefing returns the items that should be covered in a relief
+// briefing for the user's current position.
+func reliefBriefing(w *World) []ReliefBriefingItem {
+	var briefing []ReliefBriefingItem
+	add := func(category string, items []string) {
+		if len(items) == 0 {
+			items = []string{"None"}
+		}
+		briefing = append(briefing, ReliefBriefingItem{Category: category, Items: items})
+	}
+
+	var wx []string
+	for _, icao := range SortedMapKeys(w.METAR) {
+		m := w.METAR[icao]
+		wx = append(wx, fmt.Sprintf("%s: wind %s, altimeter %s %s", icao, m.Wind, m.Altimeter, m.Weather))
+	}
+	add("Weather", wx)
+
+	var rwys []string
+	for _, r := range w.DepartureRunways {
+		rwys = append(rwys, fmt.Sprintf("Departing %s runway %s", r.Airport, r.Runway)+
+			Select(r.Category != "", " ("+r.Category+")", ""))
+	}
+	for _, r := range w.ArrivalRunways {
+		rwys = append(rwys, fmt.Sprintf("Landing %s runway %s", r.Airport, r.Runway))
+	}
+	rwys = append(rwys, w.RunwayWindWarnings()...)
+	add("Runway configuration", rwys)
+
+	var tracked, inbound, outbound []string
+	for _, callsign := range SortedMapKeys(w.Aircraft) {
+		ac := w.Aircraft[callsign]
+		if ac.TrackingController == w.Callsign {
+			s := fmt.Sprintf("%s at %d", callsign, int(ac.Altitude()))
+			if fp := ac.FlightPlan; fp != nil {
+				s = fmt.Sprintf("%s %s at %d", callsign, fp.TypeWithoutSuffix(), int(ac.Altitude()))
+				if fp.ArrivalAirport != "" {
+					s += " to " + fp.ArrivalAirport
+				}
+			}
+			tracked = append(tracked, s)
+
+			if ac.HandoffTrackController != "" {
+				outbound = append(outbound, callsign+" to "+ac.HandoffTrackController)
+			}
+		} else if ac.HandoffTrackController == w.Callsign {
+			inbound = append(inbound, callsign+" from "+ac.TrackingController)
+		}
+	}
+	add(fmt.Sprintf("Traffic (%d tracked)", len(tracked)), tracked)
+
+	var handoffs []string
+	for _, h := range inbound {
+		handoffs = append(handoffs, "Inbound "+h)
+	}
+	for _, h := range outbound {
+		handoffs = append(handoffs, "Outbound "+h)
+	}
+	add("Pending handoffs", handoffs)
+
+	var pointOuts []string
+	globalConfig.VisitAllPanes(func(pane Pane) {
+		if sp, ok := pane.(*STARSPane); ok {
+			for _, callsign := range SortedMapKeys(sp.InboundPointOuts) {
+				pointOuts = append(pointOuts, "Received "+callsign+
+					Select(sp.InboundPointOuts[callsign] != "", " from "+sp.InboundPointOuts[callsign], ""))
+			}
+			for _, callsign := range SortedMapKeys(sp.OutboundPointOuts) {
+				pointOuts = append(pointOuts, "Made "+callsign+" to "+sp.OutboundPointOuts[callsign])
+			}
+		}
+	})
+	add("Point outs", pointOuts)
+
+	var status []string
+	if w.LaunchConfig.Controller == w.Callsign {
+		status = append(status, "Controlling departure and arrival launches")
+	}
+	if w.SimIsPaused {
+		status = append(status, "Simulation is paused")
+	}
+	add("Equipment and status", status)
+
+	return briefing
+}
+
+// uiDrawReliefWindow draws the position relief window and handles relief
+// requests from other controllers.
+func uiDrawReliefWindow(w *World, eventStream *EventStream) {
+	if w == nil || !w.Connected() {
+		return
+	}
+
+	if relief.events == nil {
+		relief.events = eventStream.Subscribe()
+	}
+	for _, e := range relief.events.Get() {
+		if e.Type == ReliefRequestEvent && e.ToController == w.Callsign {
+			relief.requester = e.FromController
+			relief.briefed = make(map[string]bool)
+			relief.status, relief.err = "", nil
+			relief.visible = true
+			globalConfig.Audio.PlayOnce(AudioNewMessage)
+		}
+	}
+
+	if !relief.visible {
+		return
+	}
+
+	imgui.BeginV("Position Relief", &relief.visible, imgui.WindowFlagsAlwaysAutoResize)
+
+	text := "Signed in to " + w.Callsign
+	if ctrl := w.GetControllerByCallsign(w.Callsign); ctrl != nil && ctrl.Initials != "" {
+		text += " (" + ctrl.Initials + ")"
+	}
+	imgui.Text(text)
+
+	if relief.err != nil {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{1, .5, .5, 1})
+		imgui.Text(relief.err.Error())
+		imgui.PopStyleColor()
+	} else if relief.status != "" {
+		imgui.Text(relief.status)
+	}
+	imgui.Separator()
+
+	briefing := reliefBriefing(w)
+
+	if relief.requester != "" {
+		imgui.Text(relief.requester + " has asked to relieve you. Brief them on each of the following")
+		imgui.Text("and then transfer the position.")
+
+		done := true
+		for _, item := range briefing {
+			category := strings.Fields(item.Category)[0] // ignore counts
+			b := relief.briefed[category]
+			imgui.Checkbox(item.Category, &b)
+			relief.briefed[category] = b
+			done = done && b
+
+			imgui.Indent()
+			for _, s := range item.Items {
+				imgui.Text(s)
+			}
+			imgui.Unindent()
+		}
+
+		imgui.Separator()
+		uiStartDisable(!done)
+		if imgui.Button("Transfer position") {
+			w.AcceptRelief(func(any) {
+				relief.requester = ""
+				relief.status = "You have been relieved"
+			}, func(err error) { relief.err = err })
+		}
+		uiEndDisable(!done)
+		imgui.SameLine()
+		if imgui.Button("Decline") {
+			relief.requester = ""
+		}
+	} else {
+		var positions []string
+		for _, callsign := range SortedMapKeys(w.GetAllControllers()) {
+			if ctrl := w.GetControllerByCallsign(callsign); ctrl != nil && ctrl.IsHuman && callsign != w.Callsign {
+				positions = append(positions, callsign)
+			}
+		}
+
+		if len(positions) == 0 {
+			imgui.Text("No other controllers are signed in.")
+		} else {
+			if relief.position == "" || !slices.Contains(positions, relief.position) {
+				relief.position = positions[0]
+			}
+			if imgui.BeginComboV("Position", relief.position, 0) {
+				for _, pos := range positions {
+					if imgui.SelectableV(pos, pos == relief.position, 0, imgui.Vec2{}) {
+						relief.position = pos
+					}
+				}
+				imgui.EndCombo()
+			}
+			imgui.SameLine()
+			if imgui.Button("Request relief") {
+				relief.status, relief.err = "", nil
+				pos := relief.position
+				w.RequestRelief(pos, func(any) {
+					relief.status = "Waiting for the relief briefing from " + pos
+				}, func(err error) { relief.err = err })
+			}
+		}
+
+		if imgui.CollapsingHeader("Briefing checklist for " + w.Callsign) {
+			for _, item := range briefing {
+				imgui.Text(item.Category)
+				imgui.Indent()
+				for _, s := range item.Items {
+					imgui.Text(s)
+				}
+				imgui.Unindent()
+			}
+		}
+	}
+
+	imgui.End()
+}
@@ -16,9 +16,10 @@ import (
 )
 
 // Renderer defines an interface for all of the various drawing that happens in vice.
-// There is currently a single implementation of it--OpenGL2Renderer--though having
-// all of these details behind the Renderer interface would make it realtively easy
-// to write a Vulkan, Metal, or DirectX rendering backend.
+// There are currently two implementations of it--OpenGL2Renderer and
+// OpenGL3Renderer--though having all of these details behind the Renderer
+// interface would make it realtively easy to write a Vulkan, Metal, or
+// DirectX rendering backend.
 type Renderer interface {
 	// CreateTextureFromImage returns an identifier for a texture map defined
 	// by the specified image.
@@ -44,10 +45,33 @@ type Renderer interface {
 	// rendered.
 	RenderCommandBuffer(*CommandBuffer) RendererStats
 
+	// ReadPixels returns the contents of the given region of the current
+	// framebuffer; (x,y) is the lower-left corner of the region, in
+	// pixels.
+	ReadPixels(x, y, width, height int) *image.RGBA
+
 	// Dispose releases resources allocated by the renderer.
 	Dispose()
 }
 
+// finishReadPixels converts an image read from OpenGL, with the first
+// row at the bottom, to the usual top-down order and makes it opaque.
+func finishReadPixels(img *image.RGBA) {
+	stride := img.Stride
+	ny := img.Bounds().Dy()
+	row := make([]byte, stride)
+	for y := 0; y < ny/2; y++ {
+		a := img.Pix[y*stride : (y+1)*stride]
+		b := img.Pix[(ny-1-y)*stride : (ny-y)*stride]
+		copy(row, a)
+		copy(a, b)
+		copy(b, row)
+	}
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+}
+
 // RendererStats encapsulates assorted statistics from rendering.
 type RendererStats struct {
 	nBuffers, bufferBytes               int
@@ -129,6 +153,8 @@ const (
 	RendererDrawQuads                   // 2 int32: offset to the index buffer, count
 	RendererCallBuffer                  // 1 int32: buffer index
 	RendererResetState                  // no args
+	RendererLineStipple                 // 2 int32: factor, 16-bit pattern (as glLineStipple)
+	RendererDrawLinesInstanced          // 5 int32: offset to the index buffer, count, offsets to instance positions and RGB colors, instance count
 )
 
 // CommandBuffer encodes a sequence of rendering commands in an
@@ -138,6 +164,11 @@ const (
 type CommandBuffer struct {
 	Buf    []uint32
 	called []CommandBuffer
+	// If non-zero, framebufferScale is used by SetDrawBounds in place of
+	// the main window's framebuffer to display size ratio; it is set when
+	// drawing panes in secondary windows, which may be on a monitor with
+	// a different resolution.
+	framebufferScale float32
 }
 
 // CommandBuffers are managed using a sync.Pool so that their buf slice
@@ -158,6 +189,7 @@ func ReturnCommandBuffer(cb *CommandBuffer) {
 func (cb *CommandBuffer) Reset() {
 	cb.Buf = cb.Buf[:0]
 	cb.called = cb.called[:0]
+	cb.framebufferScale = 0
 }
 
 // growFor ensures that at least n more values can be added to the end of
@@ -254,7 +286,10 @@ func (cb *CommandBuffer) SetDrawBounds(b Extent2D) {
 	// One messy detail here is that these windows are specified in
 	// framebuffer coordinates, not display coordinates, so they must be
 	// scaled for e.g., retina displays.
-	scale := platform.FramebufferSize()[1] / platform.DisplaySize()[1]
+	scale := cb.framebufferScale
+	if scale == 0 {
+		scale = platform.FramebufferSize()[1] / platform.DisplaySize()[1]
+	}
 	x0, y0 := int(scale*b.p0[0]), int(scale*b.p0[1])
 	w, h := int(scale*b.Width()), int(scale*b.Height())
 	w, h = max(w, 0), max(h, 0)
@@ -442,6 +477,49 @@ func (cb *CommandBuffer) LineWidth(w float32) {
 	cb.appendFloats(w * platform.DPIScale())
 }
 
+// LineStyle represents the pattern used for drawing lines.
+type LineStyle int
+
+const (
+	LineStyleSolid LineStyle = iota
+	LineStyleDashed
+	LineStyleDotted
+	LineStyleDashDot
+)
+
+func (ls LineStyle) String() string {
+	return [...]string{"Solid", "Dashed", "Dotted", "Dash-dot"}[ls]
+}
+
+// stipple returns the repeat factor and 16-bit pattern for the line
+// style, in the form expected by glLineStipple: each bit of the pattern
+// covers factor pixels, starting from the low bit.
+func (ls LineStyle) stipple() (int, uint16) {
+	switch ls {
+	case LineStyleDashed:
+		return 2, 0x00ff
+	case LineStyleDotted:
+		return 1, 0x3333
+	case LineStyleDashDot:
+		return 1, 0x18ff
+	default:
+		return 0, 0xffff
+	}
+}
+
+// LineStyle adds a command to the command buffer that sets the pattern
+// used for subsequent lines that are drawn. The pattern restarts at the
+// start of each line segment.
+func (cb *CommandBuffer) LineStyle(ls LineStyle) {
+	factor, pattern := ls.stipple()
+	if factor > 0 {
+		// As with line widths, scale so that the pattern has the same
+		// size on retina-style displays.
+		factor = max(1, int(float32(factor)*platform.DPIScale()+0.5))
+	}
+	cb.appendInts(RendererLineStipple, factor, int(pattern))
+}
+
 // DrawLines adds a command to the command buffer to draw a number of
 // lines; each line is specified by two indices in the index buffer.
 // offset gives the offset in the current command buffer where the index
@@ -451,6 +529,16 @@ func (cb *CommandBuffer) DrawLines(offset, count int) {
 	cb.appendInts(RendererDrawLines, offset, count)
 }
 
+// DrawLinesInstanced adds a command to the command buffer to draw the
+// lines given by the index buffer at offset once for each of n instances.
+// positions and colors are the offsets of float32 arrays (e.g., as
+// returned by Float2Buffer and RGBBuffer) with each instance's
+// translation, which is added to the vertex positions, and RGB color,
+// which is used in place of the current color or color array.
+func (cb *CommandBuffer) DrawLinesInstanced(offset, count, positions, colors, n int) {
+	cb.appendInts(RendererDrawLinesInstanced, offset, count, positions, colors, n)
+}
+
 // DrawTriangles adds a command to the command buffer to draw a number of
 // triangles; each is specified by three vertices in the index
 // buffer. offset gives the offset to the start of the index buffer in the
@@ -723,6 +811,19 @@ func (l *LinesDrawBuilder) GenerateCommands(cb *CommandBuffer) {
 	cb.DisableVertexArray()
 }
 
+// PointsDrawBuilders are managed using a sync.Pool so that their buf slice
+// allocations persist across multiple uses.
+var pointsDrawBuilderPool = sync.Pool{New: func() any { return &PointsDrawBuilder{} }}
+
+func GetPointsDrawBuilder() *PointsDrawBuilder {
+	return pointsDrawBuilderPool.Get().(*PointsDrawBuilder)
+}
+
+func ReturnPointsDrawBuilder(pd *PointsDrawBuilder) {
+	pd.Reset()
+	pointsDrawBuilderPool.Put(pd)
+}
+
 // LinesDrawBuilders are managed using a sync.Pool so that their buf slice
 // allocations persist across multiple uses.
 var linesDrawBuilderPool = sync.Pool{New: func() any { return &LinesDrawBuilder{} }}
@@ -799,6 +900,56 @@ func ReturnColoredLinesDrawBuilder(ld *ColoredLinesDrawBuilder) {
 	coloredLinesDrawBuilderPool.Put(ld)
 }
 
+// InstancedLinesDrawBuilder draws the same set of lines at multiple
+// positions, each with its own color, using a single instanced draw call;
+// it is used for symbols that are drawn for many aircraft. The lines are
+// specified using the LinesDrawBuilder methods, relative to the instance
+// positions.
+type InstancedLinesDrawBuilder struct {
+	LinesDrawBuilder
+	positions [][2]float32
+	colors    []RGB
+}
+
+func (l *InstancedLinesDrawBuilder) Reset() {
+	l.LinesDrawBuilder.Reset()
+	l.positions = l.positions[:0]
+	l.colors = l.colors[:0]
+}
+
+// AddInstance adds an instance of the lines at the given position.
+func (l *InstancedLinesDrawBuilder) AddInstance(p [2]float32, color RGB) {
+	l.positions = append(l.positions, p)
+	l.colors = append(l.colors, color)
+}
+
+func (l *InstancedLinesDrawBuilder) GenerateCommands(cb *CommandBuffer) {
+	if len(l.indices) == 0 || len(l.positions) == 0 {
+		return
+	}
+
+	p := cb.Float2Buffer(l.p)
+	cb.VertexArray(p, 2, 2*4)
+	pos := cb.Float2Buffer(l.positions)
+	rgb := cb.RGBBuffer(l.colors)
+
+	ind := cb.IntBuffer(l.indices)
+	cb.DrawLinesInstanced(ind, len(l.indices), pos, rgb, len(l.positions))
+
+	cb.DisableVertexArray()
+}
+
+var instancedLinesDrawBuilderPool = sync.Pool{New: func() any { return &InstancedLinesDrawBuilder{} }}
+
+func GetInstancedLinesDrawBuilder() *InstancedLinesDrawBuilder {
+	return instancedLinesDrawBuilderPool.Get().(*InstancedLinesDrawBuilder)
+}
+
+func ReturnInstancedLinesDrawBuilder(ld *InstancedLinesDrawBuilder) {
+	ld.Reset()
+	instancedLinesDrawBuilderPool.Put(ld)
+}
+
 // TrianglesDrawBuilder collects triangles to be batched up in a single
 // draw call. Note that it does not allow specifying per-vertex or
 // per-triangle color; rather, the current color as specified by a call to
@@ -0,0 +1,182 @@
+// runwayoccupancy.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Runway occupancy: arrivals don't vacate the runway the moment that they
+// reach the threshold. After touchdown, an aircraft decelerates at a rate
+// derived from its type's landing distance until it's slow enough to turn
+// off at the first usable exit; it then takes a few more seconds to clear
+// the runway. Scenarios may describe each runway's exits, e.g.:
+//
+//	"runway_exits": {
+//	    "31L": [ { "distance": 4500 }, { "distance": 6200, "high_speed": true } ]
+//	}
+//
+// where distances are in feet from the threshold. High-speed exits can be
+// taken at a higher speed than right-angle ones. Without exits for a
+// runway, aircraft are assumed to turn off as soon as they've slowed down.
+//
+// If the runway is still occupied when the next arrival would reach the
+// threshold, tower sends it around, so that final spacing that's too
+// tight has consequences. Departures are likewise held until the runway
+// is clear.
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+type RunwayExit struct {
+	Distance  float32 `json:"distance"` // feet from the threshold
+	HighSpeed bool    `json:"high_speed"`
+}
+
+const (
+	touchdownDistance = 1000 // feet past the threshold
+	// Fraction of the aircraft type's landing distance that is the
+	// ground roll to a stop.
+	landingRollFraction = 0.5
+	// Speeds (knots) at which exits are taken.
+	highSpeedExitSpeed  = 50
+	rightAngleExitSpeed = 15
+	// Time to taxi clear of the runway after turning off.
+	highSpeedExitClearTime  = 8 * time.Second
+	rightAngleExitClearTime = 12 * time.Second
+	// Distance from the threshold at which tower decides whether an
+	// arrival must go around.
+	runwayOccupiedGoAroundDistance = 0.5 // nm
+)
+
+type RunwayOccupant struct {
+	Callsign string
+	Clear    time.Time
+}
+
+// checkRunwayExits validates the airport's runway exits and sorts them by
+// distance from the threshold.
+func (ap *Airport) checkRunwayExits(icao string, e *ErrorLogger) {
+	for rwy, exits := range ap.RunwayExits {
+		e.Push("Runway exits " + rwy)
+		if _, ok := LookupRunway(icao, rwy); !ok {
+			e.ErrorString("runway \"%s\" is unknown. Options: %s", rwy, database.Airports[icao].ValidRunways())
+		}
+		for _, exit := range exits {
+			if exit.Distance <= 0 {
+				e.ErrorString("\"distance\" must be positive")
+			}
+		}
+		sort.Slice(exits, func(i, j int) bool { return exits[i].Distance < exits[j].Distance })
+		e.Pop()
+	}
+}
+
+// runwayOccupancyTime returns how long an aircraft with the given
+// performance occupies the runway after crossing the threshold, given the
+// runway's exits, sorted by distance.
+func runwayOccupancyTime(perf AircraftPerformance, exits []RunwayExit) time.Duration {
+	const ftPerKnotSecond = 1.68781 // feet per second in a knot
+
+	v0 := perf.Speed.Landing
+	if v0 == 0 {
+		v0 = 130
+	}
+	roll := landingRollFraction * perf.Runway.Landing // nm
+	if roll == 0 {
+		roll = 0.8
+	}
+	decel := v0 * v0 / (2 * roll) / 3600 // knots per second
+
+	// Time and distance (feet) from touchdown to slow to the given speed.
+	slow := func(v float32) (float32, float32) {
+		return (v0 - v) / decel, (v0*v0 - v*v) / (2 * decel) * ftPerKnotSecond
+	}
+	// Time to cross the threshold and touch down
+	t := touchdownDistance / (v0 * ftPerKnotSecond)
+
+	for _, exit := range exits {
+		v, clear := float32(rightAngleExitSpeed), rightAngleExitClearTime
+		if exit.HighSpeed {
+			v, clear = highSpeedExitSpeed, highSpeedExitClearTime
+		}
+		ts, ds := slow(v)
+		if remaining := exit.Distance - touchdownDistance - ds; remaining >= 0 {
+			t += ts + remaining/(v*ftPerKnotSecond)
+			return time.Duration(t*float32(time.Second)) + clear
+		}
+	}
+
+	// No exits given, or the aircraft can't make any of them; assume it
+	// turns off once it has slowed down.
+	ts, _ := slow(rightAngleExitSpeed)
+	return time.Duration((t+ts)*float32(time.Second)) + rightAngleExitClearTime
+}
+
+func runwayKey(airport, runway string) string {
+	return airport + "/" + baseRunway(runway)
+}
+
+// landed is called when an arrival reaches the runway threshold; it
+// records how long it will be on the runway.
+func (s *Sim) landed(ac *Aircraft, now time.Time) {
+	if ac.FlightPlan == nil || ac.Nav.Approach.Assigned == nil {
+		return
+	}
+	rwy := ac.Nav.Approach.Assigned.Runway
+
+	var exits []RunwayExit
+	if ap := s.World.GetAirport(ac.FlightPlan.ArrivalAirport); ap != nil {
+		exits = ap.RunwayExits[baseRunway(rwy)]
+	}
+	rot := runwayOccupancyTime(ac.Nav.Perf, exits)
+
+	if s.RunwayOccupancy == nil {
+		s.RunwayOccupancy = make(map[string]RunwayOccupant)
+	}
+	s.RunwayOccupancy[runwayKey(ac.FlightPlan.ArrivalAirport, rwy)] = RunwayOccupant{
+		Callsign: ac.Callsign,
+		Clear:    now.Add(rot),
+	}
+	s.lg.Info("landed", slog.String("callsign", ac.Callsign), slog.String("runway", rwy),
+		slog.Duration("occupancy", rot))
+}
+
+// runwayOccupant returns the aircraft that will still be on the runway at
+// the given time, if any.
+func (s *Sim) runwayOccupant(airport, runway string, t time.Time) (string, bool) {
+	occ, ok := s.RunwayOccupancy[runwayKey(airport, runway)]
+	if !ok || !t.Before(occ.Clear) {
+		return "", false
+	}
+	return occ.Callsign, true
+}
+
+// checkRunwayOccupied is called once a second with s.mu held for each
+// aircraft; if it's an arrival on short final and the runway won't be
+// clear by the time it reaches the threshold, tower sends it around.
+func (s *Sim) checkRunwayOccupied(ac *Aircraft, now time.Time) {
+	if ac.FlightPlan == nil || ac.Nav.Approach.Assigned == nil || ac.Nav.FlightState.GS == 0 {
+		return
+	}
+	d, err := ac.Nav.distanceToEndOfApproach()
+	if err != nil || d > runwayOccupiedGoAroundDistance {
+		return
+	}
+
+	rwy := ac.Nav.Approach.Assigned.Runway
+	eta := time.Duration(d / ac.Nav.FlightState.GS * 3600 * float32(time.Second))
+	if occupant, ok := s.runwayOccupant(ac.FlightPlan.ArrivalAirport, rwy, now.Add(eta)); ok && occupant != ac.Callsign {
+		s.lg.Info("going around for runway occupied", slog.String("callsign", ac.Callsign),
+			slog.String("occupant", occupant))
+		s.PostEvent(Event{
+			Type: StatusMessageEvent,
+			Message: fmt.Sprintf("%s tower sent %s around, runway %s occupied by %s",
+				ac.FlightPlan.ArrivalAirport, ac.Callsign, baseRunway(rwy), occupant),
+		})
+		ac.GoAroundDistance = nil
+		ac.executeGoAround(s.World, s)
+	}
+}
@@ -0,0 +1,89 @@
+// runwayoccupancy_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunwayOccupancyTime(t *testing.T) {
+	var b738 AircraftPerformance
+	b738.Speed.Landing = 140
+	b738.Runway.Landing = 1.6
+
+	generic := runwayOccupancyTime(b738, nil)
+	if generic < 40*time.Second || generic > 70*time.Second {
+		t.Errorf("unexpected occupancy time %s without exits", generic)
+	}
+
+	// A high-speed exit at a convenient distance should be quicker.
+	highSpeed := runwayOccupancyTime(b738, []RunwayExit{{Distance: 3000}, {Distance: 5500, HighSpeed: true}})
+	if highSpeed >= generic {
+		t.Errorf("high-speed exit %s not faster than %s", highSpeed, generic)
+	}
+
+	// Missing the only nearby exit means a long roll to the next one.
+	far := runwayOccupancyTime(b738, []RunwayExit{{Distance: 3000}, {Distance: 10000}})
+	if far <= generic {
+		t.Errorf("distant exit %s not slower than %s", far, generic)
+	}
+
+	var c172 AircraftPerformance
+	c172.Speed.Landing = 65
+	c172.Runway.Landing = 0.395
+	if small := runwayOccupancyTime(c172, nil); small >= generic {
+		t.Errorf("C172 occupancy %s not less than B738's %s", small, generic)
+	}
+}
+
+func TestRunwayOccupiedGoAround(t *testing.T) {
+	now := time.Now()
+	threshold := Point2LL{-73.8, 40.6}
+
+	ac := &Aircraft{
+		Callsign:   "AAL1",
+		FlightPlan: &FlightPlan{ArrivalAirport: "KJFK"},
+	}
+	ac.Nav.Approach.Assigned = &Approach{Runway: "31L"}
+	ac.Nav.Approach.Cleared = true
+	ac.Nav.Waypoints = []Waypoint{{Fix: "_JFK_31L", Location: threshold, Delete: true}}
+	ac.Nav.FlightState.Position = Point2LL{threshold[0], threshold[1] - 0.005} // 0.3nm south
+	ac.Nav.FlightState.GS = 140
+
+	s := &Sim{
+		World: &World{
+			Aircraft: map[string]*Aircraft{"AAL1": ac},
+		},
+		eventStream: NewEventStream(),
+		RunwayOccupancy: map[string]RunwayOccupant{
+			"KJFK/31L": {Callsign: "UAL2", Clear: now.Add(5 * time.Second)},
+		},
+	}
+
+	// The runway will be clear by the time it gets there.
+	s.checkRunwayOccupied(ac, now)
+	if ac.Nav.Approach.Assigned == nil {
+		t.Fatalf("unexpected go around")
+	}
+
+	s.RunwayOccupancy["KJFK/31L"] = RunwayOccupant{Callsign: "UAL2", Clear: now.Add(30 * time.Second)}
+	s.checkRunwayOccupied(ac, now)
+	if ac.Nav.Approach.Assigned != nil {
+		t.Errorf("expected a go around for the occupied runway")
+	}
+
+	dep := &Aircraft{
+		Callsign:        "DAL3",
+		FlightPlan:      &FlightPlan{DepartureAirport: "KJFK"},
+		DepartureRunway: "31L",
+	}
+	if r := s.holdReason(dep, now); r == "" {
+		t.Errorf("expected departure to be held for the occupied runway")
+	}
+	if r := s.holdReason(dep, now.Add(time.Minute)); r != "" {
+		t.Errorf("unexpected hold %q after the runway is clear", r)
+	}
+}
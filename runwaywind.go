@@ -0,0 +1,231 @@
+// runwaywind.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Runway recommendations based on the surface winds: given an airport's
+// METAR (real or generated from the scenario's wind), the headwind and
+// crosswind components are computed for each of its runways so that the
+// controller can see which runways are favored and whether the active
+// configuration has an excessive tailwind.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+const (
+	// Runways with a greater tailwind component shouldn't be used.
+	maxRunwayTailwind = 5 // knots
+	// Runways with a greater crosswind component are flagged.
+	maxRunwayCrosswind = 20 // knots
+	// Runways with a headwind component within this much of the best one
+	// at the airport are recommended.
+	runwayHeadwindTolerance = 5 // knots
+)
+
+type METARWind struct {
+	Direction   int // true, in degrees
+	Variable    bool
+	Speed, Gust int // knots; Gust is zero if there are no gusts
+}
+
+// ParseMETARWind parses the wind from a METAR, e.g. "27012G20KT",
+// "VRB03KT", or "00000KT".
+func ParseMETARWind(s string) (METARWind, error) {
+	var wind METARWind
+	w, ok := strings.CutSuffix(s, "KT")
+	if !ok || len(w) < 4 {
+		return wind, fmt.Errorf("%s: invalid METAR wind", s)
+	}
+
+	if w[:3] == "VRB" {
+		wind.Variable = true
+	} else if dir, err := strconv.Atoi(w[:3]); err != nil {
+		return wind, fmt.Errorf("%s: invalid wind direction: %w", s, err)
+	} else {
+		wind.Direction = dir
+	}
+
+	spd, gst, gusting := strings.Cut(w[3:], "G")
+	var err error
+	if wind.Speed, err = strconv.Atoi(spd); err != nil {
+		return wind, fmt.Errorf("%s: invalid wind speed: %w", s, err)
+	}
+	if gusting {
+		if wind.Gust, err = strconv.Atoi(gst); err != nil {
+			return wind, fmt.Errorf("%s: invalid wind gust: %w", s, err)
+		}
+	}
+	return wind, nil
+}
+
+type RunwayWind struct {
+	Runway      string
+	Headwind    float32 // negative for a tailwind
+	Crosswind   float32 // always positive
+	Recommended bool
+}
+
+func (rw RunwayWind) Tailwind() float32 {
+	return max(0, -rw.Headwind)
+}
+
+// ExceedsLimits returns a description of how the runway's wind components
+// exceed the tailwind and crosswind limits, or an empty string if they
+// don't.
+func (rw RunwayWind) ExceedsLimits() string {
+	var s []string
+	if tw := rw.Tailwind(); tw > maxRunwayTailwind {
+		s = append(s, fmt.Sprintf("%d kt tailwind exceeds %d kt limit", int(tw+0.5), maxRunwayTailwind))
+	}
+	if rw.Crosswind > maxRunwayCrosswind {
+		s = append(s, fmt.Sprintf("%d kt crosswind exceeds %d kt limit", int(rw.Crosswind+0.5), maxRunwayCrosswind))
+	}
+	return strings.Join(s, ", ")
+}
+
+// runwayWinds returns the wind components for each of the given runways,
+// sorted by decreasing headwind. Gusts are used when present, giving the
+// worst case, and variable winds are taken to be a tailwind and a
+// crosswind at the full wind speed. magneticVariation is used to convert
+// the runways' magnetic headings to true, following the
+// World.MagneticVariation convention.
+func runwayWinds(runways []Runway, wind METARWind, magneticVariation float32) []RunwayWind {
+	spd := float32(max(wind.Speed, wind.Gust))
+
+	var rws []RunwayWind
+	for _, rwy := range runways {
+		rw := RunwayWind{Runway: rwy.Id}
+		if wind.Variable {
+			rw.Headwind, rw.Crosswind = -spd, spd
+		} else if spd > 0 {
+			// Angle between the runway heading and the direction the
+			// wind is coming from.
+			a := radians(float32(wind.Direction) - (rwy.Heading - magneticVariation))
+			rw.Headwind = spd * cos(a)
+			rw.Crosswind = abs(spd * sin(a))
+		}
+		rws = append(rws, rw)
+	}
+
+	sort.SliceStable(rws, func(i, j int) bool { return rws[i].Headwind > rws[j].Headwind })
+
+	if len(rws) > 0 {
+		best := rws[0].Headwind
+		for i := range rws {
+			rws[i].Recommended = rws[i].Headwind >= best-runwayHeadwindTolerance && rws[i].ExceedsLimits() == ""
+		}
+	}
+	return rws
+}
+
+// RunwayWinds returns the wind components for the runways at each of the
+// airports that the world has a METAR for.
+func (w *World) RunwayWinds() map[string][]RunwayWind {
+	result := make(map[string][]RunwayWind)
+	for icao, metar := range w.METAR {
+		ap, ok := database.Airports[icao]
+		if !ok || len(ap.Runways) == 0 {
+			continue
+		}
+		wind, err := ParseMETARWind(metar.Wind)
+		if err != nil {
+			lg.Debugf("%s: %v", icao, err)
+			continue
+		}
+		result[icao] = runwayWinds(ap.Runways, wind, w.MagneticVariationAt(ap.Location))
+	}
+	return result
+}
+
+// activeRunways returns the runways in use at each airport.
+func (w *World) activeRunways() map[string]map[string]interface{} {
+	active := make(map[string]map[string]interface{})
+	add := func(airport, rwy string) {
+		if active[airport] == nil {
+			active[airport] = make(map[string]interface{})
+		}
+		active[airport][cleanRunway(rwy)] = nil
+	}
+	for _, r := range w.DepartureRunways {
+		add(r.Airport, r.Runway)
+	}
+	for _, r := range w.ArrivalRunways {
+		add(r.Airport, r.Runway)
+	}
+	return active
+}
+
+// RunwayWindWarnings returns warnings for active runways where the
+// wind exceeds the tailwind or crosswind limits.
+func (w *World) RunwayWindWarnings() []string {
+	var warnings []string
+	active := w.activeRunways()
+	winds := w.RunwayWinds()
+	for _, icao := range SortedMapKeys(winds) {
+		for _, rw := range winds[icao] {
+			if _, ok := active[icao][rw.Runway]; !ok {
+				continue
+			}
+			if s := rw.ExceedsLimits(); s != "" {
+				warnings = append(warnings, fmt.Sprintf("%s runway %s: %s", icao, rw.Runway, s))
+			}
+		}
+	}
+	return warnings
+}
+
+func (w *World) drawRunwayWinds(tableFlags imgui.TableFlags) {
+	for _, warning := range w.RunwayWindWarnings() {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{1, .5, .5, 1})
+		imgui.Text(FontAwesomeIconExclamationTriangle + " " + warning)
+		imgui.PopStyleColor()
+	}
+
+	active := w.activeRunways()
+	winds := w.RunwayWinds()
+	for _, icao := range SortedMapKeys(winds) {
+		imgui.Text(icao + ": wind " + w.METAR[icao].Wind)
+		if imgui.BeginTableV("rwywind-"+icao, 4, tableFlags, imgui.Vec2{}, 0) {
+			imgui.TableSetupColumn("Runway")
+			imgui.TableSetupColumn("Headwind")
+			imgui.TableSetupColumn("Crosswind")
+			imgui.TableSetupColumn("Status")
+			imgui.TableHeadersRow()
+
+			for _, rw := range winds[icao] {
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				imgui.Text(rw.Runway)
+				imgui.TableNextColumn()
+				if rw.Headwind < 0 {
+					imgui.Text(fmt.Sprintf("%d (tail)", int(rw.Tailwind()+0.5)))
+				} else {
+					imgui.Text(fmt.Sprintf("%d", int(rw.Headwind+0.5)))
+				}
+				imgui.TableNextColumn()
+				imgui.Text(fmt.Sprintf("%d", int(rw.Crosswind+0.5)))
+
+				imgui.TableNextColumn()
+				var status []string
+				if _, ok := active[icao][rw.Runway]; ok {
+					status = append(status, "Active")
+				}
+				if rw.Recommended {
+					status = append(status, "Recommended")
+				}
+				if s := rw.ExceedsLimits(); s != "" {
+					status = append(status, s)
+				}
+				imgui.Text(strings.Join(status, ", "))
+			}
+			imgui.EndTable()
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// runwaywind_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestParseMETARWind(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		wind METARWind
+	}{
+		{"27012KT", METARWind{Direction: 270, Speed: 12}},
+		{"09015G25KT", METARWind{Direction: 90, Speed: 15, Gust: 25}},
+		{"VRB03KT", METARWind{Variable: true, Speed: 3}},
+		{"00000KT", METARWind{}},
+	} {
+		if wind, err := ParseMETARWind(test.s); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.s, err)
+		} else if wind != test.wind {
+			t.Errorf("%s: got %+v, expected %+v", test.s, wind, test.wind)
+		}
+	}
+
+	for _, s := range []string{"", "27012", "27KT", "XYZ12KT", "27012GKT"} {
+		if _, err := ParseMETARWind(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+func TestRunwayWinds(t *testing.T) {
+	runways := []Runway{
+		{Id: "4L", Heading: 40},
+		{Id: "4R", Heading: 40},
+		{Id: "22L", Heading: 220},
+		{Id: "13", Heading: 130},
+		{Id: "31", Heading: 310},
+	}
+
+	// Straight down runway 22: the wind direction is true and the runway
+	// headings are magnetic, so with a magnetic variation of 13, a wind
+	// from 207 true is from 220 magnetic.
+	rws := runwayWinds(runways, METARWind{Direction: 207, Speed: 10, Gust: 16}, 13)
+	if rws[0].Runway != "22L" || abs(rws[0].Headwind-16) > 0.01 || rws[0].Crosswind > 0.01 {
+		t.Errorf("expected a 16 kt headwind on 22L, got %+v", rws[0])
+	}
+	if !rws[0].Recommended {
+		t.Errorf("expected 22L to be recommended")
+	}
+
+	byRunway := make(map[string]RunwayWind)
+	for _, rw := range rws {
+		byRunway[rw.Runway] = rw
+	}
+	if rw := byRunway["4L"]; rw.Recommended || abs(rw.Tailwind()-16) > 0.01 || rw.ExceedsLimits() == "" {
+		t.Errorf("expected 4L to have a 16 kt tailwind that exceeds limits, got %+v", rw)
+	}
+	if rw := byRunway["13"]; rw.Recommended || abs(rw.Crosswind-16) > 0.01 || rw.ExceedsLimits() != "" {
+		t.Errorf("expected 13 to have a 16 kt crosswind within limits, got %+v", rw)
+	}
+
+	// With calm winds, all of the runways are fine.
+	for _, rw := range runwayWinds(runways, METARWind{}, 13) {
+		if !rw.Recommended || rw.Headwind != 0 || rw.Crosswind != 0 {
+			t.Errorf("expected %s to be recommended with calm winds: %+v", rw.Runway, rw)
+		}
+	}
+
+	// Variable winds are taken as a worst-case tailwind.
+	for _, rw := range runwayWinds(runways, METARWind{Variable: true, Speed: 6}, 13) {
+		if rw.Recommended || rw.Tailwind() != 6 {
+			t.Errorf("expected %s to have a 6 kt tailwind: %+v", rw.Runway, rw)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+// savedsims.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Saved sims: in addition to the sim that is stored in the config file at
+// exit, local sims may be saved to named slots in the "saves" folder in
+// the config directory; the autosaves (see autosave.go) are kept there as
+// well. Each file holds a JSON-encoded SavedSimInfo followed by the
+// JSON-encoded Sim so that the saves can be listed without decoding all
+// of the sims.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type SavedSimInfo struct {
+	Name     string
+	Time     time.Time // when it was saved
+	SimTime  time.Time
+	Callsign string
+	TRACON   string
+	Scenario string
+	Autosave bool
+
+	filename string
+}
+
+func savedSimsDirectory() string {
+	dir := path.Join(configDirectory(), "saves")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		lg.Errorf("%s: unable to make directory for saved sims: %v", dir, err)
+	}
+	return dir
+}
+
+// savedSimFilename returns the path of the file for the save slot with
+// the given name.
+func savedSimFilename(name string) string {
+	fn := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	return path.Join(savedSimsDirectory(), fn+".json")
+}
+
+func makeSavedSimInfo(name string, callsign string, sim *Sim) SavedSimInfo {
+	info := SavedSimInfo{
+		Name:     name,
+		Time:     time.Now(),
+		Callsign: callsign,
+		Scenario: sim.Scenario,
+	}
+	if sim.World != nil {
+		info.SimTime = sim.World.SimTime
+		info.TRACON = sim.World.TRACON
+	}
+	return info
+}
+
+func writeSavedSim(fn string, info SavedSimInfo, sim *Sim) error {
+	return writeFileAtomically(fn, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(info); err != nil {
+			return err
+		}
+		return enc.Encode(sim)
+	})
+}
+
+func readSavedSimInfo(fn string) (SavedSimInfo, error) {
+	var info SavedSimInfo
+	f, err := os.Open(fn)
+	if err != nil {
+		return info, err
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(&info)
+	info.filename = fn
+	return info, err
+}
+
+func readSavedSim(fn string) (SavedSimInfo, *Sim, error) {
+	var info SavedSimInfo
+	f, err := os.Open(fn)
+	if err != nil {
+		return info, nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(&info); err != nil {
+		return info, nil, err
+	}
+	info.filename = fn
+
+	var sim Sim
+	if err := dec.Decode(&sim); err != nil {
+		return info, nil, err
+	}
+	return info, &sim, nil
+}
+
+// listSavedSims returns information about all of the saved sims, most
+// recent first.
+func listSavedSims() []SavedSimInfo {
+	dir := savedSimsDirectory()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		lg.Errorf("%s: %v", dir, err)
+		return nil
+	}
+
+	var saves []SavedSimInfo
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".json" {
+			continue
+		}
+		fn := path.Join(dir, e.Name())
+		if info, err := readSavedSimInfo(fn); err != nil {
+			lg.Warnf("%s: %v", fn, err)
+		} else {
+			saves = append(saves, info)
+		}
+	}
+
+	sort.Slice(saves, func(i, j int) bool { return saves[i].Time.After(saves[j].Time) })
+	return saves
+}
+
+// loadSavedSim starts the given saved sim on the local server, replacing
+// the current sim, if any.
+func loadSavedSim(info SavedSimInfo) error {
+	if localServer == nil {
+		return fmt.Errorf("the local sim server isn't available")
+	}
+
+	_, sim, err := readSavedSim(info.filename)
+	if err != nil {
+		return err
+	}
+
+	var result NewSimResult
+	if err := localServer.Call("SimManager.Add", sim, &result); err != nil {
+		return err
+	}
+	result.World.simProxy = &SimProxy{
+		ControllerToken: result.ControllerToken,
+		Client:          localServer.RPCClient,
+	}
+	lg.Infof("%s: loaded saved sim", info.filename)
+
+	newWorldChan <- result.World
+	return nil
+}
+
+func isLocalSim(w *World) bool {
+	return w != nil && w.simProxy != nil && localServer != nil && w.simProxy.Client == localServer.RPCClient
+}
+
+///////////////////////////////////////////////////////////////////////////
+// UI
+
+func uiDrawSavedSimsMenu(w *World) {
+	if imgui.BeginMenu(FontAwesomeIconFolder) {
+		if imgui.MenuItemV("Save sim...", "", false, isLocalSim(w)) {
+			uiShowModalDialog(NewModalDialogBox(&SaveSimModalClient{world: w}), false)
+		}
+		if imgui.MenuItemV("Load saved sim...", "", false, localServer != nil) {
+			uiShowModalDialog(NewModalDialogBox(&LoadSimModalClient{}), false)
+		}
+		imgui.Separator()
+		if imgui.MenuItemV("Situations...", "", false, w != nil && w.LaunchConfig.Controller == w.Callsign) {
+			uiShowModalDialog(NewModalDialogBox(&SituationsModalClient{world: w}), false)
+		}
+		imgui.EndMenu()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Saved simulations")
+	}
+}
+
+type SaveSimModalClient struct {
+	world  *World
+	name   string
+	status string
+	saving bool
+	done   chan error
+}
+
+func (s *SaveSimModalClient) Title() string { return "Save Simulation" }
+
+func (s *SaveSimModalClient) Opening() {
+	s.name = s.world.TRACON + " " + s.world.SimTime.UTC().Format("1504")
+}
+
+func (s *SaveSimModalClient) Buttons() []ModalDialogButton {
+	var b []ModalDialogButton
+	b = append(b, ModalDialogButton{text: "Cancel", disabled: s.saving})
+
+	name := strings.TrimSpace(s.name)
+	ok := ModalDialogButton{
+		text:     "Save",
+		disabled: s.saving || name == "",
+		action: func() bool {
+			s.saving = true
+			s.status = "Saving..."
+			s.done = make(chan error, 1)
+
+			// Get the snapshot and write it in the background; the dialog
+			// stays open until it's finished.
+			proxy, callsign := s.world.simProxy, s.world.Callsign
+			go func() {
+				sim, err := proxy.GetSerializeSim()
+				if err == nil {
+					err = writeSavedSim(savedSimFilename(name), makeSavedSimInfo(name, callsign, sim), sim)
+				}
+				s.done <- err
+			}()
+			return false
+		},
+	}
+	return append(b, ok)
+}
+
+func (s *SaveSimModalClient) Draw() int {
+	if s.saving {
+		select {
+		case err := <-s.done:
+			s.saving = false
+			if err != nil {
+				s.status = "Error: " + err.Error()
+			} else {
+				s.status = ""
+				return 0 // close the dialog
+			}
+		default:
+		}
+	}
+
+	uiStartDisable(s.saving)
+	imgui.InputTextV("Name", &s.name, 0, nil)
+	uiEndDisable(s.saving)
+	if name := strings.TrimSpace(s.name); name != "" {
+		if _, err := os.Stat(savedSimFilename(name)); err == nil {
+			imgui.Text("A saved sim with this name will be replaced.")
+		}
+	}
+	if s.status != "" {
+		imgui.Text(s.status)
+	}
+	return -1
+}
+
+type LoadSimModalClient struct {
+	saves    []SavedSimInfo
+	selected int
+	err      error
+}
+
+func (l *LoadSimModalClient) Title() string { return "Load Saved Simulation" }
+
+func (l *LoadSimModalClient) Opening() {
+	l.saves = listSavedSims()
+	l.selected = -1
+	l.err = nil
+}
+
+func (l *LoadSimModalClient) Buttons() []ModalDialogButton {
+	haveSelection := l.selected >= 0 && l.selected < len(l.saves)
+	return []ModalDialogButton{
+		ModalDialogButton{text: "Cancel"},
+		ModalDialogButton{
+			text:     "Delete",
+			disabled: !haveSelection,
+			action: func() bool {
+				if err := os.Remove(l.saves[l.selected].filename); err != nil {
+					l.err = err
+				}
+				l.saves = listSavedSims()
+				l.selected = -1
+				return false
+			},
+		},
+		ModalDialogButton{
+			text:     "Load",
+			disabled: !haveSelection,
+			action: func() bool {
+				if l.err = loadSavedSim(l.saves[l.selected]); l.err != nil {
+					lg.Errorf("%s: %v", l.saves[l.selected].filename, l.err)
+					return false
+				}
+				return true
+			},
+		},
+	}
+}
+
+func (l *LoadSimModalClient) Draw() int {
+	if len(l.saves) == 0 {
+		imgui.Text("No saved sims found.")
+		return -1
+	}
+
+	flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg |
+		imgui.TableFlagsSizingStretchProp | imgui.TableFlagsScrollY
+	tableScale := Select(runtime.GOOS == "windows", platform.DPIScale(), float32(1))
+	if imgui.BeginTableV("saves", 4, flags, imgui.Vec2{tableScale * 600, tableScale * 300}, 0.) {
+		imgui.TableSetupColumn("Name")
+		imgui.TableSetupColumn("Scenario")
+		imgui.TableSetupColumn("Sim time")
+		imgui.TableSetupColumn("Saved")
+		imgui.TableHeadersRow()
+
+		for i, save := range l.saves {
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			name := save.Name
+			if save.Autosave {
+				name = FontAwesomeIconHistory + " " + name
+			}
+			if imgui.SelectableV(name+"##"+save.filename, i == l.selected,
+				imgui.SelectableFlagsSpanAllColumns, imgui.Vec2{}) {
+				l.selected = i
+			}
+			imgui.TableNextColumn()
+			imgui.Text(strings.TrimSpace(save.TRACON + " " + save.Scenario))
+			imgui.TableNextColumn()
+			imgui.Text(save.SimTime.UTC().Format("15:04:05Z"))
+			imgui.TableNextColumn()
+			imgui.Text(save.Time.Local().Format("Jan 2 15:04"))
+		}
+		imgui.EndTable()
+	}
+
+	if l.err != nil {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{1, .5, .5, 1})
+		imgui.Text(fmt.Sprintf("Error: %v", l.err))
+		imgui.PopStyleColor()
+	}
+	return -1
+}
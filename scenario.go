@@ -35,6 +35,8 @@ type ScenarioGroup struct {
 	ControlPositions map[string]*Controller `json:"control_positions"`
 	Airspace         Airspace               `json:"airspace"`
 	ArrivalGroups    map[string][]Arrival   `json:"arrival_groups"`
+	ClassAirspace    []ClassAirspace        `json:"class_airspace"`
+	Sectors          map[string]*Sector     `json:"sectors,omitempty"`
 
 	PrimaryAirport string `json:"primary_airport"`
 
@@ -56,17 +58,107 @@ type AirspaceAwareness struct {
 }
 
 type STARSFacilityAdaptation struct {
-	AirspaceAwareness   []AirspaceAwareness   `json:"airspace_awareness"`
-	ForceQLToSelf       bool                  `json:"force_ql_self"`
-	AllowLongScratchpad [2]bool               `json:"allow_long_scratchpad"` // [0] is for the primary. [1] is for the secondary
-	Maps                []STARSMap            `json:"stars_maps"`
-	InhibitCAVolumes    []AirspaceVolume      `json:"inhibit_ca_volumes"`
-	RadarSites          map[string]*RadarSite `json:"radar_sites"`
-	Center              Point2LL              `json:"-"`
-	CenterString        string                `json:"center"`
-	Range               float32               `json:"range"`
-	Scratchpads         map[string]string     `json:"scratchpads"`
-	VideoMapFile        string                `json:"video_map_file"`
+	AirspaceAwareness   []AirspaceAwareness      `json:"airspace_awareness"`
+	ForceQLToSelf       bool                     `json:"force_ql_self"`
+	AllowLongScratchpad [2]bool                  `json:"allow_long_scratchpad"` // [0] is for the primary. [1] is for the secondary
+	Maps                []STARSMap               `json:"stars_maps"`
+	InhibitCAVolumes    []AirspaceVolume         `json:"inhibit_ca_volumes"`
+	RadarSites          map[string]*RadarSite    `json:"radar_sites"`
+	Center              Point2LL                 `json:"-"`
+	CenterString        string                   `json:"center"`
+	Range               float32                  `json:"range"`
+	Scratchpads         map[string]string        `json:"scratchpads"`
+	ScratchpadRules     ScratchpadRules          `json:"scratchpad_rules"`
+	DepartureGates      map[string]DepartureGate `json:"departure_gates"`
+	SquawkCodes         SquawkCodeAdaptation     `json:"squawk_codes"`
+	VideoMapFile        string                   `json:"video_map_file"`
+	Datablocks          DatablockAdaptation      `json:"datablocks"`
+	// Default positions of system lists in normalized [0,1] scope
+	// coordinates, indexed by list name; see starsListNames.
+	ListPositions map[string][2]float32 `json:"list_positions"`
+}
+
+// DatablockAdaptation allows a facility to customize the contents of full
+// datablocks. Anything left unspecified gives the standard layout.
+type DatablockAdaptation struct {
+	// Names of the fields shown on each of the three lines of a full
+	// datablock; see datablockFieldNames for the available fields.
+	Lines [3][]string `json:"lines"`
+	// When the third line is shown: "always" (the default), "active"
+	// (only when it has something to show), or "alternate" (time-shared
+	// with the second line).
+	Line3 string `json:"line3"`
+	// How the arrival airport is shown when there is no scratchpad:
+	// "short" (the default; e.g., "JFK"), "full" ("KJFK"), or "none".
+	ArrivalAirport string `json:"arrival_airport"`
+	// If set, the secondary scratchpad isn't time-shared with the
+	// altitude; it is only shown if "secondary_scratchpad" is in the
+	// layout.
+	SeparateSecondaryScratchpad bool `json:"separate_secondary_scratchpad"`
+}
+
+// datablockFieldNames gives the fields that may be used in datablock
+// layouts along with their descriptions.
+var datablockFieldNames = map[string]string{
+	"callsign":             "aircraft callsign",
+	"inhibit_indicator":    "MSAW/CA inhibited indicator",
+	"pointout":             "point out and redirected handoff status",
+	"altitude_scratchpad":  "altitude time-shared with scratchpads or arrival airport",
+	"altitude":             "altitude",
+	"scratchpad":           "primary scratchpad",
+	"secondary_scratchpad": "secondary scratchpad",
+	"arrival_airport":      "arrival airport",
+	"handoff":              "handoff sector",
+	"speed_type":           "groundspeed and category time-shared with aircraft type",
+	"speed":                "groundspeed",
+	"type":                 "aircraft type",
+	"category":             "flight rules modifier and wake category",
+	"requested_altitude":   "requested altitude",
+	"beacon":               "beacon code",
+	"atpa":                 "ATPA in-trail distance",
+	"temp_altitude":        "temporary altitude",
+	"mode_s":               "Mode S selected altitude, heading, and ground speed",
+	"_":                    "a single space",
+}
+
+var defaultDatablockLines = [3][]string{
+	{"callsign", "inhibit_indicator", "pointout"},
+	{"altitude_scratchpad", "handoff", "speed_type"},
+	{"atpa", "_", "_", "temp_altitude"},
+}
+
+// FullDatablockLines returns the fields for each line of a full
+// datablock, using the standard layout for lines that weren't specified.
+func (da *DatablockAdaptation) FullDatablockLines() [3][]string {
+	lines := da.Lines
+	for i := range lines {
+		if lines[i] == nil {
+			lines[i] = defaultDatablockLines[i]
+		}
+	}
+	return lines
+}
+
+func (da *DatablockAdaptation) PostDeserialize(e *ErrorLogger) {
+	e.Push("datablocks")
+	defer e.Pop()
+
+	for i := range da.Lines {
+		for _, f := range da.Lines[i] {
+			if _, ok := datablockFieldNames[f]; !ok {
+				e.ErrorString("unknown field \"%s\" in line %d. Options: %s", f, i+1,
+					strings.Join(SortedMapKeys(datablockFieldNames), ", "))
+			}
+		}
+	}
+
+	if da.Line3 != "" && !slices.Contains([]string{"always", "active", "alternate"}, da.Line3) {
+		e.ErrorString("\"line3\" must be \"always\", \"active\", or \"alternate\"")
+	}
+
+	if da.ArrivalAirport != "" && !slices.Contains([]string{"short", "full", "none"}, da.ArrivalAirport) {
+		e.ErrorString("\"arrival_airport\" must be \"short\", \"full\", or \"none\"")
+	}
 }
 
 type Airspace struct {
@@ -85,8 +177,12 @@ type Scenario struct {
 	SoloController      string                `json:"solo_controller"`
 	SplitConfigurations SplitConfigurationSet `json:"multi_controllers"`
 	DefaultSplit        string                `json:"default_split"`
+	StartTime           string                `json:"start_time"` // zulu HHMM; see simclock.go
 	Wind                Wind                  `json:"wind"`
-	VirtualControllers  []string              `json:"controllers"`
+	// Feet above the airport and statute miles; see weather.go.
+	Ceiling            int      `json:"ceiling"`
+	Visibility         float32  `json:"visibility"`
+	VirtualControllers []string `json:"controllers"`
 
 	// Map from arrival group name to map from airport name to default rate...
 	ArrivalGroupDefaultRates map[string]map[string]int `json:"arrivals"`
@@ -103,6 +199,13 @@ type Scenario struct {
 	CenterString string   `json:"center"`
 	Range        float32  `json:"range"`
 	DefaultMaps  []string `json:"default_maps"`
+
+	Triggers   []ScenarioTrigger   `json:"triggers,omitempty"`
+	Objectives []ScenarioObjective `json:"objectives,omitempty"`
+	Tutorial   []TutorialStep      `json:"tutorial,omitempty"`
+	Schedule   *TrafficSchedule    `json:"schedule,omitempty"`
+
+	DependentOperations []DependentOperation `json:"dependent_operations,omitempty"`
 }
 
 // split -> config
@@ -133,6 +236,50 @@ type ScenarioGroupArrivalRunway struct {
 }
 
 func (s *Scenario) PostDeserialize(sg *ScenarioGroup, e *ErrorLogger) {
+	if s.Ceiling < 0 {
+		e.ErrorString("\"ceiling\" cannot be negative")
+	}
+	if s.Visibility < 0 {
+		e.ErrorString("\"visibility\" cannot be negative")
+	}
+	if s.StartTime != "" {
+		if _, _, err := parseZuluTime(s.StartTime); err != nil {
+			e.ErrorString("\"start_time\": %v", err)
+		}
+	}
+
+	for i := range s.Triggers {
+		if err := s.Triggers[i].Compile(); err != nil {
+			e.ErrorString("trigger: %v", err)
+			continue
+		}
+		for _, a := range s.Triggers[i].actions {
+			if a.verb == "radar_fail" || a.verb == "radar_restore" {
+				if _, ok := sg.STARSFacilityAdaptation.RadarSites[a.args[0]]; !ok {
+					e.ErrorString("trigger: %s: radar site \"%s\" not found", a.verb, a.args[0])
+				}
+			}
+		}
+	}
+	for i := range s.Objectives {
+		if err := s.Objectives[i].Compile(); err != nil {
+			e.ErrorString("objective: %v", err)
+		}
+	}
+	for i := range s.Tutorial {
+		if err := s.Tutorial[i].Compile(); err != nil {
+			e.ErrorString("tutorial step %d: %v", i+1, err)
+		}
+	}
+	if s.Schedule != nil {
+		s.Schedule.PostDeserialize(e)
+	}
+	for i := range s.DependentOperations {
+		e.Push(fmt.Sprintf("dependent operation %d", i+1))
+		s.DependentOperations[i].PostDeserialize(sg, e)
+		e.Pop()
+	}
+
 	for _, as := range s.ApproachAirspaceNames {
 		if vol, ok := sg.Airspace.Volumes[as]; !ok {
 			e.ErrorString("unknown approach airspace \"%s\"", as)
@@ -590,6 +737,10 @@ func (sg *ScenarioGroup) PostDeserialize(e *ErrorLogger, simConfigurations map[s
 	sg.NmPerLatitude = 60
 	sg.NmPerLongitude = 60 * cos(radians(sg.STARSFacilityAdaptation.Center[1]))
 
+	for i := range sg.ClassAirspace {
+		sg.ClassAirspace[i].PostDeserialize(e)
+	}
+
 	if sg.TRACON == "" {
 		e.ErrorString("\"tracon\" must be specified")
 	} else if _, ok := database.TRACONs[sg.TRACON]; !ok {
@@ -657,6 +808,12 @@ func (sg *ScenarioGroup) PostDeserialize(e *ErrorLogger, simConfigurations map[s
 		}
 	}
 
+	for _, name := range SortedMapKeys(sg.Sectors) {
+		e.Push("Sector " + name)
+		sg.Sectors[name].PostDeserialize(sg, e)
+		e.Pop()
+	}
+
 	if len(sg.Airports) == 0 {
 		e.ErrorString("No \"airports\" specified in scenario group")
 	}
@@ -670,7 +827,7 @@ func (sg *ScenarioGroup) PostDeserialize(e *ErrorLogger, simConfigurations map[s
 		e.ErrorString("\"primary_airport\" not specified")
 	} else if ap, ok := database.Airports[sg.PrimaryAirport]; !ok {
 		e.ErrorString("\"primary_airport\" \"%s\" unknown", sg.PrimaryAirport)
-	} else if mvar, err := database.MagneticGrid.Lookup(ap.Location); err != nil {
+	} else if mvar, err := database.MagneticVariation(ap.Location, time.Now()); err != nil {
 		e.ErrorString("%s: unable to find magnetic declination: %v", sg.PrimaryAirport, err)
 	} else {
 		sg.MagneticVariation = mvar + sg.MagneticAdjustment
@@ -795,6 +952,20 @@ func (s *STARSFacilityAdaptation) PostDeserialize(e *ErrorLogger, sg *ScenarioGr
 		e.Pop()
 	}
 
+	s.Datablocks.PostDeserialize(e)
+	s.ScratchpadRules.PostDeserialize(e, s, sg)
+	s.checkDepartureGates(e, sg)
+	s.SquawkCodes.PostDeserialize(e, sg)
+
+	for name, p := range s.ListPositions {
+		if !slices.Contains(starsListNames, name) {
+			e.ErrorString("unknown list \"%s\" in \"list_positions\". Options: %s", name,
+				strings.Join(starsListNames, ", "))
+		} else if p[0] < 0 || p[0] > 1 || p[1] < 0 || p[1] > 1 {
+			e.ErrorString("position %v for list \"%s\" must be between 0 and 1", p, name)
+		}
+	}
+
 	e.Pop() // stars_config
 }
 
@@ -810,7 +981,7 @@ func initializeSimConfigurations(sg *ScenarioGroup,
 		sc := &SimScenarioConfiguration{
 			SplitConfigurations: scenario.SplitConfigurations,
 			LaunchConfig: MakeLaunchConfig(scenario.DepartureRunways,
-				scenario.ArrivalGroupDefaultRates),
+				scenario.ArrivalGroupDefaultRates, scenario.Schedule),
 			Wind:             scenario.Wind,
 			DepartureRunways: scenario.DepartureRunways,
 			ArrivalRunways:   scenario.ArrivalRunways,
@@ -1064,15 +1235,18 @@ type LoadedVideoMap struct {
 	err         error
 }
 
-func loadVideoMaps(filesystem fs.FS, path string, referencedVideoMaps map[string]map[string]interface{}, result chan LoadedVideoMap) {
+// loadVideoMaps loads the video map file with the given path. Command
+// buffers are only generated for the maps named in referenced; the
+// others are included with empty command buffers so that it's known which
+// maps the file has.
+func loadVideoMaps(filesystem fs.FS, path string, referenced map[string]interface{}) LoadedVideoMap {
 	start := time.Now()
 	lvm := LoadedVideoMap{path: path}
 
 	fr, err := filesystem.Open(path)
 	if err != nil {
 		lvm.err = err
-		result <- lvm
-		return
+		return lvm
 	}
 	defer fr.Close()
 
@@ -1084,17 +1258,14 @@ func loadVideoMaps(filesystem fs.FS, path string, referencedVideoMaps map[string
 		r = zr
 	}
 
-	if referenced, ok := referencedVideoMaps[path]; ok {
-		lvm.commandBufs, err = loadVideoMapFile(r, referenced)
-		if err != nil {
-			lvm.err = err
-			result <- lvm
-			return
-		}
+	lvm.commandBufs, err = loadVideoMapFile(r, referenced)
+	if err != nil {
+		lvm.err = err
+		return lvm
 	}
 	lg.Infof("%s: video map loaded in %s\n", path, time.Since(start))
 
-	result <- lvm
+	return lvm
 }
 
 func loadVideoMapFile(ir io.Reader, referenced map[string]interface{}) (map[string]CommandBuffer, error) {
@@ -1356,12 +1527,14 @@ func (r RootFS) Open(filename string) (fs.File, error) {
 }
 
 // LoadScenarioGroups loads all of the available scenarios, both from the
-// scenarios/ directory in the source code distribution as well as,
-// optionally, a scenario file provided on the command line.  It doesn't
-// try to do any sort of meaningful error handling but it does try to
-// continue on in the presence of errors; all errors will be printed and
-// the program will exit if there are any.  We'd rather force any errors
-// due to invalid scenario definitions to be fixed...
+// scenarios/ directory in the source code distribution and any installed
+// scenario packs as well as, optionally, a scenario file provided on the
+// command line.  It doesn't try to do any sort of meaningful error
+// handling but it does try to continue on in the presence of errors; all
+// errors will be printed and the program will exit if there are any.
+// We'd rather force any errors due to invalid scenario definitions to be
+// fixed... (Errors in scenario packs are the exception; those packs are
+// skipped.)
 func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, map[string]map[string]*SimConfiguration) {
 	start := time.Now()
 
@@ -1369,6 +1542,7 @@ func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, m
 	scenarioGroups := make(map[string]map[string]*ScenarioGroup)
 	simConfigurations := make(map[string]map[string]*SimConfiguration)
 	referencedVideoMaps := make(map[string]map[string]interface{}) // filename -> map name -> used
+	var scenarioPaths []string
 	err := fs.WalkDir(resourcesFS, "scenarios", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			lg.Errorf("error walking scenarios/: %v", err)
@@ -1379,12 +1553,21 @@ func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, m
 			return nil
 		}
 
-		if filepath.Ext(path) != ".json" {
-			return nil
+		if filepath.Ext(path) == ".json" {
+			scenarioPaths = append(scenarioPaths, path)
 		}
+		return nil
+	})
+	if err != nil {
+		e.Error(err)
+	}
 
+	startupProgress.StartStage("Loading scenarios", len(scenarioPaths))
+	for _, path := range scenarioPaths {
 		lg.Infof("%s: loading scenario", path)
+		startupProgress.Loading(path)
 		s := loadScenarioGroup(resourcesFS, path, e)
+		startupProgress.Finished()
 		if s != nil {
 			if _, ok := scenarioGroups[s.TRACON][s.Name]; ok {
 				e.ErrorString("%s / %s: scenario redefined", s.TRACON, s.Name)
@@ -1402,10 +1585,6 @@ func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, m
 				referencedVideoMaps[s.STARSFacilityAdaptation.VideoMapFile][m.Name] = nil
 			}
 		}
-		return nil
-	})
-	if err != nil {
-		e.Error(err)
 	}
 	if e.HaveErrors() {
 		// Don't keep going since we'll likely crash in the following
@@ -1449,10 +1628,10 @@ func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, m
 		}
 	}
 
-	// Next load the video maps.
-	videoMapCommandBuffers := make(map[string]map[string]CommandBuffer)
-	vmChan := make(chan LoadedVideoMap, 16)
-	launches := 0
+	// Next scan the video map files to find which maps each one has; the
+	// maps themselves are loaded on demand (see videomapcache.go).
+	availableVideoMaps := make(map[string]map[string]CommandBuffer)
+	var videoMapPaths []string
 	err = fs.WalkDir(resourcesFS, "videomaps", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			lg.Errorf("error walking videomaps: %v", err)
@@ -1467,8 +1646,7 @@ func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, m
 			return nil
 		}
 
-		launches++
-		go loadVideoMaps(resourcesFS, path, referencedVideoMaps, vmChan)
+		videoMapPaths = append(videoMapPaths, path)
 		return nil
 	})
 	if err != nil {
@@ -1476,21 +1654,30 @@ func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, m
 		os.Exit(1)
 	}
 
-	receiveLoadedVideoMap := func() {
-		lvm := <-vmChan
+	addLoadedVideoMap := func(lvm LoadedVideoMap) {
 		if lvm.err != nil {
 			e.Push("File " + lvm.path)
 			e.Error(lvm.err)
 			e.Pop()
 		} else {
-			videoMapCommandBuffers[lvm.path] = lvm.commandBufs
+			availableVideoMaps[lvm.path] = lvm.commandBufs
 		}
 	}
 
-	// Get all of the loaded video map command buffers
-	for launches > 0 {
-		receiveLoadedVideoMap()
-		launches--
+	startupProgress.StartStage("Scanning video maps", len(videoMapPaths))
+	loaded := ParallelMap(videoMapPaths, func(path string) LoadedVideoMap {
+		startupProgress.Loading(path)
+		defer startupProgress.Finished()
+
+		videoMaps.Register(path, resourcesFS)
+		if _, ok := referencedVideoMaps[path]; !ok {
+			// No need to look at it if no scenario uses it.
+			return LoadedVideoMap{path: path}
+		}
+		return loadVideoMaps(resourcesFS, path, nil)
+	})
+	for _, lvm := range loaded {
+		addLoadedVideoMap(lvm)
 	}
 
 	lg.Infof("video map load time: %s\n", time.Since(start))
@@ -1504,11 +1691,12 @@ func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, m
 				return os.DirFS(".")
 			}
 		}()
-		loadVideoMaps(fs, *videoMapFilename, referencedVideoMaps, vmChan)
-		receiveLoadedVideoMap()
+		videoMaps.Register(*videoMapFilename, fs)
+		addLoadedVideoMap(loadVideoMaps(fs, *videoMapFilename, nil))
 	}
 
 	// Final tidying before we return the loaded scenarios.
+	startupProgress.StartStage("Initializing scenarios", 0)
 	for tname, tracon := range scenarioGroups {
 		e.Push("TRACON " + tname)
 
@@ -1527,19 +1715,17 @@ func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, m
 				scenarioNames[scenarioName] = groupName
 			}
 
-			// Initialize the CommandBuffers in the scenario's maps.
+			// Make sure that the scenario's maps are available.
 			if vf := sgroup.STARSFacilityAdaptation.VideoMapFile; vf == "" {
 				e.ErrorString("no \"video_map_file\" specified")
 			} else {
-				if bufferMap, ok := videoMapCommandBuffers[vf]; !ok {
+				if available, ok := availableVideoMaps[vf]; !ok {
 					e.ErrorString("video map file \"%s\" unknown", vf)
 				} else {
-					for i, sm := range sgroup.STARSFacilityAdaptation.Maps {
-						if cb, ok := bufferMap[sm.Name]; !ok {
+					for _, sm := range sgroup.STARSFacilityAdaptation.Maps {
+						if _, ok := available[sm.Name]; !ok {
 							e.ErrorString("video map \"%s\" not found. Available maps: %s",
-								sm.Name, `"`+strings.Join(SortedMapKeys(bufferMap), `", "`)+`"`)
-						} else {
-							sgroup.STARSFacilityAdaptation.Maps[i].CommandBuffer = cb
+								sm.Name, `"`+strings.Join(SortedMapKeys(available), `", "`)+`"`)
 						}
 					}
 				}
@@ -1552,6 +1738,11 @@ func LoadScenarioGroups(e *ErrorLogger) (map[string]map[string]*ScenarioGroup, m
 		e.Pop()
 	}
 
+	// Finally, add the installed scenario packs (see scenariopacks.go).
+	if !e.HaveErrors() {
+		loadScenarioPacks(scenarioGroups, simConfigurations, availableVideoMaps)
+	}
+
 	// Walk all of the scenario groups to get all of the possible departing aircraft
 	// types to see where V2 is needed in the performance database..
 	acTypes := make(map[string]struct{})
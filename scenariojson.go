@@ -0,0 +1,289 @@
+// scenariojson.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Serialization of scenario groups back to JSON in the format of the
+// scenario definition files, e.g. for programmatic transformations of
+// scenarios or to normalize hand-written files with -lint -fix. The
+// output is normalized: only fields that have JSON names are written,
+// in the order they are declared, fields with zero values are omitted,
+// map entries are sorted (fixes keep the order they were given in), and
+// waypoints use the compact string encoding.
+// Reading the output back gives the same ScenarioGroup and
+// re-serializing it gives the same JSON.
+//
+// Scenario groups should be serialized as loaded, before PostDeserialize
+// initializes the derived fields and fills in defaults.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MarshalScenarioGroup returns the normalized JSON for the scenario group.
+func MarshalScenarioGroup(sg *ScenarioGroup) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marshalScenarioJSON(&buf, reflect.ValueOf(sg), ""); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// Duplicate returns a deep copy of the scenario group, made by
+// round-tripping it through JSON. As with MarshalScenarioGroup, it
+// should be called before PostDeserialize.
+func (sg *ScenarioGroup) Duplicate() (*ScenarioGroup, error) {
+	b, err := MarshalScenarioGroup(sg)
+	if err != nil {
+		return nil, err
+	}
+	var dup ScenarioGroup
+	if err := UnmarshalJSON(b, &dup); err != nil {
+		return nil, err
+	}
+	return &dup, nil
+}
+
+// normalizeScenarioFile rewrites the given scenario file in normalized
+// form.
+func normalizeScenarioFile(filename string) error {
+	var e ErrorLogger
+	sg := loadScenarioGroup(RootFS{}, filename, &e)
+	if e.HaveErrors() {
+		return fmt.Errorf("%s: %s", filename, e.Errors()[0].String())
+	}
+
+	b, err := MarshalScenarioGroup(sg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if orig, err := os.ReadFile(filename); err == nil && bytes.Equal(orig, b) {
+		return nil
+	}
+	return writeFileAtomically(filename, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+var (
+	jsonMarshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	waypointArrayType  = reflect.TypeOf(WaypointArray{})
+	scenarioJSONIndent = "  "
+)
+
+// jsonFieldName returns the JSON name of the given struct field and
+// whether it is serialized.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok || !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+func marshalScenarioJSON(buf *bytes.Buffer, v reflect.Value, indent string) error {
+	// Make sure that methods with pointer receivers are found.
+	if !v.CanAddr() {
+		av := reflect.New(v.Type()).Elem()
+		av.Set(v)
+		v = av
+	}
+
+	if v.Type() == waypointArrayType {
+		s, _ := json.Marshal(v.Interface().(WaypointArray).Encode())
+		buf.Write(s)
+		return nil
+	}
+	if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface {
+		var m json.Marshaler
+		if v.Type().Implements(jsonMarshalerType) {
+			m = v.Interface().(json.Marshaler)
+		} else if v.Addr().Type().Implements(jsonMarshalerType) {
+			m = v.Addr().Interface().(json.Marshaler)
+		}
+		if m != nil {
+			b, err := m.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			return json.Indent(buf, b, indent, scenarioJSONIndent)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return marshalScenarioJSON(buf, v.Elem(), indent)
+
+	case reflect.Struct:
+		type field struct {
+			name  string
+			value reflect.Value
+		}
+		var fields []field
+		haveNames := false
+		for _, sf := range reflect.VisibleFields(v.Type()) {
+			name, ok := jsonFieldName(sf)
+			if !ok {
+				continue
+			}
+			haveNames = true
+			fv, err := v.FieldByIndexErr(sf.Index)
+			if err != nil || fv.IsZero() {
+				// Skip zero values and fields of nil embedded structs.
+				continue
+			}
+			fields = append(fields, field{name: name, value: fv})
+		}
+		if !haveNames {
+			// Structs without JSON names are written using the standard
+			// encoding, which is what they are read with.
+			b, err := json.Marshal(v.Interface())
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+			return nil
+		}
+
+		if len(fields) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{")
+		for i, f := range fields {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + indent + scenarioJSONIndent)
+			k, _ := json.Marshal(f.name)
+			buf.Write(k)
+			buf.WriteString(": ")
+			if err := marshalScenarioJSON(buf, f.value, indent+scenarioJSONIndent); err != nil {
+				return fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+		buf.WriteString("\n" + indent + "}")
+		return nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value)
+		for iter := v.MapRange(); iter.Next(); {
+			var k string
+			switch key := iter.Key(); key.Kind() {
+			case reflect.String:
+				k = key.String()
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				k = strconv.FormatInt(key.Int(), 10)
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				k = strconv.FormatUint(key.Uint(), 10)
+			default:
+				return fmt.Errorf("%s: unsupported map key type", key.Type())
+			}
+			keys = append(keys, k)
+			values[k] = iter.Value()
+		}
+		sort.Strings(keys)
+
+		if len(keys) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{")
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + indent + scenarioJSONIndent)
+			kb, _ := json.Marshal(k)
+			buf.Write(kb)
+			buf.WriteString(": ")
+			if err := marshalScenarioJSON(buf, values[k], indent+scenarioJSONIndent); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+		}
+		buf.WriteString("\n" + indent + "}")
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Len() == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string.
+			b, err := json.Marshal(v.Interface())
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+			return nil
+		}
+		// Arrays of simple values are written on a single line.
+		if k := v.Type().Elem().Kind(); k != reflect.Struct && k != reflect.Map && k != reflect.Slice &&
+			k != reflect.Array && k != reflect.Pointer && k != reflect.Interface {
+			buf.WriteString("[")
+			for i := 0; i < v.Len(); i++ {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				if err := marshalScenarioJSON(buf, v.Index(i), indent); err != nil {
+					return err
+				}
+			}
+			buf.WriteString("]")
+			return nil
+		}
+		buf.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + indent + scenarioJSONIndent)
+			if err := marshalScenarioJSON(buf, v.Index(i), indent+scenarioJSONIndent); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		buf.WriteString("\n" + indent + "]")
+		return nil
+
+	default:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+// scenariojson_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// loadTestDatabase initializes the static database from the resources
+// directory; it's needed by tests that load the scenarios that ship with
+// vice, since they refer to fixes, navaids, and airports by name.
+var loadTestDatabase = sync.OnceFunc(func() {
+	if resourcesFS == nil {
+		resourcesFS = os.DirFS("resources").(fs.StatFS)
+	}
+	database = InitializeStaticDatabase()
+})
+
+func TestMarshalScenarioGroup(t *testing.T) {
+	fsys := fstest.MapFS{
+		"test.json": &fstest.MapFile{Data: []byte(`{
+    "tracon": "PHL",
+    "name": "Test",
+    "fixes": { "BBBBB": "N040.00.00.000,W075.00.00.000", "AAAAA": "N039.51.39.243,W075.16.29.511" },
+    "default_scenario": "Test",
+    "stars_config": { "center": "N040.00.00.000,W075.00.00.000", "scratchpads": { "ZZZ": "Z", "AAA": "A" } }
+}`)},
+	}
+
+	var e ErrorLogger
+	sg := loadScenarioGroup(fsys, "test.json", &e)
+	if e.HaveErrors() {
+		t.Fatalf("unexpected errors: %s", e.String())
+	}
+
+	b, err := MarshalScenarioGroup(sg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := string(b)
+	// Fields are written in declaration order and map keys are sorted,
+	// but fixes stay in the order they were given.
+	if strings.Index(s, `"tracon"`) > strings.Index(s, `"name"`) {
+		t.Errorf("expected \"tracon\" before \"name\": %s", s)
+	}
+	if strings.Index(s, `"AAA"`) > strings.Index(s, `"ZZZ"`) {
+		t.Errorf("expected sorted scratchpads: %s", s)
+	}
+	if strings.Index(s, `"BBBBB"`) > strings.Index(s, `"AAAAA"`) {
+		t.Errorf("expected fixes in their original order: %s", s)
+	}
+	if !strings.Contains(s, "N039.51.39.243,W075.16.29.511") {
+		t.Errorf("expected fix location to be preserved: %s", s)
+	}
+
+	dup, err := sg.Duplicate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(sg, dup) {
+		t.Errorf("duplicate differs: %+v vs %+v", sg, dup)
+	}
+}
+
+func TestMarshalScenarioGroupRoundTrip(t *testing.T) {
+	loadTestDatabase()
+
+	resources := os.DirFS("resources")
+	paths, err := fs.Glob(resources, "scenarios/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		var e ErrorLogger
+		sg := loadScenarioGroup(resources, path, &e)
+		if e.HaveErrors() {
+			t.Errorf("%s: %s", path, e.String())
+			continue
+		}
+
+		b, err := MarshalScenarioGroup(sg)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		CheckJSONVsSchema[ScenarioGroup](b, &e)
+		if e.HaveErrors() {
+			t.Errorf("%s: normalized JSON doesn't match schema: %s", path, e.String())
+			continue
+		}
+
+		// Reading the normalized JSON and writing it again should give
+		// the same scenario group and the same JSON.
+		var sg2 ScenarioGroup
+		if err := UnmarshalJSON(b, &sg2); err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		b2, err := MarshalScenarioGroup(&sg2)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if !bytes.Equal(b, b2) {
+			t.Errorf("%s: serialization isn't stable", path)
+		}
+	}
+}
@@ -0,0 +1,810 @@
+// scenariopacks.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Scenario packs make it possible to distribute additional scenarios and
+// video maps without users having to copy files around by hand. A pack
+// is a zip file with a manifest.json file at its root, e.g.,
+//
+//	{ "name": "n90-extra", "version": "1.2", "description": "More N90 scenarios" }
+//
+// along with scenario files under scenarios/ and video map files under
+// videomaps/. Installed packs are extracted into the "packs" directory in
+// the config directory and are loaded along with the built-in scenarios.
+// A scenario in a pack may use a video map file from the same pack, given
+// by its path in the zip file (e.g., "videomaps/N90-extra.gob.zst"), or
+// one of the built-in ones. Packs that have errors are skipped rather
+// than preventing vice from starting.
+//
+// Packs can be downloaded and installed from the "Scenario Packs" dialog,
+// which is available from the connect dialog. The available packs are
+// given by an index file at a user-specified URL of the form:
+//
+//	{ "packs": [ { "name": "n90-extra", "version": "1.2", "description": "...",
+//	               "url": "n90-extra-1.2.zip", "sha256": "..." } ] }
+//
+// Relative URLs are relative to the index file's URL. The SHA-256
+// checksum of the zip file is optional but recommended. Packs may also be
+// signed with an Ed25519 key by adding "public_key" and "signature"
+// entries that give the base64-encoded public key and signature of the
+// zip file; for example, with OpenSSL:
+//
+//	openssl genpkey -algorithm ed25519 -out key.pem
+//	openssl pkey -in key.pem -pubout -outform DER | tail -c 32 | base64
+//	openssl pkeyutl -sign -inkey key.pem -rawin -in n90-extra-1.2.zip | base64
+//
+// The first time a signed pack is installed, the user is asked whether to
+// trust its key; after that, updates must be signed with the same key or
+// the user is warned. Users are also warned before installing packs that
+// aren't signed or don't have a checksum. After a pack is installed,
+// updated, or removed, the local server reloads its scenarios so that the
+// change is visible in the connect dialog right away.
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type ScenarioPackManifest struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+}
+
+type ScenarioPackIndex struct {
+	Packs []ScenarioPackIndexEntry `json:"packs"`
+}
+
+type ScenarioPackIndexEntry struct {
+	ScenarioPackManifest
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256,omitempty"`
+	PublicKey string `json:"public_key,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+var (
+	ErrInvalidScenarioPackName = errors.New("scenario pack names may only use letters, digits, '.', '-', and '_'")
+	ErrScenarioPackChecksum    = errors.New("scenario pack checksum mismatch")
+	ErrScenarioPackSignature   = errors.New("scenario pack signature is invalid")
+)
+
+// Errors from loading installed packs, indexed by pack name. Packs are
+// loaded by the sim server, so these are protected by a mutex so that
+// the UI can show them.
+var scenarioPackErrors struct {
+	mu   sync.Mutex
+	errs map[string]string
+}
+
+func scenarioPacksDirectory() string {
+	return path.Join(configDirectory(), "packs")
+}
+
+func validScenarioPackName(name string) bool {
+	if name == "" || len(name) > 64 || name[0] == '.' {
+		return false
+	}
+	for _, ch := range name {
+		if !(ch >= 'a' && ch <= 'z') && !(ch >= 'A' && ch <= 'Z') && !(ch >= '0' && ch <= '9') &&
+			ch != '.' && ch != '-' && ch != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m ScenarioPackManifest) check() error {
+	if !validScenarioPackName(m.Name) {
+		return fmt.Errorf("\"%s\": %w", m.Name, ErrInvalidScenarioPackName)
+	}
+	if m.Version == "" {
+		return fmt.Errorf("%s: scenario pack is missing \"version\"", m.Name)
+	}
+	return nil
+}
+
+// readScenarioPackManifest reads and validates the manifest.json file in
+// the given directory.
+func readScenarioPackManifest(fsys fs.FS, dir string) (ScenarioPackManifest, error) {
+	var m ScenarioPackManifest
+	b, err := fs.ReadFile(fsys, path.Join(dir, "manifest.json"))
+	if err != nil {
+		return m, err
+	}
+	if err := UnmarshalJSON(b, &m); err != nil {
+		return m, err
+	}
+	return m, m.check()
+}
+
+// InstalledScenarioPacks returns the manifests of the installed scenario
+// packs, indexed by name.
+func InstalledScenarioPacks() map[string]ScenarioPackManifest {
+	packs := make(map[string]ScenarioPackManifest)
+	entries, err := os.ReadDir(scenarioPacksDirectory())
+	if err != nil {
+		return packs
+	}
+
+	fsys := os.DirFS(scenarioPacksDirectory())
+	for _, entry := range entries {
+		if !entry.IsDir() || !validScenarioPackName(entry.Name()) {
+			continue
+		}
+		if m, err := readScenarioPackManifest(fsys, entry.Name()); err != nil {
+			lg.Warnf("%s: %v", entry.Name(), err)
+		} else {
+			packs[entry.Name()] = m
+		}
+	}
+	return packs
+}
+
+// loadScenarioPacks loads the scenarios and video maps from the installed
+// scenario packs, adding them to the given maps. It's called by
+// LoadScenarioGroups after the built-in scenarios have been loaded and
+// validated. Packs with errors are skipped.
+func loadScenarioPacks(scenarioGroups map[string]map[string]*ScenarioGroup,
+	simConfigurations map[string]map[string]*SimConfiguration, availableVideoMaps map[string]map[string]CommandBuffer) {
+	packErrors := make(map[string]string)
+	defer func() {
+		scenarioPackErrors.mu.Lock()
+		scenarioPackErrors.errs = packErrors
+		scenarioPackErrors.mu.Unlock()
+	}()
+
+	dir := scenarioPacksDirectory()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			lg.Warnf("%s: %v", dir, err)
+		}
+		return
+	}
+
+	fsys := os.DirFS(dir)
+	for _, entry := range entries {
+		if !entry.IsDir() || !validScenarioPackName(entry.Name()) {
+			continue
+		}
+
+		var e ErrorLogger
+		e.Push("Scenario pack " + entry.Name())
+		loadScenarioPack(fsys, entry.Name(), scenarioGroups, simConfigurations, availableVideoMaps, &e)
+		e.Pop()
+		if e.HaveErrors() {
+			e.PrintErrors(lg)
+			packErrors[entry.Name()] = e.String()
+		}
+	}
+}
+
+func loadScenarioPack(fsys fs.FS, name string, scenarioGroups map[string]map[string]*ScenarioGroup,
+	simConfigurations map[string]map[string]*SimConfiguration, availableVideoMaps map[string]map[string]CommandBuffer,
+	e *ErrorLogger) {
+	if _, err := readScenarioPackManifest(fsys, name); err != nil {
+		e.Error(err)
+		return
+	}
+
+	var groups []*ScenarioGroup
+	packVideoMaps := make(map[string]map[string]CommandBuffer)
+	err := fs.WalkDir(fsys, name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel := strings.TrimPrefix(p, name+"/")
+		if strings.HasPrefix(rel, "scenarios/") && path.Ext(p) == ".json" {
+			if sg := loadScenarioGroup(fsys, p, e); sg != nil {
+				groups = append(groups, sg)
+			}
+		} else if strings.HasPrefix(rel, "videomaps/") && (path.Ext(p) == ".json" || path.Ext(p) == ".zst") {
+			if lvm := loadVideoMaps(fsys, p, nil); lvm.err != nil {
+				e.Push("File " + p)
+				e.Error(lvm.err)
+				e.Pop()
+			} else {
+				packVideoMaps[p] = lvm.commandBufs
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		e.Error(err)
+	}
+	if e.HaveErrors() {
+		return
+	}
+
+	// Returns the name of the scenario group that already defines the
+	// given scenario, if any.
+	definedIn := func(tracon, scenario string, groups ...map[string]map[string]*ScenarioGroup) string {
+		for _, g := range groups {
+			for groupName, sg := range g[tracon] {
+				if _, ok := sg.Scenarios[scenario]; ok {
+					return groupName
+				}
+			}
+		}
+		return ""
+	}
+
+	packGroups := make(map[string]map[string]*ScenarioGroup)
+	packConfigs := make(map[string]map[string]*SimConfiguration)
+	for _, sg := range groups {
+		e.Push("Scenario group " + sg.Name)
+
+		if _, ok := scenarioGroups[sg.TRACON][sg.Name]; ok {
+			e.ErrorString("%s / %s: scenario group is already defined", sg.TRACON, sg.Name)
+		} else if _, ok := packGroups[sg.TRACON][sg.Name]; ok {
+			e.ErrorString("%s / %s: scenario redefined", sg.TRACON, sg.Name)
+		}
+		for _, scenarioName := range SortedMapKeys(sg.Scenarios) {
+			if other := definedIn(sg.TRACON, scenarioName, scenarioGroups, packGroups); other != "" {
+				e.ErrorString("scenario \"%s\" is also defined in the \"%s\" scenario group",
+					scenarioName, other)
+			}
+		}
+
+		// Video map files in the pack are registered using their path in
+		// the packs directory so that they don't collide with the
+		// built-in ones.
+		vf := &sg.STARSFacilityAdaptation.VideoMapFile
+		if *vf == "" {
+			e.ErrorString("no \"video_map_file\" specified")
+		} else {
+			if _, ok := packVideoMaps[path.Join(name, *vf)]; ok {
+				*vf = path.Join(name, *vf)
+			}
+
+			available, ok := packVideoMaps[*vf]
+			if !ok {
+				if available, ok = availableVideoMaps[*vf]; ok && available == nil {
+					// Built-in video map files that aren't used by any of
+					// the built-in scenarios haven't been scanned yet.
+					if lvm := loadVideoMaps(resourcesFS, *vf, nil); lvm.err == nil {
+						available = lvm.commandBufs
+						availableVideoMaps[*vf] = available
+					}
+				}
+			}
+
+			if !ok {
+				e.ErrorString("video map file \"%s\" unknown", *vf)
+			} else {
+				for _, sm := range sg.STARSFacilityAdaptation.Maps {
+					if _, ok := available[sm.Name]; !ok {
+						e.ErrorString("video map \"%s\" not found. Available maps: %s",
+							sm.Name, `"`+strings.Join(SortedMapKeys(available), `", "`)+`"`)
+					}
+				}
+			}
+		}
+
+		if !e.HaveErrors() {
+			sg.PostDeserialize(e, packConfigs)
+		}
+
+		if packGroups[sg.TRACON] == nil {
+			packGroups[sg.TRACON] = make(map[string]*ScenarioGroup)
+		}
+		packGroups[sg.TRACON][sg.Name] = sg
+
+		e.Pop()
+	}
+	if e.HaveErrors() {
+		return
+	}
+
+	for p, maps := range packVideoMaps {
+		videoMaps.Register(p, fsys)
+		availableVideoMaps[p] = maps
+	}
+	for tracon, tgroups := range packGroups {
+		if scenarioGroups[tracon] == nil {
+			scenarioGroups[tracon] = make(map[string]*ScenarioGroup)
+		}
+		for groupName, sg := range tgroups {
+			scenarioGroups[tracon][groupName] = sg
+		}
+	}
+	for tracon, configs := range packConfigs {
+		if simConfigurations[tracon] == nil {
+			simConfigurations[tracon] = make(map[string]*SimConfiguration)
+		}
+		for groupName, c := range configs {
+			simConfigurations[tracon][groupName] = c
+		}
+	}
+	lg.Infof("%s: loaded scenario pack", name)
+}
+
+// installScenarioPack extracts the scenario pack in the given zip file
+// into the packs directory, replacing the current version of it, if
+// any. If name is non-empty, the pack's manifest must have that name.
+func installScenarioPack(fn string, name string) (ScenarioPackManifest, error) {
+	zr, err := zip.OpenReader(fn)
+	if err != nil {
+		return ScenarioPackManifest{}, err
+	}
+	defer zr.Close()
+
+	m, err := readScenarioPackManifest(zr, ".")
+	if err != nil {
+		return m, err
+	}
+	if name != "" && m.Name != name {
+		return m, fmt.Errorf("expected scenario pack \"%s\" but got \"%s\"", name, m.Name)
+	}
+
+	dir := scenarioPacksDirectory()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return m, err
+	}
+	// Extract to a temporary directory first so that the current version
+	// isn't lost if something goes wrong.
+	tmp, err := os.MkdirTemp(dir, ".install-")
+	if err != nil {
+		return m, err
+	}
+	defer os.RemoveAll(tmp)
+
+	extract := func(f *zip.File) error {
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		fn := filepath.Join(tmp, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0o755); err != nil {
+			return err
+		}
+		w, err := os.Create(fn)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}
+
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			// Directories are created as needed.
+			continue
+		}
+		if !fs.ValidPath(f.Name) || strings.Contains(f.Name, `\`) {
+			return m, fmt.Errorf("%s: invalid path in scenario pack", f.Name)
+		}
+		if err := extract(f); err != nil {
+			return m, fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+
+	dst := filepath.Join(dir, m.Name)
+	if err := os.RemoveAll(dst); err != nil {
+		return m, err
+	}
+	return m, os.Rename(tmp, dst)
+}
+
+func removeScenarioPack(name string) error {
+	if !validScenarioPackName(name) {
+		return ErrInvalidScenarioPackName
+	}
+	return os.RemoveAll(filepath.Join(scenarioPacksDirectory(), name))
+}
+
+func FetchScenarioPackIndex(indexURL string) (*ScenarioPackIndex, error) {
+	b, err := FetchURLWithOptions(indexURL, FetchOptions{
+		Timeout: 15 * time.Second,
+		Retries: 2,
+		MaxSize: 16 << 20,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var index ScenarioPackIndex
+	if err := UnmarshalJSON(b, &index); err != nil {
+		return nil, fmt.Errorf("%s: %w", indexURL, err)
+	}
+
+	index.Packs = FilterSlice(index.Packs, func(p ScenarioPackIndexEntry) bool {
+		if err := p.check(); err != nil {
+			lg.Warnf("%s: %v", indexURL, err)
+			return false
+		}
+		return true
+	})
+	return &index, nil
+}
+
+// resolveScenarioPackURL returns the URL of the given pack's zip file,
+// which may be relative to the index's URL.
+func resolveScenarioPackURL(indexURL, packURL string) (string, error) {
+	base, err := url.Parse(indexURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(packURL)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// verify checks the given contents of the pack's zip file against its
+// checksum and signature, if it has them.
+func (p ScenarioPackIndexEntry) verify(b []byte) error {
+	if sum := sha256.Sum256(b); p.SHA256 != "" && !strings.EqualFold(hex.EncodeToString(sum[:]), p.SHA256) {
+		return ErrScenarioPackChecksum
+	}
+
+	if p.Signature != "" || p.PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(p.PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return ErrScenarioPackSignature
+		}
+		sig, err := base64.StdEncoding.DecodeString(p.Signature)
+		if err != nil || !ed25519.Verify(ed25519.PublicKey(key), b, sig) {
+			return ErrScenarioPackSignature
+		}
+	}
+	return nil
+}
+
+// scenarioPackKeyFingerprint returns a short string that identifies the
+// given base64-encoded public key.
+func scenarioPackKeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	fp := hex.EncodeToString(sum[:8])
+	return fp[:4] + ":" + fp[4:8] + ":" + fp[8:12] + ":" + fp[12:]
+}
+
+// trustWarnings returns the reasons, if any, that the user should be
+// asked before the pack is installed, given the key that was previously
+// trusted for it, if any.
+func (p ScenarioPackIndexEntry) trustWarnings(trustedKey string) []string {
+	var w []string
+	if p.Signature == "" {
+		if trustedKey != "" {
+			w = append(w, "WARNING: previous versions of this pack were signed but this one isn't.\n"+
+				"It may have been tampered with.")
+		} else {
+			w = append(w, "This pack isn't signed, so there's no way to verify who made it.")
+		}
+	} else if trustedKey == "" {
+		w = append(w, "This pack is signed with a key that you haven't trusted before:\n"+
+			"    "+scenarioPackKeyFingerprint(p.PublicKey)+"\n"+
+			"If you continue, future updates must be signed with the same key.")
+	} else if trustedKey != p.PublicKey {
+		w = append(w, "WARNING: this pack is signed with a different key than the one you trusted before:\n"+
+			"    "+scenarioPackKeyFingerprint(p.PublicKey)+" (previously "+scenarioPackKeyFingerprint(trustedKey)+")\n"+
+			"It may have been tampered with.")
+	}
+	if p.SHA256 == "" {
+		w = append(w, "The index doesn't give a checksum for this pack, so a corrupted or\n"+
+			"modified download can't be detected.")
+	}
+	return w
+}
+
+// DownloadScenarioPack downloads the given pack, verifies it, and
+// installs it.
+func DownloadScenarioPack(indexURL string, p ScenarioPackIndexEntry) (ScenarioPackManifest, error) {
+	u, err := resolveScenarioPackURL(indexURL, p.URL)
+	if err != nil {
+		return ScenarioPackManifest{}, err
+	}
+
+	// Packs with video maps may be large, so allow plenty of time.
+	b, err := FetchURLWithOptions(u, FetchOptions{
+		Timeout: 10 * time.Minute,
+		Retries: 2,
+		MaxSize: 1 << 30,
+	})
+	if err != nil {
+		return ScenarioPackManifest{}, err
+	}
+	if err := p.verify(b); err != nil {
+		return ScenarioPackManifest{}, fmt.Errorf("%s: %w", u, err)
+	}
+
+	if err := os.MkdirAll(scenarioPacksDirectory(), 0o755); err != nil {
+		return ScenarioPackManifest{}, err
+	}
+	f, err := os.CreateTemp(scenarioPacksDirectory(), ".download-*.zip")
+	if err != nil {
+		return ScenarioPackManifest{}, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return ScenarioPackManifest{}, err
+	}
+
+	return installScenarioPack(f.Name(), p.Name)
+}
+
+// reloadLocalScenarios has the local sim server reload its scenarios and
+// returns the updated configurations.
+func reloadLocalScenarios() (map[string]map[string]*SimConfiguration, error) {
+	var configs map[string]map[string]*SimConfiguration
+	err := localServer.Call("LocalSimManager.ReloadScenarios", 0, &configs)
+	return configs, err
+}
+
+///////////////////////////////////////////////////////////////////////////
+// UI
+
+// As with config sync, network requests and installation run
+// asynchronously; when they finish, they send a function to run on the
+// main thread to process the result.
+var scenarioPacks struct {
+	index     *ScenarioPackIndex
+	installed map[string]ScenarioPackManifest
+	results   chan func(*EventStream)
+	pending   bool
+	status    string
+}
+
+func scenarioPacksStart(status string, f func() func(*EventStream)) {
+	if scenarioPacks.results == nil {
+		scenarioPacks.results = make(chan func(*EventStream), 1)
+	}
+	scenarioPacks.pending = true
+	scenarioPacks.status = status
+	go func() { scenarioPacks.results <- f() }()
+}
+
+// uiUpdateScenarioPacks should be called once per frame from the main
+// thread; it processes the results of completed downloads.
+func uiUpdateScenarioPacks(eventStream *EventStream) {
+	if !scenarioPacks.pending {
+		return
+	}
+
+	select {
+	case f := <-scenarioPacks.results:
+		scenarioPacks.pending = false
+		f(eventStream)
+	default:
+	}
+}
+
+func StartScenarioPackIndexDownload() {
+	indexURL := globalConfig.ScenarioPackIndexURL
+	if indexURL == "" || scenarioPacks.pending {
+		return
+	}
+
+	scenarioPacksStart("Downloading index...", func() func(*EventStream) {
+		index, err := FetchScenarioPackIndex(indexURL)
+		return func(*EventStream) {
+			if err != nil {
+				lg.Warnf("scenario packs: %v", err)
+				scenarioPacks.status = "Error: " + err.Error()
+			} else {
+				scenarioPacks.index = index
+				scenarioPacks.status = ""
+			}
+		}
+	})
+}
+
+// startScenarioPackChange runs the given function to install or remove a
+// pack and then has the local server reload its scenarios. If it's
+// successful, onSuccess, if non-nil, is called on the main thread.
+func startScenarioPackChange(status string, change func() (string, error), onSuccess func()) {
+	if scenarioPacks.pending {
+		return
+	}
+
+	scenarioPacksStart(status, func() func(*EventStream) {
+		msg, err := change()
+		var configs map[string]map[string]*SimConfiguration
+		if err == nil {
+			configs, err = reloadLocalScenarios()
+		}
+		return func(eventStream *EventStream) {
+			scenarioPacks.installed = InstalledScenarioPacks()
+			if err != nil {
+				lg.Warnf("scenario packs: %v", err)
+				scenarioPacks.status = "Error: " + err.Error()
+			} else {
+				localServer.configs = configs
+				if onSuccess != nil {
+					onSuccess()
+				}
+				scenarioPacks.status = msg
+				eventStream.Post(Event{Type: StatusMessageEvent, Message: msg})
+			}
+		}
+	})
+}
+
+// uiInstallScenarioPack installs the given pack, first asking the user
+// to confirm if there's any reason to doubt that it's authentic.
+func uiInstallScenarioPack(p ScenarioPackIndexEntry) {
+	indexURL := globalConfig.ScenarioPackIndexURL
+	install := func() {
+		startScenarioPackChange("Downloading "+p.Name+"...", func() (string, error) {
+			m, err := DownloadScenarioPack(indexURL, p)
+			return "Installed scenario pack " + m.Name + " " + m.Version, err
+		}, func() {
+			// The signature has been verified, so remember the key for
+			// future updates.
+			if p.PublicKey != "" {
+				if globalConfig.TrustedScenarioPackKeys == nil {
+					globalConfig.TrustedScenarioPackKeys = make(map[string]string)
+				}
+				globalConfig.TrustedScenarioPackKeys[p.Name] = p.PublicKey
+			}
+		})
+	}
+
+	warnings := p.trustWarnings(globalConfig.TrustedScenarioPackKeys[p.Name])
+	if len(warnings) == 0 {
+		install()
+		return
+	}
+
+	uiShowModalDialog(NewModalDialogBox(&YesOrNoModalClient{
+		title: "Install Scenario Pack?",
+		query: p.Name + " " + p.Version + " from " + indexURL + "\n\n" + strings.Join(warnings, "\n\n") +
+			"\n\nOnly install scenario packs from sources that you trust. Install it anyway?",
+		ok: install,
+	}), true)
+}
+
+type ScenarioPacksModalClient struct {
+	allowCancel bool
+}
+
+func (c *ScenarioPacksModalClient) Title() string { return "Scenario Packs" }
+
+func (c *ScenarioPacksModalClient) Opening() {
+	scenarioPacks.installed = InstalledScenarioPacks()
+	if scenarioPacks.index == nil {
+		StartScenarioPackIndexDownload()
+	}
+}
+
+func (c *ScenarioPacksModalClient) Buttons() []ModalDialogButton {
+	return []ModalDialogButton{{
+		text: "Back",
+		action: func() bool {
+			uiShowModalDialog(NewModalDialogBox(&ConnectModalClient{allowCancel: c.allowCancel}), false)
+			return true
+		},
+	}}
+}
+
+func (c *ScenarioPacksModalClient) Draw() int {
+	imgui.InputTextV("Index URL", &globalConfig.ScenarioPackIndexURL, 0, nil)
+	imgui.SameLine()
+	uiStartDisable(scenarioPacks.pending || globalConfig.ScenarioPackIndexURL == "")
+	if imgui.Button(FontAwesomeIconRedo) {
+		StartScenarioPackIndexDownload()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Download the list of available scenario packs")
+	}
+	uiEndDisable(scenarioPacks.pending || globalConfig.ScenarioPackIndexURL == "")
+
+	available := make(map[string]ScenarioPackIndexEntry)
+	if scenarioPacks.index != nil {
+		for _, p := range scenarioPacks.index.Packs {
+			available[p.Name] = p
+		}
+	}
+	names := SortedMapKeys(available)
+	for name := range scenarioPacks.installed {
+		if _, ok := available[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	scenarioPackErrors.mu.Lock()
+	packErrors := scenarioPackErrors.errs
+	scenarioPackErrors.mu.Unlock()
+
+	if len(names) == 0 {
+		imgui.Text("No scenario packs are installed or available.")
+	} else if imgui.BeginTableV("packs", 5, imgui.TableFlagsBordersV|imgui.TableFlagsRowBg, imgui.Vec2{}, 0) {
+		imgui.TableSetupColumn("Name")
+		imgui.TableSetupColumn("Installed")
+		imgui.TableSetupColumn("Available")
+		imgui.TableSetupColumn("Description")
+		imgui.TableSetupColumn("")
+		imgui.TableHeadersRow()
+
+		uiStartDisable(scenarioPacks.pending)
+		for _, name := range names {
+			imgui.PushID(name)
+			installed, isInstalled := scenarioPacks.installed[name]
+			avail, isAvailable := available[name]
+
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.Text(name)
+			imgui.TableNextColumn()
+			imgui.Text(installed.Version)
+			if err, ok := packErrors[name]; ok && isInstalled {
+				imgui.SameLine()
+				imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{1, .5, .5, 1})
+				imgui.Text(FontAwesomeIconExclamationTriangle)
+				imgui.PopStyleColor()
+				if imgui.IsItemHovered() {
+					imgui.SetTooltip("This pack has errors and was not loaded:\n" + err)
+				}
+			}
+			imgui.TableNextColumn()
+			imgui.Text(avail.Version)
+			imgui.TableNextColumn()
+			imgui.Text(Select(isAvailable, avail.Description, installed.Description))
+
+			imgui.TableNextColumn()
+			if isAvailable && (!isInstalled || installed.Version != avail.Version) {
+				if imgui.Button(Select(isInstalled, "Update", "Install")) {
+					uiInstallScenarioPack(avail)
+				}
+				imgui.SameLine()
+			}
+			if isInstalled {
+				if imgui.Button(FontAwesomeIconTrash) {
+					name := name
+					startScenarioPackChange("Removing "+name+"...", func() (string, error) {
+						return "Removed scenario pack " + name, removeScenarioPack(name)
+					}, nil)
+				}
+				if imgui.IsItemHovered() {
+					imgui.SetTooltip("Remove this scenario pack")
+				}
+			}
+
+			imgui.PopID()
+		}
+		uiEndDisable(scenarioPacks.pending)
+
+		imgui.EndTable()
+	}
+
+	if scenarioPacks.status != "" {
+		imgui.Text(scenarioPacks.status)
+	}
+
+	return -1
+}
@@ -0,0 +1,201 @@
+// scenariopacks_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestZip(t *testing.T, fn string, files map[string]string) {
+	f, err := os.Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstallScenarioPack(t *testing.T) {
+	defer func(dir string) { *configDir = dir }(*configDir)
+	*configDir = t.TempDir()
+
+	manifest := `{"name": "test-pack", "version": "1.0"}`
+	fn := filepath.Join(t.TempDir(), "pack.zip")
+	writeTestZip(t, fn, map[string]string{
+		"manifest.json":           manifest,
+		"scenarios/test.json":     "{}",
+		"videomaps/test.gob.zst":  "",
+		"videomaps/nested/a.json": "{}",
+	})
+
+	if _, err := installScenarioPack(fn, "other-pack"); err == nil {
+		t.Errorf("expected error for mismatched pack name")
+	}
+	m, err := installScenarioPack(fn, "test-pack")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Name != "test-pack" || m.Version != "1.0" {
+		t.Errorf("unexpected manifest %+v", m)
+	}
+	if _, err := os.Stat(filepath.Join(scenarioPacksDirectory(), "test-pack", "videomaps", "nested", "a.json")); err != nil {
+		t.Errorf("pack not extracted: %v", err)
+	}
+	if packs := InstalledScenarioPacks(); len(packs) != 1 || packs["test-pack"] != m {
+		t.Errorf("unexpected installed packs %+v", packs)
+	}
+
+	// Bad packs shouldn't disturb the installed one.
+	for _, files := range []map[string]string{
+		{"scenarios/test.json": "{}"}, // no manifest
+		{"manifest.json": `{"name": "../evil", "version": "1.0"}`},
+		{"manifest.json": `{"name": "test-pack"}`},
+		{"manifest.json": manifest, "../evil.json": "{}"},
+	} {
+		bad := filepath.Join(t.TempDir(), "bad.zip")
+		writeTestZip(t, bad, files)
+		if _, err := installScenarioPack(bad, ""); err == nil {
+			t.Errorf("%+v: expected error", files)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(scenarioPacksDirectory(), "..", "evil.json")); err == nil {
+		t.Errorf("file extracted outside of the packs directory")
+	}
+	if packs := InstalledScenarioPacks(); len(packs) != 1 {
+		t.Errorf("unexpected installed packs %+v", packs)
+	}
+
+	if err := removeScenarioPack("test-pack"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if packs := InstalledScenarioPacks(); len(packs) != 0 {
+		t.Errorf("expected no installed packs, got %+v", packs)
+	}
+	if err := removeScenarioPack(".."); err == nil {
+		t.Errorf("expected error for invalid pack name")
+	}
+}
+
+func TestLoadScenarioPacksSkipsErrors(t *testing.T) {
+	defer func(dir string) { *configDir = dir }(*configDir)
+	*configDir = t.TempDir()
+
+	fn := filepath.Join(t.TempDir(), "pack.zip")
+	writeTestZip(t, fn, map[string]string{
+		"manifest.json":      `{"name": "broken", "version": "1.0"}`,
+		"scenarios/bad.json": "{",
+	})
+	if _, err := installScenarioPack(fn, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	scenarioGroups := make(map[string]map[string]*ScenarioGroup)
+	simConfigurations := make(map[string]map[string]*SimConfiguration)
+	loadScenarioPacks(scenarioGroups, simConfigurations, make(map[string]map[string]CommandBuffer))
+
+	if len(scenarioGroups) != 0 || len(simConfigurations) != 0 {
+		t.Errorf("expected broken pack to be skipped")
+	}
+	if _, ok := scenarioPackErrors.errs["broken"]; !ok {
+		t.Errorf("expected errors to be recorded for the broken pack")
+	}
+}
+
+func TestResolveScenarioPackURL(t *testing.T) {
+	for _, c := range [][3]string{
+		{"https://example.com/vice/index.json", "pack-1.0.zip", "https://example.com/vice/pack-1.0.zip"},
+		{"https://example.com/vice/index.json", "/packs/pack.zip", "https://example.com/packs/pack.zip"},
+		{"https://example.com/vice/index.json", "https://other.org/pack.zip", "https://other.org/pack.zip"},
+	} {
+		if u, err := resolveScenarioPackURL(c[0], c[1]); err != nil || u != c[2] {
+			t.Errorf("%s + %s: got %q (%v), expected %q", c[0], c[1], u, err, c[2])
+		}
+	}
+}
+
+func TestScenarioPackVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := []byte("not really a zip file")
+	sum := sha256.Sum256(b)
+	key := base64.StdEncoding.EncodeToString(pub)
+	p := ScenarioPackIndexEntry{
+		ScenarioPackManifest: ScenarioPackManifest{Name: "test", Version: "1"},
+		SHA256:               hex.EncodeToString(sum[:]),
+		PublicKey:            key,
+		Signature:            base64.StdEncoding.EncodeToString(ed25519.Sign(priv, b)),
+	}
+
+	if err := p.verify(b); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := p.verify(append(b, '!')); !errors.Is(err, ErrScenarioPackChecksum) {
+		t.Errorf("expected checksum error, got %v", err)
+	}
+
+	unsummed := p
+	unsummed.SHA256 = ""
+	if err := unsummed.verify(append(b, '!')); !errors.Is(err, ErrScenarioPackSignature) {
+		t.Errorf("expected signature error, got %v", err)
+	}
+	wrongKey := p
+	wrongKey.PublicKey = base64.StdEncoding.EncodeToString(otherPub)
+	if err := wrongKey.verify(b); !errors.Is(err, ErrScenarioPackSignature) {
+		t.Errorf("expected signature error, got %v", err)
+	}
+	unsigned := p
+	unsigned.Signature = ""
+	if err := unsigned.verify(b); !errors.Is(err, ErrScenarioPackSignature) {
+		t.Errorf("expected signature error for a key without a signature, got %v", err)
+	}
+
+	// Trust warnings
+	if w := p.trustWarnings(key); len(w) != 0 {
+		t.Errorf("expected no warnings for a trusted key, got %v", w)
+	}
+	if w := p.trustWarnings(""); len(w) != 1 {
+		t.Errorf("expected a warning for a new key, got %v", w)
+	}
+	if w := wrongKey.trustWarnings(key); len(w) != 1 || !strings.HasPrefix(w[0], "WARNING") {
+		t.Errorf("expected a warning for a changed key, got %v", w)
+	}
+	unsigned.PublicKey = ""
+	if w := unsigned.trustWarnings(key); len(w) != 1 || !strings.HasPrefix(w[0], "WARNING") {
+		t.Errorf("expected a warning for an unsigned update, got %v", w)
+	}
+	if w := unsummed.trustWarnings(key); len(w) != 1 {
+		t.Errorf("expected a warning for a missing checksum, got %v", w)
+	}
+}
@@ -0,0 +1,225 @@
+// schedule.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Traffic schedules: rather than launching aircraft at the same rates for
+// the entire session, a scenario may provide a schedule of arrival and
+// departure banks, as at hub airports where a push of arrivals is
+// followed by a push of departures and then a quiet period. While a bank
+// is active, the configured launch rates are scaled by the bank's
+// factors; they ramp up at the start of the bank and back down at its
+// end. Outside of banks, the rates are scaled by the schedule's quiet
+// factors. For example:
+//
+//	"schedule": {
+//	    "quiet_arrival_scale": 0.25,
+//	    "quiet_departure_scale": 0.25,
+//	    "repeat": 90,
+//	    "banks": [
+//	        { "name": "arrival push", "start": 10, "length": 25, "ramp": 5,
+//	          "arrival_scale": 2, "departure_scale": 0.5 },
+//	        { "name": "departure push", "start": 40, "length": 25, "ramp": 5,
+//	          "arrival_scale": 0.5, "departure_scale": 2 }
+//	    ]
+//	}
+//
+// Times are in minutes after the start of the session.
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type TrafficBank struct {
+	Name           string  `json:"name,omitempty"`
+	Start          float32 `json:"start"`
+	Length         float32 `json:"length"`
+	Ramp           float32 `json:"ramp,omitempty"`
+	ArrivalScale   float32 `json:"arrival_scale"`
+	DepartureScale float32 `json:"departure_scale"`
+}
+
+type TrafficSchedule struct {
+	Banks               []TrafficBank `json:"banks"`
+	QuietArrivalScale   float32       `json:"quiet_arrival_scale"`
+	QuietDepartureScale float32       `json:"quiet_departure_scale"`
+	// If non-zero, the schedule starts over after this many minutes.
+	Repeat float32 `json:"repeat,omitempty"`
+}
+
+func (ts *TrafficSchedule) PostDeserialize(e *ErrorLogger) {
+	e.Push("schedule")
+	defer e.Pop()
+
+	if len(ts.Banks) == 0 {
+		e.ErrorString("no \"banks\" specified")
+	}
+	if ts.QuietArrivalScale < 0 || ts.QuietDepartureScale < 0 {
+		e.ErrorString("quiet period scale factors must be non-negative")
+	}
+	for i, b := range ts.Banks {
+		e.Push(fmt.Sprintf("bank %d", i+1))
+		if b.Start < 0 {
+			e.ErrorString("\"start\" must be non-negative")
+		}
+		if b.Length <= 0 {
+			e.ErrorString("\"length\" must be positive")
+		}
+		if b.Ramp < 0 || 2*b.Ramp > b.Length {
+			e.ErrorString("\"ramp\" must be between 0 and half of \"length\"")
+		}
+		if b.ArrivalScale < 0 || b.DepartureScale < 0 {
+			e.ErrorString("scale factors must be non-negative")
+		}
+		if ts.Repeat > 0 && b.Start+b.Length > ts.Repeat {
+			e.ErrorString("bank ends after the schedule repeats at %.0f minutes", ts.Repeat)
+		}
+		e.Pop()
+	}
+	if ts.Repeat < 0 {
+		e.ErrorString("\"repeat\" must be non-negative")
+	}
+}
+
+// minutes returns the time in minutes into the schedule, accounting for
+// repeats, for the given time since the start of the session.
+func (ts *TrafficSchedule) minutes(elapsed time.Duration) float32 {
+	m := max(0, float32(elapsed.Minutes()))
+	if ts.Repeat > 0 {
+		m -= ts.Repeat * floor(m/ts.Repeat)
+	}
+	return m
+}
+
+// weight returns how much the bank is in effect at the given time in
+// minutes into the schedule: zero before and after the bank, one while
+// it is fully active, and linearly in between while it ramps up or down.
+func (b *TrafficBank) weight(m float32) float32 {
+	if m < b.Start || m >= b.Start+b.Length {
+		return 0
+	}
+	if b.Ramp > 0 {
+		return min(1, min((m-b.Start)/b.Ramp, (b.Start+b.Length-m)/b.Ramp))
+	}
+	return 1
+}
+
+// Scales returns the factors that arrival and departure rates are scaled
+// by at the given time since the start of the session. Where banks
+// overlap, the larger of their factors is used.
+func (ts *TrafficSchedule) Scales(elapsed time.Duration) (arrival, departure float32) {
+	m := ts.minutes(elapsed)
+	arrival, departure = ts.QuietArrivalScale, ts.QuietDepartureScale
+	var inBank bool
+	for _, b := range ts.Banks {
+		if w := b.weight(m); w > 0 {
+			a := lerp(w, ts.QuietArrivalScale, b.ArrivalScale)
+			d := lerp(w, ts.QuietDepartureScale, b.DepartureScale)
+			if !inBank {
+				arrival, departure = a, d
+				inBank = true
+			} else {
+				arrival, departure = max(arrival, a), max(departure, d)
+			}
+		}
+	}
+	return
+}
+
+// untilNextBank returns how long it is from the given time since the
+// start of the session until the next bank starts. It returns false if
+// there are no more banks.
+func (ts *TrafficSchedule) untilNextBank(elapsed time.Duration) (time.Duration, bool) {
+	m := ts.minutes(elapsed)
+	next, found := float32(0), false
+	for _, b := range ts.Banks {
+		start := b.Start
+		if start <= m {
+			if ts.Repeat == 0 {
+				continue
+			}
+			start += ts.Repeat
+		}
+		if !found || start < next {
+			next, found = start, true
+		}
+	}
+	return time.Duration((next - m) * float32(time.Minute)), found
+}
+
+// scheduledRate returns the given launch rate (aircraft per hour) scaled
+// according to the traffic schedule, if it's in use, and then the
+// controller's workload.
+func (lc *LaunchConfig) scheduledRate(rate int, arrival bool, elapsed time.Duration) int {
+	if lc.Schedule != nil && lc.UseSchedule && rate > 0 {
+		a, d := lc.Schedule.Scales(elapsed)
+		if rate = int(float32(rate)*Select(arrival, a, d) + 0.5); rate == 0 {
+			return 0
+		}
+	}
+	return lc.Adaptive.scaleRate(rate)
+}
+
+// launchWait returns how long to wait before launching the next aircraft
+// given the launch rate. When a traffic schedule is in use, the wait ends
+// no later than shortly after the start of the next bank so that slow or
+// stopped launches during quiet periods pick up when it begins.
+func (lc *LaunchConfig) launchWait(rate int, arrival, pushActive bool, elapsed time.Duration) time.Duration {
+	wait := randomWait(lc.scheduledRate(rate, arrival, elapsed), pushActive)
+	if lc.Schedule != nil && lc.UseSchedule && rate > 0 {
+		if d, ok := lc.Schedule.untilNextBank(elapsed); ok {
+			// Don't launch everything at the start of the bank.
+			d += time.Duration(rand.Intn(60)) * time.Second
+			wait = min(wait, d)
+		}
+	}
+	return wait
+}
+
+func (lc *LaunchConfig) DrawScheduleUI() (changed bool) {
+	if lc.Schedule == nil {
+		return
+	}
+
+	imgui.Separator()
+	changed = imgui.Checkbox("Follow scenario traffic schedule", &lc.UseSchedule)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Scale the arrival and departure rates over time according to the scenario's schedule of traffic banks")
+	}
+
+	uiStartDisable(!lc.UseSchedule)
+	ts := lc.Schedule
+	imgui.Text(fmt.Sprintf("Between banks: arrivals x%.2f, departures x%.2f", ts.QuietArrivalScale,
+		ts.QuietDepartureScale))
+	if ts.Repeat > 0 {
+		imgui.Text(fmt.Sprintf("Repeats every %.0f minutes", ts.Repeat))
+	}
+	flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg | imgui.TableFlagsSizingStretchProp
+	if imgui.BeginTableV("schedule", 4, flags, imgui.Vec2{}, 0.) {
+		imgui.TableSetupColumn("Bank")
+		imgui.TableSetupColumn("Minutes")
+		imgui.TableSetupColumn("Arrivals")
+		imgui.TableSetupColumn("Departures")
+		imgui.TableHeadersRow()
+
+		for i, b := range ts.Banks {
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.Text(Select(b.Name != "", b.Name, fmt.Sprintf("%d", i+1)))
+			imgui.TableNextColumn()
+			imgui.Text(fmt.Sprintf("%.0f-%.0f", b.Start, b.Start+b.Length))
+			imgui.TableNextColumn()
+			imgui.Text(fmt.Sprintf("x%.2f", b.ArrivalScale))
+			imgui.TableNextColumn()
+			imgui.Text(fmt.Sprintf("x%.2f", b.DepartureScale))
+		}
+		imgui.EndTable()
+	}
+	uiEndDisable(!lc.UseSchedule)
+
+	return
+}
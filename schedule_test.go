@@ -0,0 +1,78 @@
+// schedule_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrafficScheduleScales(t *testing.T) {
+	ts := TrafficSchedule{
+		QuietArrivalScale:   0.5,
+		QuietDepartureScale: 0.25,
+		Repeat:              60,
+		Banks: []TrafficBank{
+			{Start: 10, Length: 20, Ramp: 5, ArrivalScale: 2, DepartureScale: 0.5},
+			{Start: 35, Length: 10, ArrivalScale: 0, DepartureScale: 3},
+		},
+	}
+
+	for _, test := range []struct {
+		minutes   float32
+		arr, dep  float32
+		untilNext float32
+	}{
+		{minutes: 0, arr: 0.5, dep: 0.25, untilNext: 10},
+		{minutes: 12.5, arr: 1.25, dep: 0.375, untilNext: 22.5}, // ramping up
+		{minutes: 20, arr: 2, dep: 0.5, untilNext: 15},
+		{minutes: 30, arr: 0.5, dep: 0.25, untilNext: 5},
+		{minutes: 40, arr: 0, dep: 3, untilNext: 30},
+		{minutes: 75, arr: 2, dep: 0.5, untilNext: 20}, // repeated
+	} {
+		elapsed := time.Duration(test.minutes * float32(time.Minute))
+		arr, dep := ts.Scales(elapsed)
+		if abs(arr-test.arr) > 1e-4 || abs(dep-test.dep) > 1e-4 {
+			t.Errorf("%.1f minutes: got scales %f, %f; expected %f, %f", test.minutes, arr, dep,
+				test.arr, test.dep)
+		}
+		if d, ok := ts.untilNextBank(elapsed); !ok {
+			t.Errorf("%.1f minutes: expected another bank", test.minutes)
+		} else if m := float32(d.Minutes()); abs(m-test.untilNext) > 1e-3 {
+			t.Errorf("%.1f minutes: got %f minutes until the next bank; expected %f", test.minutes,
+				m, test.untilNext)
+		}
+	}
+
+	// Without repeats, there's nothing after the last bank.
+	ts.Repeat = 0
+	if _, ok := ts.untilNextBank(50 * time.Minute); ok {
+		t.Errorf("unexpected bank after the end of the schedule")
+	}
+}
+
+func TestScheduledLaunchRate(t *testing.T) {
+	lc := LaunchConfig{
+		Schedule: &TrafficSchedule{
+			Banks: []TrafficBank{{Start: 10, Length: 10, ArrivalScale: 2, DepartureScale: 1}},
+		},
+		UseSchedule: true,
+	}
+
+	if r := lc.scheduledRate(30, true, 0); r != 0 {
+		t.Errorf("expected no arrivals before the bank; got rate %d", r)
+	}
+	if w := lc.launchWait(30, true, false, 0); w < 10*time.Minute || w > 11*time.Minute {
+		t.Errorf("expected to wait for the start of the bank; got %s", w)
+	}
+	if r := lc.scheduledRate(30, true, 15*time.Minute); r != 60 {
+		t.Errorf("expected doubled arrival rate during the bank; got %d", r)
+	}
+
+	lc.UseSchedule = false
+	if r := lc.scheduledRate(30, true, 0); r != 30 {
+		t.Errorf("expected unscaled rate without the schedule; got %d", r)
+	}
+}
@@ -0,0 +1,127 @@
+// scratchpads.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Scratchpad rules: beyond the standard STARS restrictions on scratchpad
+// contents (length, characters, no three numerals, and a few reserved
+// values), a facility's adaptation may restrict what can be entered and
+// may have scratchpads set automatically for new traffic, e.g.:
+//
+//	"scratchpad_rules": {
+//	    "allowed_characters": "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+//	    "patterns": [ "[A-Z]{3}", "[0-9][A-Z]{1,2}" ],
+//	    "forbidden": [ "TST" ],
+//	    "approaches": { "KJFK/I4R": "I4R", "KJFK/R4L": "R4L" }
+//	}
+//
+// When "patterns" or "secondary_patterns" are given, primary or secondary
+// scratchpads, respectively, must match one of the regular expressions
+// in their entirety. Scratchpads starting with any of the "forbidden"
+// values can't be entered. Arrivals that spawn expecting an approach
+// given in "approaches" (indexed by "airport/approach") get the
+// corresponding primary scratchpad; departures get the one given for
+// their exit in the adaptation's "scratchpads".
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type ScratchpadRules struct {
+	AllowedCharacters string            `json:"allowed_characters"`
+	Patterns          []string          `json:"patterns"`
+	SecondaryPatterns []string          `json:"secondary_patterns"`
+	Forbidden         []string          `json:"forbidden"`
+	Approaches        map[string]string `json:"approaches"`
+}
+
+const defaultScratchpadCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789./*" + STARSTriangleCharacter
+
+// Scratchpads may never start with these.
+var reservedScratchpads = []string{"NAT", "CST", "AMB", "RDR", "ADB", "XXX"}
+
+func (r *ScratchpadRules) PostDeserialize(e *ErrorLogger, fa *STARSFacilityAdaptation, sg *ScenarioGroup) {
+	e.Push("scratchpad_rules")
+	defer e.Pop()
+
+	for _, p := range append(slices.Clone(r.Patterns), r.SecondaryPatterns...) {
+		if _, err := regexp.Compile(p); err != nil {
+			e.ErrorString("\"%s\": invalid pattern: %v", p, err)
+		}
+	}
+
+	for _, id := range SortedMapKeys(r.Approaches) {
+		if icao, appr, ok := strings.Cut(id, "/"); !ok {
+			e.ErrorString("\"%s\": approaches must be given as \"airport/approach\"", id)
+		} else if ap, ok := sg.Airports[icao]; !ok {
+			e.ErrorString("\"%s\": airport \"%s\" not found", id, icao)
+		} else if _, ok := ap.Approaches[appr]; !ok {
+			e.ErrorString("\"%s\": approach \"%s\" not found", id, appr)
+		}
+		if err := fa.CheckScratchpad(r.Approaches[id], false); err != nil {
+			e.ErrorString("\"%s\": scratchpad \"%s\" isn't allowed", id, r.Approaches[id])
+		}
+	}
+}
+
+// CheckScratchpad returns an error if the given scratchpad can't be
+// entered under the facility's rules. Empty scratchpads, which clear the
+// current one, are always allowed.
+func (fa *STARSFacilityAdaptation) CheckScratchpad(sp string, secondary bool) error {
+	lc := len([]rune(sp))
+	if lc == 0 {
+		return nil
+	}
+
+	if secondary {
+		// 5-148: secondary is 1 to 3-maybe-4 characters
+		if (fa.AllowLongScratchpad[1] && lc > 4) || (!fa.AllowLongScratchpad[1] && lc > 3) {
+			return ErrSTARSCommandFormat
+		}
+	} else {
+		// 5-148: primary is 2 to 3-maybe-4 characters
+		if lc == 1 || (fa.AllowLongScratchpad[0] && lc > 4) || (!fa.AllowLongScratchpad[0] && lc > 3) {
+			return ErrSTARSCommandFormat
+		}
+	}
+
+	rules := &fa.ScratchpadRules
+	allowed := Select(rules.AllowedCharacters != "", rules.AllowedCharacters, defaultScratchpadCharacters)
+	for _, ch := range sp {
+		if !strings.ContainsRune(allowed, ch) {
+			return ErrSTARSCommandFormat
+		}
+	}
+
+	// It can't be three numerals
+	if lc == 3 && sp[0] >= '0' && sp[0] <= '9' && sp[1] >= '0' && sp[1] <= '9' && sp[2] >= '0' && sp[2] <= '9' {
+		return ErrSTARSCommandFormat
+	}
+
+	for _, f := range append(slices.Clone(reservedScratchpads), rules.Forbidden...) {
+		if strings.HasPrefix(sp, f) {
+			return ErrSTARSIllegalScratchpad
+		}
+	}
+
+	if patterns := Select(secondary, rules.SecondaryPatterns, rules.Patterns); len(patterns) > 0 {
+		if !slices.ContainsFunc(patterns, func(p string) bool {
+			ok, err := regexp.MatchString("^(?:"+p+")$", sp)
+			return ok && err == nil
+		}) {
+			return ErrSTARSIllegalScratchpad
+		}
+	}
+
+	return nil
+}
+
+// ArrivalScratchpad returns the scratchpad that an arrival expecting the
+// given approach should have, if the adaptation specifies one.
+func (fa *STARSFacilityAdaptation) ArrivalScratchpad(airport, approach string) (string, bool) {
+	sp, ok := fa.ScratchpadRules.Approaches[airport+"/"+approach]
+	return sp, ok
+}
@@ -0,0 +1,78 @@
+// scratchpads_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckScratchpad(t *testing.T) {
+	type test struct {
+		sp        string
+		secondary bool
+		err       error
+	}
+	check := func(fa *STARSFacilityAdaptation, tests []test) {
+		t.Helper()
+		for _, tc := range tests {
+			if err := fa.CheckScratchpad(tc.sp, tc.secondary); err != tc.err {
+				t.Errorf("%q (secondary %v): expected %v, got %v", tc.sp, tc.secondary, tc.err, err)
+			}
+		}
+	}
+
+	var fa STARSFacilityAdaptation
+	check(&fa, []test{
+		{sp: "", err: nil},
+		{sp: "A", err: ErrSTARSCommandFormat},
+		{sp: "A", secondary: true, err: nil},
+		{sp: "JFK", err: nil},
+		{sp: "ABCD", err: ErrSTARSCommandFormat},
+		{sp: "A-B", err: ErrSTARSCommandFormat},
+		{sp: "123", err: ErrSTARSCommandFormat},
+		{sp: "12A", err: nil},
+		{sp: "NAT", err: ErrSTARSIllegalScratchpad},
+		{sp: "XXX", secondary: true, err: ErrSTARSIllegalScratchpad},
+	})
+
+	fa.AllowLongScratchpad = [2]bool{true, false}
+	check(&fa, []test{
+		{sp: "ABCD", err: nil},
+		{sp: "ABCD", secondary: true, err: ErrSTARSCommandFormat},
+	})
+
+	fa = STARSFacilityAdaptation{
+		ScratchpadRules: ScratchpadRules{
+			AllowedCharacters: "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+			Patterns:          []string{"[A-Z]{3}", "[0-9][A-Z]{1,2}"},
+			SecondaryPatterns: []string{"[0-9]{2}"},
+			Forbidden:         []string{"TS"},
+		},
+	}
+	check(&fa, []test{
+		{sp: "", err: nil},
+		{sp: "JFK", err: nil},
+		{sp: "4R", err: nil},
+		{sp: "R4", err: ErrSTARSIllegalScratchpad},
+		{sp: "JF.", err: ErrSTARSCommandFormat},
+		{sp: "TST", err: ErrSTARSIllegalScratchpad},
+		{sp: "35", secondary: true, err: nil},
+		{sp: "JFK", secondary: true, err: ErrSTARSIllegalScratchpad},
+	})
+}
+
+func TestArrivalScratchpad(t *testing.T) {
+	fa := STARSFacilityAdaptation{
+		ScratchpadRules: ScratchpadRules{
+			Approaches: map[string]string{"KJFK/I4R": "I4R"},
+		},
+	}
+	if sp, ok := fa.ArrivalScratchpad("KJFK", "I4R"); !ok || sp != "I4R" {
+		t.Errorf("expected scratchpad I4R, got %q (%v)", sp, ok)
+	}
+	if sp, ok := fa.ArrivalScratchpad("KJFK", "I4L"); ok {
+		t.Errorf("unexpected scratchpad %q", sp)
+	}
+}
@@ -0,0 +1,196 @@
+// screenshot.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Screenshots are captured from the framebuffer at the end of the frame:
+// either just the radar scope, before the imgui windows are drawn over it,
+// or the entire window, including the UI. They can be taken using the
+// "Screenshot" key function, automatically when selected events happen
+// in the sim, or requested programmatically via RequestScreenshot, which
+// delivers the image to a callback so that it can be embedded elsewhere.
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type ScreenshotConfig struct {
+	// If set, screenshots include only the scope pane and not the rest of
+	// the window.
+	ScopeOnly bool
+	// Event types that automatically trigger a screenshot.
+	Triggers []EventType
+}
+
+type screenshotRequest struct {
+	scopeOnly bool
+	callback  func(img *image.RGBA)
+}
+
+var screenshots struct {
+	mu      sync.Mutex
+	pending []screenshotRequest
+
+	events        *EventsSubscription
+	lastTriggered time.Time
+}
+
+// RequestScreenshot requests that a screenshot be captured at the end of
+// the current frame; the callback is then called with the image from the
+// main thread. It may be called from any goroutine.
+func RequestScreenshot(scopeOnly bool, callback func(img *image.RGBA)) {
+	screenshots.mu.Lock()
+	defer screenshots.mu.Unlock()
+	screenshots.pending = append(screenshots.pending, screenshotRequest{scopeOnly: scopeOnly, callback: callback})
+}
+
+// TakeScreenshot captures a screenshot and saves it to a PNG file in the
+// screenshots directory.
+func TakeScreenshot(scopeOnly bool, eventStream *EventStream) {
+	RequestScreenshot(scopeOnly, func(img *image.RGBA) {
+		filename, err := saveScreenshot(img)
+		if err != nil {
+			lg.Errorf("unable to save screenshot: %v", err)
+			eventStream.Post(Event{Type: StatusMessageEvent, Message: "Unable to save screenshot: " + err.Error()})
+		} else {
+			lg.Infof("saved screenshot %s", filename)
+			eventStream.Post(Event{Type: StatusMessageEvent, Message: "Saved screenshot " + filename})
+		}
+	})
+}
+
+func screenshotDirectory() string {
+	return path.Join(configDirectory(), "screenshots")
+}
+
+func saveScreenshot(img *image.RGBA) (string, error) {
+	dir := screenshotDirectory()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	filename := path.Join(dir, "vice-"+stamp+".png")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			break
+		}
+		filename = path.Join(dir, fmt.Sprintf("vice-%s-%d.png", stamp, i))
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return "", err
+	}
+	return filename, f.Close()
+}
+
+// captureScreenshots handles pending screenshot requests; it is called
+// after the panes have been drawn with scopeOnly set and then again after
+// the UI has been drawn.
+func captureScreenshots(r Renderer, p Platform, scopeOnly bool) {
+	screenshots.mu.Lock()
+	var requests []screenshotRequest
+	screenshots.pending = slices.DeleteFunc(screenshots.pending, func(req screenshotRequest) bool {
+		if req.scopeOnly == scopeOnly {
+			requests = append(requests, req)
+			return true
+		}
+		return false
+	})
+	screenshots.mu.Unlock()
+
+	if len(requests) == 0 {
+		return
+	}
+
+	var img *image.RGBA
+	fbSize := p.FramebufferSize()
+	if scopeOnly {
+		// Convert the scope's extent from window coordinates to pixels.
+		scale := fbSize[1] / p.DisplaySize()[1]
+		e := wm.scopeExtent.Scale(scale)
+		img = r.ReadPixels(int(e.p0[0]), int(e.p0[1]), int(e.Width()), int(e.Height()))
+	} else {
+		img = r.ReadPixels(0, 0, int(fbSize[0]), int(fbSize[1]))
+	}
+
+	for _, req := range requests {
+		req.callback(img)
+	}
+}
+
+// wmCheckScreenshotKey checks whether the screenshot key function was
+// invoked, removing its key from the keyboard state if so; the
+// screenshot is taken in uiUpdateScreenshots.
+func wmCheckScreenshotKey(keyboard *KeyboardState) {
+	if keyboard != nil && keyboard.IsPressed(KeyF12) && keyboard.IsPressed(KeyControl) {
+		delete(keyboard.Pressed, KeyF12)
+		wm.takeScreenshot = true
+	}
+}
+
+// uiUpdateScreenshots takes a screenshot if the screenshot key was
+// pressed or if any of the events that are configured to trigger one
+// have happened.
+func uiUpdateScreenshots(eventStream *EventStream) {
+	if screenshots.events == nil {
+		screenshots.events = eventStream.Subscribe()
+	}
+	events := screenshots.events.Get()
+
+	if wm.takeScreenshot {
+		wm.takeScreenshot = false
+		TakeScreenshot(globalConfig.Screenshots.ScopeOnly, eventStream)
+		return
+	}
+
+	triggers := globalConfig.Screenshots.Triggers
+	if len(triggers) == 0 || time.Since(screenshots.lastTriggered) < time.Second {
+		return
+	}
+	for _, e := range events {
+		if slices.Contains(triggers, e.Type) {
+			screenshots.lastTriggered = time.Now()
+			TakeScreenshot(globalConfig.Screenshots.ScopeOnly, eventStream)
+			return
+		}
+	}
+}
+
+func uiDrawScreenshotSettings() {
+	if imgui.CollapsingHeader("Screenshots") {
+		sc := &globalConfig.Screenshots
+		imgui.Checkbox("Capture only the radar scope", &sc.ScopeOnly)
+		imgui.Text("Screenshots are saved in " + screenshotDirectory())
+		imgui.Text("Take screenshots automatically for:")
+		for t := EventType(0); t < NumEventTypes; t++ {
+			if t == StatusMessageEvent {
+				// Screenshots post status messages...
+				continue
+			}
+			enabled := slices.Contains(sc.Triggers, t)
+			if imgui.Checkbox(t.String(), &enabled) {
+				if enabled {
+					sc.Triggers = append(sc.Triggers, t)
+				} else {
+					sc.Triggers = slices.DeleteFunc(sc.Triggers, func(et EventType) bool { return et == t })
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,478 @@
+// script.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// This file implements a small scripting language that scenarios can use
+// to make things happen during a sim: conditional spawns, custom pilot
+// transmissions, timed runway changes, and so forth. Scripts are given as
+// a list of triggers in the scenario JSON, e.g.:
+//
+//	"triggers": [
+//	    { "when": "time >= 600 and arrivals >= 5",
+//	      "do": [ "message Runway change: departures now 31L",
+//	              "departure_rate KJFK 22R 0",
+//	              "departure_rate KJFK 31L 30" ] }
+//	]
+//
+// Conditions are boolean expressions using and, or, not, parentheses, and
+// the comparison operators <, <=, >, >=, ==, and !=. The available
+// variables are:
+//
+//	time:       seconds since the sim started
+//	aircraft:   number of aircraft currently in the sim
+//	airborne:   number of airborne aircraft
+//	tracked:    number of aircraft tracked by a human controller
+//	arrivals:   total arrivals launched
+//	departures: total departures launched
+//
+// along with those related to training that are described in training.go.
+//
+// The available actions are:
+//
+//	message <text>
+//	radio <callsign> <text>
+//	spawn_arrival <group> <airport>
+//	spawn_departure <airport> <runway> [category]
+//	departure_rate <airport> <runway> [category] <rate>
+//	arrival_rate <group> <airport> <rate>
+//	pause
+//	radar_fail <site>
+//	radar_restore <site>
+//
+// Scripts can only access the sim through these variables and actions.
+// By default, each trigger runs once, the first time its condition is
+// true; if "repeat" is given, the trigger runs again after the given
+// number of seconds if its condition is still true.
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type ScenarioTrigger struct {
+	When   string   `json:"when"`
+	Do     []string `json:"do"`
+	Repeat int      `json:"repeat,omitempty"` // seconds
+
+	cond    scriptExpr
+	actions []scriptAction
+}
+
+// ScriptState is the per-Sim state for running scenario triggers; it is
+// serialized with the Sim so that triggers don't fire again on resume.
+type ScriptState struct {
+	Start   time.Time
+	LastRun []time.Time // per trigger; zero if it hasn't run
+}
+
+// scriptEnv provides the values of the variables that conditions may
+// refer to.
+type scriptEnv map[string]float64
+
+///////////////////////////////////////////////////////////////////////////
+// Expressions
+
+type scriptExpr interface {
+	eval(env scriptEnv) float64
+}
+
+type scriptConst float64
+
+func (c scriptConst) eval(scriptEnv) float64 { return float64(c) }
+
+type scriptVar string
+
+func (v scriptVar) eval(env scriptEnv) float64 { return env[string(v)] }
+
+type scriptBinary struct {
+	op   string
+	a, b scriptExpr
+}
+
+func boolf(b bool) float64 { return Select(b, 1., 0.) }
+
+func (s scriptBinary) eval(env scriptEnv) float64 {
+	a := s.a.eval(env)
+	switch s.op {
+	case "and":
+		return boolf(a != 0 && s.b.eval(env) != 0)
+	case "or":
+		return boolf(a != 0 || s.b.eval(env) != 0)
+	}
+
+	b := s.b.eval(env)
+	switch s.op {
+	case "<":
+		return boolf(a < b)
+	case "<=":
+		return boolf(a <= b)
+	case ">":
+		return boolf(a > b)
+	case ">=":
+		return boolf(a >= b)
+	case "==":
+		return boolf(a == b)
+	case "!=":
+		return boolf(a != b)
+	default:
+		panic("unhandled script operator " + s.op)
+	}
+}
+
+type scriptNot struct{ e scriptExpr }
+
+func (n scriptNot) eval(env scriptEnv) float64 { return boolf(n.e.eval(env) == 0) }
+
+var scriptVariables = []string{"time", "aircraft", "airborne", "tracked", "arrivals", "departures",
+	"landed", "deals", "approaches_used", "cleared_approach", "established", "handoffs_accepted",
+	"handoffs_made"}
+
+func tokenizeScript(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		ch := rune(s[i])
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, string(ch))
+			i++
+		case strings.ContainsRune("<>=!", ch):
+			if i+1 < len(s) && s[i+1] == '=' {
+				tokens = append(tokens, s[i:i+2])
+				i += 2
+			} else if ch == '<' || ch == '>' {
+				tokens = append(tokens, string(ch))
+				i++
+			} else {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", ch, i)
+			}
+		case unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_' || ch == '.':
+			start := i
+			for i < len(s) && (unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i])) ||
+				s[i] == '_' || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, s[start:i])
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d", ch, i)
+		}
+	}
+	return tokens, nil
+}
+
+type scriptParser struct {
+	tokens []string
+	pos    int
+	vars   []string
+}
+
+func (p *scriptParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *scriptParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *scriptParser) parseOr() (scriptExpr, error) {
+	e, err := p.parseAnd()
+	for err == nil && p.peek() == "or" {
+		p.next()
+		var b scriptExpr
+		b, err = p.parseAnd()
+		e = scriptBinary{op: "or", a: e, b: b}
+	}
+	return e, err
+}
+
+func (p *scriptParser) parseAnd() (scriptExpr, error) {
+	e, err := p.parseUnary()
+	for err == nil && p.peek() == "and" {
+		p.next()
+		var b scriptExpr
+		b, err = p.parseUnary()
+		e = scriptBinary{op: "and", a: e, b: b}
+	}
+	return e, err
+}
+
+func (p *scriptParser) parseUnary() (scriptExpr, error) {
+	switch p.peek() {
+	case "not":
+		p.next()
+		e, err := p.parseUnary()
+		return scriptNot{e}, err
+	case "(":
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("expected \")\"")
+		}
+		return e, nil
+	default:
+		a, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		switch op := p.peek(); op {
+		case "<", "<=", ">", ">=", "==", "!=":
+			p.next()
+			b, err := p.parseOperand()
+			return scriptBinary{op: op, a: a, b: b}, err
+		default:
+			return a, nil
+		}
+	}
+}
+
+func (p *scriptParser) parseOperand() (scriptExpr, error) {
+	t := p.next()
+	if t == "" {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	if v, err := strconv.ParseFloat(t, 64); err == nil {
+		return scriptConst(v), nil
+	}
+	for _, v := range p.vars {
+		if t == v {
+			return scriptVar(t), nil
+		}
+	}
+	return nil, fmt.Errorf("%s: unknown variable", t)
+}
+
+func parseScriptExpr(s string) (scriptExpr, error) {
+	return parseScriptExprVars(s, scriptVariables)
+}
+
+// parseScriptExprVars parses an expression that may refer to the given
+// variables.
+func parseScriptExprVars(s string, vars []string) (scriptExpr, error) {
+	tokens, err := tokenizeScript(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &scriptParser{tokens: tokens, vars: vars}
+	e, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.peek())
+	}
+	return e, err
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Actions
+
+type scriptAction struct {
+	verb string
+	args []string
+}
+
+// scriptActionArgs gives the minimum and maximum number of arguments for
+// each action; -1 as the maximum indicates that the remainder of the line
+// is taken as the final argument.
+var scriptActionArgs = map[string][2]int{
+	"message":         {1, -1},
+	"radio":           {2, -1},
+	"spawn_arrival":   {2, 2},
+	"spawn_departure": {2, 3},
+	"departure_rate":  {3, 4},
+	"arrival_rate":    {3, 3},
+	"pause":           {0, 0},
+	"radar_fail":      {1, 1},
+	"radar_restore":   {1, 1},
+}
+
+func parseScriptAction(s string) (scriptAction, error) {
+	verb, rest, _ := strings.Cut(strings.TrimSpace(s), " ")
+	nargs, ok := scriptActionArgs[verb]
+	if !ok {
+		return scriptAction{}, fmt.Errorf("%s: unknown action", verb)
+	}
+
+	a := scriptAction{verb: verb}
+	if nargs[1] == -1 {
+		// Split off the leading arguments and then take the rest of the
+		// line as-is.
+		for len(a.args) < nargs[0]-1 {
+			var arg string
+			arg, rest, _ = strings.Cut(strings.TrimSpace(rest), " ")
+			a.args = append(a.args, arg)
+		}
+		if rest = strings.TrimSpace(rest); rest != "" {
+			a.args = append(a.args, rest)
+		}
+	} else {
+		a.args = strings.Fields(rest)
+	}
+
+	if len(a.args) < nargs[0] || (nargs[1] != -1 && len(a.args) > nargs[1]) {
+		return a, fmt.Errorf("%s: wrong number of arguments", verb)
+	}
+	if verb == "departure_rate" || verb == "arrival_rate" {
+		if _, err := strconv.Atoi(a.args[len(a.args)-1]); err != nil {
+			return a, fmt.Errorf("%s: invalid rate: %v", verb, err)
+		}
+	}
+	return a, nil
+}
+
+// Compile parses the trigger's condition and actions.
+func (t *ScenarioTrigger) Compile() error {
+	var err error
+	if t.cond, err = parseScriptExpr(t.When); err != nil {
+		return fmt.Errorf("\"%s\": %w", t.When, err)
+	}
+
+	t.actions = nil
+	for _, d := range t.Do {
+		if a, err := parseScriptAction(d); err != nil {
+			return fmt.Errorf("\"%s\": %w", d, err)
+		} else {
+			t.actions = append(t.actions, a)
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Sim integration
+
+func (s *Sim) scriptEnv() scriptEnv {
+	env := scriptEnv{
+		"time":       s.SimTime.Sub(s.ScriptState.Start).Seconds(),
+		"aircraft":   float64(len(s.World.Aircraft)),
+		"arrivals":   float64(s.TotalArrivals),
+		"departures": float64(s.TotalDepartures),
+
+		"landed":            float64(s.TrainingState.Landed),
+		"deals":             float64(s.TrainingState.Deals),
+		"approaches_used":   float64(len(s.TrainingState.ApproachesUsed)),
+		"handoffs_accepted": float64(s.TrainingState.HandoffsAccepted),
+		"handoffs_made":     float64(s.TrainingState.HandoffsMade),
+	}
+	for _, ac := range s.World.Aircraft {
+		if ac.IsAirborne() {
+			env["airborne"]++
+		}
+		if ap := ac.Nav.Approach; ap.Cleared {
+			env["cleared_approach"]++
+			if ap.InterceptState == HoldingLocalizer || ap.PassedApproachFix {
+				env["established"]++
+			}
+		}
+		if ctrl := s.World.GetControllerByCallsign(ac.TrackingController); ctrl != nil && ctrl.IsHuman {
+			env["tracked"]++
+		}
+	}
+	return env
+}
+
+// runTriggers evaluates the scenario's triggers and runs the actions of
+// those whose conditions are satisfied. It is called with s.mu held.
+func (s *Sim) runTriggers() {
+	if len(s.Triggers) == 0 {
+		return
+	}
+
+	if len(s.ScriptState.LastRun) != len(s.Triggers) {
+		s.ScriptState.LastRun = make([]time.Time, len(s.Triggers))
+	}
+
+	env := s.scriptEnv()
+	for i := range s.Triggers {
+		t := &s.Triggers[i]
+		if t.cond == nil {
+			// Compile lazily since the compiled form isn't serialized
+			// with the Sim; errors were already reported when the
+			// scenario was loaded.
+			if err := t.Compile(); err != nil {
+				continue
+			}
+		}
+
+		last := s.ScriptState.LastRun[i]
+		if !last.IsZero() && (t.Repeat == 0 || s.SimTime.Sub(last) < time.Duration(t.Repeat)*time.Second) {
+			continue
+		}
+
+		if t.cond.eval(env) != 0 {
+			s.lg.Info("running scenario trigger", slog.String("when", t.When))
+			s.ScriptState.LastRun[i] = s.SimTime
+			for _, a := range t.actions {
+				s.runScriptAction(a)
+			}
+		}
+	}
+}
+
+func (s *Sim) runScriptAction(a scriptAction) {
+	switch a.verb {
+	case "message":
+		s.eventStream.Post(Event{Type: StatusMessageEvent, Message: a.args[0]})
+
+	case "radio":
+		if ac, ok := s.World.Aircraft[a.args[0]]; ok {
+			PostRadioEvents(ac.Callsign, []RadioTransmission{RadioTransmission{
+				Controller: ac.ControllingController,
+				Message:    a.args[1],
+				Type:       RadioTransmissionUnexpected,
+			}}, s)
+		}
+
+	case "spawn_arrival":
+		goAround := rand.Float32() < s.LaunchConfig.GoAroundRate
+		if ac, err := s.World.CreateArrival(a.args[0], a.args[1], goAround); err != nil {
+			s.lg.Errorf("script spawn_arrival: %v", err)
+		} else if ac != nil {
+			s.launchAircraftNoLock(*ac)
+		}
+
+	case "spawn_departure":
+		category := Select(len(a.args) == 3, a.args[len(a.args)-1], "")
+		if ac, _, err := s.World.CreateDeparture(a.args[0], a.args[1], category,
+			s.LaunchConfig.DepartureChallenge, nil); err != nil {
+			s.lg.Errorf("script spawn_departure: %v", err)
+		} else {
+			s.launchAircraftNoLock(*ac)
+		}
+
+	case "departure_rate":
+		airport, runway := a.args[0], a.args[1]
+		category := Select(len(a.args) == 4, a.args[2], "")
+		rate, _ := strconv.Atoi(a.args[len(a.args)-1])
+		if err := s.setDepartureRate(airport, runway, category, rate); err != nil {
+			s.lg.Errorf("%s/%s: script departure_rate: %v", airport, runway, err)
+		}
+
+	case "arrival_rate":
+		group, airport := a.args[0], a.args[1]
+		rate, _ := strconv.Atoi(a.args[2])
+		if err := s.setArrivalRate(group, airport, rate); err != nil {
+			s.lg.Errorf("%s/%s: script arrival_rate: %v", group, airport, err)
+		}
+
+	case "pause":
+		s.Paused = true
+
+	case "radar_fail", "radar_restore":
+		if err := s.setRadarSiteFailed(a.args[0], a.verb == "radar_fail"); err != nil {
+			s.lg.Errorf("%s: script %s: %v", a.args[0], a.verb, err)
+		}
+	}
+}
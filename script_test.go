@@ -0,0 +1,66 @@
+// script_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestScriptExpr(t *testing.T) {
+	env := scriptEnv{"time": 600, "aircraft": 12, "arrivals": 4}
+
+	for _, test := range []struct {
+		expr     string
+		expected bool
+	}{
+		{"time >= 600", true},
+		{"time > 600", false},
+		{"time >= 600 and arrivals >= 5", false},
+		{"time >= 600 or arrivals >= 5", true},
+		{"not (aircraft < 10)", true},
+		{"(aircraft != 12 or time==600) and not arrivals == 3", true},
+		{"departures", false},
+	} {
+		e, err := parseScriptExpr(test.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.expr, err)
+			continue
+		}
+		if v := e.eval(env) != 0; v != test.expected {
+			t.Errorf("%s: got %v, expected %v", test.expr, v, test.expected)
+		}
+	}
+
+	for _, bad := range []string{"time >", "foo > 3", "(time > 3", "time = 3", "time > 3 4"} {
+		if _, err := parseScriptExpr(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestScriptAction(t *testing.T) {
+	a, err := parseScriptAction("radio AAL123 unable, need to return to the field")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.args) != 2 || a.args[0] != "AAL123" || a.args[1] != "unable, need to return to the field" {
+		t.Errorf("unexpected args %q", a.args)
+	}
+
+	if a, err = parseScriptAction("departure_rate KJFK 31L 30"); err != nil || len(a.args) != 3 {
+		t.Errorf("unexpected result %+v / %v", a, err)
+	}
+
+	if a, err = parseScriptAction("radar_fail JFK"); err != nil || len(a.args) != 1 || a.args[0] != "JFK" {
+		t.Errorf("unexpected result %+v / %v", a, err)
+	}
+
+	for _, bad := range []string{"launch_rockets", "pause now", "arrival_rate KJFK", "departure_rate KJFK 31L many",
+		"radar_restore", "radar_fail JFK EWR"} {
+		if _, err := parseScriptAction(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
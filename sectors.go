@@ -0,0 +1,213 @@
+// sectors.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Sectors: scenario groups may divide their airspace into sectors, each
+// made of one or more of the airspace volumes (lateral boundaries with
+// altitude strata) and owned by a control position. For example:
+//
+//	"sectors": {
+//	    "ROBER": { "controller": "2K", "volumes": [ "ROBER_LOW", "ROBER_HIGH" ] },
+//	    "CAMRN": { "controller": "4P", "volumes": [ "CAMRN" ] }
+//	}
+//
+// When positions are consolidated, a sector is owned by whoever is
+// covering its position. Once a second, the sim finds which sector each
+// aircraft is in and uses that to manage the handoffs between
+// controllers:
+//
+//   - A virtual controller offers the handoff to a human controller
+//     shortly before one of its aircraft is predicted to enter the human
+//     controller's sector (see trajectory.go).
+//   - Virtual controllers hand off and switch aircraft to each other as
+//     they cross the boundaries between their sectors.
+//   - Human controllers are alerted when an untracked IFR aircraft enters
+//     their sector.
+//
+// Scenario groups without sectors are unaffected; handoffs then happen
+// at waypoints as specified in the routes.
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// How far ahead aircraft are checked for entering a human controller's
+// sector when deciding whether to offer a handoff.
+const sectorHandoffLead = 45 * time.Second
+
+type Sector struct {
+	Controller  string                     `json:"controller"`
+	VolumeNames []string                   `json:"volumes"`
+	Volumes     []ControllerAirspaceVolume `json:"airspace_volumes,omitempty"` // not in JSON
+}
+
+func (sec *Sector) PostDeserialize(sg *ScenarioGroup, e *ErrorLogger) {
+	if sec.Controller == "" {
+		e.ErrorString("must specify \"controller\"")
+	} else if _, ok := sg.ControlPositions[sec.Controller]; !ok {
+		e.ErrorString("controller \"%s\" not found", sec.Controller)
+	}
+
+	if len(sec.VolumeNames) == 0 {
+		e.ErrorString("must specify at least one of the airspace \"volumes\"")
+	}
+	for _, name := range sec.VolumeNames {
+		if vols, ok := sg.Airspace.Volumes[name]; !ok {
+			e.ErrorString("airspace volume \"%s\" not found", name)
+		} else {
+			sec.Volumes = append(sec.Volumes, vols...)
+		}
+	}
+}
+
+// Inside returns true if the given point is inside one of the sector's
+// volumes.
+func (sec *Sector) Inside(p Point2LL, alt float32) bool {
+	for _, v := range sec.Volumes {
+		// 10 feet of slop for rounding error, as in InAirspace.
+		if int(alt)+10 < v.LowerLimit || int(alt)-10 > v.UpperLimit {
+			continue
+		}
+		inside := false
+		for _, pts := range v.Boundaries {
+			if PointInPolygon2LL(p, pts) {
+				inside = !inside
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
+
+// SectorAt returns the name of the sector that includes the given point
+// or an empty string if there is none. If sectors overlap, the first one
+// in alphabetical order is returned.
+func (w *World) SectorAt(p Point2LL, alt float32) string {
+	for _, name := range SortedMapKeys(w.Sectors) {
+		if w.Sectors[name].Inside(p, alt) {
+			return name
+		}
+	}
+	return ""
+}
+
+// sectorOwner returns the callsign of the controller currently working
+// the given sector.
+func (s *Sim) sectorOwner(sector string) string {
+	sec, ok := s.World.Sectors[sector]
+	if !ok {
+		return ""
+	}
+	return s.workingController(sec.Controller)
+}
+
+// updateSectors is called once a second with s.mu held; it updates the
+// aircraft's sectors and handles the consequences.
+func (s *Sim) updateSectors(now time.Time) {
+	if len(s.World.Sectors) == 0 {
+		return
+	}
+
+	var aircraft []*Aircraft
+	for _, ac := range s.World.Aircraft {
+		if ac.IsAirborne() {
+			aircraft = append(aircraft, ac)
+		}
+	}
+	sort.Slice(aircraft, func(i, j int) bool { return aircraft[i].Callsign < aircraft[j].Callsign })
+
+	for callsign := range s.sectorHandoffs {
+		if _, ok := s.World.Aircraft[callsign]; !ok {
+			delete(s.sectorHandoffs, callsign)
+		}
+	}
+
+	for _, ac := range aircraft {
+		if sector := s.World.SectorAt(ac.Position(), ac.Altitude()); sector != ac.Sector {
+			ac.Sector = sector
+			if sector != "" {
+				s.enteredSector(ac, sector)
+			}
+		}
+		s.checkSectorHandoff(ac, now)
+	}
+}
+
+func (s *Sim) enteredSector(ac *Aircraft, sector string) {
+	owner := s.sectorOwner(sector)
+	if owner == "" {
+		return
+	}
+
+	if s.controllerIsSignedIn(owner) {
+		if ac.TrackingController == "" && ac.FlightPlan != nil && ac.FlightPlan.Rules == IFR {
+			s.lg.Info("untracked aircraft entered sector", slog.String("callsign", ac.Callsign),
+				slog.String("sector", sector), slog.String("controller", owner))
+			s.eventStream.Post(Event{
+				Type:         UntrackedInSectorEvent,
+				Callsign:     ac.Callsign,
+				ToController: owner,
+				Message:      fmt.Sprintf("%s entered sector %s untracked", ac.Callsign, sector),
+			})
+		}
+		return
+	}
+
+	// Virtual controllers work the aircraft in their sectors; if one
+	// comes from another virtual controller, its track and
+	// communications are transferred at the boundary.
+	from := ac.TrackingController
+	if from != "" && from != owner && ac.ControllingController == from && ac.HandoffTrackController == "" &&
+		!s.controllerIsSignedIn(from) {
+		s.lg.Info("virtual sector handoff", slog.String("callsign", ac.Callsign),
+			slog.String("sector", sector), slog.String("from", from), slog.String("to", owner))
+		ac.TrackingController = owner
+		ac.ControllingController = owner
+	}
+}
+
+// checkSectorHandoff offers a handoff from a virtual controller to a human
+// controller if the aircraft will soon be in the human controller's
+// sector.
+func (s *Sim) checkSectorHandoff(ac *Aircraft, now time.Time) {
+	from := ac.TrackingController
+	if from == "" || ac.HandoffTrackController != "" || ac.ControllingController != from ||
+		s.controllerIsSignedIn(from) {
+		return
+	}
+
+	p, alt := ac.Position(), ac.Altitude()
+	if tp, ok := ac.Trajectory.At(now.Add(sectorHandoffLead)); ok {
+		p, alt = tp.Position, tp.Altitude
+	}
+	sector := s.World.SectorAt(p, alt)
+	owner := s.sectorOwner(sector)
+	if owner == "" || owner == from || !s.controllerIsSignedIn(owner) {
+		return
+	}
+	if s.sectorHandoffs[ac.Callsign] == owner {
+		// Already offered once; don't keep offering it if it was rejected.
+		return
+	}
+
+	s.lg.Info("sector handoff offered", slog.String("callsign", ac.Callsign),
+		slog.String("sector", sector), slog.String("from", from), slog.String("to", owner))
+	if s.sectorHandoffs == nil {
+		s.sectorHandoffs = make(map[string]string)
+	}
+	s.sectorHandoffs[ac.Callsign] = owner
+	ac.HandoffTrackController = owner
+	s.eventStream.Post(Event{
+		Type:           OfferedHandoffEvent,
+		Callsign:       ac.Callsign,
+		FromController: from,
+		ToController:   owner,
+	})
+}
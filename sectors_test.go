@@ -0,0 +1,62 @@
+// sectors_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestSectorAt(t *testing.T) {
+	square := func(x0, y0, x1, y1 float32) [][]Point2LL {
+		return [][]Point2LL{{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}}
+	}
+
+	w := &World{
+		Sectors: map[string]*Sector{
+			// Low and high strata over the same area, split at 10,000'.
+			"EAST_LOW": {Controller: "2K", Volumes: []ControllerAirspaceVolume{
+				{LowerLimit: 0, UpperLimit: 9999, Boundaries: square(-74, 40, -73, 41)}}},
+			"EAST_HIGH": {Controller: "N56", Volumes: []ControllerAirspaceVolume{
+				{LowerLimit: 10000, UpperLimit: 24000, Boundaries: square(-74, 40, -73, 41)}}},
+			// Two volumes, one with a hole in it.
+			"WEST": {Controller: "4P", Volumes: []ControllerAirspaceVolume{
+				{LowerLimit: 0, UpperLimit: 8000, Boundaries: append(square(-76, 40, -74, 41),
+					square(-75.5, 40.25, -75, 40.75)...)},
+				{LowerLimit: 2000, UpperLimit: 5000, Boundaries: square(-75.4, 40.4, -75.1, 40.6)}}},
+		},
+	}
+
+	for _, test := range []struct {
+		p      Point2LL
+		alt    float32
+		sector string
+	}{
+		{Point2LL{-73.5, 40.5}, 5000, "EAST_LOW"},
+		{Point2LL{-73.5, 40.5}, 15000, "EAST_HIGH"},
+		{Point2LL{-73.5, 40.5}, 30000, ""},
+		{Point2LL{-75.8, 40.5}, 5000, "WEST"},
+		{Point2LL{-75.45, 40.5}, 5000, ""},     // in the hole
+		{Point2LL{-75.25, 40.5}, 3000, "WEST"}, // in the hole, but inside the second volume
+		{Point2LL{-75.25, 40.5}, 6000, ""},
+		{Point2LL{-72, 40.5}, 5000, ""},
+	} {
+		if sector := w.SectorAt(test.p, test.alt); sector != test.sector {
+			t.Errorf("%v at %.0f: got sector %q, expected %q", test.p, test.alt, sector, test.sector)
+		}
+	}
+
+	// Virtual controllers work their own sectors, while human positions
+	// may be consolidated.
+	s := &Sim{
+		World:           w,
+		SignOnPositions: map[string]*Controller{"2K": {Callsign: "2K"}, "4P": {Callsign: "4P"}},
+	}
+	w.PrimaryController = "4P"
+	for sector, owner := range map[string]string{"EAST_LOW": "4P", "EAST_HIGH": "N56", "WEST": "4P", "NONE": ""} {
+		if o := s.sectorOwner(sector); o != owner {
+			t.Errorf("%s: got owner %q, expected %q", sector, o, owner)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/gob"
 	"fmt"
 	"html/template"
 	"io"
@@ -18,14 +19,14 @@ import (
 	"os/exec"
 	"runtime"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/shirou/gopsutil/cpu"
 )
 
-const ViceRPCVersion = 13
+const ViceRPCVersion = 14
 
 type SimServer struct {
 	*RPCClient
@@ -59,6 +60,10 @@ func (s *SimProxy) TogglePause() *rpc.Call {
 	return s.Client.Go("Sim.TogglePause", s.ControllerToken, nil, nil)
 }
 
+func (s *SimProxy) AdvanceTutorial() *rpc.Call {
+	return s.Client.Go("Sim.AdvanceTutorial", s.ControllerToken, nil, nil)
+}
+
 func (s *SimProxy) SignOff(_, _ *struct{}) error {
 	if err := s.Client.CallWithTimeout("Sim.SignOff", s.ControllerToken, nil); err != nil {
 		return err
@@ -77,6 +82,17 @@ func (s *SimProxy) ChangeControlPosition(callsign string, keepTracks bool) error
 		}, nil)
 }
 
+func (s *SimProxy) RequestRelief(callsign string) *rpc.Call {
+	return s.Client.Go("Sim.RequestRelief", &RequestReliefArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+	}, nil, nil)
+}
+
+func (s *SimProxy) AcceptRelief() *rpc.Call {
+	return s.Client.Go("Sim.AcceptRelief", s.ControllerToken, nil, nil)
+}
+
 func (s *SimProxy) GetSerializeSim() (*Sim, error) {
 	var sim Sim
 	err := s.Client.CallWithTimeout("SimManager.GetSerializeSim", s.ControllerToken, &sim)
@@ -103,6 +119,36 @@ func (s *SimProxy) SetLaunchConfig(lc LaunchConfig) *rpc.Call {
 		}, nil, nil)
 }
 
+func (s *SimProxy) SetRadarSiteFailed(site string, failed bool) *rpc.Call {
+	return s.Client.Go("Sim.SetRadarSiteFailed",
+		&SetRadarSiteFailedArgs{
+			ControllerToken: s.ControllerToken,
+			Site:            site,
+			Failed:          failed,
+		}, nil, nil)
+}
+
+func (s *SimProxy) SetArrivalRate(group, airport string, rate int) *rpc.Call {
+	return s.Client.Go("Sim.SetArrivalRate",
+		&SetArrivalRateArgs{
+			ControllerToken: s.ControllerToken,
+			Group:           group,
+			Airport:         airport,
+			Rate:            rate,
+		}, nil, nil)
+}
+
+func (s *SimProxy) SetDepartureRate(airport, runway, category string, rate int) *rpc.Call {
+	return s.Client.Go("Sim.SetDepartureRate",
+		&SetDepartureRateArgs{
+			ControllerToken: s.ControllerToken,
+			Airport:         airport,
+			Runway:          runway,
+			Category:        category,
+			Rate:            rate,
+		}, nil, nil)
+}
+
 func (s *SimProxy) TakeOrReturnLaunchControl() *rpc.Call {
 	return s.Client.Go("Sim.TakeOrReturnLaunchControl", s.ControllerToken, nil, nil)
 }
@@ -131,6 +177,14 @@ func (s *SimProxy) SetSecondaryScratchpad(callsign string, scratchpad string) *r
 	}, nil, nil)
 }
 
+func (s *SimProxy) AmendFlightPlan(callsign string, fp FlightPlan) *rpc.Call {
+	return s.Client.Go("Sim.AmendFlightPlan", &AmendFlightPlanArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+		FlightPlan:      fp,
+	}, nil, nil)
+}
+
 func (s *SimProxy) InitiateTrack(callsign string) *rpc.Call {
 	return s.Client.Go("Sim.InitiateTrack", &InitiateTrackArgs{
 		ControllerToken: s.ControllerToken,
@@ -167,6 +221,21 @@ func (s *SimProxy) CancelHandoff(callsign string) *rpc.Call {
 	}, nil, nil)
 }
 
+func (s *SimProxy) SetSquawk(callsign string, squawk Squawk) *rpc.Call {
+	return s.Client.Go("Sim.SetSquawk", &SquawkArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+		Squawk:          squawk,
+	}, nil, nil)
+}
+
+func (s *SimProxy) SetSquawkAutomatic(callsign string) *rpc.Call {
+	return s.Client.Go("Sim.SetSquawkAutomatic", &SetSquawkAutomaticArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+	}, nil, nil)
+}
+
 func (s *SimProxy) GlobalMessage(global GlobalMessage) *rpc.Call {
 	return s.Client.Go("Sim.GlobalMessage", &GlobalMessageArgs{
 		ControllerToken: s.ControllerToken,
@@ -251,6 +320,49 @@ func (s *SimProxy) DeleteAircraft(callsign string) *rpc.Call {
 	}, nil, nil)
 }
 
+func (s *SimProxy) EditAircraft(callsign string, edit AircraftEdit) *rpc.Call {
+	return s.Client.Go("Sim.EditAircraft", &EditAircraftArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+		Edit:            edit,
+	}, nil, nil)
+}
+
+func (s *SimProxy) ProbeTrialPlan(callsign string, plan TrialPlan, result *[]ProbeConflict) *rpc.Call {
+	return s.Client.Go("Sim.ProbeTrialPlan", &ProbeTrialPlanArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+		Plan:            plan,
+	}, result, nil)
+}
+
+func (s *SimProxy) SaveSituation(name string) error {
+	return s.Client.CallWithTimeout("Sim.SaveSituation", &SituationArgs{
+		ControllerToken: s.ControllerToken,
+		Name:            name,
+	}, nil)
+}
+
+func (s *SimProxy) RestoreSituation(name string) error {
+	return s.Client.CallWithTimeout("Sim.RestoreSituation", &SituationArgs{
+		ControllerToken: s.ControllerToken,
+		Name:            name,
+	}, nil)
+}
+
+func (s *SimProxy) DeleteSituation(name string) error {
+	return s.Client.CallWithTimeout("Sim.DeleteSituation", &SituationArgs{
+		ControllerToken: s.ControllerToken,
+		Name:            name,
+	}, nil)
+}
+
+func (s *SimProxy) ListSituations() ([]SituationInfo, error) {
+	var situations []SituationInfo
+	err := s.Client.CallWithTimeout("Sim.ListSituations", s.ControllerToken, &situations)
+	return situations, err
+}
+
 func (s *SimProxy) RunAircraftCommands(callsign string, cmds string, result *AircraftCommandsResult) *rpc.Call {
 	return s.Client.Go("Sim.RunAircraftCommands", &AircraftCommandsArgs{
 		ControllerToken: s.ControllerToken,
@@ -274,7 +386,8 @@ type SimManager struct {
 	configs              map[string]map[string]*SimConfiguration
 	activeSims           map[string]*Sim
 	controllerTokenToSim map[string]*Sim
-	mu                   LoggingMutex
+	mu                   LoggingRWMutex
+	reloadMu             sync.Mutex // see serverreload.go
 	startTime            time.Time
 	lg                   *Logger
 }
@@ -287,7 +400,7 @@ func NewSimManager(scenarioGroups map[string]map[string]*ScenarioGroup,
 		activeSims:           make(map[string]*Sim),
 		controllerTokenToSim: make(map[string]*Sim),
 		startTime:            time.Now(),
-		lg:                   lg,
+		lg:                   lg.Subsystem("net"),
 	}
 
 	return sm
@@ -300,7 +413,15 @@ type NewSimResult struct {
 
 func (sm *SimManager) New(config *NewSimConfiguration, result *NewSimResult) error {
 	if config.NewSimType == NewSimCreateLocal || config.NewSimType == NewSimCreateRemote {
-		sim := NewSim(*config, sm.scenarioGroups, config.NewSimType == NewSimCreateLocal, sm.lg)
+		// The scenarios may be reloaded at any time; see serverreload.go.
+		sm.mu.RLock(sm.lg)
+		scenarioGroups := sm.scenarioGroups
+		sm.mu.RUnlock(sm.lg)
+
+		sim := NewSim(*config, scenarioGroups, config.NewSimType == NewSimCreateLocal, sm.lg)
+		if ctrl, ok := sim.SignOnPositions[sim.World.PrimaryController]; ok {
+			ctrl.Initials = config.Initials
+		}
 		sim.prespawn()
 		return sm.Add(sim, result)
 	} else {
@@ -319,7 +440,7 @@ func (sm *SimManager) New(config *NewSimConfiguration, result *NewSimResult) err
 			return ErrInvalidPassword
 		}
 
-		world, token, err := sim.SignOn(config.SelectedRemoteSimPosition)
+		world, token, err := sim.SignOn(config.SelectedRemoteSimPosition, config.Initials)
 		if err != nil {
 			return err
 		}
@@ -350,7 +471,7 @@ func (sm *SimManager) Add(sim *Sim, result *NewSimResult) error {
 
 	sm.mu.Unlock(sm.lg)
 
-	world, token, err := sim.SignOn(sim.World.PrimaryController)
+	world, token, err := sim.SignOn(sim.World.PrimaryController, "")
 	if err != nil {
 		return err
 	}
@@ -411,12 +532,12 @@ func (sm *SimManager) SignOn(version int, result *SignOnResult) error {
 }
 
 func (sm *SimManager) GetRunningSims(_ int, result *map[string]*RemoteSim) error {
-	sm.mu.Lock(lg)
-	defer sm.mu.Unlock(sm.lg)
+	sm.mu.RLock(lg)
+	defer sm.mu.RUnlock(sm.lg)
 
 	running := make(map[string]*RemoteSim)
 	for name, s := range sm.activeSims {
-		s.mu.Lock(s.lg)
+		s.mu.RLock(s.lg)
 		rs := &RemoteSim{
 			GroupName:          s.ScenarioGroup,
 			ScenarioName:       s.Scenario,
@@ -438,7 +559,7 @@ func (sm *SimManager) GetRunningSims(_ int, result *map[string]*RemoteSim) error
 				rs.CoveredPositions[ctrl.Callsign] = struct{}{}
 			}
 		}
-		s.mu.Unlock(s.lg)
+		s.mu.RUnlock(s.lg)
 
 		running[name] = rs
 	}
@@ -450,12 +571,15 @@ func (sm *SimManager) GetRunningSims(_ int, result *map[string]*RemoteSim) error
 const simIdleLimit = 4 * time.Hour
 
 func (sm *SimManager) SimShouldExit(sim *Sim) bool {
+	if sim.ExitWhenEmpty && !sim.hasControllers() {
+		return true
+	}
 	if sim.IdleTime() < simIdleLimit {
 		return false
 	}
 
-	sm.mu.Lock(lg)
-	defer sm.mu.Unlock(sm.lg)
+	sm.mu.RLock(lg)
+	defer sm.mu.RUnlock(sm.lg)
 
 	nIdle := 0
 	for _, sim := range sm.activeSims {
@@ -467,8 +591,8 @@ func (sm *SimManager) SimShouldExit(sim *Sim) bool {
 }
 
 func (sm *SimManager) GetSerializeSim(token string, s *Sim) error {
-	sm.mu.Lock(lg)
-	defer sm.mu.Unlock(sm.lg)
+	sm.mu.RLock(lg)
+	defer sm.mu.RUnlock(sm.lg)
 
 	if sm.controllerTokenToSim == nil {
 		return ErrNoSimForControllerToken
@@ -477,13 +601,23 @@ func (sm *SimManager) GetSerializeSim(token string, s *Sim) error {
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
-	*s = *sim
-	return nil
+
+	// Make a deep copy while holding the Sim's lock so that the snapshot
+	// is consistent; the copy is then serialized for the RPC result
+	// without holding up the simulation.
+	sim.mu.RLock(sm.lg)
+	defer sim.mu.RUnlock(sm.lg)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(sim); err != nil {
+		return err
+	}
+	return gob.NewDecoder(&buf).Decode(s)
 }
 
 func (sm *SimManager) ControllerTokenToSim(token string) (*Sim, bool) {
-	sm.mu.Lock(lg)
-	defer sm.mu.Unlock(sm.lg)
+	sm.mu.RLock(lg)
+	defer sm.mu.RUnlock(sm.lg)
 
 	sim, ok := sm.controllerTokenToSim[token]
 	return sim, ok
@@ -509,8 +643,8 @@ func (ss SimStatus) LogValue() slog.Value {
 }
 
 func (sm *SimManager) GetSimStatus() []SimStatus {
-	sm.mu.Lock(lg)
-	defer sm.mu.Unlock(sm.lg)
+	sm.mu.RLock(lg)
+	defer sm.mu.RUnlock(sm.lg)
 
 	var ss []SimStatus
 	for _, name := range SortedMapKeys(sm.activeSims) {
@@ -536,22 +670,46 @@ func (sm *SimManager) GetSimStatus() []SimStatus {
 	return ss
 }
 
+// ServerResourceUsage is returned by SimManager.GetResourceUsage so that
+// -loadtest can monitor the server; memory is in MB.
+type ServerResourceUsage struct {
+	CPUUsage      int // percent, since the previous call
+	AllocMemory   uint64
+	SysMemory     uint64
+	NumGoRoutines int
+	NumSims       int
+}
+
+func (sm *SimManager) GetResourceUsage(_ int, result *ServerResourceUsage) error {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	*result = ServerResourceUsage{
+		AllocMemory:   m.Alloc / (1024 * 1024),
+		SysMemory:     m.Sys / (1024 * 1024),
+		NumGoRoutines: runtime.NumGoroutine(),
+	}
+	if usage, err := cpu.Percent(0, false); err == nil && len(usage) > 0 {
+		result.CPUUsage = int(math.Round(usage[0]))
+	}
+
+	sm.mu.RLock(sm.lg)
+	result.NumSims = len(sm.activeSims)
+	sm.mu.RUnlock(sm.lg)
+
+	return nil
+}
+
 type SimBroadcastMessage struct {
 	Password string
 	Message  string
 }
 
 func (sm *SimManager) Broadcast(m *SimBroadcastMessage, _ *struct{}) error {
-	pw, err := os.ReadFile("password")
-	if err != nil {
+	if err := checkAdminPassword(m.Password); err != nil {
 		return err
 	}
 
-	password := strings.TrimRight(string(pw), "\n\r")
-	if password != m.Password {
-		return ErrInvalidPassword
-	}
-
 	sm.mu.Lock(lg)
 	defer sm.mu.Unlock(sm.lg)
 
@@ -624,6 +782,27 @@ func (sd *SimDispatcher) ChangeControlPosition(cs *ChangeControlPositionArgs, _
 	}
 }
 
+type RequestReliefArgs struct {
+	ControllerToken string
+	Callsign        string
+}
+
+func (sd *SimDispatcher) RequestRelief(rr *RequestReliefArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.ControllerTokenToSim(rr.ControllerToken); !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.RequestRelief(rr.ControllerToken, rr.Callsign)
+	}
+}
+
+func (sd *SimDispatcher) AcceptRelief(token string, _ *struct{}) error {
+	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.AcceptRelief(token)
+	}
+}
+
 func (sd *SimDispatcher) TakeOrReturnLaunchControl(token string, _ *struct{}) error {
 	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
 		return ErrNoSimForControllerToken
@@ -658,6 +837,51 @@ func (sd *SimDispatcher) SetLaunchConfig(lc *SetLaunchConfigArgs, _ *struct{}) e
 	}
 }
 
+type SetRadarSiteFailedArgs struct {
+	ControllerToken string
+	Site            string
+	Failed          bool
+}
+
+func (sd *SimDispatcher) SetRadarSiteFailed(a *SetRadarSiteFailedArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.ControllerTokenToSim(a.ControllerToken); !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.SetRadarSiteFailed(a.ControllerToken, a.Site, a.Failed)
+	}
+}
+
+type SetArrivalRateArgs struct {
+	ControllerToken string
+	Group           string
+	Airport         string
+	Rate            int
+}
+
+func (sd *SimDispatcher) SetArrivalRate(a *SetArrivalRateArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.ControllerTokenToSim(a.ControllerToken); !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.SetArrivalRate(a.ControllerToken, a.Group, a.Airport, a.Rate)
+	}
+}
+
+type SetDepartureRateArgs struct {
+	ControllerToken string
+	Airport         string
+	Runway          string
+	Category        string
+	Rate            int
+}
+
+func (sd *SimDispatcher) SetDepartureRate(a *SetDepartureRateArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.ControllerTokenToSim(a.ControllerToken); !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.SetDepartureRate(a.ControllerToken, a.Airport, a.Runway, a.Category, a.Rate)
+	}
+}
+
 func (sd *SimDispatcher) TogglePause(token string, _ *struct{}) error {
 	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
 		return ErrNoSimForControllerToken
@@ -666,6 +890,14 @@ func (sd *SimDispatcher) TogglePause(token string, _ *struct{}) error {
 	}
 }
 
+func (sd *SimDispatcher) AdvanceTutorial(token string, _ *struct{}) error {
+	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.AdvanceTutorial(token)
+	}
+}
+
 type SetScratchpadArgs struct {
 	ControllerToken string
 	Callsign        string
@@ -688,6 +920,20 @@ func (sd *SimDispatcher) SetSecondaryScratchpad(a *SetScratchpadArgs, _ *struct{
 	}
 }
 
+type AmendFlightPlanArgs struct {
+	ControllerToken string
+	Callsign        string
+	FlightPlan      FlightPlan
+}
+
+func (sd *SimDispatcher) AmendFlightPlan(a *AmendFlightPlanArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.controllerTokenToSim[a.ControllerToken]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.AmendFlightPlan(a.ControllerToken, a.Callsign, a.FlightPlan)
+	}
+}
+
 type SetGlobalLeaderLineArgs struct {
 	ControllerToken string
 	Callsign        string
@@ -772,6 +1018,30 @@ func (sd *SimDispatcher) CancelHandoff(ch *CancelHandoffArgs, _ *struct{}) error
 	}
 }
 
+type SquawkArgs struct {
+	ControllerToken string
+	Callsign        string
+	Squawk          Squawk
+}
+
+func (sd *SimDispatcher) SetSquawk(sq *SquawkArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.controllerTokenToSim[sq.ControllerToken]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.SetSquawk(sq.ControllerToken, sq.Callsign, sq.Squawk)
+	}
+}
+
+type SetSquawkAutomaticArgs AircraftSpecifier
+
+func (sd *SimDispatcher) SetSquawkAutomatic(sq *SetSquawkAutomaticArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.controllerTokenToSim[sq.ControllerToken]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.SetSquawkAutomatic(sq.ControllerToken, sq.Callsign)
+	}
+}
+
 type PointOutArgs struct {
 	ControllerToken string
 	Callsign        string
@@ -874,6 +1144,71 @@ func (sd *SimDispatcher) DeleteAircraft(da *DeleteAircraftArgs, _ *struct{}) err
 	}
 }
 
+type EditAircraftArgs struct {
+	ControllerToken string
+	Callsign        string
+	Edit            AircraftEdit
+}
+
+func (sd *SimDispatcher) EditAircraft(ea *EditAircraftArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.controllerTokenToSim[ea.ControllerToken]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.EditAircraft(ea.ControllerToken, ea.Callsign, ea.Edit)
+	}
+}
+
+type ProbeTrialPlanArgs struct {
+	ControllerToken string
+	Callsign        string
+	Plan            TrialPlan
+}
+
+func (sd *SimDispatcher) ProbeTrialPlan(pa *ProbeTrialPlanArgs, result *[]ProbeConflict) error {
+	if sim, ok := sd.sm.controllerTokenToSim[pa.ControllerToken]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.ProbeTrialPlan(pa.ControllerToken, pa.Callsign, pa.Plan, result)
+	}
+}
+
+type SituationArgs struct {
+	ControllerToken string
+	Name            string
+}
+
+func (sd *SimDispatcher) SaveSituation(sa *SituationArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.controllerTokenToSim[sa.ControllerToken]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.SaveSituation(sa.ControllerToken, sa.Name)
+	}
+}
+
+func (sd *SimDispatcher) RestoreSituation(sa *SituationArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.controllerTokenToSim[sa.ControllerToken]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.RestoreSituation(sa.ControllerToken, sa.Name)
+	}
+}
+
+func (sd *SimDispatcher) DeleteSituation(sa *SituationArgs, _ *struct{}) error {
+	if sim, ok := sd.sm.controllerTokenToSim[sa.ControllerToken]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.DeleteSituation(sa.ControllerToken, sa.Name)
+	}
+}
+
+func (sd *SimDispatcher) ListSituations(token string, result *[]SituationInfo) error {
+	if sim, ok := sd.sm.controllerTokenToSim[token]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.ListSituations(token, result)
+	}
+}
+
 type AircraftCommandsArgs struct {
 	ControllerToken string
 	Callsign        string
@@ -894,377 +1229,54 @@ func (sd *SimDispatcher) RunAircraftCommands(cmds *AircraftCommandsArgs, result
 		return ErrNoSimForControllerToken
 	}
 
+	defer sim.journalCommands(token, callsign, cmds.Commands, result)
+
 	commands := strings.Fields(cmds.Commands)
+	locate := func(fix string) bool {
+		_, ok := sim.World.Locate(fix)
+		return ok
+	}
 
+	offset := 0 // of the current command in cmds.Commands
 	for i, command := range commands {
+		offset += strings.Index(cmds.Commands[offset:], command)
+
 		rewriteError := func(err error) {
 			result.RemainingInput = strings.Join(commands[i:], " ")
 
+			if perr, ok := err.(*CommandParseError); ok {
+				// Report the position in the commands as given.
+				result.ErrorMessage = (&CommandParseError{Pos: offset + perr.Pos, Msg: perr.Msg}).Error()
+				return
+			}
+
 			switch err {
 			case nil:
 				//
 			case ErrOtherControllerHasTrack:
 				result.ErrorMessage = "Another controller is controlling this aircraft's"
+			case ErrNotOnFrequency:
+				result.ErrorMessage = "Aircraft is not on your frequency"
+			case ErrNoResponse:
+				result.ErrorMessage = "No response"
 			default:
 				result.ErrorMessage = "Invalid or unknown command"
 			}
 		}
 
-		switch command[0] {
-		case 'A', 'C':
-			if command == "CAC" {
-				// Cancel approach clearance
-				if err := sim.CancelApproachClearance(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if command == "CVS" {
-				if err := sim.ClimbViaSID(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if len(command) > 4 && command[:3] == "CSI" && !isAllNumbers(command[3:]) {
-				// Cleared straight in approach.
-				if err := sim.ClearedApproach(token, callsign, command[3:], true); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if command[0] == 'C' && len(command) > 2 && !isAllNumbers(command[1:]) {
-				if components := strings.Split(command, "/"); len(components) > 1 {
-					// Cross fix [at altitude] [at speed]
-					fix := components[0][1:]
-					var ar *AltitudeRestriction
-					speed := 0
-
-					for _, cmd := range components[1:] {
-						if len(cmd) == 0 {
-							rewriteError(ErrInvalidCommandSyntax)
-							return nil
-						}
-
-						var err error
-						if cmd[0] == 'A' && len(cmd) > 1 {
-							if ar, err = ParseAltitudeRestriction(cmd[1:]); err != nil {
-								rewriteError(err)
-								return nil
-							}
-							// User input here is 100s of feet, while AltitudeRestriction is feet...
-							ar.Range[0] *= 100
-							ar.Range[1] *= 100
-						} else if cmd[0] == 'S' {
-							if speed, err = strconv.Atoi(cmd[1:]); err != nil {
-								rewriteError(err)
-								return nil
-							}
-						} else {
-							rewriteError(ErrInvalidCommandSyntax)
-							return nil
-						}
-					}
-
-					if err := sim.CrossFixAt(token, callsign, fix, ar, speed); err != nil {
-						rewriteError(err)
-						return nil
-					}
-				} else if err := sim.ClearedApproach(token, callsign, command[1:], false); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else {
-				if command[0] == 'A' {
-					components := strings.Split(command, "/")
-					if len(components) != 2 || len(components[1]) == 0 || components[1][0] != 'C' {
-						rewriteError(ErrInvalidCommandSyntax)
-						return nil
-					}
-
-					fix := strings.ToUpper(components[0][1:])
-					approach := components[1][1:]
-					if err := sim.AtFixCleared(token, callsign, fix, approach); err != nil {
-						rewriteError(err)
-						return nil
-					} else {
-						continue
-					}
-				}
-
-				// Otherwise look for an altitude
-				if alt, err := strconv.Atoi(command[1:]); err != nil {
-					rewriteError(err)
-					return nil
-				} else if err := sim.AssignAltitude(token, callsign, 100*alt, false); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			}
-
-		case 'D':
-			if command == "DVS" {
-				if err := sim.DescendViaSTAR(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if components := strings.Split(command, "/"); len(components) > 1 && len(components[1]) > 1 {
-				fix := components[0][1:]
-
-				switch components[1][0] {
-				case 'D':
-					// Depart <fix1> direct <fix2>
-					if err := sim.DepartFixDirect(token, callsign, fix, components[1][1:]); err != nil {
-						rewriteError(err)
-						return nil
-					}
-				case 'H':
-					// Depart <fix> at heading <hdg>
-					if hdg, err := strconv.Atoi(components[1][1:]); err != nil {
-						rewriteError(err)
-						return nil
-					} else if err := sim.DepartFixHeading(token, callsign, fix, hdg); err != nil {
-						rewriteError(err)
-						return nil
-					}
-
-				default:
-					rewriteError(ErrInvalidCommandSyntax)
-					return nil
-				}
-			} else if len(command) > 1 && command[1] >= '0' && command[1] <= '9' {
-				// Looks like an altitude.
-				if alt, err := strconv.Atoi(command[1:]); err != nil {
-					rewriteError(err)
-					return nil
-				} else if err := sim.AssignAltitude(token, callsign, 100*alt, false); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if _, ok := sim.World.Locate(string(command[1:])); ok {
-				if err := sim.DirectFix(token, callsign, command[1:]); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else {
-				rewriteError(ErrInvalidCommandSyntax)
-				return nil
-			}
-
-		case 'E':
-			if command == "ED" {
-				if err := sim.ExpediteDescent(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if command == "EC" {
-				if err := sim.ExpediteClimb(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if len(command) > 1 {
-				// Expect approach.
-				if err := sim.ExpectApproach(token, callsign, command[1:]); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else {
-				rewriteError(ErrInvalidCommandSyntax)
-				return nil
-			}
-		case 'F':
-			if command == "FC" {
-				if err := sim.HandoffControl(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			}
-		case 'H':
-			if len(command) == 1 {
-				if err := sim.AssignHeading(&HeadingArgs{
-					ControllerToken: token,
-					Callsign:        callsign,
-					Present:         true,
-				}); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if hdg, err := strconv.Atoi(command[1:]); err != nil {
-				rewriteError(err)
-				return nil
-			} else if err := sim.AssignHeading(&HeadingArgs{
-				ControllerToken: token,
-				Callsign:        callsign,
-				Heading:         hdg,
-				Turn:            TurnClosest,
-			}); err != nil {
-				rewriteError(err)
-				return nil
-			}
-
-		case 'I':
-			if len(command) == 1 {
-				if err := sim.InterceptLocalizer(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if command == "ID" {
-				if err := sim.Ident(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else {
-				rewriteError(ErrInvalidCommandSyntax)
-				return nil
-			}
-
-		case 'L':
-			if l := len(command); l > 2 && command[l-1] == 'D' {
-				// turn left x degrees
-				if deg, err := strconv.Atoi(command[1 : l-1]); err != nil {
-					rewriteError(err)
-					return nil
-				} else if err := sim.AssignHeading(&HeadingArgs{
-					ControllerToken: token,
-					Callsign:        callsign,
-					LeftDegrees:     deg,
-				}); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else {
-				// turn left heading...
-				if hdg, err := strconv.Atoi(command[1:]); err != nil {
-					rewriteError(err)
-					return nil
-				} else if err := sim.AssignHeading(&HeadingArgs{
-					ControllerToken: token,
-					Callsign:        callsign,
-					Heading:         hdg,
-					Turn:            TurnLeft,
-				}); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			}
-
-		case 'R':
-			if l := len(command); l > 2 && command[l-1] == 'D' {
-				// turn right x degrees
-				if deg, err := strconv.Atoi(command[1 : l-1]); err != nil {
-					rewriteError(err)
-					return nil
-				} else if err := sim.AssignHeading(&HeadingArgs{
-					ControllerToken: token,
-					Callsign:        callsign,
-					RightDegrees:    deg,
-				}); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else {
-				// turn right heading...
-				if hdg, err := strconv.Atoi(command[1:]); err != nil {
-					rewriteError(err)
-					return nil
-				} else if err := sim.AssignHeading(&HeadingArgs{
-					ControllerToken: token,
-					Callsign:        callsign,
-					Heading:         hdg,
-					Turn:            TurnRight,
-				}); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			}
-
-		case 'S':
-			if len(command) == 1 {
-				// Cancel speed restrictions
-				if err := sim.AssignSpeed(token, callsign, 0, false); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if command == "SMIN" {
-				if err := sim.MaintainSlowestPractical(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if command == "SMAX" {
-				if err := sim.MaintainMaximumForward(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else {
-				if kts, err := strconv.Atoi(command[1:]); err != nil {
-					rewriteError(err)
-					return nil
-				} else if err := sim.AssignSpeed(token, callsign, kts, false); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			}
-
-		case 'T':
-			if command == "TO" {
-				if err := sim.ContactTower(token, callsign); err != nil {
-					rewriteError(err)
-					return nil
-				}
-			} else if n := len(command); n > 2 {
-				if deg, err := strconv.Atoi(command[1 : n-1]); err == nil {
-					if command[n-1] == 'L' {
-						// turn x degrees left
-						if err := sim.AssignHeading(&HeadingArgs{
-							ControllerToken: token,
-							Callsign:        callsign,
-							LeftDegrees:     deg,
-						}); err != nil {
-							rewriteError(err)
-							return nil
-						} else {
-							continue
-						}
-					} else if command[n-1] == 'R' {
-						// turn x degrees right
-						if err := sim.AssignHeading(&HeadingArgs{
-							ControllerToken: token,
-							Callsign:        callsign,
-							RightDegrees:    deg,
-						}); err != nil {
-							rewriteError(err)
-							return nil
-						} else {
-							continue
-						}
-					}
-				}
-
-				switch command[:2] {
-				case "TS":
-					if kts, err := strconv.Atoi(command[2:]); err != nil {
-						rewriteError(err)
-						return nil
-					} else if err := sim.AssignSpeed(token, callsign, kts, true); err != nil {
-						rewriteError(err)
-						return nil
-					}
-
-				case "TA", "TC", "TD":
-					if alt, err := strconv.Atoi(command[2:]); err != nil {
-						rewriteError(err)
-						return nil
-					} else if err := sim.AssignAltitude(token, callsign, 100*alt, true); err != nil {
-						rewriteError(err)
-						return nil
-					}
-
-				default:
-					rewriteError(ErrInvalidCommandSyntax)
-					return nil
-				}
-			}
-
-		default:
+		// See commandref.go for the available commands.
+		if c, args, err := parseAircraftCommand(command, locate); err != nil {
+			rewriteError(err)
+			return nil
+		} else if c.run == nil {
 			rewriteError(ErrInvalidCommandSyntax)
 			return nil
+		} else if err := c.run(sim, token, callsign, args); err != nil {
+			rewriteError(err)
+			return nil
 		}
+
+		offset += len(command)
 	}
 
 	return nil
@@ -1378,6 +1390,11 @@ func runServer(l net.Listener, isLocal bool) chan map[string]map[string]*SimConf
 		scenarioGroups, simConfigurations := LoadScenarioGroups(&e)
 		if e.HaveErrors() {
 			e.PrintErrors(lg)
+			if isLocal {
+				// The main thread shows the errors and then exits.
+				startupProgress.Fail(e.String())
+				return
+			}
 			os.Exit(1)
 		}
 
@@ -1393,7 +1410,17 @@ func runServer(l net.Listener, isLocal bool) chan map[string]map[string]*SimConf
 			os.Exit(1)
 		}
 
+		if isLocal {
+			if err := server.RegisterName("LocalSimManager", &LocalSimManager{sm: sm}); err != nil {
+				lg.Errorf("unable to register LocalSimManager: %v", err)
+				os.Exit(1)
+			}
+		}
+
 		go launchHTTPStats(sm)
+		if !isLocal {
+			go sm.reloadOnSIGHUP()
+		}
 
 		ch <- simConfigurations
 
@@ -1404,7 +1431,15 @@ func runServer(l net.Listener, isLocal bool) chan map[string]map[string]*SimConf
 			lg.Infof("%s: new connection", conn.RemoteAddr())
 			if err != nil {
 				lg.Errorf("Accept error: %v", err)
-			} else if cc, err := MakeCompressedConn(MakeLoggingConn(conn)); err != nil {
+				continue
+			}
+
+			var c net.Conn = MakeLoggingConn(conn)
+			if isLocal && netSimEnabled() {
+				// See netsim.go.
+				c = MakeDegradedConn(c, *netLatency, *netJitter, float32(*netLoss))
+			}
+			if cc, err := MakeCompressedConn(c); err != nil {
 				lg.Errorf("MakeCompressedConn: %v", err)
 			} else {
 				codec := MakeGOBServerCodec(cc)
@@ -1433,6 +1468,8 @@ func launchHTTPStats(sm *SimManager) {
 		statsHandler(w, r, sm)
 		lg.Infof("%s: served stats request", r.URL.String())
 	})
+	http.HandleFunc("/join/", handleJoinLanding)
+	http.HandleFunc("/crashreport", handleCrashReport)
 	http.HandleFunc("/vice-logs/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
 		if f, err := os.Open("." + r.URL.String()); err == nil {
@@ -1444,7 +1481,7 @@ func launchHTTPStats(sm *SimManager) {
 		}
 	})
 
-	if err := http.ListenAndServe(":6502", nil); err != nil {
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", ViceHTTPServerPort), nil); err != nil {
 		lg.Errorf("Failed to start HTTP server for stats: %v\n", err)
 	}
 }
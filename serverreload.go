@@ -0,0 +1,116 @@
+// serverreload.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Reloading the server's scenarios: when the multi-controller server
+// receives SIGHUP or a SimManager.ReloadScenarios RPC, e.g. via
+//
+//	vice -reload -server vice.example.com:8000 -password ...
+//
+// it loads the scenario groups and video maps from disk again, so that
+// new scenarios can be made available without restarting the server and
+// disconnecting everyone. Sims created afterward use the new scenarios;
+// those that are already running keep the ones they were created with.
+// Clients see the new scenarios the next time they connect. If there
+// are errors in the new scenarios, they are reported and the server
+// carries on with the current ones.
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// checkAdminPassword returns an error if the given password doesn't match
+// the one in the server's "password" file.
+func checkAdminPassword(password string) error {
+	pw, err := os.ReadFile("password")
+	if err != nil {
+		return err
+	}
+	if strings.TrimRight(string(pw), "\n\r") != password {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+func (sm *SimManager) ReloadScenarios(password string, _ *struct{}) error {
+	if err := checkAdminPassword(password); err != nil {
+		return err
+	}
+	return sm.reloadScenarios()
+}
+
+func (sm *SimManager) reloadScenarios() error {
+	// Only one reload at a time; sims can still be created and joined
+	// while it's happening.
+	sm.reloadMu.Lock()
+	defer sm.reloadMu.Unlock()
+
+	start := time.Now()
+	sm.lg.Info("reloading scenarios")
+
+	var e ErrorLogger
+	scenarioGroups, configs := LoadScenarioGroups(&e)
+	if e.HaveErrors() {
+		e.PrintErrors(sm.lg)
+		return ErrScenarioReload
+	}
+
+	sm.mu.Lock(sm.lg)
+	sm.scenarioGroups, sm.configs = scenarioGroups, configs
+	sm.mu.Unlock(sm.lg)
+
+	// The video map files may have changed as well.
+	videoMaps.Flush()
+
+	sm.lg.Info("reloaded scenarios", slog.Int("tracons", len(configs)),
+		slog.Duration("time", time.Since(start)))
+	return nil
+}
+
+// LocalSimManager provides RPCs that are only available from the local
+// sim server.
+type LocalSimManager struct {
+	sm *SimManager
+}
+
+// ReloadScenarios reloads the scenarios, e.g. after a scenario pack has
+// been installed, and returns the new configurations.
+func (l *LocalSimManager) ReloadScenarios(_ int, configs *map[string]map[string]*SimConfiguration) error {
+	if err := l.sm.reloadScenarios(); err != nil {
+		return err
+	}
+
+	l.sm.mu.Lock(l.sm.lg)
+	defer l.sm.mu.Unlock(l.sm.lg)
+	*configs = l.sm.configs
+	return nil
+}
+
+// reloadOnSIGHUP reloads the scenarios each time the process receives
+// SIGHUP.
+func (sm *SimManager) reloadOnSIGHUP() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		if err := sm.reloadScenarios(); err != nil {
+			sm.lg.Errorf("%v", err)
+		}
+	}
+}
+
+// ReloadServerScenarios asks the server to reload its scenarios.
+func ReloadServerScenarios(hostname, password string) error {
+	client, err := getClient(hostname)
+	if err != nil {
+		return err
+	}
+	// Loading the scenarios can take longer than the usual RPC timeout.
+	return client.Call("SimManager.ReloadScenarios", password, nil)
+}
@@ -65,15 +65,30 @@ type LaunchConfig struct {
 	ArrivalPushes               bool
 	ArrivalPushFrequencyMinutes int
 	ArrivalPushLengthMinutes    int
-}
-
-func MakeLaunchConfig(dep []ScenarioGroupDepartureRunway, arr map[string]map[string]int) LaunchConfig {
+	// VFR aircraft per hour; see vfr.go.
+	VFRRate int
+	// Pilot-initiated requests per hour; see pilotrequests.go.
+	PilotRequestRate int
+	// See callsigns.go.
+	AvoidSimilarCallsigns bool
+	// Optional schedule of traffic banks from the scenario; see
+	// schedule.go.
+	Schedule    *TrafficSchedule
+	UseSchedule bool
+
+	Adaptive AdaptiveLaunchConfig
+}
+
+func MakeLaunchConfig(dep []ScenarioGroupDepartureRunway, arr map[string]map[string]int,
+	schedule *TrafficSchedule) LaunchConfig {
 	lc := LaunchConfig{
 		DepartureChallenge:          0.25,
 		GoAroundRate:                0.05,
 		ArrivalGroupRates:           arr,
 		ArrivalPushFrequencyMinutes: 20,
 		ArrivalPushLengthMinutes:    10,
+		Schedule:                    schedule,
+		UseSchedule:                 schedule != nil,
 	}
 
 	// Walk the departure runways to create the map for departures.
@@ -115,7 +130,10 @@ func (lc *LaunchConfig) DrawActiveDepartureRunways() {
 	}
 }
 
-func (lc *LaunchConfig) DrawDepartureUI() (changed bool) {
+// DrawDepartureUI draws the departure launch settings. If setRate is
+// non-nil, it is called when a departure rate is changed, and those
+// changes aren't included in the returned value.
+func (lc *LaunchConfig) DrawDepartureUI(setRate func(airport, runway, category string, rate int)) (changed bool) {
 	if len(lc.DepartureRates) == 0 {
 		return
 	}
@@ -166,8 +184,15 @@ func (lc *LaunchConfig) DrawDepartureUI() (changed bool) {
 					imgui.TableNextColumn()
 
 					r := int32(lc.DepartureRates[airport][runway][category])
-					changed = imgui.InputIntV("##adr", &r, 0, 120, 0) || changed
-					lc.DepartureRates[airport][runway][category] = int(r)
+					if imgui.InputIntV("##adr", &r, 0, 120, 0) {
+						r = max(0, r)
+						lc.DepartureRates[airport][runway][category] = int(r)
+						if setRate != nil {
+							setRate(airport, runway, category, int(r))
+						} else {
+							changed = true
+						}
+					}
 
 					imgui.PopID()
 				}
@@ -182,7 +207,9 @@ func (lc *LaunchConfig) DrawDepartureUI() (changed bool) {
 	return
 }
 
-func (lc *LaunchConfig) DrawArrivalUI() (changed bool) {
+// DrawArrivalUI draws the arrival launch settings; setRate is handled as
+// in DrawDepartureUI.
+func (lc *LaunchConfig) DrawArrivalUI(setRate func(group, airport string, rate int)) (changed bool) {
 	if len(lc.ArrivalGroupRates) == 0 {
 		return
 	}
@@ -232,8 +259,15 @@ func (lc *LaunchConfig) DrawArrivalUI() (changed bool) {
 					imgui.Text(group)
 					imgui.TableNextColumn()
 					r := int32(rate)
-					changed = imgui.InputIntV("##aar-"+ap, &r, 0, 120, 0) || changed
-					lc.ArrivalGroupRates[group][ap] = int(r)
+					if imgui.InputIntV("##aar-"+ap, &r, 0, 120, 0) {
+						r = max(0, r)
+						lc.ArrivalGroupRates[group][ap] = int(r)
+						if setRate != nil {
+							setRate(group, ap, int(r))
+						} else {
+							changed = true
+						}
+					}
 				}
 				imgui.PopID()
 			}
@@ -255,12 +289,15 @@ type NewSimConfiguration struct {
 	NewSimName      string // for create remote only
 	RequirePassword bool   // for create remote only
 	Password        string // for create remote only
+	ExitWhenEmpty   bool   // for create remote only; see Sim.ExitWhenEmpty
 	NewSimType      int
 
 	LiveWeather               bool
+	Seed                      int64 // for local sims; chosen at random if zero
 	SelectedRemoteSim         string
 	SelectedRemoteSimPosition string
 	RemoteSimPassword         string // for join remote only
+	Initials                  string
 
 	lastRemoteSimsUpdate time.Time
 	updateRemoteSimsCall *PendingCall
@@ -430,6 +467,10 @@ func (c *NewSimConfiguration) DrawUI() bool {
 	}
 	imgui.Separator()
 
+	if c.NewSimType == NewSimCreateLocal {
+		c.uiDrawTutorialOffer()
+	}
+
 	if c.NewSimType == NewSimCreateLocal || c.NewSimType == NewSimCreateRemote {
 		flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg |
 			imgui.TableFlagsSizingStretchProp
@@ -489,7 +530,12 @@ func (c *NewSimConfiguration) DrawUI() bool {
 				for _, groupName := range SortedMapKeys(c.TRACON) {
 					group := c.TRACON[groupName]
 					for _, name := range SortedMapKeys(group.ScenarioConfigs) {
-						if imgui.SelectableV(name, name == c.ScenarioName, 0, imgui.Vec2{}) {
+						fontsAddText(name)
+						label := name
+						if globalConfig.CompletedScenarios[trainingScenarioKey(c.TRACONName, name)] {
+							label += " " + FontAwesomeIconCheckSquare
+						}
+						if imgui.SelectableV(label, name == c.ScenarioName, 0, imgui.Vec2{}) {
 							c.SetScenario(groupName, name)
 						}
 					}
@@ -653,6 +699,7 @@ func (c *NewSimConfiguration) DrawUI() bool {
 
 				selected := simName == c.SelectedRemoteSim
 				selFlags := imgui.SelectableFlagsSpanAllColumns | imgui.SelectableFlagsDontClosePopups
+				fontsAddText(simName)
 				if imgui.SelectableV(simName, selected, selFlags, imgui.Vec2{}) {
 					c.SelectedRemoteSim = simName
 
@@ -705,12 +752,27 @@ func (c *NewSimConfiguration) DrawUI() bool {
 		}
 	}
 
+	imgui.InputTextV("Operating initials", &globalConfig.ControllerInitials,
+		imgui.InputTextFlagsCharsUppercase|imgui.InputTextFlagsCharsNoBlank, nil)
+	if len(globalConfig.ControllerInitials) > 2 {
+		globalConfig.ControllerInitials = globalConfig.ControllerInitials[:2]
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Two-letter initials that identify you when you sign on to a position")
+	}
+
 	return false
 }
 
 func (c *NewSimConfiguration) DrawRatesUI() bool {
-	c.Scenario.LaunchConfig.DrawDepartureUI()
-	c.Scenario.LaunchConfig.DrawArrivalUI()
+	c.Scenario.LaunchConfig.DrawDepartureUI(nil)
+	c.Scenario.LaunchConfig.DrawArrivalUI(nil)
+	c.Scenario.LaunchConfig.DrawScheduleUI()
+	c.Scenario.LaunchConfig.DrawVFRUI()
+	c.Scenario.LaunchConfig.DrawPilotRequestUI()
+	c.Scenario.LaunchConfig.DrawCallsignUI()
+	imgui.Separator()
+	c.Scenario.LaunchConfig.Adaptive.DrawUI()
 	return false
 }
 
@@ -761,6 +823,8 @@ func (c *NewSimConfiguration) OkDisabled() bool {
 }
 
 func (c *NewSimConfiguration) Start() error {
+	c.Initials = strings.ToUpper(strings.TrimSpace(globalConfig.ControllerInitials))
+
 	var result NewSimResult
 	if err := c.selectedServer.CallWithTimeout("SimManager.New", c, &result); err != nil {
 		err = TryDecodeError(err)
@@ -792,7 +856,7 @@ func (c *NewSimConfiguration) Start() error {
 type Sim struct {
 	Name string
 
-	mu LoggingMutex
+	mu LoggingRWMutex
 
 	ScenarioGroup string
 	Scenario      string
@@ -800,10 +864,17 @@ type Sim struct {
 	World           *World
 	controllers     map[string]*ServerController // from token
 	SignOnPositions map[string]*Controller
+	// position callsign -> token of the controller who has asked to
+	// relieve it
+	reliefRequests map[string]string
 
 	eventStream *EventStream
 	lg          *Logger
 
+	// How long the most recent call to Update took; reported to clients
+	// for the performance HUD.
+	lastUpdateDuration time.Duration
+
 	LaunchConfig LaunchConfig
 
 	// airport -> runway -> category
@@ -817,10 +888,16 @@ type Sim struct {
 	// Key is arrival group name
 	NextArrivalSpawn map[string]time.Time
 
+	NextVFRSpawn time.Time
+	// See pilotrequests.go.
+	NextPilotRequest time.Time
+
 	// callsign -> auto accept time
 	Handoffs map[string]time.Time
 	// callsign -> "to" controller
 	PointOuts map[string]map[string]PointOut
+	// callsign -> frequency change in progress
+	PendingContacts map[string]PendingContact
 
 	TotalDepartures int
 	TotalArrivals   int
@@ -829,6 +906,10 @@ type Sim struct {
 
 	RequirePassword bool
 	Password        string
+	// Remote sims are usually kept around after everyone signs off so
+	// that they can be rejoined; this is set for the ones created by
+	// -loadtest so that they go away once the test is done.
+	ExitWhenEmpty bool
 
 	lastSimUpdate time.Time
 
@@ -844,6 +925,51 @@ type Sim struct {
 	PushEnd       time.Time
 
 	STARSInputOverride string
+
+	// Radar sites that are currently out of service.
+	FailedRadarSites map[string]bool
+
+	// See dependentops.go. RunwayDepartureTimes records the last time a
+	// departure started its takeoff roll, indexed by "airport/runway".
+	DependentOperations  []DependentOperation
+	RunwayDepartureTimes map[string]time.Time
+	// See runwayoccupancy.go; indexed by "airport/runway".
+	RunwayOccupancy map[string]RunwayOccupant
+	// callsign -> why it is being held; not serialized, so the message
+	// may be repeated after a restore.
+	dependentHolds map[string]string
+
+	Triggers    []ScenarioTrigger
+	ScriptState ScriptState
+
+	Objectives    []ScenarioObjective
+	Tutorial      []TutorialStep
+	TrainingState TrainingState
+
+	// See commandjournal.go. Seed is the random number generator seed
+	// for local sims and is zero otherwise.
+	CommandJournal []CommandJournalEntry
+	Seed           int64
+
+	// Named snapshots that the launch controller may restore; see
+	// situations.go.
+	Situations map[string]*Situation
+
+	workload workloadMonitor
+	// Problems found when the sim was created; see simcheck.go.
+	consistencyErrors ErrorLogger
+	// Sim time when predicted trajectories were last updated.
+	lastTrajectoryUpdate time.Time
+	// callsign -> controller that a sector handoff was last offered to;
+	// see sectors.go.
+	sectorHandoffs map[string]string
+	// Airborne aircraft by position; rebuilt once a second.
+	aircraftIndex *SpatialIndex[*Aircraft]
+	// Storage reused across calls to updateState to reduce garbage.
+	updateScratch struct {
+		aircraft []*Aircraft
+		passed   []*Waypoint
+	}
 }
 
 type PointOut struct {
@@ -853,6 +979,7 @@ type PointOut struct {
 
 type ServerController struct {
 	Callsign            string
+	Initials            string
 	lastUpdateCall      time.Time
 	warnedNoUpdateCalls bool
 	events              *EventsSubscription
@@ -866,7 +993,7 @@ func (sc *ServerController) LogValue() slog.Value {
 }
 
 func NewSim(ssc NewSimConfiguration, scenarioGroups map[string]map[string]*ScenarioGroup, isLocal bool, lg *Logger) *Sim {
-	lg = lg.With(slog.String("sim_name", ssc.NewSimName))
+	lg = lg.Subsystem("sim").With(slog.String("sim_name", ssc.NewSimName))
 
 	tracon, ok := scenarioGroups[ssc.TRACONName]
 	if !ok {
@@ -884,6 +1011,16 @@ func NewSim(ssc NewSimConfiguration, scenarioGroups map[string]map[string]*Scena
 		return nil
 	}
 
+	startTime := sc.SimStartTime(time.Now())
+
+	// Local sims use a known seed so that the command journal can be
+	// replayed.
+	var seed int64
+	if isLocal {
+		seed = Select(ssc.Seed != 0, ssc.Seed, time.Now().UnixNano())
+		rand.Seed(seed)
+	}
+
 	s := &Sim{
 		ScenarioGroup: ssc.GroupName,
 		Scenario:      ssc.ScenarioName,
@@ -900,13 +1037,24 @@ func NewSim(ssc NewSimConfiguration, scenarioGroups map[string]map[string]*Scena
 
 		Password:        ssc.Password,
 		RequirePassword: ssc.RequirePassword,
+		ExitWhenEmpty:   ssc.ExitWhenEmpty,
 
-		SimTime:        time.Now(),
+		SimTime:        startTime,
 		lastUpdateTime: time.Now(),
 
-		SimRate:   1,
-		Handoffs:  make(map[string]time.Time),
-		PointOuts: make(map[string]map[string]PointOut),
+		SimRate:         1,
+		Handoffs:        make(map[string]time.Time),
+		PointOuts:       make(map[string]map[string]PointOut),
+		PendingContacts: make(map[string]PendingContact),
+
+		Triggers:    sc.Triggers,
+		ScriptState: ScriptState{Start: startTime},
+		Objectives:  sc.Objectives,
+		Tutorial:    cloneTutorial(sc.Tutorial),
+
+		DependentOperations: sc.DependentOperations,
+
+		Seed: seed,
 	}
 
 	if !isLocal {
@@ -916,7 +1064,7 @@ func NewSim(ssc NewSimConfiguration, scenarioGroups map[string]map[string]*Scena
 	if s.LaunchConfig.ArrivalPushes {
 		// Figure out when the next arrival push will start
 		m := 1 + rand.Intn(s.LaunchConfig.ArrivalPushFrequencyMinutes)
-		s.NextPushStart = time.Now().Add(time.Duration(m) * time.Minute)
+		s.NextPushStart = s.SimTime.Add(time.Duration(m) * time.Minute)
 	}
 
 	for ap := range s.LaunchConfig.DepartureRates {
@@ -945,6 +1093,7 @@ func NewSim(ssc NewSimConfiguration, scenarioGroups map[string]map[string]*Scena
 	}
 
 	s.World = newWorld(ssc, s, sg, sc)
+	s.checkConsistency()
 
 	s.setInitialSpawnTimes()
 
@@ -962,6 +1111,7 @@ func newWorld(ssc NewSimConfiguration, s *Sim, sg *ScenarioGroup, sc *Scenario)
 	}
 	w.TRACON = sg.TRACON
 	w.MagneticVariation = sg.MagneticVariation
+	w.MagneticAdjustment = sg.MagneticAdjustment
 	w.NmPerLongitude = sg.NmPerLongitude
 	w.Wind = sc.Wind
 	w.Airports = sg.Airports
@@ -972,8 +1122,18 @@ func newWorld(ssc NewSimConfiguration, s *Sim, sg *ScenarioGroup, sc *Scenario)
 	w.Center = Select(stars.Center.IsZero(), stars.Center, stars.Center)
 	w.Range = Select(sc.Range == 0, stars.Range, sc.Range)
 	w.DefaultMaps = sc.DefaultMaps
-	w.STARSMaps = stars.Maps
+	w.STARSMaps = slices.Clone(stars.Maps)
+	mapNames := MapSlice(stars.Maps, func(m STARSMap) string { return m.Name })
+	if cbs, err := videoMaps.Get(stars.VideoMapFile, mapNames); err != nil {
+		s.lg.Errorf("%v", err)
+	} else {
+		for i := range w.STARSMaps {
+			w.STARSMaps[i].CommandBuffer = cbs[w.STARSMaps[i].Name]
+		}
+	}
 	w.InhibitCAVolumes = stars.InhibitCAVolumes
+	w.ClassAirspace = sg.ClassAirspace
+	w.Sectors = sg.Sectors
 	w.Scratchpads = stars.Scratchpads
 	w.ArrivalGroups = sg.ArrivalGroups
 	w.ApproachAirspace = sc.ApproachAirspace
@@ -1025,10 +1185,12 @@ func newWorld(ssc NewSimConfiguration, s *Sim, sg *ScenarioGroup, sc *Scenario)
 			wind += "KT"
 		}
 
-		// Just provide the stuff that the STARS display shows
+		// Just provide the stuff that the STARS display shows, along
+		// with the ceiling and visibility.
 		w.METAR[icao] = &METAR{
 			AirportICAO: icao,
 			Wind:        wind,
+			Weather:     FormatMETARConditions(sc.WeatherConditions()),
 			Altimeter:   fmt.Sprintf("A%d", alt-2+rand.Intn(4)),
 		}
 	}
@@ -1070,10 +1232,17 @@ func newWorld(ssc NewSimConfiguration, s *Sim, sg *ScenarioGroup, sc *Scenario)
 			wind += "KT"
 		}
 
+		// The ceiling and visibility are needed as well; see weather.go.
+		var wx string
+		if m, err := ParseMETAR(fullMETAR); err == nil {
+			wx = m.Weather
+		}
+
 		// Just provide the stuff that the STARS display shows
 		w.METAR[icao] = &METAR{
 			AirportICAO: icao,
 			Wind:        wind,
+			Weather:     wx,
 			Altimeter:   "A" + altimiter,
 		}
 	}
@@ -1128,6 +1297,7 @@ func (s *Sim) LogValue() slog.Value {
 		slog.Any("next_arrival_spawn", s.NextArrivalSpawn),
 		slog.Any("automatic_handoffs", s.Handoffs),
 		slog.Any("automatic_pointouts", s.PointOuts),
+		slog.Any("pending_contacts", s.PendingContacts),
 		slog.Int("departures", s.TotalDepartures),
 		slog.Int("arrivals", s.TotalArrivals),
 		slog.Time("sim_time", s.SimTime),
@@ -1138,8 +1308,10 @@ func (s *Sim) LogValue() slog.Value {
 		slog.Any("aircraft", s.World.Aircraft))
 }
 
-func (s *Sim) SignOn(callsign string) (*World, string, error) {
-	if err := s.signOn(callsign); err != nil {
+// SignOn signs a controller on to the given position. If initials are
+// empty, any that were previously used for the position are kept.
+func (s *Sim) SignOn(callsign, initials string) (*World, string, error) {
+	if err := s.signOn(callsign, initials); err != nil {
 		return nil, "", err
 	}
 
@@ -1149,11 +1321,16 @@ func (s *Sim) SignOn(callsign string) (*World, string, error) {
 	}
 	token := base64.StdEncoding.EncodeToString(buf[:])
 
+	if ctrl, ok := s.SignOnPositions[callsign]; ok && initials == "" {
+		initials = ctrl.Initials
+	}
 	s.controllers[token] = &ServerController{
 		Callsign:       callsign,
+		Initials:       initials,
 		lastUpdateCall: time.Now(),
 		events:         s.eventStream.Subscribe(),
 	}
+	s.postConsistencyErrors()
 
 	w := NewWorld()
 	w.Assign(s.World)
@@ -1162,7 +1339,7 @@ func (s *Sim) SignOn(callsign string) (*World, string, error) {
 	return w, token, nil
 }
 
-func (s *Sim) signOn(callsign string) error {
+func (s *Sim) signOn(callsign, initials string) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
 
@@ -1175,6 +1352,9 @@ func (s *Sim) signOn(callsign string) error {
 		if !ok {
 			return ErrNoController
 		}
+		if initials != "" {
+			ctrl.Initials = initials
+		}
 		s.World.Controllers[callsign] = ctrl
 
 		if callsign == s.World.PrimaryController {
@@ -1185,9 +1365,13 @@ func (s *Sim) signOn(callsign string) error {
 		}
 	}
 
+	msg := callsign + " has signed on"
+	if initials != "" {
+		msg += " (" + initials + ")"
+	}
 	s.eventStream.Post(Event{
 		Type:    StatusMessageEvent,
-		Message: callsign + " has signed on.",
+		Message: msg + ".",
 	})
 	s.lg.Infof("%s: controller signed on", callsign)
 
@@ -1214,6 +1398,7 @@ func (s *Sim) SignOff(token string) error {
 		ctrl.events.Unsubscribe()
 		delete(s.controllers, token)
 		delete(s.World.Controllers, ctrl.Callsign)
+		s.cancelReliefRequests(token, ctrl.Callsign)
 
 		s.eventStream.Post(Event{
 			Type:    StatusMessageEvent,
@@ -1235,10 +1420,13 @@ func (s *Sim) ChangeControlPosition(token string, callsign string, keepTracks bo
 
 	// Make sure we can successfully sign on before signing off from the
 	// current position.
-	if err := s.signOn(callsign); err != nil {
+	if err := s.signOn(callsign, ctrl.Initials); err != nil {
 		return err
 	}
 	ctrl.Callsign = callsign
+	s.mu.Lock(s.lg)
+	s.cancelReliefRequests(token, oldCallsign)
+	s.mu.Unlock(s.lg)
 
 	delete(s.World.Controllers, oldCallsign)
 
@@ -1258,6 +1446,100 @@ func (s *Sim) ChangeControlPosition(token string, callsign string, keepTracks bo
 	return nil
 }
 
+// RequestRelief records that the controller with the given token would
+// like to relieve the controller signed in to the given position; the
+// position is transferred once that controller has given a relief
+// briefing and accepts.
+func (s *Sim) RequestRelief(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	if callsign == ctrl.Callsign || callsign == "Observer" {
+		return ErrInvalidController
+	}
+	if !s.controllerIsSignedIn(callsign) {
+		return ErrNotSignedIn
+	}
+
+	if s.reliefRequests == nil {
+		s.reliefRequests = make(map[string]string)
+	}
+	s.reliefRequests[callsign] = token
+
+	s.eventStream.Post(Event{
+		Type:           ReliefRequestEvent,
+		FromController: ctrl.Callsign,
+		ToController:   callsign,
+	})
+	s.lg.Infof("%s: requested relief of %s", ctrl.Callsign, callsign)
+
+	return nil
+}
+
+// AcceptRelief transfers the position of the controller with the given
+// token to the controller who asked to relieve it. The relieved
+// controller takes the reliever's previous position (which may be an
+// observer position) in exchange. Tracks stay with the positions.
+func (s *Sim) AcceptRelief(token string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	relieved, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	relieverToken, ok := s.reliefRequests[relieved.Callsign]
+	if !ok {
+		return ErrNoReliefRequest
+	}
+	reliever, ok := s.controllers[relieverToken]
+	if !ok {
+		delete(s.reliefRequests, relieved.Callsign)
+		return ErrNoReliefRequest
+	}
+
+	position, oldPosition := relieved.Callsign, reliever.Callsign
+	delete(s.reliefRequests, position)
+	s.cancelReliefRequests(relieverToken, oldPosition)
+
+	relieved.Callsign, reliever.Callsign = oldPosition, position
+
+	// The initials go with the controllers rather than the positions.
+	if ctrl, ok := s.World.Controllers[position]; ok {
+		ctrl.Initials = reliever.Initials
+	}
+	if ctrl, ok := s.World.Controllers[oldPosition]; ok {
+		ctrl.Initials = relieved.Initials
+	}
+
+	msg := position + " has been relieved"
+	if relieved.Initials != "" && reliever.Initials != "" {
+		msg += ": " + relieved.Initials + " by " + reliever.Initials
+	}
+	s.eventStream.Post(Event{
+		Type:    StatusMessageEvent,
+		Message: msg + ".",
+	})
+	s.lg.Infof("%s: relieved by %s", position, oldPosition)
+
+	return nil
+}
+
+// cancelReliefRequests discards any relief requests made by or for the
+// controller; s.mu must be held.
+func (s *Sim) cancelReliefRequests(token, callsign string) {
+	delete(s.reliefRequests, callsign)
+	for position, tok := range s.reliefRequests {
+		if tok == token {
+			delete(s.reliefRequests, position)
+		}
+	}
+}
+
 func (s *Sim) TogglePause(token string) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
@@ -1273,6 +1555,11 @@ func (s *Sim) TogglePause(token string) error {
 }
 
 func (s *Sim) PostEvent(e Event) {
+	if e.Type == RadioTransmissionEvent && e.RadioTransmissionType == RadioTransmissionContact {
+		if ctrl := s.World.GetControllerByCallsign(e.ToController); ctrl != nil && ctrl.IsHuman {
+			s.workload.contact(e.Callsign, s.SimTime)
+		}
+	}
 	s.eventStream.Post(e)
 }
 
@@ -1285,15 +1572,22 @@ type SimWorldUpdate struct {
 	Aircraft    map[string]*Aircraft
 	Controllers map[string]*Controller
 	Time        time.Time
+	// The position the controller is signed in to; it changes if the
+	// controller is relieved or relieves someone else.
+	Callsign string
 
 	LaunchConfig LaunchConfig
 
-	SimIsPaused     bool
-	SimRate         float32
-	STARSInput      string
-	Events          []Event
-	TotalDepartures int
-	TotalArrivals   int
+	SimIsPaused      bool
+	SimRate          float32
+	STARSInput       string
+	FailedRadarSites map[string]bool
+	Objectives       []ObjectiveReport
+	Tutorial         TutorialReport
+	Events           []Event
+	TotalDepartures  int
+	TotalArrivals    int
+	UpdateDuration   time.Duration
 }
 
 func (wu *SimWorldUpdate) UpdateWorld(w *World, eventStream *EventStream) {
@@ -1301,16 +1595,24 @@ func (wu *SimWorldUpdate) UpdateWorld(w *World, eventStream *EventStream) {
 	if wu.Controllers != nil {
 		w.Controllers = wu.Controllers
 	}
+	if wu.Callsign != "" {
+		w.Callsign = wu.Callsign
+	}
 
 	w.LaunchConfig = wu.LaunchConfig
 
 	w.SimTime = wu.Time
 	w.SimIsPaused = wu.SimIsPaused
 	w.SimRate = wu.SimRate
+	w.simUpdateDuration = wu.UpdateDuration
 	w.STARSInputOverride = wu.STARSInput
+	w.FailedRadarSites = wu.FailedRadarSites
 	w.TotalDepartures = wu.TotalDepartures
 	w.TotalArrivals = wu.TotalArrivals
 
+	w.Objectives = wu.Objectives
+	w.Tutorial = wu.Tutorial
+
 	// Important: do this after updating aircraft, controllers, etc.,
 	// so that they reflect any changes the events are flagging.
 	for _, e := range wu.Events {
@@ -1336,15 +1638,20 @@ func (s *Sim) GetWorldUpdate(token string, update *SimWorldUpdate) error {
 		}
 
 		*update = SimWorldUpdate{
-			Aircraft:        s.World.Aircraft,
-			Controllers:     s.World.Controllers,
-			Time:            s.SimTime,
-			LaunchConfig:    s.LaunchConfig,
-			SimIsPaused:     s.Paused,
-			SimRate:         s.SimRate,
-			Events:          ctrl.events.Get(),
-			TotalDepartures: s.TotalDepartures,
-			TotalArrivals:   s.TotalArrivals,
+			Aircraft:         s.World.Aircraft,
+			Controllers:      s.World.Controllers,
+			Time:             s.SimTime,
+			Callsign:         ctrl.Callsign,
+			LaunchConfig:     s.LaunchConfig,
+			SimIsPaused:      s.Paused,
+			SimRate:          s.SimRate,
+			FailedRadarSites: s.FailedRadarSites,
+			Objectives:       s.objectiveReports(),
+			Tutorial:         s.tutorialReport(),
+			Events:           ctrl.events.Get(),
+			TotalDepartures:  s.TotalDepartures,
+			TotalArrivals:    s.TotalArrivals,
+			UpdateDuration:   s.lastUpdateDuration,
 		}
 
 		return nil
@@ -1352,6 +1659,7 @@ func (s *Sim) GetWorldUpdate(token string, update *SimWorldUpdate) error {
 }
 
 func (s *Sim) Activate(lg *Logger) {
+	lg = lg.Subsystem("sim")
 	if s.Name == "" {
 		s.lg = lg
 	} else {
@@ -1387,7 +1695,9 @@ func (s *Sim) Update() {
 
 	startUpdate := time.Now()
 	defer func() {
-		if d := time.Since(startUpdate); d > 200*time.Millisecond {
+		d := time.Since(startUpdate)
+		s.lastUpdateDuration = d
+		if d > 200*time.Millisecond {
 			lg.Warn("unexpectedly long Sim Update() call", slog.Duration("duration", d),
 				slog.Any("sim", s))
 		}
@@ -1481,6 +1791,9 @@ func (s *Sim) updateState() {
 				slog.String("from", ac.TrackingController),
 				slog.String("to", ac.HandoffTrackController))
 
+			if ctrl := s.World.GetControllerByCallsign(ac.TrackingController); ctrl != nil && ctrl.IsHuman {
+				s.TrainingState.HandoffsMade++
+			}
 			ac.TrackingController = ac.HandoffTrackController
 			ac.HandoffTrackController = ""
 		}
@@ -1511,11 +1824,44 @@ func (s *Sim) updateState() {
 		}
 	}
 
+	s.updatePendingContacts(now)
+
 	// Update the simulation state once a second.
 	if now.Sub(s.lastSimUpdate) >= time.Second {
 		s.lastSimUpdate = now
-		for callsign, ac := range s.World.Aircraft {
-			passedWaypoint := ac.Update(s.World, s, s.lg)
+
+		// Gather the aircraft to update in a consistent order so that
+		// the results don't depend on map iteration order or on how the
+		// nav updates are scheduled.
+		aircraft := s.updateScratch.aircraft[:0]
+		for _, ac := range s.World.Aircraft {
+			// Departures waiting for release or for traffic at nearby
+			// airports stay on the ground, and frozen aircraft stay put.
+			if !ac.Release.Waiting(now) && !ac.Frozen && !s.departureHeld(ac, now) {
+				aircraft = append(aircraft, ac)
+			}
+		}
+		sort.Slice(aircraft, func(i, j int) bool { return aircraft[i].Callsign < aircraft[j].Callsign })
+		passed := s.updateAircraftNav(aircraft)
+
+		for i, ac := range aircraft {
+			callsign := ac.Callsign
+			if s.World.Aircraft[callsign] != ac {
+				// Deleted while handling an earlier aircraft.
+				continue
+			}
+
+			passedWaypoint := ac.FinishUpdate(s.World, s, passed[i], s.lg)
+			if passedWaypoint != nil && passedWaypoint.Delete {
+				s.landed(ac, now)
+				if ac.FlightPlan.Rules == IFR {
+					s.TrainingState.Landed++
+					s.workload.landed(ac, now)
+				}
+			} else {
+				s.checkRunwayOccupied(ac, now)
+			}
+			s.checkVFRAirspaceEntry(ac)
 			if passedWaypoint != nil && passedWaypoint.Handoff {
 				// Handoff from virtual controller to a human controller.
 				ctrl := s.ResolveController(ac.WaypointHandoffController)
@@ -1575,12 +1921,50 @@ func (s *Sim) updateState() {
 				delete(s.World.Aircraft, callsign)
 			}
 		}
+
+		// Keep the storage but not references to deleted aircraft.
+		clear(aircraft)
+		clear(passed)
+		s.updateScratch.aircraft = aircraft[:0]
+
+		s.updateAircraftIndex()
+		s.updateLostComms(now)
+		s.updateFuel()
+		s.updateTrajectories(now)
+		s.updateSectors(now)
+		s.updatePilotRequests(now)
+		s.updateTrainingStats()
+		s.updateAdaptiveLaunch()
 	}
 
 	// Don't spawn automatically if someone is spawning manually.
 	if s.LaunchConfig.Mode == LaunchAutomatic {
 		s.spawnAircraft()
 	}
+
+	s.runTriggers()
+	s.updateObjectives()
+	s.updateTutorial()
+}
+
+// Below this many aircraft per worker, it's not worth the overhead of
+// parallelizing nav updates.
+const minAircraftPerNavWorker = 16
+
+// updateAircraftNav runs the nav updates for the given aircraft, which
+// only modify each aircraft's own state, spreading the work across the
+// available cores for large scenarios. The returned passed waypoints are
+// in the same order as the aircraft.
+func (s *Sim) updateAircraftNav(aircraft []*Aircraft) []*Waypoint {
+	if cap(s.updateScratch.passed) < len(aircraft) {
+		s.updateScratch.passed = make([]*Waypoint, len(aircraft))
+	}
+	passed := s.updateScratch.passed[:len(aircraft)]
+
+	ParallelFor(len(aircraft), minAircraftPerNavWorker, func(i int) {
+		passed[i] = aircraft[i].UpdateNav(s.World, s.lg)
+	})
+	return passed
 }
 
 func (s *Sim) ResolveController(callsign string) string {
@@ -1604,11 +1988,17 @@ func (s *Sim) ResolveController(callsign string) string {
 }
 
 func (s *Sim) IdleTime() time.Duration {
-	s.mu.Lock(s.lg)
-	defer s.mu.Unlock(s.lg)
+	s.mu.RLock(s.lg)
+	defer s.mu.RUnlock(s.lg)
 	return time.Since(s.lastUpdateTime)
 }
 
+func (s *Sim) hasControllers() bool {
+	s.mu.RLock(s.lg)
+	defer s.mu.RUnlock(s.lg)
+	return len(s.controllers) > 0
+}
+
 func (s *Sim) controllerIsSignedIn(callsign string) bool {
 	for _, ctrl := range s.controllers {
 		if ctrl.Callsign == callsign {
@@ -1621,16 +2011,17 @@ func (s *Sim) controllerIsSignedIn(callsign string) bool {
 func (s *Sim) prespawn() {
 	s.lg.Info("starting aircraft prespawn")
 
-	// Prime the pump before the user gets involved
-	t := time.Now().Add(-(initialSimSeconds + 1) * time.Second)
+	// Prime the pump before the user gets involved; the sim clock is
+	// wound back so that it ends up at the scenario's start time.
+	start := s.SimTime
+	t := start.Add(-(initialSimSeconds + 1) * time.Second)
 	for i := 0; i < initialSimSeconds; i++ {
 		s.SimTime = t
-		s.lastUpdateTime = t
 		t = t.Add(1 * time.Second)
 
 		s.updateState()
 	}
-	s.SimTime = time.Now()
+	s.SimTime = start
 	s.World.SimTime = s.SimTime
 	s.lastUpdateTime = time.Now()
 
@@ -1643,13 +2034,21 @@ func (s *Sim) prespawn() {
 func (s *Sim) setInitialSpawnTimes() {
 	// Randomize next spawn time for departures and arrivals; may be before
 	// or after the current time.
+	now := s.SimTime
 	randomSpawn := func(rate int) time.Time {
 		if rate == 0 {
-			return time.Now().Add(365 * 24 * time.Hour)
+			return now.Add(365 * 24 * time.Hour)
 		}
 		avgWait := 3600 / rate
 		delta := rand.Intn(avgWait) - avgWait/2 - initialSimSeconds
-		return time.Now().Add(time.Duration(delta) * time.Second)
+		return now.Add(time.Duration(delta) * time.Second)
+	}
+	scheduledSpawn := func(rate int, arrival bool) time.Time {
+		if r := s.LaunchConfig.scheduledRate(rate, arrival, 0); r > 0 || rate == 0 {
+			return randomSpawn(r)
+		}
+		// The traffic schedule starts out quiet; wait for the first bank.
+		return now.Add(s.LaunchConfig.launchWait(rate, arrival, false, 0))
 	}
 
 	s.NextArrivalSpawn = make(map[string]time.Time)
@@ -1658,7 +2057,7 @@ func (s *Sim) setInitialSpawnTimes() {
 		for _, rate := range rates {
 			rateSum += rate
 		}
-		s.NextArrivalSpawn[group] = randomSpawn(rateSum)
+		s.NextArrivalSpawn[group] = scheduledSpawn(rateSum, true)
 	}
 
 	s.NextDepartureSpawn = make(map[string]time.Time)
@@ -1671,8 +2070,10 @@ func (s *Sim) setInitialSpawnTimes() {
 			}
 		}
 
-		s.NextDepartureSpawn[airport] = randomSpawn(rateSum)
+		s.NextDepartureSpawn[airport] = scheduledSpawn(rateSum, false)
 	}
+
+	s.NextVFRSpawn = randomSpawn(s.LaunchConfig.VFRRate)
 }
 
 func sampleRateMap(rates map[string]int) (string, int) {
@@ -1743,17 +2144,20 @@ func (s *Sim) spawnAircraft() {
 	}
 
 	pushActive := now.Before(s.PushEnd)
+	elapsed := now.Sub(s.ScriptState.Start)
 
 	for group, airportRates := range s.LaunchConfig.ArrivalGroupRates {
 		if now.After(s.NextArrivalSpawn[group]) {
 			arrivalAirport, rateSum := sampleRateMap(airportRates)
 
 			goAround := rand.Float32() < s.LaunchConfig.GoAroundRate
-			if ac, err := s.World.CreateArrival(group, arrivalAirport, goAround); err != nil {
+			if ac, err := s.createAircraft(func() (*Aircraft, error) {
+				return s.World.CreateArrival(group, arrivalAirport, goAround)
+			}); err != nil {
 				s.lg.Error("CreateArrival error: %v", err)
 			} else if ac != nil {
 				s.launchAircraftNoLock(*ac)
-				s.NextArrivalSpawn[group] = now.Add(randomWait(rateSum, pushActive))
+				s.NextArrivalSpawn[group] = now.Add(s.LaunchConfig.launchWait(rateSum, true, pushActive, elapsed))
 			}
 		}
 	}
@@ -1772,17 +2176,30 @@ func (s *Sim) spawnAircraft() {
 
 		prevDep := s.lastDeparture[airport][runway][category]
 		s.lg.Infof("%s/%s/%s: previous departure", airport, runway, category)
-		ac, dep, err := s.World.CreateDeparture(airport, runway, category,
-			s.LaunchConfig.DepartureChallenge, prevDep)
+		var dep *Departure
+		ac, err := s.createAircraft(func() (ac *Aircraft, err error) {
+			ac, dep, err = s.World.CreateDeparture(airport, runway, category,
+				s.LaunchConfig.DepartureChallenge, prevDep)
+			return
+		})
 		if err != nil {
 			s.lg.Errorf("CreateDeparture error: %v", err)
 		} else {
 			s.lastDeparture[airport][runway][category] = dep
 			s.lg.Infof("%s/%s/%s: launch departure", airport, runway, category)
 			s.launchAircraftNoLock(*ac)
-			s.NextDepartureSpawn[airport] = now.Add(randomWait(rateSum, false))
+			s.NextDepartureSpawn[airport] = now.Add(s.LaunchConfig.launchWait(rateSum, false, false, elapsed))
 		}
 	}
+
+	if now.After(s.NextVFRSpawn) {
+		if ac, err := s.createAircraft(s.World.CreateVFRAircraft); err != nil {
+			s.lg.Errorf("CreateVFRAircraft error: %v", err)
+		} else {
+			s.launchAircraftNoLock(*ac)
+		}
+		s.NextVFRSpawn = now.Add(randomWait(s.LaunchConfig.Adaptive.scaleRate(s.LaunchConfig.VFRRate), false))
+	}
 }
 
 ///////////////////////////////////////////////////////////////////////////
@@ -1801,6 +2218,44 @@ func (s *Sim) SetSimRate(token string, rate float32) error {
 	}
 }
 
+// SetRadarSiteFailed fails or restores the given radar site; it may only
+// be called by the launch controller, who acts as the instructor.
+func (s *Sim) SetRadarSiteFailed(token string, site string, failed bool) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if ctrl, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	} else if ctrl.Callsign != s.LaunchConfig.Controller {
+		return ErrNotLaunchController
+	} else {
+		return s.setRadarSiteFailed(site, failed)
+	}
+}
+
+func (s *Sim) setRadarSiteFailed(site string, failed bool) error {
+	if _, ok := s.World.RadarSites[site]; !ok {
+		return ErrUnknownRadarSite
+	}
+	if failed == s.FailedRadarSites[site] {
+		return nil
+	}
+
+	if failed {
+		if s.FailedRadarSites == nil {
+			s.FailedRadarSites = make(map[string]bool)
+		}
+		s.FailedRadarSites[site] = true
+		s.lg.Infof("%s: radar site failed", site)
+		s.eventStream.Post(Event{Type: StatusMessageEvent, Message: "Radar site " + site + " is out of service"})
+	} else {
+		delete(s.FailedRadarSites, site)
+		s.lg.Infof("%s: radar site restored", site)
+		s.eventStream.Post(Event{Type: StatusMessageEvent, Message: "Radar site " + site + " has been restored"})
+	}
+	return nil
+}
+
 func (s *Sim) SetLaunchConfig(token string, lc LaunchConfig) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
@@ -1810,7 +2265,13 @@ func (s *Sim) SetLaunchConfig(token string, lc LaunchConfig) error {
 	} else if ctrl.Callsign != s.LaunchConfig.Controller {
 		return ErrNotLaunchController
 	} else {
-		// Update the next spawn time for any rates that changed.
+		// The scale is maintained by the sim, not by the client.
+		lc.Adaptive.Scale = s.LaunchConfig.Adaptive.Scale
+
+		// Update the next spawn time for any rates that changed; if the
+		// traffic schedule was switched on or off, all of them change.
+		elapsed := s.SimTime.Sub(s.ScriptState.Start)
+		scheduleChanged := lc.UseSchedule != s.LaunchConfig.UseSchedule
 		for ap, rwyRates := range lc.DepartureRates {
 			newSum, oldSum := 0, 0
 			for rwy, categoryRates := range rwyRates {
@@ -1819,9 +2280,9 @@ func (s *Sim) SetLaunchConfig(token string, lc LaunchConfig) error {
 					oldSum += s.LaunchConfig.DepartureRates[ap][rwy][category]
 				}
 			}
-			if newSum != oldSum {
+			if newSum != oldSum || scheduleChanged {
 				s.lg.Infof("%s: departure rate changed %d -> %d", ap, oldSum, newSum)
-				s.NextDepartureSpawn[ap] = s.SimTime.Add(randomWait(newSum, false))
+				s.NextDepartureSpawn[ap] = s.SimTime.Add(lc.launchWait(newSum, false, false, elapsed))
 			}
 		}
 		for group, groupRates := range lc.ArrivalGroupRates {
@@ -1830,19 +2291,122 @@ func (s *Sim) SetLaunchConfig(token string, lc LaunchConfig) error {
 				newSum += rate
 				oldSum += s.LaunchConfig.ArrivalGroupRates[group][ap]
 			}
-			if newSum != oldSum {
+			if newSum != oldSum || scheduleChanged {
 				pushActive := s.SimTime.Before(s.PushEnd)
 				s.lg.Infof("%s: arrival rate changed %d -> %d", group, oldSum, newSum)
-				s.NextArrivalSpawn[group] = s.SimTime.Add(randomWait(newSum, pushActive))
+				s.NextArrivalSpawn[group] = s.SimTime.Add(lc.launchWait(newSum, true, pushActive, elapsed))
 			}
 
 		}
 
+		if lc.VFRRate != s.LaunchConfig.VFRRate {
+			s.lg.Infof("VFR rate changed %d -> %d", s.LaunchConfig.VFRRate, lc.VFRRate)
+			s.NextVFRSpawn = s.SimTime.Add(randomWait(lc.VFRRate, false))
+		}
+		if lc.PilotRequestRate != s.LaunchConfig.PilotRequestRate {
+			s.lg.Infof("pilot request rate changed %d -> %d", s.LaunchConfig.PilotRequestRate, lc.PilotRequestRate)
+			s.NextPilotRequest = s.SimTime.Add(randomWait(lc.PilotRequestRate, false))
+		}
+
 		s.LaunchConfig = lc
 		return nil
 	}
 }
 
+// SetArrivalRate sets the rate of arrivals to the given airport via the
+// given arrival group; it may only be called by the launch controller.
+func (s *Sim) SetArrivalRate(token, group, airport string, rate int) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if ctrl, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	} else if ctrl.Callsign != s.LaunchConfig.Controller {
+		return ErrNotLaunchController
+	} else {
+		return s.setArrivalRate(group, airport, rate)
+	}
+}
+
+func (s *Sim) setArrivalRate(group, airport string, rate int) error {
+	rates, ok := s.LaunchConfig.ArrivalGroupRates[group]
+	if !ok {
+		return ErrUnknownArrivalGroup
+	}
+	if _, ok := rates[airport]; !ok && !slices.ContainsFunc(s.World.ArrivalGroups[group], func(ar Arrival) bool {
+		_, ok := ar.Airlines[airport]
+		return ok
+	}) {
+		return ErrUnknownAirport
+	}
+	rate = max(0, rate)
+	if rates[airport] == rate {
+		return nil
+	}
+	s.lg.Infof("%s/%s: arrival rate changed %d -> %d", group, airport, rates[airport], rate)
+	rates[airport] = rate
+
+	// Reset the next spawn time so that the new rate takes effect
+	// promptly.
+	sum := 0
+	for _, r := range rates {
+		sum += r
+	}
+	s.NextArrivalSpawn[group] = s.SimTime.Add(s.LaunchConfig.launchWait(sum, true, s.SimTime.Before(s.PushEnd),
+		s.SimTime.Sub(s.ScriptState.Start)))
+	return nil
+}
+
+// SetDepartureRate sets the rate of departures of the given category from
+// the given airport and runway; it may only be called by the launch
+// controller.
+func (s *Sim) SetDepartureRate(token, airport, runway, category string, rate int) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if ctrl, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	} else if ctrl.Callsign != s.LaunchConfig.Controller {
+		return ErrNotLaunchController
+	} else {
+		return s.setDepartureRate(airport, runway, category, rate)
+	}
+}
+
+func (s *Sim) setDepartureRate(airport, runway, category string, rate int) error {
+	runwayRates, ok := s.LaunchConfig.DepartureRates[airport]
+	if !ok {
+		return ErrUnknownAirport
+	}
+	rate = max(0, rate)
+	if old, ok := runwayRates[runway][category]; ok && old == rate {
+		return nil
+	} else if !ok && !slices.ContainsFunc(s.World.DepartureRunways, func(r ScenarioGroupDepartureRunway) bool {
+		return r.Airport == airport && r.Runway == runway && r.Category == category
+	}) {
+		return ErrUnknownRunway
+	}
+	if runwayRates[runway] == nil {
+		runwayRates[runway] = make(map[string]int)
+	}
+	if s.lastDeparture[airport][runway] == nil {
+		s.lastDeparture[airport][runway] = make(map[string]*Departure)
+	}
+	s.lg.Infof("%s/%s/%s: departure rate changed %d -> %d", airport, runway, category,
+		runwayRates[runway][category], rate)
+	runwayRates[runway][category] = rate
+
+	sum := 0
+	for _, categoryRates := range runwayRates {
+		for _, r := range categoryRates {
+			sum += r
+		}
+	}
+	s.NextDepartureSpawn[airport] = s.SimTime.Add(s.LaunchConfig.launchWait(sum, false, false,
+		s.SimTime.Sub(s.ScriptState.Start)))
+	return nil
+}
+
 func (s *Sim) TakeOrReturnLaunchControl(token string) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
@@ -1884,15 +2448,21 @@ func (s *Sim) launchAircraftNoLock(ac Aircraft) {
 		return
 	}
 
+	s.assignLaunchSquawk(&ac)
 	s.World.Aircraft[ac.Callsign] = &ac
 
 	ac.Nav.Check(s.lg)
+	s.checkSimilarCallsigns(&ac)
 
-	if ac.IsDeparture() {
+	if ac.FlightPlan != nil && ac.FlightPlan.Rules == VFR {
+		s.lg.Info("launched VFR aircraft", slog.String("callsign", ac.Callsign), slog.Any("aircraft", ac))
+	} else if ac.IsDeparture() {
 		s.TotalDepartures++
+		s.requestDepartureRelease(&ac)
 		s.lg.Info("launched departure", slog.String("callsign", ac.Callsign), slog.Any("aircraft", ac))
 	} else {
 		s.TotalArrivals++
+		s.workload.launchedArrival(&ac, s.SimTime)
 		s.lg.Info("launched arrival", slog.String("callsign", ac.Callsign), slog.Any("aircraft", ac))
 	}
 }
@@ -1924,6 +2494,7 @@ func (s *Sim) dispatchCommand(token string, callsign string,
 			s.lg.Info("dispatch_command", slog.String("callsign", ac.Callsign),
 				slog.Any("prepost_aircraft", []Aircraft{preAc, *ac}),
 				slog.Any("radio_transmissions", radioTransmissions))
+			s.workload.command(ac.Callsign, s.SimTime)
 			PostRadioEvents(ac.Callsign, radioTransmissions, s)
 			return nil
 		}
@@ -1931,15 +2502,12 @@ func (s *Sim) dispatchCommand(token string, callsign string,
 }
 
 // Commands that are allowed by the controlling controller, who may not still have the track;
-// e.g., turns after handoffs.
+// e.g., turns after handoffs. The aircraft must be on the controller's frequency.
 func (s *Sim) dispatchControllingCommand(token string, callsign string,
 	cmd func(*Controller, *Aircraft) []RadioTransmission) error {
 	return s.dispatchCommand(token, callsign,
 		func(ctrl *Controller, ac *Aircraft) error {
-			if ac.ControllingController != ctrl.Callsign {
-				return ErrOtherControllerHasTrack
-			}
-			return nil
+			return onFrequency(ctrl, ac)
 		},
 		cmd)
 }
@@ -1974,6 +2542,10 @@ func (s *Sim) SetScratchpad(token, callsign, scratchpad string) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
 
+	if s.World.STARSFacilityAdaptation.CheckScratchpad(scratchpad, false) != nil {
+		return ErrInvalidScratchpad
+	}
+
 	return s.dispatchTrackingCommand(token, callsign,
 		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
 			ac.Scratchpad = scratchpad
@@ -1985,6 +2557,10 @@ func (s *Sim) SetSecondaryScratchpad(token, callsign, scratchpad string) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
 
+	if s.World.STARSFacilityAdaptation.CheckScratchpad(scratchpad, true) != nil {
+		return ErrInvalidScratchpad
+	}
+
 	return s.dispatchTrackingCommand(token, callsign,
 		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
 			ac.SecondaryScratchpad = scratchpad
@@ -1992,6 +2568,33 @@ func (s *Sim) SetSecondaryScratchpad(token, callsign, scratchpad string) error {
 		})
 }
 
+// AmendFlightPlan replaces the aircraft's flight plan. The tracking
+// controller may amend it at any time; any controller may amend the flight
+// plan of a departure that is still on the ground, as clearance delivery
+// does before it departs.
+func (s *Sim) AmendFlightPlan(token, callsign string, fp FlightPlan) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	return s.dispatchCommand(token, callsign,
+		func(ctrl *Controller, ac *Aircraft) error {
+			if ac.TrackingController != ctrl.Callsign && (!ac.IsDeparture() || ac.IsAirborne()) {
+				return ErrOtherControllerHasTrack
+			}
+			return nil
+		},
+		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
+			if ac.IsDeparture() && fp.Altitude != 0 {
+				// Departures climb to the (amended) requested altitude.
+				ac.Nav.FinalAltitude = float32(fp.Altitude)
+			}
+			ac.FlightPlan = &fp
+			s.lg.Info("flight plan amended", slog.String("callsign", ac.Callsign),
+				slog.String("controller", ctrl.Callsign), slog.Any("flight_plan", fp))
+			return nil
+		})
+}
+
 func (s *Sim) Ident(token, callsign string) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
@@ -2000,7 +2603,9 @@ func (s *Sim) Ident(token, callsign string) error {
 		func(c *Controller, ac *Aircraft) error {
 			// Can't ask for ident if they're on someone else's frequency.
 			if ac.ControllingController != "" && ac.ControllingController != c.Callsign {
-				return ErrOtherControllerHasTrack
+				return ErrNotOnFrequency
+			} else if ac.LostComms {
+				return ErrNoResponse
 			}
 			return nil
 		},
@@ -2133,28 +2738,13 @@ func (s *Sim) HandoffControl(token, callsign string) error {
 
 	return s.dispatchCommand(token, callsign,
 		func(ctrl *Controller, ac *Aircraft) error {
-			if ac.ControllingController != ctrl.Callsign {
-				return ErrOtherControllerHasTrack
-			}
-			return nil
+			return onFrequency(ctrl, ac)
 		},
 		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
 			var radioTransmissions []RadioTransmission
-			if octrl := s.World.GetControllerByCallsign(ac.TrackingController); octrl != nil {
-				name := Select(octrl.FullName != "", octrl.FullName, octrl.Callsign)
-				bye := Sample("good day", "seeya")
-				contact := Sample("contact ", "over to ", "")
-				goodbye := contact + name + " on " + octrl.Frequency.String() + ", " + bye
-				radioTransmissions = append(radioTransmissions, RadioTransmission{
-					Controller: ac.ControllingController,
-					Message:    goodbye,
-					Type:       RadioTransmissionReadback,
-				})
-				radioTransmissions = append(radioTransmissions, RadioTransmission{
-					Controller: ac.TrackingController,
-					Message:    ac.ContactMessage(s.ReportingPoints),
-					Type:       RadioTransmissionContact,
-				})
+			octrl := s.World.GetControllerByCallsign(ac.TrackingController)
+			if octrl != nil {
+				radioTransmissions = append(radioTransmissions, frequencyChange(ac, octrl))
 			} else {
 				radioTransmissions = append(radioTransmissions, RadioTransmission{
 					Controller: ac.ControllingController,
@@ -2170,11 +2760,17 @@ func (s *Sim) HandoffControl(token, callsign string) error {
 				Callsign:       ac.Callsign,
 			})
 
-			ac.ControllingController = ac.TrackingController
+			if octrl == nil {
+				ac.ControllingController = ac.TrackingController
+				return radioTransmissions
+			}
+
+			// The pilot checks in with the new controller once they've
+			// changed frequencies.
+			s.switchFrequency(ac, octrl.Callsign)
 
 			// Go ahead and climb departures the rest of the way and send
 			// them direct to their first fix (if they aren't already).
-			octrl := s.World.GetControllerByCallsign(ac.TrackingController)
 			if ac.IsDeparture() && !octrl.IsHuman {
 				s.lg.Info("departing on course", slog.String("callsign", ac.Callsign),
 					slog.Int("final_altitude", ac.FlightPlan.Altitude))
@@ -2204,19 +2800,31 @@ func (s *Sim) AcceptHandoff(token, callsign string) error {
 				Callsign:       ac.Callsign,
 			})
 
+			s.TrainingState.HandoffsAccepted++
+			if s.controllerIsSignedIn(ac.TrackingController) {
+				s.TrainingState.HandoffsMade++
+			}
+
 			ac.HandoffTrackController = ""
 			ac.TrackingController = ctrl.Callsign
-			if !s.controllerIsSignedIn(ac.ControllingController) {
-				// Take immediate control on handoffs from virtual
+			if _, ok := s.PendingContacts[ac.Callsign]; ok {
+				// Already changing frequencies.
+				return nil
+			} else if ac.ControllingController == "" {
+				// Not on anyone's frequency yet; take immediate control.
 				ac.ControllingController = ctrl.Callsign
 				return []RadioTransmission{RadioTransmission{
 					Controller: ctrl.Callsign,
 					Message:    ac.ContactMessage(s.ReportingPoints),
 					Type:       RadioTransmissionContact,
 				}}
-			} else {
-				return nil
+			} else if !s.controllerIsSignedIn(ac.ControllingController) {
+				// Virtual controllers switch the aircraft to the new
+				// controller's frequency as soon as the handoff is
+				// accepted.
+				s.switchFrequency(ac, ctrl.Callsign)
 			}
+			return nil
 		})
 }
 
@@ -0,0 +1,114 @@
+// simcheck.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Consistency checks run when a sim is created. The validation done when
+// scenarios are loaded catches most problems with the JSON, but some only
+// show up when an aircraft is actually created--e.g., an airline whose
+// fleet has no aircraft types in the database or a route that can't be
+// initialized. Rather than discovering these partway through a session
+// (or having the launch control window give up on them), we try to spawn
+// an aircraft for each departure and arrival stream when the sim is
+// created. Problems are logged, reported to controllers when they sign
+// on, and reported by -lint, which creates a sim for every scenario.
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// spawnCheckTries is the number of times we try to create an aircraft for
+// a departure or arrival stream before deciding that it's not possible;
+// aircraft are sampled randomly, so a few failures are expected.
+const spawnCheckTries = 100
+
+// CheckSpawns makes sure that an aircraft can be created for each of the
+// departure and arrival streams in the world's launch configuration.
+func (w *World) CheckSpawns(e *ErrorLogger) {
+	for _, airport := range SortedMapKeys(w.LaunchConfig.DepartureRates) {
+		runwayRates := w.LaunchConfig.DepartureRates[airport]
+		for _, rwy := range SortedMapKeys(runwayRates) {
+			for _, category := range SortedMapKeys(runwayRates[rwy]) {
+				e.Push(strings.TrimSpace("Departures " + airport + " " + rwy + " " + category))
+				var err error
+				for i := 0; i < spawnCheckTries; i++ {
+					if _, _, err = w.CreateDeparture(airport, rwy, category, 0, nil); err == nil {
+						break
+					}
+				}
+				if err != nil {
+					e.ErrorString("unable to create a departure: %v", err)
+				}
+				e.Pop()
+			}
+		}
+	}
+
+	for _, group := range SortedMapKeys(w.LaunchConfig.ArrivalGroupRates) {
+		for _, airport := range SortedMapKeys(w.LaunchConfig.ArrivalGroupRates[group]) {
+			e.Push("Arrivals " + group + " " + airport)
+			var err error
+			for i := 0; i < spawnCheckTries; i++ {
+				if _, err = w.CreateArrival(group, airport, false); err == nil {
+					break
+				}
+			}
+			if err != nil {
+				e.ErrorString("unable to create an arrival: %v", err)
+			}
+			e.Pop()
+		}
+	}
+}
+
+// checkConsistency runs the consistency checks for a newly-created sim,
+// recording any problems so that they can be reported to controllers.
+func (s *Sim) checkConsistency() {
+	s.World.CheckSpawns(&s.consistencyErrors)
+	for _, err := range s.consistencyErrors.Errors() {
+		s.lg.Error("scenario consistency error", slog.String("error", err.String()))
+	}
+}
+
+// postConsistencyErrors reports any errors found by checkConsistency as
+// status messages.
+func (s *Sim) postConsistencyErrors() {
+	for _, err := range s.consistencyErrors.Errors() {
+		s.eventStream.Post(Event{
+			Type:    StatusMessageEvent,
+			Message: "Scenario error: " + err.String(),
+		})
+	}
+}
+
+// lintSims creates a sim for each scenario and adds any errors from its
+// consistency checks to the given ErrorLogger.
+func lintSims(scenarioGroups map[string]map[string]*ScenarioGroup,
+	configs map[string]map[string]*SimConfiguration, e *ErrorLogger) {
+	for _, tracon := range SortedMapKeys(configs) {
+		for _, group := range SortedMapKeys(configs[tracon]) {
+			for _, name := range SortedMapKeys(configs[tracon][group].ScenarioConfigs) {
+				e.Push(tracon + " " + name)
+
+				config := NewSimConfiguration{
+					NewSimType: NewSimCreateLocal,
+					TRACONName: tracon,
+					TRACON:     configs[tracon],
+				}
+				config.SetScenario(group, name)
+
+				if sim := NewSim(config, scenarioGroups, true, lg); sim == nil {
+					e.ErrorString("unable to create sim")
+				} else {
+					for _, err := range sim.consistencyErrors.Errors() {
+						e.ErrorString("%s", err.String())
+					}
+				}
+
+				e.Pop()
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// simcheck_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckSpawns(t *testing.T) {
+	w := NewWorld()
+	w.Airports = map[string]*Airport{"KJFK": {}}
+	w.LaunchConfig.DepartureRates = map[string]map[string]map[string]int{
+		"KJFK": {"31L": {"": 10}}, // no matching departure runway
+		"KXXX": {"4": {"": 5}},    // unknown airport
+	}
+	w.LaunchConfig.ArrivalGroupRates = map[string]map[string]int{
+		"CAMRN": {"KJFK": 10}, // no arrival group
+	}
+
+	var e ErrorLogger
+	w.CheckSpawns(&e)
+
+	errs := e.Errors()
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	for i, ctx := range []string{"Departures KJFK 31L", "Departures KXXX 4", "Arrivals CAMRN KJFK"} {
+		if len(errs[i].Context) != 1 || errs[i].Context[0] != ctx {
+			t.Errorf("error %d: expected context %q, got %v", i, ctx, errs[i].Context)
+		}
+	}
+
+	w.LaunchConfig = LaunchConfig{}
+	e = ErrorLogger{}
+	w.CheckSpawns(&e)
+	if e.HaveErrors() {
+		t.Errorf("unexpected errors for an empty launch configuration: %s", e.String())
+	}
+}
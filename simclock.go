@@ -0,0 +1,50 @@
+// simclock.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// The simulation clock: Sim.SimTime advances with the sim rate and stops
+// when the sim is paused, independently of wallclock time. A scenario may
+// specify the zulu time at which the sim starts, e.g. for the evening
+// arrival push:
+//
+//	"start_time": "2245"
+//
+// Without "start_time", the sim starts at the current time. Everything
+// displayed to the controller (the STARS status area, coordination times,
+// flight plan times) is based on the sim clock.
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// parseZuluTime parses a time of day given as "HHMM" or "HHMMZ".
+func parseZuluTime(s string) (hour, minute int, err error) {
+	if len(s) == 5 && s[4] == 'Z' {
+		s = s[:4]
+	}
+	if len(s) != 4 {
+		return 0, 0, fmt.Errorf("%s: expected HHMM zulu time", s)
+	}
+	if hour, err = strconv.Atoi(s[:2]); err != nil || hour < 0 || hour > 23 {
+		return 0, 0, fmt.Errorf("%s: invalid hour", s)
+	}
+	if minute, err = strconv.Atoi(s[2:]); err != nil || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("%s: invalid minute", s)
+	}
+	return
+}
+
+// SimStartTime returns the time at which a sim running the scenario
+// should start, given the current wallclock time.
+func (s *Scenario) SimStartTime(now time.Time) time.Time {
+	hour, minute, err := parseZuluTime(s.StartTime)
+	if s.StartTime == "" || err != nil {
+		return now
+	}
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, time.UTC)
+}
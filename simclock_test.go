@@ -0,0 +1,35 @@
+// simclock_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimStartTime(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	sc := Scenario{}
+	if st := sc.SimStartTime(now); !st.Equal(now) {
+		t.Errorf("expected start at the current time, got %s", st)
+	}
+
+	sc.StartTime = "2245"
+	if st := sc.SimStartTime(now); !st.Equal(time.Date(2024, 3, 14, 22, 45, 0, 0, time.UTC)) {
+		t.Errorf("unexpected start time %s", st)
+	}
+
+	for _, s := range []string{"0000", "2359", "0630Z"} {
+		if _, _, err := parseZuluTime(s); err != nil {
+			t.Errorf("%s: unexpected error %v", s, err)
+		}
+	}
+	for _, s := range []string{"2400", "1260", "630", "12:30", "ABCD"} {
+		if _, _, err := parseZuluTime(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
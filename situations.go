@@ -0,0 +1,375 @@
+// situations.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Situations: the launch controller may capture the state of the sim at a
+// moment--the aircraft, the sim time, the spawn and script state, and so
+// forth--as a named situation and then restore it as many times as they
+// like, so that a trainee can re-run the same tricky traffic picture
+// until they get it right. Situations are kept by the Sim itself, so
+// they're available for remote sims as well and are kept along with it
+// when it's saved (see savedsims.go), but they're otherwise independent
+// of saved sims: restoring a situation doesn't change who is signed in
+// or the sim's configuration.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type Situation struct {
+	Name    string
+	Created time.Time // wallclock time
+	SimTime time.Time
+
+	Aircraft     map[string]*Aircraft
+	LaunchConfig LaunchConfig
+
+	NextDepartureSpawn map[string]time.Time
+	NextArrivalSpawn   map[string]time.Time
+	NextVFRSpawn       time.Time
+	NextPilotRequest   time.Time
+	NextPushStart      time.Time
+	PushEnd            time.Time
+
+	Handoffs        map[string]time.Time
+	PointOuts       map[string]map[string]PointOut
+	PendingContacts map[string]PendingContact
+
+	TotalDepartures int
+	TotalArrivals   int
+
+	FailedRadarSites     map[string]bool
+	RunwayDepartureTimes map[string]time.Time
+	RunwayOccupancy      map[string]RunwayOccupant
+	ScriptState          ScriptState
+	TrainingState        TrainingState
+}
+
+// SituationInfo summarizes a situation for the UI.
+type SituationInfo struct {
+	Name        string
+	Created     time.Time
+	SimTime     time.Time
+	NumAircraft int
+}
+
+// copySituation returns a deep copy of the situation so that the stored
+// one isn't affected when the sim runs on after it's captured or
+// restored.
+func copySituation(sit *Situation) (*Situation, error) {
+	b, err := json.Marshal(sit)
+	if err != nil {
+		return nil, err
+	}
+	var c Situation
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// captureSituation returns a copy of the current state of the sim; s.mu
+// must be held.
+func (s *Sim) captureSituation(name string) (*Situation, error) {
+	return copySituation(&Situation{
+		Name:                 name,
+		Created:              time.Now(),
+		SimTime:              s.SimTime,
+		Aircraft:             s.World.Aircraft,
+		LaunchConfig:         s.LaunchConfig,
+		NextDepartureSpawn:   s.NextDepartureSpawn,
+		NextArrivalSpawn:     s.NextArrivalSpawn,
+		NextVFRSpawn:         s.NextVFRSpawn,
+		NextPilotRequest:     s.NextPilotRequest,
+		NextPushStart:        s.NextPushStart,
+		PushEnd:              s.PushEnd,
+		Handoffs:             s.Handoffs,
+		PointOuts:            s.PointOuts,
+		PendingContacts:      s.PendingContacts,
+		TotalDepartures:      s.TotalDepartures,
+		TotalArrivals:        s.TotalArrivals,
+		FailedRadarSites:     s.FailedRadarSites,
+		RunwayDepartureTimes: s.RunwayDepartureTimes,
+		RunwayOccupancy:      s.RunwayOccupancy,
+		ScriptState:          s.ScriptState,
+		TrainingState:        s.TrainingState,
+	})
+}
+
+// restoreSituation returns the sim to the state in the given situation; s.mu
+// must be held. The launch controller is unchanged.
+func (s *Sim) restoreSituation(sit *Situation) error {
+	sit, err := copySituation(sit)
+	if err != nil {
+		return err
+	}
+
+	s.SimTime = sit.SimTime
+	s.World.Aircraft = sit.Aircraft
+	sit.LaunchConfig.Controller = s.LaunchConfig.Controller
+	s.LaunchConfig = sit.LaunchConfig
+	s.NextDepartureSpawn = sit.NextDepartureSpawn
+	s.NextArrivalSpawn = sit.NextArrivalSpawn
+	s.NextVFRSpawn = sit.NextVFRSpawn
+	s.NextPilotRequest = sit.NextPilotRequest
+	s.NextPushStart = sit.NextPushStart
+	s.PushEnd = sit.PushEnd
+	s.Handoffs = sit.Handoffs
+	s.PointOuts = sit.PointOuts
+	s.PendingContacts = sit.PendingContacts
+	s.TotalDepartures = sit.TotalDepartures
+	s.TotalArrivals = sit.TotalArrivals
+	s.FailedRadarSites = sit.FailedRadarSites
+	s.RunwayDepartureTimes = sit.RunwayDepartureTimes
+	s.RunwayOccupancy = sit.RunwayOccupancy
+	s.ScriptState = sit.ScriptState
+	s.TrainingState = sit.TrainingState
+	s.dependentHolds = nil
+	// The sim time may have gone backward; make sure that the next
+	// update isn't delayed until it catches up.
+	s.lastSimUpdate = time.Time{}
+	s.lastTrajectoryUpdate = time.Time{}
+
+	// Maps that were empty when the situation was captured may come back
+	// nil; the sim expects to be able to add to them.
+	if s.World.Aircraft == nil {
+		s.World.Aircraft = make(map[string]*Aircraft)
+	}
+	if s.NextDepartureSpawn == nil {
+		s.NextDepartureSpawn = make(map[string]time.Time)
+	}
+	if s.NextArrivalSpawn == nil {
+		s.NextArrivalSpawn = make(map[string]time.Time)
+	}
+	if s.Handoffs == nil {
+		s.Handoffs = make(map[string]time.Time)
+	}
+	if s.PointOuts == nil {
+		s.PointOuts = make(map[string]map[string]PointOut)
+	}
+	if s.FailedRadarSites == nil {
+		s.FailedRadarSites = make(map[string]bool)
+	}
+	return nil
+}
+
+func (s *Sim) launchController(token string) (*ServerController, error) {
+	if ctrl, ok := s.controllers[token]; !ok {
+		return nil, ErrInvalidControllerToken
+	} else if ctrl.Callsign != s.LaunchConfig.Controller {
+		return nil, ErrNotLaunchController
+	} else {
+		return ctrl, nil
+	}
+}
+
+// SaveSituation captures the current state of the sim as a situation with
+// the given name, replacing any existing one with that name.
+func (s *Sim) SaveSituation(token, name string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, err := s.launchController(token)
+	if err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrUnknownSituation
+	}
+
+	sit, err := s.captureSituation(name)
+	if err != nil {
+		return err
+	}
+	if s.Situations == nil {
+		s.Situations = make(map[string]*Situation)
+	}
+	s.Situations[name] = sit
+
+	s.lg.Info("saved situation", slog.String("name", name), slog.String("controller", ctrl.Callsign),
+		slog.Int("aircraft", len(sit.Aircraft)))
+	s.eventStream.Post(Event{
+		Type:    StatusMessageEvent,
+		Message: fmt.Sprintf("%s saved situation \"%s\"", ctrl.Callsign, name),
+	})
+	return nil
+}
+
+// RestoreSituation returns the sim to the state of the named situation.
+func (s *Sim) RestoreSituation(token, name string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, err := s.launchController(token)
+	if err != nil {
+		return err
+	}
+	sit, ok := s.Situations[name]
+	if !ok {
+		return ErrUnknownSituation
+	}
+
+	if err := s.restoreSituation(sit); err != nil {
+		return err
+	}
+
+	s.lg.Info("restored situation", slog.String("name", name), slog.String("controller", ctrl.Callsign))
+	s.eventStream.Post(Event{
+		Type:    StatusMessageEvent,
+		Message: fmt.Sprintf("%s restored situation \"%s\"", ctrl.Callsign, name),
+	})
+	return nil
+}
+
+func (s *Sim) DeleteSituation(token, name string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if _, err := s.launchController(token); err != nil {
+		return err
+	}
+	if _, ok := s.Situations[name]; !ok {
+		return ErrUnknownSituation
+	}
+	delete(s.Situations, name)
+	return nil
+}
+
+// ListSituations returns information about the sim's situations, most
+// recently created first.
+func (s *Sim) ListSituations(token string, result *[]SituationInfo) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if _, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	}
+
+	*result = nil
+	for _, sit := range s.Situations {
+		*result = append(*result, SituationInfo{
+			Name:        sit.Name,
+			Created:     sit.Created,
+			SimTime:     sit.SimTime,
+			NumAircraft: len(sit.Aircraft),
+		})
+	}
+	sort.Slice(*result, func(i, j int) bool { return (*result)[i].Created.After((*result)[j].Created) })
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// UI
+
+type SituationsModalClient struct {
+	world      *World
+	situations []SituationInfo
+	selected   int
+	name       string
+	err        error
+}
+
+func (sm *SituationsModalClient) Title() string { return "Situations" }
+
+func (sm *SituationsModalClient) Opening() {
+	sm.name = sm.world.SimTime.UTC().Format("1504")
+	sm.refresh()
+}
+
+func (sm *SituationsModalClient) refresh() {
+	sm.situations, sm.err = sm.world.simProxy.ListSituations()
+	sm.selected = -1
+}
+
+func (sm *SituationsModalClient) Buttons() []ModalDialogButton {
+	haveSelection := sm.selected >= 0 && sm.selected < len(sm.situations)
+	name := strings.TrimSpace(sm.name)
+
+	return []ModalDialogButton{
+		ModalDialogButton{text: "Close"},
+		ModalDialogButton{
+			text:     "Save",
+			disabled: name == "",
+			action: func() bool {
+				if sm.err = sm.world.simProxy.SaveSituation(name); sm.err == nil {
+					sm.refresh()
+				}
+				return false
+			},
+		},
+		ModalDialogButton{
+			text:     "Delete",
+			disabled: !haveSelection,
+			action: func() bool {
+				if sm.err = sm.world.simProxy.DeleteSituation(sm.situations[sm.selected].Name); sm.err == nil {
+					sm.refresh()
+				}
+				return false
+			},
+		},
+		ModalDialogButton{
+			text:     "Restore",
+			disabled: !haveSelection,
+			action: func() bool {
+				sm.err = sm.world.simProxy.RestoreSituation(sm.situations[sm.selected].Name)
+				return sm.err == nil
+			},
+		},
+	}
+}
+
+func (sm *SituationsModalClient) Draw() int {
+	imgui.InputTextV("Name", &sm.name, 0, nil)
+	if name := strings.TrimSpace(sm.name); slices.ContainsFunc(sm.situations,
+		func(si SituationInfo) bool { return si.Name == name }) {
+		imgui.Text("A situation with this name will be replaced.")
+	}
+	imgui.Separator()
+
+	if len(sm.situations) == 0 {
+		imgui.Text("No situations have been saved.")
+	} else {
+		flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg |
+			imgui.TableFlagsSizingStretchProp | imgui.TableFlagsScrollY
+		tableScale := Select(runtime.GOOS == "windows", platform.DPIScale(), float32(1))
+		if imgui.BeginTableV("situations", 3, flags, imgui.Vec2{tableScale * 500, tableScale * 250}, 0.) {
+			imgui.TableSetupColumn("Name")
+			imgui.TableSetupColumn("Sim time")
+			imgui.TableSetupColumn("Aircraft")
+			imgui.TableHeadersRow()
+
+			for i, si := range sm.situations {
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				if imgui.SelectableV(si.Name+"##situation", i == sm.selected,
+					imgui.SelectableFlagsSpanAllColumns, imgui.Vec2{}) {
+					sm.selected = i
+				}
+				imgui.TableNextColumn()
+				imgui.Text(si.SimTime.UTC().Format("15:04:05Z"))
+				imgui.TableNextColumn()
+				imgui.Text(fmt.Sprintf("%d", si.NumAircraft))
+			}
+			imgui.EndTable()
+		}
+	}
+
+	if sm.err != nil {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{1, .5, .5, 1})
+		imgui.Text(fmt.Sprintf("Error: %v", sm.err))
+		imgui.PopStyleColor()
+	}
+	return -1
+}
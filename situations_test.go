@@ -0,0 +1,70 @@
+// situations_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSituationRestore(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	ac := &Aircraft{Callsign: "AAL1", FlightPlan: &FlightPlan{ArrivalAirport: "KJFK"}}
+	ac.Nav.FlightState.Altitude = 8000
+
+	s := &Sim{
+		World:              &World{Aircraft: map[string]*Aircraft{"AAL1": ac}},
+		SimTime:            start,
+		LaunchConfig:       LaunchConfig{Controller: "JFK_APP"},
+		NextDepartureSpawn: map[string]time.Time{"KJFK": start.Add(time.Minute)},
+		TotalArrivals:      1,
+	}
+
+	sit, err := s.captureSituation("tricky")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Let the sim run on: the aircraft descends, another arrives, and
+	// launch control changes hands.
+	s.SimTime = start.Add(10 * time.Minute)
+	ac.Nav.FlightState.Altitude = 3000
+	s.World.Aircraft["UAL2"] = &Aircraft{Callsign: "UAL2"}
+	s.TotalArrivals = 2
+	s.NextDepartureSpawn["KJFK"] = start.Add(12 * time.Minute)
+	s.LaunchConfig.Controller = "N90_APP"
+
+	// Restore twice to make sure that the stored situation isn't changed
+	// by the sim running on after a restore.
+	for i := 0; i < 2; i++ {
+		if err := s.restoreSituation(sit); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !s.SimTime.Equal(start) {
+			t.Errorf("expected sim time %s, got %s", start, s.SimTime)
+		}
+		if len(s.World.Aircraft) != 1 || s.TotalArrivals != 1 {
+			t.Errorf("expected only AAL1, got %d aircraft, %d arrivals", len(s.World.Aircraft), s.TotalArrivals)
+		}
+		if restored, ok := s.World.Aircraft["AAL1"]; !ok || restored.Nav.FlightState.Altitude != 8000 {
+			t.Errorf("expected AAL1 at 8000, got %+v", restored)
+		} else if restored == ac {
+			t.Errorf("expected a copy of the aircraft")
+		}
+		if tm := s.NextDepartureSpawn["KJFK"]; !tm.Equal(start.Add(time.Minute)) {
+			t.Errorf("expected the departure spawn time to be restored, got %s", tm)
+		}
+		if s.LaunchConfig.Controller != "N90_APP" {
+			t.Errorf("expected the launch controller to be unchanged, got %q", s.LaunchConfig.Controller)
+		}
+		if s.Handoffs == nil || s.PointOuts == nil {
+			t.Errorf("expected non-nil maps after restoring")
+		}
+
+		s.World.Aircraft["AAL1"].Nav.FlightState.Altitude = 2000
+		s.World.Aircraft["DAL3"] = &Aircraft{Callsign: "DAL3"}
+	}
+}
@@ -0,0 +1,417 @@
+// smoketest.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Scenario smoke tests: the -smoketest command-line option runs a
+// scenario without the user interface, issues controller commands at
+// given times, and checks assertions about what happens, so that changes
+// to scenarios and to the navigation model can be regression tested. A
+// test is described in a JSON file, e.g.:
+//
+//	{
+//	    "tracon": "N90",
+//	    "scenario": "JFK 31L/31R",
+//	    "seed": 1,
+//	    "duration": 1200,
+//	    "commands": [
+//	        { "time": 0, "action": "spawn_arrival CAMRN KJFK" },
+//	        { "time": 30, "callsign": "#1", "commands": "D40 S210" }
+//	    ],
+//	    "assertions": [
+//	        { "description": "#1 descends to 4,000",
+//	          "callsign": "#1", "condition": "altitude <= 4100", "by": 600 },
+//	        { "description": "No losses of separation",
+//	          "condition": "deals == 0" }
+//	    ]
+//	}
+//
+// Commands are run the given number of seconds after the sim starts,
+// either as controller commands for an aircraft, as if they were typed
+// by the scenario's primary controller, or as one of the actions
+// described in script.go. Aircraft launched by "spawn_arrival" and
+// "spawn_departure" actions can be referred to as #1, #2, ... in the
+// order they were launched.
+//
+// Assertions use the expression language from script.go. Those that
+// give a callsign may also use the aircraft's "altitude", "ias",
+// "groundspeed", and "heading", as well as "exists", which is 0 once the
+// aircraft has landed or otherwise left the sim. An assertion must be
+// true at its "at" time, at some point by its "by" time, or, if neither
+// is given, throughout the test.
+//
+// If a directory is given, all of the JSON files in it are run. vice
+// exits with a non-zero status if any assertion fails.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var smokeTestAircraftVariables = []string{"altitude", "ias", "groundspeed", "heading", "exists"}
+
+type SmokeTest struct {
+	TRACON     string               `json:"tracon"`
+	Scenario   string               `json:"scenario"`
+	Seed       int64                `json:"seed,omitempty"`
+	Duration   int                  `json:"duration"` // seconds
+	Commands   []SmokeTestCommand   `json:"commands"`
+	Assertions []SmokeTestAssertion `json:"assertions"`
+
+	filename string
+}
+
+type SmokeTestCommand struct {
+	Time     int    `json:"time"`
+	Callsign string `json:"callsign,omitempty"`
+	Commands string `json:"commands,omitempty"`
+	Action   string `json:"action,omitempty"`
+
+	action scriptAction
+}
+
+type SmokeTestAssertion struct {
+	Description string `json:"description"`
+	Callsign    string `json:"callsign,omitempty"`
+	Condition   string `json:"condition"`
+	At          int    `json:"at,omitempty"`
+	By          int    `json:"by,omitempty"`
+
+	cond scriptExpr
+}
+
+type SmokeTestResult struct {
+	Test      string `json:"test"`
+	Assertion string `json:"assertion"`
+	Passed    bool   `json:"passed"`
+	Message   string `json:"message,omitempty"`
+}
+
+func LoadSmokeTest(filename string) (*SmokeTest, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var t SmokeTest
+	if err := UnmarshalJSON(b, &t); err != nil {
+		return nil, err
+	}
+	t.filename = filename
+
+	if t.TRACON == "" || t.Scenario == "" {
+		return nil, errors.New("\"tracon\" and \"scenario\" must be specified")
+	}
+
+	last := t.Duration
+	for i := range t.Commands {
+		c := &t.Commands[i]
+		if (c.Commands == "") == (c.Action == "") {
+			return nil, fmt.Errorf("command %d: exactly one of \"commands\" and \"action\" must be given", i+1)
+		}
+		if c.Commands != "" && c.Callsign == "" {
+			return nil, fmt.Errorf("command %d: \"callsign\" must be given with \"commands\"", i+1)
+		}
+		if c.Action != "" {
+			if c.action, err = parseScriptAction(c.Action); err != nil {
+				return nil, fmt.Errorf("command %d: %w", i+1, err)
+			}
+		}
+		last = max(last, c.Time)
+	}
+
+	for i := range t.Assertions {
+		a := &t.Assertions[i]
+		if a.Description == "" {
+			a.Description = a.Condition
+		}
+		if a.At != 0 && a.By != 0 {
+			return nil, fmt.Errorf("%s: only one of \"at\" and \"by\" may be given", a.Description)
+		}
+		vars := scriptVariables
+		if a.Callsign != "" {
+			vars = append(slices.Clone(vars), smokeTestAircraftVariables...)
+		}
+		if a.cond, err = parseScriptExprVars(a.Condition, vars); err != nil {
+			return nil, fmt.Errorf("%s: %w", a.Description, err)
+		}
+		last = max(last, max(a.At, a.By))
+	}
+
+	if last == 0 {
+		return nil, errors.New("\"duration\" must be specified")
+	}
+	t.Duration = last
+	return &t, nil
+}
+
+// smokeTestSim wraps a Sim that is run without a server or user
+// interface.
+type smokeTestSim struct {
+	sim   *Sim
+	sd    *SimDispatcher
+	token string
+	// Aircraft launched by the test's actions, in order.
+	spawned []string
+}
+
+func (t *SmokeTest) newSim(scenarioGroups map[string]map[string]*ScenarioGroup,
+	configs map[string]map[string]*SimConfiguration) (*smokeTestSim, error) {
+	traconConfigs, ok := configs[t.TRACON]
+	if !ok {
+		return nil, fmt.Errorf("%s: TRACON not found", t.TRACON)
+	}
+	group := ""
+	for _, name := range SortedMapKeys(scenarioGroups[t.TRACON]) {
+		if _, ok := scenarioGroups[t.TRACON][name].Scenarios[t.Scenario]; ok {
+			group = name
+		}
+	}
+	if group == "" {
+		return nil, fmt.Errorf("%s: scenario not found in %s", t.Scenario, t.TRACON)
+	}
+
+	config := NewSimConfiguration{
+		NewSimType: NewSimCreateLocal,
+		TRACONName: t.TRACON,
+		TRACON:     traconConfigs,
+		Seed:       t.Seed,
+	}
+	config.SetScenario(group, t.Scenario)
+
+	sim := NewSim(config, scenarioGroups, true, lg)
+	if sim == nil {
+		return nil, fmt.Errorf("%s: unable to create sim", t.Scenario)
+	}
+	sim.prespawn()
+	sim.Activate(lg)
+	_, token, err := sim.SignOn(sim.World.PrimaryController, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Commands are run through a SimDispatcher, as they are when they
+	// come from a client, but nothing runs the sim other than the test.
+	sm := NewSimManager(scenarioGroups, configs, lg)
+	sm.controllerTokenToSim[token] = sim
+
+	return &smokeTestSim{sim: sim, sd: &SimDispatcher{sm: sm}, token: token}, nil
+}
+
+// callsign maps #n references to the aircraft launched by the test.
+func (st *smokeTestSim) callsign(cs string) (string, error) {
+	if n, ok := strings.CutPrefix(cs, "#"); ok {
+		i, err := strconv.Atoi(n)
+		if err != nil || i < 1 {
+			return "", fmt.Errorf("%s: invalid aircraft reference", cs)
+		} else if i > len(st.spawned) {
+			return "", fmt.Errorf("%s: only %d aircraft have been launched", cs, len(st.spawned))
+		}
+		return st.spawned[i-1], nil
+	}
+	return cs, nil
+}
+
+func (st *smokeTestSim) runCommand(c SmokeTestCommand) error {
+	if c.Commands != "" {
+		callsign, err := st.callsign(c.Callsign)
+		if err != nil {
+			return err
+		}
+		var result AircraftCommandsResult
+		if err := st.sd.RunAircraftCommands(&AircraftCommandsArgs{
+			ControllerToken: st.token,
+			Callsign:        callsign,
+			Commands:        c.Commands,
+		}, &result); err != nil {
+			return err
+		} else if result.ErrorMessage != "" {
+			return fmt.Errorf("%s: %s: %s", callsign, result.RemainingInput, result.ErrorMessage)
+		}
+		return nil
+	}
+
+	s := st.sim
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	before := make(map[string]interface{})
+	for cs := range s.World.Aircraft {
+		before[cs] = nil
+	}
+	s.runScriptAction(c.action)
+	for _, cs := range SortedMapKeys(s.World.Aircraft) {
+		if _, ok := before[cs]; !ok {
+			st.spawned = append(st.spawned, cs)
+		}
+	}
+	return nil
+}
+
+func (st *smokeTestSim) eval(a SmokeTestAssertion) (bool, error) {
+	s := st.sim
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	env := s.scriptEnv()
+	if a.Callsign != "" {
+		callsign, err := st.callsign(a.Callsign)
+		if err != nil {
+			return false, err
+		}
+		if ac, ok := s.World.Aircraft[callsign]; ok {
+			env["exists"] = 1
+			env["altitude"] = float64(ac.Altitude())
+			env["ias"] = float64(ac.IAS())
+			env["groundspeed"] = float64(ac.GS())
+			env["heading"] = float64(ac.Heading())
+		}
+	}
+	return a.cond.eval(env) != 0, nil
+}
+
+// step advances the sim by one second.
+func (st *smokeTestSim) step() {
+	s := st.sim
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	s.SimTime = s.SimTime.Add(time.Second)
+	s.updateState()
+	s.World.SimTime = s.SimTime
+}
+
+// Run runs the test and returns the results for each of its assertions.
+func (t *SmokeTest) Run(scenarioGroups map[string]map[string]*ScenarioGroup,
+	configs map[string]map[string]*SimConfiguration) []SmokeTestResult {
+	results := make([]SmokeTestResult, len(t.Assertions))
+	for i, a := range t.Assertions {
+		results[i] = SmokeTestResult{Test: t.filename, Assertion: a.Description}
+	}
+	done := make([]bool, len(t.Assertions))
+	finish := func(i int, passed bool, msg string, args ...interface{}) {
+		results[i].Passed, results[i].Message = passed, fmt.Sprintf(msg, args...)
+		done[i] = true
+	}
+
+	st, err := t.newSim(scenarioGroups, configs)
+	if err != nil {
+		return []SmokeTestResult{SmokeTestResult{Test: t.filename, Message: err.Error()}}
+	}
+
+	for sec := 0; sec <= t.Duration; sec++ {
+		for _, c := range t.Commands {
+			if c.Time == sec {
+				if err := st.runCommand(c); err != nil {
+					return append(results, SmokeTestResult{
+						Test:    t.filename,
+						Message: fmt.Sprintf("%ds: %v", sec, err),
+					})
+				}
+			}
+		}
+
+		for i, a := range t.Assertions {
+			if done[i] || (a.At != 0 && sec != a.At) || (a.By != 0 && sec > a.By) {
+				continue
+			}
+
+			ok, err := st.eval(a)
+			if err != nil {
+				if a.At == 0 && a.By == 0 {
+					// The aircraft hasn't been launched yet.
+					continue
+				}
+				finish(i, false, "%ds: %v", sec, err)
+			} else if a.At != 0 && ok {
+				finish(i, true, "")
+			} else if a.At != 0 {
+				finish(i, false, "false at %ds", sec)
+			} else if a.By != 0 && ok {
+				finish(i, true, "true at %ds", sec)
+			} else if a.By == 0 && !ok {
+				finish(i, false, "false at %ds", sec)
+			}
+		}
+
+		st.step()
+	}
+
+	for i, a := range t.Assertions {
+		if !done[i] {
+			if a.By != 0 {
+				finish(i, false, "not true by %ds", a.By)
+			} else {
+				finish(i, true, "")
+			}
+		}
+	}
+	return results
+}
+
+// runSmokeTests runs the tests in the given file or directory, prints
+// the results, and returns the process exit code.
+func runSmokeTests(path string, jsonOutput bool) int {
+	files := []string{path}
+	if fi, err := os.Stat(path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	} else if fi.IsDir() {
+		files, _ = filepath.Glob(filepath.Join(path, "*.json"))
+		slices.Sort(files)
+	}
+
+	var e ErrorLogger
+	scenarioGroups, configs := LoadScenarioGroups(&e)
+	if e.HaveErrors() {
+		e.PrintErrors(nil)
+		return 1
+	}
+
+	var results []SmokeTestResult
+	for _, fn := range files {
+		t, err := LoadSmokeTest(fn)
+		if err != nil {
+			results = append(results, SmokeTestResult{Test: fn, Message: err.Error()})
+			continue
+		}
+		results = append(results, t.Run(scenarioGroups, configs)...)
+	}
+
+	if jsonOutput {
+		writeJSON(os.Stdout, results)
+	} else {
+		printSmokeTestResults(os.Stdout, results)
+	}
+
+	if slices.ContainsFunc(results, func(r SmokeTestResult) bool { return !r.Passed }) {
+		return 1
+	}
+	return 0
+}
+
+func printSmokeTestResults(w io.Writer, results []SmokeTestResult) {
+	failed := 0
+	for _, r := range results {
+		status := Select(r.Passed, "PASS", "FAIL")
+		if !r.Passed {
+			failed++
+		}
+		line := status + " " + r.Test
+		if r.Assertion != "" {
+			line += ": " + r.Assertion
+		}
+		if r.Message != "" {
+			line += " (" + r.Message + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(results)-failed, failed)
+}
@@ -0,0 +1,62 @@
+// smoketest_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSmokeTest(t *testing.T) {
+	dir := t.TempDir()
+	load := func(s string) (*SmokeTest, error) {
+		fn := filepath.Join(dir, "test.json")
+		if err := os.WriteFile(fn, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return LoadSmokeTest(fn)
+	}
+
+	st, err := load(`{ "tracon": "N90", "scenario": "JFK",
+  "commands": [ { "time": 0, "action": "spawn_arrival CAMRN KJFK" },
+                { "time": 30, "callsign": "#1", "commands": "D40" } ],
+  "assertions": [ { "callsign": "#1", "condition": "altitude <= 4100", "by": 600 },
+                  { "condition": "deals == 0" } ] }`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if st.Duration != 600 {
+		t.Errorf("expected duration to be inferred as 600, got %d", st.Duration)
+	}
+	if st.Assertions[1].Description != "deals == 0" {
+		t.Errorf("expected the condition to be used as the description")
+	}
+
+	for _, bad := range []string{
+		`{ "scenario": "JFK", "duration": 60 }`,
+		`{ "tracon": "N90", "scenario": "JFK" }`,
+		`{ "tracon": "N90", "scenario": "JFK", "commands": [ { "time": 10, "commands": "D40" } ] }`,
+		`{ "tracon": "N90", "scenario": "JFK", "commands": [ { "time": 10, "action": "fly_away" } ] }`,
+		// Aircraft variables require a callsign.
+		`{ "tracon": "N90", "scenario": "JFK", "assertions": [ { "condition": "altitude > 0", "at": 10 } ] }`,
+		`{ "tracon": "N90", "scenario": "JFK", "assertions": [ { "condition": "landed > 0", "at": 10, "by": 20 } ] }`,
+	} {
+		if _, err := load(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+
+	sts := &smokeTestSim{spawned: []string{"AAL1", "JBU2"}}
+	if cs, err := sts.callsign("#2"); err != nil || cs != "JBU2" {
+		t.Errorf("expected #2 to be JBU2, got %q (%v)", cs, err)
+	}
+	if cs, err := sts.callsign("DAL3"); err != nil || cs != "DAL3" {
+		t.Errorf("expected DAL3 to be passed through, got %q (%v)", cs, err)
+	}
+	if _, err := sts.callsign("#3"); err == nil {
+		t.Errorf("expected an error for an aircraft that hasn't been launched")
+	}
+}
@@ -0,0 +1,103 @@
+// spatial.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// SpatialIndex is a uniform grid over the scenario's region that makes it
+// cheap to find the items near a point. It's intended to be rebuilt each
+// time the positions of the things it stores change (e.g., once per
+// radar update), which is O(n); queries then only need to consider the
+// items in the nearby cells rather than scanning all of them.
+type SpatialIndex[T any] struct {
+	cellSize       float32 // nm
+	nmPerLongitude float32
+	cells          map[[2]int][]spatialIndexEntry[T]
+}
+
+type spatialIndexEntry[T any] struct {
+	p    Point2LL
+	item T
+}
+
+// MakeSpatialIndex returns a new SpatialIndex with cells of the given size
+// in nautical miles; queries are most efficient when their radius is
+// similar to the cell size.
+func MakeSpatialIndex[T any](cellSize float32, nmPerLongitude float32) *SpatialIndex[T] {
+	return &SpatialIndex[T]{
+		cellSize:       cellSize,
+		nmPerLongitude: nmPerLongitude,
+		cells:          make(map[[2]int][]spatialIndexEntry[T]),
+	}
+}
+
+func (si *SpatialIndex[T]) cell(p Point2LL) [2]int {
+	pnm := ll2nm(p, si.nmPerLongitude)
+	return [2]int{int(floor(pnm[0] / si.cellSize)), int(floor(pnm[1] / si.cellSize))}
+}
+
+// Clear removes all items from the index. The storage for each cell is
+// kept so that rebuilding the index doesn't require further allocation.
+func (si *SpatialIndex[T]) Clear() {
+	for c, entries := range si.cells {
+		clear(entries) // don't hold on to references to the items
+		si.cells[c] = entries[:0]
+	}
+}
+
+// Insert adds the item at the given position to the index.
+func (si *SpatialIndex[T]) Insert(p Point2LL, item T) {
+	c := si.cell(p)
+	si.cells[c] = append(si.cells[c], spatialIndexEntry[T]{p: p, item: item})
+}
+
+// Query calls the provided callback for each item within the given
+// distance in nautical miles of p, stopping early if it returns false.
+// The order in which items are visited is unspecified. It's safe to call
+// Query with a nil *SpatialIndex, in which case nothing is found.
+func (si *SpatialIndex[T]) Query(p Point2LL, radius float32, fn func(p Point2LL, item T) bool) {
+	if si == nil {
+		return
+	}
+
+	// Include an extra cell on each side to account for the difference
+	// between the flat projection used for cells and actual distances.
+	n := int(ceil(radius/si.cellSize)) + 1
+	c := si.cell(p)
+	for y := c[1] - n; y <= c[1]+n; y++ {
+		for x := c[0] - n; x <= c[0]+n; x++ {
+			for _, e := range si.cells[[2]int{x, y}] {
+				if nmdistance2ll(p, e.p) <= radius && !fn(e.p, e.item) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Nearby returns all of the items within the given distance in nautical
+// miles of p.
+func (si *SpatialIndex[T]) Nearby(p Point2LL, radius float32) []T {
+	var items []T
+	si.Query(p, radius, func(_ Point2LL, item T) bool {
+		items = append(items, item)
+		return true
+	})
+	return items
+}
+
+// updateAircraftIndex rebuilds the index of airborne aircraft; it is
+// called once a second with s.mu held.
+func (s *Sim) updateAircraftIndex() {
+	if s.aircraftIndex == nil {
+		s.aircraftIndex = MakeSpatialIndex[*Aircraft](5, s.World.NmPerLongitude)
+	} else {
+		s.aircraftIndex.Clear()
+	}
+
+	for _, ac := range s.World.Aircraft {
+		if ac.IsAirborne() {
+			s.aircraftIndex.Insert(ac.Position(), ac)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// spatial_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestSpatialIndex(t *testing.T) {
+	const nmPerLongitude = 45
+	si := MakeSpatialIndex[int](3, nmPerLongitude)
+
+	// Points on a 0.05 degree grid around (-75, 40).
+	var pts []Point2LL
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 20; j++ {
+			p := Point2LL{-75.5 + 0.05*float32(i), 39.5 + 0.05*float32(j)}
+			si.Insert(p, len(pts))
+			pts = append(pts, p)
+		}
+	}
+
+	for _, q := range []Point2LL{{-75, 40}, {-75.5, 39.5}, {-74.6, 40.3}, {-73, 40}} {
+		for _, r := range []float32{0.5, 3, 7.5} {
+			var expected []int
+			for i, p := range pts {
+				if nmdistance2ll(q, p) <= r {
+					expected = append(expected, i)
+				}
+			}
+
+			found := si.Nearby(q, r)
+			sort.Ints(found)
+			if len(found) != len(expected) {
+				t.Errorf("query %v radius %f: found %d items, expected %d", q, r, len(found), len(expected))
+				continue
+			}
+			for i := range found {
+				if found[i] != expected[i] {
+					t.Errorf("query %v radius %f: found %v, expected %v", q, r, found, expected)
+					break
+				}
+			}
+		}
+	}
+
+	// Early termination
+	n := 0
+	si.Query(Point2LL{-75, 40}, 10, func(Point2LL, int) bool { n++; return n < 3 })
+	if n != 3 {
+		t.Errorf("expected query to stop after 3 items, got %d", n)
+	}
+
+	si.Clear()
+	if items := si.Nearby(Point2LL{-75, 40}, 10); len(items) != 0 {
+		t.Errorf("expected no items after Clear, got %d", len(items))
+	}
+
+	var nilIndex *SpatialIndex[int]
+	if items := nilIndex.Nearby(Point2LL{-75, 40}, 10); len(items) != 0 {
+		t.Errorf("expected no items from nil index")
+	}
+}
+
+func BenchmarkSpatialIndexRebuild(b *testing.B) {
+	const nmPerLongitude = 45
+	var pts []Point2LL
+	for i := 0; i < 300; i++ {
+		pts = append(pts, Point2LL{-75.5 + 0.003*float32(i), 39.5 + 0.002*float32((i*37)%300)})
+	}
+	si := MakeSpatialIndex[int](3, nmPerLongitude)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		si.Clear()
+		for j, p := range pts {
+			si.Insert(p, j)
+		}
+		n := 0
+		for _, p := range pts {
+			si.Query(p, 3, func(Point2LL, int) bool { n++; return true })
+		}
+		if n < len(pts) {
+			b.Fatalf("expected each point to find at least itself")
+		}
+	}
+}
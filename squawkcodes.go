@@ -0,0 +1,286 @@
+// squawkcodes.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Beacon code assignment: a facility's adaptation may describe how beacon
+// codes are assigned, e.g.:
+//
+//	"squawk_codes": {
+//	    "local": [ "0101-0177", "0201-0277" ],
+//	    "local_flights": [ "departure", "vfr" ],
+//	    "reserved": { "KTEB": [ "0301-0317" ], "vfr": [ "0401-0477" ] }
+//	}
+//
+// Flights of the types ("departure", "arrival", or "vfr") given in
+// "local_flights" are assigned codes from the facility's local code
+// ranges; the others get NAS codes, as ERAM would assign them, which are
+// all of the codes that aren't local, reserved, or special purpose.
+// Ranges that are reserved for an airport are used first for its
+// departures and ones reserved for a flight type are used first for
+// flights of that type. Without "squawk_codes", all flights get NAS
+// codes.
+//
+// A code is never assigned to two aircraft at once. Arrivals enter
+// squawking the code that the center assigned them; if another aircraft
+// already has it, the flight is automatically recoded and the controller
+// must then issue the new code to the pilot.
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+var squawkFlightTypes = []string{"departure", "arrival", "vfr"}
+
+type SquawkCodeAdaptation struct {
+	Local        []string            `json:"local"`
+	LocalFlights []string            `json:"local_flights"`
+	Reserved     map[string][]string `json:"reserved"`
+}
+
+type SquawkRange [2]Squawk // inclusive
+
+func (r SquawkRange) Contains(sq Squawk) bool {
+	return sq >= r[0] && sq <= r[1]
+}
+
+// parseSquawkRanges parses code ranges like "0101-0177"; a single code
+// may also be given.
+func parseSquawkRanges(strs []string) ([]SquawkRange, error) {
+	var ranges []SquawkRange
+	for _, s := range strs {
+		lo, hi, ok := strings.Cut(s, "-")
+		if !ok {
+			hi = lo
+		}
+		l, err := ParseSquawk(lo)
+		if err != nil {
+			return nil, err
+		}
+		h, err := ParseSquawk(hi)
+		if err != nil {
+			return nil, err
+		}
+		if l > h {
+			return nil, fmt.Errorf("%s: invalid code range", s)
+		}
+		ranges = append(ranges, SquawkRange{l, h})
+	}
+	return ranges, nil
+}
+
+func (sc *SquawkCodeAdaptation) PostDeserialize(e *ErrorLogger, sg *ScenarioGroup) {
+	e.Push("squawk_codes")
+	defer e.Pop()
+
+	if _, err := parseSquawkRanges(sc.Local); err != nil {
+		e.Error(err)
+	}
+	for _, ft := range sc.LocalFlights {
+		if !slices.Contains(squawkFlightTypes, ft) {
+			e.ErrorString("\"%s\": unknown flight type in \"local_flights\". Options: %s", ft,
+				strings.Join(squawkFlightTypes, ", "))
+		}
+	}
+	for _, name := range SortedMapKeys(sc.Reserved) {
+		if _, ok := sg.Airports[name]; !ok && !slices.Contains(squawkFlightTypes, name) {
+			e.ErrorString("\"%s\": codes must be reserved for an airport or a flight type", name)
+		}
+		if _, err := parseSquawkRanges(sc.Reserved[name]); err != nil {
+			e.Error(err)
+		}
+	}
+}
+
+// isNASCode returns true if ERAM may assign the code: it must not be
+// used locally, reserved, or a special purpose code.
+func (sc *SquawkCodeAdaptation) isNASCode(sq Squawk) bool {
+	if sq < 0o0100 || sq%0o100 == 0 || sq == Squawk(0o1200) {
+		return false
+	}
+	if spc, _ := SquawkIsSPC(sq); spc {
+		return false
+	}
+
+	local, _ := parseSquawkRanges(sc.Local)
+	if slices.ContainsFunc(local, func(r SquawkRange) bool { return r.Contains(sq) }) {
+		return false
+	}
+	for _, strs := range sc.Reserved {
+		reserved, _ := parseSquawkRanges(strs)
+		if slices.ContainsFunc(reserved, func(r SquawkRange) bool { return r.Contains(sq) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// AssignCode returns a code for a flight of the given type from the
+// given airport (which may be empty) that isn't in use.
+func (sc *SquawkCodeAdaptation) AssignCode(flightType, airport string, inUse func(Squawk) bool) (Squawk, bool) {
+	sample := func(ranges []SquawkRange) (Squawk, bool) {
+		var codes []Squawk
+		for _, r := range ranges {
+			for sq := r[0]; sq <= r[1]; sq++ {
+				if !inUse(sq) {
+					codes = append(codes, sq)
+				}
+			}
+		}
+		if len(codes) == 0 {
+			return 0, false
+		}
+		return SampleSlice(codes), true
+	}
+
+	if airport != "" {
+		reserved, _ := parseSquawkRanges(sc.Reserved[airport])
+		if sq, ok := sample(reserved); ok {
+			return sq, true
+		}
+	}
+	reserved, _ := parseSquawkRanges(sc.Reserved[flightType])
+	if sq, ok := sample(reserved); ok {
+		return sq, true
+	}
+
+	if slices.Contains(sc.LocalFlights, flightType) {
+		local, _ := parseSquawkRanges(sc.Local)
+		if sq, ok := sample(local); ok {
+			return sq, true
+		}
+	}
+
+	// Random probing is plenty fast given how many NAS codes there are.
+	for i := 0; i < 1000; i++ {
+		if sq := Squawk(0o0100 + rand.Intn(0o7700)); sc.isNASCode(sq) && !inUse(sq) {
+			return sq, true
+		}
+	}
+	return 0, false
+}
+
+func squawkFlightType(ac *Aircraft) string {
+	if ac.FlightPlan != nil && ac.FlightPlan.Rules == VFR {
+		return "vfr"
+	} else if ac.IsDeparture() {
+		return "departure"
+	}
+	return "arrival"
+}
+
+// squawkInUse returns true if an aircraft other than the given one has
+// been assigned or is squawking the given code.
+func (s *Sim) squawkInUse(sq Squawk, callsign string) bool {
+	for cs, ac := range s.World.Aircraft {
+		if cs != callsign && (ac.AssignedSquawk == sq || ac.Squawk == sq) {
+			return true
+		}
+	}
+	return false
+}
+
+// assignSquawk returns a code for the aircraft following the facility's
+// code assignment policy.
+func (s *Sim) assignSquawk(ac *Aircraft) (Squawk, error) {
+	airport := ""
+	if ac.FlightPlan != nil && ac.IsDeparture() {
+		airport = ac.FlightPlan.DepartureAirport
+	}
+	sq, ok := s.World.STARSFacilityAdaptation.SquawkCodes.AssignCode(squawkFlightType(ac), airport,
+		func(sq Squawk) bool { return s.squawkInUse(sq, ac.Callsign) })
+	if !ok {
+		return 0, ErrNoAvailableBeaconCode
+	}
+	return sq, nil
+}
+
+// assignLaunchSquawk is called with s.mu held when an aircraft is
+// launched. Departures get a code following the facility's policy;
+// arrivals keep the code they entered with unless it's already in use,
+// in which case they're recoded.
+func (s *Sim) assignLaunchSquawk(ac *Aircraft) {
+	if ac.FlightPlan == nil || ac.FlightPlan.Rules == VFR {
+		return
+	}
+
+	if ac.IsDeparture() {
+		if sq, err := s.assignSquawk(ac); err == nil {
+			ac.AssignedSquawk, ac.Squawk = sq, sq
+		}
+	} else if s.squawkInUse(ac.Squawk, ac.Callsign) {
+		if sq, err := s.assignSquawk(ac); err == nil {
+			s.lg.Info("recoded arrival", slog.String("callsign", ac.Callsign),
+				slog.String("squawk", ac.Squawk.String()), slog.String("new_squawk", sq.String()))
+			s.PostEvent(Event{
+				Type:    StatusMessageEvent,
+				Message: fmt.Sprintf("%s squawking duplicate code %s, recoded to %s", ac.Callsign, ac.Squawk, sq),
+			})
+			ac.AssignedSquawk = sq
+		}
+	}
+}
+
+// SetSquawk assigns the given code to the aircraft's flight plan; the
+// pilot must still be told to squawk it.
+func (s *Sim) SetSquawk(token, callsign string, squawk Squawk) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	return s.dispatchCommand(token, callsign,
+		func(c *Controller, ac *Aircraft) error {
+			if ac.TrackingController != "" && ac.TrackingController != c.Callsign {
+				return ErrOtherControllerHasTrack
+			} else if s.squawkInUse(squawk, ac.Callsign) {
+				return ErrBeaconCodeInUse
+			}
+			return nil
+		},
+		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
+			ac.AssignedSquawk = squawk
+			return nil
+		})
+}
+
+// SetSquawkAutomatic assigns the aircraft a new code following the
+// facility's code assignment policy.
+func (s *Sim) SetSquawkAutomatic(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	var sq Squawk
+	return s.dispatchCommand(token, callsign,
+		func(c *Controller, ac *Aircraft) error {
+			if ac.TrackingController != "" && ac.TrackingController != c.Callsign {
+				return ErrOtherControllerHasTrack
+			}
+			var err error
+			sq, err = s.assignSquawk(ac)
+			return err
+		},
+		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
+			ac.AssignedSquawk = sq
+			return nil
+		})
+}
+
+// SquawkAssignedCode has the pilot set the transponder to the code that
+// the flight has been assigned.
+func (s *Sim) SquawkAssignedCode(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	return s.dispatchControllingCommand(token, callsign,
+		func(ctrl *Controller, ac *Aircraft) []RadioTransmission {
+			ac.Squawk = ac.AssignedSquawk
+			return []RadioTransmission{RadioTransmission{
+				Controller: ctrl.Callsign,
+				Message:    "squawk " + ac.AssignedSquawk.String(),
+				Type:       RadioTransmissionReadback,
+			}}
+		})
+}
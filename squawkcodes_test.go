@@ -0,0 +1,59 @@
+// squawkcodes_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestAssignSquawkCode(t *testing.T) {
+	sc := SquawkCodeAdaptation{
+		Local:        []string{"0101-0107"},
+		LocalFlights: []string{"departure"},
+		Reserved:     map[string][]string{"KTEB": {"0301-0302"}, "vfr": {"0401"}},
+	}
+	inUse := make(map[Squawk]bool)
+	isInUse := func(sq Squawk) bool { return inUse[sq] }
+
+	// Reserved codes for the airport are used first, then local ones.
+	for i := 0; i < 4; i++ {
+		sq, ok := sc.AssignCode("departure", "KTEB", isInUse)
+		if !ok {
+			t.Fatalf("no code assigned")
+		}
+		if i < 2 && (sq < 0o301 || sq > 0o302) {
+			t.Errorf("expected a KTEB code, got %s", sq)
+		} else if i >= 2 && (sq < 0o101 || sq > 0o107) {
+			t.Errorf("expected a local code, got %s", sq)
+		}
+		inUse[sq] = true
+	}
+
+	if sq, ok := sc.AssignCode("vfr", "", isInUse); !ok || sq != 0o401 {
+		t.Errorf("expected 0401 for VFR, got %s", sq)
+	}
+
+	// Arrivals get NAS codes.
+	for i := 0; i < 100; i++ {
+		sq, ok := sc.AssignCode("arrival", "", isInUse)
+		if !ok {
+			t.Fatalf("no code assigned")
+		}
+		if !sc.isNASCode(sq) || inUse[sq] {
+			t.Errorf("%s: not an available NAS code", sq)
+		}
+		if spc, _ := SquawkIsSPC(sq); spc || sq == 0o1200 || (sq >= 0o101 && sq <= 0o107) || sq == 0o301 {
+			t.Errorf("%s: unexpected NAS code", sq)
+		}
+		inUse[sq] = true
+	}
+
+	if _, err := parseSquawkRanges([]string{"0177-0101"}); err == nil {
+		t.Errorf("expected error for reversed range")
+	}
+	if _, err := parseSquawkRanges([]string{"0108-0190"}); err == nil {
+		t.Errorf("expected error for non-octal code")
+	}
+}
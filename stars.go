@@ -52,6 +52,13 @@ var (
 	STARSATPAWarningColor = RGB{1, 1, 0}
 	STARSATPAAlertColor   = RGB{1, .215, 0}
 
+	STARSSimilarCallsignColor = RGB{1, .6, .2}
+
+	// RGBs from STARS Manual, B-5
+	STARSWeatherLowColor     = RGBFromHex(0x254D4D)
+	STARSWeatherHighColor    = RGBFromHex(0x646433)
+	STARSWeatherStippleColor = RGBFromHex(0xFAFAFA)
+
 	STARSDCBButtonColor         = RGB{0, .4, 0}
 	STARSDCBActiveButtonColor   = RGB{0, .8, 0}
 	STARSDCBTextColor           = RGB{1, 1, 1}
@@ -67,6 +74,14 @@ type STARSPane struct {
 	CurrentPreferenceSet  STARSPreferenceSet
 	SelectedPreferenceSet int
 	PreferenceSets        []STARSPreferenceSet
+	// Saved preference sets are specific to a facility; PreferenceSets
+	// holds the ones for PreferenceSetsFacility and the others are
+	// stored here, indexed by TRACON.
+	PreferenceSetsFacility string
+	FacilityPreferenceSets map[string][]STARSPreferenceSet
+	// Preference set in effect before one was last recalled, for the
+	// PREF menu's RESTORE button.
+	restorePreferenceSet *STARSPreferenceSet
 
 	SystemMaps map[int]*STARSMap
 
@@ -89,7 +104,13 @@ type STARSPane struct {
 	// map[string]interface{}.
 	AutoTrackDepartures bool `json:"autotrack_departures"`
 	LockDisplay         bool
-	AirspaceAwareness   struct {
+	// Highlight the callsigns of aircraft with similar callsigns in their
+	// datablocks; see callsigns.go.
+	MarkSimilarCallsigns bool
+	// Don't show the syntax of matching aircraft commands while a
+	// command is being typed; see commandref.go.
+	HideCommandHelp   bool
+	AirspaceAwareness struct {
 		Interfacility bool
 		Intrafacility bool
 	}
@@ -113,6 +134,7 @@ type STARSPane struct {
 	scopeClickHandler   func(pw [2]float32, transforms ScopeTransformations) STARSCommandStatus
 	activeDCBMenu       int
 	selectedPlaceButton string
+	touch               TouchGestures
 
 	dwellAircraft     string
 	drawRouteAircraft string
@@ -127,6 +149,11 @@ type STARSPane struct {
 	lastTrackUpdate        time.Time
 	lastHistoryTrackUpdate time.Time
 	discardTracks          bool
+	// Callsigns indexed by radar track position; rebuilt with each
+	// track update.
+	trackIndex *SpatialIndex[string]
+	// Reused each frame for the list of visible aircraft.
+	visibleAircraftScratch []*Aircraft
 
 	drawApproachAirspace  bool
 	drawDepartureAirspace bool
@@ -413,6 +440,7 @@ type STARSAircraftState struct {
 	DatablockType            DatablockType
 	FullLDB                  time.Time // If the LDB displays the groundspeed. When to stop
 	DisplayRequestedAltitude *bool     // nil if unspecified
+	DisplayModeS             *bool     // nil if unspecified; see modes.go
 
 	IsSelected bool // middle click
 
@@ -553,6 +581,7 @@ type STARSMap struct {
 	Group         int           `json:"group"` // 0 -> A, 1 -> B
 	Name          string        `json:"name"`
 	CommandBuffer CommandBuffer `json:"command_buffer"`
+	LineStyle     LineStyle     `json:"line_style,omitempty"`
 }
 
 ///////////////////////////////////////////////////////////////////////////
@@ -623,7 +652,9 @@ type STARSPreferenceSet struct {
 
 	// TODO: review--should some of the below not be in prefs but be in STARSPane?
 
-	// DisplayUncorrelatedTargets bool // NOT USED
+	// Primary-only targets, from aircraft without transponders, aren't
+	// displayed if set.
+	HideUncorrelatedTargets bool
 
 	DisableCAWarnings bool
 	DisableMSAW       bool
@@ -643,6 +674,7 @@ type STARSPreferenceSet struct {
 	PTLOwn, PTLAll bool
 
 	DisplayRequestedAltitude bool
+	DisplayModeS             bool
 
 	DwellMode DwellMode
 
@@ -655,25 +687,11 @@ type STARSPreferenceSet struct {
 		TopDownMode bool
 	}
 
-	Brightness struct {
-		DCB                STARSBrightness
-		BackgroundContrast STARSBrightness
-		VideoGroupA        STARSBrightness
-		VideoGroupB        STARSBrightness
-		FullDatablocks     STARSBrightness
-		Lists              STARSBrightness
-		Positions          STARSBrightness
-		LimitedDatablocks  STARSBrightness
-		OtherTracks        STARSBrightness
-		Lines              STARSBrightness
-		RangeRings         STARSBrightness
-		Compass            STARSBrightness
-		BeaconSymbols      STARSBrightness
-		PrimarySymbols     STARSBrightness
-		History            STARSBrightness
-		Weather            STARSBrightness
-		WxContrast         STARSBrightness
-	}
+	Brightness STARSBrightnessSettings
+	// The brightness settings for night if it's currently day and vice
+	// versa; see daynight.go.
+	AlternateBrightness   STARSBrightnessSettings
+	NightBrightnessActive bool
 
 	CharSize struct {
 		DCB             int
@@ -730,6 +748,16 @@ type STARSPreferenceSet struct {
 		Visible  bool
 		Lines    int
 	}
+	InboundList struct {
+		Position [2]float32
+		Visible  bool
+		Lines    int
+	}
+	HoldList struct {
+		Position [2]float32
+		Visible  bool
+		Lines    int
+	}
 	SignOnList struct {
 		Position [2]float32
 		Visible  bool
@@ -838,8 +866,6 @@ func (sp *STARSPane) MakePreferenceSet(name string, w *World) STARSPreferenceSet
 	ps.AltitudeFilters.Unassociated = [2]int{100, 60000}
 	ps.AltitudeFilters.Associated = [2]int{100, 60000}
 
-	//ps.DisplayUncorrelatedTargets = true
-
 	ps.DisplayTPASize = true
 	ps.DisplayATPAWarningAlertCones = true
 
@@ -862,6 +888,7 @@ func (sp *STARSPane) MakePreferenceSet(name string, w *World) STARSPreferenceSet
 	ps.Brightness.History = 60
 	ps.Brightness.Weather = 30
 	ps.Brightness.WxContrast = 30
+	ps.AlternateBrightness = ps.Brightness.Dimmed()
 
 	for i := range ps.DisplayWeatherLevel {
 		ps.DisplayWeatherLevel[i] = true
@@ -913,11 +940,64 @@ func (sp *STARSPane) MakePreferenceSet(name string, w *World) STARSPreferenceSet
 	ps.TowerLists[2].Position = [2]float32{.05, .9}
 	ps.TowerLists[2].Lines = 5
 
+	ps.InboundList.Position = [2]float32{.8, .8}
+	ps.InboundList.Lines = 5
+	ps.InboundList.Visible = true
+
+	ps.HoldList.Position = [2]float32{.05, .35}
+	ps.HoldList.Lines = 5
+	ps.HoldList.Visible = true
+
+	// The facility adaptation may override the default list positions.
+	if w != nil {
+		for name, p := range w.STARSFacilityAdaptation.ListPositions {
+			if pos := ps.listPosition(name); pos != nil {
+				*pos = p
+			}
+		}
+	}
+
 	ps.ResetCRDAState(sp.ConvergingRunways)
 
 	return ps
 }
 
+// listPosition returns a pointer to the position of the system list with
+// the given name, or nil if there is no such list.
+func (ps *STARSPreferenceSet) listPosition(name string) *[2]float32 {
+	switch name {
+	case "preview":
+		return &ps.PreviewAreaPosition
+	case "ssa":
+		return &ps.SSAList.Position
+	case "tab":
+		return &ps.TABList.Position
+	case "vfr":
+		return &ps.VFRList.Position
+	case "alert":
+		return &ps.AlertList.Position
+	case "coast":
+		return &ps.CoastList.Position
+	case "inbound":
+		return &ps.InboundList.Position
+	case "hold":
+		return &ps.HoldList.Position
+	case "signon":
+		return &ps.SignOnList.Position
+	case "video_maps":
+		return &ps.VideoMapsList.Position
+	case "crda":
+		return &ps.CRDAStatusList.Position
+	case "tower1", "tower2", "tower3":
+		return &ps.TowerLists[name[5]-'1'].Position
+	default:
+		return nil
+	}
+}
+
+var starsListNames = []string{"preview", "ssa", "tab", "vfr", "alert", "coast", "inbound", "hold",
+	"signon", "video_maps", "crda", "tower1", "tower2", "tower3"}
+
 func (ps *STARSPreferenceSet) Duplicate() STARSPreferenceSet {
 	dupe := *ps
 	dupe.SelectedBeaconCodes = DuplicateSlice(ps.SelectedBeaconCodes)
@@ -940,6 +1020,22 @@ func (ps *STARSPreferenceSet) Activate(w *World) {
 		ps.RadarTrackHistoryRate = 4.5 // upgrade from old
 	}
 
+	if ps.InboundList.Lines == 0 { // upgrade from before the inbound and hold lists
+		ps.InboundList.Position = [2]float32{.8, .8}
+		ps.InboundList.Lines = 5
+		ps.InboundList.Visible = true
+		ps.HoldList.Position = [2]float32{.05, .35}
+		ps.HoldList.Lines = 5
+		ps.HoldList.Visible = true
+		if w != nil {
+			for _, name := range []string{"inbound", "hold"} {
+				if p, ok := w.STARSFacilityAdaptation.ListPositions[name]; ok {
+					*ps.listPosition(name) = p
+				}
+			}
+		}
+	}
+
 	// Brightness goes in steps of 5 (similarly not enforced previously...)
 	remapBrightness := func(b *STARSBrightness) {
 		*b = (*b + 2) / 5 * 5
@@ -963,6 +1059,11 @@ func (ps *STARSPreferenceSet) Activate(w *World) {
 	remapBrightness(&ps.Brightness.Weather)
 	remapBrightness(&ps.Brightness.WxContrast)
 
+	if ps.AlternateBrightness.DCB == 0 {
+		// Upgrade from before there were separate day and night settings.
+		ps.AlternateBrightness = ps.Brightness.Dimmed()
+	}
+
 	if ps.VideoMapVisible == nil {
 		ps.VideoMapVisible = make(map[string]interface{})
 		if w != nil && len(w.STARSMaps) > 0 {
@@ -1035,7 +1136,7 @@ func (sp *STARSPane) flightPlanSTARS(w *World, ac *Aircraft) (string, error) {
 				result += fp.DepartureAirport[1:] + " "
 			}
 			result += "D" + fmtTime(state.FirstRadarTrack) + " "
-			result += fmt.Sprintf("%03d", int(ac.Altitude())/100) + "\n"
+			result += fmt.Sprintf("%03d", w.ModeCAltitude(ac)/100) + "\n"
 
 			result += ac.Scratchpad + " "
 			result += "R" + fmt.Sprintf("%03d", fp.Altitude/100) + " "
@@ -1047,7 +1148,7 @@ func (sp *STARSPane) flightPlanSTARS(w *World, ac *Aircraft) (string, error) {
 		result += numType + " "
 		result += ac.AssignedSquawk.String() + " "
 		result += owner + " "
-		result += fmt.Sprintf("%03d", int(ac.Altitude())/100) + "\n"
+		result += fmt.Sprintf("%03d", w.ModeCAltitude(ac)/100) + "\n"
 
 		// Use the last item in the route for the entry fix
 		routeFields := strings.Fields(fp.Route)
@@ -1080,6 +1181,26 @@ func (b STARSBrightness) ScaleRGB(r RGB) RGB {
 	return r.Scale(float32(b) / 100)
 }
 
+type STARSBrightnessSettings struct {
+	DCB                STARSBrightness
+	BackgroundContrast STARSBrightness
+	VideoGroupA        STARSBrightness
+	VideoGroupB        STARSBrightness
+	FullDatablocks     STARSBrightness
+	Lists              STARSBrightness
+	Positions          STARSBrightness
+	LimitedDatablocks  STARSBrightness
+	OtherTracks        STARSBrightness
+	Lines              STARSBrightness
+	RangeRings         STARSBrightness
+	Compass            STARSBrightness
+	BeaconSymbols      STARSBrightness
+	PrimarySymbols     STARSBrightness
+	History            STARSBrightness
+	Weather            STARSBrightness
+	WxContrast         STARSBrightness
+}
+
 ///////////////////////////////////////////////////////////////////////////
 // STARSPane proper
 
@@ -1150,6 +1271,8 @@ func (sp *STARSPane) Deactivate() {
 }
 
 func (sp *STARSPane) ResetWorld(w *World) {
+	sp.selectFacilityPreferenceSets(w.TRACON)
+
 	ps := &sp.CurrentPreferenceSet
 
 	ps.Center = w.Center
@@ -1194,6 +1317,137 @@ func (sp *STARSPane) ResetWorld(w *World) {
 	sp.lastTrackUpdate = time.Time{} // force update
 }
 
+// selectFacilityPreferenceSets makes the saved preference sets for the
+// given facility available, stashing the ones for the previous facility.
+func (sp *STARSPane) selectFacilityPreferenceSets(facility string) {
+	if facility == sp.PreferenceSetsFacility {
+		return
+	}
+
+	if sp.FacilityPreferenceSets == nil {
+		sp.FacilityPreferenceSets = make(map[string][]STARSPreferenceSet)
+	}
+	if sp.PreferenceSetsFacility != "" {
+		sp.FacilityPreferenceSets[sp.PreferenceSetsFacility] = sp.PreferenceSets
+		sp.PreferenceSets = sp.FacilityPreferenceSets[facility]
+	} else if sets, ok := sp.FacilityPreferenceSets[facility]; ok {
+		sp.PreferenceSets = sets
+	}
+	// Otherwise, preference sets that were saved before they were
+	// stored per-facility go to the first facility used.
+
+	sp.PreferenceSetsFacility = facility
+	sp.SelectedPreferenceSet = -1
+	sp.restorePreferenceSet = nil
+}
+
+// recallPreferenceSet makes the specified saved preference set current.
+func (sp *STARSPane) recallPreferenceSet(i int, ctx *PaneContext) {
+	prev := sp.CurrentPreferenceSet.Duplicate()
+	sp.restorePreferenceSet = &prev
+
+	sp.SelectedPreferenceSet = i
+	sp.setCurrentPreferenceSet(sp.PreferenceSets[i].Duplicate(), ctx)
+}
+
+// setCurrentPreferenceSet replaces the current preferences with the given
+// ones and activates them, restarting the weather radar at the new
+// center.
+func (sp *STARSPane) setCurrentPreferenceSet(ps STARSPreferenceSet, ctx *PaneContext) {
+	sp.CurrentPreferenceSet = ps
+	sp.CurrentPreferenceSet.Activate(ctx.world)
+	sp.weatherRadar.Activate(sp.CurrentPreferenceSet.Center, ctx.renderer)
+}
+
+// savePreferenceSet saves the current preference set as the i-th one,
+// which may either replace an existing one or be the next one.
+func (sp *STARSPane) savePreferenceSet(i int, name string) error {
+	if i < 0 || i >= NumSTARSPreferenceSets || i > len(sp.PreferenceSets) {
+		return ErrSTARSIllegalPrefSet
+	}
+
+	psave := sp.CurrentPreferenceSet.Duplicate()
+	if i == len(sp.PreferenceSets) {
+		psave.Name = name
+		sp.PreferenceSets = append(sp.PreferenceSets, psave)
+	} else {
+		psave.Name = Select(name != "", name, sp.PreferenceSets[i].Name)
+		sp.PreferenceSets[i] = psave
+	}
+	sp.SelectedPreferenceSet = i
+	globalConfig.Save()
+	return nil
+}
+
+// executePrefCommand handles the .PREF commands: ".PREF n" recalls the
+// n-th preference set, ".PREF SAVE n [name]" saves the current
+// preferences to it, ".PREF DELETE n" deletes it, and ".PREF DEFAULT" and
+// ".PREF RESTORE" match the corresponding buttons in the PREF menu.
+func (sp *STARSPane) executePrefCommand(args []string, ctx *PaneContext) (status STARSCommandStatus) {
+	prefIndex := func(s string) (int, bool) {
+		n, err := strconv.Atoi(s)
+		return n - 1, err == nil && n >= 1 && n <= len(sp.PreferenceSets)
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "DEFAULT":
+		prev := sp.CurrentPreferenceSet.Duplicate()
+		sp.restorePreferenceSet = &prev
+		sp.SelectedPreferenceSet = -1
+		sp.setCurrentPreferenceSet(sp.MakePreferenceSet("", ctx.world), ctx)
+
+	case len(args) == 1 && args[0] == "RESTORE":
+		if sp.restorePreferenceSet == nil {
+			status.err = ErrSTARSIllegalFunction
+			return
+		}
+		ps := *sp.restorePreferenceSet
+		sp.restorePreferenceSet = nil
+		sp.setCurrentPreferenceSet(ps, ctx)
+
+	case len(args) == 1:
+		if i, ok := prefIndex(args[0]); ok {
+			sp.recallPreferenceSet(i, ctx)
+			status.output = "PREF SET " + strconv.Itoa(i+1) + " " + sp.CurrentPreferenceSet.Name
+		} else {
+			status.err = ErrSTARSIllegalPrefSet
+			return
+		}
+
+	case len(args) >= 2 && args[0] == "SAVE":
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			status.err = ErrSTARSCommandFormat
+			return
+		}
+		if status.err = sp.savePreferenceSet(n-1, strings.Join(args[2:], " ")); status.err != nil {
+			return
+		}
+		status.output = "PREF SET " + strconv.Itoa(n) + " SAVED"
+
+	case len(args) == 2 && args[0] == "DELETE":
+		if i, ok := prefIndex(args[1]); ok {
+			sp.PreferenceSets = DeleteSliceElement(sp.PreferenceSets, i)
+			if sp.SelectedPreferenceSet == i {
+				sp.SelectedPreferenceSet = -1
+			} else if sp.SelectedPreferenceSet > i {
+				sp.SelectedPreferenceSet--
+			}
+			globalConfig.Save()
+		} else {
+			status.err = ErrSTARSIllegalPrefSet
+			return
+		}
+
+	default:
+		status.err = ErrSTARSCommandFormat
+		return
+	}
+
+	status.clear = true
+	return
+}
+
 func (sp *STARSPane) makeSystemMaps(w *World) map[int]*STARSMap {
 	maps := make(map[int]*STARSMap)
 
@@ -1222,12 +1476,64 @@ func (sp *STARSPane) makeSystemMaps(w *World) map[int]*STARSMap {
 	ReturnLinesDrawBuilder(ld)
 	maps[401] = mvas
 
+	// Class B, C, and D airspace, labeled with each shelf's ceiling and
+	// floor in hundreds of feet.
+	for i, class := range []string{"B", "C", "D"} {
+		sm := &STARSMap{
+			Label: "CLASS " + class,
+			Name:  "CLASS " + class + " AIRSPACE",
+		}
+		ld := GetLinesDrawBuilder()
+		for _, ca := range w.ClassAirspace {
+			if ca.Class != class {
+				continue
+			}
+			for _, vol := range ca.Volumes {
+				vol.GenerateDrawCommands(&sm.CommandBuffer, w.NmPerLongitude)
+
+				var p [2]float32
+				if vol.Type == AirspaceVolumeCircle {
+					// Label it just inside its edge.
+					p = vol.Center
+					p[0] += (vol.Radius - 1) / w.NmPerLongitude
+				} else {
+					p = Extent2DFromPoints(MapSlice(vol.Vertices, func(p Point2LL) [2]float32 { return p })).Center()
+				}
+				ld.AddNumber(p, 0.005, fmt.Sprintf("%d", vol.Ceiling/100))
+				ld.AddNumber([2]float32{p[0], p[1] - 0.015}, 0.005, fmt.Sprintf("%d", vol.Floor/100))
+			}
+		}
+		ld.GenerateCommands(&sm.CommandBuffer)
+		ReturnLinesDrawBuilder(ld)
+		maps[402+i] = sm
+	}
+
+	// Terrain contours every 1,000', if we have terrain data.
+	if t := LookupTerrain(w.TRACON); t != nil && t.Grid != nil {
+		sm := &STARSMap{
+			Label:     "TERRAIN",
+			Name:      "TERRAIN CONTOURS",
+			LineStyle: LineStyleDashed,
+		}
+		d := [2]float32{w.Range / w.NmPerLongitude, w.Range / 60}
+		extent := Extent2DFromPoints([][2]float32{sub2f(w.Center, d), add2f(w.Center, d)})
+
+		ld := GetLinesDrawBuilder()
+		for _, l := range t.ContourLines(extent, 1000) {
+			ld.AddLine(l[0], l[1])
+		}
+		ld.GenerateCommands(&sm.CommandBuffer)
+		ReturnLinesDrawBuilder(ld)
+		maps[405] = sm
+	}
+
 	// Radar maps
 	radarIndex := 701
 	for _, name := range SortedMapKeys(w.RadarSites) {
 		sm := &STARSMap{
-			Label: name + "RCM",
-			Name:  name + " RADAR COVERAGE MAP",
+			Label:     name + "RCM",
+			Name:      name + " RADAR COVERAGE MAP",
+			LineStyle: LineStyleDashed,
 		}
 
 		site := w.RadarSites[name]
@@ -1272,6 +1578,25 @@ func (sp *STARSPane) makeSystemMaps(w *World) map[int]*STARSMap {
 func (sp *STARSPane) DrawUI() {
 	imgui.Checkbox("Auto track departures", &sp.AutoTrackDepartures)
 	imgui.Checkbox("Lock display", &sp.LockDisplay)
+	imgui.Checkbox("Mark similar callsigns in datablocks", &sp.MarkSimilarCallsigns)
+	showHelp := !sp.HideCommandHelp
+	if imgui.Checkbox("Show command help while typing", &showHelp) {
+		sp.HideCommandHelp = !showHelp
+	}
+
+	// The same character sizes as the DCB's CHAR SIZE menu.
+	ps := &sp.CurrentPreferenceSet
+	charSize := func(label string, size *int, maxSize int32) {
+		s := int32(*size)
+		if imgui.SliderInt(label+" character size", &s, 0, maxSize) {
+			*size = int(s)
+		}
+	}
+	charSize("Datablock", &ps.CharSize.Datablocks, 5)
+	charSize("List", &ps.CharSize.Lists, 5)
+	charSize("DCB", &ps.CharSize.DCB, 2)
+	charSize("Tools", &ps.CharSize.Tools, 5)
+	charSize("Position symbol", &ps.CharSize.PositionSymbols, 5)
 }
 
 func (sp *STARSPane) CanTakeKeyboardFocus() bool { return true }
@@ -1319,6 +1644,11 @@ func (sp *STARSPane) processEvents(w *World) {
 		warn := slices.ContainsFunc(mvas, func(mva MVA) bool {
 			return mva.Inside(ac.Position()) && ac.Altitude() < float32(mva.MinimumLimit)
 		})
+		// Also warn if it's less than 500' above nearby terrain or
+		// obstacles, when we know about them.
+		if t := LookupTerrain(w.TRACON); t != nil && !warn {
+			warn = ac.Altitude() < t.HighestPoint(ac.Position(), 1, w.NmPerLongitude)+500
+		}
 
 		if !warn && state.InhibitMSAW {
 			// The warning has cleared, so the inhibit is disabled (p.7-25)
@@ -1485,12 +1815,16 @@ func (sp *STARSPane) Draw(ctx *PaneContext, cb *CommandBuffer) {
 	sp.processEvents(ctx.world)
 	sp.updateRadarTracks(ctx.world)
 
+	sp.CurrentPreferenceSet.UpdateDayNight(globalConfig.DayNight.IsNight(ctx.world))
 	ps := sp.CurrentPreferenceSet
 
 	// Clear to background color
 	cb.ClearRGB(ps.Brightness.BackgroundContrast.ScaleRGB(STARSBackgroundColor))
 
 	sp.processKeyboardInput(ctx)
+	if !sp.HideCommandHelp && sp.commandMode == CommandModeNone && ctx.mouse != nil {
+		uiDrawCommandHelp(sp.previewAreaInput)
+	}
 
 	transforms := GetScopeTransformations(ctx.paneExtent, ctx.world.MagneticVariation, ctx.world.NmPerLongitude,
 		ps.CurrentCenter, float32(ps.Range), 0)
@@ -1541,6 +1875,7 @@ func (sp *STARSPane) Draw(ctx *PaneContext, cb *CommandBuffer) {
 			color = ps.Brightness.VideoGroupB.ScaleRGB(STARSMapColor)
 		}
 		cb.SetRGB(color)
+		cb.LineStyle(vmap.LineStyle)
 		transforms.LoadLatLongViewingMatrices(cb)
 		cb.Call(vmap.CommandBuffer)
 	}
@@ -1548,9 +1883,11 @@ func (sp *STARSPane) Draw(ctx *PaneContext, cb *CommandBuffer) {
 	for _, idx := range SortedMapKeys(ps.SystemMapVisible) {
 		color := ps.Brightness.VideoGroupA.ScaleRGB(STARSMapColor)
 		cb.SetRGB(color)
+		cb.LineStyle(sp.SystemMaps[idx].LineStyle)
 		transforms.LoadLatLongViewingMatrices(cb)
 		cb.Call(sp.SystemMaps[idx].CommandBuffer)
 	}
+	cb.LineStyle(LineStyleSolid)
 
 	ctx.world.DrawScenarioRoutes(transforms, sp.systemFont[ps.CharSize.Tools],
 		ps.Brightness.Lists.ScaleRGB(STARSListColor), cb)
@@ -1573,7 +1910,8 @@ func (sp *STARSPane) Draw(ctx *PaneContext, cb *CommandBuffer) {
 	// which can cause shimmering when datablocks overlap (especially if
 	// one is selected). We'll go with alphabetical by callsign, with the
 	// selected aircraft, if any, always drawn last.
-	aircraft := sp.visibleAircraft(ctx.world)
+	sp.visibleAircraftScratch = sp.appendVisibleAircraft(sp.visibleAircraftScratch[:0], ctx.world)
+	aircraft := sp.visibleAircraftScratch
 	sort.Slice(aircraft, func(i, j int) bool {
 		return aircraft[i].Callsign < aircraft[j].Callsign
 	})
@@ -1595,29 +1933,30 @@ func (sp *STARSPane) Draw(ctx *PaneContext, cb *CommandBuffer) {
 
 	ghosts := sp.getGhostAircraft(aircraft, ctx)
 	sp.drawGhosts(ghosts, ctx, transforms, cb)
+	sp.applyControls(ctx)
 	sp.consumeMouseEvents(ctx, ghosts, transforms, cb)
 	sp.drawMouseCursor(ctx, paneExtent, transforms, cb)
 
-	// Play the CA sound if any CAs or MSAWs are unacknowledged
-	playAlertSound := !ps.DisableCAWarnings && slices.ContainsFunc(sp.CAAircraft,
+	// Play the CA and MSAW sounds if any CAs or MSAWs are unacknowledged
+	playCASound := !ps.DisableCAWarnings && slices.ContainsFunc(sp.CAAircraft,
 		func(ca CAAircraft) bool {
 			return !ca.Acknowledged && !sp.Aircraft[ca.Callsigns[0]].DisableCAWarnings &&
 				!sp.Aircraft[ca.Callsigns[1]].DisableCAWarnings
 		})
-	if !ps.DisableMSAW {
-		for _, ac := range aircraft {
-			state := sp.Aircraft[ac.Callsign]
-			if state.MSAW && !state.MSAWAcknowledged && !state.InhibitMSAW && !state.DisableMSAW {
-				playAlertSound = true
-				break
-			}
+	playMSAWSound := !ps.DisableMSAW && slices.ContainsFunc(aircraft, func(ac *Aircraft) bool {
+		state := sp.Aircraft[ac.Callsign]
+		return state.MSAW && !state.MSAWAcknowledged && !state.InhibitMSAW && !state.DisableMSAW
+	})
+	for _, alert := range []struct {
+		audio AudioType
+		play  bool
+	}{{AudioConflictAlert, playCASound}, {AudioMinimumSafeAltitudeWarning, playMSAWSound}} {
+		if alert.play {
+			globalConfig.Audio.StartPlayContinuous(alert.audio)
+		} else {
+			globalConfig.Audio.StopPlayContinuous(alert.audio)
 		}
 	}
-	if playAlertSound {
-		globalConfig.Audio.StartPlayContinuous(AudioConflictAlert)
-	} else {
-		globalConfig.Audio.StopPlayContinuous(AudioConflictAlert)
-	}
 
 	// Do this at the end of drawing so that we hold on to the tracks we
 	// have for rendering the current frame.
@@ -1633,7 +1972,19 @@ func (sp *STARSPane) Draw(ctx *PaneContext, cb *CommandBuffer) {
 func (sp *STARSPane) updateRadarTracks(w *World) {
 	// FIXME: all aircraft radar tracks are updated at the same time.
 	now := w.CurrentTime()
-	if sp.radarMode(w) == RadarModeFused {
+	if now.Before(sp.lastTrackUpdate) {
+		// The sim has gone back in time, presumably because a situation
+		// was restored; the history tracks no longer apply.
+		for _, state := range sp.Aircraft {
+			state.historyTracksIndex = 0
+		}
+		sp.lastTrackUpdate = time.Time{}
+		sp.lastHistoryTrackUpdate = time.Time{}
+	}
+	// Fused tracks are updated every second, but if any of the radar
+	// sites have failed, the remaining sensors can only provide updates at
+	// the rate of an individual radar.
+	if sp.radarMode(w) == RadarModeFused && len(w.FailedRadarSites) == 0 {
 		if now.Sub(sp.lastTrackUpdate) < 1*time.Second {
 			return
 		}
@@ -1651,14 +2002,28 @@ func (sp *STARSPane) updateRadarTracks(w *World) {
 			continue
 		}
 
+		if len(w.FailedRadarSites) > 0 && !sp.seenByWorkingRadar(w, ac) {
+			// Without a radar return, the track coasts.
+			continue
+		}
+
 		state.track = RadarTrack{
 			Position:    ac.Position(),
-			Altitude:    int(ac.Altitude()),
+			Altitude:    w.ModeCAltitude(ac),
 			Groundspeed: int(ac.Nav.FlightState.GS),
 			Time:        now,
 		}
 	}
 
+	if sp.trackIndex == nil || sp.trackIndex.nmPerLongitude != w.NmPerLongitude {
+		sp.trackIndex = MakeSpatialIndex[string](LateralMinimum, w.NmPerLongitude)
+	} else {
+		sp.trackIndex.Clear()
+	}
+	for callsign, state := range sp.Aircraft {
+		sp.trackIndex.Insert(state.TrackPosition(), callsign)
+	}
+
 	// History tracks are updated after a radar track update, only if
 	// H_RATE seconds have elapsed (4-94).
 	ps := &sp.CurrentPreferenceSet
@@ -1687,7 +2052,7 @@ func (sp *STARSPane) processKeyboardInput(ctx *PaneContext) {
 
 	if ctx.keyboard.IsPressed(KeyTab) {
 		// focus back to the MessagesPane
-		globalConfig.DisplayRoot.VisitPanes(func(pane Pane) {
+		globalConfig.VisitAllPanes(func(pane Pane) {
 			if mp, ok := pane.(*MessagesPane); ok {
 				wmTakeKeyboardFocus(mp, false)
 				delete(ctx.keyboard.Pressed, KeyTab) // prevent cycling back and forth
@@ -1888,6 +2253,8 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *PaneContext) (status S
 		return
 	}
 
+	cmd = globalConfig.KeyBindings.ExpandAliases(cmd)
+
 	lookupAircraft := func(callsign string, abbreviated bool) *Aircraft {
 		if ac := ctx.world.GetAircraft(callsign, abbreviated); ac != nil {
 			return ac
@@ -2091,7 +2458,9 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *PaneContext) (status S
 
 		f := strings.Fields(cmd)
 		if len(f) > 1 {
-			if f[0] == ".AUTOTRACK" && len(f) == 2 {
+			if f[0] == ".PREF" {
+				return sp.executePrefCommand(f[1:], ctx)
+			} else if f[0] == ".AUTOTRACK" && len(f) == 2 {
 				if f[1] == "NONE" {
 					sp.AutoTrackDepartures = false
 					status.clear = true
@@ -2784,6 +3153,18 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *PaneContext) (status S
 				ps.DisplayRequestedAltitude = false
 				status.clear = true
 				return
+			case "S": // toggle Mode S data
+				ps.DisplayModeS = !ps.DisplayModeS
+				status.clear = true
+				return
+			case "SE": // enable
+				ps.DisplayModeS = true
+				status.clear = true
+				return
+			case "SI": // inhibit
+				ps.DisplayModeS = false
+				status.clear = true
+				return
 			}
 
 		case "S":
@@ -2880,6 +3261,12 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *PaneContext) (status S
 				case 'C':
 					updateList(cmd[1:], &ps.CoastList.Visible, &ps.CoastList.Lines)
 					return
+				case 'I':
+					updateList(cmd[1:], &ps.InboundList.Visible, &ps.InboundList.Lines)
+					return
+				case 'H':
+					updateList(cmd[1:], &ps.HoldList.Visible, &ps.HoldList.Lines)
+					return
 				case 'S':
 					updateList(cmd[1:], &ps.SignOnList.Visible, nil)
 					return
@@ -2967,11 +3354,11 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *PaneContext) (status S
 		f := strings.Fields(cmd)
 		if len(f) == 1 {
 			callsign := lookupCallsign(f[0], false)
-			status.err = ctx.world.SetSquawkAutomatic(callsign)
+			ctx.world.SetSquawkAutomatic(callsign, nil, func(err error) { sp.displayError(err) })
 		} else if len(f) == 2 {
 			if squawk, err := ParseSquawk(f[1]); err == nil {
 				callsign := lookupCallsign(f[0], false)
-				status.err = ctx.world.SetSquawk(callsign, squawk)
+				ctx.world.SetSquawk(callsign, squawk, nil, func(err error) { sp.displayError(err) })
 			} else {
 				status.err = ErrSTARSIllegalCode
 			}
@@ -3013,12 +3400,9 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *PaneContext) (status S
 		return
 
 	case CommandModeSavePrefAs:
-		psave := sp.CurrentPreferenceSet.Duplicate()
-		psave.Name = cmd
-		sp.PreferenceSets = append(sp.PreferenceSets, psave)
-		sp.SelectedPreferenceSet = len(sp.PreferenceSets) - 1
-		status.clear = true
-		globalConfig.Save()
+		if status.err = sp.savePreferenceSet(len(sp.PreferenceSets), cmd); status.err == nil {
+			status.clear = true
+		}
 		return
 
 	case CommandModeMaps:
@@ -3076,26 +3460,55 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *PaneContext) (status S
 			ps.RadarSiteSelected = ""
 			status.clear = true
 			return
+		} else if cmd == "MULTI" || cmd == "FUSED" {
+			ps.RadarSiteSelected = ""
+			if fused := cmd == "FUSED"; fused != ps.FusedRadarMode {
+				ps.FusedRadarMode = fused
+				sp.discardTracks = true
+			}
+			status.clear = true
+			return
+		} else if cmd == "*" {
+			// Select the working radar site closest to the center of the
+			// scope.
+			id, closest := "", float32(0)
+			for sid, rs := range ctx.world.RadarSites {
+				d := nmdistance2ll(rs.Position, ps.CurrentCenter)
+				if !ctx.world.RadarSiteFailed(sid) && (id == "" || d < closest) {
+					id, closest = sid, d
+				}
+			}
+			if id == "" {
+				status.err = ErrSTARSIllegalParam
+			} else {
+				ps.RadarSiteSelected = id
+				status.clear = true
+			}
+			return
 		} else if len(cmd) > 0 {
 			// Index, character id, or name
+			id := ""
 			if i, err := strconv.Atoi(cmd); err == nil {
 				if i < 0 || i >= len(ctx.world.RadarSites) {
 					status.err = ErrSTARSIllegalValue
-				} else {
-					ps.RadarSiteSelected = SortedMapKeys(ctx.world.RadarSites)[i]
-					status.clear = true
+					return
 				}
-				return
-			}
-			for id, rs := range ctx.world.RadarSites {
-				if cmd == rs.Char || cmd == id {
-					ps.RadarSiteSelected = id
-					status.clear = true
+				id = SortedMapKeys(ctx.world.RadarSites)[i]
+			} else {
+				for sid, rs := range ctx.world.RadarSites {
+					if cmd == rs.Char || cmd == sid {
+						id = sid
+						break
+					}
 				}
-				return
 			}
-			status.clear = true
-			status.err = ErrSTARSIllegalParam
+
+			if id == "" || ctx.world.RadarSiteFailed(id) {
+				status.err = ErrSTARSIllegalParam
+			} else {
+				ps.RadarSiteSelected = id
+				status.clear = true
+			}
 			return
 		}
 	}
@@ -3150,32 +3563,9 @@ func (sp *STARSPane) setScratchpad(ctx *PaneContext, callsign string, contents s
 		for unassociated tracks. So might as well weed them out now. */
 	}
 
-	fac := ctx.world.STARSFacilityAdaptation
-	if isSecondary {
-		// 5-148: secondary is 1 to 3-maybe-4 characters
-		if (fac.AllowLongScratchpad[1] && lc > 4) || (!fac.AllowLongScratchpad[1] && lc > 3) {
-			return ErrSTARSCommandFormat
-		}
-	} else {
-		// 5-148: primary is 2 to 3-maybe-4 characters
-		if lc == 1 || (fac.AllowLongScratchpad[0] && lc > 4) || (!fac.AllowLongScratchpad[0] && lc > 3) {
-			return ErrSTARSCommandFormat
-		}
-	}
-
-	// Make sure it's only allowed characters
-	allowedCharacters := "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789./*" + STARSTriangleCharacter
-	for _, letter := range contents {
-		if !strings.ContainsRune(allowedCharacters, letter) {
-			return ErrSTARSCommandFormat
-		}
-	}
-
-	// It can't be three numerals
-	if lc == 3 && contents[0] >= '0' && contents[0] <= '9' &&
-		contents[1] >= '0' && contents[1] <= '9' &&
-		contents[2] >= '0' && contents[2] <= '9' {
-		return ErrSTARSCommandFormat
+	// Length, characters, and the facility's rules; see scratchpads.go.
+	if err := ctx.world.STARSFacilityAdaptation.CheckScratchpad(contents, isSecondary); err != nil {
+		return err
 	}
 
 	if !isSecondary && isImplied {
@@ -3190,12 +3580,6 @@ func (sp *STARSPane) setScratchpad(ctx *PaneContext, callsign string, contents s
 		}
 	}
 
-	// Certain specific strings aren't allowed in the first 3 characters
-	illegalScratchpads := []string{"NAT", "CST", "AMB", "RDR", "ADB", "XXX"}
-	if lc >= 3 && slices.Contains(illegalScratchpads, contents[:3]) {
-		return ErrSTARSIllegalScratchpad
-	}
-
 	if isSecondary {
 		ctx.world.SetSecondaryScratchpad(callsign, contents, nil,
 			func(err error) { sp.displayError(err) })
@@ -3433,6 +3817,8 @@ func (sp *STARSPane) cancelHandoff(ctx *PaneContext, callsign string) {
 
 func (sp *STARSPane) executeSTARSClickedCommand(ctx *PaneContext, cmd string, mousePosition [2]float32,
 	ghosts []*GhostAircraft, transforms ScopeTransformations) (status STARSCommandStatus) {
+	cmd = globalConfig.KeyBindings.ExpandAliases(cmd)
+
 	// See if an aircraft was clicked
 	ac, acDistance := sp.tryGetClosestAircraft(ctx.world, mousePosition, transforms)
 	ghost, ghostDistance := sp.tryGetClosestGhost(ghosts, mousePosition, transforms)
@@ -3721,7 +4107,7 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *PaneContext, cmd string, mo
 				if alt, err := strconv.Atoi(cmd[2:]); err == nil {
 					status.err = amendFlightPlan(ctx.world, ac.Callsign, func(fp *FlightPlan) {
 						fp.Altitude = alt * 100
-					})
+					}, func(err error) { sp.displayError(err) })
 					status.clear = true
 				} else {
 					status.err = ErrSTARSCommandFormat
@@ -3843,7 +4229,13 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *PaneContext, cmd string, mo
 			return
 
 		case CommandModeHandOff:
-			if cmd == "" {
+			if cmd == "" && ac.HandoffTrackController == "" && ac.ExitHandoffController != "" &&
+				ac.TrackingController == ctx.world.Callsign {
+				// Hand off to the next sector from the departure gate.
+				status.clear = true
+				ctx.world.HandoffTrack(ac.Callsign, ac.ExitHandoffController, nil,
+					func(err error) { sp.displayError(err) })
+			} else if cmd == "" {
 				status.clear = true
 				sp.cancelHandoff(ctx, ac.Callsign)
 			} else {
@@ -4009,6 +4401,20 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *PaneContext, cmd string, mo
 						status.clear = true
 					}
 					return
+				case "S", "SE", "SI": // toggle, enable, or inhibit Mode S data
+					if sp.datablockType(ctx, ac) != FullDatablock {
+						status.err = ErrSTARSIllegalFunction
+					} else if _, ok := ac.ModeSData(); !ok {
+						status.err = ErrSTARSIllegalTrack
+					} else {
+						b := cmd == "SE"
+						if cmd == "S" {
+							b = !sp.displayModeS(state)
+						}
+						state.DisplayModeS = &b
+						status.clear = true
+					}
+					return
 				}
 
 			case "V":
@@ -4068,11 +4474,11 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *PaneContext, cmd string, mo
 		case CommandModeFlightData:
 			if cmd == "" {
 				status.clear = true
-				status.err = ctx.world.SetSquawkAutomatic(ac.Callsign)
+				ctx.world.SetSquawkAutomatic(ac.Callsign, nil, func(err error) { sp.displayError(err) })
 				return
 			} else {
 				if squawk, err := ParseSquawk(cmd); err == nil {
-					status.err = ctx.world.SetSquawk(ac.Callsign, squawk)
+					ctx.world.SetSquawk(ac.Callsign, squawk, nil, func(err error) { sp.displayError(err) })
 				} else {
 					status.err = ErrSTARSIllegalParam
 				}
@@ -4161,6 +4567,16 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *PaneContext, cmd string, mo
 			ps.CoastList.Visible = true
 			status.clear = true
 			return
+		} else if cmd == "TI" {
+			ps.InboundList.Position = transforms.NormalizedFromWindowP(mousePosition)
+			ps.InboundList.Visible = true
+			status.clear = true
+			return
+		} else if cmd == "TH" {
+			ps.HoldList.Position = transforms.NormalizedFromWindowP(mousePosition)
+			ps.HoldList.Visible = true
+			status.clear = true
+			return
 		} else if cmd == "TS" {
 			ps.SignOnList.Position = transforms.NormalizedFromWindowP(mousePosition)
 			ps.SignOnList.Visible = true
@@ -4359,7 +4775,10 @@ func (sp *STARSPane) DrawDCB(ctx *PaneContext, transforms ScopeTransformations,
 		STARSDisabledButton("CURSOR\nHOME", STARSButtonFull, buttonScale)
 		STARSDisabledButton("CSR SPD\n4", STARSButtonFull, buttonScale)
 		STARSDisabledButton("MAP\nUNCOR", STARSButtonFull, buttonScale)
-		STARSDisabledButton("UNCOR", STARSButtonFull, buttonScale)
+		uncor := !ps.HideUncorrelatedTargets
+		if STARSToggleButton("UNCOR", &uncor, STARSButtonFull, buttonScale) {
+			ps.HideUncorrelatedTargets = !uncor
+		}
 		STARSDisabledButton("BEACON\nMODE-2", STARSButtonFull, buttonScale)
 		STARSDisabledButton("RTQC", STARSButtonFull, buttonScale)
 		STARSDisabledButton("MCP", STARSButtonFull, buttonScale)
@@ -4512,9 +4931,7 @@ func (sp *STARSPane) DrawDCB(ctx *PaneContext, transforms ScopeTransformations,
 			}
 			if STARSSelectButton(text, flags, buttonScale) {
 				// Make this one current
-				sp.SelectedPreferenceSet = i
-				sp.CurrentPreferenceSet = sp.PreferenceSets[i]
-				sp.weatherRadar.Activate(sp.CurrentPreferenceSet.Center, ctx.renderer)
+				sp.recallPreferenceSet(i, ctx)
 			}
 		}
 		for i := len(sp.PreferenceSets); i < NumSTARSPreferenceSets; i++ {
@@ -4522,18 +4939,21 @@ func (sp *STARSPane) DrawDCB(ctx *PaneContext, transforms ScopeTransformations,
 		}
 
 		if STARSSelectButton("DEFAULT", STARSButtonHalfVertical, buttonScale) {
-			sp.CurrentPreferenceSet = sp.MakePreferenceSet("", ctx.world)
+			sp.executePrefCommand([]string{"DEFAULT"}, ctx)
 		}
 		STARSDisabledButton("FSSTARS", STARSButtonHalfVertical, buttonScale)
-		if STARSSelectButton("RESTORE", STARSButtonHalfVertical, buttonScale) {
-			// TODO: restore settings in effect when entered the Pref sub-menu
+		if sp.restorePreferenceSet != nil {
+			if STARSSelectButton("RESTORE", STARSButtonHalfVertical, buttonScale) {
+				sp.executePrefCommand([]string{"RESTORE"}, ctx)
+			}
+		} else {
+			STARSDisabledButton("RESTORE", STARSButtonHalfVertical, buttonScale)
 		}
 
 		validSelection := sp.SelectedPreferenceSet != -1 && sp.SelectedPreferenceSet < len(sp.PreferenceSets)
 		if validSelection {
 			if STARSSelectButton("SAVE", STARSButtonHalfVertical, buttonScale) {
-				sp.PreferenceSets[sp.SelectedPreferenceSet] = sp.CurrentPreferenceSet
-				globalConfig.Save()
+				sp.savePreferenceSet(sp.SelectedPreferenceSet, "")
 			}
 		} else {
 			STARSDisabledButton("SAVE", STARSButtonHalfVertical, buttonScale)
@@ -4546,7 +4966,7 @@ func (sp *STARSPane) DrawDCB(ctx *PaneContext, transforms ScopeTransformations,
 		}
 		if validSelection {
 			if STARSSelectButton("DELETE", STARSButtonHalfVertical, buttonScale) {
-				sp.PreferenceSets = DeleteSliceElement(sp.PreferenceSets, sp.SelectedPreferenceSet)
+				sp.executePrefCommand([]string{"DELETE", strconv.Itoa(sp.SelectedPreferenceSet + 1)}, ctx)
 			}
 		} else {
 			STARSDisabledButton("DELETE", STARSButtonHalfVertical, buttonScale)
@@ -4560,6 +4980,10 @@ func (sp *STARSPane) DrawDCB(ctx *PaneContext, transforms ScopeTransformations,
 		for _, id := range SortedMapKeys(ctx.world.RadarSites) {
 			site := ctx.world.RadarSites[id]
 			label := " " + site.Char + " " + "\n" + id
+			if ctx.world.RadarSiteFailed(id) {
+				STARSDisabledButton(label, STARSButtonFull, buttonScale)
+				continue
+			}
 			selected := ps.RadarSiteSelected == id
 			if STARSToggleButton(label, &selected, STARSButtonFull, buttonScale) {
 				if selected {
@@ -4825,6 +5249,17 @@ func (sp *STARSPane) drawSystemLists(aircraft []*Aircraft, ctx *PaneContext, pan
 			newline()
 		}
 
+		if (filter.All || filter.Radar) && len(ctx.world.FailedRadarSites) > 0 {
+			var failed []string
+			for _, id := range SortedMapKeys(ctx.world.FailedRadarSites) {
+				if site, ok := ctx.world.RadarSites[id]; ok {
+					failed = append(failed, site.Char)
+				}
+			}
+			pw = td.AddText("SITE FAIL "+strings.Join(failed, " "), pw, alertStyle)
+			newline()
+		}
+
 		if filter.All || filter.Codes {
 			if len(ps.SelectedBeaconCodes) > 0 {
 				pw = td.AddText(strings.Join(ps.SelectedBeaconCodes, " "), pw, style)
@@ -4956,6 +5391,24 @@ func (sp *STARSPane) drawSystemLists(aircraft []*Aircraft, ctx *PaneContext, pan
 		}
 	}
 
+	// drawAircraftList draws a list of aircraft sorted by their list
+	// index, showing at most the given number of lines.
+	drawAircraftList := func(title string, acs map[int]*Aircraft, lines int, format func(*Aircraft) string,
+		p [2]float32) {
+		text := title + "\n"
+		if len(acs) > lines {
+			text += fmt.Sprintf("MORE: %d/%d\n", lines, len(acs))
+		}
+		for i, acIdx := range SortedMapKeys(acs) {
+			// Limit to the user limit
+			if i == lines {
+				break
+			}
+			text += fmt.Sprintf("%2d %-7s %s\n", acIdx, acs[acIdx].Callsign, format(acs[acIdx]))
+		}
+		drawList(text, p)
+	}
+
 	if ps.VFRList.Visible {
 		vfr := make(map[int]*Aircraft)
 		// Find all untracked VFR aircraft
@@ -4965,50 +5418,70 @@ func (sp *STARSPane) drawSystemLists(aircraft []*Aircraft, ctx *PaneContext, pan
 			}
 		}
 
-		text := "VFR LIST\n"
-		if len(vfr) > ps.VFRList.Lines {
-			text += fmt.Sprintf("MORE: %d/%d\n", ps.VFRList.Lines, len(vfr))
-		}
-		for i, acIdx := range SortedMapKeys(vfr) {
-			ac := vfr[acIdx]
-			text += fmt.Sprintf("%2d %-7s VFR\n", acIdx, ac.Callsign)
-
-			// Limit to the user limit
-			if i == ps.VFRList.Lines {
-				break
-			}
-		}
+		drawAircraftList("VFR LIST", vfr, ps.VFRList.Lines, func(*Aircraft) string { return "VFR" },
+			ps.VFRList.Position)
+	}
 
-		drawList(text, ps.VFRList.Position)
+	// Untracked departures from one of our airports go in the TAB list
+	// once they are airborne; until then, they are in the hold list,
+	// awaiting release.
+	isDeparture := func(ac *Aircraft) bool {
+		fp := ac.FlightPlan
+		return fp != nil && ac.TrackingController == "" && ctx.world.DepartureAirports[fp.DepartureAirport] != nil
 	}
 
 	if ps.TABList.Visible {
 		dep := make(map[int]*Aircraft)
-		// Untracked departures departing from one of our airports
 		for _, ac := range aircraft {
-			if fp := ac.FlightPlan; fp != nil && ac.TrackingController == "" {
-				if ap := ctx.world.DepartureAirports[fp.DepartureAirport]; ap != nil {
-					dep[sp.getAircraftIndex(ac)] = ac
-					break
-				}
+			if isDeparture(ac) && ac.IsAirborne() {
+				dep[sp.getAircraftIndex(ac)] = ac
 			}
 		}
 
-		text := "FLIGHT PLAN\n"
-		if len(dep) > ps.TABList.Lines {
-			text += fmt.Sprintf("MORE: %d/%d\n", ps.TABList.Lines, len(dep))
+		drawAircraftList("FLIGHT PLAN", dep, ps.TABList.Lines, func(ac *Aircraft) string { return ac.Squawk.String() },
+			ps.TABList.Position)
+	}
+
+	if ps.HoldList.Visible {
+		hold := make(map[int]*Aircraft)
+		for _, ac := range aircraft {
+			if isDeparture(ac) && !ac.IsAirborne() {
+				hold[sp.getAircraftIndex(ac)] = ac
+			}
 		}
-		for i, acIdx := range SortedMapKeys(dep) {
-			ac := dep[acIdx]
-			text += fmt.Sprintf("%2d %-7s %s\n", acIdx, ac.Callsign, ac.Squawk.String())
 
-			// Limit to the user limit
-			if i == ps.TABList.Lines {
-				break
+		drawAircraftList("HOLD", hold, ps.HoldList.Lines,
+			func(ac *Aircraft) string {
+				s := ac.FlightPlan.DepartureAirport + " " + ac.Squawk.String()
+				if r := ac.Release; r == nil {
+					return s
+				} else if !r.Released {
+					// Release requested
+					return s + " RLS"
+				} else if now := ctx.world.CurrentTime(); now.Before(r.Time) {
+					return s + " " + r.Time.UTC().Format("1504")
+				}
+				return s
+			}, ps.HoldList.Position)
+	}
+
+	if ps.InboundList.Visible {
+		// Aircraft that are being handed off to us.
+		inbound := make(map[int]*Aircraft)
+		for _, ac := range aircraft {
+			if ac.HandoffTrackController == ctx.world.Callsign {
+				inbound[sp.getAircraftIndex(ac)] = ac
 			}
 		}
 
-		drawList(text, ps.TABList.Position)
+		drawAircraftList("INBOUND", inbound, ps.InboundList.Lines,
+			func(ac *Aircraft) string {
+				from := ac.TrackingController
+				if ctrl := ctx.world.GetControllerByCallsign(from); ctrl != nil {
+					from = ctrl.SectorId
+				}
+				return ac.Squawk.String() + " " + from
+			}, ps.InboundList.Position)
 	}
 
 	if ps.AlertList.Visible {
@@ -5063,9 +5536,16 @@ func (sp *STARSPane) drawSystemLists(aircraft []*Aircraft, ctx *PaneContext, pan
 	}
 
 	if ps.CoastList.Visible {
-		text := "COAST/SUSPEND"
-		// TODO
-		drawList(text, ps.CoastList.Position)
+		// Our tracks that we haven't heard from recently.
+		coast := make(map[int]*Aircraft)
+		for _, ac := range aircraft {
+			if ac.TrackingController == ctx.world.Callsign && sp.Aircraft[ac.Callsign].LostTrack(ctx.world.CurrentTime()) {
+				coast[sp.getAircraftIndex(ac)] = ac
+			}
+		}
+
+		drawAircraftList("COAST/SUSPEND", coast, ps.CoastList.Lines,
+			func(ac *Aircraft) string { return "CST " + ac.Squawk.String() }, ps.CoastList.Position)
 	}
 
 	if ps.VideoMapsList.Visible {
@@ -5198,7 +5678,7 @@ func (sp *STARSPane) drawSystemLists(aircraft []*Aircraft, ctx *PaneContext, pan
 				id = STARSTriangleCharacter + ctrl.FacilityIdentifier + id
 			}
 			return fmt.Sprintf("%4s", id) + " " + ctrl.Frequency.String() + " " +
-				ctrl.Callsign + Select(ctrl.IsHuman, "*", "") + "\n"
+				ctrl.Callsign + Select(ctrl.IsHuman, "*", "") + Select(ctrl.Initials != "", " "+ctrl.Initials, "") + "\n"
 		}
 
 		// User first
@@ -5350,12 +5830,31 @@ func (sp *STARSPane) drawTracks(aircraft []*Aircraft, ctx *PaneContext, transfor
 	cb *CommandBuffer) {
 	td := GetTextDrawBuilder()
 	defer ReturnTextDrawBuilder(td)
-	pd := PointsDrawBuilder{}
-	pd2 := PointsDrawBuilder{}
+	pd := GetPointsDrawBuilder()
+	defer ReturnPointsDrawBuilder(pd)
+	pd2 := GetPointsDrawBuilder()
+	defer ReturnPointsDrawBuilder(pd2)
 	ld := GetColoredLinesDrawBuilder()
 	defer ReturnColoredLinesDrawBuilder(ld)
 	trid := GetColoredTrianglesDrawBuilder()
 	defer ReturnColoredTrianglesDrawBuilder(trid)
+
+	// Tracks without a position symbol get a small asterisk, which is the
+	// same for all of them and so is drawn with instancing, in window
+	// coordinates.
+	sym := GetInstancedLinesDrawBuilder()
+	defer ReturnInstancedLinesDrawBuilder(sym)
+	// On high DPI windows displays we need to scale up the tracks
+	px := 3 * Select(runtime.GOOS == "windows", ctx.platform.DPIScale(), float32(1))
+	diagPx := px * 0.707107 /* 1/sqrt(2) */
+	// diagonals
+	sym.AddLine([2]float32{-diagPx, -diagPx}, [2]float32{diagPx, diagPx})
+	sym.AddLine([2]float32{diagPx, -diagPx}, [2]float32{-diagPx, diagPx})
+	// horizontal line
+	sym.AddLine([2]float32{-px, 0}, [2]float32{px, 0})
+	// vertical line
+	sym.AddLine([2]float32{0, -px}, [2]float32{0, px})
+
 	// TODO: square icon if it's squawking a beacon code we're monitoring
 
 	now := ctx.world.CurrentTime()
@@ -5386,7 +5885,7 @@ func (sp *STARSPane) drawTracks(aircraft []*Aircraft, ctx *PaneContext, transfor
 		heading := Select(state.HaveHeading(),
 			state.TrackHeading(ac.NmPerLongitude())+ac.MagneticVariation(), ac.Heading())
 
-		sp.drawRadarTrack(ac, state, heading, ctx, transforms, trackId, &pd, &pd2, ld, trid, td)
+		sp.drawRadarTrack(ac, state, heading, ctx, transforms, trackId, pd, pd2, ld, trid, sym, td)
 	}
 
 	transforms.LoadLatLongViewingMatrices(cb)
@@ -5398,6 +5897,7 @@ func (sp *STARSPane) drawTracks(aircraft []*Aircraft, ctx *PaneContext, transfor
 	cb.LineWidth(1)
 	ld.GenerateCommands(cb)
 	transforms.LoadWindowViewingMatrices(cb)
+	sym.GenerateCommands(cb)
 	td.GenerateCommands(cb)
 }
 
@@ -5513,7 +6013,7 @@ func (sp *STARSPane) drawGhosts(ghosts []*GhostAircraft, ctx *PaneContext, trans
 func (sp *STARSPane) drawRadarTrack(ac *Aircraft, state *STARSAircraftState, heading float32, ctx *PaneContext,
 	transforms ScopeTransformations, trackId string,
 	pd *PointsDrawBuilder, pd2 *PointsDrawBuilder, ld *ColoredLinesDrawBuilder,
-	trid *ColoredTrianglesDrawBuilder, td *TextDrawBuilder) {
+	trid *ColoredTrianglesDrawBuilder, sym *InstancedLinesDrawBuilder, td *TextDrawBuilder) {
 	ps := sp.CurrentPreferenceSet
 	// TODO: orient based on radar center if just one radar
 
@@ -5522,6 +6022,9 @@ func (sp *STARSPane) drawRadarTrack(ac *Aircraft, state *STARSAircraftState, hea
 	// On high DPI windows displays we need to scale up the tracks
 	scale := Select(runtime.GOOS == "windows", ctx.platform.DPIScale(), float32(1))
 
+	// Without a transponder, there's only a primary return.
+	primaryOnly := ac.Mode == Standby
+
 	primaryTargetBrightness := ps.Brightness.PrimarySymbols
 	if primaryTargetBrightness > 0 {
 		switch mode := sp.radarMode(ctx.world); mode {
@@ -5548,10 +6051,10 @@ func (sp *STARSPane) drawRadarTrack(ac *Aircraft, state *STARSAircraftState, hea
 			if primary {
 				// Draw a filled box
 				trid.AddQuad(box[0], box[1], box[2], box[3], color)
-			} else if secondary {
+			} else if secondary && !primaryOnly {
 				// If it's just a secondary return, only draw the box outline.
 				// TODO: is this 40nm, or secondary?
-				ld.AddPolyline([2]float32{}, color, box[:])
+				ld.AddPolyline([2]float32{}, ps.Brightness.BeaconSymbols.ScaleRGB(STARSTrackBlockColor), box[:])
 			}
 
 			// green line
@@ -5578,10 +6081,10 @@ func (sp *STARSPane) drawRadarTrack(ac *Aircraft, state *STARSAircraftState, hea
 			if primary {
 				// Draw a filled box
 				trid.AddQuad(box[0], box[1], box[2], box[3], color)
-			} else if secondary {
+			} else if secondary && !primaryOnly {
 				// If it's just a secondary return, only draw the box outline.
 				// TODO: is this 40nm, or secondary?
-				ld.AddPolyline([2]float32{}, color, box[:])
+				ld.AddPolyline([2]float32{}, ps.Brightness.BeaconSymbols.ScaleRGB(STARSTrackBlockColor), box[:])
 			}
 
 		case RadarModeFused:
@@ -5592,9 +6095,9 @@ func (sp *STARSPane) drawRadarTrack(ac *Aircraft, state *STARSAircraftState, hea
 		}
 	}
 
-	// Draw main track symbol letter
+	// Draw main track symbol letter; primary-only targets don't have one.
 	trackIdBrightness := ps.Brightness.Positions
-	if trackIdBrightness > 0 {
+	if trackIdBrightness > 0 && !primaryOnly {
 		dt := sp.datablockType(ctx, ac)
 		color, _ := sp.datablockColor(ctx, ac)
 		if dt == PartialDatablock || dt == LimitedDatablock {
@@ -5605,25 +6108,8 @@ func (sp *STARSPane) drawRadarTrack(ac *Aircraft, state *STARSAircraftState, hea
 			td.AddTextCentered(trackId, pw, TextStyle{Font: font, Color: trackIdBrightness.ScaleRGB(color), DropShadow: true})
 		} else {
 			// TODO: draw box if in range of squawks we have selected
-
-			// diagonals
-			dx := transforms.LatLongFromWindowV([2]float32{1, 0})
-			dy := transforms.LatLongFromWindowV([2]float32{0, 1})
-			// Returns lat-long point w.r.t. p with a window coordinates vector (x,y) added.
-			delta := func(p Point2LL, x, y float32) Point2LL {
-				return add2ll(p, add2ll(scale2f(dx, x), scale2f(dy, y)))
-			}
-
-			px := float32(3) * scale
-			// diagonals
-			diagPx := px * 0.707107                                            /* 1/sqrt(2) */
 			trackColor := trackIdBrightness.ScaleRGB(RGB{R: .1, G: .7, B: .1}) // TODO make a STARS... constant
-			ld.AddLine(delta(pos, -diagPx, -diagPx), delta(pos, diagPx, diagPx), trackColor)
-			ld.AddLine(delta(pos, diagPx, -diagPx), delta(pos, -diagPx, diagPx), trackColor)
-			// horizontal line
-			ld.AddLine(delta(pos, -px, 0), delta(pos, px, 0), trackColor)
-			// vertical line
-			ld.AddLine(delta(pos, 0, -px), delta(pos, 0, px), trackColor)
+			sym.AddInstance(pw, trackColor)
 		}
 	}
 
@@ -5727,6 +6213,16 @@ func (sp *STARSPane) WarnOutsideAirspace(ctx *PaneContext, ac *Aircraft) (alts [
 	return
 }
 
+const (
+	// How far ahead predicted conflicts are alerted and how much of the
+	// uncertainty in the predictions is accounted for.
+	caLookahead            = 25 * time.Second
+	caPredictionConfidence = 0.5
+	// Aircraft further apart than this can't lose separation within the
+	// lookahead time unless they're closing at over 600 knots.
+	caQueryRadius = LateralMinimum + 5
+)
+
 func (sp *STARSPane) updateCAAircraft(w *World, aircraft []*Aircraft) {
 	inCAVolumes := func(state *STARSAircraftState) bool {
 		for _, vol := range w.InhibitCAVolumes {
@@ -5737,6 +6233,7 @@ func (sp *STARSPane) updateCAAircraft(w *World, aircraft []*Aircraft) {
 		return false
 	}
 
+	now := w.CurrentTime()
 	conflicting := func(callsigna, callsignb string) bool {
 		sa, sb := sp.Aircraft[callsigna], sp.Aircraft[callsignb]
 		if sa.DisableCAWarnings || sb.DisableCAWarnings {
@@ -5745,10 +6242,23 @@ func (sp *STARSPane) updateCAAircraft(w *World, aircraft []*Aircraft) {
 		if inCAVolumes(sa) || inCAVolumes(sb) {
 			return false
 		}
-		return nmdistance2ll(sa.TrackPosition(), sb.TrackPosition()) <= LateralMinimum &&
+		aca, acb := w.Aircraft[callsigna], w.Aircraft[callsignb]
+		if aca.Mode == Standby || acb.Mode == Standby {
+			// No altitude without Mode C.
+			return false
+		}
+		if nmdistance2ll(sa.TrackPosition(), sb.TrackPosition()) <= LateralMinimum &&
 			/*small slop for fp error*/
 			abs(sa.TrackAltitude()-sb.TrackAltitude()) <= VerticalMinimum-5 &&
-			!sp.diverging(w.Aircraft[callsigna], w.Aircraft[callsignb])
+			!sp.diverging(aca, acb) {
+			return true
+		}
+
+		// Also alert if the sim's predicted trajectories (see
+		// trajectory.go) have them losing separation shortly.
+		_, ok := aca.Trajectory.Conflict(acb.Trajectory, LateralMinimum, VerticalMinimum,
+			caPredictionConfidence, now, now.Add(caLookahead))
+		return ok
 	}
 
 	// Remove ones that are no longer conflicting
@@ -5757,16 +6267,35 @@ func (sp *STARSPane) updateCAAircraft(w *World, aircraft []*Aircraft) {
 	})
 
 	// Remove ones that are no longer visible
+	visibleIndex := make(map[string]int)
+	for i, ac := range aircraft {
+		visibleIndex[ac.Callsign] = i
+	}
 	sp.CAAircraft = FilterSlice(sp.CAAircraft, func(ca CAAircraft) bool {
-		return slices.ContainsFunc(aircraft, func(ac *Aircraft) bool { return ac.Callsign == ca.Callsigns[0] }) &&
-			slices.ContainsFunc(aircraft, func(ac *Aircraft) bool { return ac.Callsign == ca.Callsigns[1] })
+		_, ok0 := visibleIndex[ca.Callsigns[0]]
+		_, ok1 := visibleIndex[ca.Callsigns[1]]
+		return ok0 && ok1
 	})
 
 	// Add new conflicts; by appending we keep them sorted by when they
 	// were first detected...
-	callsigns := MapSlice(aircraft, func(ac *Aircraft) string { return ac.Callsign })
-	for i, callsign := range callsigns {
-		for _, ocs := range callsigns[i+1:] {
+	for i, ac := range aircraft {
+		callsign := ac.Callsign
+		// Only visible aircraft that come after this one are considered
+		// so that each pair is only checked once; sort them so that
+		// conflicts are added in the same order as before.
+		var nearby []int
+		sp.trackIndex.Query(sp.Aircraft[callsign].TrackPosition(), caQueryRadius,
+			func(_ Point2LL, ocs string) bool {
+				if j, ok := visibleIndex[ocs]; ok && j > i {
+					nearby = append(nearby, j)
+				}
+				return true
+			})
+		sort.Ints(nearby)
+
+		for _, j := range nearby {
+			ocs := aircraft[j].Callsign
 			if conflicting(callsign, ocs) {
 				if !slices.ContainsFunc(sp.CAAircraft, func(ca CAAircraft) bool {
 					return callsign == ca.Callsigns[0] && ocs == ca.Callsigns[1]
@@ -5848,65 +6377,12 @@ func (sp *STARSPane) updateInTrailDistance(aircraft []*Aircraft, w *World) {
 			leadingState, trailingState := sp.Aircraft[leading.Callsign], sp.Aircraft[trailing.Callsign]
 			trailingState.IntrailDistance =
 				nmdistance2ll(leadingState.TrackPosition(), trailingState.TrackPosition())
-			sp.checkInTrailCwtSeparation(trailing, leading)
+			sp.checkInTrailCwtSeparation(trailing, leading, w.CurrentTime())
 		}
 		handledVolumes[vol.Id] = nil
 	}
 }
 
-type ModeledAircraft struct {
-	callsign     string
-	p            [2]float32 // nm coords
-	v            [2]float32 // nm, normalized
-	gs           float32
-	alt          float32
-	dalt         float32    // per second
-	threshold    [2]float32 // nm
-	landingSpeed float32
-}
-
-func MakeModeledAircraft(ac *Aircraft, state *STARSAircraftState, threshold Point2LL) ModeledAircraft {
-	ma := ModeledAircraft{
-		callsign:  ac.Callsign,
-		p:         ll2nm(state.TrackPosition(), ac.NmPerLongitude()),
-		gs:        float32(state.TrackGroundspeed()),
-		alt:       float32(state.TrackAltitude()),
-		dalt:      float32(state.TrackDeltaAltitude()),
-		threshold: ll2nm(threshold, ac.NmPerLongitude()),
-	}
-	if perf, ok := database.AircraftPerformance[ac.FlightPlan.BaseType()]; ok {
-		ma.landingSpeed = perf.Speed.Landing
-	} else {
-		ma.landingSpeed = 120 // ....
-	}
-	ma.v = state.HeadingVector(ac.NmPerLongitude(), ac.MagneticVariation())
-	ma.v = ll2nm(ma.v, ac.NmPerLongitude())
-	ma.v = normalize2f(ma.v)
-	return ma
-}
-
-// estimated altitude s seconds in the future
-func (ma *ModeledAircraft) EstimatedAltitude(s float32) float32 {
-	// simple linear model
-	return ma.alt + s*ma.dalt
-}
-
-// Return estimated position 1s in the future
-func (ma *ModeledAircraft) NextPosition(p [2]float32) [2]float32 {
-	gs := ma.gs // current speed
-	td := distance2f(p, ma.threshold)
-	if td < 2 {
-		gs = min(gs, ma.landingSpeed)
-	} else if td < 5 {
-		t := (td - 2) / 3 // [0,1]
-		// lerp from current speed down to landing speed
-		gs = lerp(t, ma.landingSpeed, gs)
-	}
-
-	gs /= 3600 // nm / second
-	return add2f(p, scale2f(ma.v, gs))
-}
-
 func getCwtCategory(ac *Aircraft) string {
 	perf, ok := database.AircraftPerformance[ac.FlightPlan.BaseType()]
 	if !ok {
@@ -5947,7 +6423,7 @@ func getCwtCategory(ac *Aircraft) string {
 
 }
 
-func (sp *STARSPane) checkInTrailCwtSeparation(back, front *Aircraft) {
+func (sp *STARSPane) checkInTrailCwtSeparation(back, front *Aircraft, now time.Time) {
 	cwtClass := func(ac *Aircraft) int {
 		perf, ok := database.AircraftPerformance[ac.FlightPlan.BaseType()]
 		if !ok {
@@ -6030,17 +6506,20 @@ func (sp *STARSPane) checkInTrailCwtSeparation(back, front *Aircraft) {
 		return
 	}
 
-	// front, back aircraft
-	frontModel := MakeModeledAircraft(front, sp.Aircraft[front.Callsign], vol.Threshold)
-	backModel := MakeModeledAircraft(back, state, vol.Threshold)
-
-	// Will there be a MIT violation s seconds in the future?  (Note that
-	// we don't include altitude separation here since what we need is
-	// distance separation by the threshold...)
-	frontPosition, backPosition := frontModel.p, backModel.p
-	for s := 0; s < 45; s++ {
-		frontPosition, backPosition = frontModel.NextPosition(frontPosition), backModel.NextPosition(backPosition)
-		distance := distance2f(frontPosition, backPosition)
+	// Will there be a MIT violation s seconds in the future? The sim's
+	// predicted trajectories (see trajectory.go) account for the aircraft
+	// slowing as they approach the threshold. (Note that we don't include
+	// altitude separation here since what we need is distance separation
+	// by the threshold...)
+	for s := 1; s <= 45; s++ {
+		t := now.Add(time.Duration(s) * time.Second)
+		frontPoint, okf := front.Trajectory.At(t)
+		backPoint, okb := back.Trajectory.At(t)
+		if !okf || !okb {
+			// Out of predictions, or the leader has landed.
+			return
+		}
+		distance := nmdistance2ll(frontPoint.Position, backPoint.Position)
 		if distance < cwtSeparation { // no bueno
 			if s <= 24 {
 				// Error if conflict expected within 24 seconds (6-159).
@@ -6195,10 +6674,6 @@ func (sp *STARSPane) formatDatablocks(ctx *PaneContext, ac *Aircraft) []STARSDat
 
 		// Field 1: alternate between altitude and either primary
 		// scratchpad or destination airport.
-		ap := ac.FlightPlan.ArrivalAirport
-		if len(ap) == 4 {
-			ap = ap[1:] // drop the leading K
-		}
 		alt := fmt.Sprintf("%03d", (state.TrackAltitude()+50)/100)
 		sp := fmt.Sprintf("%3s", ac.Scratchpad)
 
@@ -6206,7 +6681,7 @@ func (sp *STARSPane) formatDatablocks(ctx *PaneContext, ac *Aircraft) []STARSDat
 		field1[0] = alt
 		if ac.Scratchpad != "" {
 			field1[1] = sp
-		} else if airport := ctx.world.GetAirport(ac.FlightPlan.ArrivalAirport); airport != nil && !airport.OmitArrivalScratchpad {
+		} else if ap := datablockArrivalAirport(ctx, ac); ap != "" {
 			field1[1] = ap
 		} else {
 			field1[1] = alt
@@ -6254,21 +6729,17 @@ func (sp *STARSPane) formatDatablocks(ctx *PaneContext, ac *Aircraft) []STARSDat
 		if state.LostTrack(ctx.world.CurrentTime()) {
 			alt = "CST"
 		}
+		adapt := &ctx.world.STARSFacilityAdaptation.Datablocks
+		arrivalAirport := datablockArrivalAirport(ctx, ac)
 		field3 := []string{alt}
 		if ac.Scratchpad != "" {
 			field3 = append(field3, ac.Scratchpad)
 		}
-		if ac.SecondaryScratchpad != "" {
+		if ac.SecondaryScratchpad != "" && !adapt.SeparateSecondaryScratchpad {
 			field3 = append(field3, ac.SecondaryScratchpad)
 		}
-		if len(field3) == 1 {
-			if ap := ctx.world.GetAirport(ac.FlightPlan.ArrivalAirport); ap != nil && !ap.OmitArrivalScratchpad {
-				ap := ac.FlightPlan.ArrivalAirport
-				if len(ap) == 4 {
-					ap = ap[1:] // drop the leading K
-				}
-				field3 = append(field3, ap)
-			}
+		if len(field3) == 1 && arrivalAirport != "" {
+			field3 = append(field3, arrivalAirport)
 		}
 
 		field4 := "  "
@@ -6310,6 +6781,12 @@ func (sp *STARSPane) formatDatablocks(ctx *PaneContext, ac *Aircraft) []STARSDat
 		cat := getCwtCategory(ac)
 		acCategory = modifier + cat
 
+		requestedAltitude := ""
+		if (state.DisplayRequestedAltitude != nil && *state.DisplayRequestedAltitude) ||
+			(state.DisplayRequestedAltitude == nil && sp.CurrentPreferenceSet.DisplayRequestedAltitude) {
+			requestedAltitude = fmt.Sprintf("R%03d", ac.FlightPlan.Altitude/100)
+		}
+
 		field5 := []string{} // alternate speed and aircraft type
 		if state.Ident() {
 			// Speed is followed by ID when identing (2-67, field 5)
@@ -6318,9 +6795,13 @@ func (sp *STARSPane) formatDatablocks(ctx *PaneContext, ac *Aircraft) []STARSDat
 			field5 = append(field5, speed+acCategory)
 		}
 		field5 = append(field5, actype)
-		if (state.DisplayRequestedAltitude != nil && *state.DisplayRequestedAltitude) ||
-			(state.DisplayRequestedAltitude == nil && sp.CurrentPreferenceSet.DisplayRequestedAltitude) {
-			field5 = append(field5, fmt.Sprintf("R%03d", ac.FlightPlan.Altitude/100))
+		if requestedAltitude != "" {
+			field5 = append(field5, requestedAltitude)
+		}
+		modeS := []string{""}
+		if d, ok := ac.ModeSData(); ok && sp.displayModeS(state) {
+			modeS = d.DatablockFields()
+			field5 = append(field5, modeS...)
 		}
 		for i := range field5 {
 			if len(field5[i]) < 5 {
@@ -6329,24 +6810,16 @@ func (sp *STARSPane) formatDatablocks(ctx *PaneContext, ac *Aircraft) []STARSDat
 		}
 
 		field6 := ""
-		var line3FieldColors *STARSDatablockFieldColors
+		var field6Color *RGB
 		if state.DisplayATPAWarnAlert != nil && !*state.DisplayATPAWarnAlert {
 			field6 = "*TPA"
 		} else if state.IntrailDistance != 0 && sp.CurrentPreferenceSet.DisplayATPAInTrailDist {
 			field6 = fmt.Sprintf("%.2f", state.IntrailDistance)
 
 			if state.ATPAStatus == ATPAStatusWarning {
-				line3FieldColors = &STARSDatablockFieldColors{
-					Start: 0,
-					End:   len(field6),
-					Color: STARSATPAWarningColor,
-				}
+				field6Color = &STARSATPAWarningColor
 			} else if state.ATPAStatus == ATPAStatusAlert {
-				line3FieldColors = &STARSDatablockFieldColors{
-					Start: 0,
-					End:   len(field6),
-					Color: STARSATPAAlertColor,
-				}
+				field6Color = &STARSATPAAlertColor
 			}
 		}
 		for len(field6) < 5 {
@@ -6358,24 +6831,96 @@ func (sp *STARSPane) formatDatablocks(ctx *PaneContext, ac *Aircraft) []STARSDat
 			ta := (ac.TempAltitude + 50) / 100
 			field7 = fmt.Sprintf("A%03d", ta)
 		}
-		line3 := field6 + "  " + field7
 
-		// Now make some datablocks. For our purposes, only field3 and
-		// field5 (and thus line 2) may be time multiplexed, which
-		// simplifies db creation here.  Note that line 1 has already been
+		// The facility adaptation determines which fields are shown on
+		// each line; any field may be time multiplexed.
+		fields := map[string][]string{
+			"callsign":             {field1},
+			"inhibit_indicator":    {field2},
+			"pointout":             field8,
+			"altitude_scratchpad":  field3,
+			"altitude":             {alt},
+			"scratchpad":           {ac.Scratchpad},
+			"secondary_scratchpad": {ac.SecondaryScratchpad},
+			"arrival_airport":      {arrivalAirport},
+			"handoff":              {field4},
+			"speed_type":           field5,
+			"speed":                {speed},
+			"type":                 {actype},
+			"category":             {acCategory},
+			"requested_altitude":   {requestedAltitude},
+			"beacon":               {ac.Squawk.String()},
+			"atpa":                 {field6},
+			"temp_altitude":        {field7},
+			"mode_s":               modeS,
+			"_":                    {" "},
+		}
+		layout := adapt.FullDatablockLines()
+
+		similarCallsign := sp.MarkSimilarCallsigns && len(ctx.world.SimilarCallsigns(ac.Callsign)) > 0
+
+		formatLine := func(names []string, i int) STARSDatablockLine {
+			var line STARSDatablockLine
+			for _, name := range names {
+				f, ok := fields[name]
+				if !ok {
+					continue
+				}
+				text := f[i%len(f)]
+				if name == "atpa" && field6Color != nil {
+					line.Colors = append(line.Colors, STARSDatablockFieldColors{
+						Start: len(line.Text),
+						End:   len(line.Text) + len(text),
+						Color: *field6Color,
+					})
+				} else if name == "callsign" && similarCallsign {
+					line.Colors = append(line.Colors, STARSDatablockFieldColors{
+						Start: len(line.Text),
+						End:   len(line.Text) + len(text),
+						Color: STARSSimilarCallsignColor,
+					})
+				}
+				line.Text += text
+			}
+			return line
+		}
+
+		// Now make some datablocks, cycling through all of the variations
+		// of the multiplexed fields. (Note that line 0 has already been
 		// set in baseDB above.)
+		n := 1
+		for _, line := range layout {
+			for _, name := range line {
+				if f, ok := fields[name]; ok {
+					n = lcm(n, len(f))
+				}
+			}
+		}
 		dbs := []STARSDatablock{}
-		n := lcm(len(field3), len(field5)) // cycle through all variations
-		n = lcm(n, len(field8))
 		for i := 0; i < n; i++ {
 			db := baseDB.Duplicate()
-			db.Lines[1].Text = field1 + field2 + field8[i%len(field8)]
-			db.Lines[2].Text = field3[i%len(field3)] + field4 + field5[i%len(field5)]
-			db.Lines[3].Text = line3
-			if line3FieldColors != nil {
-				db.Lines[3].Colors = append(db.Lines[3].Colors, *line3FieldColors)
+			db.Lines[1] = formatLine(layout[0], i)
+			db.Lines[2] = formatLine(layout[1], i)
+			line3 := formatLine(layout[2], i)
+			active := strings.TrimSpace(line3.Text) != ""
+
+			switch adapt.Line3 {
+			case "active":
+				if active {
+					db.Lines[3] = line3
+				}
+				dbs = append(dbs, db)
+			case "alternate":
+				dbs = append(dbs, db)
+				if active {
+					db = db.Duplicate()
+					db.Lines[2] = line3
+					dbs = append(dbs, db)
+				}
+			default:
+				db.Lines[3] = line3
+				dbs = append(dbs, db)
 			}
-			dbs = append(dbs, db)
 		}
 		return dbs
 	}
@@ -6383,6 +6928,38 @@ func (sp *STARSPane) formatDatablocks(ctx *PaneContext, ac *Aircraft) []STARSDat
 	return nil
 }
 
+// displayModeS returns true if Mode S data should be shown in the
+// aircraft's datablock.
+func (sp *STARSPane) displayModeS(state *STARSAircraftState) bool {
+	if state.DisplayModeS != nil {
+		return *state.DisplayModeS
+	}
+	return sp.CurrentPreferenceSet.DisplayModeS
+}
+
+// datablockArrivalAirport returns the aircraft's arrival airport formatted
+// according to the facility adaptation, or an empty string if it
+// shouldn't be shown in its datablock.
+func datablockArrivalAirport(ctx *PaneContext, ac *Aircraft) string {
+	ap := ctx.world.GetAirport(ac.FlightPlan.ArrivalAirport)
+	if ap == nil || ap.OmitArrivalScratchpad {
+		return ""
+	}
+
+	switch ctx.world.STARSFacilityAdaptation.Datablocks.ArrivalAirport {
+	case "none":
+		return ""
+	case "full":
+		return ac.FlightPlan.ArrivalAirport
+	default:
+		icao := ac.FlightPlan.ArrivalAirport
+		if len(icao) == 4 {
+			icao = icao[1:] // drop the leading K
+		}
+		return icao
+	}
+}
+
 func sameFacility(ctx *PaneContext, receiving string) bool {
 	return ctx.world.GetControllerByCallsign(ctx.world.Callsign).FacilityIdentifier ==
 		ctx.world.GetControllerByCallsign(receiving).FacilityIdentifier
@@ -6862,15 +7439,54 @@ func (sp *STARSPane) drawAirspace(ctx *PaneContext, transforms ScopeTransformati
 	td.GenerateCommands(cb)
 }
 
+// applyControls handles continuous controls from game controllers and
+// MIDI devices.
+func (sp *STARSPane) applyControls(ctx *PaneContext) {
+	if len(ctx.controls) == 0 || sp.LockDisplay {
+		return
+	}
+
+	ps := &sp.CurrentPreferenceSet
+	brightness := map[string]*STARSBrightness{
+		"Map brightness":               &ps.Brightness.VideoGroupA,
+		"Full datablock brightness":    &ps.Brightness.FullDatablocks,
+		"Limited datablock brightness": &ps.Brightness.LimitedDatablocks,
+		"Position brightness":          &ps.Brightness.Positions,
+		"Weather brightness":           &ps.Brightness.Weather,
+		"History brightness":           &ps.Brightness.History,
+	}
+	for action, v := range ctx.controls {
+		if action == "Range" {
+			ps.Range = float32(int(6 + v*(256-6)))
+		} else if b, ok := brightness[action]; ok {
+			// Brightness is adjusted in steps of 5 in the DCB
+			*b = STARSBrightness(5 * int(v*20+0.5))
+		}
+	}
+}
+
 func (sp *STARSPane) consumeMouseEvents(ctx *PaneContext, ghosts []*GhostAircraft,
 	transforms ScopeTransformations, cb *CommandBuffer) {
 	if ctx.mouse == nil {
+		sp.touch.Reset()
 		return
 	}
 
 	mouse := ctx.mouse
 	ps := &sp.CurrentPreferenceSet
 
+	// With touch input, primary button drags pan and zoom the scope and
+	// taps are turned into clicks.
+	var touchPan [2]float32
+	var touchZoom float32
+	zoomCenter := mouse.Pos
+	if globalConfig.TouchInput {
+		touchPan, touchZoom = sp.touch.Process(mouse)
+		if touchZoom != 0 {
+			zoomCenter = sp.touch.pressPos
+		}
+	}
+
 	if ctx.mouse.Clicked[MouseButtonPrimary] && !ctx.haveFocus {
 		if ac, _ := sp.tryGetClosestAircraft(ctx.world, ctx.mouse.Pos, transforms); ac != nil {
 			sp.events.PostEvent(Event{Type: TrackClickedEvent, Callsign: ac.Callsign})
@@ -6881,8 +7497,8 @@ func (sp *STARSPane) consumeMouseEvents(ctx *PaneContext, ghosts []*GhostAircraf
 
 	if activeSpinner == nil && !sp.LockDisplay {
 		// Handle dragging the scope center
-		if mouse.Dragging[MouseButtonSecondary] {
-			delta := mouse.DragDelta
+		if mouse.Dragging[MouseButtonSecondary] || touchPan != [2]float32{} {
+			delta := Select(mouse.Dragging[MouseButtonSecondary], mouse.DragDelta, touchPan)
 			if delta[0] != 0 || delta[1] != 0 {
 				deltaLL := transforms.LatLongFromWindowV(delta)
 				ps.CurrentCenter = sub2f(ps.CurrentCenter, deltaLL)
@@ -6890,19 +7506,19 @@ func (sp *STARSPane) consumeMouseEvents(ctx *PaneContext, ghosts []*GhostAircraf
 		}
 
 		// Consume mouse wheel
-		if mouse.Wheel[1] != 0 {
+		if wheel := mouse.Wheel[1] + touchZoom; wheel != 0 {
 			r := ps.Range
-			if _, ok := ctx.keyboard.Pressed[KeyControl]; ok {
-				ps.Range += 3 * mouse.Wheel[1]
+			if ctx.keyboard != nil && ctx.keyboard.IsPressed(KeyControl) {
+				ps.Range += 3 * wheel
 			} else {
-				ps.Range += mouse.Wheel[1]
+				ps.Range += wheel
 			}
 			ps.Range = clamp(ps.Range, 6, 256) // 4-33
 
 			// We want to zoom in centered at the mouse position; this affects
 			// the scope center after the zoom, so we'll find the
 			// transformation that gives the new center position.
-			mouseLL := transforms.LatLongFromWindowP(mouse.Pos)
+			mouseLL := transforms.LatLongFromWindowP(zoomCenter)
 			scale := ps.Range / r
 			centerTransform := Identity3x3().
 				Translate(mouseLL[0], mouseLL[1]).
@@ -7719,14 +8335,19 @@ func STARSDisabledButton(text string, flags int, buttonScale float32) {
 
 // amendFlightPlan is a useful utility function for changing an entry in
 // the flightplan; the provided callback function should make the update
-// and the rest of the details are handled here.
-func amendFlightPlan(w *World, callsign string, amend func(fp *FlightPlan)) error {
+// and the rest of the details are handled here. Errors from the server are
+// reported via the err callback.
+func amendFlightPlan(w *World, callsign string, amend func(fp *FlightPlan), err func(error)) error {
 	if ac := w.GetAircraft(callsign, false); ac == nil {
 		return ErrNoAircraftForCallsign
 	} else {
-		fp := Select(ac.FlightPlan != nil, ac.FlightPlan, &FlightPlan{})
-		amend(fp)
-		return w.AmendFlightPlan(callsign, *fp)
+		var fp FlightPlan
+		if ac.FlightPlan != nil {
+			fp = *ac.FlightPlan
+		}
+		amend(&fp)
+		w.AmendFlightPlan(callsign, fp, nil, err)
+		return nil
 	}
 }
 
@@ -7790,7 +8411,7 @@ func (sp *STARSPane) radarVisibility(w *World, pos Point2LL, alt int) (primary,
 	distance = 1e30
 	single := sp.radarMode(w) == RadarModeSingle
 	for id, site := range w.RadarSites {
-		if single && ps.RadarSiteSelected != id {
+		if (single && ps.RadarSiteSelected != id) || w.RadarSiteFailed(id) {
 			continue
 		}
 
@@ -7804,43 +8425,48 @@ func (sp *STARSPane) radarVisibility(w *World, pos Point2LL, alt int) (primary,
 	return
 }
 
+// trackVisible returns true if the given aircraft's radar track should be
+// displayed.
+func (sp *STARSPane) trackVisible(w *World, ac *Aircraft, state *STARSAircraftState, now time.Time) bool {
+	// This includes the case of a spawned aircraft for which we don't
+	// yet have a radar track.
+	if state.LostTrack(now) {
+		return false
+	}
+
+	if ac.Mode == Standby {
+		// Primary-only targets are only seen within primary range.
+		p, _, _ := sp.radarVisibility(w, state.TrackPosition(), state.TrackAltitude())
+		return p && !sp.CurrentPreferenceSet.HideUncorrelatedTargets
+	}
+
+	if sp.radarMode(w) == RadarModeFused {
+		// visible unless if it's almost on the ground
+		alt := float32(state.TrackAltitude())
+		return (ac.IsDeparture() && alt > ac.DepartureAirportElevation()+100) ||
+			(!ac.IsDeparture() && alt > ac.ArrivalAirportElevation()+100)
+	}
+
+	// Otherwise see if any of the radars can see it
+	p, s, _ := sp.radarVisibility(w, state.TrackPosition(), state.TrackAltitude())
+	return p || s
+}
+
 func (sp *STARSPane) visibleAircraft(w *World) []*Aircraft {
-	var aircraft []*Aircraft
-	ps := sp.CurrentPreferenceSet
-	single := sp.radarMode(w) == RadarModeSingle
+	return sp.appendVisibleAircraft(nil, w)
+}
+
+// appendVisibleAircraft appends the visible aircraft to the provided
+// slice, allowing its storage to be reused across frames.
+func (sp *STARSPane) appendVisibleAircraft(aircraft []*Aircraft, w *World) []*Aircraft {
 	now := w.CurrentTime()
 	for callsign, state := range sp.Aircraft {
 		ac, ok := w.Aircraft[callsign]
 		if !ok {
 			continue
 		}
-		// This includes the case of a spawned aircraft for which we don't
-		// yet have a radar track.
-		if state.LostTrack(now) {
-			continue
-		}
-
-		visible := false
 
-		if sp.radarMode(w) == RadarModeFused {
-			// visible unless if it's almost on the ground
-			alt := float32(state.TrackAltitude())
-			visible = (ac.IsDeparture() && alt > ac.DepartureAirportElevation()+100) ||
-				(!ac.IsDeparture() && alt > ac.ArrivalAirportElevation()+100)
-		} else {
-			// Otherwise see if any of the radars can see it
-			for id, site := range w.RadarSites {
-				if single && ps.RadarSiteSelected != id {
-					continue
-				}
-
-				if p, s, _ := site.CheckVisibility(w, state.TrackPosition(), state.TrackAltitude()); p || s {
-					visible = true
-				}
-			}
-		}
-
-		if visible {
+		if sp.trackVisible(w, ac, state, now) {
 			aircraft = append(aircraft, ac)
 
 			// Is this the first we've seen it?
@@ -7950,14 +8576,24 @@ func (sp *STARSPane) tryGetClosestAircraft(w *World, mousePosition [2]float32, t
 	var ac *Aircraft
 	distance := float32(20) // in pixels; don't consider anything farther away
 
-	for _, a := range sp.visibleAircraft(w) {
-		pw := transforms.WindowFromLatLongP(sp.Aircraft[a.Callsign].TrackPosition())
-		dist := distance2f(pw, mousePosition)
-		if dist < distance {
+	// Only check the visibility of the aircraft near the mouse.
+	p := transforms.LatLongFromWindowP(mousePosition)
+	radius := distance * transforms.PixelDistanceNM(w.NmPerLongitude)
+	now := w.CurrentTime()
+	sp.trackIndex.Query(p, radius, func(_ Point2LL, callsign string) bool {
+		a, ok := w.Aircraft[callsign]
+		state := sp.Aircraft[callsign]
+		if !ok || state == nil || !sp.trackVisible(w, a, state, now) {
+			return true
+		}
+
+		pw := transforms.WindowFromLatLongP(state.TrackPosition())
+		if dist := distance2f(pw, mousePosition); dist < distance {
 			ac = a
 			distance = dist
 		}
-	}
+		return true
+	})
 
 	return ac, distance
 }
@@ -7978,6 +8614,20 @@ func (sp *STARSPane) tryGetClosestGhost(ghosts []*GhostAircraft, mousePosition [
 	return ghost, distance
 }
 
+// seenByWorkingRadar returns true if any radar site that hasn't failed
+// can see the aircraft.
+func (sp *STARSPane) seenByWorkingRadar(w *World, ac *Aircraft) bool {
+	for id, site := range w.RadarSites {
+		if w.RadarSiteFailed(id) {
+			continue
+		}
+		if p, s, _ := site.CheckVisibility(w, ac.Position(), int(ac.Altitude())); p || s {
+			return true
+		}
+	}
+	return false
+}
+
 func (sp *STARSPane) radarSiteId(w *World) string {
 	switch sp.radarMode(w) {
 	case RadarModeSingle:
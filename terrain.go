@@ -0,0 +1,291 @@
+// terrain.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Terrain and obstacles: if elevation and obstacle data is available for a
+// TRACON, it is used for MSAW alerts, to mask radar coverage behind
+// terrain, to pick VFR cruising altitudes, and to draw terrain contours
+// on the scope.
+//
+// Terrain data is stored in resources/terrain/<TRACON>.txt.zst. The first
+// line gives the latitude and longitude of the southwest corner of the
+// grid, the spacing between samples in degrees, and the number of samples
+// in latitude and longitude, e.g. "40.0 -75.5 0.01 200 250". It's
+// followed by one elevation in feet per line, going east and then north
+// from the southwest corner. It can be generated from SRTM or GTOPO30
+// data:
+//
+//	% gdal_translate -of XYZ -tr 0.01 0.01 -projwin ... srtm.tif grid.xyz
+//	% (echo "40.0 -75.5 0.01 200 250"; awk '{print int($3*3.28084)}' grid.xyz) | zstd -19 -o PHL.txt.zst
+//
+// (Note that gdal writes rows from north to south; they must be reversed.)
+//
+// Obstacles are stored in resources/obstacles/<TRACON>.csv.zst, with one
+// obstacle per line giving its latitude, longitude, height above ground
+// level, and the elevation of its top above mean sea level, both in feet.
+// The FAA's Digital Obstacle File can be converted to this format.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type TerrainGrid struct {
+	MinLatitude, MinLongitude float32
+	Step                      float32
+	NLat, NLong               int
+	Samples                   []int16 // elevations in feet
+}
+
+type Obstacle struct {
+	Location  Point2LL
+	AGL, AMSL int
+}
+
+type Terrain struct {
+	Grid      *TerrainGrid // may be nil if only obstacles are available
+	Obstacles []Obstacle
+}
+
+var terrainCache struct {
+	mu      sync.Mutex
+	terrain map[string]*Terrain
+}
+
+// LookupTerrain returns the terrain and obstacles for the given TRACON,
+// loading them the first time they are needed. It returns nil if there is
+// no data for the TRACON.
+func LookupTerrain(tracon string) *Terrain {
+	terrainCache.mu.Lock()
+	defer terrainCache.mu.Unlock()
+
+	if t, ok := terrainCache.terrain[tracon]; ok {
+		return t
+	}
+	if terrainCache.terrain == nil {
+		terrainCache.terrain = make(map[string]*Terrain)
+	}
+
+	t := &Terrain{}
+	if fn := "terrain/" + tracon + ".txt.zst"; resourceExists(fn) {
+		if g, err := parseTerrainGrid(LoadResource(fn)); err != nil {
+			lg.Errorf("%s: %v", fn, err)
+		} else {
+			t.Grid = g
+		}
+	}
+	if fn := "obstacles/" + tracon + ".csv.zst"; resourceExists(fn) {
+		if obs, err := parseObstacles(LoadResource(fn)); err != nil {
+			lg.Errorf("%s: %v", fn, err)
+		} else {
+			t.Obstacles = obs
+		}
+	}
+	if t.Grid == nil && len(t.Obstacles) == 0 {
+		t = nil
+	} else {
+		lg.Infof("%s: loaded terrain with %d obstacles", tracon, len(t.Obstacles))
+	}
+
+	terrainCache.terrain[tracon] = t
+	return t
+}
+
+func resourceExists(path string) bool {
+	_, err := fs.Stat(resourcesFS, path)
+	return err == nil
+}
+
+func parseTerrainGrid(b []byte) (*TerrainGrid, error) {
+	r := bufio.NewScanner(bytes.NewReader(b))
+	if !r.Scan() {
+		return nil, fmt.Errorf("missing header")
+	}
+
+	var g TerrainGrid
+	if _, err := fmt.Sscanf(r.Text(), "%f %f %f %d %d", &g.MinLatitude, &g.MinLongitude, &g.Step,
+		&g.NLat, &g.NLong); err != nil {
+		return nil, fmt.Errorf("%s: malformed header: %v", r.Text(), err)
+	}
+	if g.Step <= 0 || g.NLat < 2 || g.NLong < 2 {
+		return nil, fmt.Errorf("%s: invalid grid specification", r.Text())
+	}
+
+	g.Samples = make([]int16, 0, g.NLat*g.NLong)
+	for r.Scan() {
+		if v, err := strconv.Atoi(strings.TrimSpace(r.Text())); err != nil {
+			return nil, fmt.Errorf("%s: %v", r.Text(), err)
+		} else {
+			g.Samples = append(g.Samples, int16(v))
+		}
+	}
+
+	if len(g.Samples) != g.NLat*g.NLong {
+		return nil, fmt.Errorf("found %d samples, expected %d x %d = %d", len(g.Samples),
+			g.NLat, g.NLong, g.NLat*g.NLong)
+	}
+	return &g, nil
+}
+
+func parseObstacles(b []byte) ([]Obstacle, error) {
+	var obs []Obstacle
+	r := bufio.NewScanner(bytes.NewReader(b))
+	for r.Scan() {
+		f := strings.Split(r.Text(), ",")
+		if len(f) != 4 {
+			return nil, fmt.Errorf("%s: expected 4 fields", r.Text())
+		}
+
+		var v [4]float64
+		for i := range f {
+			var err error
+			if v[i], err = strconv.ParseFloat(strings.TrimSpace(f[i]), 32); err != nil {
+				return nil, fmt.Errorf("%s: %v", r.Text(), err)
+			}
+		}
+		obs = append(obs, Obstacle{
+			Location: Point2LL{float32(v[1]), float32(v[0])},
+			AGL:      int(v[2]),
+			AMSL:     int(v[3]),
+		})
+	}
+	return obs, nil
+}
+
+func (g *TerrainGrid) sample(lat, long int) float32 {
+	lat, long = clamp(lat, 0, g.NLat-1), clamp(long, 0, g.NLong-1)
+	return float32(g.Samples[long+g.NLong*lat])
+}
+
+// Elevation returns the terrain elevation at the given point, bilinearly
+// interpolated; ok is false if the point is outside the grid.
+func (g *TerrainGrid) Elevation(p Point2LL) (elevation float32, ok bool) {
+	x := (p[0] - g.MinLongitude) / g.Step
+	y := (p[1] - g.MinLatitude) / g.Step
+	if x < 0 || y < 0 || x > float32(g.NLong-1) || y > float32(g.NLat-1) {
+		return 0, false
+	}
+
+	x0, y0 := int(x), int(y)
+	dx, dy := x-float32(x0), y-float32(y0)
+	e0 := lerp(dx, g.sample(y0, x0), g.sample(y0, x0+1))
+	e1 := lerp(dx, g.sample(y0+1, x0), g.sample(y0+1, x0+1))
+	return lerp(dy, e0, e1), true
+}
+
+// Elevation returns the terrain elevation at the given point, or 0 if
+// it's unknown.
+func (t *Terrain) Elevation(p Point2LL) float32 {
+	if t == nil || t.Grid == nil {
+		return 0
+	}
+	e, _ := t.Grid.Elevation(p)
+	return e
+}
+
+// HighestPoint returns the elevation of the highest terrain or the top of
+// the highest obstacle within the given distance of the point.
+func (t *Terrain) HighestPoint(p Point2LL, radius float32, nmPerLongitude float32) float32 {
+	if t == nil {
+		return 0
+	}
+
+	var highest float32
+	if g := t.Grid; g != nil {
+		dlat, dlong := radius/60/g.Step, radius/nmPerLongitude/g.Step
+		x := int((p[0] - g.MinLongitude) / g.Step)
+		y := int((p[1] - g.MinLatitude) / g.Step)
+		for lat := y - int(dlat); lat <= y+int(dlat)+1; lat++ {
+			for long := x - int(dlong); long <= x+int(dlong)+1; long++ {
+				if lat < 0 || long < 0 || lat >= g.NLat || long >= g.NLong {
+					continue
+				}
+				highest = max(highest, g.sample(lat, long))
+			}
+		}
+	}
+	for _, o := range t.Obstacles {
+		if abs(o.Location[1]-p[1]) > radius/60 {
+			// Quick cull before computing the distance
+			continue
+		}
+		if float32(o.AMSL) > highest && nmdistance2ll(p, o.Location) < radius {
+			highest = float32(o.AMSL)
+		}
+	}
+	return highest
+}
+
+// BlocksLineOfSight returns true if terrain is in the way of the straight
+// line between the two given points and altitudes.
+func (t *Terrain) BlocksLineOfSight(p0 Point2LL, alt0 float32, p1 Point2LL, alt1 float32) bool {
+	if t == nil || t.Grid == nil {
+		return false
+	}
+
+	// Sample every half mile or so; the grid is coarse anyway.
+	n := int(2*nmdistance2ll(p0, p1)) + 1
+	for i := 1; i < n; i++ {
+		u := float32(i) / float32(n)
+		p := Point2LL{lerp(u, p0[0], p1[0]), lerp(u, p0[1], p1[1])}
+		if e, ok := t.Grid.Elevation(p); ok && e > lerp(u, alt0, alt1) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContourLines returns line segments along the terrain contours at
+// multiples of the given interval within the given extent.
+func (t *Terrain) ContourLines(extent Extent2D, interval int) [][2]Point2LL {
+	if t == nil || t.Grid == nil {
+		return nil
+	}
+	g := t.Grid
+
+	pt := func(lat, long float32) Point2LL {
+		return Point2LL{g.MinLongitude + long*g.Step, g.MinLatitude + lat*g.Step}
+	}
+	y0 := max(0, int((extent.p0[1]-g.MinLatitude)/g.Step))
+	y1 := min(g.NLat-1, int((extent.p1[1]-g.MinLatitude)/g.Step)+1)
+	x0 := max(0, int((extent.p0[0]-g.MinLongitude)/g.Step))
+	x1 := min(g.NLong-1, int((extent.p1[0]-g.MinLongitude)/g.Step)+1)
+
+	// Marching squares: for each cell, find where each contour level
+	// crosses its edges and connect the crossings pairwise.
+	var lines [][2]Point2LL
+	for lat := y0; lat < y1; lat++ {
+		for long := x0; long < x1; long++ {
+			// Corners, counter-clockwise from the southwest.
+			c := [4][2]float32{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+			e := [4]float32{g.sample(lat, long), g.sample(lat, long+1), g.sample(lat+1, long+1),
+				g.sample(lat+1, long)}
+
+			lo, hi := min(min(e[0], e[1]), min(e[2], e[3])), max(max(e[0], e[1]), max(e[2], e[3]))
+			for level := (int(lo)/interval + 1) * interval; float32(level) <= hi; level += interval {
+				lv := float32(level)
+				var crossings []Point2LL
+				for i := 0; i < 4; i++ {
+					j := (i + 1) % 4
+					if (e[i] < lv) == (e[j] < lv) {
+						continue
+					}
+					u := (lv - e[i]) / (e[j] - e[i])
+					crossings = append(crossings, pt(float32(lat)+lerp(u, c[i][1], c[j][1]),
+						float32(long)+lerp(u, c[i][0], c[j][0])))
+				}
+				for i := 0; i+1 < len(crossings); i += 2 {
+					lines = append(lines, [2]Point2LL{crossings[i], crossings[i+1]})
+				}
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,45 @@
+// terrain_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestTerrainGrid(t *testing.T) {
+	// 3x2 grid (latitude x longitude) with a ridge along the northern edge.
+	g, err := parseTerrainGrid([]byte("40 -75 0.5 3 2\n0\n0\n1000\n1000\n3000\n3000\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if e, ok := g.Elevation(Point2LL{-74.75, 40.25}); !ok || e != 500 {
+		t.Errorf("got elevation %f (%v), expected 500", e, ok)
+	}
+	if e, ok := g.Elevation(Point2LL{-75, 40.75}); !ok || e != 2000 {
+		t.Errorf("got elevation %f (%v), expected 2000", e, ok)
+	}
+	if _, ok := g.Elevation(Point2LL{-76, 40}); ok {
+		t.Errorf("expected point outside of grid to not have an elevation")
+	}
+
+	terrain := &Terrain{Grid: g}
+	if !terrain.BlocksLineOfSight(Point2LL{-75, 40}, 100, Point2LL{-75, 41}, 2500) {
+		t.Errorf("expected the ridge to block line of sight")
+	}
+	if terrain.BlocksLineOfSight(Point2LL{-75, 40}, 100, Point2LL{-75, 40.5}, 1500) {
+		t.Errorf("expected clear line of sight")
+	}
+
+	// The 1000', 2000', and 3000' contours each cross the grid once,
+	// running east-west.
+	if lines := terrain.ContourLines(Extent2DFromPoints([][2]float32{{-76, 39}, {-74, 42}}), 1000); len(lines) != 3 {
+		t.Errorf("got %d contour line segments, expected 3", len(lines))
+	}
+
+	if _, err := parseTerrainGrid([]byte("40 -75 0.5 3 2\n0\n0\n")); err == nil {
+		t.Errorf("expected error for too few samples")
+	}
+}
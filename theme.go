@@ -0,0 +1,379 @@
+// theme.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Themes specify the colors used both for the imgui user interface and
+// for drawing the radar scope. A Theme only stores the colors that differ
+// from vice's defaults, so that themes saved by older versions of vice
+// pick up sensible values for colors that are added later.
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type Theme struct {
+	Name string
+	// Use imgui's light color scheme rather than the dark one.
+	Light bool
+	// Use solid backgrounds and maximum-contrast text and borders for the
+	// user interface; see accessibility.go.
+	HighContrast bool
+	// Colors that differ from the defaults, indexed by ThemeColor Name.
+	Colors map[string]RGB
+}
+
+// ThemeColor describes a color that can be set by a Theme.
+type ThemeColor struct {
+	Name  string
+	Group string
+	Color *RGB
+
+	defaultColor RGB
+}
+
+// UIAccentColor is used for imgui buttons, headers, checkmarks, and the
+// like.
+var UIAccentColor = RGB{.26, .59, .98}
+
+var themeColors = []ThemeColor{
+	{Name: "Accent", Group: "User Interface", Color: &UIAccentColor},
+	{Name: "Controls", Group: "User Interface", Color: &UIControlColor},
+	{Name: "Text", Group: "User Interface", Color: &UITextColor},
+	{Name: "Highlighted text", Group: "User Interface", Color: &UITextHighlightColor},
+	{Name: "Caution", Group: "User Interface", Color: &UICautionColor},
+	{Name: "Error", Group: "User Interface", Color: &UIErrorColor},
+
+	{Name: "Background", Group: "Scope", Color: &STARSBackgroundColor},
+	{Name: "Video maps", Group: "Scope", Color: &STARSMapColor},
+	{Name: "Compass", Group: "Scope", Color: &STARSCompassColor},
+	{Name: "Range rings", Group: "Scope", Color: &STARSRangeRingColor},
+	{Name: "J-rings and cones", Group: "Scope", Color: &STARSJRingConeColor},
+	{Name: "Lists", Group: "Scope", Color: &STARSListColor},
+	{Name: "Text alerts", Group: "Scope", Color: &STARSTextAlertColor},
+
+	{Name: "Tracked aircraft", Group: "Datablocks", Color: &STARSTrackedAircraftColor},
+	{Name: "Untracked aircraft", Group: "Datablocks", Color: &STARSUntrackedAircraftColor},
+	{Name: "Inbound point outs", Group: "Datablocks", Color: &STARSInboundPointOutColor},
+	{Name: "Selected aircraft", Group: "Datablocks", Color: &STARSSelectedAircraftColor},
+	{Name: "Ghosts", Group: "Datablocks", Color: &STARSGhostColor},
+	{Name: "ATPA warning", Group: "Datablocks", Color: &STARSATPAWarningColor},
+	{Name: "ATPA alert", Group: "Datablocks", Color: &STARSATPAAlertColor},
+	{Name: "Similar callsigns", Group: "Datablocks", Color: &STARSSimilarCallsignColor},
+
+	{Name: "Track blocks", Group: "Tracks", Color: &STARSTrackBlockColor},
+	{Name: "History 1", Group: "Tracks", Color: &STARSTrackHistoryColors[0]},
+	{Name: "History 2", Group: "Tracks", Color: &STARSTrackHistoryColors[1]},
+	{Name: "History 3", Group: "Tracks", Color: &STARSTrackHistoryColors[2]},
+	{Name: "History 4", Group: "Tracks", Color: &STARSTrackHistoryColors[3]},
+	{Name: "History 5", Group: "Tracks", Color: &STARSTrackHistoryColors[4]},
+
+	{Name: "Weather levels 1-3", Group: "Weather", Color: &STARSWeatherLowColor},
+	{Name: "Weather levels 4-6", Group: "Weather", Color: &STARSWeatherHighColor},
+	{Name: "Weather stipple", Group: "Weather", Color: &STARSWeatherStippleColor},
+
+	{Name: "DCB buttons", Group: "DCB", Color: &STARSDCBButtonColor},
+	{Name: "DCB active buttons", Group: "DCB", Color: &STARSDCBActiveButtonColor},
+	{Name: "DCB disabled buttons", Group: "DCB", Color: &STARSDCBDisabledButtonColor},
+	{Name: "DCB text", Group: "DCB", Color: &STARSDCBTextColor},
+	{Name: "DCB selected text", Group: "DCB", Color: &STARSDCBTextSelectedColor},
+	{Name: "DCB disabled text", Group: "DCB", Color: &STARSDCBDisabledTextColor},
+}
+
+var themeColorGroups = []string{"User Interface", "Scope", "Datablocks", "Tracks", "Weather", "DCB"}
+
+func init() {
+	for i := range themeColors {
+		themeColors[i].defaultColor = *themeColors[i].Color
+	}
+}
+
+// Built-in themes; the colors for the STARS and ERAM presets are
+// approximations of the real systems' default color sets.
+var themePresets = []Theme{
+	{Name: "Default"},
+	{
+		Name:  "Light",
+		Light: true,
+		Colors: map[string]RGB{
+			"Controls":         {.7, .7, .7},
+			"Text":             {.1, .1, .1},
+			"Highlighted text": {.45, .45, .05},
+		},
+	},
+	{
+		Name: "STARS",
+		Colors: map[string]RGB{
+			"Background":  {0, 0, 0},
+			"Video maps":  {.7, .7, .7},
+			"Compass":     {.7, .7, .7},
+			"Range rings": {.7, .7, .7},
+		},
+	},
+	{
+		Name: "ERAM",
+		Colors: map[string]RGB{
+			"Background":         {0, 0, 0},
+			"Video maps":         {.33, .41, .55},
+			"Compass":            {.33, .41, .55},
+			"Range rings":        {.25, .3, .4},
+			"Lists":              {.85, .85, .85},
+			"Tracked aircraft":   {.95, .95, .95},
+			"Untracked aircraft": {.55, .7, .55},
+			"Track blocks":       {.95, .95, .95},
+			"Accent":             {.33, .41, .55},
+		},
+	},
+	{
+		Name:         "High Contrast",
+		HighContrast: true,
+		Colors: map[string]RGB{
+			"Accent":           {0, .5, 1},
+			"Controls":         {1, 1, 1},
+			"Text":             {1, 1, 1},
+			"Highlighted text": {1, 1, 0},
+			"Caution":          {1, .75, 0},
+			"Error":            {1, .3, .3},
+			"Background":       {0, 0, 0},
+			"Lists":            {1, 1, 1},
+		},
+	},
+}
+
+// Apply makes the theme's colors current. Colors that are set by the
+// selected color vision palette (see colorvision.go) take precedence.
+func (t *Theme) Apply() {
+	palette := currentColorVisionPalette()
+	for _, tc := range themeColors {
+		if c, ok := palette[tc.Name]; ok {
+			*tc.Color = c
+		} else if c, ok := t.Colors[tc.Name]; ok {
+			*tc.Color = c
+		} else {
+			*tc.Color = tc.defaultColor
+		}
+	}
+
+	if t.Light {
+		imgui.StyleColorsLight()
+	} else {
+		imgui.StyleColorsDark()
+	}
+
+	style := imgui.CurrentStyle()
+	accent := func(a float32) imgui.Vec4 {
+		return imgui.Vec4{X: UIAccentColor.R, Y: UIAccentColor.G, Z: UIAccentColor.B, W: a}
+	}
+	style.SetColor(imgui.StyleColorButton, accent(.4))
+	style.SetColor(imgui.StyleColorButtonHovered, accent(1))
+	style.SetColor(imgui.StyleColorButtonActive, accent(.8))
+	style.SetColor(imgui.StyleColorHeader, accent(.31))
+	style.SetColor(imgui.StyleColorHeaderHovered, accent(.8))
+	style.SetColor(imgui.StyleColorHeaderActive, accent(1))
+	style.SetColor(imgui.StyleColorCheckMark, accent(1))
+	style.SetColor(imgui.StyleColorSliderGrab, accent(.8))
+	style.SetColor(imgui.StyleColorSliderGrabActive, accent(1))
+
+	if t.HighContrast {
+		fg := Select(t.Light, imgui.Vec4{0, 0, 0, 1}, imgui.Vec4{1, 1, 1, 1})
+		bg := Select(t.Light, imgui.Vec4{1, 1, 1, 1}, imgui.Vec4{0, 0, 0, 1})
+		for _, c := range []imgui.StyleColorID{imgui.StyleColorText, imgui.StyleColorBorder,
+			imgui.StyleColorSeparator, imgui.StyleColorNavHighlight} {
+			style.SetColor(c, fg)
+		}
+		for _, c := range []imgui.StyleColorID{imgui.StyleColorWindowBg, imgui.StyleColorChildBg,
+			imgui.StyleColorPopupBg, imgui.StyleColorMenuBarBg, imgui.StyleColorTitleBg,
+			imgui.StyleColorTitleBgActive, imgui.StyleColorFrameBg} {
+			style.SetColor(c, bg)
+		}
+		style.SetColor(imgui.StyleColorTextDisabled, imgui.Vec4{.6, .6, .6, 1})
+		style.SetColor(imgui.StyleColorFrameBgHovered, accent(.6))
+		style.SetColor(imgui.StyleColorFrameBgActive, accent(.8))
+		style.SetColor(imgui.StyleColorButton, accent(.7))
+	}
+}
+
+func (t *Theme) Export(filename string) error {
+	b, err := json.MarshalIndent(t, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
+
+func ImportTheme(filename string) (*Theme, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var t Theme
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, err
+	}
+	for name := range t.Colors {
+		if !slices.ContainsFunc(themeColors, func(tc ThemeColor) bool { return tc.Name == name }) {
+			lg.Warnf("%s: unknown theme color %q", filename, name)
+		}
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	}
+	return &t, nil
+}
+
+func copyTheme(t Theme) Theme {
+	c := t
+	c.Colors = DuplicateMap(t.Colors)
+	return c
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Theme editor
+
+var themeEditor struct {
+	visible    bool
+	fileDialog *FileSelectDialogBox
+}
+
+func uiToggleShowThemeEditor() {
+	themeEditor.visible = !themeEditor.visible
+}
+
+func uiDrawThemeEditor() {
+	if themeEditor.fileDialog != nil {
+		themeEditor.fileDialog.Draw()
+	}
+	if !themeEditor.visible {
+		return
+	}
+
+	theme := &globalConfig.Theme
+	imgui.BeginV("Theme", &themeEditor.visible, imgui.WindowFlagsAlwaysAutoResize)
+
+	if imgui.BeginComboV("Preset", theme.Name, 0) {
+		selectable := func(t Theme) {
+			if imgui.SelectableV(t.Name, t.Name == theme.Name, 0, imgui.Vec2{}) {
+				*theme = copyTheme(t)
+				theme.Apply()
+			}
+		}
+		for _, t := range themePresets {
+			selectable(t)
+		}
+		if len(globalConfig.SavedThemes) > 0 {
+			imgui.Separator()
+			for _, name := range SortedMapKeys(globalConfig.SavedThemes) {
+				selectable(*globalConfig.SavedThemes[name])
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	if imgui.Checkbox("Light user interface", &theme.Light) {
+		theme.Apply()
+	}
+	if imgui.Checkbox("High contrast user interface", &theme.HighContrast) {
+		theme.Apply()
+	}
+
+	palette := currentColorVisionPalette()
+	for _, group := range themeColorGroups {
+		if !imgui.CollapsingHeader(group) {
+			continue
+		}
+		for _, tc := range themeColors {
+			if tc.Group != group {
+				continue
+			}
+			imgui.PushID(tc.Name)
+			c := [3]float32{tc.Color.R, tc.Color.G, tc.Color.B}
+			if imgui.ColorEdit3(tc.Name, &c) {
+				if theme.Colors == nil {
+					theme.Colors = make(map[string]RGB)
+				}
+				theme.Colors[tc.Name] = RGB{R: c[0], G: c[1], B: c[2]}
+				theme.Apply()
+			}
+			if _, ok := palette[tc.Name]; ok {
+				imgui.SameLine()
+				imgui.Text(FontAwesomeIconLock)
+				if imgui.IsItemHovered() {
+					imgui.SetTooltip("Set by the color vision palette")
+				}
+			} else if _, ok := theme.Colors[tc.Name]; ok {
+				imgui.SameLine()
+				if imgui.Button(FontAwesomeIconRedo) {
+					delete(theme.Colors, tc.Name)
+					theme.Apply()
+				}
+				if imgui.IsItemHovered() {
+					imgui.SetTooltip("Reset to default")
+				}
+			}
+			imgui.PopID()
+		}
+	}
+
+	if imgui.CollapsingHeader("Day and night") {
+		globalConfig.DayNight.DrawUI()
+	}
+
+	imgui.Separator()
+	imgui.InputTextV("Name", &theme.Name, 0, nil)
+	name := strings.TrimSpace(theme.Name)
+	uiStartDisable(name == "")
+	if imgui.Button("Save") {
+		if globalConfig.SavedThemes == nil {
+			globalConfig.SavedThemes = make(map[string]*Theme)
+		}
+		t := copyTheme(*theme)
+		t.Name = name
+		globalConfig.SavedThemes[name] = &t
+	}
+	imgui.SameLine()
+	if imgui.Button("Export...") {
+		t := copyTheme(*theme)
+		themeEditor.fileDialog = NewDirectorySelectDialogBox("Export to directory...", "",
+			func(dir string) {
+				fn := path.Join(dir, name+".json")
+				if err := t.Export(fn); err != nil {
+					ShowErrorDialog("%s: unable to export theme: %v", fn, err)
+				}
+			})
+		themeEditor.fileDialog.Activate()
+	}
+	uiEndDisable(name == "")
+	imgui.SameLine()
+	if imgui.Button("Import...") {
+		themeEditor.fileDialog = NewFileSelectDialogBox("Import theme...", []string{".json"}, "",
+			func(fn string) {
+				t, err := ImportTheme(fn)
+				if err != nil {
+					ShowErrorDialog("%s: unable to import theme: %v", fn, err)
+					return
+				}
+				if globalConfig.SavedThemes == nil {
+					globalConfig.SavedThemes = make(map[string]*Theme)
+				}
+				globalConfig.SavedThemes[t.Name] = t
+				globalConfig.Theme = copyTheme(*t)
+				globalConfig.Theme.Apply()
+			})
+		themeEditor.fileDialog.Activate()
+	}
+	if _, ok := globalConfig.SavedThemes[name]; ok {
+		imgui.SameLine()
+		if imgui.Button(FontAwesomeIconTrash) {
+			delete(globalConfig.SavedThemes, name)
+		}
+	}
+
+	imgui.End()
+}
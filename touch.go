@@ -0,0 +1,95 @@
+// touch.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Touchscreen and pen support for the radar scope. GLFW doesn't provide
+// multi-touch events; instead, the OS delivers touches and pen contacts
+// as emulated primary mouse button events and pen hover as cursor
+// motion. Therefore, when touch input is enabled, TouchGestures
+// reinterprets primary button input as gestures:
+//
+//   - Tap: a primary click (i.e., slew), delivered when the finger is
+//     lifted so that it isn't also issued at the start of a drag.
+//   - Drag: pans the scope.
+//   - Double-tap and drag: zooms, in when dragging up and out when
+//     dragging down (the one-finger equivalent of a pinch.)
+//
+// Two-finger pinches on precision touchpads and some touchscreen drivers
+// arrive as scroll wheel events and so already zoom the scope.
+
+import (
+	"time"
+)
+
+const (
+	touchDoubleTapInterval = 300 * time.Millisecond
+	touchDoubleTapDistance = 20
+	// Pixels of vertical drag for a one-step change in range.
+	touchZoomPixelsPerStep = 10
+)
+
+type TouchGestures struct {
+	pressed  bool
+	pressPos [2]float32
+	dragged  bool
+	zooming  bool
+
+	lastTap    time.Time
+	lastTapPos [2]float32
+}
+
+// Process updates the given mouse state to reflect touch gestures; any
+// primary button clicks and drags are removed and replaced with the
+// results of gesture recognition. It returns the amount to pan in window
+// coordinates and the amount to zoom, in the same units as mouse wheel
+// motion.
+func (tg *TouchGestures) Process(ms *MouseState) (pan [2]float32, zoom float32) {
+	const b = MouseButtonPrimary
+	now := time.Now()
+
+	if ms.Clicked[b] {
+		tg.pressed = true
+		tg.pressPos = ms.Pos
+		tg.dragged = false
+		// The second tap of a double-tap starts a zoom if followed by a
+		// drag.
+		tg.zooming = now.Sub(tg.lastTap) < touchDoubleTapInterval &&
+			distance2f(ms.Pos, tg.lastTapPos) < touchDoubleTapDistance
+	}
+	ms.Clicked[b] = false
+	ms.DoubleClicked[b] = false
+
+	if tg.pressed && ms.Dragging[b] {
+		tg.dragged = true
+		if tg.zooming {
+			zoom = -ms.DragDelta[1] / touchZoomPixelsPerStep
+		} else {
+			pan = ms.DragDelta
+		}
+	}
+	ms.Dragging[b] = false
+
+	if tg.pressed && (ms.Released[b] || !ms.Down[b]) {
+		if !tg.dragged {
+			// It was a tap.
+			ms.Clicked[b] = true
+			ms.DoubleClicked[b] = tg.zooming
+			tg.lastTap = now
+			tg.lastTapPos = tg.pressPos
+		} else {
+			tg.lastTap = time.Time{}
+		}
+		tg.pressed = false
+		tg.zooming = false
+	}
+
+	return
+}
+
+// Reset should be called when a pane stops receiving mouse events, so
+// that a gesture in progress isn't resumed later.
+func (tg *TouchGestures) Reset() {
+	*tg = TouchGestures{}
+}
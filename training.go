@@ -0,0 +1,285 @@
+// training.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Training objectives: a scenario may give a list of objectives that the
+// sim tracks the completion of, so that it can be used as a step in a
+// training syllabus rather than as an open-ended session, e.g.:
+//
+//	"objectives": [
+//	    { "description": "10 arrivals with no deal",
+//	      "complete": "landed >= 10", "fail": "deals > 0" },
+//	    { "description": "Use all three approaches",
+//	      "complete": "approaches_used >= 3" }
+//	]
+//
+// Conditions are written using the expression language in script.go. In
+// addition to the variables described there, the following are
+// available, both here and in triggers:
+//
+//	landed:         number of arrivals that have landed
+//	deals:          number of losses of separation (less than 3nm and
+//	                1000' between aircraft tracked by a human controller)
+//	approaches_used: number of distinct approaches that aircraft have
+//	                been cleared for
+//	cleared_approach: number of aircraft currently cleared for an approach
+//	established:    number of aircraft currently cleared for an approach
+//	                and established on the final approach course
+//	handoffs_accepted: number of handoffs accepted by a human controller
+//	handoffs_made:  number of handoffs from a human controller that have
+//	                been accepted
+//
+// Once an objective is complete or has failed, it stays that way. When
+// all of a scenario's objectives are complete, the scenario is marked as
+// completed in the scenario list so that the user can move on to the
+// next one.
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type ScenarioObjective struct {
+	Description string `json:"description"`
+	Complete    string `json:"complete"`
+	Fail        string `json:"fail,omitempty"`
+
+	complete, fail scriptExpr
+}
+
+type ObjectiveStatus int
+
+const (
+	ObjectiveInProgress = iota
+	ObjectiveComplete
+	ObjectiveFailed
+)
+
+func (s ObjectiveStatus) String() string {
+	return [...]string{"In progress", "Complete", "Failed"}[s]
+}
+
+// ObjectiveReport is sent to clients to report the status of an
+// objective.
+type ObjectiveReport struct {
+	Description string
+	Status      ObjectiveStatus
+}
+
+// TrainingState holds the per-Sim statistics that objectives are
+// evaluated with; it is serialized with the Sim.
+type TrainingState struct {
+	Landed           int
+	Deals            int
+	ApproachesUsed   map[string]interface{}
+	HandoffsAccepted int
+	HandoffsMade     int
+	Status           []ObjectiveStatus // per objective
+
+	// The current step of the tutorial, if any (see tutorial.go), and
+	// whether its actions have been run.
+	TutorialStep    int
+	TutorialStarted bool
+
+	// Pairs of aircraft that are currently in a loss of separation, so
+	// that each one is only counted once.
+	dealPairs map[[2]string]interface{}
+}
+
+// Compile parses the objective's conditions.
+func (o *ScenarioObjective) Compile() error {
+	if o.Complete == "" {
+		return fmt.Errorf("\"%s\": no \"complete\" condition specified", o.Description)
+	}
+
+	var err error
+	if o.complete, err = parseScriptExpr(o.Complete); err != nil {
+		return fmt.Errorf("\"%s\": %w", o.Complete, err)
+	}
+	if o.Fail != "" {
+		if o.fail, err = parseScriptExpr(o.Fail); err != nil {
+			return fmt.Errorf("\"%s\": %w", o.Fail, err)
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Sim integration
+
+// updateTrainingStats updates the statistics that objectives refer to; it
+// is called once a second with s.mu held.
+func (s *Sim) updateTrainingStats() {
+	ts := &s.TrainingState
+	if ts.ApproachesUsed == nil {
+		ts.ApproachesUsed = make(map[string]interface{})
+	}
+
+	var tracked []*Aircraft
+	isTracked := make(map[*Aircraft]bool)
+	for _, ac := range s.World.Aircraft {
+		if ap := ac.Nav.Approach; ap.Cleared && ap.AssignedId != "" && ac.FlightPlan != nil {
+			ts.ApproachesUsed[ac.FlightPlan.ArrivalAirport+"/"+ap.AssignedId] = nil
+		}
+		if ctrl := s.World.GetControllerByCallsign(ac.TrackingController); ctrl != nil && ctrl.IsHuman &&
+			ac.IsAirborne() {
+			tracked = append(tracked, ac)
+			isTracked[ac] = true
+		}
+	}
+
+	deals := make(map[[2]string]interface{})
+	for _, ac0 := range tracked {
+		s.aircraftIndex.Query(ac0.Position(), 3, func(_ Point2LL, ac1 *Aircraft) bool {
+			// Only consider each pair once and only pairs where both
+			// aircraft are tracked by a human.
+			if ac1.Callsign <= ac0.Callsign || !isTracked[ac1] {
+				return true
+			}
+			if abs(ac0.Altitude()-ac1.Altitude()) >= 1000 {
+				return true
+			}
+			// Don't count aircraft on final; they are allowed to be
+			// closer than this.
+			if ac0.Nav.Approach.PassedApproachFix || ac1.Nav.Approach.PassedApproachFix {
+				return true
+			}
+
+			pair := [2]string{ac0.Callsign, ac1.Callsign}
+			deals[pair] = nil
+			if _, ok := ts.dealPairs[pair]; !ok {
+				ts.Deals++
+				s.lg.Info("loss of separation", slog.String("aircraft", pair[0]+"/"+pair[1]))
+			}
+			return true
+		})
+	}
+	ts.dealPairs = deals
+}
+
+// updateObjectives evaluates the scenario's objectives; it is called with
+// s.mu held.
+func (s *Sim) updateObjectives() {
+	if len(s.Objectives) == 0 {
+		return
+	}
+
+	ts := &s.TrainingState
+	if len(ts.Status) != len(s.Objectives) {
+		ts.Status = make([]ObjectiveStatus, len(s.Objectives))
+	}
+
+	env := s.scriptEnv()
+	for i := range s.Objectives {
+		o := &s.Objectives[i]
+		if ts.Status[i] != ObjectiveInProgress {
+			continue
+		}
+		if o.complete == nil {
+			// As with triggers, compile lazily since the compiled form
+			// isn't serialized.
+			if err := o.Compile(); err != nil {
+				continue
+			}
+		}
+
+		if o.fail != nil && o.fail.eval(env) != 0 {
+			ts.Status[i] = ObjectiveFailed
+			s.eventStream.Post(Event{Type: StatusMessageEvent, Message: "Objective failed: " + o.Description})
+		} else if o.complete.eval(env) != 0 {
+			ts.Status[i] = ObjectiveComplete
+			s.eventStream.Post(Event{Type: StatusMessageEvent, Message: "Objective complete: " + o.Description})
+		}
+	}
+}
+
+func (s *Sim) objectiveReports() []ObjectiveReport {
+	var r []ObjectiveReport
+	for i, o := range s.Objectives {
+		rep := ObjectiveReport{Description: o.Description}
+		if i < len(s.TrainingState.Status) {
+			rep.Status = s.TrainingState.Status[i]
+		}
+		r = append(r, rep)
+	}
+	return r
+}
+
+///////////////////////////////////////////////////////////////////////////
+// UI
+
+var training struct {
+	visible bool
+	// The scenario that the window was last shown for, so that it's
+	// opened automatically for new ones.
+	scenario string
+}
+
+func uiToggleShowTrainingWindow() {
+	training.visible = !training.visible
+}
+
+func trainingScenarioKey(tracon, scenario string) string {
+	return tracon + "/" + scenario
+}
+
+// uiDrawTrainingWindow shows the status of the scenario's objectives and
+// records the scenario as completed once they all are.
+func uiDrawTrainingWindow(w *World) {
+	if w == nil || len(w.Objectives) == 0 {
+		return
+	}
+
+	key := trainingScenarioKey(w.TRACON, w.SimDescription)
+	if training.scenario != key {
+		training.scenario = key
+		training.visible = true
+	}
+
+	complete, failed := true, false
+	for _, o := range w.Objectives {
+		complete = complete && o.Status == ObjectiveComplete
+		failed = failed || o.Status == ObjectiveFailed
+	}
+	if complete && !globalConfig.CompletedScenarios[key] {
+		if globalConfig.CompletedScenarios == nil {
+			globalConfig.CompletedScenarios = make(map[string]bool)
+		}
+		globalConfig.CompletedScenarios[key] = true
+		lg.Infof("%s: all training objectives completed", key)
+	}
+
+	if !training.visible {
+		return
+	}
+
+	imgui.BeginV("Training Objectives", &training.visible, imgui.WindowFlagsAlwaysAutoResize)
+	for _, o := range w.Objectives {
+		switch o.Status {
+		case ObjectiveComplete:
+			imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{.4, 1, .4, 1})
+			imgui.Text(FontAwesomeIconCheckSquare + " " + o.Description)
+		case ObjectiveFailed:
+			imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{1, .5, .5, 1})
+			imgui.Text(FontAwesomeIconExclamationTriangle + " " + o.Description)
+		default:
+			imgui.PushStyleColor(imgui.StyleColorText, imgui.CurrentStyle().Color(imgui.StyleColorText))
+			imgui.Text(FontAwesomeIconSquare + " " + o.Description)
+		}
+		imgui.PopStyleColor()
+	}
+
+	if complete {
+		imgui.Separator()
+		imgui.Text("All objectives complete.")
+	} else if failed {
+		imgui.Separator()
+		imgui.Text("Start the scenario again to retry failed objectives.")
+	}
+
+	imgui.End()
+}
@@ -0,0 +1,35 @@
+// training_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestObjectiveCompile(t *testing.T) {
+	o := ScenarioObjective{Description: "10 arrivals with no deal", Complete: "landed >= 10", Fail: "deals > 0"}
+	if err := o.Compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	env := scriptEnv{"landed": 10, "deals": 0}
+	if o.complete.eval(env) == 0 || o.fail.eval(env) != 0 {
+		t.Errorf("expected objective to be complete and not failed")
+	}
+	env["deals"] = 1
+	if o.fail.eval(env) == 0 {
+		t.Errorf("expected objective to have failed")
+	}
+
+	for _, bad := range []ScenarioObjective{
+		{Description: "no condition"},
+		{Description: "bad variable", Complete: "landings > 3"},
+		{Description: "bad fail", Complete: "approaches_used >= 3", Fail: "deals >"},
+	} {
+		if err := bad.Compile(); err == nil {
+			t.Errorf("%s: expected an error", bad.Description)
+		}
+	}
+}
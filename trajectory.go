@@ -0,0 +1,217 @@
+// trajectory.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Trajectory prediction: the sim periodically predicts where each
+// airborne aircraft will be over the next minute by flying a copy of it
+// ahead, following its current clearances and accounting for the wind.
+// The predictions are sent to clients along with the aircraft, where
+// they're used by conflict alert and ATPA; the conflict probe (see
+// conflictprobe.go) makes longer predictions the same way.
+//
+// The predicted positions come with an estimate of their uncertainty,
+// which grows with how far ahead they are: pilots don't fly exactly the
+// modeled paths, the radar tracks that controllers see lag the aircraft,
+// and so forth. Consumers can decide how conservative to be by scaling
+// the uncertainty when checking separation.
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+const (
+	trajectoryHorizon        = time.Minute
+	trajectoryStep           = 5 * time.Second
+	trajectoryUpdateInterval = 5 * time.Second
+
+	// How quickly the uncertainty in the predicted position grows: nm of
+	// lateral uncertainty per minute, and feet of vertical uncertainty
+	// per minute while the aircraft is climbing or descending.
+	trajectoryLateralUncertaintyRate  = 0.25
+	trajectoryVerticalUncertaintyRate = 300
+)
+
+type TrajectoryPoint struct {
+	Position Point2LL
+	Altitude float32
+	// Radius of the lateral confidence interval, in nm, and half the
+	// height of the vertical one, in feet.
+	LateralUncertainty  float32
+	VerticalUncertainty float32
+}
+
+// Trajectory holds an aircraft's predicted positions starting at the
+// given sim time and spaced at regular intervals.
+type Trajectory struct {
+	Start  time.Time
+	Step   time.Duration
+	Points []TrajectoryPoint
+}
+
+func (t Trajectory) End() time.Time {
+	if len(t.Points) == 0 {
+		return t.Start
+	}
+	return t.Start.Add(time.Duration(len(t.Points)-1) * t.Step)
+}
+
+// At returns the predicted position at the given time, interpolating
+// between the stored points; false is returned if the time isn't covered
+// by the prediction.
+func (t Trajectory) At(tm time.Time) (TrajectoryPoint, bool) {
+	if len(t.Points) == 0 || tm.Before(t.Start) || tm.After(t.End()) {
+		return TrajectoryPoint{}, false
+	}
+	if len(t.Points) == 1 || t.Step == 0 {
+		return t.Points[0], true
+	}
+
+	f := float32(tm.Sub(t.Start)) / float32(t.Step)
+	i := min(int(f), len(t.Points)-2)
+	f -= float32(i)
+	p0, p1 := t.Points[i], t.Points[i+1]
+	return TrajectoryPoint{
+		Position:            Point2LL(lerp2f(f, p0.Position, p1.Position)),
+		Altitude:            lerp(f, p0.Altitude, p1.Altitude),
+		LateralUncertainty:  lerp(f, p0.LateralUncertainty, p1.LateralUncertainty),
+		VerticalUncertainty: lerp(f, p0.VerticalUncertainty, p1.VerticalUncertainty),
+	}, true
+}
+
+// TrajectoryConflict describes a predicted loss of separation between
+// two trajectories.
+type TrajectoryConflict struct {
+	Time time.Time // when separation is first lost
+	// The predicted separation at the closest point while separation is
+	// lost.
+	Distance           float32
+	AltitudeDifference float32
+}
+
+// Conflict checks whether the two trajectories are predicted to come
+// within the given lateral (nm) and vertical (feet) separation between
+// the given times. The uncertainties of the predictions are scaled by
+// confidence and subtracted from the separation; zero only considers
+// the most likely positions.
+func (t Trajectory) Conflict(o Trajectory, lateral, vertical, confidence float32,
+	from, until time.Time) (TrajectoryConflict, bool) {
+	var c TrajectoryConflict
+	found := false
+	for i, p := range t.Points {
+		tm := t.Start.Add(time.Duration(i) * t.Step)
+		if tm.Before(from) {
+			continue
+		}
+		if tm.After(until) || tm.After(o.End()) {
+			break
+		}
+		q, ok := o.At(tm)
+		if !ok {
+			continue
+		}
+
+		d := nmdistance2ll(p.Position, q.Position)
+		dalt := abs(p.Altitude - q.Altitude)
+		if d-confidence*(p.LateralUncertainty+q.LateralUncertainty) >= lateral ||
+			dalt-confidence*(p.VerticalUncertainty+q.VerticalUncertainty) > vertical-5 /* slop */ {
+			continue
+		}
+
+		if !found {
+			c = TrajectoryConflict{Time: tm, Distance: d, AltitudeDifference: dalt}
+			found = true
+		} else if d < c.Distance {
+			c.Distance, c.AltitudeDifference = d, dalt
+		}
+	}
+	return c, found
+}
+
+// copyNav returns a deep copy of the nav state so that it can be flown
+// ahead without affecting the aircraft.
+func copyNav(nav *Nav) (*Nav, error) {
+	b, err := json.Marshal(nav)
+	if err != nil {
+		return nil, err
+	}
+	var c Nav
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// PredictTrajectory predicts the aircraft's positions, starting at the
+// given sim time, by flying a copy of it ahead one second at a time. The
+// prediction ends early if the aircraft lands.
+func (ac *Aircraft) PredictTrajectory(wind WindModel, start time.Time, horizon, step time.Duration,
+	lg *Logger) (Trajectory, error) {
+	nav, err := copyNav(&ac.Nav)
+	if err != nil {
+		return Trajectory{}, err
+	}
+
+	traj := Trajectory{Start: start, Step: step}
+	n := int(horizon / time.Second)
+	perStep := int(step / time.Second)
+	var vertical float32
+	for i := 0; i <= n; i++ {
+		if i%perStep == 0 {
+			minutes := float32(i) / 60
+			traj.Points = append(traj.Points, TrajectoryPoint{
+				Position:            nav.FlightState.Position,
+				Altitude:            nav.FlightState.Altitude,
+				LateralUncertainty:  trajectoryLateralUncertaintyRate * minutes,
+				VerticalUncertainty: vertical,
+			})
+		}
+		if i == n || ac.Frozen {
+			continue
+		}
+
+		alt := nav.FlightState.Altitude
+		if wp := nav.Update(wind, lg); wp != nil && wp.Delete {
+			break
+		}
+		if nav.FlightState.Altitude != alt {
+			vertical += trajectoryVerticalUncertaintyRate / 60
+		}
+	}
+	return traj, nil
+}
+
+// updateTrajectories updates the predicted trajectories of the aircraft
+// if it's time to do so; s.mu must be held.
+func (s *Sim) updateTrajectories(now time.Time) {
+	if now.Sub(s.lastTrajectoryUpdate) < trajectoryUpdateInterval {
+		return
+	}
+	s.lastTrajectoryUpdate = now
+
+	var aircraft []*Aircraft
+	for _, ac := range s.World.Aircraft {
+		if ac.IsAirborne() {
+			aircraft = append(aircraft, ac)
+		} else {
+			ac.Trajectory = Trajectory{}
+		}
+	}
+	sort.Slice(aircraft, func(i, j int) bool { return aircraft[i].Callsign < aircraft[j].Callsign })
+
+	lg := s.lg.Subsystem("trajectory")
+	ParallelFor(len(aircraft), minAircraftPerNavWorker, func(i int) {
+		ac := aircraft[i]
+		if traj, err := ac.PredictTrajectory(s.World, now, trajectoryHorizon, trajectoryStep, lg); err != nil {
+			lg.Error("unable to predict trajectory", slog.String("callsign", ac.Callsign),
+				slog.Any("error", err))
+			ac.Trajectory = Trajectory{}
+		} else {
+			ac.Trajectory = traj
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// trajectory_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrajectoryAt(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	traj := Trajectory{
+		Start: start,
+		Step:  10 * time.Second,
+		Points: []TrajectoryPoint{
+			{Position: Point2LL{-74, 40}, Altitude: 5000},
+			{Position: Point2LL{-73.9, 40}, Altitude: 6000, LateralUncertainty: 1},
+		},
+	}
+
+	if p, ok := traj.At(start.Add(5 * time.Second)); !ok {
+		t.Errorf("expected a point halfway along")
+	} else if abs(p.Position[0]+73.95) > 1e-4 || p.Altitude != 5500 || p.LateralUncertainty != 0.5 {
+		t.Errorf("got %+v halfway along", p)
+	}
+	if p, ok := traj.At(traj.End()); !ok || p.Altitude != 6000 {
+		t.Errorf("got %+v, %v at the end", p, ok)
+	}
+	for _, tm := range []time.Time{start.Add(-time.Second), start.Add(11 * time.Second)} {
+		if _, ok := traj.At(tm); ok {
+			t.Errorf("%s: expected no prediction", tm)
+		}
+	}
+}
+
+func TestTrajectoryConflictConfidence(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	// Two aircraft at the same altitude on parallel courses ~3.5nm apart
+	// with increasingly uncertain positions.
+	a := Trajectory{Start: start, Step: 10 * time.Second}
+	b := Trajectory{Start: start, Step: 10 * time.Second}
+	for i := 0; i < 4; i++ {
+		u := 0.2 * float32(i)
+		a.Points = append(a.Points, TrajectoryPoint{Position: Point2LL{-74, 40 + 0.02*float32(i)},
+			Altitude: 8000, LateralUncertainty: u})
+		b.Points = append(b.Points, TrajectoryPoint{Position: Point2LL{-73.925, 40 + 0.02*float32(i)},
+			Altitude: 8000, LateralUncertainty: u})
+	}
+
+	if _, ok := a.Conflict(b, 3, 1000, 0, start, a.End()); ok {
+		t.Errorf("unexpected conflict for the most likely positions")
+	}
+	if c, ok := a.Conflict(b, 3, 1000, 1, start, a.End()); !ok {
+		t.Errorf("expected a conflict when accounting for uncertainty")
+	} else if want := start.Add(20 * time.Second); !c.Time.Equal(want) {
+		t.Errorf("expected the conflict at %s, got %s", want, c.Time)
+	}
+	if _, ok := a.Conflict(b, 3, 1000, 1, start, start.Add(10*time.Second)); ok {
+		t.Errorf("unexpected conflict before the time limit")
+	}
+	if c, ok := a.Conflict(b, 3, 1000, 1, start.Add(25*time.Second), a.End()); !ok {
+		t.Errorf("expected a conflict after the start time")
+	} else if want := start.Add(30 * time.Second); !c.Time.Equal(want) {
+		t.Errorf("expected the conflict at %s, got %s", want, c.Time)
+	}
+}
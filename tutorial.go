@@ -0,0 +1,277 @@
+// tutorial.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Tutorials: a scenario may give a sequence of steps that walk a new
+// user through the basics of controlling, one at a time, e.g.:
+//
+//	"tutorial": [
+//	    { "title": "Welcome",
+//	      "instructions": "You are working Allentown approach..." },
+//	    { "title": "Accept a handoff",
+//	      "instructions": "An arrival is being handed off to you...",
+//	      "do": [ "spawn_arrival ZNY Southwest KABE" ],
+//	      "complete": "handoffs_accepted >= 1" }
+//	]
+//
+// When a step starts, its "do" actions are run; they use the same
+// actions as triggers (see script.go) and are typically used to spawn
+// the traffic that the step is about, so tutorial scenarios generally
+// set all of their launch rates to zero. A step is complete when its
+// "complete" condition is true, evaluated against the actual state of
+// the sim using the variables described in script.go and training.go;
+// steps without one wait for the user to click "Continue". The user
+// may also skip a step, e.g. if the aircraft it was about has left.
+//
+// Scenarios whose names start with "Tutorial" are offered to new users
+// in the connect dialog, so that they can find them without knowing
+// which TRACON has one.
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type TutorialStep struct {
+	Title        string   `json:"title"`
+	Instructions string   `json:"instructions"`
+	Do           []string `json:"do,omitempty"`
+	Complete     string   `json:"complete,omitempty"`
+
+	complete scriptExpr
+	actions  []scriptAction
+	compiled bool
+}
+
+// TutorialReport is sent to clients to report the current step of the
+// tutorial. Step == Steps once the tutorial is finished; Steps is zero if
+// the scenario doesn't have one.
+type TutorialReport struct {
+	Step, Steps  int
+	Title        string
+	Instructions string
+	// Manual is set if the step waits for the user to continue.
+	Manual bool
+}
+
+func (r TutorialReport) Done() bool {
+	return r.Steps > 0 && r.Step == r.Steps
+}
+
+// Compile parses the step's condition and actions.
+func (t *TutorialStep) Compile() error {
+	if t.Title == "" {
+		return fmt.Errorf("no \"title\" specified")
+	}
+
+	var err error
+	t.complete = nil
+	if t.Complete != "" {
+		if t.complete, err = parseScriptExpr(t.Complete); err != nil {
+			return fmt.Errorf("\"%s\": \"%s\": %w", t.Title, t.Complete, err)
+		}
+	}
+
+	t.actions = nil
+	for _, d := range t.Do {
+		if a, err := parseScriptAction(d); err != nil {
+			return fmt.Errorf("\"%s\": \"%s\": %w", t.Title, d, err)
+		} else {
+			t.actions = append(t.actions, a)
+		}
+	}
+	t.compiled = true
+	return nil
+}
+
+// cloneTutorial returns a copy of the scenario's tutorial steps for a new
+// Sim, so that Sims don't share the steps' compiled state.
+func cloneTutorial(steps []TutorialStep) []TutorialStep {
+	return slices.Clone(steps)
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Sim integration
+
+// updateTutorial starts the current tutorial step if it hasn't been
+// started yet and advances to the next one once it's complete. It is
+// called with s.mu held.
+func (s *Sim) updateTutorial() {
+	ts := &s.TrainingState
+	if ts.TutorialStep >= len(s.Tutorial) {
+		return
+	}
+
+	t := &s.Tutorial[ts.TutorialStep]
+	if !t.compiled {
+		// As with triggers, compile lazily since the compiled form isn't
+		// serialized. Errors were reported when the scenario was loaded,
+		// so this should only fail for a sim saved by an older version
+		// of vice; skip the step rather than getting stuck on it.
+		if err := t.Compile(); err != nil {
+			s.lg.Errorf("tutorial step %d: %v", ts.TutorialStep+1, err)
+			s.advanceTutorial()
+			return
+		}
+	}
+
+	if !ts.TutorialStarted {
+		s.lg.Info("starting tutorial step", slog.Int("step", ts.TutorialStep), slog.String("title", t.Title))
+		ts.TutorialStarted = true
+		for _, a := range t.actions {
+			s.runScriptAction(a)
+		}
+		// Evaluate the condition on the next update so that the traffic
+		// the actions launched is included.
+		return
+	}
+
+	if t.complete != nil && t.complete.eval(s.scriptEnv()) != 0 {
+		s.eventStream.Post(Event{Type: StatusMessageEvent, Message: "Tutorial step complete: " + t.Title})
+		s.advanceTutorial()
+	}
+}
+
+func (s *Sim) advanceTutorial() {
+	s.TrainingState.TutorialStep++
+	s.TrainingState.TutorialStarted = false
+	s.updateTutorial()
+}
+
+// AdvanceTutorial moves to the next step of the tutorial, regardless of
+// whether the current one is complete.
+func (s *Sim) AdvanceTutorial(token string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if _, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	} else if s.TrainingState.TutorialStep < len(s.Tutorial) {
+		s.advanceTutorial()
+	}
+	return nil
+}
+
+func (s *Sim) tutorialReport() TutorialReport {
+	r := TutorialReport{Step: s.TrainingState.TutorialStep, Steps: len(s.Tutorial)}
+	if r.Step < r.Steps {
+		t := s.Tutorial[r.Step]
+		r.Title = t.Title
+		r.Instructions = t.Instructions
+		r.Manual = t.Complete == ""
+	}
+	return r
+}
+
+///////////////////////////////////////////////////////////////////////////
+// UI
+
+var tutorial struct {
+	visible bool
+	// The scenario and step that the window was last shown for, so that
+	// it's reopened when a new step starts.
+	scenario string
+	step     int
+}
+
+// tutorialScenario returns the first tutorial scenario that the server
+// offers.
+func (c *NewSimConfiguration) tutorialScenario() (tracon, group, scenario string, ok bool) {
+	for _, tracon := range SortedMapKeys(c.selectedServer.configs) {
+		for _, group := range SortedMapKeys(c.selectedServer.configs[tracon]) {
+			for _, scenario := range SortedMapKeys(c.selectedServer.configs[tracon][group].ScenarioConfigs) {
+				if strings.HasPrefix(scenario, "Tutorial") {
+					return tracon, group, scenario, true
+				}
+			}
+		}
+	}
+	return
+}
+
+// uiDrawTutorialOffer offers to select the tutorial scenario until the
+// user has completed it.
+func (c *NewSimConfiguration) uiDrawTutorialOffer() {
+	tracon, group, scenario, ok := c.tutorialScenario()
+	if !ok || globalConfig.CompletedScenarios[trainingScenarioKey(tracon, scenario)] ||
+		(tracon == c.TRACONName && scenario == c.ScenarioName) {
+		return
+	}
+
+	if imgui.Button(FontAwesomeIconGraduationCap + " New to vice? Start with the tutorial") {
+		c.SetTRACON(tracon)
+		c.SetScenario(group, scenario)
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Selects a scenario that walks you through accepting a handoff,\n" +
+			"vectoring an arrival to final, and handing off a departure")
+	}
+}
+
+func uiToggleShowTutorialWindow() {
+	tutorial.visible = !tutorial.visible
+}
+
+func uiDrawTutorialWindow(w *World) {
+	if w == nil || w.Tutorial.Steps == 0 {
+		return
+	}
+	t := w.Tutorial
+
+	key := trainingScenarioKey(w.TRACON, w.SimDescription)
+	if tutorial.scenario != key || tutorial.step != t.Step {
+		tutorial.scenario = key
+		tutorial.step = t.Step
+		tutorial.visible = true
+		if t.Done() {
+			announce("Tutorial complete")
+		} else {
+			announce(t.Title + ". " + t.Instructions)
+		}
+	}
+
+	if t.Done() && !globalConfig.CompletedScenarios[key] {
+		if globalConfig.CompletedScenarios == nil {
+			globalConfig.CompletedScenarios = make(map[string]bool)
+		}
+		globalConfig.CompletedScenarios[key] = true
+		lg.Infof("%s: tutorial completed", key)
+	}
+
+	if !tutorial.visible {
+		return
+	}
+
+	imgui.BeginV("Tutorial", &tutorial.visible, imgui.WindowFlagsAlwaysAutoResize)
+	if t.Done() {
+		imgui.Text("Tutorial complete!")
+		imgui.Text("You can keep controlling here or start another scenario.")
+	} else {
+		imgui.Text(fmt.Sprintf("Step %d of %d: %s", t.Step+1, t.Steps, t.Title))
+		imgui.Separator()
+		instructions, _ := wrapText(t.Instructions, 60, 0, true)
+		imgui.Text(instructions)
+		imgui.Separator()
+
+		if t.Manual {
+			if imgui.Button("Continue") {
+				w.AdvanceTutorial()
+			}
+		} else {
+			imgui.Text(FontAwesomeIconSquare + " Waiting for you to complete this step")
+			if imgui.Button("Skip step") {
+				w.AdvanceTutorial()
+			}
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("Move on to the next step without completing this one")
+			}
+		}
+	}
+	imgui.End()
+}
@@ -0,0 +1,110 @@
+// tutorial_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestTutorialSteps(t *testing.T) {
+	s := &Sim{
+		World: &World{
+			Aircraft:    make(map[string]*Aircraft),
+			Controllers: make(map[string]*Controller),
+		},
+		Tutorial: []TutorialStep{
+			{Title: "Welcome", Instructions: "Hello"},
+			{Title: "Accept a handoff", Do: []string{"message handoff incoming"}, Complete: "handoffs_accepted >= 1"},
+			{Title: "Hand off", Complete: "handoffs_made >= 1"},
+		},
+		eventStream: NewEventStream(),
+	}
+	for i := range s.Tutorial {
+		if err := s.Tutorial[i].Compile(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", s.Tutorial[i].Title, err)
+		}
+	}
+	sub := s.eventStream.Subscribe()
+
+	// Steps without a condition wait for the user.
+	for i := 0; i < 3; i++ {
+		s.updateTutorial()
+	}
+	if r := s.tutorialReport(); r.Step != 0 || r.Steps != 3 || !r.Manual || r.Title != "Welcome" {
+		t.Errorf("unexpected report %+v", r)
+	}
+
+	// Advancing starts the next step immediately, running its actions.
+	s.advanceTutorial()
+	if ev := sub.Get(); len(ev) != 1 || ev[0].Message != "handoff incoming" {
+		t.Errorf("expected the step's message, got %v", ev)
+	}
+	s.updateTutorial()
+	if r := s.tutorialReport(); r.Step != 1 || r.Manual {
+		t.Errorf("unexpected report %+v", r)
+	}
+
+	// Conditions are checked against the sim's state.
+	s.TrainingState.HandoffsAccepted++
+	s.updateTutorial()
+	if r := s.tutorialReport(); r.Step != 2 {
+		t.Errorf("expected step 2, got %+v", r)
+	}
+	if ev := sub.Get(); len(ev) != 1 || ev[0].Type != StatusMessageEvent {
+		t.Errorf("expected a step complete message, got %v", ev)
+	}
+
+	s.TrainingState.HandoffsMade++
+	s.updateTutorial()
+	s.updateTutorial()
+	if r := s.tutorialReport(); !r.Done() {
+		t.Errorf("expected the tutorial to be done, got %+v", r)
+	}
+	s.updateTutorial()
+
+	for _, bad := range []TutorialStep{
+		{Instructions: "no title"},
+		{Title: "bad condition", Complete: "handoffs > 1"},
+		{Title: "bad action", Do: []string{"spawn_everything"}},
+	} {
+		if err := bad.Compile(); err == nil {
+			t.Errorf("%+v: expected an error", bad)
+		}
+	}
+}
+
+func TestTutorialPerSim(t *testing.T) {
+	steps := []TutorialStep{
+		{Title: "Welcome"},
+		{Title: "Hand off", Complete: "handoffs_made >= 1"},
+	}
+	for i := range steps {
+		if err := steps[i].Compile(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", steps[i].Title, err)
+		}
+	}
+
+	// Recompiling one sim's copy shouldn't touch the scenario's steps.
+	c := cloneTutorial(steps)
+	c[1].Complete = "handoffs_made >= 2"
+	c[1].compiled = false
+	if err := c[1].Compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if steps[1].Complete != "handoffs_made >= 1" || !steps[1].compiled {
+		t.Errorf("scenario's tutorial step was modified")
+	}
+
+	// A step that fails to compile when it is reached is skipped.
+	s := &Sim{
+		World:       &World{Aircraft: make(map[string]*Aircraft)},
+		Tutorial:    []TutorialStep{{Title: "bad", Complete: "handoffs > 1"}, {Title: "Welcome"}},
+		eventStream: NewEventStream(),
+	}
+	s.updateTutorial()
+	if r := s.tutorialReport(); r.Step != 1 || r.Title != "Welcome" {
+		t.Errorf("expected the bad step to be skipped, got %+v", r)
+	}
+}
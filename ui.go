@@ -11,7 +11,6 @@ import (
 	"fmt"
 	"image/png"
 	"log/slog"
-	"net/http"
 	"os"
 	"path"
 	"runtime"
@@ -209,11 +208,11 @@ func imguiInit() *imgui.Context {
 }
 
 func uiInit(r Renderer, p Platform, es *EventStream) {
-	if runtime.GOOS == "windows" {
-		imgui.CurrentStyle().ScaleAllSizes(p.DPIScale())
-	}
+	uiScaleState.styleScale = styleScale(p)
+	imgui.CurrentStyle().ScaleAllSizes(uiScaleState.styleScale)
+	globalConfig.Theme.Apply()
 
-	ui.font = GetFont(FontIdentifier{Name: "Roboto Regular", Size: globalConfig.UIFontSize})
+	ui.font = GetFont(FontIdentifier{Name: "Roboto Regular", Size: globalConfig.uiFontSize()})
 	ui.aboutFont = GetFont(FontIdentifier{Name: "Roboto Regular", Size: 18})
 	ui.aboutFontSmall = GetFont(FontIdentifier{Name: "Roboto Regular", Size: 14})
 	ui.eventsSubscription = es.Subscribe()
@@ -257,6 +256,21 @@ func uiShowConnectDialog(allowCancel bool) {
 	uiShowModalDialog(NewModalDialogBox(&ConnectModalClient{allowCancel: allowCancel}), false)
 }
 
+// uiShowJoinDialog shows the connect dialog with the given remote sim
+// selected, e.g. for vice:// links, replacing any connect dialog that is
+// already open.
+func uiShowJoinDialog(simName string, allowCancel bool) {
+	uiCloseConnectDialogs()
+	uiShowModalDialog(NewModalDialogBox(&ConnectModalClient{allowCancel: allowCancel, joinSim: simName}), false)
+}
+
+func uiCloseConnectDialogs() {
+	ui.activeModalDialogs = FilterSlice(ui.activeModalDialogs, func(m *ModalDialogBox) bool {
+		_, ok := m.client.(*ConnectModalClient)
+		return !ok
+	})
+}
+
 func uiShowDiscordOptInDialog() {
 	uiShowModalDialog(NewModalDialogBox(&DiscordOptInModalClient{}), true)
 }
@@ -284,6 +298,13 @@ func uiEndDisable(b bool) {
 }
 
 func drawUI(p Platform, r Renderer, w *World, eventStream *EventStream, stats *Stats) {
+	if w != nil {
+		uiApplyPendingLayout(w, r, eventStream)
+	}
+	uiUpdateScreenshots(eventStream)
+	uiUpdateVideoRecording()
+	uiUpdateDayNight(w)
+
 	if ui.newReleaseDialogChan != nil {
 		select {
 		case dialog, ok := <-ui.newReleaseDialogChan:
@@ -318,6 +339,17 @@ func drawUI(p Platform, r Renderer, w *World, eventStream *EventStream, stats *S
 					imgui.SetTooltip("Pause simulation")
 				}
 			}
+
+			imgui.Text(w.CurrentTime().UTC().Format("15:04:05Z"))
+			if imgui.IsItemHovered() {
+				tip := "Simulation time"
+				if w.SimIsPaused {
+					tip += " (paused)"
+				} else if w.SimRate != 1 {
+					tip += fmt.Sprintf(" (%.1fx)", w.SimRate)
+				}
+				imgui.SetTooltip(tip)
+			}
 		}
 
 		if imgui.Button(FontAwesomeIconRedo) {
@@ -350,6 +382,64 @@ func drawUI(p Platform, r Renderer, w *World, eventStream *EventStream, stats *S
 			imgui.SetTooltip("Show summary of keyboard commands")
 		}
 
+		if w != nil && len(w.Objectives) > 0 {
+			if imgui.Button(FontAwesomeIconCheckSquare) {
+				uiToggleShowTrainingWindow()
+			}
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("Show training objectives")
+			}
+		}
+		if w != nil && w.Tutorial.Steps > 0 {
+			if imgui.Button(FontAwesomeIconGraduationCap) {
+				uiToggleShowTutorialWindow()
+			}
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("Show tutorial")
+			}
+		}
+
+		if w != nil && w.Connected() {
+			if imgui.Button(FontAwesomeIconUserFriends) {
+				uiToggleShowReliefWindow()
+			}
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("Position relief")
+			}
+		}
+
+		if eventStream.Journal() != nil {
+			if imgui.Button(FontAwesomeIconHistory) {
+				uiToggleShowEventHistoryWindow()
+			}
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("Search the history of events in this session")
+			}
+		}
+
+		uiDrawSavedSimsMenu(w)
+		uiDrawProfilesMenu(w, r, eventStream)
+		uiDrawLayoutsMenu(w, r, eventStream)
+		uiDrawPaneWindowsMenu(p)
+		plugins.DrawMenu()
+		uiDrawVideoMenu(eventStream)
+
+		if imgui.BeginMenu(FontAwesomeIconFile) {
+			if imgui.MenuItem("Show log viewer") {
+				uiToggleShowLogViewer()
+			}
+			if imgui.MenuItem("Show logs folder") {
+				uiOpenLogsFolder()
+			}
+			if imgui.MenuItemV("Show performance HUD", "", perfHUD.visible, true) {
+				uiToggleShowPerfHUD()
+			}
+			imgui.EndMenu()
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Logs and diagnostics")
+		}
+
 		enableLaunch := w != nil &&
 			(w.LaunchConfig.Controller == "" || w.LaunchConfig.Controller == w.Callsign)
 		uiStartDisable(!enableLaunch)
@@ -423,6 +513,20 @@ func drawUI(p Platform, r Renderer, w *World, eventStream *EventStream, stats *S
 
 	uiDrawKeyboardWindow(w)
 
+	uiDrawEventHistoryWindow(eventStream.Journal())
+	uiDrawReliefWindow(w, eventStream)
+	uiDrawTrainingWindow(w)
+	uiDrawTutorialWindow(w)
+	plugins.DrawPanes()
+	uiDrawLogViewer()
+	uiDrawPerfHUD(stats, w)
+	uiDrawProfilesWindow(w, r, eventStream)
+	uiDrawThemeEditor()
+	uiDrawKeyBindingsEditor(p)
+	uiDrawHardwareKeyboardEditor(p)
+	uiDrawControllerEditor()
+	uiDrawAdaptationEditor(w)
+
 	imgui.PopFont()
 
 	// Finalize and submit the imgui draw lists
@@ -643,6 +747,12 @@ func (m *ModalDialogBox) Draw() {
 			imgui.SetKeyboardFocusHere()
 			m.client.Opening()
 			m.isOpen = true
+
+			text := m.client.Title()
+			if ad, ok := m.client.(accessibleDialog); ok {
+				text += ". " + ad.AccessibleText()
+			}
+			announce(text)
 		}
 
 		selIndex := m.client.Draw()
@@ -663,7 +773,11 @@ func (m *ModalDialogBox) Draw() {
 			if i > 0 {
 				imgui.SameLine()
 			}
-			if (imgui.Button(b.text) || i == selIndex) && !b.disabled {
+			pressed := imgui.Button(b.text)
+			if imgui.IsItemHovered() {
+				announceLabel(b.text + Select(b.disabled, " (unavailable)", ""))
+			}
+			if (pressed || i == selIndex) && !b.disabled {
 				if b.action == nil || b.action() {
 					imgui.CloseCurrentPopup()
 					m.closed = true
@@ -680,16 +794,29 @@ func (m *ModalDialogBox) Draw() {
 type ConnectModalClient struct {
 	config      NewSimConfiguration
 	allowCancel bool
+	joinSim     string
 }
 
 func (c *ConnectModalClient) Title() string { return "New Simulation" }
 
 func (c *ConnectModalClient) Opening() {
 	c.config = MakeNewSimConfiguration()
+	if c.joinSim != "" && !c.config.SelectRemoteSim(c.joinSim) {
+		c.config.displayError = fmt.Errorf("%s: simulation is not available on the server", c.joinSim)
+	}
 }
 
 func (c *ConnectModalClient) Buttons() []ModalDialogButton {
 	var b []ModalDialogButton
+	if c.config.NewSimType == NewSimCreateLocal {
+		b = append(b, ModalDialogButton{
+			text: "Scenario Packs...",
+			action: func() bool {
+				uiShowModalDialog(NewModalDialogBox(&ScenarioPacksModalClient{allowCancel: c.allowCancel}), false)
+				return true
+			},
+		})
+	}
 	if c.allowCancel {
 		b = append(b, ModalDialogButton{text: "Cancel"})
 	}
@@ -794,6 +921,8 @@ func (yn *YesOrNoModalClient) Buttons() []ModalDialogButton {
 	return b
 }
 
+func (yn *YesOrNoModalClient) AccessibleText() string { return yn.query }
+
 func (yn *YesOrNoModalClient) Draw() int {
 	imgui.Text(yn.query)
 	return -1
@@ -804,21 +933,25 @@ func checkForNewRelease(newReleaseDialogChan chan *NewReleaseModalClient) {
 
 	url := "https://api.github.com/repos/mmp/vice/releases"
 
-	resp, err := http.Get(url)
+	// Make a conditional request so that repeated checks don't count
+	// against GitHub's API rate limit if nothing has changed.
+	b, err := FetchURLWithOptions(url, FetchOptions{
+		Timeout:   15 * time.Second,
+		CacheFile: fetchCacheFilePath("releases.json"),
+		MaxSize:   16 << 20,
+	})
 	if err != nil {
 		lg.Warn("new release GET error", slog.String("url", url), slog.Any("error", err))
 		return
 	}
-	defer resp.Body.Close()
 
 	type Release struct {
 		TagName string    `json:"tag_name"`
 		Created time.Time `json:"created_at"`
 	}
 
-	decoder := json.NewDecoder(resp.Body)
 	var releases []Release
-	if err := decoder.Decode(&releases); err != nil {
+	if err := json.Unmarshal(b, &releases); err != nil {
 		lg.Errorf("JSON decode error: %v", err)
 		return
 	}
@@ -1327,6 +1460,8 @@ func (e *ErrorModalClient) Buttons() []ModalDialogButton {
 	return b
 }
 
+func (e *ErrorModalClient) AccessibleText() string { return e.message }
+
 func (e *ErrorModalClient) Draw() int {
 	if imgui.BeginTableV("Error", 2, 0, imgui.Vec2{}, 0) {
 		imgui.TableSetupColumn("icon")
@@ -1334,7 +1469,9 @@ func (e *ErrorModalClient) Draw() int {
 
 		imgui.TableNextRow()
 		imgui.TableNextColumn()
-		imgui.Image(imgui.TextureID(ui.sadTowerTextureID), imgui.Vec2{128, 128})
+		if ui.sadTowerTextureID != 0 { // not yet available if called before uiInit
+			imgui.Image(imgui.TextureID(ui.sadTowerTextureID), imgui.Vec2{128, 128})
+		}
 
 		imgui.TableNextColumn()
 		text, _ := wrapText(e.message, 80, 0, true)
@@ -1352,16 +1489,35 @@ func ShowErrorDialog(s string, args ...interface{}) {
 	lg.Errorf(s, args...)
 }
 
-func ShowFatalErrorDialog(r Renderer, p Platform, s string, args ...interface{}) {
+// ShowFatalErrorDialog runs its own event loop to display the given error
+// message until the user dismisses it. If report is non-nil, the user is
+// also offered the option of uploading it.
+func ShowFatalErrorDialog(r Renderer, p Platform, report *CrashReport, s string, args ...interface{}) {
 	lg.Errorf(s, args...)
 
-	d := NewModalDialogBox(&ErrorModalClient{message: fmt.Sprintf(s, args...)})
+	var d *ModalDialogBox
+	if report != nil {
+		d = NewModalDialogBox(&CrashReportModalClient{message: fmt.Sprintf(s, args...), report: report})
+	} else {
+		d = NewModalDialogBox(&ErrorModalClient{message: fmt.Sprintf(s, args...)})
+	}
+	uiRunModalDialog(r, p, d)
+}
+
+// uiRunModalDialog runs its own event loop to display the given dialog
+// until it is closed. It may be called before uiInit, as long as the
+// fonts have been initialized.
+func uiRunModalDialog(r Renderer, p Platform, d *ModalDialogBox) {
+	font := ui.font
+	if font == nil {
+		font = GetFont(FontIdentifier{Name: "Roboto Regular", Size: globalConfig.uiFontSize()})
+	}
 
 	for !d.closed {
 		p.ProcessEvents()
 		p.NewFrame()
 		imgui.NewFrame()
-		imgui.PushFont(ui.font.ifont)
+		imgui.PushFont(font.ifont)
 		d.Draw()
 		imgui.PopFont()
 
@@ -1647,24 +1803,34 @@ func MakeLaunchControlWindow(w *World) *LaunchControlWindow {
 	return lc
 }
 
+// spawnDeparture returns nil if it isn't possible to create a departure
+// for the given airport, runway, and category; this is also reported when
+// the sim is created (see simcheck.go).
 func (lc *LaunchControlWindow) spawnDeparture(airport, rwy, category string) *Aircraft {
-	for i := 0; i < 100; i++ {
-		if ac, _, err := lc.w.CreateDeparture(airport, rwy, category, 0, nil); err == nil {
+	var err error
+	for i := 0; i < spawnCheckTries; i++ {
+		var ac *Aircraft
+		if ac, _, err = lc.w.CreateDeparture(airport, rwy, category, 0, nil); err == nil {
 			return ac
 		}
 	}
-	panic("unable to spawn a departure")
+	lg.Errorf("%s/%s/%s: unable to spawn a departure: %v", airport, rwy, category, err)
+	return nil
 }
 
+// spawnArrival returns nil if it isn't possible to create an arrival.
 func (lc *LaunchControlWindow) spawnArrival(group, airport string) *Aircraft {
-	for i := 0; i < 100; i++ {
+	var err error
+	for i := 0; i < spawnCheckTries; i++ {
 		goAround := rand.Float32() < lc.w.LaunchConfig.GoAroundRate
 
-		if ac, err := lc.w.CreateArrival(group, airport, goAround); err == nil {
+		var ac *Aircraft
+		if ac, err = lc.w.CreateArrival(group, airport, goAround); err == nil {
 			return ac
 		}
 	}
-	panic("unable to spawn an arrival")
+	lg.Errorf("%s/%s: unable to spawn an arrival: %v", group, airport, err)
+	return nil
 }
 
 func (lc *LaunchControlWindow) Draw(w *World, eventStream *EventStream) {
@@ -1777,26 +1943,36 @@ func (lc *LaunchControlWindow) Draw(w *World, eventStream *EventStream) {
 				imgui.TableNextColumn()
 				imgui.Text(strconv.Itoa(dep.TotalLaunches))
 
-				imgui.TableNextColumn()
-				imgui.Text(dep.Aircraft.Callsign)
+				if dep.Aircraft == nil {
+					// No aircraft could be created; leave the row empty
+					// other than the button to try again.
+					imgui.TableNextColumn()
+					imgui.Text("(unable to spawn)")
+					for i := 0; i < 5; i++ {
+						imgui.TableNextColumn()
+					}
+				} else {
+					imgui.TableNextColumn()
+					imgui.Text(dep.Aircraft.Callsign)
 
-				imgui.TableNextColumn()
-				imgui.Text(dep.Aircraft.FlightPlan.TypeWithoutSuffix())
+					imgui.TableNextColumn()
+					imgui.Text(dep.Aircraft.FlightPlan.TypeWithoutSuffix())
 
-				imgui.TableNextColumn()
-				imgui.Text(dep.Aircraft.Scratchpad)
+					imgui.TableNextColumn()
+					imgui.Text(dep.Aircraft.Scratchpad)
 
-				mitAndTime(dep.Aircraft, dep.Aircraft.Position(), dep.LastLaunchCallsign,
-					dep.LastLaunchTime)
+					mitAndTime(dep.Aircraft, dep.Aircraft.Position(), dep.LastLaunchCallsign,
+						dep.LastLaunchTime)
 
-				imgui.TableNextColumn()
-				if imgui.Button(FontAwesomeIconPlaneDeparture) {
-					lc.w.LaunchAircraft(*dep.Aircraft)
-					dep.LastLaunchCallsign = dep.Aircraft.Callsign
-					dep.LastLaunchTime = lc.w.CurrentTime()
-					dep.TotalLaunches++
+					imgui.TableNextColumn()
+					if imgui.Button(FontAwesomeIconPlaneDeparture) {
+						lc.w.LaunchAircraft(*dep.Aircraft)
+						dep.LastLaunchCallsign = dep.Aircraft.Callsign
+						dep.LastLaunchTime = lc.w.CurrentTime()
+						dep.TotalLaunches++
 
-					dep.Aircraft = lc.spawnDeparture(dep.Airport, dep.Runway, dep.Category)
+						dep.Aircraft = lc.spawnDeparture(dep.Airport, dep.Runway, dep.Category)
+					}
 				}
 
 				imgui.TableNextColumn()
@@ -1841,23 +2017,31 @@ func (lc *LaunchControlWindow) Draw(w *World, eventStream *EventStream) {
 				imgui.TableNextColumn()
 				imgui.Text(arr.Airport)
 
-				imgui.TableNextColumn()
-				imgui.Text(arr.Aircraft.Callsign)
+				if arr.Aircraft == nil {
+					imgui.TableNextColumn()
+					imgui.Text("(unable to spawn)")
+					for i := 0; i < 4; i++ {
+						imgui.TableNextColumn()
+					}
+				} else {
+					imgui.TableNextColumn()
+					imgui.Text(arr.Aircraft.Callsign)
 
-				imgui.TableNextColumn()
-				imgui.Text(arr.Aircraft.FlightPlan.TypeWithoutSuffix())
+					imgui.TableNextColumn()
+					imgui.Text(arr.Aircraft.FlightPlan.TypeWithoutSuffix())
 
-				mitAndTime(arr.Aircraft, arr.Aircraft.Position(), arr.LastLaunchCallsign,
-					arr.LastLaunchTime)
+					mitAndTime(arr.Aircraft, arr.Aircraft.Position(), arr.LastLaunchCallsign,
+						arr.LastLaunchTime)
 
-				imgui.TableNextColumn()
-				if imgui.Button(FontAwesomeIconPlaneDeparture) {
-					lc.w.LaunchAircraft(*arr.Aircraft)
-					arr.LastLaunchCallsign = arr.Aircraft.Callsign
-					arr.LastLaunchTime = lc.w.CurrentTime()
-					arr.TotalLaunches++
+					imgui.TableNextColumn()
+					if imgui.Button(FontAwesomeIconPlaneDeparture) {
+						lc.w.LaunchAircraft(*arr.Aircraft)
+						arr.LastLaunchCallsign = arr.Aircraft.Callsign
+						arr.LastLaunchTime = lc.w.CurrentTime()
+						arr.TotalLaunches++
 
-					arr.Aircraft = lc.spawnArrival(arr.Group, arr.Airport)
+						arr.Aircraft = lc.spawnArrival(arr.Group, arr.Airport)
+					}
 				}
 
 				imgui.TableNextColumn()
@@ -1877,14 +2061,38 @@ func (lc *LaunchControlWindow) Draw(w *World, eventStream *EventStream) {
 			lc.w.LaunchConfig.DrawActiveDepartureRunways()
 			imgui.EndTable()
 		}
-		changed := lc.w.LaunchConfig.DrawDepartureUI()
-		changed = lc.w.LaunchConfig.DrawArrivalUI() || changed
+		// Individual rates are sent to the sim as they're changed so that
+		// they don't overwrite changes made by scenario scripts.
+		changed := lc.w.LaunchConfig.DrawDepartureUI(func(airport, runway, category string, rate int) {
+			lc.w.SetDepartureRate(airport, runway, category, rate, eventStream)
+		})
+		changed = lc.w.LaunchConfig.DrawArrivalUI(func(group, airport string, rate int) {
+			lc.w.SetArrivalRate(group, airport, rate, eventStream)
+		}) || changed
+		changed = lc.w.LaunchConfig.DrawScheduleUI() || changed
+		changed = lc.w.LaunchConfig.DrawVFRUI() || changed
+		changed = lc.w.LaunchConfig.DrawPilotRequestUI() || changed
+		changed = lc.w.LaunchConfig.DrawCallsignUI() || changed
+		imgui.Separator()
+		changed = lc.w.LaunchConfig.Adaptive.DrawUI() || changed
 
 		if changed {
 			lc.w.SetLaunchConfig(lc.w.LaunchConfig)
 		}
 	}
 
+	if len(lc.w.RadarSites) > 0 {
+		imgui.Separator()
+		if imgui.CollapsingHeader("Radar Sites") {
+			for _, id := range SortedMapKeys(lc.w.RadarSites) {
+				failed := lc.w.RadarSiteFailed(id)
+				if imgui.Checkbox("Fail "+id+" ("+lc.w.RadarSites[id].Char+")", &failed) {
+					lc.w.SetRadarSiteFailed(id, failed, eventStream)
+				}
+			}
+		}
+	}
+
 	imgui.End()
 
 	if !showLaunchControls {
@@ -1896,53 +2104,12 @@ func (lc *LaunchControlWindow) Draw(w *World, eventStream *EventStream) {
 
 var keyboardWindowVisible bool
 var selectedCommandTypes string
+var commandSearch string
 
 func uiToggleShowKeyboardWindow() {
 	keyboardWindowVisible = !keyboardWindowVisible
 }
 
-var primaryAcCommands = [][3]string{
-	[3]string{"*H_hdg", `"Fly heading _hdg_." If no heading is given, "fly present heading".`,
-		"*H050*, *H*"},
-	[3]string{"*D_fix", `"Proceed direct _fix_".`, "*DWAVEY*"},
-	[3]string{"*C_alt", `"Climb and maintain _alt_".`, "*C170*"},
-	[3]string{"*TC_alt", `"After reaching speed _kts_, climb and maintain _alt_", where _kts_ is a previously-assigned speed.`, "*TC170*"},
-	[3]string{"*D_alt", `"Descend and maintain _alt_".`, "*D20*"},
-	[3]string{"*TD_alt", `"Descend and maintain _alt_ after reaching _kts_ knots", where _kts_ is a previously-assigned
-speed. (*TD* = 'then descend')`, "*TD20*"},
-	[3]string{"*S_kts", `"Reduce/increase speed to _kts_."
-If no speed is given, "cancel speed restrictions".`, "*S210*, *S*"},
-	[3]string{"*TS_kts", `"After reaching _alt_, reduce/increase speed to _kts_", where _alt_ is a previously-assigned
-altitude. (*TS* = 'then speed')`, "*TS210*"},
-	[3]string{"*E_appr", `"Expect the _appr_ approach."`, "*EI2L*"},
-	[3]string{"*C_appr", `"Cleared _appr_ approach."`, "*CI2L*"},
-	[3]string{"*TO*", `"Contact tower"`, "*TO*"},
-	[3]string{"*FC*", `"Contact _ctrl_ on _freq_, where _ctrl_ is the controller who has the track and _freq_ is their frequency."`, "*FC*"},
-	[3]string{"*X*", "(Deletes the aircraft.)", "*X*"},
-}
-
-var secondaryAcCommands = [][3]string{
-	[3]string{"*L_hdg", `"Turn left heading _hdg_."`, "*L130*"},
-	[3]string{"*T_deg*L", `"Turn _deg_ degrees left."`, "*T10L*"},
-	[3]string{"*R_hdg", `"Turn right heading _hdg_".`, "*R210*"},
-	[3]string{"*T_deg*R", `"Turn _deg_ degrees right".`, "*T20R*"},
-	[3]string{"*D_fix*/H_hdg", `"Depart _fix_ heading _hdg_".`, "*DLENDY/H180*"},
-	[3]string{"*C_fix*/A_alt*/S_kts",
-		`"Cross _fix_ at _alt_ / _kts_ knots."
-Either one or both of *A* and *S* may be specified.`, "*CCAMRN/A110+*"},
-	[3]string{"*ED*", `"Expedite descent"`, "*ED*"},
-	[3]string{"*EC*", `"Expedite climb"`, "*EC*"},
-	[3]string{"*SMIN*", `"Maintain slowest practical speed".`, "*SMIN*"},
-	[3]string{"*SMAX*", `"Maintain maximum forward speed".`, "*SMAX*"},
-	[3]string{"*A_fix*/C_appr", `"At _fix_, cleared _appr_ approach."`, "*AROSLY/CI2L*"},
-	[3]string{"*CAC*", `"Cancel approach clearance".`, "*CAC*"},
-	[3]string{"*CSI_appr", `"Cleared straight-in _appr_ approach.`, "*CSII6*"},
-	[3]string{"*I*", `"Intercept the localizer."`, "*I*"},
-	[3]string{"*ID*", `"Ident."`, "*ID*"},
-	[3]string{"*CVS*", `"Climb via the SID"`, "*CVS*"},
-	[3]string{"*DVS*", `"Descend via the STAR"`, "*CVS*"},
-}
-
 var starsCommands = [][2]string{
 	[2]string{"@", `If the aircraft is an inbound handoff, accept the handoff.
 If the aircraft has been handed off to another controller who has accepted
@@ -1984,8 +2151,13 @@ func uiDrawKeyboardWindow(w *World) {
 
 	imgui.Separator()
 
-	fixedFont := GetFont(FontIdentifier{Name: "Roboto Mono", Size: globalConfig.UIFontSize})
-	italicFont := GetFont(FontIdentifier{Name: "Roboto Mono Italic", Size: globalConfig.UIFontSize})
+	imgui.InputTextV("Search", &commandSearch, 0, nil)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Show all commands whose syntax, description, or example includes the given text")
+	}
+
+	fixedFont := GetFont(FontIdentifier{Name: "Roboto Mono", Size: globalConfig.uiFontSize()})
+	italicFont := GetFont(FontIdentifier{Name: "Roboto Mono Italic", Size: globalConfig.uiFontSize()})
 
 	// Tighten up the line spacing
 	spc := style.ItemSpacing()
@@ -2002,7 +2174,8 @@ func uiDrawKeyboardWindow(w *World) {
 	if selectedCommandTypes == "" {
 		selectedCommandTypes = ACControlPrimary
 	}
-	if imgui.BeginComboV("Command Group", selectedCommandTypes, imgui.ComboFlagsHeightLarge) {
+	searching := strings.TrimSpace(commandSearch) != ""
+	if !searching && imgui.BeginComboV("Command Group", selectedCommandTypes, imgui.ComboFlagsHeightLarge) {
 		if imgui.SelectableV(ACControlPrimary, selectedCommandTypes == ACControlPrimary, 0, imgui.Vec2{}) {
 			selectedCommandTypes = ACControlPrimary
 		}
@@ -2017,7 +2190,58 @@ func uiDrawKeyboardWindow(w *World) {
 
 	flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg |
 		imgui.TableFlagsSizingStretchProp
-	if selectedCommandTypes == ACControlPrimary || selectedCommandTypes == ACControlSecondary {
+	drawAircraftCommands := func(show func(c AircraftCommand) bool) {
+		if imgui.BeginTableV("control", 3, flags, imgui.Vec2{}, 0.) {
+			imgui.TableSetupColumn("Command")
+			imgui.TableSetupColumn("Instruction")
+			imgui.TableSetupColumn("Example")
+			imgui.TableHeadersRow()
+
+			for _, cmd := range aircraftCommands {
+				if !show(cmd) {
+					continue
+				}
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd.Syntax)
+				imgui.TableNextColumn()
+				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd.Description)
+				imgui.TableNextColumn()
+				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd.Example)
+			}
+			imgui.EndTable()
+		}
+	}
+	drawSTARSCommands := func(show func(cmd [2]string) bool) {
+		if imgui.BeginTableV("stars", 2, flags, imgui.Vec2{}, 0.) {
+			imgui.TableSetupColumn("Command")
+			imgui.TableSetupColumn("Description")
+			imgui.TableHeadersRow()
+
+			for _, cmd := range starsCommands {
+				if !show(cmd) {
+					continue
+				}
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd[0])
+				imgui.TableNextColumn()
+				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd[1])
+			}
+			imgui.EndTable()
+		}
+	}
+
+	if searching {
+		imgui.Text("\n")
+		drawAircraftCommands(func(c AircraftCommand) bool {
+			return matchesCommandSearch(commandSearch, c.Syntax, c.Description, c.Example)
+		})
+		imgui.Text("\n")
+		drawSTARSCommands(func(cmd [2]string) bool {
+			return matchesCommandSearch(commandSearch, cmd[0], cmd[1])
+		})
+	} else if selectedCommandTypes == ACControlPrimary || selectedCommandTypes == ACControlSecondary {
 		imgui.Text("\n")
 		uiDrawMarkedupText(ui.font, fixedFont, italicFont, `
 To issue a command to an aircraft, enter one the following commands and then click on an
@@ -2048,24 +2272,8 @@ after the first.`)
 			}
 		}
 
-		if imgui.BeginTableV("control", 3, flags, imgui.Vec2{}, 0.) {
-			imgui.TableSetupColumn("Command")
-			imgui.TableSetupColumn("Instruction")
-			imgui.TableSetupColumn("Example")
-			imgui.TableHeadersRow()
-
-			cmds := Select(selectedCommandTypes == ACControlPrimary, primaryAcCommands, secondaryAcCommands)
-			for _, cmd := range cmds {
-				imgui.TableNextRow()
-				imgui.TableNextColumn()
-				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd[0])
-				imgui.TableNextColumn()
-				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd[1])
-				imgui.TableNextColumn()
-				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd[2])
-			}
-			imgui.EndTable()
-		}
+		primary := selectedCommandTypes == ACControlPrimary
+		drawAircraftCommands(func(c AircraftCommand) bool { return c.Primary == primary })
 	} else {
 		imgui.Text("\n")
 		uiDrawMarkedupText(ui.font, fixedFont, italicFont, `
@@ -2075,20 +2283,7 @@ The _id_s used to identify other controllers are 2-3 characters and are listed t
 control positions in the controller list on the upper right side of the scope (unless moved).`)
 		imgui.Text("\n\n")
 
-		if imgui.BeginTableV("stars", 2, flags, imgui.Vec2{}, 0.) {
-			imgui.TableSetupColumn("Command")
-			imgui.TableSetupColumn("Description")
-			imgui.TableHeadersRow()
-
-			for _, cmd := range starsCommands {
-				imgui.TableNextRow()
-				imgui.TableNextColumn()
-				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd[0])
-				imgui.TableNextColumn()
-				uiDrawMarkedupText(ui.font, fixedFont, italicFont, cmd[1])
-			}
-			imgui.EndTable()
-		}
+		drawSTARSCommands(func([2]string) bool { return true })
 	}
 
 	imgui.PopStyleVar()
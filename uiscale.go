@@ -0,0 +1,90 @@
+// uiscale.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// The UI scale setting enlarges (or shrinks) all text and imgui widgets
+// uniformly, which is mostly useful on high-resolution displays. Fonts
+// are rasterized into the font atlas at their scaled size when vice
+// starts; if the scale changes while vice is running (either because the
+// user changed the setting or because the window moved to a monitor with
+// a different DPI), imgui's global font scale is used to preview the new
+// size until the next time vice starts. Each pane's settings window also
+// has controls for the size of the text it draws.
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+var uiScaleChoices = []float32{0.75, 0.875, 1, 1.125, 1.25, 1.5, 1.75, 2, 2.5, 3}
+
+var uiScaleState struct {
+	// Scale factor that the font atlas was built with.
+	fontsBuiltScale float32
+	// Scale factor currently applied to the imgui style sizes.
+	styleScale float32
+}
+
+// uiScale returns the user's UI scale setting.
+func uiScale() float32 {
+	if globalConfig.UIScale == 0 {
+		return 1
+	}
+	return clamp(globalConfig.UIScale, 0.5, 4)
+}
+
+// fontScale returns the factor by which nominal font sizes should be
+// scaled to get the size of the rasterized fonts.
+func fontScale(p Platform) float32 {
+	s := float32(1)
+	if runtime.GOOS == "windows" {
+		// Windows uses 96dpi but everyone else uses 72; for high-DPI
+		// displays, including fractional scales like 150%, use the
+		// display's scale factor.
+		s = max(p.DPIScale(), 96./72.)
+	}
+	return s * uiScale()
+}
+
+// styleScale returns the factor that imgui's style sizes (padding,
+// spacing, etc.) should be scaled by.
+func styleScale(p Platform) float32 {
+	s := uiScale()
+	if runtime.GOOS == "windows" {
+		s *= p.DPIScale()
+	}
+	return s
+}
+
+// uiUpdateScale should be called each frame before imgui.NewFrame; it
+// applies any changes to the UI scale or the display's DPI.
+func uiUpdateScale(p Platform) {
+	if uiScaleState.fontsBuiltScale == 0 {
+		return // fonts aren't initialized yet
+	}
+	imgui.CurrentIO().SetFontGlobalScale(fontScale(p) / uiScaleState.fontsBuiltScale)
+
+	if s := styleScale(p); s != uiScaleState.styleScale {
+		imgui.CurrentStyle().ScaleAllSizes(s / uiScaleState.styleScale)
+		uiScaleState.styleScale = s
+	}
+}
+
+func uiDrawScaleSettings() {
+	cur := uiScale()
+	if imgui.BeginComboV("UI Scale", fmt.Sprintf("%d%%", int(100*cur+0.5)), 0) {
+		for _, s := range uiScaleChoices {
+			if imgui.SelectableV(fmt.Sprintf("%d%%", int(100*s+0.5)), s == cur, 0, imgui.Vec2{}) {
+				globalConfig.UIScale = s
+			}
+		}
+		imgui.EndCombo()
+	}
+	if uiScaleState.fontsBuiltScale != fontScale(platform) {
+		imgui.Text("Text will be rendered at full resolution at the new size when vice is restarted.")
+	}
+}
@@ -6,6 +6,8 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	_ "embed"
 	"encoding/gob"
 	"encoding/json"
@@ -56,6 +58,38 @@ func decompressZstd(s string) string {
 	return string(b)
 }
 
+///////////////////////////////////////////////////////////////////////////
+// files
+
+// writeFileAtomically writes a file using the provided function. The
+// output is first written to a temporary file that is then renamed to the
+// given filename, so that if vice crashes or the system loses power
+// partway through, the original file is left intact rather than being
+// truncated.
+func writeFileAtomically(fn string, write func(w io.Writer) error) error {
+	tmp := fn + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
 ///////////////////////////////////////////////////////////////////////////
 // text
 
@@ -626,19 +660,166 @@ func (r *RingBuffer[V]) Get(i int) V {
 ///////////////////////////////////////////////////////////////////////////
 // Networking miscellany
 
+// FetchURL returns the contents of the given URL, using the default
+// FetchOptions.
 func FetchURL(url string) ([]byte, error) {
-	response, err := http.Get(url)
+	return FetchURLWithOptions(url, FetchOptions{})
+}
+
+// FetchOptions specifies how FetchURLWithOptions should go about fetching
+// a URL. The zero value gives a single attempt with a 30 second timeout.
+type FetchOptions struct {
+	// Context, if non-nil, allows the caller to cancel the fetch,
+	// including any pending retries.
+	Context context.Context
+	// Timeout for each attempt; zero gives the default of 30 seconds.
+	Timeout time.Duration
+	// Retries gives the number of times to retry after a network error or
+	// a server error (5xx or 429) response. The delay before the first
+	// retry is given by Backoff (default 1 second) and it doubles for
+	// each subsequent one.
+	Retries int
+	Backoff time.Duration
+	// CacheFile, if set, gives a filename (see fetchCacheFilePath) where
+	// the response is stored along with its ETag and Last-Modified
+	// headers. Subsequent requests are conditional and the cached
+	// contents are returned if the server reports they are unchanged.
+	CacheFile string
+	// MaxSize, if non-zero, gives the maximum number of bytes that will be
+	// read; larger responses cause an error to be returned.
+	MaxSize int64
+}
+
+// fetchCacheMetadata is stored alongside cached responses.
+type fetchCacheMetadata struct {
+	ETag         string
+	LastModified string
+}
+
+// errFetchRetryable wraps errors for which FetchURLWithOptions should try
+// again.
+type errFetchRetryable struct{ error }
+
+// fetchCacheFilePath returns the path to use for caching the fetched
+// resource with the given name.
+func fetchCacheFilePath(name string) string {
+	dir := path.Join(configDirectory(), "cache")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		lg.Errorf("%s: unable to make cache directory: %v", dir, err)
+	}
+	return path.Join(dir, name)
+}
+
+// FetchURLWithOptions returns the contents of the given URL, fetching it
+// as specified by the provided options.
+func FetchURLWithOptions(url string, opts FetchOptions) ([]byte, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	backoff := Select(opts.Backoff != 0, opts.Backoff, time.Second)
+
+	for attempt := 0; ; attempt++ {
+		b, err := fetchURLOnce(ctx, url, opts)
+		var rerr errFetchRetryable
+		if !errors.As(err, &rerr) {
+			// Success or an error that retrying won't help with.
+			return b, err
+		} else if attempt == opts.Retries || ctx.Err() != nil {
+			return nil, rerr.error
+		}
+
+		lg.Warnf("%s: %v; retrying in %s", url, rerr.error, backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+func fetchURLOnce(ctx context.Context, url string, opts FetchOptions) ([]byte, error) {
+	timeout := Select(opts.Timeout != 0, opts.Timeout, 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
 
-	var text []byte
-	if text, err = io.ReadAll(response.Body); err != nil {
-		return nil, err
+	var meta fetchCacheMetadata
+	var cached []byte
+	if opts.CacheFile != "" {
+		if mb, err := os.ReadFile(opts.CacheFile + ".meta"); err == nil && json.Unmarshal(mb, &meta) == nil {
+			if cached, err = os.ReadFile(opts.CacheFile); err == nil {
+				if meta.ETag != "" {
+					req.Header.Set("If-None-Match", meta.ETag)
+				}
+				if meta.LastModified != "" {
+					req.Header.Set("If-Modified-Since", meta.LastModified)
+				}
+			}
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errFetchRetryable{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		lg.Debugf("%s: using cached %s", url, opts.CacheFile)
+		return cached, nil
+	} else if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, errFetchRetryable{fmt.Errorf("server returned %s", resp.Status)}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	var r io.Reader = resp.Body
+	if opts.MaxSize > 0 {
+		if resp.ContentLength > opts.MaxSize {
+			return nil, fmt.Errorf("response size %d exceeds limit of %d bytes", resp.ContentLength, opts.MaxSize)
+		}
+		// Read one more byte than the limit to detect overflow.
+		r = io.LimitReader(r, opts.MaxSize+1)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errFetchRetryable{err}
+	}
+	if opts.MaxSize > 0 && int64(len(b)) > opts.MaxSize {
+		return nil, fmt.Errorf("response exceeds limit of %d bytes", opts.MaxSize)
+	}
+
+	if opts.CacheFile != "" {
+		meta = fetchCacheMetadata{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		if meta.ETag != "" || meta.LastModified != "" {
+			// Write the contents first so that the metadata never refers
+			// to a stale file.
+			os.Remove(opts.CacheFile + ".meta")
+			err := writeFileAtomically(opts.CacheFile, func(w io.Writer) error {
+				_, err := w.Write(b)
+				return err
+			})
+			if err == nil {
+				err = writeFileAtomically(opts.CacheFile+".meta", func(w io.Writer) error {
+					return json.NewEncoder(w).Encode(meta)
+				})
+			}
+			if err != nil {
+				lg.Warnf("%s: unable to cache: %v", opts.CacheFile, err)
+			}
+		}
 	}
 
-	return text, nil
+	return b, nil
 }
 
 ///////////////////////////////////////////////////////////////////////////
@@ -701,6 +882,14 @@ func GenerateImagePyramid(img image.Image) []image.Image {
 ///////////////////////////////////////////////////////////////////////////
 // JSON
 
+// writeJSON writes the value as indented JSON; it's used for the
+// machine-readable output of the command-line tools.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
 // Unmarshal the bytes into the given type but go through some efforts to
 // return useful error messages when the JSON is invalid...
 func UnmarshalJSON[T any](b []byte, out *T) error {
@@ -1135,7 +1324,9 @@ func getResourcesFS() fs.StatFS {
 	}
 
 	dir := filepath.Dir(path)
-	if runtime.GOOS == "darwin" {
+	if *resourcesDir != "" {
+		dir = *resourcesDir
+	} else if runtime.GOOS == "darwin" {
 		dir = filepath.Clean(filepath.Join(dir, "..", "Resources"))
 	} else {
 		dir = filepath.Join(dir, "resources")
@@ -1251,30 +1442,161 @@ func (a *AtomicBool) UnmarshalJSON(data []byte) error {
 ///////////////////////////////////////////////////////////////////////////
 // LoggingMutex
 
+// LoggingMutex is a sync.Mutex that logs its use and helps diagnose
+// deadlocks: it warns about inconsistent lock ordering across
+// goroutines, which can lead to deadlock, and if a goroutine waits for
+// too long to acquire one, a watchdog logs all of the held mutexes, which
+// goroutines are waiting for which mutexes, and all goroutines' stacks.
+// LoggingRWMutex provides the same for a sync.RWMutex.
+
+const (
+	mutexLongWait        = time.Second
+	mutexDeadlockTimeout = 10 * time.Second
+	// Limit on the number of lock order relationships that are tracked,
+	// so that sims coming and going don't lead to unbounded growth.
+	maxLockOrderEdges = 4096
+)
+
+// trackedMutex is implemented by both LoggingMutex and LoggingRWMutex.
+type trackedMutex interface {
+	slog.LogValuer
+	// heldBy reports whether the given goroutine holds the mutex; it must
+	// be called with heldMutexesMutex held.
+	heldBy(gid int64) bool
+}
+
+// The following are all protected by heldMutexesMutex.
 var heldMutexesMutex sync.Mutex
-var heldMutexes map[*LoggingMutex]interface{} = make(map[*LoggingMutex]interface{})
+var heldMutexes map[trackedMutex]interface{} = make(map[trackedMutex]interface{})
+
+// mutexWaiters records which mutex each goroutine that is trying to
+// acquire one is waiting for, indexed by goroutine ID.
+var mutexWaiters = make(map[int64]trackedMutex)
+
+// lockOrder records the pairs of mutexes that have been acquired while
+// holding another along with where the second one was acquired.
+type lockOrderEdge struct {
+	held, acquired trackedMutex
+}
+
+var lockOrder = make(map[lockOrderEdge][]StackFrame)
+
+// goroutineID returns the ID of the calling goroutine. The Go runtime
+// deliberately doesn't provide this, so it's parsed from the first line
+// of the goroutine's stack trace, "goroutine 123 [running]:".
+func goroutineID() int64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i != -1 {
+		if id, err := strconv.ParseInt(string(b[:i]), 10, 64); err == nil {
+			return id
+		}
+	}
+	return 0
+}
+
+// checkLockOrder is called before the given goroutine tries to acquire
+// the mutex; it records the order in which it is being acquired with
+// respect to mutexes the goroutine already holds and warns if the
+// opposite order has been seen before. It also registers the goroutine
+// as waiting for the mutex.
+func checkLockOrder(lg *Logger, m trackedMutex, gid int64) {
+	heldMutexesMutex.Lock()
+	defer heldMutexesMutex.Unlock()
+
+	for h := range heldMutexes {
+		if !h.heldBy(gid) {
+			continue
+		}
+		if h == m {
+			lg.Error("goroutine is acquiring a mutex it already holds; deadlock", slog.Any("mutex", m))
+			continue
+		}
+
+		edge := lockOrderEdge{held: h, acquired: m}
+		if _, ok := lockOrder[edge]; ok {
+			continue
+		}
+		if len(lockOrder) >= maxLockOrderEdges {
+			clear(lockOrder)
+		}
+		lockOrder[edge] = Callstack()
+
+		if stack, ok := lockOrder[lockOrderEdge{held: m, acquired: h}]; ok {
+			lg.Warn("inconsistent mutex lock order; potential deadlock", slog.Any("held", h),
+				slog.Any("acquiring", m), slog.Any("reverse_order_acq_stack", stack))
+		}
+	}
+
+	mutexWaiters[gid] = m
+}
+
+// lockWithWatchdog calls the provided function to acquire the mutex; if
+// that takes longer than mutexDeadlockTimeout, diagnostics are logged.
+func lockWithWatchdog(lg *Logger, m trackedMutex, gid int64, lock func()) time.Duration {
+	tryTime := time.Now()
+	lg.Debug("attempting to acquire mutex", slog.Any("mutex", m))
+
+	checkLockOrder(lg, m, gid)
+
+	watchdog := time.AfterFunc(mutexDeadlockTimeout, func() { logMutexWaitDiagnostics(lg, m, tryTime) })
+	lock()
+	watchdog.Stop()
+
+	return time.Since(tryTime)
+}
+
+// logMutexWaitDiagnostics is called by the watchdog if a goroutine has
+// been waiting for the mutex for longer than mutexDeadlockTimeout.
+func logMutexWaitDiagnostics(lg *Logger, m trackedMutex, tryTime time.Time) {
+	heldMutexesMutex.Lock()
+	var held []any
+	for h := range heldMutexes {
+		held = append(held, slog.Any(fmt.Sprintf("%p", h), h))
+		if rw, ok := h.(*LoggingRWMutex); ok {
+			held = append(held, slog.Group(fmt.Sprintf("%p_readers", h), rw.readerAttrs()...))
+		}
+	}
+	var waiting []any
+	for gid, w := range mutexWaiters {
+		waiting = append(waiting, slog.String(fmt.Sprintf("goroutine %d", gid), fmt.Sprintf("%p", w)))
+	}
+	heldMutexesMutex.Unlock()
+
+	buf := make([]byte, 1<<20)
+	stacks := string(buf[:runtime.Stack(buf, true)])
+
+	lg.Error("possible deadlock: still waiting to acquire mutex", slog.String("id", fmt.Sprintf("%p", m)),
+		slog.Any("mutex", m), slog.Duration("wait", time.Since(tryTime)),
+		slog.Group("held_mutexes", held...), slog.Group("waiting_goroutines", waiting...),
+		slog.String("goroutines", stacks))
+}
 
 type LoggingMutex struct {
 	sync.Mutex
 	acq      time.Time
 	acqStack []StackFrame
+	holder   int64 // goroutine ID
 }
 
-func (l *LoggingMutex) Lock(lg *Logger) {
-	tryTime := time.Now()
-	lg.Debug("attempting to acquire mutex", slog.Any("mutex", l))
+func (l *LoggingMutex) heldBy(gid int64) bool {
+	return l.holder == gid
+}
 
-	l.Mutex.Lock()
+func (l *LoggingMutex) Lock(lg *Logger) {
+	gid := goroutineID()
+	w := lockWithWatchdog(lg, l, gid, l.Mutex.Lock)
 
 	heldMutexesMutex.Lock()
+	delete(mutexWaiters, gid)
 	heldMutexes[l] = nil
+	l.holder = gid
 	heldMutexesMutex.Unlock()
 
 	l.acq = time.Now()
 	l.acqStack = Callstack()
-	w := l.acq.Sub(tryTime)
 	lg.Debug("acquired mutex", slog.Any("mutex", l), slog.Duration("wait", w))
-	if w > time.Second {
+	if w > mutexLongWait {
 		lg.Warn("long wait to acquire mutex", slog.Any("mutex", l), slog.Duration("wait", w))
 	}
 }
@@ -1292,13 +1614,14 @@ func (l *LoggingMutex) Unlock(lg *Logger) {
 	}
 	delete(heldMutexes, l)
 
-	if d := time.Since(l.acq); d > time.Second {
+	if d := time.Since(l.acq); d > mutexLongWait {
 		lg.Warn("mutex held for over 1 second", slog.Any("mutex", l), slog.Duration("held", d),
 			slog.Any("held_mutexes", heldMutexes))
 	}
 
 	l.acq = time.Time{}
 	l.acqStack = nil
+	l.holder = 0
 	l.Mutex.Unlock()
 
 	lg.Debug("released mutex", slog.Any("mutex", l))
@@ -1306,11 +1629,142 @@ func (l *LoggingMutex) Unlock(lg *Logger) {
 
 func (l *LoggingMutex) LogValue() slog.Value {
 	return slog.GroupValue(
+		slog.Int64("holder", l.holder),
 		slog.Time("acq", l.acq),
 		slog.Duration("held", time.Since(l.acq)),
 		slog.Any("acq_stack", l.acqStack))
 }
 
+// LoggingRWMutex is a sync.RWMutex with the same instrumentation as
+// LoggingMutex. It is intended for state that is read much more often
+// than it is modified, so that readers don't contend with each other.
+type LoggingRWMutex struct {
+	sync.RWMutex
+	// Writer
+	acq      time.Time
+	acqStack []StackFrame
+	holder   int64
+	// Readers, indexed by goroutine ID. Protected by heldMutexesMutex;
+	// nreaders is also available for logging without it.
+	readers  map[int64]mutexReader
+	nreaders atomic.Int32
+}
+
+type mutexReader struct {
+	acq      time.Time
+	acqStack []StackFrame
+}
+
+func (l *LoggingRWMutex) heldBy(gid int64) bool {
+	_, reading := l.readers[gid]
+	return l.holder == gid || reading
+}
+
+func (l *LoggingRWMutex) Lock(lg *Logger) {
+	gid := goroutineID()
+	w := lockWithWatchdog(lg, l, gid, l.RWMutex.Lock)
+
+	heldMutexesMutex.Lock()
+	delete(mutexWaiters, gid)
+	heldMutexes[l] = nil
+	l.holder = gid
+	heldMutexesMutex.Unlock()
+
+	l.acq = time.Now()
+	l.acqStack = Callstack()
+	lg.Debug("acquired mutex", slog.Any("mutex", l), slog.Duration("wait", w))
+	if w > mutexLongWait {
+		lg.Warn("long wait to acquire mutex", slog.Any("mutex", l), slog.Duration("wait", w))
+	}
+}
+
+func (l *LoggingRWMutex) Unlock(lg *Logger) {
+	heldMutexesMutex.Lock()
+	// As in LoggingMutex.Unlock, hold this until we return.
+	defer heldMutexesMutex.Unlock()
+
+	if l.holder == 0 {
+		lg.Error("mutex not held", slog.Any("held_mutexes", heldMutexes))
+	}
+	delete(heldMutexes, l)
+
+	if d := time.Since(l.acq); d > mutexLongWait {
+		lg.Warn("mutex held for over 1 second", slog.Any("mutex", l), slog.Duration("held", d),
+			slog.Any("held_mutexes", heldMutexes))
+	}
+
+	l.acq = time.Time{}
+	l.acqStack = nil
+	l.holder = 0
+	l.RWMutex.Unlock()
+
+	lg.Debug("released mutex", slog.Any("mutex", l))
+}
+
+func (l *LoggingRWMutex) RLock(lg *Logger) {
+	gid := goroutineID()
+	w := lockWithWatchdog(lg, l, gid, l.RWMutex.RLock)
+
+	heldMutexesMutex.Lock()
+	delete(mutexWaiters, gid)
+	heldMutexes[l] = nil
+	if l.readers == nil {
+		l.readers = make(map[int64]mutexReader)
+	}
+	l.readers[gid] = mutexReader{acq: time.Now(), acqStack: Callstack()}
+	l.nreaders.Store(int32(len(l.readers)))
+	heldMutexesMutex.Unlock()
+
+	lg.Debug("acquired mutex for reading", slog.Any("mutex", l), slog.Duration("wait", w))
+	if w > mutexLongWait {
+		lg.Warn("long wait to acquire mutex for reading", slog.Any("mutex", l), slog.Duration("wait", w))
+	}
+}
+
+func (l *LoggingRWMutex) RUnlock(lg *Logger) {
+	gid := goroutineID()
+
+	heldMutexesMutex.Lock()
+	defer heldMutexesMutex.Unlock()
+
+	if r, ok := l.readers[gid]; !ok {
+		lg.Error("mutex not held for reading", slog.Any("mutex", l), slog.Any("held_mutexes", heldMutexes))
+	} else if d := time.Since(r.acq); d > mutexLongWait {
+		lg.Warn("mutex held for reading for over 1 second", slog.Any("mutex", l), slog.Duration("held", d),
+			slog.Any("held_mutexes", heldMutexes))
+	}
+	delete(l.readers, gid)
+	l.nreaders.Store(int32(len(l.readers)))
+	if len(l.readers) == 0 {
+		delete(heldMutexes, l)
+	}
+
+	l.RWMutex.RUnlock()
+
+	lg.Debug("released mutex for reading", slog.Any("mutex", l))
+}
+
+func (l *LoggingRWMutex) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("holder", l.holder),
+		slog.Time("acq", l.acq),
+		slog.Duration("held", time.Since(l.acq)),
+		slog.Any("acq_stack", l.acqStack),
+		slog.Int("readers", int(l.nreaders.Load())))
+}
+
+// readerAttrs returns logging attributes for each of the current readers;
+// it must be called with heldMutexesMutex held.
+func (l *LoggingRWMutex) readerAttrs() []any {
+	var attrs []any
+	for _, gid := range SortedMapKeys(l.readers) {
+		r := l.readers[gid]
+		attrs = append(attrs, slog.Group(fmt.Sprintf("goroutine %d", gid),
+			slog.Duration("held", time.Since(r.acq)), slog.Any("acq_stack", r.acqStack)))
+	}
+	return attrs
+}
+
 ///////////////////////////////////////////////////////////////////////////
 
 // discordStatus encapsulates the user's current vice activity; if the user is not
@@ -1319,6 +1773,30 @@ type discordStatus struct {
 	totalDepartures, totalArrivals int
 	callsign                       string
 	start                          time.Time
+
+	tracon   string
+	scenario string
+	// The following are only set for multi-controller sims.
+	simName             string
+	server              string
+	players, maxPlayers int
+}
+
+// discordFacilityArtwork maps from TRACON names to the keys of the
+// corresponding artwork uploaded to the vice Discord application; TRACONs
+// without their own artwork use the default tower image.
+var discordFacilityArtwork = map[string]string{
+	"A80": "a80",
+	"A90": "a90",
+	"C90": "c90",
+	"D10": "d10",
+	"F11": "f11",
+	"L30": "l30",
+	"N90": "n90",
+	"NCT": "nct",
+	"P50": "p50",
+	"PCT": "pct",
+	"SCT": "sct",
 }
 
 // discord collects various variables related to the state of the discord
@@ -1339,10 +1817,7 @@ func SetDiscordStatus(s discordStatus) {
 	discord.mu.Lock()
 	defer discord.mu.Unlock()
 
-	if s.totalDepartures != discord.status.totalDepartures ||
-		s.totalArrivals != discord.status.totalArrivals ||
-		s.callsign != discord.status.callsign ||
-		s.start != discord.status.start {
+	if s != discord.status {
 		discord.statusChanged = true
 	}
 
@@ -1395,6 +1870,32 @@ func updateDiscordStatus() {
 				activity.State = strconv.Itoa(status.totalDepartures) + " departures" + " | " +
 					strconv.Itoa(status.totalArrivals) + " arrivals"
 				activity.Details = "Controlling " + status.callsign
+				if status.scenario != "" {
+					activity.Details += " (" + status.scenario + ")"
+				}
+
+				if art, ok := discordFacilityArtwork[status.tracon]; ok {
+					activity.LargeImage = art
+					activity.LargeText = status.tracon
+					activity.SmallImage = "towerlarge"
+					activity.SmallText = "Vice ATC"
+				}
+
+				if status.simName != "" {
+					activity.Party = &discord_client.Party{
+						ID:         status.server + "/" + status.simName,
+						Players:    status.players,
+						MaxPlayers: status.maxPlayers,
+					}
+					if status.players < status.maxPlayers {
+						// Discord doesn't allow both a join secret and
+						// buttons; a button works without Discord needing
+						// to launch vice itself.
+						activity.Buttons = []*discord_client.Button{
+							&discord_client.Button{Label: "Join", Url: viceJoinLandingURL(status.server, status.simName)},
+						}
+					}
+				}
 			}
 
 			if err := discord_client.SetActivity(activity); err != nil {
@@ -5,6 +5,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"slices"
 	"testing"
 	"time"
@@ -202,3 +210,113 @@ func TestReduceMap(t *testing.T) {
 		t.Errorf("Expected %d from ReduceMap; got %d", 5+5+6+6+1, length)
 	}
 }
+
+func TestWriteFileAtomically(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "file.txt")
+
+	write := func(s string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := fmt.Fprint(w, s)
+			return err
+		}
+	}
+	check := func(expected string) {
+		t.Helper()
+		if b, err := os.ReadFile(fn); err != nil {
+			t.Errorf("%v", err)
+		} else if string(b) != expected {
+			t.Errorf("got %q, expected %q", string(b), expected)
+		}
+		if _, err := os.Stat(fn + ".tmp"); !os.IsNotExist(err) {
+			t.Errorf("temporary file left behind")
+		}
+	}
+
+	if err := writeFileAtomically(fn, write("hello")); err != nil {
+		t.Fatalf("%v", err)
+	}
+	check("hello")
+
+	if err := writeFileAtomically(fn, write("goodbye")); err != nil {
+		t.Fatalf("%v", err)
+	}
+	check("goodbye")
+
+	// A failed write should leave the original file alone.
+	err := writeFileAtomically(fn, func(w io.Writer) error {
+		fmt.Fprint(w, "partial")
+		return errors.New("failed")
+	})
+	if err == nil {
+		t.Errorf("expected error from failed write")
+	}
+	check("goodbye")
+}
+
+func TestFetchURLWithOptions(t *testing.T) {
+	var requests, failures int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/flaky":
+			if failures < 2 {
+				failures++
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "ok")
+		case "/cached":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprint(w, "cached contents")
+		case "/large":
+			fmt.Fprint(w, "0123456789")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	if b, err := FetchURLWithOptions(srv.URL+"/flaky", FetchOptions{Retries: 3, Backoff: time.Millisecond}); err != nil {
+		t.Errorf("flaky: %v", err)
+	} else if string(b) != "ok" || requests != 3 {
+		t.Errorf("flaky: got %q after %d requests, expected \"ok\" after 3", string(b), requests)
+	}
+
+	requests, failures = 0, 0
+	if _, err := FetchURLWithOptions(srv.URL+"/flaky", FetchOptions{Retries: 1, Backoff: time.Millisecond}); err == nil {
+		t.Errorf("flaky: expected error after running out of retries")
+	}
+
+	requests = 0
+	if _, err := FetchURLWithOptions(srv.URL+"/missing", FetchOptions{Retries: 3}); err == nil {
+		t.Errorf("missing: expected error")
+	} else if requests != 1 {
+		t.Errorf("missing: %d requests, expected no retries", requests)
+	}
+
+	cache := filepath.Join(t.TempDir(), "cached")
+	for i := 0; i < 2; i++ {
+		if b, err := FetchURLWithOptions(srv.URL+"/cached", FetchOptions{CacheFile: cache}); err != nil {
+			t.Errorf("cached: %v", err)
+		} else if string(b) != "cached contents" {
+			t.Errorf("cached: got %q", string(b))
+		}
+	}
+
+	if _, err := FetchURLWithOptions(srv.URL+"/large", FetchOptions{MaxSize: 5}); err == nil {
+		t.Errorf("large: expected error for response exceeding MaxSize")
+	}
+	if _, err := FetchURLWithOptions(srv.URL+"/large", FetchOptions{MaxSize: 10}); err != nil {
+		t.Errorf("large: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FetchURLWithOptions(srv.URL+"/flaky", FetchOptions{Context: ctx, Retries: 3}); err == nil {
+		t.Errorf("expected error with canceled context")
+	}
+}
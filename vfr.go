@@ -0,0 +1,331 @@
+// vfr.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// VFR traffic: the sim can launch VFR aircraft that fly between airports
+// around the TRACON, squawking 1200. A scenario group may give the
+// geometry of the class B, C, and D airspace in its area, e.g.:
+//
+//	"class_airspace": [
+//	    { "class": "B", "airport": "KEWR",
+//	      "volumes": [ { "type": "circle", "center": "KEWR", "radius": 5,
+//	                     "floor": 0, "ceiling": 7000 }, ... ] }
+//	]
+//
+// VFR aircraft plan their routes to stay out of class B airspace, either
+// by picking a cruising altitude that keeps them under or over its
+// shelves or by going around it. Before entering class C airspace, they
+// call the controller to establish communications; class D airspace is
+// assumed to be handled by the (virtual) tower. The airspace is also
+// available as STARS system maps.
+//
+// A few VFR aircraft don't have transponders; they're only seen by the
+// radars' primary returns and are shown on the scope as uncorrelated
+// primary targets.
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type ClassAirspace struct {
+	Class   string           `json:"class"`
+	Airport string           `json:"airport"`
+	Volumes []AirspaceVolume `json:"volumes"`
+}
+
+func (ca *ClassAirspace) PostDeserialize(e *ErrorLogger) {
+	e.Push("class " + ca.Class + " " + ca.Airport)
+	defer e.Pop()
+
+	if !slices.Contains([]string{"B", "C", "D"}, ca.Class) {
+		e.ErrorString("\"class\" must be \"B\", \"C\", or \"D\"")
+	}
+	if ca.Airport == "" {
+		e.ErrorString("\"airport\" must be specified")
+	} else if _, ok := database.Airports[ca.Airport]; !ok {
+		e.ErrorString("%s: airport unknown", ca.Airport)
+	}
+	if len(ca.Volumes) == 0 {
+		e.ErrorString("no \"volumes\" specified")
+	}
+	for _, vol := range ca.Volumes {
+		if vol.Ceiling <= vol.Floor {
+			e.ErrorString("volume \"ceiling\" %d must be above its \"floor\" %d", vol.Ceiling, vol.Floor)
+		}
+	}
+}
+
+func (ca *ClassAirspace) Inside(p Point2LL, alt int) bool {
+	return slices.ContainsFunc(ca.Volumes, func(v AirspaceVolume) bool { return v.Inside(p, alt) })
+}
+
+// bounds returns a circle, in nm coordinates, that encloses all of the
+// airspace's volumes.
+func (ca *ClassAirspace) bounds(nmPerLongitude float32) (center [2]float32, radius float32) {
+	var pts [][2]float32
+	for _, vol := range ca.Volumes {
+		switch vol.Type {
+		case AirspaceVolumePolygon:
+			for _, v := range vol.Vertices {
+				pts = append(pts, ll2nm(v, nmPerLongitude))
+			}
+		case AirspaceVolumeCircle:
+			c := ll2nm(vol.Center, nmPerLongitude)
+			pts = append(pts, add2f(c, [2]float32{vol.Radius, 0}), add2f(c, [2]float32{-vol.Radius, 0}),
+				add2f(c, [2]float32{0, vol.Radius}), add2f(c, [2]float32{0, -vol.Radius}))
+		}
+	}
+
+	center = Extent2DFromPoints(pts).Center()
+	for _, p := range pts {
+		radius = max(radius, distance2f(p, center))
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Launching VFR aircraft
+
+var vfrAircraftTypes = []string{"C150", "C172", "C182", "P28A", "BE36", "C337"}
+
+// Fraction of VFR aircraft that don't have a transponder and so only
+// appear as primary targets.
+const vfrPrimaryOnlyFraction = 0.1
+
+// vfrPathClear returns true if flying along the given path at the given
+// altitude doesn't enter any class B airspace.
+func (w *World) vfrPathClear(path []Point2LL, alt int) bool {
+	for i := 0; i+1 < len(path); i++ {
+		p0, p1 := ll2nm(path[i], w.NmPerLongitude), ll2nm(path[i+1], w.NmPerLongitude)
+		n := int(distance2f(p0, p1)*2) + 1 // check every half mile
+		for j := 0; j <= n; j++ {
+			p := nm2ll(lerp2f(float32(j)/float32(n), p0, p1), w.NmPerLongitude)
+			for _, ca := range w.ClassAirspace {
+				if ca.Class == "B" && ca.Inside(p, alt) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// planVFRRoute returns a path and cruising altitude for a VFR flight
+// between the two airports that avoids class B airspace.
+func (w *World) planVFRRoute(from, to FAAAirport) ([]Point2LL, int, bool) {
+	// Hemispheric rule above 3,000 AGL; below that, anything goes.
+	hdg := headingp2ll(from.Location, to.Location, w.NmPerLongitude, w.MagneticVariation)
+	var cruise []int
+	for alt := Select(hdg < 180, 3500, 4500); alt <= 9500; alt += 2000 {
+		cruise = append(cruise, alt)
+	}
+	rand.Shuffle(len(cruise), func(i, j int) { cruise[i], cruise[j] = cruise[j], cruise[i] })
+	cruise = append(cruise, 2500, 1500) // try to sneak under the shelves
+
+	minAlt := max(from.Elevation, to.Elevation) + 1000
+	if t := LookupTerrain(w.TRACON); t != nil {
+		// Stay 1,000' above the terrain and obstacles along the way.
+		p0, p1 := ll2nm(from.Location, w.NmPerLongitude), ll2nm(to.Location, w.NmPerLongitude)
+		n := int(distance2f(p0, p1)) + 1
+		for i := 0; i <= n; i++ {
+			p := nm2ll(lerp2f(float32(i)/float32(n), p0, p1), w.NmPerLongitude)
+			minAlt = max(minAlt, int(t.HighestPoint(p, 1, w.NmPerLongitude))+1000)
+		}
+	}
+	cruise = FilterSlice(cruise, func(alt int) bool { return alt >= minAlt })
+	if len(cruise) == 0 {
+		return nil, 0, false
+	}
+
+	path := []Point2LL{from.Location, to.Location}
+	for _, alt := range cruise {
+		if w.vfrPathClear(path, alt) {
+			return path, alt, true
+		}
+	}
+
+	// Go around the class B airspace that's in the way.
+	alt := cruise[0]
+	p0, p1 := ll2nm(from.Location, w.NmPerLongitude), ll2nm(to.Location, w.NmPerLongitude)
+	var detours [][2]float32
+	for _, ca := range w.ClassAirspace {
+		if ca.Class != "B" || w.vfrPathClear(path, alt) {
+			continue
+		}
+
+		center, radius := ca.bounds(w.NmPerLongitude)
+		dir := sub2f(ClosestPointOnLine([2][2]float32{p0, p1}, center), center)
+		if length2f(dir) < 0.1 {
+			// The route goes right through the middle; pick a side.
+			dir = [2]float32{p1[1] - p0[1], p0[0] - p1[0]}
+		}
+		detours = append(detours, add2f(center, scale2f(normalize2f(dir), radius+3)))
+	}
+	// Visit the detour points in order of distance from the start.
+	sort.Slice(detours, func(i, j int) bool {
+		return distance2f(detours[i], p0) < distance2f(detours[j], p0)
+	})
+	path = []Point2LL{from.Location}
+	for _, d := range detours {
+		path = append(path, nm2ll(d, w.NmPerLongitude))
+	}
+	path = append(path, to.Location)
+
+	return path, alt, w.vfrPathClear(path, alt)
+}
+
+func (w *World) sampleVFRCallsign() string {
+	for {
+		callsign := "N" + strconv.Itoa(1+rand.Intn(9))
+		for _, ch := range SampleSlice([]string{"##@@", "###@", "####", "##@", "#@@"}) {
+			if ch == '#' {
+				callsign += strconv.Itoa(rand.Intn(10))
+			} else {
+				callsign += string(rune('A' + rand.Intn(26)))
+			}
+		}
+		if _, ok := w.Aircraft[callsign]; !ok {
+			return callsign
+		}
+	}
+}
+
+// CreateVFRAircraft returns a new VFR aircraft flying between two airports
+// in the TRACON's area.
+func (w *World) CreateVFRAircraft() (*Aircraft, error) {
+	// Airports that are in range of the scope and aren't under class B
+	// airspace.
+	var airports []FAAAirport
+	for _, ap := range database.Airports {
+		if len(ap.Runways) == 0 || nmdistance2ll(ap.Location, w.Center) > 0.8*w.Range {
+			continue
+		}
+		if !slices.ContainsFunc(w.ClassAirspace, func(ca ClassAirspace) bool {
+			return ca.Class == "B" && ca.Inside(ap.Location, ap.Elevation+1)
+		}) {
+			airports = append(airports, ap)
+		}
+	}
+	if len(airports) < 2 {
+		return nil, fmt.Errorf("not enough airports for VFR flights")
+	}
+
+	for i := 0; i < 10; i++ {
+		from, to := SampleSlice(airports), SampleSlice(airports)
+		if nmdistance2ll(from.Location, to.Location) < 15 {
+			continue
+		}
+		path, alt, ok := w.planVFRRoute(from, to)
+		if !ok {
+			continue
+		}
+
+		acType := SampleSlice(vfrAircraftTypes)
+		perf, ok := database.AircraftPerformance[acType]
+		if !ok {
+			return nil, ErrUnknownAircraftType
+		}
+
+		ac := &Aircraft{
+			Callsign:       w.sampleVFRCallsign(),
+			AssignedSquawk: Squawk(0o1200),
+			Squawk:         Squawk(0o1200),
+			Mode:           Select(rand.Float32() < vfrPrimaryOnlyFraction, TransponderMode(Standby), Charlie),
+		}
+		ac.FlightPlan = NewFlightPlan(VFR, acType, from.Id, to.Id)
+		ac.FlightPlan.Altitude = alt
+
+		var wps []Waypoint
+		for i, p := range path {
+			wps = append(wps, Waypoint{Fix: Select(i == 0, from.Id, Select(i == len(path)-1, to.Id, "_VFR")),
+				Location: p})
+		}
+		wps[len(wps)-1].Delete = true
+
+		nav := makeNav(w, *ac.FlightPlan, perf, wps)
+		if nav == nil {
+			return nil, fmt.Errorf("error initializing Nav")
+		}
+		a := float32(alt)
+		nav.Altitude.Assigned = &a
+		nav.FlightState.Altitude = float32(from.Elevation + 1000)
+		nav.FlightState.IAS = perf.Speed.CruiseTAS
+		nav.FlightState.GS = nav.FlightState.IAS
+		ac.Nav = *nav
+
+		return ac, nil
+	}
+
+	return nil, fmt.Errorf("unable to find a VFR route")
+}
+
+func (lc *LaunchConfig) DrawVFRUI() (changed bool) {
+	imgui.Separator()
+	imgui.Text("VFR Traffic")
+	r := int32(lc.VFRRate)
+	changed = imgui.InputIntV("VFR aircraft per hour", &r, 0, 60, 0)
+	lc.VFRRate = max(0, int(r))
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Sim integration
+
+// checkVFRAirspaceEntry is called once a second with s.mu held; if a VFR
+// aircraft is about to enter class C airspace, the pilot calls the
+// controller.
+func (s *Sim) checkVFRAirspaceEntry(ac *Aircraft) {
+	if ac.FlightPlan == nil || ac.FlightPlan.Rules != VFR || ac.ControllingController != "" ||
+		len(ac.Nav.Waypoints) == 0 {
+		return
+	}
+	if _, ok := s.World.Aircraft[ac.Callsign]; !ok {
+		// It was just deleted at its destination.
+		return
+	}
+
+	// Look a few miles ahead along the route.
+	nmPerLongitude := s.World.NmPerLongitude
+	p := ll2nm(ac.Position(), nmPerLongitude)
+	dir := normalize2f(sub2f(ll2nm(ac.Nav.Waypoints[0].Location, nmPerLongitude), p))
+	alt := int(ac.Altitude())
+	if a := ac.Nav.Altitude.Assigned; a != nil {
+		alt = max(alt, int(*a))
+	}
+
+	for _, ca := range s.World.ClassAirspace {
+		if ca.Class != "C" || ca.Inside(ac.Position(), alt) {
+			continue
+		}
+		for d := float32(0.5); d <= 4; d += 0.5 {
+			if !ca.Inside(nm2ll(add2f(p, scale2f(dir, d)), nmPerLongitude), alt) {
+				continue
+			}
+
+			ctrl := s.ResolveController(s.World.PrimaryController)
+			ac.ControllingController = ctrl
+
+			apt := database.Airports[ca.Airport]
+			hdg := headingp2ll(apt.Location, ac.Position(), nmPerLongitude, s.World.MagneticVariation)
+			msg := fmt.Sprintf("%s, %d miles %s of %s at %d, VFR to %s, request to enter the class C airspace",
+				ac.FlightPlan.BaseType(), int(nmdistance2ll(apt.Location, ac.Position())+0.5),
+				compass(hdg), ca.Airport, int(ac.Altitude()+50)/100*100, ac.FlightPlan.ArrivalAirport)
+			PostRadioEvents(ac.Callsign, []RadioTransmission{RadioTransmission{
+				Controller: ctrl,
+				Message:    msg,
+				Type:       RadioTransmissionContact,
+			}}, s)
+			s.lg.Info("VFR requesting class C entry", slog.String("callsign", ac.Callsign),
+				slog.String("airport", ca.Airport), slog.String("controller", ctrl))
+			return
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// videoexport.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Recording of sessions to MP4 or WebM video. Frames are captured from the
+// framebuffer using the screenshot machinery and piped to ffmpeg, which
+// must be installed separately, for scaling and encoding. A speed greater
+// than one gives a time-lapse: frames are then captured less often than
+// they are played back.
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+type VideoConfig struct {
+	Format string // "mp4" or "webm"
+	// Output resolution; if zero, the size of the window (or scope) is
+	// used.
+	Width, Height int
+	FPS           int
+	Speed         float32
+	ScopeOnly     bool
+	// Path to the ffmpeg executable; if empty, it is found using $PATH.
+	FFmpegPath string
+}
+
+var videoResolutions = [][2]int{{0, 0}, {1280, 720}, {1920, 1080}, {2560, 1440}, {3840, 2160}}
+
+func (vc *VideoConfig) setDefaults() {
+	if vc.Format == "" {
+		vc.Format = "mp4"
+	}
+	if vc.FPS == 0 {
+		vc.FPS = 30
+	}
+	if vc.Speed == 0 {
+		vc.Speed = 1
+	}
+}
+
+// ffmpegArgs returns the command-line arguments for encoding raw RGBA
+// frames of the given size read from stdin to the given file.
+func (vc *VideoConfig) ffmpegArgs(width, height int, filename string) []string {
+	args := []string{"-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pixel_format", "rgba", "-video_size", fmt.Sprintf("%dx%d", width, height),
+		"-framerate", strconv.Itoa(vc.FPS), "-i", "-"}
+
+	// The encoders require even dimensions.
+	if vc.Width > 0 && vc.Height > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2",
+			vc.Width, vc.Height, vc.Width, vc.Height))
+	} else {
+		args = append(args, "-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2")
+	}
+
+	if vc.Format == "webm" {
+		args = append(args, "-c:v", "libvpx-vp9", "-b:v", "0", "-crf", "32")
+	} else {
+		args = append(args, "-c:v", "libx264", "-preset", "fast", "-crf", "20")
+	}
+	return append(args, "-pix_fmt", "yuv420p", filename)
+}
+
+type VideoRecorder struct {
+	config   VideoConfig
+	filename string
+	start    time.Time
+
+	// Set when the first frame arrives and ffmpeg is launched.
+	size   [2]int
+	frames chan *image.RGBA
+	done   chan error
+	err    error
+
+	lastCapture time.Time
+	nFrames     int
+	dropped     int
+}
+
+var videoRecorder *VideoRecorder
+
+func StartVideoRecording(config VideoConfig) (*VideoRecorder, error) {
+	config.setDefaults()
+
+	ffmpeg := config.FFmpegPath
+	if ffmpeg == "" {
+		var err error
+		if ffmpeg, err = exec.LookPath("ffmpeg"); err != nil {
+			return nil, fmt.Errorf("ffmpeg not found; it must be installed to record video: %w", err)
+		}
+	}
+	config.FFmpegPath = ffmpeg
+
+	dir := path.Join(configDirectory(), "videos")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	filename := path.Join(dir, "vice-"+time.Now().Format("20060102-150405")+"."+config.Format)
+
+	lg.Infof("%s: started recording video", filename)
+	return &VideoRecorder{
+		config:   config,
+		filename: filename,
+		start:    time.Now(),
+	}, nil
+}
+
+// Update requests a frame if it's time for the next one; it must be
+// called each frame from the main thread.
+func (vr *VideoRecorder) Update() {
+	interval := time.Duration(float32(time.Second) * vr.config.Speed / float32(vr.config.FPS))
+	if time.Since(vr.lastCapture) < interval {
+		return
+	}
+	vr.lastCapture = time.Now()
+	RequestScreenshot(vr.config.ScopeOnly, vr.addFrame)
+}
+
+func (vr *VideoRecorder) addFrame(img *image.RGBA) {
+	if vr.err != nil {
+		return
+	}
+	if vr.frames == nil {
+		vr.size = [2]int{img.Bounds().Dx(), img.Bounds().Dy()}
+		if vr.err = vr.launchEncoder(); vr.err != nil {
+			lg.Errorf("%s: unable to start ffmpeg: %v", vr.filename, vr.err)
+			return
+		}
+	}
+
+	if img.Bounds().Dx() != vr.size[0] || img.Bounds().Dy() != vr.size[1] {
+		// The window was resized; the video's size can't change.
+		img = resizeNearest(img, vr.size[0], vr.size[1])
+	}
+
+	select {
+	case vr.frames <- img:
+		vr.nFrames++
+	default:
+		// Don't stall the UI if ffmpeg can't keep up.
+		vr.dropped++
+	}
+}
+
+func (vr *VideoRecorder) launchEncoder() error {
+	cmd := exec.Command(vr.config.FFmpegPath, vr.config.ffmpegArgs(vr.size[0], vr.size[1], vr.filename)...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stderr := &limitedBuffer{max: 4096}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	vr.frames = make(chan *image.RGBA, 16)
+	vr.done = make(chan error, 1)
+	go func() {
+		var err error
+		for img := range vr.frames {
+			if err == nil {
+				_, err = stdin.Write(img.Pix)
+			}
+		}
+		stdin.Close()
+		if werr := cmd.Wait(); err == nil && werr != nil {
+			err = fmt.Errorf("%w: %s", werr, stderr.String())
+		}
+		vr.done <- err
+	}()
+	return nil
+}
+
+// Stop finishes the recording; it returns once the video file has been
+// written.
+func (vr *VideoRecorder) Stop() (string, error) {
+	if vr.err != nil {
+		return "", vr.err
+	}
+	if vr.frames == nil {
+		return "", fmt.Errorf("no frames were recorded")
+	}
+	close(vr.frames)
+	if err := <-vr.done; err != nil {
+		return "", err
+	}
+	lg.Infof("%s: recorded %d frames (%d dropped)", vr.filename, vr.nFrames, vr.dropped)
+	return vr.filename, nil
+}
+
+// resizeNearest returns a copy of the image resized to the given
+// resolution using nearest-neighbor sampling.
+func resizeNearest(img *image.RGBA, width, height int) *image.RGBA {
+	r := image.NewRGBA(image.Rect(0, 0, width, height))
+	b := img.Bounds()
+	for y := 0; y < height; y++ {
+		sy := b.Min.Y + y*b.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := b.Min.X + x*b.Dx()/width
+			si := img.PixOffset(sx, sy)
+			copy(r.Pix[r.PixOffset(x, y):r.PixOffset(x, y)+4], img.Pix[si:si+4])
+		}
+	}
+	return r
+}
+
+// limitedBuffer is an io.Writer that keeps the first max bytes written
+// to it.
+type limitedBuffer struct {
+	buf []byte
+	max int
+}
+
+var _ io.Writer = (*limitedBuffer)(nil)
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if n := lb.max - len(lb.buf); n > 0 {
+		lb.buf = append(lb.buf, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (lb *limitedBuffer) String() string { return string(lb.buf) }
+
+///////////////////////////////////////////////////////////////////////////
+// Video UI
+
+func uiUpdateVideoRecording() {
+	if videoRecorder != nil {
+		videoRecorder.Update()
+	}
+}
+
+func uiDrawVideoMenu(eventStream *EventStream) {
+	vc := &globalConfig.Video
+	vc.setDefaults()
+
+	if imgui.BeginMenu(FontAwesomeIconVideo) {
+		if videoRecorder != nil {
+			elapsed := time.Since(videoRecorder.start).Round(time.Second)
+			imgui.Text(fmt.Sprintf("Recording %s (%d frames)", elapsed, videoRecorder.nFrames))
+			if imgui.MenuItem("Stop recording") {
+				filename, err := videoRecorder.Stop()
+				videoRecorder = nil
+				if err != nil {
+					ShowErrorDialog("Unable to record video: %v", err)
+				} else {
+					eventStream.Post(Event{Type: StatusMessageEvent, Message: "Saved video " + filename})
+				}
+			}
+		} else {
+			if imgui.BeginComboV("Format", vc.Format, 0) {
+				for _, f := range []string{"mp4", "webm"} {
+					if imgui.SelectableV(f, f == vc.Format, 0, imgui.Vec2{}) {
+						vc.Format = f
+					}
+				}
+				imgui.EndCombo()
+			}
+
+			resName := func(r [2]int) string {
+				return Select(r[0] == 0, "Window size", fmt.Sprintf("%dx%d", r[0], r[1]))
+			}
+			if imgui.BeginComboV("Resolution", resName([2]int{vc.Width, vc.Height}), 0) {
+				for _, r := range videoResolutions {
+					if imgui.SelectableV(resName(r), r[0] == vc.Width && r[1] == vc.Height, 0, imgui.Vec2{}) {
+						vc.Width, vc.Height = r[0], r[1]
+					}
+				}
+				imgui.EndCombo()
+			}
+
+			fps := int32(vc.FPS)
+			if imgui.SliderInt("Frames per second", &fps, 10, 60) {
+				vc.FPS = int(fps)
+			}
+			imgui.SliderFloatV("Speed", &vc.Speed, 1, 16, "%.0fx", 0)
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("Values greater than one give a time-lapse video")
+			}
+			imgui.Checkbox("Record only the radar scope", &vc.ScopeOnly)
+			imgui.InputTextV("ffmpeg path", &vc.FFmpegPath, 0, nil)
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("Leave empty to find ffmpeg automatically")
+			}
+
+			imgui.Separator()
+			if imgui.MenuItem("Start recording") {
+				var err error
+				if videoRecorder, err = StartVideoRecording(*vc); err != nil {
+					ShowErrorDialog("Unable to record video: %v", err)
+				}
+			}
+		}
+		imgui.EndMenu()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip(Select(videoRecorder != nil, "Recording video", "Record video"))
+	}
+}
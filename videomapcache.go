@@ -0,0 +1,126 @@
+// videomapcache.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Video maps are loaded on demand: at startup, the video map files are
+// only scanned to find which maps they contain so that the scenarios can
+// be validated. The geometry for a scenario's maps is loaded when a sim
+// is started using it and is then kept in a cache so that subsequent
+// sims using the same maps don't need to load them again. The cache is
+// limited to a memory budget (given by the -videomapcache command-line
+// option), evicting the least recently used maps when it's exceeded. (The
+// maps used by running sims are still referenced by their Worlds, so
+// eviction only affects whether they're loaded again for the next sim.)
+
+import (
+	"container/list"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sync"
+)
+
+type VideoMapCache struct {
+	mu      sync.Mutex
+	files   map[string]fs.FS // which filesystem each video map file is in
+	entries map[videoMapKey]*list.Element
+	lru     *list.List // of *videoMapCacheEntry; most recently used at the front
+	size    int64      // bytes
+}
+
+type videoMapKey struct {
+	file, name string
+}
+
+type videoMapCacheEntry struct {
+	key  videoMapKey
+	cb   CommandBuffer
+	size int64
+}
+
+var videoMaps = &VideoMapCache{
+	files:   make(map[string]fs.FS),
+	entries: make(map[videoMapKey]*list.Element),
+	lru:     list.New(),
+}
+
+// Register records the filesystem that the given video map file is in.
+func (c *VideoMapCache) Register(file string, filesystem fs.FS) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[file] = filesystem
+}
+
+// Flush discards all of the cached maps so that they are loaded from
+// disk again the next time that they're used.
+func (c *VideoMapCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.lru.Init()
+	c.size = 0
+}
+
+func commandBufferSize(cb CommandBuffer) int64 {
+	sz := int64(4 * len(cb.Buf))
+	for _, c := range cb.called {
+		sz += commandBufferSize(c)
+	}
+	return sz
+}
+
+// Get returns the command buffers for the given maps in the given video
+// map file, loading any that aren't already in the cache.
+func (c *VideoMapCache) Get(file string, names []string) (map[string]CommandBuffer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make(map[string]CommandBuffer)
+	missing := make(map[string]interface{})
+	for _, name := range names {
+		if el, ok := c.entries[videoMapKey{file: file, name: name}]; ok {
+			c.lru.MoveToFront(el)
+			result[name] = el.Value.(*videoMapCacheEntry).cb
+		} else {
+			missing[name] = nil
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	filesystem, ok := c.files[file]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown video map file", file)
+	}
+	lvm := loadVideoMaps(filesystem, file, missing)
+	if lvm.err != nil {
+		return nil, lvm.err
+	}
+
+	for name := range missing {
+		cb, ok := lvm.commandBufs[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: video map \"%s\" not found", file, name)
+		}
+		result[name] = cb
+
+		// Count each map as at least one byte so that empty maps are
+		// still evicted when the budget is zero.
+		e := &videoMapCacheEntry{key: videoMapKey{file: file, name: name}, cb: cb, size: max(commandBufferSize(cb), 1)}
+		c.entries[e.key] = c.lru.PushFront(e)
+		c.size += e.size
+	}
+
+	budget := int64(*videoMapCacheSize) << 20
+	for c.size > budget && c.lru.Len() > 0 {
+		e := c.lru.Remove(c.lru.Back()).(*videoMapCacheEntry)
+		delete(c.entries, e.key)
+		c.size -= e.size
+		lg.Debug("evicted video map", slog.String("file", e.key.file), slog.String("name", e.key.name))
+	}
+
+	return result, nil
+}
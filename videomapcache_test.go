@@ -0,0 +1,69 @@
+// videomapcache_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"container/list"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestVideoMapCache(t *testing.T) {
+	fsys := fstest.MapFS{
+		"maps.json": &fstest.MapFile{Data: []byte(`{
+    "A": ["N040.00.00.000,W075.00.00.000", "N040.10.00.000,W075.00.00.000"],
+    "B": ["N041.00.00.000,W075.00.00.000", "N041.10.00.000,W075.00.00.000"],
+    "C": null
+}`)},
+	}
+
+	c := &VideoMapCache{
+		files:   make(map[string]fs.FS),
+		entries: make(map[videoMapKey]*list.Element),
+		lru:     list.New(),
+	}
+	c.Register("maps.json", fsys)
+
+	cbs, err := c.Get("maps.json", []string{"A", "B"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cbs) != 2 || len(cbs["A"].Buf) == 0 || len(cbs["B"].Buf) == 0 {
+		t.Errorf("expected command buffers for maps A and B, got %+v", cbs)
+	}
+	if c.lru.Len() != 2 || c.size == 0 {
+		t.Errorf("expected 2 cached maps, got %d (%d bytes)", c.lru.Len(), c.size)
+	}
+
+	if _, err := c.Get("maps.json", []string{"A", "missing"}); err == nil {
+		t.Errorf("expected error for missing map")
+	}
+	if _, err := c.Get("other.json", []string{"A"}); err == nil {
+		t.Errorf("expected error for unregistered file")
+	}
+
+	// After a flush, the maps should be loaded again.
+	c.Flush()
+	if c.lru.Len() != 0 || c.size != 0 || len(c.entries) != 0 {
+		t.Errorf("expected empty cache after Flush, got %d maps (%d bytes)", c.lru.Len(), c.size)
+	}
+	if cbs, err := c.Get("maps.json", []string{"A"}); err != nil || len(cbs["A"].Buf) == 0 {
+		t.Errorf("unable to get map A after Flush: %v", err)
+	}
+
+	// With no memory budget, everything should be evicted but the maps
+	// should still be returned.
+	defer func(sz int) { *videoMapCacheSize = sz }(*videoMapCacheSize)
+	*videoMapCacheSize = 0
+	if cbs, err := c.Get("maps.json", []string{"B", "C"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(cbs["B"].Buf) == 0 {
+		t.Errorf("expected command buffer for map B")
+	}
+	if c.lru.Len() != 0 || c.size != 0 || len(c.entries) != 0 {
+		t.Errorf("expected empty cache, got %d maps (%d bytes)", c.lru.Len(), c.size)
+	}
+}
@@ -0,0 +1,504 @@
+// videomaptool.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Command-line tools for maintaining video map files, run via "vice maps
+// <command> ...". Video map files are JSON objects that map each video
+// map's name to an array of positions, where successive pairs of positions
+// give the endpoints of the map's line segments. Files with a ".zst"
+// extension are zstd-compressed.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const videoMapToolUsage = `usage: vice [-json] maps <command> [arguments]
+
+Commands:
+  list <file>...                 list the maps in the given files
+  convert <in> <out>             convert between formats; the output format is
+                                 given by its extension: .json, .zst (compressed
+                                 JSON), or .geojson (export only)
+  merge <out> <in>...            merge the maps from multiple files
+  relabel <in> <out> <old=new>...
+                                 rename maps; an argument of the form @file
+                                 reads old=new pairs from a file, one per line
+  diff <old> <new>               summarize the geometry added to and removed
+                                 from each map between two versions of a file
+
+With -json, the output of list and diff is printed as JSON.
+`
+
+// runVideoMapTool runs the given "vice maps" command and returns the exit
+// code for the process.
+func runVideoMapTool(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, videoMapToolUsage)
+		return 1
+	}
+
+	var err error
+	cmd, args := args[0], args[1:]
+	switch {
+	case cmd == "list" && len(args) > 0:
+		err = videoMapToolList(os.Stdout, args, *jsonOutput)
+
+	case cmd == "convert" && len(args) == 2:
+		var maps map[string][]Point2LL
+		if maps, err = readVideoMapFile(args[0]); err == nil {
+			err = writeVideoMapFile(args[1], maps)
+		}
+
+	case cmd == "merge" && len(args) >= 2:
+		var files []map[string][]Point2LL
+		for _, fn := range args[1:] {
+			var maps map[string][]Point2LL
+			if maps, err = readVideoMapFile(fn); err != nil {
+				break
+			}
+			files = append(files, maps)
+		}
+		if err == nil {
+			var merged map[string][]Point2LL
+			if merged, err = mergeVideoMaps(files, args[1:]); err == nil {
+				err = writeVideoMapFile(args[0], merged)
+			}
+		}
+
+	case cmd == "relabel" && len(args) >= 3:
+		var renames map[string]string
+		var maps map[string][]Point2LL
+		if renames, err = parseVideoMapRenames(args[2:]); err != nil {
+			break
+		}
+		if maps, err = readVideoMapFile(args[0]); err != nil {
+			break
+		}
+		if maps, err = relabelVideoMaps(maps, renames); err == nil {
+			err = writeVideoMapFile(args[1], maps)
+		}
+
+	case cmd == "diff" && len(args) == 2:
+		var a, b map[string][]Point2LL
+		if a, err = readVideoMapFile(args[0]); err != nil {
+			break
+		}
+		if b, err = readVideoMapFile(args[1]); err != nil {
+			break
+		}
+		err = diffVideoMaps(a, b).Print(os.Stdout, *jsonOutput)
+
+	default:
+		fmt.Fprint(os.Stderr, videoMapToolUsage)
+		return 1
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vice maps %s: %v\n", cmd, err)
+		return 1
+	}
+	return 0
+}
+
+func readVideoMapFile(fn string) (map[string][]Point2LL, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(filepath.Ext(fn)) == ".zst" {
+		if b, err = decoder.DecodeAll(b, nil); err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+
+	var maps map[string][]Point2LL
+	if err := UnmarshalJSON(b, &maps); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	for name, pts := range maps {
+		if len(pts)%2 != 0 {
+			return nil, fmt.Errorf("%s: %s: odd number of positions (%d); expected pairs of segment endpoints",
+				fn, name, len(pts))
+		}
+	}
+	return maps, nil
+}
+
+// writeVideoMapFile writes the maps to the given file, using the format
+// indicated by its extension.
+func writeVideoMapFile(fn string, maps map[string][]Point2LL) error {
+	return writeFileAtomically(fn, func(w io.Writer) error {
+		switch strings.ToLower(filepath.Ext(fn)) {
+		case ".json":
+			return encodeVideoMapJSON(w, maps)
+
+		case ".zst":
+			zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+			if err != nil {
+				return err
+			}
+			if err := encodeVideoMapJSON(zw, maps); err != nil {
+				zw.Close()
+				return err
+			}
+			return zw.Close()
+
+		case ".geojson":
+			return encodeVideoMapGeoJSON(w, maps)
+
+		default:
+			return fmt.Errorf("%s: unknown video map format; expected .json, .zst, or .geojson", fn)
+		}
+	})
+}
+
+// encodeVideoMapJSON writes the maps as JSON, sorted by name and with one
+// map per line so that the files are reasonable to diff and review.
+func encodeVideoMapJSON(w io.Writer, maps map[string][]Point2LL) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("{\n")
+	for i, name := range SortedMapKeys(maps) {
+		n, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		pts := maps[name]
+		if pts == nil {
+			pts = []Point2LL{}
+		}
+		p, err := json.Marshal(pts)
+		if err != nil {
+			return err
+		}
+
+		bw.WriteString("    ")
+		bw.Write(n)
+		bw.WriteString(": ")
+		bw.Write(p)
+		if i < len(maps)-1 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n")
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// encodeVideoMapGeoJSON writes the maps as a GeoJSON FeatureCollection
+// with a MultiLineString feature for each map so that they can be viewed
+// in GIS tools.
+func encodeVideoMapGeoJSON(w io.Writer, maps map[string][]Point2LL) error {
+	type geometry struct {
+		Type        string         `json:"type"`
+		Coordinates [][][2]float32 `json:"coordinates"`
+	}
+	type feature struct {
+		Type       string            `json:"type"`
+		Properties map[string]string `json:"properties"`
+		Geometry   geometry          `json:"geometry"`
+	}
+	fc := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{Type: "FeatureCollection", Features: []feature{}}
+
+	for _, name := range SortedMapKeys(maps) {
+		f := feature{
+			Type:       "Feature",
+			Properties: map[string]string{"name": name},
+			Geometry:   geometry{Type: "MultiLineString", Coordinates: [][][2]float32{}},
+		}
+		pts := maps[name]
+		for i := 0; i+1 < len(pts); i += 2 {
+			// Point2LL and GeoJSON both store longitude first.
+			f.Geometry.Coordinates = append(f.Geometry.Coordinates, [][2]float32{pts[i], pts[i+1]})
+		}
+		fc.Features = append(fc.Features, f)
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(fc)
+}
+
+// mergeVideoMaps merges the maps from multiple files; names gives the
+// filename for each for error messages. It is an error for more than one
+// file to have a map with the same name unless the maps are identical.
+func mergeVideoMaps(files []map[string][]Point2LL, names []string) (map[string][]Point2LL, error) {
+	merged := make(map[string][]Point2LL)
+	from := make(map[string]string)
+	var conflicts []string
+	for i, maps := range files {
+		for _, name := range SortedMapKeys(maps) {
+			if prev, ok := merged[name]; ok {
+				if d := diffVideoMapSegments(prev, maps[name]); len(d.Added) > 0 || len(d.Removed) > 0 {
+					conflicts = append(conflicts, fmt.Sprintf("%s: defined differently in %s and %s",
+						name, from[name], names[i]))
+				}
+				continue
+			}
+			merged[name] = maps[name]
+			from[name] = names[i]
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("conflicting maps:\n%s", strings.Join(conflicts, "\n"))
+	}
+	return merged, nil
+}
+
+// parseVideoMapRenames parses old=new arguments; @file arguments give
+// files with one old=new pair per line.
+func parseVideoMapRenames(args []string) (map[string]string, error) {
+	renames := make(map[string]string)
+	add := func(s string) error {
+		old, newName, ok := strings.Cut(s, "=")
+		if !ok || old == "" || newName == "" {
+			return fmt.Errorf("%s: expected old=new", s)
+		}
+		if _, ok := renames[old]; ok {
+			return fmt.Errorf("%s: renamed more than once", old)
+		}
+		renames[old] = newName
+		return nil
+	}
+
+	for _, arg := range args {
+		if fn, ok := strings.CutPrefix(arg, "@"); ok {
+			b, err := os.ReadFile(fn)
+			if err != nil {
+				return nil, err
+			}
+			for _, line := range strings.Split(string(b), "\n") {
+				if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+					if err := add(line); err != nil {
+						return nil, fmt.Errorf("%s: %w", fn, err)
+					}
+				}
+			}
+		} else if err := add(arg); err != nil {
+			return nil, err
+		}
+	}
+	return renames, nil
+}
+
+// relabelVideoMaps returns a copy of the maps with the given maps renamed.
+func relabelVideoMaps(maps map[string][]Point2LL, renames map[string]string) (map[string][]Point2LL, error) {
+	result := make(map[string][]Point2LL)
+	for name, pts := range maps {
+		if _, ok := renames[name]; !ok {
+			result[name] = pts
+		}
+	}
+
+	for _, old := range SortedMapKeys(renames) {
+		newName := renames[old]
+		pts, ok := maps[old]
+		if !ok {
+			return nil, fmt.Errorf("%s: no such map", old)
+		}
+		if _, ok := result[newName]; ok {
+			return nil, fmt.Errorf("%s: can't rename %s; a map with that name already exists", newName, old)
+		}
+		result[newName] = pts
+	}
+	return result, nil
+}
+
+// VideoMapGeometry summarizes a map's geometry (or some part of it) for
+// the command-line tools' output.
+type VideoMapGeometry struct {
+	Name     string  `json:"name,omitempty"`
+	Segments int     `json:"segments"`
+	LengthNM float32 `json:"length_nm"`
+}
+
+func makeVideoMapGeometry(name string, segs []VideoMapSegment) VideoMapGeometry {
+	return VideoMapGeometry{Name: name, Segments: len(segs), LengthNM: videoMapSegmentsLength(segs)}
+}
+
+func (g VideoMapGeometry) String() string {
+	return fmt.Sprintf("%d segments, %.1f nm", g.Segments, g.LengthNM)
+}
+
+func videoMapToolList(w io.Writer, files []string, asJSON bool) error {
+	type fileMaps struct {
+		File string             `json:"file"`
+		Maps []VideoMapGeometry `json:"maps"`
+	}
+	var list []fileMaps
+	for _, fn := range files {
+		maps, err := readVideoMapFile(fn)
+		if err != nil {
+			return err
+		}
+
+		fm := fileMaps{File: fn, Maps: []VideoMapGeometry{}}
+		for _, name := range SortedMapKeys(maps) {
+			fm.Maps = append(fm.Maps, makeVideoMapGeometry(name, videoMapSegments(maps[name])))
+		}
+		list = append(list, fm)
+	}
+
+	if asJSON {
+		return writeJSON(w, list)
+	}
+	for _, fm := range list {
+		fmt.Fprintf(w, "%s: %d maps\n", fm.File, len(fm.Maps))
+		for _, g := range fm.Maps {
+			fmt.Fprintf(w, "    %-40s %6d segments %9.1f nm\n", g.Name, g.Segments, g.LengthNM)
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Diffs
+
+type VideoMapSegment [2]Point2LL
+
+func videoMapSegments(pts []Point2LL) []VideoMapSegment {
+	var segs []VideoMapSegment
+	for i := 0; i+1 < len(pts); i += 2 {
+		segs = append(segs, VideoMapSegment{pts[i], pts[i+1]})
+	}
+	return segs
+}
+
+func videoMapSegmentsLength(segs []VideoMapSegment) float32 {
+	var l float32
+	for _, s := range segs {
+		l += nmdistance2ll(s[0], s[1])
+	}
+	return l
+}
+
+// key returns a string that identifies the segment; positions are compared
+// at the precision they're stored at in video map files and the direction
+// of the segment doesn't matter.
+func (s VideoMapSegment) key() string {
+	a, b := s[0].DMSString(), s[1].DMSString()
+	if a > b {
+		a, b = b, a
+	}
+	return a + "/" + b
+}
+
+type VideoMapSegmentDiff struct {
+	Added, Removed []VideoMapSegment
+}
+
+// diffVideoMapSegments compares the segments of two versions of a map.
+// The order of the segments doesn't matter but duplicates do.
+func diffVideoMapSegments(a, b []Point2LL) VideoMapSegmentDiff {
+	count := make(map[string]int)
+	for _, s := range videoMapSegments(a) {
+		count[s.key()]++
+	}
+
+	var d VideoMapSegmentDiff
+	for _, s := range videoMapSegments(b) {
+		if k := s.key(); count[k] > 0 {
+			count[k]--
+		} else {
+			d.Added = append(d.Added, s)
+		}
+	}
+	for _, s := range videoMapSegments(a) {
+		if k := s.key(); count[k] > 0 {
+			count[k]--
+			d.Removed = append(d.Removed, s)
+		}
+	}
+	return d
+}
+
+type VideoMapDiff struct {
+	Added, Removed map[string][]VideoMapSegment
+	Changed        map[string]VideoMapSegmentDiff
+	Unchanged      int
+}
+
+func diffVideoMaps(a, b map[string][]Point2LL) VideoMapDiff {
+	d := VideoMapDiff{
+		Added:   make(map[string][]VideoMapSegment),
+		Removed: make(map[string][]VideoMapSegment),
+		Changed: make(map[string]VideoMapSegmentDiff),
+	}
+	for name, pts := range a {
+		if _, ok := b[name]; !ok {
+			d.Removed[name] = videoMapSegments(pts)
+		}
+	}
+	for name, pts := range b {
+		if apts, ok := a[name]; !ok {
+			d.Added[name] = videoMapSegments(pts)
+		} else if sd := diffVideoMapSegments(apts, pts); len(sd.Added) > 0 || len(sd.Removed) > 0 {
+			d.Changed[name] = sd
+		} else {
+			d.Unchanged++
+		}
+	}
+	return d
+}
+
+// Print writes a summary of the differences, either as text or as JSON.
+func (d VideoMapDiff) Print(w io.Writer, asJSON bool) error {
+	type changed struct {
+		Name    string           `json:"name"`
+		Added   VideoMapGeometry `json:"added"`
+		Removed VideoMapGeometry `json:"removed"`
+	}
+	summary := struct {
+		Removed   []VideoMapGeometry `json:"removed"`
+		Added     []VideoMapGeometry `json:"added"`
+		Changed   []changed          `json:"changed"`
+		Unchanged int                `json:"unchanged"`
+	}{
+		Removed:   []VideoMapGeometry{},
+		Added:     []VideoMapGeometry{},
+		Changed:   []changed{},
+		Unchanged: d.Unchanged,
+	}
+	for _, name := range SortedMapKeys(d.Removed) {
+		summary.Removed = append(summary.Removed, makeVideoMapGeometry(name, d.Removed[name]))
+	}
+	for _, name := range SortedMapKeys(d.Added) {
+		summary.Added = append(summary.Added, makeVideoMapGeometry(name, d.Added[name]))
+	}
+	for _, name := range SortedMapKeys(d.Changed) {
+		summary.Changed = append(summary.Changed, changed{
+			Name:    name,
+			Added:   makeVideoMapGeometry("", d.Changed[name].Added),
+			Removed: makeVideoMapGeometry("", d.Changed[name].Removed),
+		})
+	}
+
+	if asJSON {
+		return writeJSON(w, summary)
+	}
+
+	for _, g := range summary.Removed {
+		fmt.Fprintf(w, "- %s (%s)\n", g.Name, g)
+	}
+	for _, g := range summary.Added {
+		fmt.Fprintf(w, "+ %s (%s)\n", g.Name, g)
+	}
+	for _, c := range summary.Changed {
+		fmt.Fprintf(w, "~ %s: added %s; removed %s\n", c.Name, c.Added, c.Removed)
+	}
+	fmt.Fprintf(w, "%d maps removed, %d added, %d changed, %d unchanged\n", len(d.Removed), len(d.Added),
+		len(d.Changed), d.Unchanged)
+	return nil
+}
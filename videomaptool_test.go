@@ -0,0 +1,119 @@
+// videomaptool_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestVideoMapTool(t *testing.T) {
+	p := func(s string) Point2LL {
+		pt, err := ParseLatLong([]byte(s))
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		return pt
+	}
+	a, b, c := p("N040.00.00.000,W075.00.00.000"), p("N040.10.00.000,W075.00.00.000"), p("N040.10.00.000,W075.10.00.000")
+
+	maps := map[string][]Point2LL{
+		"RWYS":   {a, b},
+		"COAST":  {a, b, b, c},
+		"EMPTY":  nil,
+		"UNUSED": {c, a},
+	}
+
+	// Round-trip through each of the writable formats.
+	dir := t.TempDir()
+	for _, ext := range []string{".json", ".zst"} {
+		fn := filepath.Join(dir, "maps"+ext)
+		if err := writeVideoMapFile(fn, maps); err != nil {
+			t.Fatalf("%s: %v", fn, err)
+		}
+		rt, err := readVideoMapFile(fn)
+		if err != nil {
+			t.Fatalf("%s: %v", fn, err)
+		}
+		if d := diffVideoMaps(maps, rt); len(d.Added)+len(d.Removed)+len(d.Changed) > 0 || d.Unchanged != len(maps) {
+			t.Errorf("%s: maps changed after round trip: %+v", fn, d)
+		}
+	}
+	if err := writeVideoMapFile(filepath.Join(dir, "maps.geojson"), maps); err != nil {
+		t.Errorf("geojson: %v", err)
+	}
+	if err := writeVideoMapFile(filepath.Join(dir, "maps.txt"), maps); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+
+	// Diffs ignore segment order and direction.
+	next := map[string][]Point2LL{
+		"RWYS":  {b, a},
+		"COAST": {b, c},
+		"EMPTY": nil,
+		"NEW":   {a, c},
+	}
+	d := diffVideoMaps(maps, next)
+	if len(d.Removed) != 1 || d.Removed["UNUSED"] == nil {
+		t.Errorf("expected UNUSED to be removed: %+v", d.Removed)
+	}
+	if len(d.Added) != 1 || len(d.Added["NEW"]) != 1 {
+		t.Errorf("expected NEW to be added: %+v", d.Added)
+	}
+	if sd, ok := d.Changed["COAST"]; len(d.Changed) != 1 || !ok || len(sd.Added) != 0 || len(sd.Removed) != 1 {
+		t.Errorf("expected one segment removed from COAST: %+v", d.Changed)
+	}
+	if d.Unchanged != 2 {
+		t.Errorf("expected 2 unchanged maps, got %d", d.Unchanged)
+	}
+
+	var buf bytes.Buffer
+	if err := d.Print(&buf, true); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	var summary struct {
+		Removed, Added []VideoMapGeometry
+		Changed        []struct{ Name string }
+		Unchanged      int
+	}
+	if err := json.Unmarshal(buf.Bytes(), &summary); err != nil {
+		t.Errorf("invalid JSON diff: %v", err)
+	} else if len(summary.Removed) != 1 || len(summary.Added) != 1 || len(summary.Changed) != 1 ||
+		summary.Changed[0].Name != "COAST" || summary.Unchanged != 2 {
+		t.Errorf("unexpected JSON diff: %s", buf.String())
+	}
+
+	// Merging
+	if m, err := mergeVideoMaps([]map[string][]Point2LL{maps, {"RWYS": {b, a}, "NEW": {a, c}}},
+		[]string{"a", "b"}); err != nil {
+		t.Errorf("unexpected merge error: %v", err)
+	} else if len(m) != 5 {
+		t.Errorf("expected 5 merged maps, got %d", len(m))
+	}
+	if _, err := mergeVideoMaps([]map[string][]Point2LL{maps, next}, []string{"a", "b"}); err == nil {
+		t.Errorf("expected merge conflict for COAST")
+	}
+
+	// Relabeling
+	if m, err := relabelVideoMaps(maps, map[string]string{"RWYS": "RUNWAYS", "COAST": "RWYS"}); err != nil {
+		t.Errorf("unexpected relabel error: %v", err)
+	} else if len(m["RUNWAYS"]) != 2 || len(m["RWYS"]) != 4 || len(m) != 4 {
+		t.Errorf("unexpected relabel result: %+v", m)
+	}
+	if _, err := relabelVideoMaps(maps, map[string]string{"RWYS": "COAST"}); err == nil {
+		t.Errorf("expected error relabeling to an existing name")
+	}
+	if _, err := relabelVideoMaps(maps, map[string]string{"MISSING": "FOO"}); err == nil {
+		t.Errorf("expected error relabeling a missing map")
+	}
+	if r, err := parseVideoMapRenames([]string{"A=B", "C=D"}); err != nil || len(r) != 2 || r["A"] != "B" {
+		t.Errorf("unexpected renames %+v, err %v", r, err)
+	}
+	if _, err := parseVideoMapRenames([]string{"A"}); err == nil {
+		t.Errorf("expected error for rename without =")
+	}
+}
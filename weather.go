@@ -0,0 +1,214 @@
+// weather.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// Ceiling and visibility: each airport's conditions come from its METAR,
+// either the real one or one generated from the scenario's "ceiling"
+// (feet above the airport; zero for no ceiling) and "visibility"
+// (statute miles) values. They determine which approaches may be flown:
+// charted visual approaches require basic VFR conditions, and instrument
+// approaches require at least their minimums, which are approximated by
+// standard values for the approach type rather than taken from the
+// charts. Pilots refuse approaches when the weather is below minimums,
+// and when it's close to them, they occasionally don't see the runway in
+// time and go missed.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ceiling used when there are no broken or overcast layers.
+const unlimitedCeiling = 99999
+
+const (
+	// Conditions within these margins of an approach's minimums may
+	// lead to a missed approach.
+	lowIMCCeilingMargin     = 200 // feet
+	lowIMCVisibilityFactor  = 2
+	lowIMCMissedProbability = 0.2
+)
+
+type WeatherConditions struct {
+	Ceiling    int     // feet above the airport
+	Visibility float32 // statute miles
+}
+
+func (wc WeatherConditions) String() string {
+	ceil := "none"
+	if wc.Ceiling != unlimitedCeiling {
+		ceil = strconv.Itoa(wc.Ceiling)
+	}
+	return fmt.Sprintf("ceiling %s, visibility %gSM", ceil, wc.Visibility)
+}
+
+var approachMinimums = map[ApproachType]WeatherConditions{
+	ILSApproach:           {Ceiling: 200, Visibility: 0.5},
+	RNAVApproach:          {Ceiling: 300, Visibility: 1},
+	ChartedVisualApproach: {Ceiling: 1000, Visibility: 3},
+}
+
+// Below returns true if the conditions are worse than the given minimums.
+func (wc WeatherConditions) Below(mins WeatherConditions) bool {
+	return wc.Ceiling < mins.Ceiling || wc.Visibility < mins.Visibility
+}
+
+// ParseMETARConditions returns the ceiling and visibility given the
+// weather part of a METAR, e.g., "1 1/2SM BR BKN008 OVC015 12/11".
+func ParseMETARConditions(s string) (WeatherConditions, error) {
+	wc := WeatherConditions{Ceiling: unlimitedCeiling, Visibility: 10}
+
+	parseFraction := func(f string) (float32, error) {
+		if num, denom, ok := strings.Cut(f, "/"); ok {
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, err
+			}
+			d, err := strconv.Atoi(denom)
+			if err != nil || d == 0 {
+				return 0, fmt.Errorf("%s: invalid fraction", f)
+			}
+			return float32(n) / float32(d), nil
+		}
+		v, err := strconv.Atoi(f)
+		return float32(v), err
+	}
+
+	fields := strings.Fields(s)
+	for i, f := range fields {
+		if vis, ok := strings.CutSuffix(f, "SM"); ok {
+			// "M1/4SM" is less than 1/4 mile and "P6SM" is more than 6.
+			vis = strings.TrimLeft(vis, "MP")
+			v, err := parseFraction(vis)
+			if err != nil {
+				return wc, fmt.Errorf("%s: invalid visibility", f)
+			}
+			if i > 0 && strings.Contains(vis, "/") {
+				// Whole miles may be given separately: "1 1/2SM".
+				if whole, err := strconv.Atoi(fields[i-1]); err == nil {
+					v += float32(whole)
+				}
+			}
+			wc.Visibility = v
+		} else if len(f) >= 5 && (strings.HasPrefix(f, "BKN") || strings.HasPrefix(f, "OVC")) ||
+			len(f) >= 4 && strings.HasPrefix(f, "VV") {
+			layer := strings.TrimLeft(f, "BKNOVC")
+			if len(layer) < 3 {
+				continue
+			}
+			if h, err := strconv.Atoi(layer[:3]); err == nil {
+				wc.Ceiling = min(wc.Ceiling, 100*h)
+			}
+		}
+	}
+	return wc, nil
+}
+
+// FormatMETARConditions returns the visibility and sky condition for a
+// METAR with the given conditions.
+func FormatMETARConditions(wc WeatherConditions) string {
+	var vis string
+	switch {
+	case wc.Visibility >= 10:
+		vis = "10SM"
+	case wc.Visibility >= 1 && wc.Visibility == float32(int(wc.Visibility)):
+		vis = fmt.Sprintf("%dSM", int(wc.Visibility))
+	default:
+		whole := int(wc.Visibility)
+		quarters := int(4*(wc.Visibility-float32(whole)) + 0.5)
+		frac := []string{"", "1/4", "1/2", "3/4", ""}[quarters]
+		if quarters == 4 {
+			whole++
+		}
+		switch {
+		case whole == 0 && frac == "":
+			vis = "0SM"
+		case whole == 0:
+			vis = frac + "SM"
+		case frac == "":
+			vis = fmt.Sprintf("%dSM", whole)
+		default:
+			vis = fmt.Sprintf("%d %sSM", whole, frac)
+		}
+	}
+	if wc.Visibility < 3 {
+		vis += Select(wc.Visibility < 0.625, " FG", " BR")
+	}
+
+	if wc.Ceiling >= unlimitedCeiling {
+		return vis + " CLR"
+	}
+	return vis + fmt.Sprintf(" OVC%03d", wc.Ceiling/100)
+}
+
+// WeatherConditions returns the ceiling and visibility that the scenario
+// specifies.
+func (s *Scenario) WeatherConditions() WeatherConditions {
+	wc := WeatherConditions{Ceiling: s.Ceiling, Visibility: s.Visibility}
+	if wc.Ceiling == 0 {
+		wc.Ceiling = unlimitedCeiling
+	}
+	if wc.Visibility == 0 {
+		wc.Visibility = 10
+	}
+	return wc
+}
+
+// WeatherConditions returns the ceiling and visibility at the given
+// airport.
+func (w *World) WeatherConditions(icao string) (WeatherConditions, bool) {
+	if metar := w.GetMETAR(icao); metar != nil {
+		if wc, err := ParseMETARConditions(metar.Weather); err == nil {
+			return wc, true
+		}
+	}
+	return WeatherConditions{}, false
+}
+
+// checkApproachWeather returns the pilot's response if the weather at
+// the destination is below the minimums for the given approach.
+func (ac *Aircraft) checkApproachWeather(id string, w *World) []RadioTransmission {
+	ap := w.GetAirport(ac.FlightPlan.ArrivalAirport)
+	if ap == nil {
+		return nil
+	}
+	appr, ok := ap.Approaches[id]
+	if !ok {
+		return nil
+	}
+	wc, ok := w.WeatherConditions(ac.FlightPlan.ArrivalAirport)
+	if !ok {
+		return nil
+	}
+
+	if wc.Below(approachMinimums[appr.Type]) {
+		return ac.readbackUnexpected("unable the %s, the weather is below minimums.", appr.FullName)
+	}
+	return nil
+}
+
+// maybeMissApproach is called when the aircraft is cleared for the given
+// approach; if the weather is close to the approach's minimums, it may
+// randomly decide to go missed.
+func (ac *Aircraft) maybeMissApproach(id string, w *World) {
+	ap := w.GetAirport(ac.FlightPlan.ArrivalAirport)
+	if ap == nil || ap.Approaches[id] == nil || ac.GoAroundDistance != nil {
+		return
+	}
+	wc, ok := w.WeatherConditions(ac.FlightPlan.ArrivalAirport)
+	if !ok {
+		return
+	}
+
+	mins := approachMinimums[ap.Approaches[id].Type]
+	if wc.Ceiling < mins.Ceiling+lowIMCCeilingMargin || wc.Visibility < lowIMCVisibilityFactor*mins.Visibility {
+		if rand.Float32() < lowIMCMissedProbability {
+			// At or shortly before the decision altitude
+			d := 0.5 + 0.3*rand.Float32()
+			ac.GoAroundDistance = &d
+		}
+	}
+}
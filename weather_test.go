@@ -0,0 +1,61 @@
+// weather_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+)
+
+func TestParseMETARConditions(t *testing.T) {
+	for _, test := range []struct {
+		wx  string
+		exp WeatherConditions
+	}{
+		{"10SM FEW250 18/06", WeatherConditions{Ceiling: unlimitedCeiling, Visibility: 10}},
+		{"1 1/2SM BR BKN008 OVC015 12/11", WeatherConditions{Ceiling: 800, Visibility: 1.5}},
+		{"M1/4SM FG VV002 10/10", WeatherConditions{Ceiling: 200, Visibility: 0.25}},
+		{"P6SM SCT012 OVC035CB", WeatherConditions{Ceiling: 3500, Visibility: 6}},
+		{"", WeatherConditions{Ceiling: unlimitedCeiling, Visibility: 10}},
+	} {
+		wc, err := ParseMETARConditions(test.wx)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.wx, err)
+		} else if wc != test.exp {
+			t.Errorf("%q: got %s, expected %s", test.wx, wc, test.exp)
+		}
+	}
+
+	if _, err := ParseMETARConditions("XSM"); err == nil {
+		t.Errorf("expected error for invalid visibility")
+	}
+}
+
+func TestFormatMETARConditions(t *testing.T) {
+	for _, wc := range []WeatherConditions{
+		{Ceiling: unlimitedCeiling, Visibility: 10},
+		{Ceiling: 800, Visibility: 1.5},
+		{Ceiling: 200, Visibility: 0.5},
+		{Ceiling: 1200, Visibility: 3},
+	} {
+		s := FormatMETARConditions(wc)
+		if parsed, err := ParseMETARConditions(s); err != nil || parsed != wc {
+			t.Errorf("%s: formatted as %q, parsed back as %s (err %v)", wc, s, parsed, err)
+		}
+	}
+}
+
+func TestApproachMinimums(t *testing.T) {
+	lowIFR := WeatherConditions{Ceiling: 400, Visibility: 1}
+	if lowIFR.Below(approachMinimums[ILSApproach]) || lowIFR.Below(approachMinimums[RNAVApproach]) {
+		t.Errorf("%s should allow instrument approaches", lowIFR)
+	}
+	if !lowIFR.Below(approachMinimums[ChartedVisualApproach]) {
+		t.Errorf("%s should not allow visual approaches", lowIFR)
+	}
+	fog := WeatherConditions{Ceiling: 100, Visibility: 0.25}
+	if !fog.Below(approachMinimums[ILSApproach]) {
+		t.Errorf("%s should be below ILS minimums", fog)
+	}
+}
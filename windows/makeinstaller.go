@@ -228,6 +228,18 @@ const xmlTemplate = `<?xml version='1.0' encoding='utf-8'?>
       </Component>
     </DirectoryRef>
 
+    <DirectoryRef Id="INSTALLFOLDER">
+      <Component Id="ViceURLProtocol" Guid="5d0f6c1e-8a3b-4f7e-9c21-7b4e2a9d3f60">
+        <RegistryKey Root="HKCU" Key="Software\Classes\vice">
+          <RegistryValue Type="string" Value="URL:vice Protocol" KeyPath="yes"/>
+          <RegistryValue Name="URL Protocol" Type="string" Value=""/>
+          <RegistryKey Key="shell\open\command">
+            <RegistryValue Type="string" Value="&quot;[#Vice.exe]&quot; &quot;%1&quot;"/>
+          </RegistryKey>
+        </RegistryKey>
+      </Component>
+    </DirectoryRef>
+
     <Feature Id="MyFeature">
       <ComponentRef Id="ViceExe" />
       <ComponentRef Id="SDLDLL" />
@@ -240,6 +252,7 @@ const xmlTemplate = `<?xml version='1.0' encoding='utf-8'?>
       <ComponentRef Id="VideoMapsId" />
       <ComponentRef Id="ApplicationShortcut" />
       <ComponentRef Id="ApplicationShortcutDesktop" />
+      <ComponentRef Id="ViceURLProtocol" />
     </Feature>
   </Product>
 </Wix>
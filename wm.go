@@ -41,6 +41,25 @@ var (
 		keyboardFocusStack []Pane
 
 		lastAircraftResponse string
+
+		// Layout to switch to at the end of the frame, after a layout
+		// hotkey was pressed or one was selected in the menu.
+		pendingLayout string
+
+		// Set when the slew key on a keypad or hardware keyboard was
+		// pressed this frame; the pane under the mouse then sees a
+		// primary button click.
+		slew bool
+
+		// Events from game controllers and MIDI devices this frame and
+		// the resulting values of continuous controls.
+		controlEvents []ControlEvent
+		controls      map[string]float32
+
+		// Extent of the radar scope in the main window, for screenshots.
+		scopeExtent Extent2D
+		// Set when the screenshot key was pressed.
+		takeScreenshot bool
 	}
 )
 
@@ -378,7 +397,7 @@ func wmInit() {
 // menu.
 // wmDrawUI draws any open Pane settings windows.
 func wmDrawUI(p Platform) {
-	globalConfig.DisplayRoot.VisitPanes(func(pane Pane) {
+	globalConfig.VisitAllPanes(func(pane Pane) {
 		if show, ok := wm.showPaneSettings[pane]; ok && *show {
 			if uid, ok := pane.(PaneUIDrawer); ok {
 				imgui.BeginV(wm.showPaneName[pane]+" settings", show, imgui.WindowFlagsAlwaysAutoResize)
@@ -429,15 +448,40 @@ func wmPaneIsPresent(pane Pane, root *DisplayNode) bool {
 	return found
 }
 
+// wmNewKeyboardState returns the keyboard state to give to the Panes,
+// after layout hotkeys and key bindings have been handled. It returns nil
+// if the Panes shouldn't receive keyboard input.
+func wmNewKeyboardState(p Platform) *KeyboardState {
+	wm.slew = false
+	if imgui.CurrentIO().WantCaptureKeyboard() || keyBindingsEditor.capturing || hardwareKeyboardLearning() {
+		return nil
+	}
+	keyboard := NewKeyboardState(p)
+	wmCheckLayoutHotkeys(keyboard)
+	globalConfig.KeyBindings.Apply(keyboard)
+	wm.slew = globalConfig.HardwareKeyboard.Apply(p.KeyEvents(), keyboard)
+	var slew bool
+	wm.controls, slew = globalConfig.Controllers.Apply(wm.controlEvents, keyboard)
+	wm.slew = wm.slew || slew
+	wmCheckScreenshotKey(keyboard)
+	return keyboard
+}
+
 // wmDrawPanes is called each time through the main rendering loop; it
 // handles all of the details of drawing the Panes in the display
 // hierarchy, making sure they don't inadvertently draw over other panes,
 // and providing mouse and keyboard events only to the Pane that should
 // respectively be receiving them.
 func wmDrawPanes(p Platform, r Renderer, w *World, stats *Stats) {
+	wm.controlEvents = globalConfig.Controllers.Poll()
+
 	var filter func(d *DisplayNode) *DisplayNode
 	filter = func(d *DisplayNode) *DisplayNode {
-		if fsp, ok := d.Children[0].Pane.(*FlightStripPane); ok && fsp.HideFlightStrips {
+		if d.SplitLine.Axis == SplitAxisNone {
+			// There may be just a single pane if others have been moved
+			// to separate windows.
+			return d
+		} else if fsp, ok := d.Children[0].Pane.(*FlightStripPane); ok && fsp.HideFlightStrips {
 			return filter(d.Children[1])
 		} else if fsp, ok := d.Children[1].Pane.(*FlightStripPane); ok && fsp.HideFlightStrips {
 			return filter(d.Children[0])
@@ -447,7 +491,11 @@ func wmDrawPanes(p Platform, r Renderer, w *World, stats *Stats) {
 	}
 	root := filter(globalConfig.DisplayRoot)
 
-	if !wmPaneIsPresent(wm.keyboardFocusPane, root) {
+	focusPresent := wmPaneIsPresent(wm.keyboardFocusPane, root)
+	for _, pw := range globalConfig.PaneWindows {
+		focusPresent = focusPresent || wmPaneIsPresent(wm.keyboardFocusPane, pw.DisplayRoot)
+	}
+	if !focusPresent {
 		// It was deleted in the config editor or a new config was loaded.
 		wm.keyboardFocusPane = nil
 	}
@@ -508,12 +556,15 @@ func wmDrawPanes(p Platform, r Renderer, w *World, stats *Stats) {
 	commandBuffer.ClearRGB(RGB{})
 
 	// Actually visit the panes.
-	var keyboard *KeyboardState
-	if !imgui.CurrentIO().WantCaptureKeyboard() {
-		keyboard = NewKeyboardState(p)
-	}
+	keyboard := wmNewKeyboardState(p)
+	wm.scopeExtent = paneDisplayExtent
+	foundScope := false
 	root.VisitPanesWithBounds(paneDisplayExtent, paneDisplayExtent,
 		func(paneExtent Extent2D, parentExtent Extent2D, pane Pane) {
+			if _, ok := pane.(*STARSPane); ok && !foundScope {
+				wm.scopeExtent = paneExtent
+				foundScope = true
+			}
 			haveFocus := pane == wm.keyboardFocusPane && !imgui.CurrentIO().WantCaptureKeyboard()
 			ctx := PaneContext{
 				paneExtent:       paneExtent,
@@ -522,6 +573,7 @@ func wmDrawPanes(p Platform, r Renderer, w *World, stats *Stats) {
 				renderer:         r,
 				world:            w,
 				keyboard:         keyboard,
+				controls:         wm.controls,
 				haveFocus:        haveFocus}
 
 			// Similarly make the mouse events available only to the
@@ -534,6 +586,9 @@ func wmDrawPanes(p Platform, r Renderer, w *World, stats *Stats) {
 				// Full display size, including the menu and status bar.
 				displayTrueFull := Extent2D{p0: [2]float32{0, 0}, p1: [2]float32{displaySize[0], displaySize[1]}}
 				ctx.InitializeMouse(displayTrueFull)
+				if wm.slew {
+					ctx.mouse.Clicked[MouseButtonPrimary] = true
+				}
 			}
 
 			// Specify the scissor rectangle and viewport that
@@ -0,0 +1,222 @@
+// wmm.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+// World Magnetic Model: if the WMM coefficient file is available in
+// resources/WMM.COF, magnetic variation is computed from the model for any
+// position and date; otherwise the precomputed CONUS grid in
+// magnetic_grid.txt.zst is used. The coefficient file can be downloaded
+// directly from https://www.ncei.noaa.gov/products/world-magnetic-model;
+// it should be copied unmodified.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type MagneticModel struct {
+	Name      string  // e.g., "WMM-2025"
+	Epoch     float64 // decimal year
+	MaxDegree int
+	// Schmidt semi-normalized Gauss coefficients and their annual rates
+	// of change, indexed [n][m].
+	G, H, GDot, HDot [][]float64
+}
+
+func parseWMMCoefficients(b []byte) (*MagneticModel, error) {
+	r := bufio.NewScanner(bytes.NewReader(b))
+	if !r.Scan() {
+		return nil, fmt.Errorf("missing header")
+	}
+
+	var mm MagneticModel
+	hdr := strings.Fields(r.Text())
+	if len(hdr) == 0 {
+		return nil, fmt.Errorf("empty header")
+	}
+	var err error
+	if mm.Epoch, err = strconv.ParseFloat(hdr[0], 64); err != nil {
+		return nil, fmt.Errorf("%s: invalid epoch: %v", r.Text(), err)
+	}
+	if len(hdr) > 1 {
+		mm.Name = hdr[1]
+	}
+
+	type coeff struct {
+		n, m         int
+		g, h, gd, hd float64
+	}
+	var coeffs []coeff
+	for r.Scan() {
+		line := strings.TrimSpace(r.Text())
+		if line == "" {
+			continue
+		} else if strings.HasPrefix(line, "9999") {
+			break
+		}
+
+		f := strings.Fields(line)
+		if len(f) != 6 {
+			return nil, fmt.Errorf("%s: expected 6 fields", line)
+		}
+		var c coeff
+		if c.n, err = strconv.Atoi(f[0]); err != nil {
+			return nil, fmt.Errorf("%s: %v", line, err)
+		}
+		if c.m, err = strconv.Atoi(f[1]); err != nil {
+			return nil, fmt.Errorf("%s: %v", line, err)
+		}
+		var v [4]float64
+		for i := range v {
+			if v[i], err = strconv.ParseFloat(f[2+i], 64); err != nil {
+				return nil, fmt.Errorf("%s: %v", line, err)
+			}
+		}
+		c.g, c.h, c.gd, c.hd = v[0], v[1], v[2], v[3]
+		if c.n < 1 || c.m < 0 || c.m > c.n {
+			return nil, fmt.Errorf("%s: invalid degree/order", line)
+		}
+		coeffs = append(coeffs, c)
+		mm.MaxDegree = max(mm.MaxDegree, c.n)
+	}
+	if len(coeffs) == 0 {
+		return nil, fmt.Errorf("no coefficients found")
+	}
+
+	alloc := func() [][]float64 {
+		a := make([][]float64, mm.MaxDegree+1)
+		for n := range a {
+			a[n] = make([]float64, n+1)
+		}
+		return a
+	}
+	mm.G, mm.H, mm.GDot, mm.HDot = alloc(), alloc(), alloc(), alloc()
+	for _, c := range coeffs {
+		mm.G[c.n][c.m], mm.H[c.n][c.m] = c.g, c.h
+		mm.GDot[c.n][c.m], mm.HDot[c.n][c.m] = c.gd, c.hd
+	}
+
+	return &mm, nil
+}
+
+func parseMagneticModel() *MagneticModel {
+	if !resourceExists("WMM.COF") {
+		return nil
+	}
+	mm, err := parseWMMCoefficients(LoadResource("WMM.COF"))
+	if err != nil {
+		lg.Errorf("WMM.COF: %v", err)
+		return nil
+	}
+	return mm
+}
+
+// decimalYear returns the given time as a fractional year, e.g. 2024.5
+// for the start of July.
+func decimalYear(t time.Time) float64 {
+	t = t.UTC()
+	start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	return float64(t.Year()) + float64(t.Sub(start))/float64(end.Sub(start))
+}
+
+// Declination returns the magnetic declination in degrees (positive east)
+// at the given position, altitude in feet above mean sea level, and time.
+func (mm *MagneticModel) Declination(p Point2LL, alt float32, t time.Time) float32 {
+	const (
+		a  = 6371.2   // geomagnetic reference radius, km
+		re = 6378.137 // WGS84 semi-major axis, km
+		f  = 1 / 298.257223563
+		e2 = f * (2 - f)
+	)
+	lat, long := float64(p[1])*math.Pi/180, float64(p[0])*math.Pi/180
+	h := float64(alt) * 0.0003048 // feet to km
+	dt := decimalYear(t) - mm.Epoch
+
+	// Geodetic to geocentric spherical coordinates.
+	sinLat, cosLat := math.Sincos(lat)
+	rc := re / math.Sqrt(1-e2*sinLat*sinLat)
+	px, pz := (rc+h)*cosLat, (rc*(1-e2)+h)*sinLat
+	r := math.Sqrt(px*px + pz*pz)
+	latc := math.Asin(pz / r)
+
+	// Schmidt semi-normalized associated Legendre functions of cos(theta),
+	// where theta is the geocentric colatitude, and their derivatives with
+	// respect to theta.
+	ct, st := math.Sin(latc), math.Cos(latc)
+	N := mm.MaxDegree
+	P, dP := make([][]float64, N+1), make([][]float64, N+1)
+	for n := 0; n <= N; n++ {
+		P[n], dP[n] = make([]float64, n+1), make([]float64, n+1)
+	}
+	P[0][0] = 1
+	for n := 1; n <= N; n++ {
+		for m := 0; m <= n; m++ {
+			if m == n {
+				k := 1.
+				if n > 1 {
+					k = math.Sqrt(float64(2*n-1) / float64(2*n))
+				}
+				P[n][n] = k * st * P[n-1][n-1]
+				dP[n][n] = k * (ct*P[n-1][n-1] + st*dP[n-1][n-1])
+			} else {
+				var p2, dp2 float64
+				if n >= 2 && m <= n-2 {
+					p2, dp2 = P[n-2][m], dP[n-2][m]
+				}
+				k1 := float64(2*n - 1)
+				k2 := math.Sqrt(float64((n-1)*(n-1) - m*m))
+				k := math.Sqrt(float64(n*n - m*m))
+				P[n][m] = (k1*ct*P[n-1][m] - k2*p2) / k
+				dP[n][m] = (k1*(ct*dP[n-1][m]-st*P[n-1][m]) - k2*dp2) / k
+			}
+		}
+	}
+
+	// Field components in geocentric spherical coordinates.
+	var br, btheta, bphi float64
+	ar := a / r
+	arn := ar * ar
+	for n := 1; n <= N; n++ {
+		arn *= ar // (a/r)^(n+2)
+		for m := 0; m <= n; m++ {
+			g := mm.G[n][m] + dt*mm.GDot[n][m]
+			hh := mm.H[n][m] + dt*mm.HDot[n][m]
+			sm, cm := math.Sincos(float64(m) * long)
+			gh := g*cm + hh*sm
+
+			br += float64(n+1) * arn * gh * P[n][m]
+			btheta -= arn * gh * dP[n][m]
+			if st != 0 {
+				bphi -= arn * float64(m) * (-g*sm + hh*cm) * P[n][m] / st
+			}
+		}
+	}
+
+	// Rotate from geocentric to geodetic north.
+	xc, zc := -btheta, -br
+	psi := latc - lat
+	x := xc*math.Cos(psi) - zc*math.Sin(psi)
+	y := bphi
+
+	return float32(math.Atan2(y, x) * 180 / math.Pi)
+}
+
+// MagneticVariation returns the magnetic variation at the given point and
+// time, using the WMM if available and otherwise the precomputed grid.
+// Following the convention used elsewhere, the sign is flipped with
+// respect to declination, so that magnetic headings are given by adding
+// the variation to true headings.
+func (d *StaticDatabase) MagneticVariation(p Point2LL, t time.Time) (float32, error) {
+	if d.MagneticModel != nil {
+		return -d.MagneticModel.Declination(p, 0, t), nil
+	}
+	return d.MagneticGrid.Lookup(p)
+}
@@ -0,0 +1,103 @@
+// wmm_test.go
+// Copyright(c) 2022 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMagneticModel(t *testing.T) {
+	cof := `    2025.0            WMM-2025     11/13/2024
+  1  0  -30000.0       0.0        0.0        0.0
+  1  1       0.0    5000.0        0.0      100.0
+999999999999999999999999999999999999999999999999
+999999999999999999999999999999999999999999999999
+`
+	mm, err := parseWMMCoefficients([]byte(cof))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mm.Epoch != 2025 || mm.MaxDegree != 1 {
+		t.Fatalf("got epoch %f max degree %d, expected 2025 and 1", mm.Epoch, mm.MaxDegree)
+	}
+
+	// For a dipole field on the equator at the prime meridian, the
+	// declination is atan2(-h11, -g10).
+	epoch := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := float32(math.Atan2(-5000, 30000) * 180 / math.Pi)
+	if d := mm.Declination(Point2LL{0, 0}, 0, epoch); abs(d-expected) > 0.01 {
+		t.Errorf("got declination %f, expected %f", d, expected)
+	}
+
+	// Ten years later, h11 has grown to 6000.
+	expected = float32(math.Atan2(-6000, 30000) * 180 / math.Pi)
+	if d := mm.Declination(Point2LL{0, 0}, 0, epoch.AddDate(10, 0, 0)); abs(d-expected) > 0.01 {
+		t.Errorf("got declination %f, expected %f", d, expected)
+	}
+
+	if _, err := parseWMMCoefficients([]byte("2025.0\n  1  0  -30000.0\n")); err == nil {
+		t.Errorf("expected error for malformed coefficients")
+	}
+}
+
+func TestDecimalYear(t *testing.T) {
+	if y := decimalYear(time.Date(2023, 7, 2, 12, 0, 0, 0, time.UTC)); math.Abs(y-2023.5) > 0.001 {
+		t.Errorf("got %f, expected 2023.5", y)
+	}
+}
+
+func TestMagneticModelReferenceValues(t *testing.T) {
+	b, err := os.ReadFile("resources/WMM.COF")
+	if err != nil {
+		t.Skipf("WMM coefficients not available: %v", err)
+	}
+	mm, err := parseWMMCoefficients(b)
+	if err != nil {
+		t.Fatalf("WMM.COF: %v", err)
+	}
+
+	// Test values published by NOAA along with each model: decimal year,
+	// height above the WGS84 ellipsoid in km, latitude, longitude, and
+	// declination in degrees.
+	type refValue struct {
+		year, height, lat, long, decl float64
+	}
+	reference := map[string][]refValue{
+		"WMM-2020": {
+			{2020.0, 0, 80, 0, -1.28},
+			{2020.0, 0, 0, 120, 0.16},
+			{2020.0, 0, -80, 240, 69.36},
+			{2020.0, 100, 80, 0, -1.70},
+			{2020.0, 100, 0, 120, 0.16},
+			{2020.0, 100, -80, 240, 68.78},
+			{2022.5, 0, 80, 0, 0.01},
+			{2022.5, 0, 0, 120, -0.06},
+			{2022.5, 0, -80, 240, 69.13},
+			{2022.5, 100, 80, 0, -0.41},
+			{2022.5, 100, 0, 120, -0.05},
+			{2022.5, 100, -80, 240, 68.55},
+		},
+	}
+	values, ok := reference[mm.Name]
+	if !ok {
+		t.Skipf("%s: no reference values", mm.Name)
+	}
+
+	for _, v := range values {
+		year := int(v.year)
+		tm := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		tm = tm.Add(time.Duration((v.year - float64(year)) * float64(tm.AddDate(1, 0, 0).Sub(tm))))
+		p := Point2LL{float32(v.long), float32(v.lat)}
+		alt := float32(v.height * 1000 / 0.3048) // km to feet
+
+		// The reference values are rounded to 0.01 degrees.
+		if d := mm.Declination(p, alt, tm); math.Abs(float64(d)-v.decl) > 0.0101 {
+			t.Errorf("%+v: got declination %.3f", v, d)
+		}
+	}
+}
@@ -36,6 +36,8 @@ type World struct {
 	lastUpdateRequest time.Time
 	lastReturnedTime  time.Time
 	updateCall        *PendingCall
+	updateLatency     time.Duration
+	simUpdateDuration time.Duration
 	showSettings      bool
 	showScenarioInfo  bool
 
@@ -64,16 +66,20 @@ type World struct {
 	SimDescription          string
 	SimTime                 time.Time
 	MagneticVariation       float32
+	MagneticAdjustment      float32
 	NmPerLongitude          float32
 	Airports                map[string]*Airport
 	Fixes                   map[string]Point2LL
 	PrimaryAirport          string
 	RadarSites              map[string]*RadarSite
+	FailedRadarSites        map[string]bool
 	Center                  Point2LL
 	Range                   float32
 	DefaultMaps             []string
 	STARSMaps               []STARSMap
 	InhibitCAVolumes        []AirspaceVolume
+	ClassAirspace           []ClassAirspace
+	Sectors                 map[string]*Sector
 	Wind                    Wind
 	Callsign                string
 	ApproachAirspace        []ControllerAirspaceVolume
@@ -85,6 +91,8 @@ type World struct {
 	TotalDepartures         int
 	TotalArrivals           int
 	STARSFacilityAdaptation STARSFacilityAdaptation
+	Objectives              []ObjectiveReport
+	Tutorial                TutorialReport
 
 	STARSInputOverride string
 }
@@ -115,6 +123,7 @@ func (w *World) Assign(other *World) {
 	w.SimDescription = other.SimDescription
 	w.SimTime = other.SimTime
 	w.MagneticVariation = other.MagneticVariation
+	w.MagneticAdjustment = other.MagneticAdjustment
 	w.NmPerLongitude = other.NmPerLongitude
 	w.Airports = other.Airports
 	w.Fixes = other.Fixes
@@ -125,6 +134,8 @@ func (w *World) Assign(other *World) {
 	w.DefaultMaps = other.DefaultMaps
 	w.STARSMaps = other.STARSMaps
 	w.InhibitCAVolumes = other.InhibitCAVolumes
+	w.ClassAirspace = other.ClassAirspace
+	w.Sectors = other.Sectors
 	w.Wind = other.Wind
 	w.Callsign = other.Callsign
 	w.ApproachAirspace = other.ApproachAirspace
@@ -160,6 +171,15 @@ func (w *World) AverageWindVector() [2]float32 {
 	return scale2f(v, float32(w.Wind.Speed))
 }
 
+// MagneticVariationAt returns the magnetic variation at the given point,
+// including the scenario's magnetic adjustment.
+func (w *World) MagneticVariationAt(p Point2LL) float32 {
+	if mvar, err := database.MagneticVariation(p, w.SimTime); err == nil {
+		return mvar + w.MagneticAdjustment
+	}
+	return w.MagneticVariation
+}
+
 func (w *World) GetAirport(icao string) *Airport {
 	return w.Airports[icao]
 }
@@ -192,12 +212,24 @@ func (w *World) AllAirports() map[string]*Airport {
 	return all
 }
 
-func (w *World) SetSquawk(callsign string, squawk Squawk) error {
-	return nil // UNIMPLEMENTED
+func (w *World) SetSquawk(callsign string, squawk Squawk, success func(any), err func(error)) {
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.SetSquawk(callsign, squawk),
+			IssueTime: time.Now(),
+			OnSuccess: success,
+			OnErr:     err,
+		})
 }
 
-func (w *World) SetSquawkAutomatic(callsign string) error {
-	return nil // UNIMPLEMENTED
+func (w *World) SetSquawkAutomatic(callsign string, success func(any), err func(error)) {
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.SetSquawkAutomatic(callsign),
+			IssueTime: time.Now(),
+			OnSuccess: success,
+			OnErr:     err,
+		})
 }
 
 func (w *World) TakeOrReturnLaunchControl(eventStream *EventStream) {
@@ -272,8 +304,19 @@ func (w *World) SetTemporaryAltitude(callsign string, alt int, success func(any)
 		})
 }
 
-func (w *World) AmendFlightPlan(callsign string, fp FlightPlan) error {
-	return nil // UNIMPLEMENTED
+func (w *World) AmendFlightPlan(callsign string, fp FlightPlan, success func(any), err func(error)) {
+	if ac := w.Aircraft[callsign]; ac != nil &&
+		(ac.TrackingController == w.Callsign || (ac.IsDeparture() && !ac.IsAirborne())) {
+		ac.FlightPlan = &fp
+	}
+
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.AmendFlightPlan(callsign, fp),
+			IssueTime: time.Now(),
+			OnSuccess: success,
+			OnErr:     err,
+		})
 }
 
 func (w *World) SetGlobalLeaderLine(callsign string, dir *CardinalOrdinalDirection, success func(any), err func(error)) {
@@ -449,6 +492,26 @@ func (w *World) ChangeControlPosition(callsign string, keepTracks bool) error {
 	return err
 }
 
+func (w *World) RequestRelief(callsign string, success func(any), err func(error)) {
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.RequestRelief(callsign),
+			IssueTime: time.Now(),
+			OnSuccess: success,
+			OnErr:     err,
+		})
+}
+
+func (w *World) AcceptRelief(success func(any), err func(error)) {
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.AcceptRelief(),
+			IssueTime: time.Now(),
+			OnSuccess: success,
+			OnErr:     err,
+		})
+}
+
 func (w *World) Disconnect() {
 	if err := w.simProxy.SignOff(nil, nil); err != nil {
 		lg.Errorf("Error signing off from sim: %v", err)
@@ -561,6 +624,7 @@ func (w *World) GetUpdates(eventStream *EventStream, onErr func(error)) {
 			IssueTime: time.Now(),
 			OnSuccess: func(any) {
 				d := time.Since(w.updateCall.IssueTime)
+				w.updateLatency = d
 				if d > 250*time.Millisecond {
 					lg.Warnf("Slow world update response %s", d)
 				} else {
@@ -593,6 +657,13 @@ func (w *World) ToggleSimPause() {
 	})
 }
 
+func (w *World) AdvanceTutorial() {
+	w.pendingCalls = append(w.pendingCalls, &PendingCall{
+		Call:      w.simProxy.AdvanceTutorial(),
+		IssueTime: time.Now(),
+	})
+}
+
 func (w *World) GetSimRate() float32 {
 	if w.SimRate == 0 {
 		return 1
@@ -608,6 +679,63 @@ func (w *World) SetSimRate(r float32) {
 	w.SimRate = r // so the UI is well-behaved...
 }
 
+func (w *World) SetRadarSiteFailed(site string, failed bool, eventStream *EventStream) {
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.SetRadarSiteFailed(site, failed),
+			IssueTime: time.Now(),
+			OnErr: func(e error) {
+				eventStream.Post(Event{
+					Type:    StatusMessageEvent,
+					Message: e.Error(),
+				})
+			},
+		})
+}
+
+// RadarSiteFailed returns true if the given radar site is out of service.
+func (w *World) RadarSiteFailed(site string) bool {
+	return w.FailedRadarSites[site]
+}
+
+// SetArrivalRate changes the launch rate for arrivals to the airport via
+// the arrival group while the sim is running.
+func (w *World) SetArrivalRate(group, airport string, rate int, eventStream *EventStream) {
+	if rates, ok := w.LaunchConfig.ArrivalGroupRates[group]; ok {
+		rates[airport] = rate // for the UI's benefit...
+	}
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.SetArrivalRate(group, airport, rate),
+			IssueTime: time.Now(),
+			OnErr: func(e error) {
+				eventStream.Post(Event{
+					Type:    StatusMessageEvent,
+					Message: group + "/" + airport + ": " + e.Error(),
+				})
+			},
+		})
+}
+
+// SetDepartureRate changes the launch rate for departures from the
+// airport's runway while the sim is running.
+func (w *World) SetDepartureRate(airport, runway, category string, rate int, eventStream *EventStream) {
+	if rates, ok := w.LaunchConfig.DepartureRates[airport][runway]; ok {
+		rates[category] = rate // for the UI's benefit...
+	}
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.SetDepartureRate(airport, runway, category, rate),
+			IssueTime: time.Now(),
+			OnErr: func(e error) {
+				eventStream.Post(Event{
+					Type:    StatusMessageEvent,
+					Message: airport + "/" + runway + ": " + e.Error(),
+				})
+			},
+		})
+}
+
 func (w *World) SetLaunchConfig(lc LaunchConfig) {
 	w.pendingCalls = append(w.pendingCalls, &PendingCall{
 		Call:      w.simProxy.SetLaunchConfig(lc),
@@ -686,6 +814,31 @@ func (w *World) DeleteAircraft(ac *Aircraft, onErr func(err error)) {
 	}
 }
 
+// EditAircraft asks the sim to apply instructor edits to the aircraft;
+// see instructor.go.
+func (w *World) EditAircraft(callsign string, edit AircraftEdit, onErr func(err error)) {
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.EditAircraft(callsign, edit),
+			IssueTime: time.Now(),
+			OnErr:     onErr,
+		})
+}
+
+// ProbeTrialPlan asks the sim to predict the conflicts that would result
+// from the trial plan; see conflictprobe.go.
+func (w *World) ProbeTrialPlan(callsign string, plan TrialPlan, handleResult func([]ProbeConflict),
+	onErr func(err error)) {
+	var result []ProbeConflict
+	w.pendingCalls = append(w.pendingCalls,
+		&PendingCall{
+			Call:      w.simProxy.ProbeTrialPlan(callsign, plan, &result),
+			IssueTime: time.Now(),
+			OnSuccess: func(any) { handleResult(result) },
+			OnErr:     onErr,
+		})
+}
+
 func (w *World) RunAircraftCommands(callsign string, cmds string, handleResult func(message string, remainingInput string)) {
 	var result AircraftCommandsResult
 	w.pendingCalls = append(w.pendingCalls,
@@ -844,6 +997,16 @@ func (w *World) CreateArrival(arrivalGroup string, arrivalAirport string, goArou
 		return nil, fmt.Errorf("unable to sample a valid aircraft")
 	}
 
+	// Arrivals that are told to expect an RNAV approach when they spawn
+	// must be able to fly it.
+	requireGNSS := false
+	if ap := w.GetAirport(arrivalAirport); ap != nil {
+		if appr, ok := ap.Approaches[arr.ExpectApproach]; ok && appr.Type == RNAVApproach {
+			requireGNSS = true
+		}
+	}
+	acType += "/" + sampleEquipmentSuffix(requireGNSS)
+
 	ac.FlightPlan = NewFlightPlan(IFR, acType, airline.Airport, arrivalAirport)
 
 	// Figure out which controller will (for starters) get the arrival
@@ -923,6 +1086,7 @@ func (w *World) CreateDeparture(departureAirport, runway, category string, chall
 		return nil, nil, fmt.Errorf("unable to sample a valid aircraft")
 	}
 
+	acType += "/" + sampleEquipmentSuffix(false)
 	ac.FlightPlan = NewFlightPlan(IFR, acType, departureAirport, dep.Destination)
 	exitRoute := rwy.ExitRoutes[dep.Exit]
 	if err := ac.InitializeDeparture(w, ap, departureAirport, dep, runway, exitRoute); err != nil {
@@ -1186,6 +1350,11 @@ func (w *World) DrawScenarioInfoWindow() {
 		}
 	}
 
+	imgui.Separator()
+	if imgui.CollapsingHeader("Runway Winds") {
+		w.drawRunwayWinds(tableFlags)
+	}
+
 	imgui.End()
 }
 
@@ -1199,7 +1368,8 @@ func (w *World) DrawScenarioRoutes(transforms ScopeTransformations, font *Font,
 	defer ReturnTextDrawBuilder(td)
 	ld := GetLinesDrawBuilder()
 	defer ReturnLinesDrawBuilder(ld)
-	pd := &PointsDrawBuilder{}
+	pd := GetPointsDrawBuilder()
+	defer ReturnPointsDrawBuilder(pd)
 	ldr := GetLinesDrawBuilder() // for restrictions--in window coords...
 	defer ReturnLinesDrawBuilder(ldr)
 
@@ -1638,25 +1808,58 @@ func (w *World) DrawSettingsWindow() {
 	globalConfig.InhibitDiscordActivity.Store(!update)
 
 	if imgui.BeginComboV("UI Font Size", strconv.Itoa(globalConfig.UIFontSize), imgui.ComboFlagsHeightLarge) {
-		sizes := make(map[int]interface{})
-		for fontid := range fonts {
-			if fontid.Name == "Roboto Regular" {
-				sizes[fontid.Size] = nil
+		for _, size := range uiFontSizes() {
+			if size < globalConfig.Accessibility.MinimumFontSize {
+				continue
 			}
-		}
-		for _, size := range SortedMapKeys(sizes) {
 			if imgui.SelectableV(strconv.Itoa(size), size == globalConfig.UIFontSize, 0, imgui.Vec2{}) {
 				globalConfig.UIFontSize = size
-				ui.font = GetFont(FontIdentifier{Name: "Roboto Regular", Size: globalConfig.UIFontSize})
+				ui.font = GetFont(FontIdentifier{Name: "Roboto Regular", Size: globalConfig.uiFontSize()})
 			}
 		}
 		imgui.EndCombo()
 	}
+	uiDrawScaleSettings()
+	imgui.Checkbox("Touchscreen / pen input (drag to pan, double-tap and drag to zoom)", &globalConfig.TouchInput)
+
+	rendererNames := map[string]string{"": "OpenGL 3.3 (if available)", "gl2": "OpenGL 2.1"}
+	if imgui.BeginComboV("Renderer", rendererNames[globalConfig.Renderer], 0) {
+		for _, r := range SortedMapKeys(rendererNames) {
+			if imgui.SelectableV(rendererNames[r], r == globalConfig.Renderer, 0, imgui.Vec2{}) {
+				globalConfig.Renderer = r
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.SameLine()
+	imgui.Text(Select(platform.OpenGLCoreProfile(), "(using OpenGL 3.3;", "(using OpenGL 2.1;") +
+		" changes take effect after restarting vice)")
+
+	uiDrawScreenshotSettings()
+	if imgui.Button("Colors and theme...") {
+		uiToggleShowThemeEditor()
+	}
+	imgui.SameLine()
+	if imgui.Button("Key bindings and aliases...") {
+		uiToggleShowKeyBindingsEditor()
+	}
+	imgui.SameLine()
+	if imgui.Button("Keypad / hardware keyboard...") {
+		uiToggleShowHardwareKeyboardEditor()
+	}
+	imgui.SameLine()
+	if imgui.Button("Controllers / MIDI...") {
+		uiToggleShowControllerEditor()
+	}
+	imgui.SameLine()
+	if imgui.Button("STARS adaptation...") {
+		uiToggleShowAdaptationEditor(w)
+	}
 
 	var fsp *FlightStripPane
 	var messages *MessagesPane
 	var stars *STARSPane
-	globalConfig.DisplayRoot.VisitPanes(func(p Pane) {
+	globalConfig.VisitAllPanes(func(p Pane) {
 		switch pane := p.(type) {
 		case *FlightStripPane:
 			fsp = pane
@@ -1673,6 +1876,8 @@ func (w *World) DrawSettingsWindow() {
 
 	if imgui.CollapsingHeader("Audio") {
 		globalConfig.Audio.DrawUI()
+		imgui.Separator()
+		globalConfig.RadioCongestion.DrawUI()
 	}
 	if fsp != nil && imgui.CollapsingHeader("Flight Strips") {
 		fsp.DrawUI()
@@ -1680,6 +1885,12 @@ func (w *World) DrawSettingsWindow() {
 	if messages != nil && imgui.CollapsingHeader("Messages") {
 		messages.DrawUI()
 	}
+	if imgui.CollapsingHeader("Accessibility") {
+		globalConfig.Accessibility.DrawUI()
+	}
+	if imgui.CollapsingHeader("Logging") {
+		uiDrawLogLevelSettings()
+	}
 
 	imgui.End()
 }